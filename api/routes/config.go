@@ -5,15 +5,18 @@ import (
 	"pipeliner/internal/services"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
-func InitConfigRoutes(router *gin.RouterGroup, db *gorm.DB) {
-	configService := services.NewConfigService()
+func InitConfigRoutes(router *gin.RouterGroup, configService services.ConfigServiceMethods) {
+	admin := handlers.RequireAdmin()
 	handlers := handlers.NewConfigHandler(configService)
 
 	configRoutes := router.Group("/config")
 	{
 		configRoutes.GET("", handlers.GetScanModules)
+		configRoutes.GET("/:name", handlers.GetModuleSource)
+		configRoutes.GET("/:name/history", handlers.GetModuleHistory)
+		configRoutes.POST("/:name/validate", admin, handlers.ValidateModule)
+		configRoutes.PUT("/:name", admin, handlers.SaveModule)
 	}
 }
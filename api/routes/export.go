@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"pipeliner/internal/handlers"
+	"pipeliner/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InitExportRoutes(router *gin.RouterGroup, exportService services.ExportServiceMethods) {
+	handlers := handlers.NewExportHandler(exportService)
+
+	router.GET("/scans/:id/export", handlers.ExportScan)
+}
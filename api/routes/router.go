@@ -3,20 +3,34 @@ package routes
 import (
 	"os"
 	"path/filepath"
+	"pipeliner/internal/config"
 	"pipeliner/internal/dao"
+	"pipeliner/internal/handlers"
 	"pipeliner/internal/handlers/web"
+	"pipeliner/internal/i18n"
+	"pipeliner/internal/metrics"
+	"pipeliner/internal/models"
 	"pipeliner/internal/services"
+	"pipeliner/internal/vulndb"
+	"pipeliner/pkg/blobstore"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func InitRouter(db *gorm.DB) *gin.Engine {
-	router := gin.Default()
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"http://127.0.0.1:3000"}
-	router.Use(cors.New(config))
+// InitRouter builds the server's routes and the services behind them.
+// Findings are scored from vulnDB. closeServices stops the services'
+// background work; call it once the scans have stopped.
+func InitRouter(db *gorm.DB, cfg *config.Config, vulnDB *vulndb.Cache) (router *gin.Engine, closeServices func()) {
+	router = gin.Default()
+	// lets templates rendered with the gin context see the request locale
+	router.ContextWithFallback = true
+	i18n.SetDevMode(gin.IsDebugging())
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = []string{"http://127.0.0.1:3000"}
+	router.Use(cors.New(corsConfig))
+	router.Use(i18n.Middleware())
 	cwd, err := os.Getwd()
 	if err != nil {
 		panic("failed to get current working directory: " + err.Error())
@@ -25,34 +39,102 @@ func InitRouter(db *gorm.DB) *gin.Engine {
 	staticDir := filepath.Join(cwd, "static")
 	scansDir := filepath.Join(cwd, "scans")
 
-	router.Static("/static", staticDir)
-	router.Static("/scan-files", scansDir)
+	findingDao := dao.NewFindingDAO(db)
+	scanOptions := []services.ScanServiceOption{
+		services.WithMaxBacklog(cfg.MaxQueuedScans),
+		services.WithReleaseSlotOnPause(cfg.ReleaseSlotOnPause),
+		services.WithDefaultWebhooks(cfg.Webhooks()...),
+		services.WithMonitorConfig(cfg.Monitor),
+		services.WithFindingDAO(findingDao),
+		services.WithVulnDB(vulnDB),
+		services.WithRestartRecovery(),
+		services.WithDuplicateCheck(cfg.DuplicateScanWindow, cfg.RejectDuplicateScans),
+		services.WithDiskQuota(cfg.ScanDiskQuotaMB),
+		services.WithDiscoveryNotifications(cfg.NotifyFirstSubdomain, cfg.NotifyNoSubdomains),
+	}
+	registry := metrics.NewRegistry()
+	scanOptions = append(scanOptions, services.WithSLOMetrics(registry, cfg.SLO))
+	resourceGuard := services.NewResourceGuard(cfg.ResourceGuard, services.NewSystemSampler(scansDir))
+	scanOptions = append(scanOptions, services.WithResourceGuard(resourceGuard))
+	nicePolicy, err := cfg.NicePolicy()
+	if err != nil {
+		panic("invalid scan priority niceness: " + err.Error())
+	}
+	scanOptions = append(scanOptions, services.WithNicePolicy(nicePolicy))
+	severityRules, err := cfg.SeverityRules()
+	if err != nil {
+		panic("invalid severity rules: " + err.Error())
+	}
+	scanOptions = append(scanOptions, services.WithSeverityRules(severityRules))
+	teamDao := dao.NewTeamDAO(db)
+	defaultTeam, err := teamDao.EnsureTeam(models.DefaultTeamName)
+	if err != nil {
+		panic("failed to create the default team: " + err.Error())
+	}
+	scanOptions = append(scanOptions, services.WithDefaultTeam(defaultTeam.ID))
+	// without tokens every request sees every team's scans
+	auth := handlers.TokenAuth(cfg.APITokens, services.NewTeamService(teamDao))
+	var artifactStore blobstore.Store = blobstore.NewLocal(scansDir)
+	switch cfg.ArtifactStore {
+	case "local":
+	case "s3":
+		s3, err := blobstore.NewS3(cfg.S3)
+		if err != nil {
+			panic("failed to configure the s3 artifact store: " + err.Error())
+		}
+		artifactStore = s3
+		scanOptions = append(scanOptions, services.WithArtifactStore(s3))
+	default:
+		panic("unknown ARTIFACT_STORE " + cfg.ArtifactStore + ", expected local or s3")
+	}
+
+	// only the health checks are served without a token
+	router.GET("/api/health", handlers.HealthWithResources(resourceGuard))
+	router.GET("/healthz", handlers.HealthWithResources(resourceGuard))
+	router.Group("/static", auth...).Static("/", staticDir)
+	router.GET("/metrics", append(auth, gin.WrapH(registry.Handler()))...)
 
 	indexWebHandlers := web.NewIndexHandler()
 	scanDao := dao.NewScanDAO(db)
-	scanService := services.NewScanService(scanDao)
-	configService := services.NewConfigService()
+	subdomainDao := dao.NewSubdomainDAO(db)
+	scanService := services.NewScanService(scanDao, subdomainDao, scanOptions...)
+	router.GET("/scan-files/*path", append(auth, web.NewScanFileHandler(artifactStore, scanService, scansDir).ServeFile)...)
+	exportService := services.NewExportService(scanDao, subdomainDao, findingDao)
+	configService := services.NewConfigService(
+		services.WithConfigChanges(dao.NewConfigChangeDAO(db)),
+		// with tokens off nobody has the admin token saving needs
+		services.WithConfigEdits(cfg.AllowConfigEdits && cfg.APITokens != config.APITokensOff),
+		services.WithScanHistory(scanDao),
+	)
 	configWebHandlers := web.NewConfigWebHandler(configService)
 	scanWebHandler := web.NewScanWebHandler(scanService, configService)
 
 	// REST APIs
-	api := router.Group("/api")
+	api := router.Group("/api", auth...)
 	{
-		InitScanRoutes(api, db)
-		InitConfigRoutes(api, db)
+		InitScanRoutes(api, scanService, resourceGuard)
+		InitConfigRoutes(api, configService)
+		InitExportRoutes(api, exportService)
 	}
 
 	// web pages
-	web := router.Group("/")
+	web := router.Group("/", auth...)
 	{
 		web.GET("/", indexWebHandlers.HomePage)
 		web.GET("/config", configWebHandlers.ConfigPage)
+		web.GET("/config/:name/edit", handlers.RequireAdmin(), configWebHandlers.EditPage)
+		web.POST("/config/:name/validate", handlers.RequireAdmin(), configWebHandlers.Validate)
+		web.POST("/config/:name/save", handlers.RequireAdmin(), configWebHandlers.Save)
 		web.GET("/scan/new", scanWebHandler.StartScanPage)
 		web.GET("/scans/:id/images", scanWebHandler.ScreenShotsPage)
 		web.GET("/scans/:id/subdomains", scanWebHandler.SubdomainsPage)
+		web.GET("/scans/:id/dag", scanWebHandler.DAGFragment)
+		web.GET("/scans/:id/webhooks", scanWebHandler.WebhooksFragment)
+		web.POST("/scans/:id/webhooks/:delivery/redeliver", scanWebHandler.RedeliverWebhook)
+		web.POST("/scans/:id/rerun", scanWebHandler.RerunScan)
 		web.GET("/scans/:id", scanWebHandler.ScanDetailPage)
 		web.GET("/scans", scanWebHandler.ScansPage)
 	}
 
-	return router
+	return router, scanService.Close
 }
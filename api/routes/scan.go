@@ -1,26 +1,36 @@
 package routes
 
 import (
-	"pipeliner/internal/dao"
 	"pipeliner/internal/handlers"
 	"pipeliner/internal/services"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
-func InitScanRoutes(router *gin.RouterGroup, db *gorm.DB) {
-	scanDao := dao.NewScanDAO(db)
-	scanService := services.NewScanService(scanDao)
-	handlers := handlers.NewScanHandler(scanService)
+func InitScanRoutes(router *gin.RouterGroup, scanService services.ScanServiceMethods, resourceGuard *services.ResourceGuard) {
+	handlers := handlers.NewScanHandler(scanService).WithResourceGuard(resourceGuard)
 
 	scanRoutes := router.Group("/scans")
 	{
 		scanRoutes.POST("", handlers.StartScan)
+		scanRoutes.POST("/batch", handlers.StartBatch)
+		scanRoutes.POST("/estimate", handlers.EstimateScan)
 		scanRoutes.GET("/:id", handlers.GetScanByUUID)
 		scanRoutes.GET("/:id/subdomains", handlers.GetScanSubdomains)
+		scanRoutes.GET("/:id/hooks", handlers.GetScanHooks)
+		scanRoutes.GET("/:id/dag", handlers.GetScanDAG)
+		scanRoutes.GET("/:id/events", handlers.StreamScanEvents)
+		scanRoutes.GET("/:id/findings", handlers.GetScanFindings)
+		scanRoutes.GET("/:id/findings/export", handlers.ExportFindings)
 		scanRoutes.GET("", handlers.ListScans)
 		scanRoutes.DELETE("/:id", handlers.DeleteScan)
+		scanRoutes.POST("/:id/cancel", handlers.CancelScan)
+		scanRoutes.POST("/:id/retry-failed", handlers.RetryFailedTools)
+		scanRoutes.POST("/:id/rerun", handlers.RerunScan)
+		scanRoutes.POST("/:id/pause", handlers.PauseScan)
+		scanRoutes.POST("/:id/resume", handlers.ResumeScan)
+		scanRoutes.GET("/:id/webhook-deliveries", handlers.ListWebhookDeliveries)
+		scanRoutes.POST("/:id/webhook-deliveries/:delivery/redeliver", handlers.RedeliverWebhook)
 	}
 
 	// Queue status endpoint
@@ -1,13 +1,26 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"pipeliner/pkg/tools"
 )
 
+// exitPartialFailure is returned when the scan ran but some tools failed.
+const exitPartialFailure = 2
+
 func main() {
 	if err := Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+
+		var partial *tools.PartialExecutionError
+		if errors.As(err, &partial) {
+			for _, failure := range partial.FailedTools {
+				fmt.Fprintf(os.Stderr, "  %s: %v\n", failure.Tool, failure.Err)
+			}
+			os.Exit(exitPartialFailure)
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,95 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"pipeliner/internal/config"
+	"pipeliner/internal/dao"
+	"pipeliner/internal/database"
+	"pipeliner/internal/services"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// openExports connects to the database the server is configured with.
+func openExports() (services.ExportServiceMethods, error) {
+	db, err := database.InitDB(config.LoadConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	return services.NewExportService(dao.NewScanDAO(db), dao.NewSubdomainDAO(db), dao.NewFindingDAO(db)), nil
+}
+
+func NewExportCommand() *cobra.Command {
+	var (
+		out    string
+		format string
+	)
+
+	exportCmd := &cobra.Command{
+		Use:   "export <scan-id>",
+		Short: "Export a scan as one JSON or CSV report",
+		Long: `Export everything about a scan from the local database: its metadata,
+failed tools, subdomains with their ports, fuzzing hits and vulns, and its
+findings, as one JSON document. CSV has one row per subdomain, with the
+values of multi-valued columns joined by ';'. The format follows --out's
+extension unless --format is given; without --out the report goes to
+stdout.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format == "" {
+				format = "json"
+				if strings.EqualFold(filepath.Ext(out), ".csv") {
+					format = "csv"
+				}
+			}
+			if format != "json" && format != "csv" {
+				return fmt.Errorf("--format must be json or csv")
+			}
+			cmd.SilenceUsage = true
+
+			exports, err := openExports()
+			if err != nil {
+				return err
+			}
+			export, err := exports.Export(args[0])
+			if err != nil {
+				if errors.Is(err, services.ErrScanNotFound) {
+					return fmt.Errorf("scan %s not found", args[0])
+				}
+				return err
+			}
+
+			var w io.Writer = cmd.OutOrStdout()
+			if out != "" {
+				file, err := os.Create(out)
+				if err != nil {
+					return fmt.Errorf("failed to create %s: %w", out, err)
+				}
+				defer file.Close()
+				w = file
+			}
+			if format == "csv" {
+				err = export.WriteCSV(w)
+			} else {
+				err = export.WriteJSON(w)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to export scan: %w", err)
+			}
+			if out != "" {
+				cmd.PrintErrf("✓ Exported scan %s to %s\n", export.Scan.UUID, out)
+			}
+			return nil
+		},
+	}
+
+	exportCmd.Flags().StringVarP(&out, "out", "o", "", "File to write, e.g. report.json or report.csv")
+	exportCmd.Flags().StringVar(&format, "format", "", "json or csv (default from --out's extension, else json)")
+
+	return exportCmd
+}
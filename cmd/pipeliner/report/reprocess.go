@@ -0,0 +1,144 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"pipeliner/internal/config"
+	"pipeliner/internal/configsource"
+	"pipeliner/internal/dao"
+	"pipeliner/internal/database"
+	"pipeliner/internal/services"
+	"pipeliner/internal/vulndb"
+	"pipeliner/pkg/blobstore"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// openReprocess connects to the database and artifact store the server is
+// configured with.
+func openReprocess() (services.ReprocessServiceMethods, error) {
+	cfg := config.LoadConfig()
+	db, err := database.InitDB(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	rules, err := cfg.SeverityRules()
+	if err != nil {
+		return nil, fmt.Errorf("invalid severity rules: %w", err)
+	}
+	opts := []services.ReprocessOption{
+		services.WithReprocessVulnDB(vulndb.NewCache(cfg.VulnDBPath)),
+		services.WithReprocessSeverityRules(rules),
+	}
+	if cfg.ArtifactStore == "s3" {
+		s3, err := blobstore.NewS3(cfg.S3)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure the s3 artifact store: %w", err)
+		}
+		opts = append(opts, services.WithReprocessArtifactStore(s3))
+	}
+	return services.NewReprocessService(dao.NewScanDAO(db), dao.NewSubdomainDAO(db), dao.NewFindingDAO(db), opts...), nil
+}
+
+func NewReprocessCommand() *cobra.Command {
+	var (
+		all    bool
+		module string
+		dryRun bool
+	)
+
+	reprocessCmd := &cobra.Command{
+		Use:   "reprocess <scan-id | --all>",
+		Short: "Parse the outputs of finished scans again",
+		Long: `Run the artifact parsers again over the directories of finished scans, so
+scans from before a parser improvement get its results. Subdomain results
+are updated in place, findings the scan already has are not added twice,
+and nothing is notified again. Each reprocessed scan records when it was
+reprocessed. Findings are scored with the severity rules of the module the
+scan ran with. Active scans, scans whose directory is gone and scans whose
+module revision is no longer checked out are skipped.
+
+With --dry-run nothing is written; the command only lists what would
+change.`,
+		Example: `  pipeliner reprocess 4f1c2d3e-...
+  pipeliner reprocess --all --module web_scan --dry-run`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) == 1) || len(args) > 1 {
+				return fmt.Errorf("give either one scan id or --all")
+			}
+			if module != "" && !all {
+				return fmt.Errorf("--module only applies with --all")
+			}
+			cmd.SilenceUsage = true
+
+			if _, err := configsource.FromEnv(cmd.Context(), false); err != nil {
+				return err
+			}
+			reprocess, err := openReprocess()
+			if err != nil {
+				return err
+			}
+
+			var changed, failed int
+			report := func(result services.ReprocessResult) {
+				printReprocessResult(cmd, result, dryRun)
+				if result.Err != nil {
+					failed++
+				} else if result.Changed() {
+					changed++
+				}
+			}
+			if all {
+				if err := reprocess.ReprocessAll(module, dryRun, report); err != nil {
+					return err
+				}
+			} else {
+				result, err := reprocess.Reprocess(args[0], dryRun)
+				if errors.Is(err, services.ErrScanNotFound) {
+					return fmt.Errorf("scan %s not found", args[0])
+				}
+				if err != nil {
+					return fmt.Errorf("failed to reprocess scan %s: %w", args[0], err)
+				}
+				report(result)
+			}
+
+			verb := "Changed"
+			if dryRun {
+				verb = "Would change"
+			}
+			cmd.Printf("%s %d scan(s)\n", verb, changed)
+			if failed > 0 {
+				return fmt.Errorf("%d scan(s) failed to reprocess", failed)
+			}
+			return nil
+		},
+	}
+
+	reprocessCmd.Flags().BoolVar(&all, "all", false, "Reprocess every finished scan")
+	reprocessCmd.Flags().StringVar(&module, "module", "", "With --all, only the scans of this module")
+	reprocessCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print what would change")
+
+	return reprocessCmd
+}
+
+func printReprocessResult(cmd *cobra.Command, result services.ReprocessResult, dryRun bool) {
+	switch {
+	case result.Err != nil:
+		cmd.PrintErrf("✗ %s: %v\n", result.ScanID, result.Err)
+	case result.Skipped != "":
+		cmd.Printf("- %s: skipped, %s\n", result.ScanID, result.Skipped)
+	case !result.Changed():
+		cmd.Printf("  %s: unchanged\n", result.ScanID)
+	default:
+		prefix := "✓"
+		if dryRun {
+			prefix = "~"
+		}
+		cmd.Printf("%s %s: %d subdomain(s), %d new finding(s)\n", prefix, result.ScanID, len(result.Subdomains), result.Findings)
+		if len(result.Subdomains) > 0 {
+			cmd.Printf("    %s\n", strings.Join(result.Subdomains, ", "))
+		}
+	}
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"pipeliner/cmd/pipeliner/report"
 	"pipeliner/cmd/pipeliner/scan"
 	"pipeliner/cmd/pipeliner/server"
+	"pipeliner/cmd/pipeliner/state"
+	"pipeliner/cmd/pipeliner/team"
+	"pipeliner/cmd/pipeliner/vulndb"
 
 	"github.com/spf13/cobra"
 )
@@ -22,6 +26,17 @@ func Execute() error {
 	rootCmd.AddCommand(scan.NewScanCommand())
 	rootCmd.AddCommand(scan.NewListConfigsCommand())
 	rootCmd.AddCommand(scan.NewListHooksCommand())
+	rootCmd.AddCommand(scan.NewInitModuleCommand())
+	rootCmd.AddCommand(scan.NewDoctorCommand())
+	rootCmd.AddCommand(scan.NewValidateCommand())
+	rootCmd.AddCommand(scan.NewScansCommand())
 	rootCmd.AddCommand(server.NewServerCommand())
+	rootCmd.AddCommand(state.NewExportCommand())
+	rootCmd.AddCommand(state.NewImportCommand())
+	rootCmd.AddCommand(report.NewExportCommand())
+	rootCmd.AddCommand(report.NewReprocessCommand())
+	rootCmd.AddCommand(vulndb.NewRefreshCommand())
+	rootCmd.AddCommand(team.NewTeamsCommand())
+	rootCmd.AddCommand(team.NewAPIKeyCommand())
 	return rootCmd.ExecuteContext(context.Background())
 }
@@ -0,0 +1,131 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"pipeliner/internal/batch"
+	"pipeliner/internal/configsource"
+	"pipeliner/pkg/engine"
+	"pipeliner/pkg/logger"
+	tools "pipeliner/pkg/tools"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type BatchConfig struct {
+	File    string
+	Format  string
+	Verbose bool
+	Timeout time.Duration
+}
+
+// RunOnce runs module against domain a single time, without the periodic loop.
+func (a *App) RunOnce(ctx context.Context, module, domain string) error {
+	engineInstance, err := engine.NewPiplinerEngine(
+		engine.WithContext(ctx),
+		engine.WithNotificationClient(a.notifier))
+	if err != nil {
+		return fmt.Errorf("failed to create pipeliner engine: %w", err)
+	}
+
+	options := tools.DefaultOptions()
+	options.ScanType = module
+	options.Domain = domain
+	options.Timeout = a.config.Timeout
+
+	if err := engineInstance.PrepareScan(options); err != nil {
+		return fmt.Errorf("failed to prepare scan: %w", err)
+	}
+	defer a.cleanup(engineInstance)
+	return engineInstance.RunHTTP(module, domain)
+}
+
+func NewBatchCommand() *cobra.Command {
+	config := &BatchConfig{
+		Timeout: 30 * time.Minute,
+	}
+
+	batchCmd := &cobra.Command{
+		Use:   "batch",
+		Short: "Scan every target listed in a CSV or JSON file",
+		Long: `Scan every target listed in a CSV or JSON file, one scan per row.
+CSV files need a header with domain and module columns; tags and priority are optional.
+Targets run one after another, highest priority first.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			if _, err := configsource.FromEnv(cmd.Context(), false); err != nil {
+				return err
+			}
+
+			format := config.Format
+			if format == "" {
+				format = batch.FormatFromName(config.File)
+			}
+
+			file, err := os.Open(config.File)
+			if err != nil {
+				return fmt.Errorf("failed to open targets file: %w", err)
+			}
+			rows, err := batch.Parse(file, format, engine.ValidateModule)
+			file.Close()
+			if err != nil {
+				return fmt.Errorf("failed to parse %s: %w", config.File, err)
+			}
+
+			app, err := NewApp(&Config{Verbose: config.Verbose, Timeout: config.Timeout})
+			if err != nil {
+				return fmt.Errorf("failed to initialize application: %w", err)
+			}
+			defer app.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sigChan := make(chan os.Signal, 1)
+			signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				sig := <-sigChan
+				app.logger.WithFields(logger.Fields{
+					"signal": sig.String(),
+				}).Info("Received shutdown signal")
+				cancel()
+			}()
+
+			failed := 0
+			for _, row := range batch.ByPriority(rows) {
+				if row.Err == nil && ctx.Err() != nil {
+					row.Err = ctx.Err()
+				}
+				if row.Err == nil {
+					row.Err = app.RunOnce(ctx, row.Target.Module, row.Target.Domain)
+				}
+				if row.Err != nil {
+					failed++
+					fmt.Printf("✗ line %d: %s (%s): %v\n", row.Line, row.Target.Domain, row.Target.Module, row.Err)
+					continue
+				}
+				fmt.Printf("✓ line %d: %s (%s)\n", row.Line, row.Target.Domain, row.Target.Module)
+			}
+
+			fmt.Printf("\n%d of %d targets scanned\n", len(rows)-failed, len(rows))
+			if failed > 0 {
+				return fmt.Errorf("%d of %d targets failed", failed, len(rows))
+			}
+			return nil
+		},
+	}
+
+	batchCmd.Flags().StringVarP(&config.File, "file", "f", "", "CSV or JSON file of targets (required)")
+	batchCmd.Flags().StringVar(&config.Format, "format", "", "Input format: csv or json (default: from file extension)")
+	batchCmd.Flags().BoolVarP(&config.Verbose, "verbose", "v", false, "Enable verbose logging")
+	batchCmd.Flags().DurationVar(&config.Timeout, "timeout", 30*time.Minute, "Timeout for each scan")
+
+	batchCmd.MarkFlagRequired("file")
+
+	return batchCmd
+}
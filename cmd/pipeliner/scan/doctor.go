@@ -0,0 +1,251 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"pipeliner/internal/configsource"
+	"pipeliner/internal/utils"
+	"pipeliner/pkg/engine"
+	perrors "pipeliner/pkg/errors"
+	"pipeliner/pkg/runner"
+	tools "pipeliner/pkg/tools"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// versionTimeout bounds each attempt to get a tool's version, in case it
+// takes the flag for something else and starts working.
+const versionTimeout = 3 * time.Second
+
+var versionPattern = regexp.MustCompile(`v?\d+\.\d+(\.\d+)*`)
+
+// installHints say how to install the tools most modules use.
+var installHints = map[string]string{
+	"subfinder": "go install -v github.com/projectdiscovery/subfinder/v2/cmd/subfinder@latest",
+	"httpx":     "go install -v github.com/projectdiscovery/httpx/cmd/httpx@latest",
+	"nuclei":    "go install -v github.com/projectdiscovery/nuclei/v3/cmd/nuclei@latest",
+	"ffuf":      "go install github.com/ffuf/ffuf/v2@latest",
+	"nmap":      "sudo apt install nmap",
+	"python3":   "sudo apt install python3",
+	"node":      "sudo apt install nodejs",
+	"ruby":      "sudo apt install ruby",
+}
+
+type DoctorConfig struct {
+	Modules        []string
+	WriteChecksums string
+}
+
+// toolCheck is what doctor found of a tool command.
+type toolCheck struct {
+	Command string
+	// Path is the binary the command resolves to; for a script, it is the
+	// interpreter's and Script is the script's.
+	Path    string
+	Script  string
+	Version string
+	Err     error
+	// Hint says how to install what is missing, if doctor knows.
+	Hint string
+}
+
+// checkTool resolves command as a scan would run it: scripts through the
+// interpreter for their extension, anything else from PATH.
+func checkTool(command string) toolCheck {
+	check := toolCheck{Command: command}
+	executable := command
+	if interpreter, _ := runner.ResolveInterpreter(command, nil); interpreter != command {
+		if _, err := os.Stat(command); err != nil {
+			check.Err = fmt.Errorf("script not found: %w", err)
+			return check
+		}
+		check.Script, _ = filepath.Abs(command)
+		executable = interpreter
+	}
+
+	path, err := exec.LookPath(executable)
+	if err != nil {
+		if executable != command {
+			check.Err = fmt.Errorf("interpreter %s not found in PATH", executable)
+		} else {
+			check.Err = errors.New("not found in PATH")
+		}
+		check.Hint = installHints[filepath.Base(executable)]
+		return check
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	check.Path = path
+	check.Version = toolVersion(path)
+	return check
+}
+
+// toolVersion is the first version number the binary prints for -version
+// or --version, or empty.
+func toolVersion(path string) string {
+	for _, flag := range []string{"-version", "--version"} {
+		ctx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+		cmd := exec.CommandContext(ctx, path, flag)
+		cmd.WaitDelay = time.Second
+		output, _ := cmd.CombinedOutput()
+		cancel()
+		if version := versionPattern.FindString(string(output)); version != "" {
+			return version
+		}
+	}
+	return ""
+}
+
+func NewDoctorCommand() *cobra.Command {
+	config := &DoctorConfig{}
+
+	doctorCmd := &cobra.Command{
+		Use:   "doctor [-m module...]",
+		Short: "Check that the tools of scan modules are installed",
+		Long: `Check that every tool command used by the given modules (all modules when
+none are given) is installed and matches its pinned checksum, if any, and
+print its version. Scripts are checked along with the interpreter they run
+with. Missing tools the command knows get an install hint; any problem
+makes it exit non-zero.
+--write-checksums records the current binaries as an allowlist for ` + tools.ChecksumsFileEnv + `.`,
+		Example: `  pipeliner doctor -m web_scan
+  pipeliner doctor --write-checksums checksums.txt`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			if _, err := configsource.FromEnv(cmd.Context(), false); err != nil {
+				return err
+			}
+
+			modules := append(config.Modules, args...)
+			if len(modules) == 0 {
+				var err error
+				if modules, err = listModules(utils.CurrentModuleOrigin().Dir); err != nil {
+					return err
+				}
+			}
+
+			global, err := tools.ChecksumAllowlist()
+			if err != nil {
+				return fmt.Errorf("failed to load %s: %w", tools.ChecksumsFileEnv, err)
+			}
+
+			out := cmd.OutOrStdout()
+			current := make(map[string]string)
+			checks := make(map[string]toolCheck)
+			problems := 0
+			for _, module := range modules {
+				chainConfig, err := engine.LoadModule(module)
+				if err != nil {
+					fmt.Fprintf(out, "\n✗ %s: %v\n", module, err)
+					problems++
+					continue
+				}
+
+				pins := make(map[string]string, len(global)+len(chainConfig.Checksums))
+				for command, sum := range global {
+					pins[command] = sum
+				}
+				for command, sum := range chainConfig.Checksums {
+					pins[command] = sum
+				}
+				verifier := tools.NewBinaryVerifier(pins)
+
+				fmt.Fprintf(out, "\n• %s\n", module)
+				for _, tc := range chainConfig.Tools {
+					check, ok := checks[tc.Command]
+					if !ok {
+						check = checkTool(tc.Command)
+						checks[tc.Command] = check
+					}
+					if check.Err != nil {
+						fmt.Fprintf(out, "  ✗ %-14s %v\n", tc.Command, check.Err)
+						if check.Hint != "" {
+							fmt.Fprintf(out, "    install with: %s\n", check.Hint)
+						}
+						problems++
+						continue
+					}
+					// a script is pinned itself, not its interpreter
+					path, shown := check.Path, check.Path
+					if check.Script != "" {
+						path, shown = check.Script, check.Script+" via "+check.Path
+					}
+
+					status := "ok"
+					if err := verifier.Verify(tc.Command); err != nil {
+						status = "checksum mismatch"
+						if !errors.Is(err, perrors.ErrChecksumMismatch) {
+							status = err.Error()
+						}
+						problems++
+					}
+					verified := status == "ok"
+					if check.Version != "" {
+						status += ", " + check.Version
+					}
+					fmt.Fprintf(out, "  %s %-14s %s (%s)\n", mark(verified), tc.Command, shown, status)
+
+					if config.WriteChecksums != "" {
+						sum, err := tools.HashBinary(path)
+						if err != nil {
+							return fmt.Errorf("failed to hash %s: %w", path, err)
+						}
+						current[path] = sum
+					}
+				}
+			}
+
+			if config.WriteChecksums != "" {
+				if err := os.WriteFile(config.WriteChecksums, tools.FormatChecksums(current), 0644); err != nil {
+					return fmt.Errorf("failed to write checksums: %w", err)
+				}
+				fmt.Fprintf(out, "\n✓ Pinned %d binaries in %s\n", len(current), config.WriteChecksums)
+				fmt.Fprintf(out, "  Use it with: %s=%s\n", tools.ChecksumsFileEnv, config.WriteChecksums)
+			}
+
+			if problems > 0 {
+				return fmt.Errorf("%d problem(s) found", problems)
+			}
+			return nil
+		},
+	}
+
+	doctorCmd.Flags().StringSliceVarP(&config.Modules, "module", "m", nil, "Module to check, repeatable; all modules when none are given")
+	doctorCmd.Flags().StringVar(&config.WriteChecksums, "write-checksums", "", "Write the sha256 of every tool binary found to this file")
+
+	return doctorCmd
+}
+
+func mark(ok bool) string {
+	if ok {
+		return "✓"
+	}
+	return "✗"
+}
+
+// listModules names the module files in dir.
+func listModules(dir string) ([]string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config directory %s: %w", dir, err)
+	}
+	var modules []string
+	for _, file := range files {
+		ext := filepath.Ext(file.Name())
+		if ext == ".yaml" || ext == ".yml" {
+			modules = append(modules, strings.TrimSuffix(file.Name(), ext))
+		}
+	}
+	sort.Strings(modules)
+	return modules, nil
+}
@@ -0,0 +1,66 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFakeBinary(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckTool(t *testing.T) {
+	bin := t.TempDir()
+	writeFakeBinary(t, bin, "subfinder", `echo "[INF] Current Version: v2.6.3" >&2`)
+	writeFakeBinary(t, bin, "nmap", `[ "$1" = "--version" ] || { echo "unknown option $1" >&2; exit 1; }
+echo "Nmap version 7.94 ( https://nmap.org )"`)
+	writeFakeBinary(t, bin, "quiet", `exit 0`)
+	writeFakeBinary(t, bin, "python3", `echo "Python 3.11.2"`)
+	t.Setenv("PATH", bin)
+
+	scripts := t.TempDir()
+	for _, name := range []string{"enum.py", "enum.rb"} {
+		if err := os.WriteFile(filepath.Join(scripts, name), []byte("print('hi')\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		path    string
+		script  string
+		version string
+		err     string
+		hint    string
+	}{
+		{name: "version from -version", command: "subfinder", path: filepath.Join(bin, "subfinder"), version: "v2.6.3"},
+		{name: "version from --version", command: "nmap", path: filepath.Join(bin, "nmap"), version: "7.94"},
+		{name: "no version", command: "quiet", path: filepath.Join(bin, "quiet")},
+		{name: "missing known tool", command: "nuclei", err: "not found in PATH", hint: installHints["nuclei"]},
+		{name: "missing unknown tool", command: "gau", err: "not found in PATH"},
+		{name: "script", command: filepath.Join(scripts, "enum.py"), path: filepath.Join(bin, "python3"), script: filepath.Join(scripts, "enum.py"), version: "3.11.2"},
+		{name: "script without interpreter", command: filepath.Join(scripts, "enum.rb"), script: filepath.Join(scripts, "enum.rb"), err: "interpreter ruby not found", hint: installHints["ruby"]},
+		{name: "missing script", command: filepath.Join(scripts, "gone.py"), err: "script not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := checkTool(tt.command)
+			if tt.err == "" && check.Err != nil {
+				t.Fatalf("unexpected error: %v", check.Err)
+			}
+			if tt.err != "" && (check.Err == nil || !strings.Contains(check.Err.Error(), tt.err)) {
+				t.Fatalf("expected an error containing %q, got %v", tt.err, check.Err)
+			}
+			if check.Path != tt.path || check.Script != tt.script || check.Version != tt.version || check.Hint != tt.hint {
+				t.Errorf("got path %q script %q version %q hint %q, want %q %q %q %q",
+					check.Path, check.Script, check.Version, check.Hint, tt.path, tt.script, tt.version, tt.hint)
+			}
+		})
+	}
+}
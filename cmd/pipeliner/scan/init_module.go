@@ -0,0 +1,120 @@
+package scan
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"pipeliner/internal/modulegen"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type InitModuleConfig struct {
+	ConfigPath  string
+	Description string
+	Mode        string
+	Tools       []string
+	Force       bool
+}
+
+func NewInitModuleCommand() *cobra.Command {
+	config := &InitModuleConfig{}
+
+	initCmd := &cobra.Command{
+		Use:   "init-module <name>",
+		Short: "Create a new scan module",
+		Long: `Create a new scan module from the built-in tool catalog. Questions not
+answered by flags are asked interactively.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			catalog, err := modulegen.Catalog()
+			if err != nil {
+				return err
+			}
+
+			in := bufio.NewReader(cmd.InOrStdin())
+			out := cmd.OutOrStdout()
+			spec := modulegen.Spec{
+				Name:          args[0],
+				Description:   config.Description,
+				ExecutionMode: config.Mode,
+				Tools:         config.Tools,
+			}
+
+			if !cmd.Flags().Changed("description") {
+				spec.Description = ask(in, out, "Description", "")
+			}
+			if spec.ExecutionMode == "" {
+				spec.ExecutionMode = ask(in, out, "Execution mode ("+strings.Join(modulegen.ExecutionModes, ", ")+")", modulegen.ExecutionModes[0])
+			}
+			if len(spec.Tools) == 0 {
+				fmt.Fprintln(out, "Available tools:")
+				for _, t := range catalog {
+					fmt.Fprintf(out, "  %-10s %s\n", t.Name, t.Description)
+				}
+				answer := ask(in, out, "Tools (comma separated)", "subfinder,httpx,nuclei")
+				spec.Tools = strings.Split(answer, ",")
+			}
+
+			data, err := modulegen.Generate(spec)
+			if err != nil {
+				return err
+			}
+
+			path := filepath.Join(config.ConfigPath, spec.Name+".yaml")
+			flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+			if config.Force {
+				flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			}
+			file, err := os.OpenFile(path, flags, 0644)
+			if err != nil {
+				if os.IsExist(err) {
+					return fmt.Errorf("%s already exists, use --force to overwrite it", path)
+				}
+				return fmt.Errorf("failed to write module: %w", err)
+			}
+			if _, err := file.Write(data); err != nil {
+				file.Close()
+				return fmt.Errorf("failed to write module: %w", err)
+			}
+			if err := file.Close(); err != nil {
+				return fmt.Errorf("failed to write module: %w", err)
+			}
+
+			fmt.Fprintf(out, "\n✓ Wrote %s\n\n", path)
+			fmt.Fprintln(out, "Next steps:")
+			fmt.Fprintf(out, "  1. Review the flags in %s (wordlists, rate limits, output names)\n", path)
+			fmt.Fprintln(out, "  2. Make sure the tools are installed and in your PATH")
+			fmt.Fprintf(out, "  3. Run it: pipeliner scan -m %s -d example.com\n", spec.Name)
+			return nil
+		},
+	}
+
+	initCmd.Flags().StringVar(&config.ConfigPath, "config", "./config", "Configuration directory path")
+	initCmd.Flags().StringVar(&config.Description, "description", "", "Module description")
+	initCmd.Flags().StringVar(&config.Mode, "mode", "", "Execution mode: hybrid, sequential or concurrent")
+	initCmd.Flags().StringSliceVar(&config.Tools, "tools", nil, "Catalog tools to include, e.g. subfinder,httpx")
+	initCmd.Flags().BoolVar(&config.Force, "force", false, "Overwrite an existing module")
+
+	return initCmd
+}
+
+// ask prints a prompt and returns the trimmed answer, or def when the answer
+// is empty or input has ended.
+func ask(in *bufio.Reader, out io.Writer, prompt, def string) string {
+	if def != "" {
+		fmt.Fprintf(out, "%s [%s]: ", prompt, def)
+	} else {
+		fmt.Fprintf(out, "%s: ", prompt)
+	}
+	answer, _ := in.ReadString('\n')
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return def
+	}
+	return answer
+}
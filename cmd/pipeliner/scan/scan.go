@@ -6,7 +6,10 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"pipeliner/internal/configsource"
 	"pipeliner/internal/notification"
+	"pipeliner/internal/tui"
+	"pipeliner/internal/utils"
 	"pipeliner/pkg/engine"
 	hooks "pipeliner/pkg/hooks"
 	"pipeliner/pkg/logger"
@@ -27,12 +30,18 @@ type Config struct {
 	ConfigPath    string
 	Timeout       time.Duration
 	PeriodicHours int
+	// Schedule, an interval or a cron expression, replaces PeriodicHours.
+	Schedule string
+	Proxy    string
+	Resume   string
+	DryRun   bool
+	TUI      bool
 }
 
 type App struct {
-	config        *Config
-	logger        *logger.Logger
-	discordClient *notification.NotificationClient
+	config   *Config
+	logger   *logger.Logger
+	notifier notification.Notifier
 }
 
 func NewApp(config *Config) (*App, error) {
@@ -42,38 +51,57 @@ func NewApp(config *Config) (*App, error) {
 	}
 	appLogger := logger.NewLogger(logLevel)
 
-	var discordClient *notification.NotificationClient
-	if token := os.Getenv("DISCORD_TOKEN"); token != "" {
+	var notifier notification.Notifier
+	if os.Getenv("DISCORD_TOKEN") != "" || os.Getenv(notification.SlackWebhookEnv) != "" || os.Getenv(notification.WebhookNotifierURLEnv) != "" {
 		var err error
-		discordClient, err = notification.NewNotificationClient()
+		notifier, err = notification.NewNotifier()
 		if err != nil {
-			appLogger.WithError(err).Warn("Failed to initialize Discord client")
+			appLogger.WithError(err).Warn("Failed to initialize notifications")
 		} else {
-			appLogger.Info("Discord notifications enabled")
+			appLogger.Info("Notifications enabled")
+			if err := notification.Validate(notifier); err != nil {
+				appLogger.WithError(err).Warn("Some Discord channels are not accessible")
+			}
 		}
 	} else {
-		appLogger.Info("DISCORD_TOKEN not set - Discord notifications disabled")
+		appLogger.Info("No notification backend configured - notifications disabled")
 	}
 
 	return &App{
-		config:        config,
-		logger:        appLogger,
-		discordClient: discordClient,
+		config:   config,
+		logger:   appLogger,
+		notifier: notifier,
 	}, nil
 }
 
 func (a *App) Close() error {
-	if a.discordClient != nil {
-		return a.discordClient.Close()
+	if a.notifier != nil {
+		return a.notifier.Close()
 	}
 	return nil
 }
 
 func (a *App) Run(ctx context.Context) error {
-	engineInstance, err := engine.NewPiplinerEngine(
+	// the terminal view can cancel the scan too
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	opts := []engine.OptFunc{
 		engine.WithContext(ctx),
 		engine.WithPeriodic(a.config.PeriodicHours),
-		engine.WithNotificationClient(a.discordClient))
+		engine.WithNotificationClient(a.notifier),
+	}
+	if a.config.Schedule != "" {
+		opts = append(opts, engine.WithSchedule(a.config.Schedule))
+	}
+	if a.config.Resume != "" {
+		if info, err := os.Stat(a.config.Resume); err != nil || !info.IsDir() {
+			return fmt.Errorf("cannot resume from %s: not a scan directory", a.config.Resume)
+		}
+		opts = append(opts, engine.WithResume(a.config.Resume))
+	}
+
+	engineInstance, err := engine.NewPiplinerEngine(opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create pipeliner engine: %w", err)
 	}
@@ -82,6 +110,8 @@ func (a *App) Run(ctx context.Context) error {
 	options.ScanType = a.config.Module
 	options.Domain = a.config.Domain
 	options.Timeout = a.config.Timeout
+	options.Proxy = a.config.Proxy
+	options.DryRun = a.config.DryRun
 
 	if err := options.Validate(); err != nil {
 		return fmt.Errorf("invalid options: %w", err)
@@ -90,6 +120,11 @@ func (a *App) Run(ctx context.Context) error {
 	if err := engineInstance.PrepareScan(options); err != nil {
 		return fmt.Errorf("failed to prepare scan: %w", err)
 	}
+	defer a.cleanup(engineInstance)
+
+	if a.config.TUI {
+		defer a.startTUI(engineInstance, options, cancel)()
+	}
 
 	errChan := make(chan error, 1)
 	go func() {
@@ -124,6 +159,49 @@ func (a *App) Run(ctx context.Context) error {
 	return nil
 }
 
+// startTUI shows the scan in the terminal view, fed the same progress events
+// the web UI gets, and returns a func that closes it. Without a terminal the
+// scan logs as usual.
+func (a *App) startTUI(engineInstance *engine.PiplinerEngine, options *tools.Options, cancel context.CancelFunc) func() {
+	if !tui.Available() {
+		a.logger.Info("Not running in a terminal, logging instead of showing --tui")
+		return func() {}
+	}
+
+	model := tui.NewModel(fmt.Sprintf("pipeliner: %s on %s", a.config.Module, a.config.Domain), time.Now())
+	model.SetTools(engineInstance.Tools())
+	if a.config.Verbose {
+		model.ToggleVerbose()
+	}
+	options.OnProgress = model.Progress
+	options.OnToolProgress = model.Progress
+
+	ui, err := tui.Start(model, tui.Options{
+		OnCancel: cancel,
+		OnVerbose: func(verbose bool) {
+			level := logrus.InfoLevel
+			if verbose {
+				level = logrus.DebugLevel
+			}
+			a.logger.SetLevel(level)
+			engineInstance.Logger().SetLevel(level)
+		},
+	})
+	if err != nil {
+		a.logger.WithError(err).Warn("Failed to start the terminal view, logging instead")
+		return func() {}
+	}
+	return ui.Stop
+}
+
+// cleanup runs the module's cleanup hooks once the scan is over, however it
+// ended.
+func (a *App) cleanup(engineInstance *engine.PiplinerEngine) {
+	if err := engineInstance.Cleanup(); err != nil {
+		a.logger.WithError(err).Warn("Cleanup hooks failed")
+	}
+}
+
 func getConfigDescription(configPath string) string {
 	type ConfigMeta struct {
 		Description string `yaml:"description,omitempty"`
@@ -155,6 +233,10 @@ func NewScanCommand() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cmd.SilenceUsage = true
 
+			if _, err := configsource.FromEnv(cmd.Context(), false); err != nil {
+				return err
+			}
+
 			app, err := NewApp(config)
 			if err != nil {
 				return fmt.Errorf("failed to initialize application: %w", err)
@@ -188,8 +270,16 @@ func NewScanCommand() *cobra.Command {
 	scanCmd.Flags().StringVar(&config.ConfigPath, "config", "./config", "Configuration directory path")
 	scanCmd.Flags().DurationVar(&config.Timeout, "timeout", 30*time.Minute, "Global timeout for operations")
 	scanCmd.Flags().IntVar(&config.PeriodicHours, "periodic-hours", 5, "Hours between periodic scans")
+	scanCmd.Flags().StringVar(&config.Schedule, "schedule", "", `When to run the scan again: an interval ("6h", "@every 6h") or a cron expression ("0 3 * * 1-5", "@daily")`)
+	scanCmd.Flags().StringVar(&config.Proxy, "proxy", "", "Proxy URL for the tools (http, https or socks5; env "+tools.ProxyEnv+")")
+	scanCmd.Flags().StringVar(&config.Resume, "resume", "", "Continue an interrupted scan in this directory, skipping tools whose output exists")
+	scanCmd.Flags().BoolVar(&config.DryRun, "dry-run", false, "Print the command lines the tools would run, once, without running them")
+	scanCmd.Flags().BoolVar(&config.TUI, "tui", false, "Show the tools, stage progress and log in a terminal view (q cancels, v toggles debug logs)")
 
 	scanCmd.MarkFlagRequired("module")
+	scanCmd.MarkFlagsMutuallyExclusive("schedule", "periodic-hours")
+
+	scanCmd.AddCommand(NewBatchCommand())
 
 	return scanCmd
 }
@@ -211,6 +301,17 @@ func NewListConfigsCommand() *cobra.Command {
 				configPath = "./config"
 			}
 
+			src, err := configsource.FromEnv(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			source := "local (" + configPath + ")"
+			if src != nil {
+				origin := utils.CurrentModuleOrigin()
+				configPath = origin.Dir
+				source = origin.Source + " @ " + origin.Revision
+			}
+
 			files, err := os.ReadDir(configPath)
 			if err != nil {
 				return fmt.Errorf("failed to read config directory %s: %w", configPath, err)
@@ -218,6 +319,7 @@ func NewListConfigsCommand() *cobra.Command {
 
 			fmt.Println("Available Configurations:")
 			fmt.Println("========================")
+			fmt.Printf("Source: %s\n", source)
 
 			for _, file := range files {
 				if !strings.HasSuffix(file.Name(), ".yaml") && !strings.HasSuffix(file.Name(), ".yml") {
@@ -266,12 +368,22 @@ func NewListHooksCommand() *cobra.Command {
 				if hook.Description != "" {
 					fmt.Printf("  Description: %s\n", hook.Description)
 				}
+				if hook.Pre {
+					fmt.Println("  Pre hook: runs before the tool (prehooks)")
+				}
+				if !hook.Critical {
+					fmt.Println("  Non-critical: failures are logged and do not fail the tool")
+				}
 			}
 
 			if len(hooks) == 0 {
 				fmt.Println("No hooks available")
 			}
 
+			if factories := tools.HookFactoryNames(); len(factories) > 0 {
+				fmt.Printf("\nConfigurable in a module's hooks section: %s\n", strings.Join(factories, ", "))
+			}
+
 			return nil
 		},
 	}
@@ -281,10 +393,26 @@ func NewListHooksCommand() *cobra.Command {
 
 func InitHooks() {
 	combineOutput := hooks.NewCombineOutput()
-	nucleiNotifier := hooks.NewNucleiNotifierHook(hooks.NucleiNotifierHookConfig{
-		Filename: "nuclei_output.json",
-	})
+	nucleiNotifier := hooks.NewNucleiNotifierHook(hooks.NucleiNotifierHookConfig{})
+
+	// modules can declare these in their hooks section; a declared hook
+	// replaces the one registered below under the same name for its stage
+	tools.RegisterHookFactory("combine_output", hooks.CombineOutputFactory)
+	tools.RegisterHookFactory("notifier", hooks.NucleiNotifierFactory)
+	tools.RegisterHookFactory("stage_summary", hooks.StageSummaryFactory)
 
 	tools.RegisterStageHook(tools.StageSubdomain, combineOutput)
+	stageSummary := hooks.NewStageSummaryHook(hooks.StageSummaryHookConfig{})
+	for _, stage := range tools.Stages {
+		tools.RegisterStageHook(stage, stageSummary)
+	}
+	tools.RegisterStageHook(tools.StageVuln, hooks.NewReportHook(hooks.ReportHookConfig{
+		TemplatePath: os.Getenv("PIPELINER_REPORT_TEMPLATE"),
+	}))
 	tools.RegisterPostHook("NucleiNotifier", nucleiNotifier)
+	tools.RegisterPostHook("template_update", hooks.NewTemplateUpdateHook(hooks.TemplateUpdateHookConfig{}))
+	tools.RegisterPostHook("cleanup_files", hooks.NewCleanupFilesHook())
+	tools.RegisterPreHook("wordlist_preparer", hooks.NewWordlistPreparerHook(hooks.WordlistPreparerHookConfig{
+		URLs: hooks.ParseWordlistURLs(os.Getenv("PIPELINER_WORDLIST_URLS")),
+	}))
 }
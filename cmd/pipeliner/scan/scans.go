@@ -0,0 +1,165 @@
+package scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// ServerEnv is the base URL of the pipeliner server the scans commands talk to.
+const ServerEnv = "PIPELINER_SERVER"
+
+// TokenEnv is the API token the scans commands authenticate with, when the
+// server requires one.
+const TokenEnv = "PIPELINER_TOKEN"
+
+// NewScansCommand groups the commands that act on the scans of a running
+// server.
+func NewScansCommand() *cobra.Command {
+	scansCmd := &cobra.Command{
+		Use:   "scans",
+		Short: "Manage the scans of a running pipeliner server",
+	}
+
+	server := os.Getenv(ServerEnv)
+	if server == "" {
+		server = "http://127.0.0.1:8080"
+	}
+	scansCmd.PersistentFlags().StringVar(&server, "server", server, "Base URL of the pipeliner server (env "+ServerEnv+")")
+	token := os.Getenv(TokenEnv)
+	scansCmd.PersistentFlags().StringVar(&token, "token", token, "API token of your team (env "+TokenEnv+")")
+
+	scansCmd.AddCommand(newStartCommand(&server, &token))
+	scansCmd.AddCommand(newRetryCommand(&server, &token))
+	return scansCmd
+}
+
+// callServer sends a request to the server's API, with the token if there
+// is one.
+func callServer(cmd *cobra.Command, server, token, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(cmd.Context(), method, strings.TrimRight(server, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach server: %w", err)
+	}
+	return resp, nil
+}
+
+func newStartCommand(server, token *string) *cobra.Command {
+	var request struct {
+		ScanType string `json:"scan_type"`
+		Domain   string `json:"domain"`
+		Priority int    `json:"priority,omitempty"`
+		Force    bool   `json:"force,omitempty"`
+	}
+
+	startCmd := &cobra.Command{
+		Use:   "start",
+		Short: "Queue a scan on the server",
+		Long: `Queue a scan of a domain on the server. When the server already has a
+queued, running or recently finished scan of the same module and domain, the
+scan still starts but a warning lists those scans; a server started with
+REJECT_DUPLICATE_SCANS only starts it with --force.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			payload, err := json.Marshal(request)
+			if err != nil {
+				return err
+			}
+			resp, err := callServer(cmd, *server, *token, http.MethodPost, "/api/scans", bytes.NewReader(payload))
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			var body struct {
+				ScanID      string   `json:"scan_id"`
+				Warning     string   `json:"warning"`
+				DuplicateOf []string `json:"duplicate_of"`
+				Error       string   `json:"error"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				return fmt.Errorf("unexpected response from server (%s): %w", resp.Status, err)
+			}
+			if resp.StatusCode == http.StatusConflict {
+				return fmt.Errorf("duplicate of %s; run again with --force to start it anyway", strings.Join(body.DuplicateOf, ", "))
+			}
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("scan rejected (%s): %s", resp.Status, body.Error)
+			}
+
+			if body.Warning != "" {
+				cmd.PrintErrf("\n⚠ WARNING: %s:\n", body.Warning)
+				for _, id := range body.DuplicateOf {
+					cmd.PrintErrf("    %s\n", id)
+				}
+				cmd.PrintErrln()
+			}
+			cmd.Printf("✓ Queued scan %s\n", body.ScanID)
+			return nil
+		},
+	}
+
+	startCmd.Flags().StringVarP(&request.ScanType, "module", "m", "", "Module to run (required)")
+	startCmd.Flags().StringVarP(&request.Domain, "domain", "d", "", "Target domain (required)")
+	startCmd.Flags().IntVar(&request.Priority, "priority", 0, "Start ahead of queued scans of a lower priority")
+	startCmd.Flags().BoolVar(&request.Force, "force", false, "Start the scan even if the server rejects duplicates")
+	startCmd.MarkFlagRequired("module")
+	startCmd.MarkFlagRequired("domain")
+
+	return startCmd
+}
+
+func newRetryCommand(server, token *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "retry <scan-id>",
+		Short: "Run the failed tools of a scan again",
+		Long: `Run the failed tools of a scan that completed with warnings again, in the
+scan's directory and with the module revision it ran with. Dependencies of the
+failed tools whose output is missing run too.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			resp, err := callServer(cmd, *server, *token, http.MethodPost, "/api/scans/"+url.PathEscape(args[0])+"/retry-failed", nil)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			var body struct {
+				Tools []string `json:"tools"`
+				Error string   `json:"error"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				return fmt.Errorf("unexpected response from server (%s): %w", resp.Status, err)
+			}
+			if resp.StatusCode != http.StatusAccepted {
+				return fmt.Errorf("retry rejected (%s): %s", resp.Status, body.Error)
+			}
+
+			cmd.Printf("✓ Retrying %s for scan %s\n", strings.Join(body.Tools, ", "), args[0])
+			return nil
+		},
+	}
+}
@@ -0,0 +1,162 @@
+package scan
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"pipeliner/internal/configsource"
+	"pipeliner/internal/utils"
+	tools "pipeliner/pkg/tools"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type ValidateConfig struct {
+	Module string
+	All    bool
+}
+
+func NewValidateCommand() *cobra.Command {
+	config := &ValidateConfig{}
+
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check scan modules for errors without running them",
+		Long: `Load a module the way a scan would and report each tool with its stage,
+dependencies and problems. Invalid settings, unknown dependencies and
+dependency cycles are errors; commands missing from PATH are warnings.
+Exits non-zero when any module has errors.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			if config.Module == "" && !config.All {
+				return fmt.Errorf("either --module or --all is required")
+			}
+			if _, err := configsource.FromEnv(cmd.Context(), false); err != nil {
+				return err
+			}
+
+			modules := []string{config.Module}
+			if config.All {
+				var err error
+				if modules, err = listModules(utils.CurrentModuleOrigin().Dir); err != nil {
+					return err
+				}
+			}
+
+			out := cmd.OutOrStdout()
+			errorCount, warningCount := 0, 0
+			for _, module := range modules {
+				errs, warnings := validateModule(out, module)
+				errorCount += errs
+				warningCount += warnings
+			}
+
+			fmt.Fprintf(out, "\n%d module(s), %d error(s), %d warning(s)\n", len(modules), errorCount, warningCount)
+			if errorCount > 0 {
+				return fmt.Errorf("%d error(s) found", errorCount)
+			}
+			return nil
+		},
+	}
+
+	validateCmd.Flags().StringVarP(&config.Module, "module", "m", "", "Module to validate")
+	validateCmd.Flags().BoolVar(&config.All, "all", false, "Validate every module in the config directory")
+
+	return validateCmd
+}
+
+// validateModule prints the report of one module and counts its errors and
+// warnings.
+func validateModule(out io.Writer, module string) (errorCount, warningCount int) {
+	v, err := utils.NewViperConfig(module)
+	if err != nil {
+		fmt.Fprintf(out, "\n✗ %s: %v\n", module, err)
+		return 1, 0
+	}
+	if err := utils.ValidateConfig(v); err != nil {
+		fmt.Fprintf(out, "\n✗ %s: %v\n", module, err)
+		return 1, 0
+	}
+	chainConfig := &tools.ChainConfig{ExecutionMode: v.GetString("execution_mode")}
+	if err := utils.DecodeConfigNamed(v, chainConfig, v.ConfigFileUsed()); err != nil {
+		fmt.Fprintf(out, "\n✗ %s: %v\n", module, err)
+		return 1, 0
+	}
+
+	var moduleErrs, toolErrs []error
+	toolNames := make(map[string]bool, len(chainConfig.Tools))
+	for _, tc := range chainConfig.Tools {
+		toolNames[tc.Name] = true
+	}
+
+	type toolReport struct {
+		config   tools.ToolConfig
+		errs     []error
+		warnings []string
+	}
+	reports := make([]toolReport, 0, len(chainConfig.Tools))
+	for _, tc := range chainConfig.Tools {
+		report := toolReport{config: tc}
+		if err := tc.Validate(); err != nil {
+			report.errs = append(report.errs, err)
+		}
+		for _, dep := range tc.DependsOn {
+			if !toolNames[dep] {
+				report.errs = append(report.errs, fmt.Errorf("tool %s depends on unknown tool %s", tc.Name, dep))
+			}
+		}
+		if tc.Command != "" {
+			if _, err := exec.LookPath(tc.Command); err != nil {
+				report.warnings = append(report.warnings, fmt.Sprintf("%s not found in PATH", tc.Command))
+			}
+		}
+		toolErrs = append(toolErrs, report.errs...)
+		reports = append(reports, report)
+	}
+
+	// Validate stops at the first problem, which may be one already shown
+	// under its tool
+	if err := chainConfig.Validate(); err != nil && !reported(err, toolErrs) {
+		moduleErrs = append(moduleErrs, err)
+	}
+	if err := tools.CheckDependencies(chainConfig.Tools); err != nil && !reported(err, toolErrs) {
+		moduleErrs = append(moduleErrs, err)
+	}
+
+	errorCount = len(moduleErrs) + len(toolErrs)
+	fmt.Fprintf(out, "\n%s %s (%s, %s)\n", mark(errorCount == 0), module, v.ConfigFileUsed(), chainConfig.ExecutionMode)
+	for _, err := range moduleErrs {
+		fmt.Fprintf(out, "  error: %v\n", err)
+	}
+	for _, report := range reports {
+		stage := string(report.config.Stage())
+		if stage == "" {
+			stage = "-"
+		}
+		deps := "-"
+		if len(report.config.DependsOn) > 0 {
+			deps = strings.Join(report.config.DependsOn, ", ")
+		}
+		fmt.Fprintf(out, "  %s %-14s stage: %-16s depends on: %s\n", mark(len(report.errs) == 0), report.config.Name, stage, deps)
+		for _, err := range report.errs {
+			fmt.Fprintf(out, "      error: %v\n", err)
+		}
+		for _, warning := range report.warnings {
+			fmt.Fprintf(out, "      warning: %s\n", warning)
+		}
+		warningCount += len(report.warnings)
+	}
+	return errorCount, warningCount
+}
+
+// reported says whether err repeats one of the tool errors.
+func reported(err error, toolErrs []error) bool {
+	for _, toolErr := range toolErrs {
+		if strings.Contains(err.Error(), toolErr.Error()) {
+			return true
+		}
+	}
+	return false
+}
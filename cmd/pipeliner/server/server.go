@@ -1,18 +1,28 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"pipeliner/api/routes"
 	"pipeliner/internal/config"
+	"pipeliner/internal/configsource"
 	"pipeliner/internal/database"
+	"pipeliner/internal/vulndb"
 	"pipeliner/pkg/engine"
+	"pipeliner/pkg/hostlimit"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 type ServerOpts struct {
-	Port int
+	Port               int
+	MaxConcurrentScans int
 }
 
 func NewServerCommand() *cobra.Command {
@@ -25,22 +35,163 @@ func NewServerCommand() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			cmd.SilenceUsage = true
 			cfg := config.LoadConfig()
+			if cmd.Flags().Changed("max-concurrent-scans") {
+				if ServerConfig.MaxConcurrentScans < 1 {
+					cmd.PrintErrf("--max-concurrent-scans must be at least 1\n")
+					os.Exit(1)
+				}
+				cfg.MaxConcurrentScans = ServerConfig.MaxConcurrentScans
+			}
+			if err := cfg.Monitor.Validate(); err != nil {
+				cmd.PrintErrf("invalid monitor config: %v\n", err)
+				os.Exit(1)
+			}
+			if err := cfg.SLO.Validate(); err != nil {
+				cmd.PrintErrf("invalid SLO config: %v\n", err)
+				os.Exit(1)
+			}
+			if err := cfg.ResourceGuard.Validate(); err != nil {
+				cmd.PrintErrf("invalid resource guard config: %v\n", err)
+				os.Exit(1)
+			}
+
+			nicePolicy, err := cfg.NicePolicy()
+			if err != nil {
+				cmd.PrintErrf("invalid scan priority niceness: %v\n", err)
+				os.Exit(1)
+			}
+			if len(nicePolicy.Levels) > 0 {
+				cmd.Printf("✓ Tools run at a niceness from their scan's priority (between %d and %d)\n", nicePolicy.Floor, nicePolicy.Ceiling)
+			}
+
+			severityRules, err := cfg.SeverityRules()
+			if err != nil {
+				cmd.PrintErrf("invalid severity rules: %v\n", err)
+				os.Exit(1)
+			}
+			if len(severityRules) > 0 {
+				cmd.Printf("✓ Findings scored by %d severity rules from %s\n", len(severityRules), cfg.SeverityRulesPath)
+			}
+
+			if src, err := configsource.FromEnv(cmd.Context(), true); err != nil {
+				cmd.PrintErrf("%v\n", err)
+				os.Exit(1)
+			} else if src != nil {
+				cmd.Printf("✓ Scan modules loaded from git (revision %s)\n", src.Revision())
+			}
 
 			// Initialize engine queue
 			engine.InitGlobalQueue(cfg.MaxConcurrentScans)
 			cmd.Printf("✓ Scan queue initialized (max concurrent: %d)\n", cfg.MaxConcurrentScans)
+			hostlimit.InitGlobal(cfg.HostRequestsPerSecond)
+			if cfg.HostRequestsPerSecond > 0 {
+				cmd.Printf("✓ Requests limited to %g per second per host across scans\n", cfg.HostRequestsPerSecond)
+			}
+
+			// the scans share this cache, so its first load here is the only
+			// time the dataset is parsed until a refresh replaces it
+			vulnDB := vulndb.NewCache(cfg.VulnDBPath)
+			if err := vulnDB.Load(); err != nil {
+				cmd.PrintErrf("No vulnerability dataset at %s (%v); findings only get the scores of their nuclei templates until refresh-vulndb runs\n", cfg.VulnDBPath, err)
+			} else {
+				cves, updatedAt, _ := vulnDB.Stat()
+				cmd.Printf("✓ Findings scored from %d CVEs in %s (updated %s)\n", cves, cfg.VulnDBPath, updatedAt.Format("2006-01-02"))
+			}
+			if cfg.VulnDBRefreshInterval > 0 {
+				cmd.Printf("✓ Vulnerability dataset refreshed every %s\n", cfg.VulnDBRefreshInterval)
+			}
 
 			db, err := database.InitDB(cfg)
 			if err != nil {
 				cmd.PrintErrf("failed to initialize database: %v\n", err)
 				os.Exit(1)
 			}
-			router := routes.InitRouter(db)
-			router.Run(fmt.Sprintf(":%d", ServerConfig.Port))
+			router, closeServices := routes.InitRouter(db, cfg, vulnDB)
+			if cfg.RejectDuplicateScans {
+				cmd.Printf("✓ Duplicate scans rejected (same module and domain queued, running or finished within %s)\n", cfg.DuplicateScanWindow)
+			}
+			if cfg.ScanDiskQuotaMB > 0 {
+				cmd.Printf("✓ Scan directories limited to %d MB (checked every %s)\n", cfg.ScanDiskQuotaMB, cfg.Monitor.DiskQuotaInterval)
+			}
+			if guard := cfg.ResourceGuard; guard.MinFreeDiskMB > 0 || guard.MaxMemoryMB > 0 {
+				cmd.Printf("✓ Scans paused below %d MB of free disk space or above %d MB of memory (0 is unchecked), checked every %s\n", guard.MinFreeDiskMB, guard.MaxMemoryMB, guard.CheckInterval)
+			}
+			switch cfg.APITokens {
+			case config.APITokensRequired:
+				cmd.Println("✓ API tokens required; each team sees its own scans (create one with pipeliner teams token)")
+			case config.APITokensOff:
+				cmd.Println("API tokens off (REQUIRE_API_TOKENS=false); every request sees every team's scans")
+			default:
+				cmd.Println("API tokens required once one is created (REQUIRE_API_TOKENS=auto); until then every request is served")
+			}
+			srv := &http.Server{Addr: fmt.Sprintf(":%d", ServerConfig.Port), Handler: router}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			if cfg.VulnDBRefreshInterval > 0 {
+				go refreshVulnDB(ctx, cmd, vulnDB, cfg.VulnDBRefreshInterval)
+			}
+			go func() {
+				if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					cmd.PrintErrf("server failed: %v\n", err)
+					os.Exit(1)
+				}
+			}()
+			<-ctx.Done()
+			stop()
+			shutdown(cmd, srv, cfg.ShutdownTimeout)
+			closeServices()
 		},
 	}
 
 	serverCmd.Flags().IntVarP(&ServerConfig.Port, "port", "p", 8080, "Port to run the server on")
+	serverCmd.Flags().IntVar(&ServerConfig.MaxConcurrentScans, "max-concurrent-scans", 1, "Scans run at once; overrides PIPELINER_MAX_CONCURRENT_SCANS")
 
 	return serverCmd
 }
+
+// shutdown stops taking requests and starting scans, then waits up to
+// timeout for the running scans. Queued scans stay queued for the next
+// start, which marks the scans still running then failed.
+func shutdown(cmd *cobra.Command, srv *http.Server, timeout time.Duration) {
+	cmd.Printf("Shutting down, waiting up to %s for running scans\n", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// event streams keep connections open, so this runs alongside the wait
+	httpDone := make(chan error, 1)
+	go func() { httpDone <- srv.Shutdown(ctx) }()
+
+	if err := engine.GetGlobalQueue().Shutdown(ctx); err != nil {
+		running, _, _ := engine.GetGlobalQueue().GetStatus()
+		cmd.PrintErrf("%d scans still running after %s; they are marked failed on the next start\n", running, timeout)
+	}
+	if err := <-httpDone; err != nil {
+		srv.Close()
+	}
+}
+
+// refreshVulnDB downloads the cache's dataset every interval, and right
+// away if it is missing or older than that, until ctx ends with the
+// command or a shutdown signal. Scans keep using the old one when a
+// download fails.
+func refreshVulnDB(ctx context.Context, cmd *cobra.Command, cache *vulndb.Cache, interval time.Duration) {
+	refresh := func() {
+		if _, err := vulndb.Refresh(ctx, cache.Path(), vulndb.RefreshOptions{}); err != nil && ctx.Err() == nil {
+			cmd.PrintErrf("failed to refresh the vulnerability dataset: %v\n", err)
+		}
+	}
+	if _, updatedAt, ok := cache.Stat(); !ok || time.Since(updatedAt) >= interval {
+		refresh()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refresh()
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package state
+
+import (
+	"fmt"
+	"pipeliner/internal/config"
+	"pipeliner/internal/dao"
+	"pipeliner/internal/database"
+	"pipeliner/internal/state"
+
+	"github.com/spf13/cobra"
+)
+
+// openState connects to the database the server is configured with.
+func openState() (dao.StateDAO, error) {
+	db, err := database.InitDB(config.LoadConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	return dao.NewStateDAO(db)
+}
+
+func NewExportCommand() *cobra.Command {
+	var (
+		out  string
+		opts state.ExportOptions
+	)
+
+	exportCmd := &cobra.Command{
+		Use:   "export-state",
+		Short: "Export the database, scan directories and modules to an archive",
+		Long: `Export everything a pipeliner server keeps into one archive, to move it to
+another server with import-state: every table of the database as JSON, the
+scan directories and the scan modules, with a manifest holding their
+checksums. The archive is compressed as --out's extension says: .zst (needs
+the zstd command), .gz or none. Stop the server first so running scans do
+not change underneath the export.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			states, err := openState()
+			if err != nil {
+				return err
+			}
+			manifest, err := state.Export(states, out, opts)
+			if err != nil {
+				return err
+			}
+
+			cmd.Printf("✓ Exported %d scans and %d files to %s\n", manifest.Tables["scans"], len(manifest.Files), out)
+			if manifest.ScreenshotsSkipped {
+				cmd.Println("  Screenshots were left out")
+			}
+			return nil
+		},
+	}
+
+	exportCmd.Flags().StringVar(&out, "out", "", "Archive to write, e.g. state.tar.zst (required)")
+	exportCmd.Flags().StringVar(&opts.ScanRoot, "scans-dir", "./scans", "Directory holding the scan directories")
+	exportCmd.Flags().StringVar(&opts.ModulesDir, "config", "./config", "Configuration directory path")
+	exportCmd.Flags().BoolVar(&opts.SkipScreenshots, "exclude-screenshots", false, "Leave the screenshots out of the scan directories")
+	exportCmd.MarkFlagRequired("out")
+
+	return exportCmd
+}
+
+func NewImportCommand() *cobra.Command {
+	var opts state.ImportOptions
+
+	importCmd := &cobra.Command{
+		Use:   "import-state <archive>",
+		Short: "Restore an archive written by export-state into a fresh instance",
+		Long: `Restore an archive written by export-state into a fresh instance: the database
+must have no rows and --scans-dir must be empty. The archive is checked
+against its manifest and schema version before anything is written. Scan
+directories are moved under --scans-dir and the database updated to match;
+modules in the archive replace those of the same name in --config.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			states, err := openState()
+			if err != nil {
+				return err
+			}
+			manifest, err := state.Import(states, args[0], opts)
+			if err != nil {
+				return err
+			}
+
+			cmd.Printf("✓ Imported %d scans exported on %s\n", manifest.Tables["scans"], manifest.CreatedAt.Format("2006-01-02 15:04 MST"))
+			return nil
+		},
+	}
+
+	importCmd.Flags().StringVar(&opts.ScanRoot, "scans-dir", "./scans", "Directory to restore the scan directories into")
+	importCmd.Flags().StringVar(&opts.ModulesDir, "config", "./config", "Configuration directory path")
+
+	return importCmd
+}
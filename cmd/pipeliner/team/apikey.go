@@ -0,0 +1,121 @@
+package team
+
+import (
+	"fmt"
+	"pipeliner/internal/models"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// NewAPIKeyCommand groups the commands that create, list and revoke API
+// keys, the tokens RequireToken accepts.
+func NewAPIKeyCommand() *cobra.Command {
+	apikeyCmd := &cobra.Command{
+		Use:   "apikey",
+		Short: "Create, list and revoke API keys",
+	}
+	apikeyCmd.AddCommand(newAPIKeyCreateCommand(), newAPIKeyListCommand(), newAPIKeyRevokeCommand())
+	return apikeyCmd
+}
+
+func newAPIKeyCreateCommand() *cobra.Command {
+	var (
+		team  string
+		name  string
+		admin bool
+	)
+
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create an API key and print it",
+		Long: `Create an API key and print it. Only a hash of the key is stored, so it
+cannot be shown again.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			teams, err := openTeams()
+			if err != nil {
+				return err
+			}
+			token, record, err := teams.CreateToken(team, name, admin)
+			if err != nil {
+				return fmt.Errorf("failed to create API key: %w", err)
+			}
+			cmd.Printf("✓ Created API key %d for team %s\n", record.ID, team)
+			cmd.Println(token)
+			return nil
+		},
+	}
+
+	createCmd.Flags().StringVar(&team, "team", models.DefaultTeamName, "Team the key belongs to")
+	createCmd.Flags().StringVar(&name, "name", "", "What the key is for, e.g. ci")
+	createCmd.Flags().BoolVar(&admin, "admin", false, "Let the key see every team's scans")
+
+	return createCmd
+}
+
+func newAPIKeyListCommand() *cobra.Command {
+	var team string
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the API keys",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			teams, err := openTeams()
+			if err != nil {
+				return err
+			}
+			tokens, err := teams.ListTokens(team)
+			if err != nil {
+				return err
+			}
+			for _, token := range tokens {
+				state := "active"
+				if token.RevokedAt != nil {
+					state = "revoked " + token.RevokedAt.Format("2006-01-02 15:04")
+				}
+				role := ""
+				if token.Admin {
+					role = "admin"
+				}
+				cmd.Printf("%d\tteam %d\t%s\t%s\t%s\t%s\n", token.ID, token.TeamID, token.Name, role,
+					token.CreatedAt.Format("2006-01-02 15:04"), state)
+			}
+			return nil
+		},
+	}
+
+	listCmd.Flags().StringVar(&team, "team", "", "Only list the keys of this team")
+
+	return listCmd
+}
+
+func newAPIKeyRevokeCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "Revoke an API key so it is no longer accepted",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid API key id %q", args[0])
+			}
+			cmd.SilenceUsage = true
+
+			teams, err := openTeams()
+			if err != nil {
+				return err
+			}
+			if err := teams.RevokeToken(uint(id)); err != nil {
+				return fmt.Errorf("failed to revoke API key: %w", err)
+			}
+			cmd.Printf("✓ Revoked API key %d\n", id)
+			return nil
+		},
+	}
+}
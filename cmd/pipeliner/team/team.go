@@ -0,0 +1,114 @@
+package team
+
+import (
+	"fmt"
+	"pipeliner/internal/config"
+	"pipeliner/internal/dao"
+	"pipeliner/internal/database"
+	"pipeliner/internal/services"
+
+	"github.com/spf13/cobra"
+)
+
+// openTeams connects to the database the server is configured with.
+func openTeams() (services.TeamServiceMethods, error) {
+	db, err := database.InitDB(config.LoadConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	return services.NewTeamService(dao.NewTeamDAO(db)), nil
+}
+
+// NewTeamsCommand groups the commands that manage teams and their API
+// tokens. Servers require tokens once the first one is created.
+func NewTeamsCommand() *cobra.Command {
+	teamsCmd := &cobra.Command{
+		Use:   "teams",
+		Short: "Manage the teams scans belong to and their API tokens",
+	}
+	teamsCmd.AddCommand(newCreateCommand(), newListCommand(), newTokenCommand())
+	return teamsCmd
+}
+
+func newCreateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create a team",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			teams, err := openTeams()
+			if err != nil {
+				return err
+			}
+			team, err := teams.CreateTeam(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to create team: %w", err)
+			}
+			cmd.Printf("✓ Created team %s (id %d)\n", team.Name, team.ID)
+			return nil
+		},
+	}
+}
+
+func newListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the teams",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			teams, err := openTeams()
+			if err != nil {
+				return err
+			}
+			all, err := teams.ListTeams()
+			if err != nil {
+				return err
+			}
+			for _, team := range all {
+				cmd.Printf("%d\t%s\n", team.ID, team.Name)
+			}
+			return nil
+		},
+	}
+}
+
+func newTokenCommand() *cobra.Command {
+	var (
+		name  string
+		admin bool
+	)
+
+	tokenCmd := &cobra.Command{
+		Use:   "token <team>",
+		Short: "Create an API token for a team",
+		Long: `Create an API token for a team and print it. Only a hash of the token is
+stored, so it cannot be shown again. Requests with the token see the team's
+scans only, and the scans they start belong to the team; an --admin token
+sees every team's scans.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			teams, err := openTeams()
+			if err != nil {
+				return err
+			}
+			token, _, err := teams.CreateToken(args[0], name, admin)
+			if err != nil {
+				return fmt.Errorf("failed to create token: %w", err)
+			}
+			cmd.Printf("✓ Created token for team %s\n", args[0])
+			cmd.Println(token)
+			return nil
+		},
+	}
+
+	tokenCmd.Flags().StringVar(&name, "name", "", "What the token is for, e.g. ci")
+	tokenCmd.Flags().BoolVar(&admin, "admin", false, "Let the token see every team's scans")
+
+	return tokenCmd
+}
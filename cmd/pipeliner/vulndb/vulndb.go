@@ -0,0 +1,49 @@
+package vulndb
+
+import (
+	"pipeliner/internal/config"
+	"pipeliner/internal/vulndb"
+
+	"github.com/spf13/cobra"
+)
+
+func NewRefreshCommand() *cobra.Command {
+	var (
+		out  string
+		opts vulndb.RefreshOptions
+	)
+
+	refreshCmd := &cobra.Command{
+		Use:   "refresh-vulndb",
+		Short: "Download the EPSS and CVSS scores nuclei findings are ranked by",
+		Long: `Download the current EPSS scores, and CVSS base scores if --cvss-url is set,
+into the local dataset the server scores nuclei findings from. Scans never
+download anything themselves, so run this from cron (the EPSS scores change
+daily) or set VULNDB_REFRESH_INTERVAL on the server. Sources can be local
+files for hosts without internet access. --cvss-url is a CSV with cve and
+cvss columns. The running server picks up the new dataset without a restart.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			if out == "" {
+				out = config.LoadConfig().VulnDBPath
+			}
+			dataset, err := vulndb.Refresh(cmd.Context(), out, opts)
+			if err != nil {
+				return err
+			}
+
+			cmd.Printf("✓ Saved scores of %d CVEs to %s\n", len(dataset.Scores), out)
+			if dataset.EPSSDate != "" {
+				cmd.Printf("  EPSS scores of %s\n", dataset.EPSSDate)
+			}
+			return nil
+		},
+	}
+
+	refreshCmd.Flags().StringVar(&out, "out", "", "Dataset to write (default VULNDB_PATH or "+vulndb.DefaultPath+")")
+	refreshCmd.Flags().StringVar(&opts.EPSSURL, "epss-url", vulndb.DefaultEPSSURL, "EPSS scores CSV, gzipped or not, as a URL or file")
+	refreshCmd.Flags().StringVar(&opts.CVSSURL, "cvss-url", "", "CVSS base scores CSV with cve and cvss columns, as a URL or file")
+
+	return refreshCmd
+}
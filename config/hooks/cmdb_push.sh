@@ -0,0 +1,27 @@
+#!/bin/sh
+# Example subprocess hook: posts the hook's input (scan, tool and artifact
+# manifest) to a CMDB. Register it in a module with
+#
+#   subprocess_hooks:
+#     - name: cmdb_push
+#       path: hooks/cmdb_push.sh
+#       timeout: 30s
+#       critical: false
+#       env: [CMDB_URL, CMDB_TOKEN]
+#
+# and name cmdb_push in a tool's posthooks or in cleanup.
+set -eu
+
+input=$(cat)
+
+if [ -z "${CMDB_URL:-}" ]; then
+	echo '{"warning": "CMDB_URL is not set, nothing was pushed"}'
+	exit 0
+fi
+
+printf '%s' "$input" | curl --fail --silent --show-error \
+	--max-time 20 \
+	-H "Content-Type: application/json" \
+	${CMDB_TOKEN:+-H "Authorization: Bearer $CMDB_TOKEN"} \
+	--data-binary @- \
+	"$CMDB_URL"
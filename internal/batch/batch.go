@@ -0,0 +1,238 @@
+// Package batch parses target lists (CSV or JSON) into scan requests for
+// POST /api/scans/batch and `pipeliner scan batch`.
+package batch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxTargets caps how many rows a single batch may contain.
+const MaxTargets = 500
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown batch format, expected csv or json")
+	ErrTooManyRows   = fmt.Errorf("batch exceeds %d targets", MaxTargets)
+	ErrEmptyBatch    = errors.New("batch contains no targets")
+)
+
+type Target struct {
+	Domain   string   `json:"domain"`
+	Module   string   `json:"module"`
+	Tags     []string `json:"tags,omitempty"`
+	Priority int      `json:"priority,omitempty"`
+}
+
+// Row is one parsed target. Line is the CSV line number (the header is line 1)
+// or the 1-based JSON array index; Err is set when the row failed validation.
+type Row struct {
+	Line   int
+	Target Target
+	Err    error
+}
+
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// FormatFromName picks the format from a file name's extension.
+func FormatFromName(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return FormatJSON
+	case ".csv", ".txt":
+		return FormatCSV
+	}
+	return ""
+}
+
+// FormatFromContentType picks the format from a request's media type,
+// without parameters.
+func FormatFromContentType(contentType string) string {
+	contentType = strings.ToLower(contentType)
+	switch {
+	case contentType == "application/json" || strings.HasSuffix(contentType, "+json"):
+		return FormatJSON
+	case contentType == "text/csv" || contentType == "text/plain":
+		return FormatCSV
+	}
+	return ""
+}
+
+// Parse reads targets in format and validates each row. Rows that fail are
+// returned with Err set; only problems with the input as a whole are errors.
+// checkModule, if set, rejects rows whose module cannot be loaded.
+func Parse(r io.Reader, format string, checkModule func(module string) error) ([]Row, error) {
+	var rows []Row
+	var err error
+	switch format {
+	case FormatCSV:
+		rows, err = parseCSV(r)
+	case FormatJSON:
+		rows, err = parseJSON(r)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	if len(rows) > MaxTargets {
+		return nil, ErrTooManyRows
+	}
+
+	moduleErrs := make(map[string]error)
+	for i := range rows {
+		row := &rows[i]
+		if row.Err != nil {
+			continue
+		}
+		row.Err = validate(row.Target)
+		if row.Err != nil || checkModule == nil {
+			continue
+		}
+		err, checked := moduleErrs[row.Target.Module]
+		if !checked {
+			err = checkModule(row.Target.Module)
+			moduleErrs[row.Target.Module] = err
+		}
+		row.Err = err
+	}
+	return rows, nil
+}
+
+// ByPriority orders rows highest priority first, keeping input order for ties,
+// so the most important targets reach the queue first.
+func ByPriority(rows []Row) []Row {
+	sorted := append([]Row(nil), rows...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Target.Priority > sorted[j].Target.Priority
+	})
+	return sorted
+}
+
+func validate(t Target) error {
+	if t.Domain == "" {
+		return errors.New("domain is required")
+	}
+	if !domainPattern.MatchString(t.Domain) {
+		return fmt.Errorf("invalid domain %q", t.Domain)
+	}
+	if t.Module == "" {
+		return errors.New("module is required")
+	}
+	return nil
+}
+
+func normalize(t Target) Target {
+	t.Domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(t.Domain), "."))
+	t.Module = strings.TrimSpace(t.Module)
+	tags := t.Tags[:0]
+	for _, tag := range t.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	t.Tags = tags
+	return t
+}
+
+func parseCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, ErrEmptyBatch
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"domain", "module"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv header is missing the %s column", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if len(rows) >= MaxTargets {
+			return nil, ErrTooManyRows
+		}
+
+		row := Row{Line: line}
+		row.Target = Target{
+			Domain: field(record, "domain"),
+			Module: field(record, "module"),
+			Tags:   strings.FieldsFunc(field(record, "tags"), isTagSeparator),
+		}
+		if priority := strings.TrimSpace(field(record, "priority")); priority != "" {
+			row.Target.Priority, err = strconv.Atoi(priority)
+			if err != nil {
+				row.Err = fmt.Errorf("invalid priority %q", priority)
+			}
+		}
+		row.Target = normalize(row.Target)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func isTagSeparator(r rune) bool {
+	return r == ';' || r == '|' || r == ','
+}
+
+func parseJSON(r io.Reader) ([]Row, error) {
+	var targets []Target
+	if err := json.NewDecoder(io.LimitReader(r, 8<<20)).Decode(&targets); err != nil {
+		if err == io.EOF {
+			return nil, ErrEmptyBatch
+		}
+		return nil, fmt.Errorf("failed to decode json targets: %w", err)
+	}
+	if len(targets) > MaxTargets {
+		return nil, ErrTooManyRows
+	}
+
+	rows := make([]Row, len(targets))
+	for i, target := range targets {
+		rows[i] = Row{Line: i + 1, Target: normalize(target)}
+	}
+	return rows, nil
+}
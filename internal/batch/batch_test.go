@@ -0,0 +1,97 @@
+package batch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCSV(t *testing.T) {
+	input := `Domain,Module,Tags,Priority
+example.com,quick_scan,prod;external,5
+# retired
+not a domain,quick_scan,,
+api.example.com,missing,,
+shop.example.com,quick_scan,,high
+`
+	checkModule := func(module string) error {
+		if module == "missing" {
+			return errors.New("module not found")
+		}
+		return nil
+	}
+
+	rows, err := Parse(strings.NewReader(input), FormatCSV, checkModule)
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+
+	assert.NoError(t, rows[0].Err)
+	assert.Equal(t, 2, rows[0].Line)
+	assert.Equal(t, Target{Domain: "example.com", Module: "quick_scan", Tags: []string{"prod", "external"}, Priority: 5}, rows[0].Target)
+
+	assert.ErrorContains(t, rows[1].Err, "invalid domain")
+	assert.EqualError(t, rows[2].Err, "module not found")
+	assert.ErrorContains(t, rows[3].Err, "invalid priority")
+}
+
+func TestParseJSONAndPriorityOrder(t *testing.T) {
+	input := `[
+		{"domain": "a.example.com", "module": "quick_scan"},
+		{"domain": "B.example.com.", "module": "quick_scan", "priority": 10},
+		{"domain": "c.example.com", "module": "quick_scan", "tags": ["x"]}
+	]`
+
+	rows, err := Parse(strings.NewReader(input), FormatJSON, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "b.example.com", rows[1].Target.Domain)
+
+	var order []string
+	for _, row := range ByPriority(rows) {
+		order = append(order, row.Target.Domain)
+	}
+	assert.Equal(t, []string{"b.example.com", "a.example.com", "c.example.com"}, order)
+}
+
+func TestParseRejectsWholeInput(t *testing.T) {
+	_, err := Parse(strings.NewReader("host,module\nexample.com,quick_scan\n"), FormatCSV, nil)
+	assert.ErrorContains(t, err, "missing the domain column")
+
+	_, err = Parse(strings.NewReader("[]"), FormatJSON, nil)
+	assert.ErrorIs(t, err, ErrEmptyBatch)
+
+	_, err = Parse(strings.NewReader(""), "xml", nil)
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	big := "domain,module\n" + strings.Repeat("example.com,quick_scan\n", MaxTargets+1)
+	_, err = Parse(strings.NewReader(big), FormatCSV, nil)
+	assert.ErrorIs(t, err, ErrTooManyRows)
+}
+
+func TestFormatFromName(t *testing.T) {
+	for name, want := range map[string]string{
+		"targets.csv":              FormatCSV,
+		"TARGETS.JSON":             FormatJSON,
+		"hosts.txt":                FormatCSV,
+		"targets_json_backup.csv":  FormatCSV,
+		"csv_exports/targets.json": FormatJSON,
+		"targets.xlsx":             "",
+		"targets":                  "",
+	} {
+		assert.Equal(t, want, FormatFromName(name), name)
+	}
+}
+
+func TestFormatFromContentType(t *testing.T) {
+	for contentType, want := range map[string]string{
+		"application/json":         FormatJSON,
+		"application/vnd.api+json": FormatJSON,
+		"text/csv":                 FormatCSV,
+		"text/plain":               FormatCSV,
+		"multipart/form-data":      "",
+	} {
+		assert.Equal(t, want, FormatFromContentType(contentType), contentType)
+	}
+}
@@ -1,8 +1,16 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"pipeliner/internal/models"
+	"pipeliner/internal/vulndb"
+	"pipeliner/pkg/blobstore"
+	"pipeliner/pkg/scoring"
+	"pipeliner/pkg/tools"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -12,10 +20,211 @@ type Config struct {
 	DBPassword         string
 	DBName             string
 	MaxConcurrentScans int
+	MaxQueuedScans     int
+	// ShutdownTimeout is how long a stopping server waits for its running
+	// scans; the next start marks the ones still running failed.
+	ShutdownTimeout time.Duration
+	// HostRequestsPerSecond is the budget of each registrable domain across
+	// all running scans; 0 is no limit.
+	HostRequestsPerSecond float64
+	AllowConfigEdits      bool
+	ReleaseSlotOnPause    bool
+	WebhookURL            string
+	WebhookSecret         string
+	WebhookEvents         []string
+	Monitor               MonitorConfig
+	// ArtifactStore is where scan artifacts are kept: "local", the scan
+	// directories, or "s3", the bucket S3 names.
+	ArtifactStore string
+	S3            blobstore.S3Config
+	// NiceLevels maps scan priorities to the niceness of their tools, as
+	// priority=nice pairs; empty leaves tools at the server's niceness.
+	// NiceFloor and NiceCeiling bound the niceness.
+	NiceLevels  string
+	NiceFloor   int
+	NiceCeiling int
+	// VulnDBPath is the EPSS/CVSS dataset findings are scored from. The
+	// server downloads it again every VulnDBRefreshInterval; 0 leaves that
+	// to refresh-vulndb, e.g. from cron.
+	VulnDBPath            string
+	VulnDBRefreshInterval time.Duration
+	SLO                   SLOConfig
+	// SeverityRulesPath is a YAML file of severity_rules every scan applies
+	// before its module's; empty is none.
+	SeverityRulesPath string
+	// APITokens is when the server is served only to requests with a
+	// team's API token, each team seeing its own scans: APITokensAuto,
+	// APITokensRequired or APITokensOff.
+	APITokens string
+	// DuplicateScanWindow is how long after a scan finishes another scan of
+	// its module and domain is reported as a duplicate; queued and running
+	// ones always are. RejectDuplicateScans turns the report into a 409.
+	DuplicateScanWindow  time.Duration
+	RejectDuplicateScans bool
+	// ScanDiskQuotaMB is the most a scan's directory may hold before the
+	// scan is failed; 0 is no limit.
+	ScanDiskQuotaMB int
+	ResourceGuard   ResourceGuardConfig
+	// NotifyFirstSubdomain notifies when a scan saves its first live host,
+	// NotifyNoSubdomains when its subdomain stage finds none.
+	NotifyFirstSubdomain bool
+	NotifyNoSubdomains   bool
+}
+
+const (
+	// APITokensAuto requires tokens once the first one has been created,
+	// so a new server is open to anyone until then.
+	APITokensAuto = "auto"
+	// APITokensRequired requires tokens even before there are any. It is
+	// the default.
+	APITokensRequired = "required"
+	// APITokensOff serves every request every team's scans.
+	APITokensOff = "off"
+)
+
+// ResourceGuardConfig sets when the server pauses its running scans and
+// refuses new ones for lack of disk space or memory. Scans resume once the
+// resume threshold is met again, which is further from the limit so they
+// do not flap around it.
+type ResourceGuardConfig struct {
+	CheckInterval time.Duration
+	// MinFreeDiskMB is the free space the scan root needs; 0 turns the disk
+	// check off.
+	MinFreeDiskMB    int
+	ResumeFreeDiskMB int
+	// MaxMemoryMB is the most resident memory the server may use; 0 turns
+	// the memory check off.
+	MaxMemoryMB    int
+	ResumeMemoryMB int
+}
+
+func DefaultResourceGuardConfig() ResourceGuardConfig {
+	return ResourceGuardConfig{
+		CheckInterval:    15 * time.Second,
+		MinFreeDiskMB:    1024,
+		ResumeFreeDiskMB: 2048,
+	}
+}
+
+func (r ResourceGuardConfig) Validate() error {
+	if r.CheckInterval < time.Second {
+		return fmt.Errorf("RESOURCE_CHECK_INTERVAL must be at least 1s, got %s", r.CheckInterval)
+	}
+	if r.MinFreeDiskMB < 0 || r.MaxMemoryMB < 0 {
+		return fmt.Errorf("MIN_FREE_DISK_MB and MAX_MEMORY_MB must not be negative")
+	}
+	if r.MinFreeDiskMB > 0 && r.ResumeFreeDiskMB < r.MinFreeDiskMB {
+		return fmt.Errorf("RESUME_FREE_DISK_MB (%d) must be at least MIN_FREE_DISK_MB (%d)", r.ResumeFreeDiskMB, r.MinFreeDiskMB)
+	}
+	if r.MaxMemoryMB > 0 && (r.ResumeMemoryMB <= 0 || r.ResumeMemoryMB > r.MaxMemoryMB) {
+		return fmt.Errorf("RESUME_MEMORY_MB (%d) must be between 1 and MAX_MEMORY_MB (%d)", r.ResumeMemoryMB, r.MaxMemoryMB)
+	}
+	return nil
+}
+
+// SLOConfig sets when the server sends a notification about a domain
+// without a recent successful scan or a scan that runs much longer than
+// its module usually does.
+type SLOConfig struct {
+	CheckInterval time.Duration
+	// StaleAfter is how long a domain may go without a successful scan; 0
+	// turns the notification off.
+	StaleAfter time.Duration
+	// StuckPercentile is the percentile of the module's recent durations a
+	// running scan may not exceed; 0 turns the notification off.
+	// StuckMinSamples is how many finished scans the module needs first.
+	StuckPercentile float64
+	StuckMinSamples int
+}
+
+func DefaultSLOConfig() SLOConfig {
+	return SLOConfig{
+		CheckInterval:   5 * time.Minute,
+		StaleAfter:      7 * 24 * time.Hour,
+		StuckPercentile: 95,
+		StuckMinSamples: 5,
+	}
+}
+
+func (s SLOConfig) Validate() error {
+	if s.CheckInterval < time.Second {
+		return fmt.Errorf("SLO_CHECK_INTERVAL must be at least 1s, got %s", s.CheckInterval)
+	}
+	if s.StaleAfter < 0 {
+		return fmt.Errorf("SLO_STALE_AFTER must not be negative, got %s", s.StaleAfter)
+	}
+	if s.StuckPercentile < 0 || s.StuckPercentile >= 100 {
+		return fmt.Errorf("SLO_STUCK_PERCENTILE must be between 0 and 100, got %g", s.StuckPercentile)
+	}
+	if s.StuckMinSamples < 1 {
+		return fmt.Errorf("SLO_STUCK_MIN_SAMPLES must be at least 1, got %d", s.StuckMinSamples)
+	}
+	return nil
+}
+
+// MonitorConfig sets how often a running scan's directory is checked for
+// new artifacts and subdomains. Changes seen within one interval are saved
+// in a single update.
+type MonitorConfig struct {
+	ArtifactInterval  time.Duration
+	SubdomainInterval time.Duration
+	// FilePollInterval and FileWaitTimeout bound the wait for
+	// httpx_output.txt to appear.
+	FilePollInterval time.Duration
+	FileWaitTimeout  time.Duration
+	// DiskQuotaInterval is how often the size of a scan directory is
+	// checked against the disk quota.
+	DiskQuotaInterval time.Duration
+}
+
+func DefaultMonitorConfig() MonitorConfig {
+	return MonitorConfig{
+		ArtifactInterval:  3 * time.Second,
+		SubdomainInterval: 2 * time.Second,
+		FilePollInterval:  500 * time.Millisecond,
+		FileWaitTimeout:   5 * time.Minute,
+		DiskQuotaInterval: 30 * time.Second,
+	}
+}
+
+// Validate rejects intervals short enough to hammer the database or long
+// enough to leave a scan looking stuck.
+func (m MonitorConfig) Validate() error {
+	intervals := []struct {
+		name     string
+		value    time.Duration
+		min, max time.Duration
+	}{
+		{"MONITOR_ARTIFACT_INTERVAL", m.ArtifactInterval, 100 * time.Millisecond, 5 * time.Minute},
+		{"MONITOR_SUBDOMAIN_INTERVAL", m.SubdomainInterval, 100 * time.Millisecond, 5 * time.Minute},
+		{"MONITOR_FILE_POLL_INTERVAL", m.FilePollInterval, 10 * time.Millisecond, time.Minute},
+		{"MONITOR_FILE_WAIT_TIMEOUT", m.FileWaitTimeout, time.Second, 24 * time.Hour},
+		{"MONITOR_DISK_QUOTA_INTERVAL", m.DiskQuotaInterval, time.Second, time.Hour},
+	}
+	for _, i := range intervals {
+		if i.value < i.min || i.value > i.max {
+			return fmt.Errorf("%s must be between %s and %s, got %s", i.name, i.min, i.max, i.value)
+		}
+	}
+	if m.FileWaitTimeout < m.FilePollInterval {
+		return fmt.Errorf("MONITOR_FILE_WAIT_TIMEOUT (%s) is shorter than MONITOR_FILE_POLL_INTERVAL (%s)", m.FileWaitTimeout, m.FilePollInterval)
+	}
+	return nil
 }
 
 // LoadConfig loads database config from environment variables with sensible defaults.
-// Supported env vars: DB_HOST, DB_PORT, DB_USER, DB_PASSWORD, DB_NAME, MAX_CONCURRENT_SCANS
+// Supported env vars: DB_HOST, DB_PORT, DB_USER, DB_PASSWORD, DB_NAME,
+// PIPELINER_MAX_CONCURRENT_SCANS (or MAX_CONCURRENT_SCANS), MAX_QUEUED_SCANS, SHUTDOWN_TIMEOUT, HOST_REQUESTS_PER_SECOND, ALLOW_CONFIG_EDITS, RELEASE_SLOT_ON_PAUSE, WEBHOOK_URL, WEBHOOK_SECRET,
+// WEBHOOK_EVENTS, MONITOR_ARTIFACT_INTERVAL, MONITOR_SUBDOMAIN_INTERVAL,
+// MONITOR_FILE_POLL_INTERVAL, MONITOR_FILE_WAIT_TIMEOUT, MONITOR_DISK_QUOTA_INTERVAL, ARTIFACT_STORE, S3_BUCKET,
+// S3_PREFIX, S3_REGION, S3_ENDPOINT, AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN,
+// PRIORITY_NICE_LEVELS, PRIORITY_NICE_FLOOR, PRIORITY_NICE_CEILING, VULNDB_PATH,
+// VULNDB_REFRESH_INTERVAL, SLO_CHECK_INTERVAL, SLO_STALE_AFTER, SLO_STUCK_PERCENTILE,
+// SLO_STUCK_MIN_SAMPLES, SEVERITY_RULES_FILE, REQUIRE_API_TOKENS,
+// DUPLICATE_SCAN_WINDOW, REJECT_DUPLICATE_SCANS, SCAN_DISK_QUOTA_MB,
+// RESOURCE_CHECK_INTERVAL, MIN_FREE_DISK_MB, RESUME_FREE_DISK_MB,
+// MAX_MEMORY_MB, RESUME_MEMORY_MB, NOTIFY_FIRST_SUBDOMAIN and
+// NOTIFY_NO_SUBDOMAINS
 func LoadConfig() *Config {
 	host := getenvDefault("DB_HOST", "localhost")
 	portStr := getenvDefault("DB_PORT", "5432")
@@ -27,25 +236,197 @@ func LoadConfig() *Config {
 	pass := getenvDefault("DB_PASSWORD", "pipeliner")
 	name := getenvDefault("DB_NAME", "pipeliner")
 
-	maxConcurrentStr := getenvDefault("MAX_CONCURRENT_SCANS", "1")
+	maxConcurrentStr := getenvDefault("PIPELINER_MAX_CONCURRENT_SCANS", getenvDefault("MAX_CONCURRENT_SCANS", "1"))
 	maxConcurrent, err := strconv.Atoi(maxConcurrentStr)
 	if err != nil || maxConcurrent < 1 {
 		maxConcurrent = 1
 	}
 
+	maxQueued, err := strconv.Atoi(getenvDefault("MAX_QUEUED_SCANS", "100"))
+	if err != nil || maxQueued < 0 {
+		maxQueued = 100
+	}
+
+	hostRate, err := strconv.ParseFloat(getenvDefault("HOST_REQUESTS_PER_SECOND", "0"), 64)
+	if err != nil || hostRate < 0 {
+		hostRate = 0
+	}
+
+	diskQuota, err := strconv.Atoi(getenvDefault("SCAN_DISK_QUOTA_MB", "0"))
+	if err != nil || diskQuota < 0 {
+		diskQuota = 0
+	}
+
+	allowEdits, _ := strconv.ParseBool(getenvDefault("ALLOW_CONFIG_EDITS", "false"))
+	rejectDuplicates, _ := strconv.ParseBool(getenvDefault("REJECT_DUPLICATE_SCANS", "false"))
+
+	releaseOnPause, err := strconv.ParseBool(getenvDefault("RELEASE_SLOT_ON_PAUSE", "true"))
+	if err != nil {
+		releaseOnPause = true
+	}
+	notifyFirst, err := strconv.ParseBool(getenvDefault("NOTIFY_FIRST_SUBDOMAIN", "true"))
+	if err != nil {
+		notifyFirst = true
+	}
+	notifyNone, err := strconv.ParseBool(getenvDefault("NOTIFY_NO_SUBDOMAINS", "true"))
+	if err != nil {
+		notifyNone = true
+	}
+
+	var webhookEvents []string
+	for _, event := range strings.Split(os.Getenv("WEBHOOK_EVENTS"), ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			webhookEvents = append(webhookEvents, event)
+		}
+	}
+
+	nice := tools.DefaultNicePolicy()
+	if floor, err := strconv.Atoi(os.Getenv("PRIORITY_NICE_FLOOR")); err == nil {
+		nice.Floor = floor
+	}
+	if ceiling, err := strconv.Atoi(os.Getenv("PRIORITY_NICE_CEILING")); err == nil {
+		nice.Ceiling = ceiling
+	}
+
+	slo := DefaultSLOConfig()
+	slo.CheckInterval = getenvDuration("SLO_CHECK_INTERVAL", slo.CheckInterval)
+	slo.StaleAfter = getenvDuration("SLO_STALE_AFTER", slo.StaleAfter)
+	if percentile, err := strconv.ParseFloat(os.Getenv("SLO_STUCK_PERCENTILE"), 64); err == nil {
+		slo.StuckPercentile = percentile
+	}
+	if samples, err := strconv.Atoi(os.Getenv("SLO_STUCK_MIN_SAMPLES")); err == nil {
+		slo.StuckMinSamples = samples
+	}
+
+	// unset resume thresholds follow the limits they go with
+	guard := DefaultResourceGuardConfig()
+	guard.CheckInterval = getenvDuration("RESOURCE_CHECK_INTERVAL", guard.CheckInterval)
+	if minFree, err := strconv.Atoi(os.Getenv("MIN_FREE_DISK_MB")); err == nil {
+		guard.MinFreeDiskMB = minFree
+		guard.ResumeFreeDiskMB = 2 * minFree
+	}
+	if resumeFree, err := strconv.Atoi(os.Getenv("RESUME_FREE_DISK_MB")); err == nil {
+		guard.ResumeFreeDiskMB = resumeFree
+	}
+	if maxMemory, err := strconv.Atoi(os.Getenv("MAX_MEMORY_MB")); err == nil {
+		guard.MaxMemoryMB = maxMemory
+		guard.ResumeMemoryMB = maxMemory * 9 / 10
+	}
+	if resumeMemory, err := strconv.Atoi(os.Getenv("RESUME_MEMORY_MB")); err == nil {
+		guard.ResumeMemoryMB = resumeMemory
+	}
+
+	monitor := DefaultMonitorConfig()
+	monitor.ArtifactInterval = getenvDuration("MONITOR_ARTIFACT_INTERVAL", monitor.ArtifactInterval)
+	monitor.SubdomainInterval = getenvDuration("MONITOR_SUBDOMAIN_INTERVAL", monitor.SubdomainInterval)
+	monitor.FilePollInterval = getenvDuration("MONITOR_FILE_POLL_INTERVAL", monitor.FilePollInterval)
+	monitor.FileWaitTimeout = getenvDuration("MONITOR_FILE_WAIT_TIMEOUT", monitor.FileWaitTimeout)
+	monitor.DiskQuotaInterval = getenvDuration("MONITOR_DISK_QUOTA_INTERVAL", monitor.DiskQuotaInterval)
+
 	return &Config{
-		DBHost:             host,
-		DBPort:             port,
-		DBUser:             user,
-		DBPassword:         pass,
-		DBName:             name,
-		MaxConcurrentScans: maxConcurrent,
+		DBHost:                host,
+		DBPort:                port,
+		DBUser:                user,
+		DBPassword:            pass,
+		DBName:                name,
+		MaxConcurrentScans:    maxConcurrent,
+		MaxQueuedScans:        maxQueued,
+		ShutdownTimeout:       getenvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		HostRequestsPerSecond: hostRate,
+		AllowConfigEdits:      allowEdits,
+		ReleaseSlotOnPause:    releaseOnPause,
+		WebhookURL:            os.Getenv("WEBHOOK_URL"),
+		WebhookSecret:         os.Getenv("WEBHOOK_SECRET"),
+		WebhookEvents:         webhookEvents,
+		Monitor:               monitor,
+		ArtifactStore:         strings.ToLower(getenvDefault("ARTIFACT_STORE", "local")),
+		S3: blobstore.S3Config{
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Prefix:          os.Getenv("S3_PREFIX"),
+			Region:          os.Getenv("S3_REGION"),
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		},
+		NiceLevels:  os.Getenv("PRIORITY_NICE_LEVELS"),
+		NiceFloor:   nice.Floor,
+		NiceCeiling: nice.Ceiling,
+
+		VulnDBPath:            getenvDefault("VULNDB_PATH", vulndb.DefaultPath),
+		VulnDBRefreshInterval: getenvDuration("VULNDB_REFRESH_INTERVAL", 0),
+		SLO:                   slo,
+		SeverityRulesPath:     os.Getenv("SEVERITY_RULES_FILE"),
+		APITokens:             apiTokenMode(os.Getenv("REQUIRE_API_TOKENS")),
+		DuplicateScanWindow:   getenvDuration("DUPLICATE_SCAN_WINDOW", 10*time.Minute),
+		RejectDuplicateScans:  rejectDuplicates,
+		ScanDiskQuotaMB:       diskQuota,
+		ResourceGuard:         guard,
+		NotifyFirstSubdomain:  notifyFirst,
+		NotifyNoSubdomains:    notifyNone,
 	}
 }
 
+// NicePolicy is the mapping of scan priorities to tool niceness the
+// configuration describes.
+func (c *Config) NicePolicy() (tools.NicePolicy, error) {
+	levels, err := tools.ParseNiceLevels(c.NiceLevels)
+	if err != nil {
+		return tools.NicePolicy{}, fmt.Errorf("PRIORITY_NICE_LEVELS: %w", err)
+	}
+	policy := tools.NicePolicy{Levels: levels, Floor: c.NiceFloor, Ceiling: c.NiceCeiling}
+	if err := policy.Validate(); err != nil {
+		return tools.NicePolicy{}, err
+	}
+	return policy, nil
+}
+
+// SeverityRules are the global severity rules, read from
+// SeverityRulesPath.
+func (c *Config) SeverityRules() ([]scoring.Rule, error) {
+	if c.SeverityRulesPath == "" {
+		return nil, nil
+	}
+	rules, err := scoring.LoadFile(c.SeverityRulesPath)
+	if err != nil {
+		return nil, fmt.Errorf("SEVERITY_RULES_FILE: %w", err)
+	}
+	return rules, nil
+}
+
+// Webhooks returns the global default webhook, if one is configured.
+func (c *Config) Webhooks() []models.ScanWebhook {
+	if c.WebhookURL == "" {
+		return nil
+	}
+	return []models.ScanWebhook{{URL: c.WebhookURL, Secret: c.WebhookSecret, Events: c.WebhookEvents}}
+}
+
+// apiTokenMode reads REQUIRE_API_TOKENS: auto and false have to be asked
+// for, anything else requires tokens.
+func apiTokenMode(value string) string {
+	if strings.EqualFold(value, APITokensAuto) {
+		return APITokensAuto
+	}
+	if require, err := strconv.ParseBool(value); err == nil && !require {
+		return APITokensOff
+	}
+	return APITokensRequired
+}
+
 func getenvDefault(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
 	}
 	return def
 }
+
+// getenvDuration parses key as a duration such as "10s", falling back to
+// def when it is unset or unparsable.
+func getenvDuration(key string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadConfig_MonitorOverrides(t *testing.T) {
+	t.Setenv("MONITOR_SUBDOMAIN_INTERVAL", "15s")
+	t.Setenv("MONITOR_FILE_WAIT_TIMEOUT", "30m")
+	t.Setenv("MONITOR_ARTIFACT_INTERVAL", "soon")
+
+	monitor := LoadConfig().Monitor
+	if monitor.SubdomainInterval != 15*time.Second || monitor.FileWaitTimeout != 30*time.Minute {
+		t.Errorf("overrides not applied: %+v", monitor)
+	}
+	if monitor.ArtifactInterval != DefaultMonitorConfig().ArtifactInterval {
+		t.Errorf("unparsable interval should keep the default, got %s", monitor.ArtifactInterval)
+	}
+	if err := monitor.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMonitorConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*MonitorConfig)
+	}{
+		{"tick too short", func(m *MonitorConfig) { m.SubdomainInterval = time.Millisecond }},
+		{"tick too long", func(m *MonitorConfig) { m.ArtifactInterval = time.Hour }},
+		{"no poll", func(m *MonitorConfig) { m.FilePollInterval = 0 }},
+		{"wait shorter than poll", func(m *MonitorConfig) {
+			m.FilePollInterval = 30 * time.Second
+			m.FileWaitTimeout = 10 * time.Second
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			monitor := DefaultMonitorConfig()
+			tt.change(&monitor)
+			if err := monitor.Validate(); err == nil {
+				t.Errorf("expected %+v to be rejected", monitor)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_NicePolicy(t *testing.T) {
+	t.Setenv("PRIORITY_NICE_LEVELS", "10=0,0=10")
+	t.Setenv("PRIORITY_NICE_CEILING", "15")
+
+	policy, err := LoadConfig().NicePolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.Floor != 0 || policy.Ceiling != 15 || policy.Levels[10] != 0 || policy.Levels[0] != 10 {
+		t.Errorf("unexpected policy: %+v", policy)
+	}
+
+	t.Setenv("PRIORITY_NICE_LEVELS", "high=0")
+	if _, err := LoadConfig().NicePolicy(); err == nil {
+		t.Error("expected an error for an unparsable level")
+	}
+	t.Setenv("PRIORITY_NICE_LEVELS", "")
+	t.Setenv("PRIORITY_NICE_FLOOR", "16")
+	if _, err := LoadConfig().NicePolicy(); err == nil {
+		t.Error("expected an error for a floor above the ceiling")
+	}
+}
+
+func TestLoadConfig_ResourceGuard(t *testing.T) {
+	t.Setenv("MIN_FREE_DISK_MB", "500")
+	t.Setenv("MAX_MEMORY_MB", "2000")
+	t.Setenv("RESUME_MEMORY_MB", "1500")
+
+	guard := LoadConfig().ResourceGuard
+	if guard.ResumeFreeDiskMB != 1000 || guard.ResumeMemoryMB != 1500 {
+		t.Errorf("resume thresholds not applied: %+v", guard)
+	}
+	if err := guard.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	guard.ResumeFreeDiskMB = 100
+	if err := guard.Validate(); err == nil {
+		t.Error("a resume threshold below the limit should be rejected")
+	}
+}
+
+func TestLoadConfig_APITokens(t *testing.T) {
+	for value, want := range map[string]string{
+		"":      APITokensRequired,
+		"yes":   APITokensRequired,
+		"auto":  APITokensAuto,
+		"true":  APITokensRequired,
+		"1":     APITokensRequired,
+		"false": APITokensOff,
+	} {
+		t.Setenv("REQUIRE_API_TOKENS", value)
+		if got := LoadConfig().APITokens; got != want {
+			t.Errorf("REQUIRE_API_TOKENS=%q gives %s, want %s", value, got, want)
+		}
+	}
+}
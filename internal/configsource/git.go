@@ -0,0 +1,342 @@
+// Package configsource keeps scan modules in sync with a remote git
+// repository instead of the local config directory.
+package configsource
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"pipeliner/internal/utils"
+	"pipeliner/pkg/engine"
+	"pipeliner/pkg/logger"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRef     = "main"
+	defaultRefresh = 15 * time.Minute
+	currentFile    = "current"
+)
+
+var ErrNoModules = errors.New("no scan modules found")
+
+// GitConfig describes the repository modules are pulled from.
+type GitConfig struct {
+	URL string
+	Ref string
+	// Path is the directory inside the repository holding the modules.
+	Path string
+
+	// Token authenticates HTTPS remotes, as Username; SSHKey is a private key
+	// file for SSH remotes.
+	Username string
+	Token    string
+	SSHKey   string
+
+	CacheDir string
+	Refresh  time.Duration
+}
+
+// GitConfigFromEnv reads PIPELINER_CONFIG_GIT_URL and its companions. It
+// reports false when no git source is configured.
+func GitConfigFromEnv() (GitConfig, bool) {
+	cfg := GitConfig{
+		URL:      os.Getenv("PIPELINER_CONFIG_GIT_URL"),
+		Ref:      getenvDefault("PIPELINER_CONFIG_GIT_REF", defaultRef),
+		Path:     os.Getenv("PIPELINER_CONFIG_GIT_PATH"),
+		Username: getenvDefault("PIPELINER_CONFIG_GIT_USERNAME", "git"),
+		Token:    os.Getenv("PIPELINER_CONFIG_GIT_TOKEN"),
+		SSHKey:   os.Getenv("PIPELINER_CONFIG_GIT_SSH_KEY"),
+		CacheDir: getenvDefault("PIPELINER_CONFIG_GIT_CACHE", filepath.Join(".cache", "pipeliner-config")),
+		Refresh:  defaultRefresh,
+	}
+	if refresh, err := time.ParseDuration(os.Getenv("PIPELINER_CONFIG_GIT_REFRESH")); err == nil {
+		cfg.Refresh = refresh
+	}
+	return cfg, cfg.URL != ""
+}
+
+// FromEnv loads the git source configured in the environment, if any, and
+// returns nil otherwise. With refresh set it keeps pulling until ctx is done.
+func FromEnv(ctx context.Context, refresh bool) (*GitSource, error) {
+	cfg, ok := GitConfigFromEnv()
+	if !ok {
+		return nil, nil
+	}
+	if !refresh {
+		cfg.Refresh = 0
+	}
+	src := NewGitSource(cfg)
+	if err := src.Start(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load scan modules from %s: %w", src.source(), err)
+	}
+	return src, nil
+}
+
+// GitSource checks out the configured ref into its cache directory and
+// switches module lookups over to it once every module in it validates.
+type GitSource struct {
+	cfg GitConfig
+	log *logger.Logger
+
+	mu sync.Mutex
+	// revision is the commit currently in use
+	revision string
+}
+
+func NewGitSource(cfg GitConfig) *GitSource {
+	if cfg.Ref == "" {
+		cfg.Ref = defaultRef
+	}
+	return &GitSource{
+		cfg: cfg,
+		log: logger.NewLogger(logrus.InfoLevel),
+	}
+}
+
+// Load syncs once. If the remote cannot be reached or its modules are broken,
+// the last revision that validated is used instead, when there is one.
+func (g *GitSource) Load(ctx context.Context) error {
+	err := g.Sync(ctx)
+	if err == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.revision != "" {
+		return err
+	}
+	revision, readErr := os.ReadFile(filepath.Join(g.cfg.CacheDir, currentFile))
+	if readErr != nil {
+		return err
+	}
+	sha := strings.TrimSpace(string(revision))
+	dir := g.moduleDir(sha)
+	if _, statErr := os.Stat(dir); statErr != nil {
+		return err
+	}
+
+	g.log.Warn("Config sync failed, using cached revision", logger.Fields{"error": err, "revision": sha})
+	g.activate(sha)
+	return nil
+}
+
+// Start loads the modules and keeps refreshing them until ctx is done.
+func (g *GitSource) Start(ctx context.Context) error {
+	if err := g.Load(ctx); err != nil {
+		return err
+	}
+	if g.cfg.Refresh <= 0 {
+		return nil
+	}
+
+	go func() {
+		ticker := time.NewTicker(g.cfg.Refresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := g.Sync(ctx); err != nil {
+					g.log.Error("Config sync failed, keeping current modules", logger.Fields{"error": err, "revision": g.Revision()})
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Revision is the commit modules are currently read from.
+func (g *GitSource) Revision() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.revision
+}
+
+// Sync fetches the ref and, if it moved, checks it out and validates every
+// module in it. A revision with a broken module is never swapped in.
+func (g *GitSource) Sync(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	repo := filepath.Join(g.cfg.CacheDir, "repo")
+	if _, err := os.Stat(filepath.Join(repo, ".git")); err != nil {
+		if err := os.MkdirAll(repo, 0755); err != nil {
+			return fmt.Errorf("create config cache: %w", err)
+		}
+		if _, err := g.git(ctx, repo, "init", "-q"); err != nil {
+			return err
+		}
+		if _, err := g.git(ctx, repo, "remote", "add", "origin", g.cfg.URL); err != nil {
+			return err
+		}
+	}
+
+	if _, err := g.git(ctx, repo, "fetch", "-q", "--depth", "1", "origin", g.cfg.Ref); err != nil {
+		return err
+	}
+	out, err := g.git(ctx, repo, "rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return err
+	}
+	sha := strings.TrimSpace(string(out))
+	if sha == g.revision {
+		return nil
+	}
+
+	checkout := filepath.Join(g.cfg.CacheDir, sha)
+	if err := os.RemoveAll(checkout); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(checkout, 0755); err != nil {
+		return err
+	}
+	if _, err := g.git(ctx, repo, "--work-tree", checkout, "checkout", "-q", "-f", sha, "--", "."); err != nil {
+		os.RemoveAll(checkout)
+		return err
+	}
+
+	if err := validateModules(g.moduleDir(sha)); err != nil {
+		os.RemoveAll(checkout)
+		return fmt.Errorf("refusing config revision %s: %w", sha, err)
+	}
+
+	previous := g.revision
+	g.activate(sha)
+	if err := os.WriteFile(filepath.Join(g.cfg.CacheDir, currentFile), []byte(sha+"\n"), 0644); err != nil {
+		g.log.Warn("Failed to remember config revision", logger.Fields{"error": err})
+	}
+	g.prune(sha, previous)
+
+	g.log.Info("Scan modules updated from git", logger.Fields{"source": g.source(), "revision": sha, "previous": previous})
+	return nil
+}
+
+// activate must be called with mu held.
+func (g *GitSource) activate(sha string) {
+	g.revision = sha
+	utils.SetModuleOrigin(utils.ModuleOrigin{
+		Dir:      g.moduleDir(sha),
+		Source:   g.source(),
+		Revision: sha,
+	})
+}
+
+// prune removes old checkouts. The previous one is kept for scans that may
+// still be loading it, and the ones running scans hold are kept until a
+// later prune.
+func (g *GitSource) prune(keep ...string) {
+	entries, err := os.ReadDir(g.cfg.CacheDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || name == "repo" || slices.Contains(keep, name) || utils.ModuleRevisionHeld(name) {
+			continue
+		}
+		os.RemoveAll(filepath.Join(g.cfg.CacheDir, name))
+	}
+}
+
+func (g *GitSource) moduleDir(sha string) string {
+	dir, _ := filepath.Abs(filepath.Join(g.cfg.CacheDir, sha, g.cfg.Path))
+	return dir
+}
+
+// source is the remote URL without credentials, followed by the ref.
+func (g *GitSource) source() string {
+	remote := g.cfg.URL
+	if u, err := url.Parse(remote); err == nil && u.User != nil {
+		u.User = nil
+		remote = u.String()
+	}
+	return remote + "#" + g.cfg.Ref
+}
+
+func (g *GitSource) git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// credentials go through the environment so they stay out of the
+	// process list and the repository config
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if g.cfg.Token != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(g.cfg.Username + ":" + g.cfg.Token))
+		cmd.Env = append(cmd.Env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+auth,
+		)
+	}
+	if g.cfg.SSHKey != "" {
+		cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND="+g.sshCommand())
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// sshCommand is the GIT_SSH_COMMAND using the key. git runs it through the
+// shell, so the key path is quoted.
+func (g *GitSource) sshCommand() string {
+	return "ssh -i " + shellQuote(g.cfg.SSHKey) + " -o IdentitiesOnly=yes"
+}
+
+// shellQuote quotes s as a single sh word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// validateModules checks every module in dir and reports all broken ones.
+func validateModules(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	count := 0
+	for _, entry := range entries {
+		if entry.IsDir() || (!strings.HasSuffix(entry.Name(), ".yaml") && !strings.HasSuffix(entry.Name(), ".yml")) {
+			continue
+		}
+		count++
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := engine.ValidateModuleSource(entry.Name(), data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if count == 0 {
+		return ErrNoModules
+	}
+	return errors.Join(errs...)
+}
+
+func getenvDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
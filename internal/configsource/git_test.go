@@ -0,0 +1,114 @@
+package configsource
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pipeliner/internal/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validModule = `name: quick
+execution_mode: sequential
+tools:
+  - name: subfinder
+    command: subfinder
+`
+
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+func commitModule(t *testing.T, remote, content string) string {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(remote, "quick.yaml"), []byte(content), 0644))
+	gitCmd(t, remote, "add", "-A")
+	gitCmd(t, remote, "commit", "-q", "-m", "update")
+	return gitCmd(t, remote, "rev-parse", "HEAD")
+}
+
+func TestGitSource_RefusesBrokenRevision(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	previous := utils.CurrentModuleOrigin()
+	t.Cleanup(func() { utils.SetModuleOrigin(previous) })
+
+	remote := t.TempDir()
+	gitCmd(t, remote, "init", "-q", "-b", "main")
+	good := commitModule(t, remote, validModule)
+
+	src := NewGitSource(GitConfig{URL: remote, CacheDir: t.TempDir()})
+	require.NoError(t, src.Sync(context.Background()))
+
+	origin := utils.CurrentModuleOrigin()
+	assert.Equal(t, good, origin.Revision)
+	assert.Equal(t, remote+"#main", origin.Source)
+	assert.FileExists(t, filepath.Join(origin.Dir, "quick.yaml"))
+
+	commitModule(t, remote, validModule+"    retries: lots\n")
+	err := src.Sync(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tools[0].retries")
+	assert.Equal(t, good, utils.CurrentModuleOrigin().Revision)
+
+	// a fresh process falls back to the last revision that validated
+	utils.SetModuleOrigin(previous)
+	restarted := NewGitSource(GitConfig{URL: remote, CacheDir: src.cfg.CacheDir})
+	require.NoError(t, restarted.Load(context.Background()))
+	assert.Equal(t, good, utils.CurrentModuleOrigin().Revision)
+}
+
+func TestGitSource_SSHCommandQuotesKeyPath(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+	key := "/keys/it's a key; touch pwned $(id)"
+	src := NewGitSource(GitConfig{SSHKey: key})
+
+	// the shell sees the key path as one argument, as given
+	args, ok := strings.CutPrefix(src.sshCommand(), "ssh ")
+	require.True(t, ok)
+	out, err := exec.Command("sh", "-c", `printf '%s\n' `+args).Output()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-i", key, "-o", "IdentitiesOnly=yes"}, strings.Split(strings.TrimSuffix(string(out), "\n"), "\n"))
+}
+
+func TestGitSource_KeepsHeldCheckouts(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	previous := utils.CurrentModuleOrigin()
+	t.Cleanup(func() { utils.SetModuleOrigin(previous) })
+
+	remote := t.TempDir()
+	gitCmd(t, remote, "init", "-q", "-b", "main")
+	first := commitModule(t, remote, validModule)
+	src := NewGitSource(GitConfig{URL: remote, CacheDir: t.TempDir()})
+	require.NoError(t, src.Sync(context.Background()))
+
+	// a scan is still running with the first revision
+	release := utils.HoldModuleOrigin(utils.CurrentModuleOrigin())
+	for _, retries := range []string{"1", "2"} {
+		commitModule(t, remote, validModule+"    retries: "+retries+"\n")
+		require.NoError(t, src.Sync(context.Background()))
+	}
+	assert.DirExists(t, filepath.Join(src.cfg.CacheDir, first))
+
+	// once it is done, the next update prunes the checkout
+	release()
+	commitModule(t, remote, validModule+"    retries: 3\n")
+	require.NoError(t, src.Sync(context.Background()))
+	assert.NoDirExists(t, filepath.Join(src.cfg.CacheDir, first))
+}
@@ -0,0 +1,32 @@
+package dao
+
+import (
+	"pipeliner/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type ConfigChangeDAO interface {
+	SaveConfigChange(change *models.ConfigChange) error
+	ListConfigChanges(module string) ([]models.ConfigChange, error)
+}
+
+type configChangeDAO struct {
+	db *gorm.DB
+}
+
+func NewConfigChangeDAO(db *gorm.DB) ConfigChangeDAO {
+	return &configChangeDAO{db: db}
+}
+
+func (dao *configChangeDAO) SaveConfigChange(change *models.ConfigChange) error {
+	return dao.db.Create(change).Error
+}
+
+func (dao *configChangeDAO) ListConfigChanges(module string) ([]models.ConfigChange, error) {
+	var changes []models.ConfigChange
+	if err := dao.db.Where("module = ?", module).Order("changed_at desc, id desc").Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,91 @@
+package dao
+
+import (
+	"pipeliner/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// findingBatchSize keeps multi-row inserts under the database's limit on
+// bound parameters.
+const findingBatchSize = 200
+
+type FindingDAO interface {
+	AddFindings(scanID string, findings []models.Finding) (int, error)
+	ListFindings(scanID string, filter models.FindingFilter, page, limit int) ([]models.Finding, error)
+	CountFindings(scanID string, filter models.FindingFilter) (int64, error)
+}
+
+type findingDAO struct {
+	db *gorm.DB
+}
+
+func NewFindingDAO(db *gorm.DB) FindingDAO {
+	return &findingDAO{db: db}
+}
+
+// AddFindings stores the findings the scan does not have yet, matched on
+// template and matched-at, and returns how many were new.
+func (dao *findingDAO) AddFindings(scanID string, findings []models.Finding) (int, error) {
+	if len(findings) == 0 {
+		return 0, nil
+	}
+	rows := make([]models.Finding, len(findings))
+	for i, f := range findings {
+		f.ID = 0
+		f.ScanID = scanID
+		rows[i] = f
+	}
+	result := dao.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&rows, findingBatchSize)
+	return int(result.RowsAffected), result.Error
+}
+
+func (dao *findingDAO) filtered(scanID string, filter models.FindingFilter) *gorm.DB {
+	query := dao.db.Model(&models.Finding{}).Where("scan_id = ?", scanID)
+	if len(filter.Severities) > 0 {
+		query = query.Where("severity IN ?", filter.Severities)
+	}
+	if filter.TemplateID != "" {
+		query = query.Where("template_id = ?", filter.TemplateID)
+	}
+	return query
+}
+
+// ListFindings returns one page of the scan's findings matching filter, in
+// the order they were found unless filter.Sort says otherwise. Pages start
+// at 1.
+func (dao *findingDAO) ListFindings(scanID string, filter models.FindingFilter, page, limit int) ([]models.Finding, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 50
+	}
+
+	order := "id asc"
+	switch filter.Sort {
+	case models.FindingSortEPSS:
+		order = "epss_score desc, id asc"
+	case models.FindingSortCVSS:
+		order = "cvss_score desc, id asc"
+	}
+
+	var findings []models.Finding
+	if err := dao.filtered(scanID, filter).
+		Order(order).
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&findings).Error; err != nil {
+		return nil, err
+	}
+	return findings, nil
+}
+
+func (dao *findingDAO) CountFindings(scanID string, filter models.FindingFilter) (int64, error) {
+	var count int64
+	if err := dao.filtered(scanID, filter).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
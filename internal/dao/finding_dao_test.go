@@ -0,0 +1,88 @@
+package dao
+
+import (
+	"testing"
+
+	"pipeliner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindingDAO_AddFindingsSkipsKnownMatches(t *testing.T) {
+	db, _ := newTestDB(t)
+	findingDao := NewFindingDAO(db)
+
+	findings := []models.Finding{
+		{TemplateID: "git-config", MatchedAt: "https://a.example.com/.git/config", Severity: "medium"},
+		{TemplateID: "env-file", MatchedAt: "https://a.example.com/.env", Severity: "critical", Tags: []string{"exposure"}},
+	}
+	added, err := findingDao.AddFindings("scan-1", findings)
+	require.NoError(t, err)
+	assert.Equal(t, 2, added)
+
+	// nuclei output is parsed again on every artifact update
+	added, err = findingDao.AddFindings("scan-1", append(findings, models.Finding{TemplateID: "env-file", MatchedAt: "https://b.example.com/.env", Severity: "critical"}))
+	require.NoError(t, err)
+	assert.Equal(t, 1, added)
+
+	added, err = findingDao.AddFindings("scan-2", findings[:1])
+	require.NoError(t, err)
+	assert.Equal(t, 1, added)
+
+	total, err := findingDao.CountFindings("scan-1", models.FindingFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+
+	listed, err := findingDao.ListFindings("scan-1", models.FindingFilter{}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, listed, 3)
+	assert.Equal(t, "scan-1", listed[1].ScanID)
+	assert.Equal(t, []string{"exposure"}, listed[1].Tags)
+}
+
+func TestFindingDAO_ListFindingsFilters(t *testing.T) {
+	db, _ := newTestDB(t)
+	findingDao := NewFindingDAO(db)
+
+	_, err := findingDao.AddFindings("scan-1", []models.Finding{
+		{TemplateID: "env-file", MatchedAt: "https://a.example.com/.env", Severity: "critical"},
+		{TemplateID: "git-config", MatchedAt: "https://a.example.com/.git/config", Severity: "medium", CVSSScore: 5.3, EPSSScore: 0.9},
+		{TemplateID: "env-file", MatchedAt: "https://b.example.com/.env", Severity: "critical"},
+		{TemplateID: "tech-detect", MatchedAt: "https://b.example.com", Severity: "info", CVSSScore: 9.8, EPSSScore: 0.1},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		filter models.FindingFilter
+		want   []string
+	}{
+		{"all", models.FindingFilter{}, []string{"https://a.example.com/.env", "https://a.example.com/.git/config", "https://b.example.com/.env", "https://b.example.com"}},
+		{"severities", models.FindingFilter{Severities: []string{"critical", "medium"}}, []string{"https://a.example.com/.env", "https://a.example.com/.git/config", "https://b.example.com/.env"}},
+		{"template", models.FindingFilter{TemplateID: "env-file"}, []string{"https://a.example.com/.env", "https://b.example.com/.env"}},
+		{"both", models.FindingFilter{Severities: []string{"info"}, TemplateID: "env-file"}, nil},
+		{"by epss", models.FindingFilter{Sort: models.FindingSortEPSS}, []string{"https://a.example.com/.git/config", "https://b.example.com", "https://a.example.com/.env", "https://b.example.com/.env"}},
+		{"by cvss", models.FindingFilter{Severities: []string{"medium", "info"}, Sort: models.FindingSortCVSS}, []string{"https://b.example.com", "https://a.example.com/.git/config"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := findingDao.ListFindings("scan-1", tt.filter, 1, 10)
+			require.NoError(t, err)
+			var got []string
+			for _, f := range findings {
+				got = append(got, f.MatchedAt)
+			}
+			assert.Equal(t, tt.want, got)
+
+			total, err := findingDao.CountFindings("scan-1", tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(tt.want)), total)
+		})
+	}
+
+	page, err := findingDao.ListFindings("scan-1", models.FindingFilter{}, 2, 3)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "tech-detect", page[0].TemplateID)
+}
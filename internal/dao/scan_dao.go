@@ -1,73 +1,270 @@
 package dao
 
 import (
+	"encoding/base64"
+	"fmt"
 	"pipeliner/internal/models"
+	"strconv"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 type ScanDAO interface {
+	ForTeam(teamID uint) ScanDAO
 	SaveScan(scan *models.Scan) error
 	GetScanByUUID(uuid string) (*models.Scan, error)
-	ListScans() ([]models.Scan, error)
+	GetScanSummary(uuid string) (*models.Scan, error)
+	GetScanByDir(dir string) (*models.Scan, error)
+	AverageScanDuration(scanType string, last int) (time.Duration, int, error)
+	ListFinishedScans(scanType string, last int) ([]models.Scan, error)
+	LatestFinishedScan(domain string) (*models.Scan, error)
+	LastFinishedByDomain() (map[string]int64, error)
+	ListUnfinishedScans() ([]models.Scan, error)
+	ListRecentScans(scanType string, finishedSince time.Time) ([]models.Scan, error)
 	ListScansWithPagination(page, limit int) ([]models.Scan, int64, error)
+	ListScansFiltered(filter models.ScanFilter, page, limit int) ([]models.Scan, int64, error)
+	ListScansAfter(cursor *ScanCursor, limit int) ([]models.Scan, *ScanCursor, error)
 	UpdateScan(scan *models.Scan) error
+	UpdateStatusFrom(uuid string, status models.ScanStatus, from []models.ScanStatus) (bool, error)
+	TransitionScan(scan *models.Scan, from []models.ScanStatus) (bool, error)
 	DeleteScan(uuid string) error
+	SaveHookExecution(exec *models.HookExecution) error
+	ListHookExecutions(scanID string) ([]models.HookExecution, error)
+	SaveWebhooks(webhooks []models.ScanWebhook) error
+	ListWebhooks(scanID string) ([]models.ScanWebhook, error)
+	SaveWebhookDelivery(delivery *models.WebhookDelivery) error
+	GetWebhookDelivery(scanID string, id uint) (*models.WebhookDelivery, error)
+	ListWebhookDeliveries(scanID string) ([]models.WebhookDelivery, error)
 }
 
 type scanDAO struct {
-	db *gorm.DB
+	db   *gorm.DB
+	team uint
 }
 
 func NewScanDAO(db *gorm.DB) ScanDAO {
 	return &scanDAO{db: db}
 }
 
+// ForTeam returns a DAO whose scan lists, gets and deletes only see the
+// team's scans, as if the others did not exist. Team 0 sees every scan.
+func (dao *scanDAO) ForTeam(teamID uint) ScanDAO {
+	return &scanDAO{db: dao.db, team: teamID}
+}
+
+// scans queries the scans table of db, limited to the DAO's team.
+func (dao *scanDAO) scans(db *gorm.DB) *gorm.DB {
+	query := db.Model(&models.Scan{})
+	if dao.team != 0 {
+		query = query.Where("team_id = ?", dao.team)
+	}
+	return query
+}
+
+// SaveScan inserts the scan and any subdomains it already has.
 func (dao *scanDAO) SaveScan(scan *models.Scan) error {
-	return dao.db.Create(scan).Error
+	return dao.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(scan).Error; err != nil {
+			return err
+		}
+		rows := subdomainRows(scan.UUID, scan.Subdomains)
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&rows, subdomainBatchSize).Error
+	})
 }
 
+// UpdateScan saves everything but the status, which only moves through
+// UpdateStatusFrom and TransitionScan.
 func (dao *scanDAO) UpdateScan(scan *models.Scan) error {
-	return dao.db.Save(scan).Error
+	return dao.db.Omit("status").Save(scan).Error
+}
+
+// UpdateStatusFrom sets the scan status if it is currently one of from. It
+// reports whether a row was updated.
+func (dao *scanDAO) UpdateStatusFrom(uuid string, status models.ScanStatus, from []models.ScanStatus) (bool, error) {
+	result := dao.db.Model(&models.Scan{}).
+		Where("uuid = ? AND status IN ?", uuid, from).
+		Update("status", status)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// TransitionScan saves the scan, status included, if its stored status is
+// one of from. It reports whether it did.
+func (dao *scanDAO) TransitionScan(scan *models.Scan, from []models.ScanStatus) (bool, error) {
+	result := dao.db.Model(scan).Where("status IN ?", from).Select("*").Updates(scan)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
 }
 
 func (dao *scanDAO) GetScanByUUID(uuid string) (*models.Scan, error) {
 	var scan models.Scan
-	if err := dao.db.Where("uuid = ?", uuid).First(&scan).Error; err != nil {
+	if err := dao.scans(dao.db).Where("uuid = ?", uuid).First(&scan).Error; err != nil {
+		return nil, err
+	}
+	if err := dao.db.Where("scan_id = ?", uuid).Order("id asc").Find(&scan.Subdomains).Error; err != nil {
 		return nil, err
 	}
 	return &scan, nil
 }
 
-func (dao *scanDAO) ListScans() ([]models.Scan, error) {
+// GetScanSummary loads a scan without its subdomains.
+func (dao *scanDAO) GetScanSummary(uuid string) (*models.Scan, error) {
+	var scan models.Scan
+	if err := dao.scans(dao.db).Where("uuid = ?", uuid).First(&scan).Error; err != nil {
+		return nil, err
+	}
+	return &scan, nil
+}
+
+// AverageScanDuration averages the run time of the last finished scans of
+// a module and says how many it averaged; 0 and 0 when there are none.
+// GetScanByDir loads the scan whose tools write to dir, without its
+// subdomains.
+func (dao *scanDAO) GetScanByDir(dir string) (*models.Scan, error) {
+	var scan models.Scan
+	if err := dao.scans(dao.db).Where("scan_dir = ?", dir).First(&scan).Error; err != nil {
+		return nil, err
+	}
+	return &scan, nil
+}
+
+func (dao *scanDAO) AverageScanDuration(scanType string, last int) (time.Duration, int, error) {
+	scans, err := dao.ListFinishedScans(scanType, last)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(scans) == 0 {
+		return 0, 0, nil
+	}
+	var total int64
+	for _, scan := range scans {
+		total += scan.UpdatedAt - scan.CreatedAt
+	}
+	return time.Duration(total/int64(len(scans))) * time.Second, len(scans), nil
+}
+
+// ListFinishedScans returns the last finished scans of a module, newest
+// first, without their subdomains.
+func (dao *scanDAO) ListFinishedScans(scanType string, last int) ([]models.Scan, error) {
 	var scans []models.Scan
-	if err := dao.db.Order("created_at desc").Limit(50).Find(&scans).Error; err != nil {
+	if err := dao.scans(dao.db).Where("scan_type = ? AND status IN ?", scanType, models.FinishedScanStatuses).
+		Order("created_at desc").
+		Limit(last).
+		Find(&scans).Error; err != nil {
 		return nil, err
 	}
 	return scans, nil
 }
 
-func (dao *scanDAO) ListScansWithPagination(page, limit int) ([]models.Scan, int64, error) {
+// LatestFinishedScan is the newest finished scan of a domain with any
+// module, or nil if it has none.
+func (dao *scanDAO) LatestFinishedScan(domain string) (*models.Scan, error) {
 	var scans []models.Scan
-	var total int64
+	if err := dao.scans(dao.db).Where("domain = ? AND status IN ?", domain, models.FinishedScanStatuses).
+		Order("created_at desc").
+		Limit(1).
+		Find(&scans).Error; err != nil {
+		return nil, err
+	}
+	if len(scans) == 0 {
+		return nil, nil
+	}
+	return &scans[0], nil
+}
 
-	if page < 1 {
-		page = 1
+// LastFinishedByDomain maps every domain with a finished scan to when its
+// newest one finished, in unix seconds.
+func (dao *scanDAO) LastFinishedByDomain() (map[string]int64, error) {
+	var rows []struct {
+		Domain   string
+		Finished int64
 	}
+	if err := dao.scans(dao.db).
+		Select("domain, MAX(updated_at) AS finished").
+		Where("status IN ?", models.FinishedScanStatuses).
+		Group("domain").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	finished := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		finished[row.Domain] = row.Finished
+	}
+	return finished, nil
+}
+
+// ListUnfinishedScans lists the queued, running and paused scans, oldest
+// first, without their subdomains.
+func (dao *scanDAO) ListUnfinishedScans() ([]models.Scan, error) {
+	var scans []models.Scan
+	err := dao.scans(dao.db).
+		Where("status IN ?", []models.ScanStatus{models.ScanQueued, models.ScanRunning, models.ScanPaused}).
+		Order("created_at asc, uuid asc").
+		Find(&scans).Error
+	return scans, err
+}
+
+// ListRecentScans lists the queued, running and paused scans of a module
+// and those that finished at or after finishedSince, oldest first, without
+// their subdomains. A zero finishedSince leaves the finished scans out.
+func (dao *scanDAO) ListRecentScans(scanType string, finishedSince time.Time) ([]models.Scan, error) {
+	unfinished := []models.ScanStatus{models.ScanQueued, models.ScanRunning, models.ScanPaused}
+	query := dao.scans(dao.db).Where("scan_type = ?", scanType)
+	if finishedSince.IsZero() {
+		query = query.Where("status IN ?", unfinished)
+	} else {
+		query = query.Where("status IN ? OR (status IN ? AND updated_at >= ?)", unfinished, models.FinishedScanStatuses, finishedSince.Unix())
+	}
+	var scans []models.Scan
+	err := query.Order("created_at asc, uuid asc").Find(&scans).Error
+	return scans, err
+}
+
+// clampLimit keeps page sizes between 1 and 100, defaulting to 10.
+func clampLimit(limit int) int {
 	if limit < 1 {
-		limit = 10
+		return 10
 	}
 	if limit > 100 {
-		limit = 100
+		return 100
+	}
+	return limit
+}
+
+// ListScansWithPagination returns a page of scans, newest first, and the
+// total count. Deep pages get slow; ListScansAfter does not.
+func (dao *scanDAO) ListScansWithPagination(page, limit int) ([]models.Scan, int64, error) {
+	return dao.ListScansFiltered(models.ScanFilter{}, page, limit)
+}
+
+// ListScansFiltered is ListScansWithPagination limited to the scans matching
+// filter; the total counts only those.
+func (dao *scanDAO) ListScansFiltered(filter models.ScanFilter, page, limit int) ([]models.Scan, int64, error) {
+	var scans []models.Scan
+	var total int64
+
+	if page < 1 {
+		page = 1
 	}
+	limit = clampLimit(limit)
 
 	offset := (page - 1) * limit
 
-	if err := dao.db.Model(&models.Scan{}).Count(&total).Error; err != nil {
+	if err := dao.filteredScans(filter).Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
-	if err := dao.db.Order("created_at desc").
+	if err := dao.filteredScans(filter).
+		Order("created_at desc, uuid desc").
 		Limit(limit).
 		Offset(offset).
 		Find(&scans).Error; err != nil {
@@ -77,13 +274,177 @@ func (dao *scanDAO) ListScansWithPagination(page, limit int) ([]models.Scan, int
 	return scans, total, nil
 }
 
+// likeEscaper escapes the LIKE wildcards of a substring.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (dao *scanDAO) filteredScans(filter models.ScanFilter) *gorm.DB {
+	query := dao.scans(dao.db)
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if filter.Domain != "" {
+		query = query.Where(`LOWER(domain) LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(strings.ToLower(filter.Domain))+"%")
+	}
+	if filter.ScanType != "" {
+		query = query.Where("scan_type = ?", filter.ScanType)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		query = query.Where("created_at >= ?", filter.CreatedAfter.Unix())
+	}
+	if !filter.CreatedBefore.IsZero() {
+		query = query.Where("created_at < ?", filter.CreatedBefore.Unix())
+	}
+	return query
+}
+
+// ScanCursor is the position of the last scan of a page in created_at desc,
+// uuid desc order.
+type ScanCursor struct {
+	CreatedAt int64
+	UUID      string
+}
+
+// String encodes the cursor for use in a URL.
+func (c ScanCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.CreatedAt, 10) + ":" + c.UUID))
+}
+
+// ParseScanCursor decodes a cursor made by ScanCursor.String.
+func ParseScanCursor(s string) (*ScanCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	createdAt, uuid, ok := strings.Cut(string(raw), ":")
+	if !ok || uuid == "" {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	ts, err := strconv.ParseInt(createdAt, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	return &ScanCursor{CreatedAt: ts, UUID: uuid}, nil
+}
+
+// ListScansAfter returns up to limit scans after cursor, newest first, using
+// the (created_at, uuid) index instead of an offset. A nil cursor starts at
+// the newest scan. The returned cursor is nil on the last page.
+func (dao *scanDAO) ListScansAfter(cursor *ScanCursor, limit int) ([]models.Scan, *ScanCursor, error) {
+	limit = clampLimit(limit)
+
+	query := dao.scans(dao.db).Order("created_at desc, uuid desc")
+	if cursor != nil {
+		query = query.Where("created_at < ? OR (created_at = ? AND uuid < ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.UUID)
+	}
+
+	// one extra row tells whether there is a next page
+	var scans []models.Scan
+	if err := query.Limit(limit + 1).Find(&scans).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(scans) <= limit {
+		return scans, nil, nil
+	}
+
+	scans = scans[:limit]
+	last := scans[limit-1]
+	return scans, &ScanCursor{CreatedAt: last.CreatedAt, UUID: last.UUID}, nil
+}
+
+// DeleteScan removes the scan and its subdomains.
 func (dao *scanDAO) DeleteScan(uuid string) error {
-	result := dao.db.Where("uuid = ?", uuid).Delete(&models.Scan{})
-	if result.Error != nil {
-		return result.Error
+	return dao.db.Transaction(func(tx *gorm.DB) error {
+		result := dao.scans(tx).Where("uuid = ?", uuid).Delete(&models.Scan{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("scan_id = ?", uuid).Delete(&models.Subdomain{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("scan_id = ?", uuid).Delete(&models.Finding{}).Error
+	})
+}
+
+func (dao *scanDAO) SaveHookExecution(exec *models.HookExecution) error {
+	return dao.db.Create(exec).Error
+}
+
+func (dao *scanDAO) ListHookExecutions(scanID string) ([]models.HookExecution, error) {
+	var execs []models.HookExecution
+	if err := dao.db.Where("scan_id = ?", scanID).Order("started_at asc, id asc").Find(&execs).Error; err != nil {
+		return nil, err
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	return execs, nil
+}
+
+func (dao *scanDAO) SaveWebhooks(webhooks []models.ScanWebhook) error {
+	if len(webhooks) == 0 {
+		return nil
 	}
-	return nil
+	return dao.db.Create(&webhooks).Error
+}
+
+func (dao *scanDAO) ListWebhooks(scanID string) ([]models.ScanWebhook, error) {
+	var webhooks []models.ScanWebhook
+	if err := dao.db.Where("scan_id = ?", scanID).Order("id asc").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// SaveWebhookDelivery inserts a new delivery or updates an existing one.
+func (dao *scanDAO) SaveWebhookDelivery(delivery *models.WebhookDelivery) error {
+	return dao.db.Save(delivery).Error
+}
+
+func (dao *scanDAO) GetWebhookDelivery(scanID string, id uint) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := dao.db.Where("scan_id = ? AND id = ?", scanID, id).First(&delivery).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (dao *scanDAO) ListWebhookDeliveries(scanID string) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	if err := dao.db.Where("scan_id = ?", scanID).Order("created_at desc, id desc").Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// MigrateStatuses repairs scan and subdomain statuses that are not in
+// models.ScanStatuses or models.SubdomainStatuses, so AutoMigrate can add
+// the check constraints. A misspelled "complete" becomes completed; other
+// unknown scan statuses become failed. The subdomain constraint from before
+// unresponsive and dead is dropped for AutoMigrate to replace. Run it before
+// AutoMigrate.
+func MigrateStatuses(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if tx.Migrator().HasTable(&models.Scan{}) {
+			if err := tx.Model(&models.Scan{}).Where("status = ?", "complete").
+				UpdateColumn("status", models.ScanCompleted).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Scan{}).Where("status IS NULL OR status NOT IN ?", models.ScanStatuses).
+				UpdateColumn("status", models.ScanFailed).Error; err != nil {
+				return err
+			}
+		}
+		if tx.Migrator().HasTable(&models.Subdomain{}) {
+			if err := tx.Model(&models.Subdomain{}).Where("status IS NULL OR status NOT IN ?", models.SubdomainStatuses).
+				UpdateColumn("status", models.SubdomainDiscovered).Error; err != nil {
+				return err
+			}
+			if tx.Migrator().HasConstraint(&models.Subdomain{}, "chk_subdomains_status") {
+				if err := tx.Migrator().DropConstraint(&models.Subdomain{}, "chk_subdomains_status"); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
 }
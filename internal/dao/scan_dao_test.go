@@ -0,0 +1,393 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pipeliner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// statementCounter is a gorm logger that only counts executed statements.
+type statementCounter struct {
+	count atomic.Int64
+}
+
+func (c *statementCounter) LogMode(gormlogger.LogLevel) gormlogger.Interface { return c }
+func (c *statementCounter) Info(context.Context, string, ...interface{})     {}
+func (c *statementCounter) Warn(context.Context, string, ...interface{})     {}
+func (c *statementCounter) Error(context.Context, string, ...interface{})    {}
+
+func (c *statementCounter) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	c.count.Add(1)
+}
+
+func newTestDB(t *testing.T) (*gorm.DB, *statementCounter) {
+	t.Helper()
+
+	counter := &statementCounter{}
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: counter})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Scan{}, &models.Subdomain{}, &models.Finding{}))
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return db, counter
+}
+
+func TestScanDAO_UpdateStatusFrom(t *testing.T) {
+	db, _ := newTestDB(t)
+	scanDao := NewScanDAO(db)
+	from := []models.ScanStatus{models.ScanQueued, models.ScanPaused}
+
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "queued", Status: models.ScanQueued}))
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "done", Status: models.ScanCompleted}))
+
+	updated, err := scanDao.UpdateStatusFrom("queued", models.ScanRunning, from)
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	updated, err = scanDao.UpdateStatusFrom("done", models.ScanRunning, from)
+	require.NoError(t, err)
+	assert.False(t, updated)
+
+	scan, err := scanDao.GetScanByUUID("done")
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanCompleted, scan.Status)
+}
+
+func TestScanDAO_TransitionScan(t *testing.T) {
+	db, _ := newTestDB(t)
+	scanDao := NewScanDAO(db)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Status: models.ScanRunning}))
+
+	scan, err := scanDao.GetScanByUUID("scan-1")
+	require.NoError(t, err)
+	scan.Status = models.ScanFailed
+	scan.ErrorMessage = "boom"
+	updated, err := scanDao.TransitionScan(scan, []models.ScanStatus{models.ScanQueued})
+	require.NoError(t, err)
+	assert.False(t, updated)
+
+	updated, err = scanDao.TransitionScan(scan, []models.ScanStatus{models.ScanRunning})
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	// UpdateScan leaves the status alone
+	scan.Status = models.ScanRunning
+	require.NoError(t, scanDao.UpdateScan(scan))
+	stored, err := scanDao.GetScanByUUID("scan-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanFailed, stored.Status)
+	assert.Equal(t, "boom", stored.ErrorMessage)
+}
+
+func TestScanDAO_StatusCheckConstraints(t *testing.T) {
+	db, _ := newTestDB(t)
+	scanDao := NewScanDAO(db)
+
+	for _, status := range models.ScanStatuses {
+		require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-" + string(status), Status: status}), status)
+	}
+	assert.Error(t, scanDao.SaveScan(&models.Scan{UUID: "typo", Status: "complete"}))
+
+	scan, err := scanDao.GetScanByUUID("scan-queued")
+	require.NoError(t, err)
+	for _, status := range models.SubdomainStatuses {
+		require.NoError(t, NewSubdomainDAO(db).UpsertSubdomains(scan.UUID, []models.Subdomain{{Domain: string(status) + ".example.com", Status: status}}), status)
+	}
+	assert.Error(t, NewSubdomainDAO(db).UpsertSubdomains(scan.UUID, []models.Subdomain{{Domain: "gone.example.com", Status: "gone"}}))
+}
+
+func TestScanDAO_ListScansPagination(t *testing.T) {
+	db, _ := newTestDB(t)
+	dao := NewScanDAO(db)
+
+	// several scans share a created_at second, so the uuid breaks ties
+	for i := 0; i < 7; i++ {
+		require.NoError(t, dao.SaveScan(&models.Scan{UUID: fmt.Sprintf("scan-%d", i), CreatedAt: int64(100 + i/3)}))
+	}
+	want := []string{"scan-6", "scan-5", "scan-4", "scan-3", "scan-2", "scan-1", "scan-0"}
+
+	t.Run("offset", func(t *testing.T) {
+		var got []string
+		for page := 1; page <= 3; page++ {
+			scans, total, err := dao.ListScansWithPagination(page, 3)
+			require.NoError(t, err)
+			assert.Equal(t, int64(7), total)
+			for _, scan := range scans {
+				got = append(got, scan.UUID)
+			}
+		}
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		var got []string
+		var cursor *ScanCursor
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 3, "too many pages")
+			scans, next, err := dao.ListScansAfter(cursor, 3)
+			require.NoError(t, err)
+			for _, scan := range scans {
+				got = append(got, scan.UUID)
+			}
+			if next == nil {
+				break
+			}
+			// round trip through the URL form
+			cursor, err = ParseScanCursor(next.String())
+			require.NoError(t, err)
+		}
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("exact last page", func(t *testing.T) {
+		scans, next, err := dao.ListScansAfter(&ScanCursor{CreatedAt: 101, UUID: "scan-3"}, 3)
+		require.NoError(t, err)
+		assert.Len(t, scans, 3)
+		assert.Nil(t, next)
+	})
+
+	_, err := ParseScanCursor("not a cursor")
+	assert.Error(t, err)
+}
+
+func TestScanDAO_ListScansFiltered(t *testing.T) {
+	db, _ := newTestDB(t)
+	dao := NewScanDAO(db)
+
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, scan := range []models.Scan{
+		{UUID: "a", Domain: "example.com", ScanType: "full", Status: models.ScanRunning, CreatedAt: day.Unix()},
+		{UUID: "b", Domain: "api.Example.com", ScanType: "quick", Status: models.ScanCompleted, CreatedAt: day.Add(time.Hour).Unix()},
+		{UUID: "c", Domain: "example.org", ScanType: "full", Status: models.ScanFailed, CreatedAt: day.AddDate(0, 0, 1).Unix()},
+		{UUID: "d", Domain: "ex_ample.net", ScanType: "full", Status: models.ScanCompleted, CreatedAt: day.AddDate(0, 0, 2).Unix()},
+	} {
+		require.NoError(t, dao.SaveScan(&scan))
+	}
+
+	for name, tc := range map[string]struct {
+		filter models.ScanFilter
+		want   []string
+	}{
+		"none":      {models.ScanFilter{}, []string{"d", "c", "b", "a"}},
+		"status":    {models.ScanFilter{Statuses: []models.ScanStatus{models.ScanCompleted, models.ScanFailed}}, []string{"d", "c", "b"}},
+		"domain":    {models.ScanFilter{Domain: "EXAMPLE.COM"}, []string{"b", "a"}},
+		"wildcard":  {models.ScanFilter{Domain: "_"}, []string{"d"}},
+		"scan type": {models.ScanFilter{ScanType: "full", Domain: "example"}, []string{"c", "a"}},
+		"range":     {models.ScanFilter{CreatedAfter: day.Add(time.Hour), CreatedBefore: day.AddDate(0, 0, 2)}, []string{"c", "b"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			scans, total, err := dao.ListScansFiltered(tc.filter, 1, 10)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(tc.want)), total)
+			var got []string
+			for _, scan := range scans {
+				got = append(got, scan.UUID)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	scans, total, err := dao.ListScansFiltered(models.ScanFilter{ScanType: "full"}, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	require.Len(t, scans, 1)
+	assert.Equal(t, "a", scans[0].UUID)
+}
+
+func TestScanDAO_GetScanSummary(t *testing.T) {
+	db, _ := newTestDB(t)
+	scanDao := NewScanDAO(db)
+
+	require.NoError(t, scanDao.SaveScan(&models.Scan{
+		UUID:            "scan-1",
+		Status:          "completed",
+		NumberOfDomains: 1,
+		Subdomains:      []models.Subdomain{{Domain: "a.example.com", Vulns: []string{"[HIGH] panel - https://a.example.com"}}},
+		SeverityCounts:  map[string]int{"high": 1},
+	}))
+
+	scan, err := scanDao.GetScanSummary("scan-1")
+	require.NoError(t, err)
+	assert.Empty(t, scan.Subdomains)
+	assert.Equal(t, models.ScanCompleted, scan.Status)
+	assert.Equal(t, map[string]int{"high": 1}, scan.SeverityCounts)
+
+	_, err = scanDao.GetScanSummary("missing")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestScanDAO_GetScanByDir(t *testing.T) {
+	db, _ := newTestDB(t)
+	scanDao := NewScanDAO(db)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Status: models.ScanCompleted, TeamID: 1, ScanDir: "/srv/scans/full_recon_a.example.com"}))
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-2", Status: models.ScanCompleted, TeamID: 2, ScanDir: "/srv/scans/full_recon_b.example.com"}))
+
+	scan, err := scanDao.GetScanByDir("/srv/scans/full_recon_a.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "scan-1", scan.UUID)
+
+	// other teams' scans are not found, like those without the directory
+	_, err = scanDao.ForTeam(2).GetScanByDir("/srv/scans/full_recon_a.example.com")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = scanDao.GetScanByDir("/srv/scans/missing")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestScanDAO_AverageScanDuration(t *testing.T) {
+	db, _ := newTestDB(t)
+	scanDao := NewScanDAO(db)
+
+	for i, scan := range []models.Scan{
+		{UUID: "old", ScanType: "quick", Status: "completed", CreatedAt: 100, UpdatedAt: 10100},
+		{UUID: "a", ScanType: "quick", Status: "completed", CreatedAt: 1000, UpdatedAt: 1060},
+		{UUID: "b", ScanType: "quick", Status: "completed_with_warnings", CreatedAt: 2000, UpdatedAt: 2120},
+		{UUID: "failed", ScanType: "quick", Status: "failed", CreatedAt: 3000, UpdatedAt: 9000},
+		{UUID: "other", ScanType: "full", Status: "completed", CreatedAt: 4000, UpdatedAt: 9000},
+	} {
+		require.NoError(t, db.Create(&scan).Error, "scan %d", i)
+		// gorm stamps UpdatedAt on create
+		require.NoError(t, db.Model(&models.Scan{}).Where("uuid = ?", scan.UUID).UpdateColumn("updated_at", scan.UpdatedAt).Error)
+	}
+
+	average, samples, err := scanDao.AverageScanDuration("quick", 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, samples)
+	assert.Equal(t, 90*time.Second, average)
+
+	average, samples, err = scanDao.AverageScanDuration("missing", 2)
+	require.NoError(t, err)
+	assert.Zero(t, samples)
+	assert.Zero(t, average)
+}
+
+func TestScanDAO_LatestFinishedScan(t *testing.T) {
+	db, _ := newTestDB(t)
+	scanDao := NewScanDAO(db)
+
+	for i, scan := range []models.Scan{
+		{UUID: "old", Domain: "example.com", ScanType: "quick", Status: "completed", NumberOfDomains: 3, CreatedAt: 100},
+		{UUID: "new", Domain: "example.com", ScanType: "full", Status: "completed_with_warnings", NumberOfDomains: 7, CreatedAt: 200},
+		{UUID: "failed", Domain: "example.com", ScanType: "quick", Status: "failed", NumberOfDomains: 1, CreatedAt: 300},
+		{UUID: "other", Domain: "example.org", ScanType: "quick", Status: "completed", NumberOfDomains: 9, CreatedAt: 400},
+	} {
+		require.NoError(t, db.Create(&scan).Error, "scan %d", i)
+	}
+
+	scan, err := scanDao.LatestFinishedScan("example.com")
+	require.NoError(t, err)
+	require.NotNil(t, scan)
+	assert.Equal(t, "new", scan.UUID)
+	assert.Equal(t, 7, scan.NumberOfDomains)
+
+	scan, err = scanDao.LatestFinishedScan("missing.com")
+	require.NoError(t, err)
+	assert.Nil(t, scan)
+}
+
+func TestScanDAO_LastFinishedByDomain(t *testing.T) {
+	db, _ := newTestDB(t)
+	scanDao := NewScanDAO(db)
+
+	for _, scan := range []models.Scan{
+		{UUID: "old", Domain: "example.com", Status: "completed", UpdatedAt: 100},
+		{UUID: "new", Domain: "example.com", Status: "completed_with_warnings", UpdatedAt: 200},
+		{UUID: "failed", Domain: "example.com", Status: "failed", UpdatedAt: 300},
+		{UUID: "other", Domain: "example.org", Status: "completed", UpdatedAt: 400},
+		{UUID: "running", Domain: "example.net", Status: "running", UpdatedAt: 500},
+	} {
+		require.NoError(t, db.Create(&scan).Error)
+		// gorm stamps UpdatedAt on create
+		require.NoError(t, db.Model(&models.Scan{}).Where("uuid = ?", scan.UUID).UpdateColumn("updated_at", scan.UpdatedAt).Error)
+	}
+
+	finished, err := scanDao.LastFinishedByDomain()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"example.com": 200, "example.org": 400}, finished)
+}
+
+func TestScanDAO_ListUnfinishedScans(t *testing.T) {
+	db, _ := newTestDB(t)
+	scanDao := NewScanDAO(db)
+
+	for _, scan := range []models.Scan{
+		{UUID: "paused", Status: "paused", CreatedAt: 300},
+		{UUID: "queued", Status: "queued", CreatedAt: 100},
+		{UUID: "done", Status: "completed", CreatedAt: 50},
+		{UUID: "running", Status: "running", CreatedAt: 200},
+	} {
+		require.NoError(t, db.Create(&scan).Error)
+	}
+
+	scans, err := scanDao.ListUnfinishedScans()
+	require.NoError(t, err)
+	var ids []string
+	for _, scan := range scans {
+		ids = append(ids, scan.UUID)
+	}
+	assert.Equal(t, []string{"queued", "running", "paused"}, ids)
+}
+
+func TestMigrateStatuses(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	// the tables as they were before the check constraints
+	require.NoError(t, db.Exec("CREATE TABLE scans (uuid text PRIMARY KEY, status text)").Error)
+	require.NoError(t, db.Exec("INSERT INTO scans (uuid, status) VALUES ('a', 'complete'), ('b', 'running'), ('c', 'exploded'), ('d', '')").Error)
+	require.NoError(t, db.Exec("CREATE TABLE subdomains (id integer PRIMARY KEY, scan_id text, domain text, status text)").Error)
+	require.NoError(t, db.Exec("INSERT INTO subdomains (scan_id, domain, status) VALUES ('a', 'x.example.com', ''), ('a', 'y.example.com', 'alive')").Error)
+
+	require.NoError(t, MigrateStatuses(db))
+
+	var scans []struct {
+		UUID   string
+		Status string
+	}
+	require.NoError(t, db.Table("scans").Order("uuid").Find(&scans).Error)
+	got := map[string]string{}
+	for _, scan := range scans {
+		got[scan.UUID] = scan.Status
+	}
+	assert.Equal(t, map[string]string{"a": "completed", "b": "running", "c": "failed", "d": "failed"}, got)
+
+	var statuses []string
+	require.NoError(t, db.Table("subdomains").Order("domain").Pluck("status", &statuses).Error)
+	assert.Equal(t, []string{"discovered", "alive"}, statuses)
+}
+
+func TestMigrateStatuses_ReplacesSubdomainConstraint(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	// the constraint from before unresponsive and dead
+	require.NoError(t, db.Exec("CREATE TABLE subdomains (id integer PRIMARY KEY, scan_id text, domain text, status text DEFAULT 'discovered', CONSTRAINT chk_subdomains_status CHECK (status IN ('discovered','alive')))").Error)
+	require.NoError(t, db.Exec("INSERT INTO subdomains (scan_id, domain, status) VALUES ('a', 'x.example.com', 'alive')").Error)
+
+	require.NoError(t, MigrateStatuses(db))
+	require.NoError(t, db.AutoMigrate(&models.Subdomain{}))
+
+	assert.NoError(t, db.Exec("UPDATE subdomains SET status = 'dead'").Error)
+	assert.Error(t, db.Exec("UPDATE subdomains SET status = 'gone'").Error)
+}
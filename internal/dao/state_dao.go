@@ -0,0 +1,190 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"pipeliner/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// stateBatchSize is how many rows a table is read and written in at a time.
+const stateBatchSize = 500
+
+// StateModels are the tables an instance's state is made of, in an order
+// they can be restored in. New tables belong here as well as in InitDB.
+var StateModels = []interface{}{
+	&models.Team{},
+	&models.APIToken{},
+	&models.Scan{},
+	&models.Subdomain{},
+	&models.Finding{},
+	&models.HookExecution{},
+	&models.ScanWebhook{},
+	&models.WebhookDelivery{},
+	&models.ConfigChange{},
+}
+
+// StateRow is one row of a table by column name, each value encoded as
+// JSON. Columns gorm serializes, such as tags, are kept as their Go values,
+// so a dump reads the same whichever database it came from.
+type StateRow map[string]json.RawMessage
+
+// StateDAO reads and writes whole tables, for moving an instance's state to
+// another database.
+type StateDAO interface {
+	// Tables lists the tables of StateModels in restore order.
+	Tables() []string
+	CountRows(table string) (int64, error)
+	ExportTable(table string, each func(row StateRow) error) error
+	// ImportTable inserts the rows next returns until it returns io.EOF.
+	// A column the table does not have fails the import.
+	ImportTable(table string, next func() (StateRow, error)) error
+	// Transaction runs fn with a StateDAO whose writes are committed only
+	// if fn succeeds.
+	Transaction(fn func(StateDAO) error) error
+}
+
+type stateDAO struct {
+	db     *gorm.DB
+	tables []string
+	models map[string]*schema.Schema
+}
+
+func NewStateDAO(db *gorm.DB) (StateDAO, error) {
+	dao := &stateDAO{db: db, models: make(map[string]*schema.Schema, len(StateModels))}
+	for _, model := range StateModels {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, fmt.Errorf("parse %T: %w", model, err)
+		}
+		dao.tables = append(dao.tables, stmt.Schema.Table)
+		dao.models[stmt.Schema.Table] = stmt.Schema
+	}
+	return dao, nil
+}
+
+func (dao *stateDAO) Tables() []string {
+	return dao.tables
+}
+
+func (dao *stateDAO) schema(table string) (*schema.Schema, error) {
+	s, ok := dao.models[table]
+	if !ok {
+		return nil, fmt.Errorf("unknown table %q", table)
+	}
+	return s, nil
+}
+
+func (dao *stateDAO) CountRows(table string) (int64, error) {
+	if _, err := dao.schema(table); err != nil {
+		return 0, err
+	}
+	var count int64
+	err := dao.db.Table(table).Count(&count).Error
+	return count, err
+}
+
+func (dao *stateDAO) ExportTable(table string, each func(row StateRow) error) error {
+	s, err := dao.schema(table)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	batch := reflect.New(reflect.SliceOf(s.ModelType))
+	return dao.db.Model(reflect.New(s.ModelType).Interface()).
+		FindInBatches(batch.Interface(), stateBatchSize, func(tx *gorm.DB, _ int) error {
+			rows := batch.Elem()
+			for i := 0; i < rows.Len(); i++ {
+				row := make(StateRow, len(s.DBNames))
+				for _, column := range s.DBNames {
+					value, err := json.Marshal(s.FieldsByDBName[column].ReflectValueOf(ctx, rows.Index(i)).Interface())
+					if err != nil {
+						return fmt.Errorf("encode %s.%s: %w", table, column, err)
+					}
+					row[column] = value
+				}
+				if err := each(row); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+func (dao *stateDAO) ImportTable(table string, next func() (StateRow, error)) error {
+	s, err := dao.schema(table)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	batch := reflect.MakeSlice(reflect.SliceOf(s.ModelType), 0, stateBatchSize)
+	flush := func() error {
+		if batch.Len() == 0 {
+			return nil
+		}
+		if err := dao.db.Table(table).Create(batch.Interface()).Error; err != nil {
+			return fmt.Errorf("insert into %s: %w", table, err)
+		}
+		batch = batch.Slice(0, 0)
+		return nil
+	}
+
+	for {
+		row, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		record := reflect.New(s.ModelType).Elem()
+		for column, value := range row {
+			field, ok := s.FieldsByDBName[column]
+			if !ok {
+				return fmt.Errorf("table %s has no column %q", table, column)
+			}
+			if err := json.Unmarshal(value, field.ReflectValueOf(ctx, record).Addr().Interface()); err != nil {
+				return fmt.Errorf("decode %s.%s: %w", table, column, err)
+			}
+		}
+		batch = reflect.Append(batch, record)
+		if batch.Len() == stateBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	return dao.resetSequence(s)
+}
+
+// resetSequence moves a Postgres serial column past the ids just inserted,
+// which set them explicitly, so new rows do not collide with them.
+func (dao *stateDAO) resetSequence(s *schema.Schema) error {
+	pk := s.PrioritizedPrimaryField
+	if dao.db.Dialector.Name() != "postgres" || pk == nil || !pk.AutoIncrement {
+		return nil
+	}
+	return dao.db.Exec(
+		fmt.Sprintf("SELECT setval(pg_get_serial_sequence(?, ?), COALESCE((SELECT MAX(%[1]s) FROM %[2]s), 0) + 1, false)", pk.DBName, s.Table),
+		s.Table, pk.DBName,
+	).Error
+}
+
+func (dao *stateDAO) Transaction(fn func(StateDAO) error) error {
+	return dao.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&stateDAO{db: tx, tables: dao.tables, models: dao.models})
+	})
+}
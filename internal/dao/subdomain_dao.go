@@ -0,0 +1,246 @@
+package dao
+
+import (
+	"encoding/json"
+	"pipeliner/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// subdomainBatchSize keeps multi-row inserts under the database's limit on
+// bound parameters.
+const subdomainBatchSize = 200
+
+// subdomainResultColumns are the columns UpsertSubdomains overwrites on a
+// subdomain the scan already has. The status is only written by
+// UpdateStatusFrom.
+var subdomainResultColumns = []string{"open_ports", "potential_false_ports", "vulns", "dir_fuzzing", "screenshot", "sensitive", "redirects", "status_code", "title", "technologies", "content_length"}
+
+type SubdomainDAO interface {
+	AddSubdomains(scanID string, subdomains []models.Subdomain) (int, error)
+	UpsertSubdomains(scanID string, subdomains []models.Subdomain) error
+	ListByScan(scanID string) ([]models.Subdomain, error)
+	GetSubdomainsPaginated(scanID string, status models.SubdomainStatus, page, limit int) ([]models.Subdomain, error)
+	CountByScan(scanID string) (int64, error)
+	CountByStatus(scanID string) (map[models.SubdomainStatus]int64, error)
+	StatsByScan(scanID string) (models.SubdomainStats, error)
+	UpdateStatusFrom(scanID string, domains []string, status models.SubdomainStatus, from []models.SubdomainStatus) (int64, error)
+	MarkSeen(scanID string, domains []string, at time.Time) error
+}
+
+type subdomainDAO struct {
+	db *gorm.DB
+}
+
+func NewSubdomainDAO(db *gorm.DB) SubdomainDAO {
+	return &subdomainDAO{db: db}
+}
+
+// AddSubdomains stores the domains the scan does not have yet and adds them
+// to its number_of_domains, in one transaction. Known domains are left as
+// they are. It returns how many were new.
+func (dao *subdomainDAO) AddSubdomains(scanID string, subdomains []models.Subdomain) (int, error) {
+	rows := subdomainRows(scanID, subdomains)
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	var added int64
+	err := dao.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&rows, subdomainBatchSize)
+		if result.Error != nil {
+			return result.Error
+		}
+		added = result.RowsAffected
+
+		result = tx.Model(&models.Scan{}).
+			Where("uuid = ?", scanID).
+			Update("number_of_domains", gorm.Expr("number_of_domains + ?", added))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(added), nil
+}
+
+// UpsertSubdomains stores the results found for the scan's subdomains,
+// adding any it does not have yet. number_of_domains is not touched.
+func (dao *subdomainDAO) UpsertSubdomains(scanID string, subdomains []models.Subdomain) error {
+	rows := subdomainRows(scanID, subdomains)
+	if len(rows) == 0 {
+		return nil
+	}
+	return dao.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "scan_id"}, {Name: "domain"}},
+		DoUpdates: clause.AssignmentColumns(subdomainResultColumns),
+	}).CreateInBatches(&rows, subdomainBatchSize).Error
+}
+
+// subdomainRows copies subdomains for insertion under scanID, without their
+// ids and with each domain once; the first copy of a domain wins, as it
+// did when results were matched against the JSON list.
+func subdomainRows(scanID string, subdomains []models.Subdomain) []models.Subdomain {
+	seen := make(map[string]bool, len(subdomains))
+	rows := make([]models.Subdomain, 0, len(subdomains))
+	for _, s := range subdomains {
+		if seen[s.Domain] {
+			continue
+		}
+		seen[s.Domain] = true
+		s.ID = 0
+		s.ScanID = scanID
+		rows = append(rows, s)
+	}
+	return rows
+}
+
+// ListByScan returns every subdomain of the scan in the order they were
+// found.
+func (dao *subdomainDAO) ListByScan(scanID string) ([]models.Subdomain, error) {
+	var subdomains []models.Subdomain
+	if err := dao.db.Where("scan_id = ?", scanID).Order("id asc").Find(&subdomains).Error; err != nil {
+		return nil, err
+	}
+	return subdomains, nil
+}
+
+// GetSubdomainsPaginated returns one page of the scan's subdomains in the
+// order they were found, only those in status unless it is empty. Pages
+// start at 1.
+func (dao *subdomainDAO) GetSubdomainsPaginated(scanID string, status models.SubdomainStatus, page, limit int) ([]models.Subdomain, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 50
+	}
+
+	query := dao.db.Where("scan_id = ?", scanID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var subdomains []models.Subdomain
+	if err := query.
+		Order("id asc").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&subdomains).Error; err != nil {
+		return nil, err
+	}
+	return subdomains, nil
+}
+
+func (dao *subdomainDAO) CountByScan(scanID string) (int64, error) {
+	var count int64
+	if err := dao.db.Model(&models.Subdomain{}).Where("scan_id = ?", scanID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountByStatus counts the scan's subdomains in each status; statuses
+// without any are left out.
+func (dao *subdomainDAO) CountByStatus(scanID string) (map[models.SubdomainStatus]int64, error) {
+	var rows []struct {
+		Status models.SubdomainStatus
+		Count  int64
+	}
+	if err := dao.db.Model(&models.Subdomain{}).
+		Select("status, COUNT(*) AS count").
+		Where("scan_id = ?", scanID).
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[models.SubdomainStatus]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// StatsByScan counts the scan's subdomains with open ports, vulns and
+// screenshots in one query, and by status in another.
+func (dao *subdomainDAO) StatsByScan(scanID string) (models.SubdomainStats, error) {
+	var stats models.SubdomainStats
+	err := dao.db.Model(&models.Subdomain{}).
+		Select(`COUNT(*) AS total,
+			COALESCE(SUM(CASE WHEN open_ports NOT IN ('', 'null', '[]') THEN 1 ELSE 0 END), 0) AS with_ports,
+			COALESCE(SUM(CASE WHEN vulns NOT IN ('', 'null', '[]') THEN 1 ELSE 0 END), 0) AS with_vulns,
+			COALESCE(SUM(CASE WHEN screenshot <> '' THEN 1 ELSE 0 END), 0) AS with_screenshots`).
+		Where("scan_id = ?", scanID).
+		Scan(&stats).Error
+	if err != nil {
+		return stats, err
+	}
+	stats.ByStatus, err = dao.CountByStatus(scanID)
+	return stats, err
+}
+
+// UpdateStatusFrom moves the scan's subdomains named in domains to status,
+// skipping those not in one of the from statuses. It returns how many
+// moved.
+func (dao *subdomainDAO) UpdateStatusFrom(scanID string, domains []string, status models.SubdomainStatus, from []models.SubdomainStatus) (int64, error) {
+	if len(domains) == 0 || len(from) == 0 {
+		return 0, nil
+	}
+	result := dao.db.Model(&models.Subdomain{}).
+		Where("scan_id = ? AND domain IN ? AND status IN ?", scanID, domains, from).
+		UpdateColumn("status", status)
+	return result.RowsAffected, result.Error
+}
+
+// MarkSeen sets last_seen_at on the scan's subdomains named in domains.
+func (dao *subdomainDAO) MarkSeen(scanID string, domains []string, at time.Time) error {
+	if len(domains) == 0 {
+		return nil
+	}
+	return dao.db.Model(&models.Subdomain{}).
+		Where("scan_id = ? AND domain IN ?", scanID, domains).
+		UpdateColumn("last_seen_at", at.Unix()).Error
+}
+
+// MigrateSubdomainColumn moves subdomains stored as JSON on the scans row,
+// from before they had their own table, into the subdomains table and drops
+// the column. It does nothing once the column is gone.
+func MigrateSubdomainColumn(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Scan{}, "subdomains") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var blobs []struct {
+			UUID       string
+			Subdomains string
+		}
+		if err := tx.Table("scans").Select("uuid", "subdomains").
+			Where("subdomains IS NOT NULL AND subdomains NOT IN ('', 'null', '[]')").
+			Find(&blobs).Error; err != nil {
+			return err
+		}
+
+		for _, blob := range blobs {
+			var subdomains []models.Subdomain
+			if err := json.Unmarshal([]byte(blob.Subdomains), &subdomains); err != nil {
+				return err
+			}
+			rows := subdomainRows(blob.UUID, subdomains)
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&rows, subdomainBatchSize).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Migrator().DropColumn(&models.Scan{}, "subdomains")
+	})
+}
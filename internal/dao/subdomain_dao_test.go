@@ -0,0 +1,220 @@
+package dao
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"pipeliner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestSubdomainDAO_AddSubdomains(t *testing.T) {
+	db, counter := newTestDB(t)
+	scanDao := NewScanDAO(db)
+	subdomainDao := NewSubdomainDAO(db)
+
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Status: "queued", Domain: "example.com"}))
+
+	const batches = 100
+	const batchSize = 100
+
+	counter.count.Store(0)
+	for b := 0; b < batches; b++ {
+		batch := make([]models.Subdomain, 0, batchSize)
+		for i := 0; i < batchSize; i++ {
+			batch = append(batch, models.Subdomain{
+				Domain: fmt.Sprintf("host-%d-%d.example.com", b, i),
+				Status: "discovered",
+			})
+		}
+
+		added, err := subdomainDao.AddSubdomains("scan-1", batch)
+		require.NoError(t, err)
+		assert.Equal(t, batchSize, added)
+	}
+
+	// one insert and one counter update per batch, independent of how many
+	// subdomains the scan already holds
+	assert.LessOrEqual(t, counter.count.Load(), int64(batches*2))
+
+	scan, err := scanDao.GetScanByUUID("scan-1")
+	require.NoError(t, err)
+	assert.Len(t, scan.Subdomains, batches*batchSize)
+	assert.Equal(t, batches*batchSize, scan.NumberOfDomains)
+	assert.Equal(t, "host-0-0.example.com", scan.Subdomains[0].Domain)
+	assert.Equal(t, models.ScanQueued, scan.Status)
+}
+
+func TestSubdomainDAO_AddSubdomainsSkipsKnownDomains(t *testing.T) {
+	db, _ := newTestDB(t)
+	scanDao := NewScanDAO(db)
+	subdomainDao := NewSubdomainDAO(db)
+
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1"}))
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-2"}))
+
+	added, err := subdomainDao.AddSubdomains("scan-1", []models.Subdomain{{Domain: "a.example.com"}, {Domain: "b.example.com"}, {Domain: "a.example.com"}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, added)
+
+	added, err = subdomainDao.AddSubdomains("scan-1", []models.Subdomain{{Domain: "b.example.com", Status: "alive"}, {Domain: "c.example.com"}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, added)
+
+	// the same domain in another scan is a different row
+	added, err = subdomainDao.AddSubdomains("scan-2", []models.Subdomain{{Domain: "a.example.com"}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, added)
+
+	scan, err := scanDao.GetScanByUUID("scan-1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, scan.NumberOfDomains)
+	require.Len(t, scan.Subdomains, 3)
+	// known domains keep their status
+	assert.Equal(t, models.SubdomainDiscovered, scan.Subdomains[1].Status)
+
+	_, err = subdomainDao.AddSubdomains("missing", []models.Subdomain{{Domain: "a.example.com"}})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	count, err := subdomainDao.CountByScan("missing")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestSubdomainDAO_UpsertSubdomains(t *testing.T) {
+	db, _ := newTestDB(t)
+	scanDao := NewScanDAO(db)
+	subdomainDao := NewSubdomainDAO(db)
+
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Subdomains: []models.Subdomain{{Domain: "a.example.com", Status: "discovered"}}}))
+
+	scan, err := scanDao.GetScanByUUID("scan-1")
+	require.NoError(t, err)
+	scan.Subdomains[0].OpenPorts = []string{"443/tcp"}
+	// only UpdateStatusFrom writes the status
+	scan.Subdomains[0].Status = models.SubdomainAlive
+	scan.Subdomains[0].Sensitive = []models.SensitiveFinding{{URL: "https://a.example.com/.env"}}
+	scan.Subdomains = append(scan.Subdomains, models.Subdomain{Domain: "b.example.com", Screenshot: "b.png"})
+	require.NoError(t, subdomainDao.UpsertSubdomains("scan-1", scan.Subdomains))
+
+	subdomains, err := subdomainDao.ListByScan("scan-1")
+	require.NoError(t, err)
+	require.Len(t, subdomains, 2)
+	assert.Equal(t, []string{"443/tcp"}, subdomains[0].OpenPorts)
+	assert.Equal(t, "https://a.example.com/.env", subdomains[0].Sensitive[0].URL)
+	assert.Equal(t, models.SubdomainDiscovered, subdomains[0].Status)
+	assert.Equal(t, "b.png", subdomains[1].Screenshot)
+}
+
+func TestSubdomainDAO_PaginationAndStats(t *testing.T) {
+	db, _ := newTestDB(t)
+	scanDao := NewScanDAO(db)
+	subdomainDao := NewSubdomainDAO(db)
+
+	var subdomains []models.Subdomain
+	for i := 0; i < 7; i++ {
+		s := models.Subdomain{Domain: fmt.Sprintf("host-%d.example.com", i)}
+		if i%2 == 0 {
+			s.OpenPorts = []string{"80/tcp"}
+		}
+		if i == 3 {
+			s.Vulns = []string{"[HIGH] panel - https://host-3.example.com"}
+			s.Screenshot = "host-3.png"
+		}
+		if i%3 == 0 {
+			s.Status = models.SubdomainAlive
+		}
+		subdomains = append(subdomains, s)
+	}
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Subdomains: subdomains}))
+
+	page, err := subdomainDao.GetSubdomainsPaginated("scan-1", "", 3, 3)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "host-6.example.com", page[0].Domain)
+
+	page, err = subdomainDao.GetSubdomainsPaginated("scan-1", models.SubdomainAlive, 2, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "host-6.example.com", page[0].Domain)
+
+	stats, err := subdomainDao.StatsByScan("scan-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.SubdomainStats{
+		Total:           7,
+		WithPorts:       4,
+		WithVulns:       1,
+		WithScreenshots: 1,
+		ByStatus:        map[models.SubdomainStatus]int64{models.SubdomainAlive: 3, models.SubdomainDiscovered: 4},
+	}, stats)
+
+	stats, err = subdomainDao.StatsByScan("missing")
+	require.NoError(t, err)
+	assert.Zero(t, stats.Total)
+	assert.Empty(t, stats.ByStatus)
+
+	require.NoError(t, scanDao.DeleteScan("scan-1"))
+	count, err := subdomainDao.CountByScan("scan-1")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestSubdomainDAO_UpdateStatusFrom(t *testing.T) {
+	db, _ := newTestDB(t)
+	scanDao := NewScanDAO(db)
+	subdomainDao := NewSubdomainDAO(db)
+
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Subdomains: []models.Subdomain{
+		{Domain: "a.example.com", Status: models.SubdomainAlive},
+		{Domain: "b.example.com", Status: models.SubdomainDiscovered},
+		{Domain: "c.example.com", Status: models.SubdomainAlive},
+	}}))
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-2", Subdomains: []models.Subdomain{{Domain: "a.example.com", Status: models.SubdomainAlive}}}))
+
+	moved, err := subdomainDao.UpdateStatusFrom("scan-1", []string{"a.example.com", "b.example.com"}, models.SubdomainUnresponsive, models.SubdomainStatusesInto(models.SubdomainUnresponsive))
+	require.NoError(t, err)
+	// b was never alive
+	assert.Equal(t, int64(1), moved)
+
+	seen := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, subdomainDao.MarkSeen("scan-1", []string{"c.example.com"}, seen))
+
+	subdomains, err := subdomainDao.ListByScan("scan-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.SubdomainUnresponsive, subdomains[0].Status)
+	assert.Equal(t, models.SubdomainDiscovered, subdomains[1].Status)
+	assert.Zero(t, subdomains[0].LastSeenAt)
+	assert.Equal(t, seen.Unix(), subdomains[2].LastSeenAt)
+
+	other, err := subdomainDao.ListByScan("scan-2")
+	require.NoError(t, err)
+	assert.Equal(t, models.SubdomainAlive, other[0].Status)
+
+	_, err = subdomainDao.UpdateStatusFrom("scan-1", []string{"c.example.com"}, "gone", []models.SubdomainStatus{models.SubdomainAlive})
+	assert.Error(t, err, "the check constraint rejects unknown statuses")
+}
+
+func TestMigrateSubdomainColumn(t *testing.T) {
+	db, _ := newTestDB(t)
+
+	// the scans table as it was when subdomains were a JSON column
+	require.NoError(t, db.Exec("ALTER TABLE `scans` ADD `subdomains` text").Error)
+	require.NoError(t, db.Exec("INSERT INTO scans (uuid, status, subdomains) VALUES (?, ?, ?), (?, ?, ?)",
+		"scan-1", "completed", `[{"domain":"a.example.com","open_ports":["443/tcp"]},{"domain":"b.example.com"},{"domain":"a.example.com"}]`,
+		"scan-2", "completed", "null").Error)
+
+	require.NoError(t, MigrateSubdomainColumn(db))
+	assert.False(t, db.Migrator().HasColumn(&models.Scan{}, "subdomains"))
+
+	scan, err := NewScanDAO(db).GetScanByUUID("scan-1")
+	require.NoError(t, err)
+	require.Len(t, scan.Subdomains, 2)
+	assert.Equal(t, "a.example.com", scan.Subdomains[0].Domain)
+	assert.Equal(t, []string{"443/tcp"}, scan.Subdomains[0].OpenPorts)
+
+	// running it again is a no-op
+	require.NoError(t, MigrateSubdomainColumn(db))
+}
@@ -0,0 +1,131 @@
+package dao
+
+import (
+	"errors"
+	"pipeliner/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TeamDAO interface {
+	CreateTeam(team *models.Team) error
+	GetTeamByName(name string) (*models.Team, error)
+	EnsureTeam(name string) (*models.Team, error)
+	ListTeams() ([]models.Team, error)
+	CreateToken(token *models.APIToken) error
+	GetTokenByHash(hash string) (*models.APIToken, error)
+	// CountTokens counts the tokens of every team, revoked ones included.
+	CountTokens() (int64, error)
+	// ListTokens lists the tokens of a team, or of every team for 0.
+	ListTokens(teamID uint) ([]models.APIToken, error)
+	// RevokeToken marks a token revoked; revoking it again keeps the first
+	// time.
+	RevokeToken(id uint, at time.Time) error
+}
+
+type teamDAO struct {
+	db *gorm.DB
+}
+
+func NewTeamDAO(db *gorm.DB) TeamDAO {
+	return &teamDAO{db: db}
+}
+
+func (dao *teamDAO) CreateTeam(team *models.Team) error {
+	return dao.db.Create(team).Error
+}
+
+func (dao *teamDAO) GetTeamByName(name string) (*models.Team, error) {
+	var team models.Team
+	if err := dao.db.Where("name = ?", name).First(&team).Error; err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// EnsureTeam returns the team of that name, creating it if there is none.
+func (dao *teamDAO) EnsureTeam(name string) (*models.Team, error) {
+	return ensureTeam(dao.db, name)
+}
+
+func (dao *teamDAO) ListTeams() ([]models.Team, error) {
+	var teams []models.Team
+	if err := dao.db.Order("name asc").Find(&teams).Error; err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+func (dao *teamDAO) CreateToken(token *models.APIToken) error {
+	return dao.db.Create(token).Error
+}
+
+func (dao *teamDAO) GetTokenByHash(hash string) (*models.APIToken, error) {
+	var token models.APIToken
+	if err := dao.db.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (dao *teamDAO) CountTokens() (int64, error) {
+	var count int64
+	if err := dao.db.Model(&models.APIToken{}).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (dao *teamDAO) ListTokens(teamID uint) ([]models.APIToken, error) {
+	query := dao.db.Order("id asc")
+	if teamID != 0 {
+		query = query.Where("team_id = ?", teamID)
+	}
+	var tokens []models.APIToken
+	if err := query.Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (dao *teamDAO) RevokeToken(id uint, at time.Time) error {
+	var token models.APIToken
+	if err := dao.db.First(&token, id).Error; err != nil {
+		return err
+	}
+	if token.RevokedAt != nil {
+		return nil
+	}
+	return dao.db.Model(&token).UpdateColumn("revoked_at", at).Error
+}
+
+func ensureTeam(db *gorm.DB, name string) (*models.Team, error) {
+	var team models.Team
+	err := db.Where("name = ?", name).First(&team).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		team = models.Team{Name: name}
+		err = db.Create(&team).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// MigrateTeams assigns the scans from before teams existed to the default
+// team, creating it if needed. Run it after AutoMigrate.
+func MigrateTeams(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		const unowned = "team_id IS NULL OR team_id = 0"
+		var count int64
+		if err := tx.Model(&models.Scan{}).Where(unowned).Count(&count).Error; err != nil || count == 0 {
+			return err
+		}
+		team, err := ensureTeam(tx, models.DefaultTeamName)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.Scan{}).Where(unowned).UpdateColumn("team_id", team.ID).Error
+	})
+}
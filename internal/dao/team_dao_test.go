@@ -0,0 +1,75 @@
+package dao
+
+import (
+	"testing"
+
+	"pipeliner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMigrateTeams_AssignsScansToDefaultTeam(t *testing.T) {
+	db, _ := newTestDB(t)
+	require.NoError(t, db.AutoMigrate(&models.Team{}, &models.APIToken{}))
+	teams := NewTeamDAO(db)
+
+	// nothing to assign, no default team
+	require.NoError(t, MigrateTeams(db))
+	all, err := teams.ListTeams()
+	require.NoError(t, err)
+	assert.Empty(t, all)
+
+	other := &models.Team{Name: "red"}
+	require.NoError(t, teams.CreateTeam(other))
+	scanDao := NewScanDAO(db)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "old", Status: models.ScanCompleted}))
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "owned", Status: models.ScanCompleted, TeamID: other.ID}))
+
+	require.NoError(t, MigrateTeams(db))
+	def, err := teams.GetTeamByName(models.DefaultTeamName)
+	require.NoError(t, err)
+	old, err := scanDao.GetScanSummary("old")
+	require.NoError(t, err)
+	assert.Equal(t, def.ID, old.TeamID)
+	owned, err := scanDao.GetScanSummary("owned")
+	require.NoError(t, err)
+	assert.Equal(t, other.ID, owned.TeamID)
+
+	again, err := teams.EnsureTeam(models.DefaultTeamName)
+	require.NoError(t, err)
+	assert.Equal(t, def.ID, again.ID)
+}
+
+func TestScanDAO_ForTeam(t *testing.T) {
+	db, _ := newTestDB(t)
+	scanDao := NewScanDAO(db)
+	for i, id := range []string{"a-1", "b-1"} {
+		require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: id, Domain: "example.com", Status: models.ScanCompleted, TeamID: uint(i + 1), CreatedAt: int64(i)}))
+	}
+	teamA := scanDao.ForTeam(1)
+
+	_, err := teamA.GetScanByUUID("b-1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = teamA.GetScanSummary("b-1")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, teamA.DeleteScan("b-1"), gorm.ErrRecordNotFound)
+
+	scans, total, err := teamA.ListScansFiltered(models.ScanFilter{}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, scans, 1)
+	assert.Equal(t, "a-1", scans[0].UUID)
+
+	scans, _, err = teamA.ListScansAfter(nil, 10)
+	require.NoError(t, err)
+	require.Len(t, scans, 1)
+	assert.Equal(t, "a-1", scans[0].UUID)
+
+	// the unscoped DAO and team 0 still see both
+	scans, _, err = scanDao.ForTeam(0).ListScansAfter(nil, 10)
+	require.NoError(t, err)
+	assert.Len(t, scans, 2)
+	require.NoError(t, scanDao.DeleteScan("b-1"))
+}
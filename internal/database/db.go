@@ -3,6 +3,7 @@ package database
 import (
 	"fmt"
 	"pipeliner/internal/config"
+	"pipeliner/internal/dao"
 	"pipeliner/internal/models"
 
 	"github.com/sirupsen/logrus"
@@ -23,9 +24,18 @@ func InitDB(cfg *config.Config) (*gorm.DB, error) {
 		return nil, fmt.Errorf("connect to database: %w", err)
 	}
 
-	if err := db.AutoMigrate(&models.Scan{}); err != nil {
+	if err := dao.MigrateStatuses(db); err != nil {
+		return nil, fmt.Errorf("migrate statuses: %w", err)
+	}
+	if err := db.AutoMigrate(&models.Scan{}, &models.HookExecution{}, &models.ConfigChange{}, &models.ScanWebhook{}, &models.WebhookDelivery{}, &models.Subdomain{}, &models.Finding{}, &models.Team{}, &models.APIToken{}); err != nil {
 		return nil, fmt.Errorf("auto-migrate database: %w", err)
 	}
+	if err := dao.MigrateSubdomainColumn(db); err != nil {
+		return nil, fmt.Errorf("migrate subdomains: %w", err)
+	}
+	if err := dao.MigrateTeams(db); err != nil {
+		return nil, fmt.Errorf("migrate teams: %w", err)
+	}
 
 	logrus.Info("Database connection established and migrated")
 	return db, nil
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"pipeliner/internal/config"
+	"pipeliner/internal/models"
+	"pipeliner/internal/services"
+	"pipeliner/pkg/logger"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// TokenCookie is the cookie browsers can send the API token in, as they
+// cannot set an Authorization header on page loads.
+const TokenCookie = "pipeliner_token"
+
+const tokenKey = "pipeliner.token"
+
+// APIKeyHeader is the header scripts can send the API token in instead of
+// a bearer token.
+const APIKeyHeader = "X-API-Key"
+
+// TokenAuth is the middleware of an APITokens mode: none with tokens off,
+// RequireTokenOnceCreated in auto mode and RequireToken otherwise.
+func TokenAuth(mode string, teams services.TeamServiceMethods) []gin.HandlerFunc {
+	switch mode {
+	case config.APITokensOff:
+		return nil
+	case config.APITokensAuto:
+		return []gin.HandlerFunc{RequireTokenOnceCreated(teams)}
+	default:
+		return []gin.HandlerFunc{RequireToken(teams)}
+	}
+}
+
+// RequireToken rejects requests without a valid API token, given as a
+// bearer token, in APIKeyHeader or in TokenCookie, with 401. Handlers see
+// the scans of the token's team through ScansFor.
+func RequireToken(teams services.TeamServiceMethods) gin.HandlerFunc {
+	log := logger.NewLogger(logrus.InfoLevel)
+	return func(c *gin.Context) {
+		record, err := teams.Authenticate(requestToken(c))
+		switch {
+		case errors.Is(err, services.ErrTokenRevoked):
+			c.AbortWithStatusJSON(401, gin.H{"error": "The API token has been revoked"})
+			return
+		case errors.Is(err, services.ErrInvalidToken):
+			c.AbortWithStatusJSON(401, gin.H{"error": "A valid API token is required"})
+			return
+		case err != nil:
+			log.Error("Failed to authenticate API token", logger.Fields{"error": err})
+			c.AbortWithStatusJSON(500, gin.H{"error": "Failed to authenticate"})
+			return
+		}
+		c.Set(tokenKey, record)
+		c.Next()
+	}
+}
+
+// RequireTokenOnceCreated is RequireToken once any API token has been
+// created. Until then every request is let through, as with tokens off.
+func RequireTokenOnceCreated(teams services.TeamServiceMethods) gin.HandlerFunc {
+	log := logger.NewLogger(logrus.InfoLevel)
+	require := RequireToken(teams)
+	return func(c *gin.Context) {
+		created, err := teams.HasTokens()
+		if err != nil {
+			log.Error("Failed to look up API tokens", logger.Fields{"error": err})
+			c.AbortWithStatusJSON(500, gin.H{"error": "Failed to authenticate"})
+			return
+		}
+		if created {
+			require(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAdmin rejects requests that RequireToken did not authenticate with
+// an admin token with 403, so the route stays closed while tokens are off or
+// before the first one is created.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token, ok := TokenFrom(c); !ok || !token.Admin {
+			c.AbortWithStatusJSON(403, gin.H{"error": "An admin API token is required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func requestToken(c *gin.Context) string {
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if token := c.GetHeader(APIKeyHeader); token != "" {
+		return strings.TrimSpace(token)
+	}
+	token, _ := c.Cookie(TokenCookie)
+	return strings.TrimSpace(token)
+}
+
+// TokenFrom returns the API token RequireToken authenticated the request
+// with.
+func TokenFrom(c *gin.Context) (*models.APIToken, bool) {
+	value, ok := c.Get(tokenKey)
+	if !ok {
+		return nil, false
+	}
+	token, ok := value.(*models.APIToken)
+	return token, ok
+}
+
+// ScansFor returns scanService as the request's team sees it. Requests
+// without a token, while tokens are not required, see every scan.
+func ScansFor(c *gin.Context, scanService services.ScanServiceMethods) services.ScanServiceMethods {
+	if token, ok := TokenFrom(c); ok {
+		return scanService.ForTeam(token.TeamID, token.Admin)
+	}
+	return scanService
+}
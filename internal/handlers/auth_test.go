@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pipeliner/internal/config"
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+	"pipeliner/internal/services"
+	"pipeliner/pkg/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// teamFixture serves the scan routes of a real service behind RequireToken,
+// with one scan for each of teams a and b.
+type teamFixture struct {
+	router *gin.Engine
+	teams  services.TeamServiceMethods
+	tokens map[string]string
+}
+
+func newTeamFixture(t *testing.T) *teamFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Scan{}, &models.Subdomain{}, &models.Finding{}, &models.HookExecution{},
+		&models.ScanWebhook{}, &models.WebhookDelivery{}, &models.Team{}, &models.APIToken{}))
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	teams := services.NewTeamService(dao.NewTeamDAO(db))
+	scanDao := dao.NewScanDAO(db)
+	fixture := &teamFixture{teams: teams, tokens: make(map[string]string)}
+	for _, name := range []string{"a", "b"} {
+		team, err := teams.CreateTeam(name)
+		require.NoError(t, err)
+		fixture.tokens[name], _, err = teams.CreateToken(name, "ci", false)
+		require.NoError(t, err)
+		require.NoError(t, scanDao.SaveScan(&models.Scan{
+			UUID:       "scan-" + name,
+			ScanType:   "quick",
+			Domain:     name + ".example.com",
+			Status:     models.ScanCompleted,
+			TeamID:     team.ID,
+			Subdomains: []models.Subdomain{{Domain: "www." + name + ".example.com"}},
+		}))
+	}
+	fixture.tokens["admin"], _, err = teams.CreateToken("a", "ops", true)
+	require.NoError(t, err)
+
+	svc := services.NewScanService(scanDao, dao.NewSubdomainDAO(db), services.WithQueue(queue.New(1)), services.WithFindingDAO(dao.NewFindingDAO(db)))
+	h := NewScanHandler(svc)
+	fixture.router = gin.New()
+	fixture.router.GET("/api/health", Health)
+	api := fixture.router.Group("/api", RequireToken(teams))
+	api.GET("/scans", h.ListScans)
+	api.GET("/scans/:id", h.GetScanByUUID)
+	api.GET("/scans/:id/subdomains", h.GetScanSubdomains)
+	api.GET("/scans/:id/findings", h.GetScanFindings)
+	api.GET("/scans/:id/hooks", h.GetScanHooks)
+	api.POST("/scans/:id/cancel", h.CancelScan)
+	api.POST("/scans/:id/rerun", h.RerunScan)
+	api.DELETE("/scans/:id", h.DeleteScan)
+	api.GET("/scans/:id/export", NewExportHandler(services.NewExportService(scanDao, dao.NewSubdomainDAO(db), dao.NewFindingDAO(db))).ExportScan)
+	return fixture
+}
+
+func (f *teamFixture) do(method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestTeamScoping_OtherTeamsScansAreNotFound(t *testing.T) {
+	f := newTeamFixture(t)
+
+	for _, route := range []struct{ method, path string }{
+		{"GET", "/api/scans/scan-b"},
+		{"GET", "/api/scans/scan-b?summary=true"},
+		{"GET", "/api/scans/scan-b/subdomains"},
+		{"GET", "/api/scans/scan-b/findings"},
+		{"GET", "/api/scans/scan-b/hooks"},
+		{"POST", "/api/scans/scan-b/cancel"},
+		{"POST", "/api/scans/scan-b/rerun"},
+		{"DELETE", "/api/scans/scan-b"},
+	} {
+		w := f.do(route.method, route.path, f.tokens["a"])
+		assert.Equal(t, http.StatusNotFound, w.Code, "%s %s", route.method, route.path)
+	}
+
+	// the scan is still there for its own team
+	w := f.do("GET", "/api/scans/scan-b", f.tokens["b"])
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "www.b.example.com")
+}
+
+func TestTeamScoping_ListsOnlyOwnScans(t *testing.T) {
+	f := newTeamFixture(t)
+
+	w := f.do("GET", "/api/scans", f.tokens["a"])
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "scan-a")
+	assert.NotContains(t, w.Body.String(), "scan-b")
+
+	w = f.do("GET", "/api/scans?cursor=", f.tokens["b"])
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "scan-a")
+}
+
+func TestTeamScoping_AdminSeesEveryTeam(t *testing.T) {
+	f := newTeamFixture(t)
+
+	w := f.do("GET", "/api/scans", f.tokens["admin"])
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "scan-a")
+	assert.Contains(t, w.Body.String(), "scan-b")
+
+	assert.Equal(t, http.StatusOK, f.do("GET", "/api/scans/scan-b", f.tokens["admin"]).Code)
+	assert.Equal(t, http.StatusNoContent, f.do("DELETE", "/api/scans/scan-b", f.tokens["admin"]).Code)
+	assert.Equal(t, http.StatusNotFound, f.do("GET", "/api/scans/scan-b", f.tokens["b"]).Code)
+}
+
+func TestRequireToken_RejectsMissingAndUnknownTokens(t *testing.T) {
+	f := newTeamFixture(t)
+
+	assert.Equal(t, http.StatusUnauthorized, f.do("GET", "/api/scans", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, f.do("GET", "/api/scans", "not-a-token").Code)
+
+	req := httptest.NewRequest("GET", "/api/scans/scan-a", nil)
+	req.AddCookie(&http.Cookie{Name: TokenCookie, Value: f.tokens["a"]})
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRequireToken_APIKeyHeaderAndRevokedKeys(t *testing.T) {
+	f := newTeamFixture(t)
+
+	req := httptest.NewRequest("GET", "/api/scans", nil)
+	req.Header.Set(APIKeyHeader, f.tokens["a"])
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "scan-a")
+
+	tokens, err := f.teams.ListTokens("a")
+	require.NoError(t, err)
+	require.NotEmpty(t, tokens)
+	require.NoError(t, f.teams.RevokeToken(tokens[0].ID))
+
+	w = f.do("GET", "/api/scans", f.tokens["a"])
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.JSONEq(t, `{"error":"The API token has been revoked"}`, w.Body.String())
+	// other keys of the team still work
+	assert.Equal(t, http.StatusOK, f.do("GET", "/api/scans", f.tokens["admin"]).Code)
+}
+
+func TestRequireToken_HealthIsOpen(t *testing.T) {
+	f := newTeamFixture(t)
+
+	w := f.do("GET", "/api/health", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+	w = f.do("GET", "/api/scans", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.JSONEq(t, `{"error":"A valid API token is required"}`, w.Body.String())
+}
+
+// newTokenlessTeams is the team service of a new server, without teams or
+// tokens.
+func newTokenlessTeams(t *testing.T) services.TeamServiceMethods {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Team{}, &models.APIToken{}))
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return services.NewTeamService(dao.NewTeamDAO(db))
+}
+
+func TestTokenAuth(t *testing.T) {
+	for value, want := range map[string]int{
+		// a new server is closed unless it is opened on purpose
+		"":      http.StatusUnauthorized,
+		"true":  http.StatusUnauthorized,
+		"auto":  http.StatusOK,
+		"false": http.StatusOK,
+	} {
+		t.Run("REQUIRE_API_TOKENS="+value, func(t *testing.T) {
+			t.Setenv("REQUIRE_API_TOKENS", value)
+			router := gin.New()
+			router.GET("/api/health", Health)
+			router.GET("/api/scans", append(TokenAuth(config.LoadConfig().APITokens, newTokenlessTeams(t)), func(c *gin.Context) { c.Status(http.StatusOK) })...)
+
+			for path, want := range map[string]int{"/api/scans": want, "/api/health": http.StatusOK} {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+				assert.Equal(t, want, w.Code, path)
+			}
+		})
+	}
+}
+
+func TestRequireTokenOnceCreated(t *testing.T) {
+	teams := newTokenlessTeams(t)
+	router := gin.New()
+	router.GET("/api/scans", RequireTokenOnceCreated(teams), func(c *gin.Context) { c.Status(http.StatusOK) })
+	get := func(token string) int {
+		req := httptest.NewRequest("GET", "/api/scans", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// a new server is usable before anyone has a token
+	assert.Equal(t, http.StatusOK, get(""))
+
+	_, err := teams.CreateTeam("a")
+	require.NoError(t, err)
+	token, _, err := teams.CreateToken("a", "ci", false)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, get(""))
+	assert.Equal(t, http.StatusOK, get(token))
+
+	// revoking the last token does not open the server again
+	tokens, err := teams.ListTokens("a")
+	require.NoError(t, err)
+	require.NoError(t, teams.RevokeToken(tokens[0].ID))
+	assert.Equal(t, http.StatusUnauthorized, get(""))
+	assert.Equal(t, http.StatusUnauthorized, get(token))
+}
+
+func TestRequireAdmin(t *testing.T) {
+	f := newTeamFixture(t)
+	f.router.PUT("/api/config/:name", RequireToken(f.teams), RequireAdmin(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	f.router.PUT("/open/config/:name", RequireAdmin(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	assert.Equal(t, http.StatusForbidden, f.do("PUT", "/api/config/quick", f.tokens["a"]).Code)
+	assert.Equal(t, http.StatusOK, f.do("PUT", "/api/config/quick", f.tokens["admin"]).Code)
+	// without token auth there is no admin
+	assert.Equal(t, http.StatusForbidden, f.do("PUT", "/open/config/quick", "").Code)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"pipeliner/internal/services"
 	"pipeliner/pkg/logger"
 
@@ -20,6 +21,98 @@ func NewConfigHandler(configService services.ConfigServiceMethods) *ConfigHandle
 	}
 }
 
+// GetScanModules lists the modules; where they come from is reported in
+// headers so the body stays a plain list.
 func (h *ConfigHandler) GetScanModules(c *gin.Context) {
+	origin := h.configService.ModuleOrigin()
+	c.Header("X-Pipeliner-Config-Source", origin.Source)
+	if origin.Revision != "" {
+		c.Header("X-Pipeliner-Config-Revision", origin.Revision)
+	}
 	c.JSON(200, h.configService.GetScanModules())
 }
+
+type moduleSourceRequest struct {
+	Content  string `json:"content" binding:"required"`
+	Checksum string `json:"checksum"`
+}
+
+func (h *ConfigHandler) GetModuleSource(c *gin.Context) {
+	source, err := h.configService.GetModuleSource(c.Param("name"))
+	if err != nil {
+		if errors.Is(err, services.ErrConfigNotFound) {
+			c.JSON(404, gin.H{"error": "Scan module not found"})
+			return
+		}
+		c.JSON(500, gin.H{"error": "Failed to read scan module"})
+		return
+	}
+	c.JSON(200, source)
+}
+
+func (h *ConfigHandler) ValidateModule(c *gin.Context) {
+	var req moduleSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	issues := h.configService.ValidateModuleSource(c.Param("name"), []byte(req.Content))
+	if len(issues) > 0 {
+		c.JSON(422, gin.H{"valid": false, "errors": issues})
+		return
+	}
+	c.JSON(200, gin.H{"valid": true})
+}
+
+func (h *ConfigHandler) SaveModule(c *gin.Context) {
+	var req moduleSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	change, err := h.configService.SaveModuleSource(c.Param("name"), []byte(req.Content), req.Checksum, ConfigAuthor(c))
+	if err != nil {
+		var validationErr *services.ConfigValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			c.JSON(422, gin.H{"valid": false, "errors": validationErr.Issues})
+		case errors.Is(err, services.ErrConfigNotFound):
+			c.JSON(404, gin.H{"error": "Scan module not found"})
+		case errors.Is(err, services.ErrConfigReadOnly):
+			c.JSON(403, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrConfigChanged):
+			c.JSON(409, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrConfigChecksumMissing):
+			c.JSON(400, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("Failed to save scan module", logger.Fields{"module": c.Param("name"), "error": err})
+			c.JSON(500, gin.H{"error": "Failed to save scan module"})
+		}
+		return
+	}
+	c.JSON(200, change)
+}
+
+func (h *ConfigHandler) GetModuleHistory(c *gin.Context) {
+	changes, err := h.configService.ListModuleChanges(c.Param("name"))
+	if err != nil {
+		if errors.Is(err, services.ErrConfigNotFound) {
+			c.JSON(404, gin.H{"error": "Scan module not found"})
+			return
+		}
+		c.JSON(500, gin.H{"error": "Failed to list module changes"})
+		return
+	}
+	c.JSON(200, changes)
+}
+
+// ConfigAuthor names who made a module change: the X-Pipeliner-User header
+// when a proxy sets it, otherwise the client address.
+func ConfigAuthor(c *gin.Context) string {
+	if user := c.GetHeader("X-Pipeliner-User"); user != "" {
+		return user
+	}
+	return c.ClientIP()
+}
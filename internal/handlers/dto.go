@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"pipeliner/internal/models"
+	"pipeliner/pkg/export"
+)
+
+// The DTOs below are the wire format of the scan API. Handlers convert
+// models into them so a change to a model does not silently change the
+// JSON clients see; the golden files in testdata pin it.
+
+type ScanDTO struct {
+	UUID              string               `json:"uuid"`
+	ScanType          string               `json:"scan_type"`
+	Status            string               `json:"status"`
+	Domain            string               `json:"domain"`
+	NumberOfDomains   int                  `json:"number_of_domains"`
+	Subdomains        []SubdomainDTO       `json:"subdomains"`
+	SeverityCounts    map[string]int       `json:"severity_counts,omitempty"`
+	ScreenshotsPath   string               `json:"screenshots_path,omitempty"`
+	ToolLogs          map[string]string    `json:"tool_logs,omitempty"`
+	SensitivePatterns string               `json:"sensitive_patterns,omitempty"`
+	SensitiveFilters  *SensitiveFiltersDTO `json:"sensitive_filters,omitempty"`
+	ErrorMessage      string               `json:"error_message,omitempty"`
+	FailedTools       []ToolFailureDTO     `json:"failed_tools,omitempty"`
+	HookWarnings      []HookWarningDTO     `json:"hook_warnings,omitempty"`
+	ToolWarnings      []ToolWarningDTO     `json:"tool_warnings,omitempty"`
+	EmptyOutputTools  []string             `json:"empty_output_tools,omitempty"`
+	Tags              []string             `json:"tags,omitempty"`
+	Priority          int                  `json:"priority,omitempty"`
+	ConfigSource      string               `json:"config_source,omitempty"`
+	ConfigRevision    string               `json:"config_revision,omitempty"`
+	ConfigChecksum    string               `json:"config_checksum,omitempty"`
+	TemplatesRef      string               `json:"templates_ref,omitempty"`
+	HTTPHeaders       map[string]string    `json:"http_headers,omitempty"`
+	Proxy             string               `json:"proxy,omitempty"`
+	RerunOf           string               `json:"rerun_of,omitempty"`
+	ReprocessedAt     int64                `json:"reprocessed_at,omitempty"`
+	CreatedAt         int64                `json:"created_at"`
+	UpdatedAt         int64                `json:"updated_at"`
+}
+
+type SubdomainDTO struct {
+	Domain              string                `json:"domain"`
+	Status              string                `json:"status,omitempty"`
+	LastSeenAt          int64                 `json:"last_seen_at,omitempty"`
+	OpenPorts           []string              `json:"open_ports,omitempty"`
+	PotentialFalsePorts []string              `json:"potential_false_ports,omitempty"`
+	Vulns               []string              `json:"vulns,omitempty"`
+	DirFuzzing          []string              `json:"dir_fuzzing,omitempty"`
+	Screenshot          string                `json:"screenshot,omitempty"`
+	Sensitive           []SensitiveFindingDTO `json:"sensitive,omitempty"`
+	Redirects           []RedirectDTO         `json:"redirects,omitempty"`
+}
+
+type RedirectDTO struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	Location string `json:"location"`
+	External bool   `json:"external,omitempty"`
+}
+
+type SensitiveFindingDTO struct {
+	URL              string   `json:"url"`
+	Status           int      `json:"status"`
+	Length           int      `json:"length"`
+	Severity         string   `json:"severity"`
+	OriginalSeverity string   `json:"original_severity,omitempty"`
+	Labels           []string `json:"labels,omitempty"`
+	Description      string   `json:"description"`
+	Category         string   `json:"category"`
+	Pattern          string   `json:"pattern"`
+	Alerted          bool     `json:"alerted"`
+	Suppressed       string   `json:"suppressed,omitempty"`
+}
+
+type SensitiveFiltersDTO struct {
+	MinLength        int  `json:"min_length,omitempty"`
+	ExcludeRedirects bool `json:"exclude_redirects,omitempty"`
+	Soft404          bool `json:"soft_404,omitempty"`
+}
+
+type FindingDTO struct {
+	Host      string `json:"host"`
+	Template  string `json:"template"`
+	Severity  string `json:"severity"`
+	MatchedAt string `json:"matched_at"`
+}
+
+// ScanFindingDTO is a stored nuclei finding, as GET /scans/:id/findings
+// lists it.
+type ScanFindingDTO struct {
+	ID               uint     `json:"id"`
+	Subdomain        string   `json:"subdomain"`
+	TemplateID       string   `json:"template_id"`
+	Name             string   `json:"name"`
+	Severity         string   `json:"severity"`
+	OriginalSeverity string   `json:"original_severity"`
+	Labels           []string `json:"labels,omitempty"`
+	MatchedAt        string   `json:"matched_at"`
+	Description      string   `json:"description,omitempty"`
+	Tags             []string `json:"tags,omitempty"`
+	CVEs             []string `json:"cves,omitempty"`
+	// The scores are 0 when unknown.
+	CVSSScore      float64 `json:"cvss_score"`
+	EPSSScore      float64 `json:"epss_score"`
+	EPSSPercentile float64 `json:"epss_percentile"`
+	Timestamp      int64   `json:"timestamp"`
+}
+
+type ToolFailureDTO struct {
+	ToolName      string `json:"tool_name"`
+	Error         string `json:"error"`
+	TimedOutStage string `json:"timed_out_stage,omitempty"`
+	Diagnostics   string `json:"diagnostics,omitempty"`
+}
+
+type HookWarningDTO struct {
+	HookName string `json:"hook_name"`
+	ToolName string `json:"tool_name"`
+	Error    string `json:"error"`
+}
+
+type ToolWarningDTO struct {
+	ToolName string `json:"tool_name"`
+	Pattern  string `json:"pattern"`
+	Line     string `json:"line"`
+}
+
+type HookExecutionDTO struct {
+	ID         uint   `json:"id"`
+	ScanID     string `json:"scan_id"`
+	HookName   string `json:"hook_name"`
+	Scope      string `json:"scope"`
+	Target     string `json:"target"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	StartedAt  int64  `json:"started_at"`
+	FinishedAt int64  `json:"finished_at"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+type WebhookDeliveryDTO struct {
+	ID           uint   `json:"id"`
+	ScanID       string `json:"scan_id"`
+	WebhookID    uint   `json:"webhook_id"`
+	URL          string `json:"url"`
+	Event        string `json:"event"`
+	Payload      string `json:"payload"`
+	Status       string `json:"status"`
+	Attempts     int    `json:"attempts"`
+	StatusCode   int    `json:"status_code,omitempty"`
+	Error        string `json:"error,omitempty"`
+	RedeliveryOf uint   `json:"redelivery_of,omitempty"`
+	CreatedAt    int64  `json:"created_at"`
+	UpdatedAt    int64  `json:"updated_at"`
+}
+
+// ScanFilterDTO echoes a scan list filter. CreatedAfter is inclusive and
+// CreatedBefore exclusive, both in unix seconds.
+type ScanFilterDTO struct {
+	Status        []string `json:"status,omitempty"`
+	Domain        string   `json:"domain,omitempty"`
+	ScanType      string   `json:"scan_type,omitempty"`
+	CreatedAfter  int64    `json:"created_after,omitempty"`
+	CreatedBefore int64    `json:"created_before,omitempty"`
+}
+
+// QueuedScanDTO is a scan waiting for a slot, as GET /queue/status lists
+// them in the order they will start.
+type QueuedScanDTO struct {
+	ScanID   string `json:"scan_id"`
+	Priority int    `json:"priority"`
+}
+
+func newScanDTO(scan *models.Scan) ScanDTO {
+	dto := ScanDTO{
+		UUID:              scan.UUID,
+		ScanType:          scan.ScanType,
+		Status:            string(scan.Status),
+		Domain:            scan.Domain,
+		NumberOfDomains:   scan.NumberOfDomains,
+		Subdomains:        newSubdomainDTOs(scan.Subdomains),
+		SeverityCounts:    scan.SeverityCounts,
+		ScreenshotsPath:   scan.ScreenshotsPath,
+		ToolLogs:          scan.ToolLogs,
+		SensitivePatterns: scan.SensitivePatterns,
+		ErrorMessage:      scan.ErrorMessage,
+		EmptyOutputTools:  scan.EmptyOutputTools,
+		Tags:              scan.Tags,
+		Priority:          scan.Priority,
+		ConfigSource:      scan.ConfigSource,
+		ConfigRevision:    scan.ConfigRevision,
+		ConfigChecksum:    scan.ConfigChecksum,
+		TemplatesRef:      scan.TemplatesRef,
+		HTTPHeaders:       scan.HTTPHeaders,
+		Proxy:             scan.Proxy,
+		RerunOf:           scan.RerunOf,
+		ReprocessedAt:     scan.ReprocessedAt,
+		CreatedAt:         scan.CreatedAt,
+		UpdatedAt:         scan.UpdatedAt,
+	}
+	if filters := scan.SensitiveFilters; filters != (models.SensitiveFilters{}) {
+		dto.SensitiveFilters = &SensitiveFiltersDTO{
+			MinLength:        filters.MinLength,
+			ExcludeRedirects: filters.ExcludeRedirects,
+			Soft404:          filters.Soft404,
+		}
+	}
+	for _, f := range scan.FailedTools {
+		dto.FailedTools = append(dto.FailedTools, ToolFailureDTO{ToolName: f.ToolName, Error: f.Error, TimedOutStage: f.TimedOutStage, Diagnostics: f.Diagnostics})
+	}
+	for _, w := range scan.HookWarnings {
+		dto.HookWarnings = append(dto.HookWarnings, HookWarningDTO{HookName: w.HookName, ToolName: w.ToolName, Error: w.Error})
+	}
+	for _, w := range scan.ToolWarnings {
+		dto.ToolWarnings = append(dto.ToolWarnings, ToolWarningDTO{ToolName: w.ToolName, Pattern: w.Pattern, Line: w.Line})
+	}
+	return dto
+}
+
+func newScanDTOs(scans []models.Scan) []ScanDTO {
+	dtos := make([]ScanDTO, 0, len(scans))
+	for i := range scans {
+		dtos = append(dtos, newScanDTO(&scans[i]))
+	}
+	return dtos
+}
+
+// newSubdomainDTOs never returns nil, so a scan without results has
+// "subdomains": [] rather than null.
+func newSubdomainDTOs(subdomains []models.Subdomain) []SubdomainDTO {
+	dtos := make([]SubdomainDTO, 0, len(subdomains))
+	for _, s := range subdomains {
+		dtos = append(dtos, SubdomainDTO{
+			Domain:              s.Domain,
+			Status:              string(s.Status),
+			LastSeenAt:          s.LastSeenAt,
+			OpenPorts:           s.OpenPorts,
+			PotentialFalsePorts: s.PotentialFalsePorts,
+			Vulns:               s.Vulns,
+			DirFuzzing:          s.DirFuzzing,
+			Screenshot:          s.Screenshot,
+			Sensitive:           newSensitiveFindingDTOs(s.Sensitive),
+			Redirects:           newRedirectDTOs(s.Redirects),
+		})
+	}
+	return dtos
+}
+
+// newStatusCountsDTO lists every subdomain status with its count.
+func newStatusCountsDTO(counts map[models.SubdomainStatus]int64) map[string]int64 {
+	dto := make(map[string]int64, len(models.SubdomainStatuses))
+	for _, status := range models.SubdomainStatuses {
+		dto[string(status)] = counts[status]
+	}
+	return dto
+}
+
+func newSensitiveFindingDTOs(findings []models.SensitiveFinding) []SensitiveFindingDTO {
+	var dtos []SensitiveFindingDTO
+	for _, f := range findings {
+		dtos = append(dtos, SensitiveFindingDTO{
+			URL:              f.URL,
+			Status:           f.Status,
+			Length:           f.Length,
+			Severity:         f.Severity,
+			OriginalSeverity: f.OriginalSeverity,
+			Labels:           f.Labels,
+			Description:      f.Description,
+			Category:         f.Category,
+			Pattern:          f.Pattern,
+			Alerted:          f.Alerted,
+			Suppressed:       f.Suppressed,
+		})
+	}
+	return dtos
+}
+
+func newRedirectDTOs(redirects []models.Redirect) []RedirectDTO {
+	var dtos []RedirectDTO
+	for _, r := range redirects {
+		dtos = append(dtos, RedirectDTO{URL: r.URL, Status: r.Status, Location: r.Location, External: r.External})
+	}
+	return dtos
+}
+
+func newFindingDTOs(findings []export.Finding) []FindingDTO {
+	dtos := make([]FindingDTO, 0, len(findings))
+	for _, f := range findings {
+		dtos = append(dtos, FindingDTO{Host: f.Host, Template: f.Template, Severity: f.Severity, MatchedAt: f.MatchedAt})
+	}
+	return dtos
+}
+
+func newScanFindingDTOs(findings []models.Finding) []ScanFindingDTO {
+	dtos := make([]ScanFindingDTO, 0, len(findings))
+	for _, f := range findings {
+		dtos = append(dtos, ScanFindingDTO{
+			ID:               f.ID,
+			Subdomain:        f.Subdomain,
+			TemplateID:       f.TemplateID,
+			Name:             f.Name,
+			Severity:         f.Severity,
+			OriginalSeverity: f.OriginalSeverity,
+			Labels:           f.Labels,
+			MatchedAt:        f.MatchedAt,
+			Description:      f.Description,
+			Tags:             f.Tags,
+			CVEs:             f.CVEs,
+			CVSSScore:        f.CVSSScore,
+			EPSSScore:        f.EPSSScore,
+			EPSSPercentile:   f.EPSSPercentile,
+			Timestamp:        f.Timestamp,
+		})
+	}
+	return dtos
+}
+
+func newHookExecutionDTOs(execs []models.HookExecution) []HookExecutionDTO {
+	dtos := make([]HookExecutionDTO, 0, len(execs))
+	for _, e := range execs {
+		dtos = append(dtos, HookExecutionDTO{
+			ID:         e.ID,
+			ScanID:     e.ScanID,
+			HookName:   e.HookName,
+			Scope:      e.Scope,
+			Target:     e.Target,
+			Status:     e.Status,
+			Error:      e.Error,
+			StartedAt:  e.StartedAt,
+			FinishedAt: e.FinishedAt,
+			DurationMs: e.DurationMs,
+		})
+	}
+	return dtos
+}
+
+func newWebhookDeliveryDTO(d *models.WebhookDelivery) WebhookDeliveryDTO {
+	return WebhookDeliveryDTO{
+		ID:           d.ID,
+		ScanID:       d.ScanID,
+		WebhookID:    d.WebhookID,
+		URL:          d.URL,
+		Event:        d.Event,
+		Payload:      d.Payload,
+		Status:       d.Status,
+		Attempts:     d.Attempts,
+		StatusCode:   d.StatusCode,
+		Error:        d.Error,
+		RedeliveryOf: d.RedeliveryOf,
+		CreatedAt:    d.CreatedAt,
+		UpdatedAt:    d.UpdatedAt,
+	}
+}
+
+func newWebhookDeliveryDTOs(deliveries []models.WebhookDelivery) []WebhookDeliveryDTO {
+	dtos := make([]WebhookDeliveryDTO, 0, len(deliveries))
+	for i := range deliveries {
+		dtos = append(dtos, newWebhookDeliveryDTO(&deliveries[i]))
+	}
+	return dtos
+}
+
+func newScanFilterDTO(filter models.ScanFilter) *ScanFilterDTO {
+	dto := &ScanFilterDTO{Domain: filter.Domain, ScanType: filter.ScanType}
+	for _, status := range filter.Statuses {
+		dto.Status = append(dto.Status, string(status))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		dto.CreatedAfter = filter.CreatedAfter.Unix()
+	}
+	if !filter.CreatedBefore.IsZero() {
+		dto.CreatedBefore = filter.CreatedBefore.Unix()
+	}
+	return dto
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"pipeliner/internal/services"
+	"pipeliner/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type ExportHandler struct {
+	exportService services.ExportServiceMethods
+	logger        *logger.Logger
+}
+
+func NewExportHandler(exportService services.ExportServiceMethods) *ExportHandler {
+	return &ExportHandler{
+		exportService: exportService,
+		logger:        logger.NewLogger(logrus.Level(logrus.InfoLevel)),
+	}
+}
+
+// exports is the export service as the request's team sees it.
+func (h *ExportHandler) exports(c *gin.Context) services.ExportServiceMethods {
+	if token, ok := TokenFrom(c); ok {
+		return h.exportService.ForTeam(token.TeamID, token.Admin)
+	}
+	return h.exportService
+}
+
+// ExportScan streams the whole scan as one JSON document or as CSV, one
+// row per subdomain.
+func (h *ExportHandler) ExportScan(c *gin.Context) {
+	scanID := c.Param("id")
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(400, gin.H{"error": "format must be json or csv"})
+		return
+	}
+
+	export, err := h.exports(c).Export(scanID)
+	if err != nil {
+		if errors.Is(err, services.ErrScanNotFound) {
+			c.JSON(404, gin.H{"error": "Scan not found"})
+			return
+		}
+		h.logger.Error("Failed to get scan:", logger.Fields{"error": err})
+		c.JSON(500, gin.H{"error": "Failed to get scan"})
+		return
+	}
+
+	filename := "scan-" + export.Scan.UUID + "." + format
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		err = export.WriteCSV(c.Writer)
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		err = export.WriteJSON(c.Writer)
+	}
+	if err != nil {
+		// the status is sent with the first rows, so the report just ends
+		h.logger.Error("Failed to export scan", logger.Fields{"error": err, "scan_id": scanID})
+	}
+}
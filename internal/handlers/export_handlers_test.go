@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportScan(t *testing.T) {
+	f := newTeamFixture(t)
+
+	w := f.do("GET", "/api/scans/scan-a/export", f.tokens["a"])
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "scan-scan-a.json")
+	var report struct {
+		Scan       map[string]any   `json:"scan"`
+		Subdomains []map[string]any `json:"subdomains"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, "scan-a", report.Scan["uuid"])
+	require.Len(t, report.Subdomains, 1)
+	assert.Equal(t, "www.a.example.com", report.Subdomains[0]["domain"])
+
+	w = f.do("GET", "/api/scans/scan-a/export?format=csv", f.tokens["a"])
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/csv")
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[1], "www.a.example.com,"), lines[1])
+
+	assert.Equal(t, http.StatusBadRequest, f.do("GET", "/api/scans/scan-a/export?format=xml", f.tokens["a"]).Code)
+	// other teams' scans are not found, as everywhere else
+	assert.Equal(t, http.StatusNotFound, f.do("GET", "/api/scans/scan-b/export", f.tokens["a"]).Code)
+	assert.Equal(t, http.StatusOK, f.do("GET", "/api/scans/scan-b/export", f.tokens["admin"]).Code)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// scanFields are the top-level fields ?fields= can select. "counts" is a
+// shorthand for the summary counters.
+var scanFields = map[string][]string{
+	"uuid":               {"uuid"},
+	"scan_type":          {"scan_type"},
+	"status":             {"status"},
+	"domain":             {"domain"},
+	"number_of_domains":  {"number_of_domains"},
+	"subdomains":         {"subdomains"},
+	"severity_counts":    {"severity_counts"},
+	"counts":             {"number_of_domains", "severity_counts"},
+	"screenshots_path":   {"screenshots_path"},
+	"tool_logs":          {"tool_logs"},
+	"sensitive_patterns": {"sensitive_patterns"},
+	"sensitive_filters":  {"sensitive_filters"},
+	"error_message":      {"error_message"},
+	"failed_tools":       {"failed_tools"},
+	"hook_warnings":      {"hook_warnings"},
+	"tool_warnings":      {"tool_warnings"},
+	"empty_output_tools": {"empty_output_tools"},
+	"tags":               {"tags"},
+	"priority":           {"priority"},
+	"config_source":      {"config_source"},
+	"config_revision":    {"config_revision"},
+	"templates_ref":      {"templates_ref"},
+	"http_headers":       {"http_headers"},
+	"proxy":              {"proxy"},
+	"created_at":         {"created_at"},
+	"updated_at":         {"updated_at"},
+}
+
+// parseFields turns a comma separated ?fields= value into the set of JSON
+// keys to return. An empty value selects everything and returns nil.
+func parseFields(value string) (map[string]bool, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		keys, ok := scanFields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		for _, key := range keys {
+			selected[key] = true
+		}
+	}
+	if len(selected) == 0 {
+		return nil, nil
+	}
+	return selected, nil
+}
+
+// selectFields renders dto with only the selected keys; omitempty fields
+// that are empty stay absent.
+func selectFields(dto ScanDTO, selected map[string]bool, withSubdomains bool) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(dto)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	for key := range all {
+		if (selected != nil && !selected[key]) || (key == "subdomains" && !withSubdomains) {
+			delete(all, key)
+		}
+	}
+	return all, nil
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"pipeliner/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Health answers load balancer and uptime checks. It is served without an
+// API token.
+func Health(c *gin.Context) {
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+// HealthWithResources is Health with the resource guard's state, so an
+// operator can see why scans are paused. The server is still healthy while
+// they are.
+func HealthWithResources(guard *services.ResourceGuard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{"status": "ok", "resources": guard.Status()})
+	}
+}
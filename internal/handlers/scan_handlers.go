@@ -2,10 +2,18 @@ package handlers
 
 import (
 	"errors"
+	"pipeliner/internal/batch"
 	"pipeliner/internal/models"
 	"pipeliner/internal/services"
 	"pipeliner/pkg/engine"
+	perrors "pipeliner/pkg/errors"
+	"pipeliner/pkg/export"
+	"pipeliner/pkg/hostlimit"
 	"pipeliner/pkg/logger"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -13,12 +21,29 @@ import (
 )
 
 type ScanHandler struct {
-	scanService services.ScanServiceMethods
-	logger      *logger.Logger
+	scanService   services.ScanServiceMethods
+	resourceGuard *services.ResourceGuard
+	logger        *logger.Logger
+	checkModule   func(module string) error
 }
 
 func NewScanHandler(scanService services.ScanServiceMethods) *ScanHandler {
-	return &ScanHandler{scanService: scanService, logger: logger.NewLogger(logrus.Level(logrus.InfoLevel))}
+	return &ScanHandler{
+		scanService: scanService,
+		logger:      logger.NewLogger(logrus.Level(logrus.InfoLevel)),
+		checkModule: engine.ValidateModule,
+	}
+}
+
+// WithResourceGuard adds guard's state to the queue status.
+func (h *ScanHandler) WithResourceGuard(guard *services.ResourceGuard) *ScanHandler {
+	h.resourceGuard = guard
+	return h
+}
+
+// scans is the scan service as the request's team sees it.
+func (h *ScanHandler) scans(c *gin.Context) services.ScanServiceMethods {
+	return ScansFor(c, h.scanService)
 }
 
 func (h *ScanHandler) StartScan(c *gin.Context) {
@@ -33,19 +58,133 @@ func (h *ScanHandler) StartScan(c *gin.Context) {
 	scanModel.ScanType = ScanRequest.ScanType
 	scanModel.Domain = ScanRequest.Domain
 	scanModel.SensitivePatterns = ScanRequest.SensitivePatterns
+	scanModel.SensitiveFilters = ScanRequest.SensitiveFilters
+	scanModel.Priority = ScanRequest.Priority
+	scanModel.Force = ScanRequest.Force
+	for _, webhook := range ScanRequest.Webhooks {
+		scanModel.Webhooks = append(scanModel.Webhooks, models.ScanWebhook{
+			URL:    webhook.URL,
+			Secret: webhook.Secret,
+			Events: webhook.Events,
+		})
+	}
 	h.logger.Info("Starting scan", logger.Fields{"scanType": scanModel.ScanType, "domain": scanModel.Domain})
-	id, err := h.scanService.StartScan(&scanModel)
+	id, err := h.scans(c).StartScan(&scanModel)
 	if err != nil {
+		if errors.Is(err, perrors.ErrInvalidConfig) {
+			c.JSON(422, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrQueueFull) {
+			c.JSON(429, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrResourcePressure) {
+			c.JSON(507, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrUnknownWebhookEvent) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		var duplicate *services.DuplicateScanError
+		if errors.As(err, &duplicate) {
+			c.JSON(409, gin.H{"error": "A scan of this module and domain is already queued, running or recently finished; send \"force\": true to start it anyway", "duplicate_of": duplicate.ScanIDs})
+			return
+		}
 		h.logger.Error("Failed to start scan:", logger.Fields{"error": err})
 		c.JSON(500, gin.H{"error": "Failed to start scan"})
 		return
 	}
-	c.JSON(200, ScanResponse{ScanID: id})
+	response := ScanResponse{ScanID: id, DuplicateOf: scanModel.DuplicateOf}
+	if len(scanModel.DuplicateOf) > 0 {
+		response.Warning = "A scan of this module and domain is already queued, running or recently finished"
+	}
+	c.JSON(200, response)
+}
+
+// StartBatch creates one scan per target in an uploaded CSV or JSON list. The
+// format comes from ?format=, the uploaded file name, or the Content-Type.
+func (h *ScanHandler) StartBatch(c *gin.Context) {
+	body := c.Request.Body
+	format := c.Query("format")
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Failed to read uploaded file"})
+			return
+		}
+		defer f.Close()
+		body = f
+		if format == "" {
+			format = batch.FormatFromName(file.Filename)
+		}
+	}
+	if format == "" {
+		format = batch.FormatFromContentType(c.ContentType())
+	}
+
+	rows, err := batch.Parse(body, format, h.checkModule)
+	if err != nil {
+		if errors.Is(err, batch.ErrTooManyRows) {
+			c.JSON(413, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := BatchScanResponse{Total: len(rows), Results: make([]BatchScanResult, 0, len(rows))}
+	for _, row := range batch.ByPriority(rows) {
+		result := BatchScanResult{Line: row.Line, Domain: row.Target.Domain, Module: row.Target.Module}
+		if row.Err == nil {
+			scan := &models.Scan{
+				ScanType: row.Target.Module,
+				Domain:   row.Target.Domain,
+				Tags:     row.Target.Tags,
+				Priority: row.Target.Priority,
+			}
+			result.ScanID, row.Err = h.scans(c).StartScan(scan)
+			result.DuplicateOf = scan.DuplicateOf
+		}
+		if row.Err != nil {
+			result.Error = row.Err.Error()
+			response.Failed++
+		} else {
+			response.Created++
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	h.logger.Info("Batch scan request processed", logger.Fields{"total": response.Total, "created": response.Created, "failed": response.Failed})
+	c.JSON(200, response)
 }
 
 func (h *ScanHandler) GetScanByUUID(c *gin.Context) {
 	scanID := c.Param("id")
-	scan, err := h.scanService.GetScanByUUID(scanID)
+
+	fields, err := parseFields(c.Query("fields"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	withSubdomains := fields == nil || fields["subdomains"]
+	if value, ok := c.GetQuery("include_subdomains"); ok {
+		include, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "include_subdomains must be a boolean"})
+			return
+		}
+		withSubdomains = withSubdomains && include
+	}
+
+	// summaries skip the subdomains column entirely
+	getScan := h.scans(c).GetScanByUUID
+	if !withSubdomains {
+		getScan = h.scans(c).GetScanSummary
+	}
+
+	scan, err := getScan(scanID)
 	if err != nil {
 		if errors.Is(err, services.ErrScanNotFound) {
 			h.logger.Warn("Scan not found", logger.Fields{"scan_id": scanID})
@@ -61,7 +200,18 @@ func (h *ScanHandler) GetScanByUUID(c *gin.Context) {
 		c.JSON(404, gin.H{"error": "Scan not found"})
 		return
 	}
-	c.JSON(200, scan)
+	if fields == nil && withSubdomains {
+		c.JSON(200, newScanDTO(scan))
+		return
+	}
+
+	body, err := selectFields(newScanDTO(scan), fields, withSubdomains)
+	if err != nil {
+		h.logger.Error("Failed to render scan fields", logger.Fields{"error": err, "scan_id": scanID})
+		c.JSON(500, gin.H{"error": "Failed to get scan"})
+		return
+	}
+	c.JSON(200, body)
 }
 
 func (h *ScanHandler) ListScans(c *gin.Context) {
@@ -81,7 +231,23 @@ func (h *ScanHandler) ListScans(c *gin.Context) {
 		pagination.Limit = 100
 	}
 
-	scans, total, err := h.scanService.ListScansWithPagination(pagination.Page, pagination.Limit)
+	filter, err := models.ParseScanFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// ?cursor= (empty for the first page) switches to keyset pagination
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		if !filter.IsZero() {
+			c.JSON(400, gin.H{"error": "Filters cannot be combined with a cursor"})
+			return
+		}
+		h.listScansByCursor(c, cursor, pagination.Limit)
+		return
+	}
+
+	scans, total, err := h.scans(c).ListScansFiltered(filter, pagination.Page, pagination.Limit)
 	if err != nil {
 		h.logger.Error("Failed to list scans:", logger.Fields{"error": err})
 		c.JSON(500, gin.H{"error": "Failed to list scans"})
@@ -94,7 +260,7 @@ func (h *ScanHandler) ListScans(c *gin.Context) {
 	}
 
 	response := PaginatedScansResponse{
-		Scans: scans,
+		Scans: newScanDTOs(scans),
 		Pagination: PaginationMeta{
 			Page:       pagination.Page,
 			Limit:      pagination.Limit,
@@ -104,10 +270,35 @@ func (h *ScanHandler) ListScans(c *gin.Context) {
 			HasPrev:    pagination.Page > 1,
 		},
 	}
+	if !filter.IsZero() {
+		response.Filter = newScanFilterDTO(filter)
+	}
 
 	c.JSON(200, response)
 }
 
+func (h *ScanHandler) listScansByCursor(c *gin.Context, cursor string, limit int) {
+	scans, next, err := h.scans(c).ListScansByCursor(cursor, limit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			c.JSON(400, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		h.logger.Error("Failed to list scans:", logger.Fields{"error": err})
+		c.JSON(500, gin.H{"error": "Failed to list scans"})
+		return
+	}
+
+	c.JSON(200, CursorScansResponse{
+		Scans: newScanDTOs(scans),
+		Pagination: CursorPaginationMeta{
+			Limit:      limit,
+			NextCursor: next,
+			HasNext:    next != "",
+		},
+	})
+}
+
 func (h *ScanHandler) DeleteScan(c *gin.Context) {
 	scanID := c.Param("id")
 	if scanID == "" {
@@ -116,7 +307,7 @@ func (h *ScanHandler) DeleteScan(c *gin.Context) {
 		return
 	}
 
-	if err := h.scanService.DeleteScan(scanID); err != nil {
+	if err := h.scans(c).DeleteScan(scanID); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			h.logger.Warn("Scan not found for deletion", logger.Fields{"scan_id": scanID})
 			c.JSON(404, gin.H{"error": "Scan not found"})
@@ -130,15 +321,356 @@ func (h *ScanHandler) DeleteScan(c *gin.Context) {
 	c.Status(204)
 }
 
+func (h *ScanHandler) CancelScan(c *gin.Context) {
+	scanID := c.Param("id")
+
+	if err := h.scans(c).CancelScan(scanID); err != nil {
+		if errors.Is(err, services.ErrScanNotFound) {
+			h.logger.Warn("Scan not found for cancellation", logger.Fields{"scan_id": scanID})
+			c.JSON(404, gin.H{"error": "Scan not found"})
+			return
+		}
+		if errors.Is(err, services.ErrScanNotCancellable) {
+			c.JSON(409, gin.H{"error": "Only queued or running scans can be cancelled"})
+			return
+		}
+		h.logger.Error("Failed to cancel scan", logger.Fields{"error": err, "scan_id": scanID})
+		c.JSON(500, gin.H{"error": "Failed to cancel scan"})
+		return
+	}
+
+	c.JSON(200, gin.H{"scan_id": scanID, "status": models.ScanCancelled})
+}
+
+// RetryFailedTools runs the failed tools of a completed_with_warnings scan
+// again in its directory.
+func (h *ScanHandler) RetryFailedTools(c *gin.Context) {
+	scanID := c.Param("id")
+
+	retried, err := h.scans(c).RetryFailedTools(scanID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrScanNotFound):
+			c.JSON(404, gin.H{"error": "Scan not found"})
+		case errors.Is(err, services.ErrNothingToRetry):
+			c.JSON(409, gin.H{"error": "Only scans that completed with warnings can be retried"})
+		case errors.Is(err, services.ErrScanDirMissing), errors.Is(err, services.ErrModuleUnavailable):
+			c.JSON(409, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrQueueFull):
+			c.JSON(429, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrResourcePressure):
+			c.JSON(507, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("Failed to retry failed tools", logger.Fields{"error": err, "scan_id": scanID})
+			c.JSON(500, gin.H{"error": "Failed to retry failed tools"})
+		}
+		return
+	}
+
+	c.JSON(202, gin.H{"scan_id": scanID, "status": models.ScanQueued, "tools": retried})
+}
+
+// RerunScan starts a new scan with the parameters of a finished one.
+func (h *ScanHandler) RerunScan(c *gin.Context) {
+	scanID := c.Param("id")
+
+	newID, err := h.scans(c).RerunScan(scanID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrScanNotFound):
+			c.JSON(404, gin.H{"error": "Scan not found"})
+		case errors.Is(err, services.ErrScanNotFinished):
+			c.JSON(409, gin.H{"error": "Only finished scans can be re-run"})
+		case errors.Is(err, perrors.ErrInvalidConfig):
+			c.JSON(422, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrQueueFull):
+			c.JSON(429, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrResourcePressure):
+			c.JSON(507, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("Failed to re-run scan", logger.Fields{"error": err, "scan_id": scanID})
+			c.JSON(500, gin.H{"error": "Failed to re-run scan"})
+		}
+		return
+	}
+
+	c.JSON(202, gin.H{"scan_id": newID, "rerun_of": scanID, "status": models.ScanQueued})
+}
+
+// EstimateScan sizes a scan before it is started. It runs the module's
+// estimate tools, so it can take as long as they do.
+func (h *ScanHandler) EstimateScan(c *gin.Context) {
+	var req EstimateScanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	estimate, err := h.scans(c).EstimateScan(c.Request.Context(), req.ScanType, req.Domain)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrConfigNotFound):
+			c.JSON(404, gin.H{"error": err.Error()})
+		case errors.Is(err, perrors.ErrInvalidConfig):
+			c.JSON(422, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("Failed to estimate scan", logger.Fields{"error": err, "scanType": req.ScanType, "domain": req.Domain})
+			c.JSON(500, gin.H{"error": "Failed to estimate scan"})
+		}
+		return
+	}
+
+	c.JSON(200, estimate)
+}
+
+func (h *ScanHandler) PauseScan(c *gin.Context) {
+	scanID := c.Param("id")
+
+	var req PauseScanRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	if err := h.scans(c).PauseScan(scanID, req.Hard); err != nil {
+		if errors.Is(err, services.ErrScanNotFound) {
+			c.JSON(404, gin.H{"error": "Scan not found"})
+			return
+		}
+		if errors.Is(err, services.ErrScanNotRunning) {
+			c.JSON(409, gin.H{"error": "Only running scans can be paused"})
+			return
+		}
+		h.logger.Error("Failed to pause scan", logger.Fields{"error": err, "scan_id": scanID})
+		c.JSON(500, gin.H{"error": "Failed to pause scan"})
+		return
+	}
+
+	c.JSON(200, gin.H{"scan_id": scanID, "status": models.ScanPaused, "hard": req.Hard})
+}
+
+func (h *ScanHandler) ResumeScan(c *gin.Context) {
+	scanID := c.Param("id")
+
+	if err := h.scans(c).ResumeScan(scanID); err != nil {
+		if errors.Is(err, services.ErrScanNotFound) {
+			c.JSON(404, gin.H{"error": "Scan not found"})
+			return
+		}
+		if errors.Is(err, services.ErrScanNotPaused) {
+			c.JSON(409, gin.H{"error": "Only paused scans can be resumed"})
+			return
+		}
+		h.logger.Error("Failed to resume scan", logger.Fields{"error": err, "scan_id": scanID})
+		c.JSON(500, gin.H{"error": "Failed to resume scan"})
+		return
+	}
+
+	// a scan that gave up its queue slot stays paused until it gets one
+	// back, so report the status it actually has
+	status := models.ScanRunning
+	if scan, err := h.scans(c).GetScanByUUID(scanID); err == nil {
+		status = scan.Status
+	}
+	c.JSON(200, gin.H{"scan_id": scanID, "status": status})
+}
+
+func (h *ScanHandler) GetScanHooks(c *gin.Context) {
+	scanID := c.Param("id")
+
+	execs, err := h.scans(c).GetHookExecutions(scanID)
+	if err != nil {
+		if errors.Is(err, services.ErrScanNotFound) {
+			h.logger.Warn("Scan not found", logger.Fields{"scan_id": scanID})
+			c.JSON(404, gin.H{"error": "Scan not found"})
+			return
+		}
+		h.logger.Error("Failed to get hook executions", logger.Fields{"error": err, "scan_id": scanID})
+		c.JSON(500, gin.H{"error": "Failed to get hook executions"})
+		return
+	}
+
+	c.JSON(200, gin.H{"scan_id": scanID, "hooks": newHookExecutionDTOs(execs)})
+}
+
+func (h *ScanHandler) GetScanDAG(c *gin.Context) {
+	scanID := c.Param("id")
+
+	snapshot, err := h.scans(c).GetScanDAG(scanID)
+	if err != nil {
+		if errors.Is(err, services.ErrScanNotFound) {
+			c.JSON(404, gin.H{"error": "Scan not found"})
+			return
+		}
+		if errors.Is(err, services.ErrDAGUnavailable) {
+			c.JSON(404, gin.H{"error": "No tool graph for this scan"})
+			return
+		}
+		h.logger.Error("Failed to get scan graph", logger.Fields{"error": err, "scan_id": scanID})
+		c.JSON(500, gin.H{"error": "Failed to get scan graph"})
+		return
+	}
+
+	c.JSON(200, gin.H{"scan_id": scanID, "dag": snapshot})
+}
+
+// sseHeartbeat is how often an idle event stream gets a keepalive comment,
+// and how often it rechecks the scan status in case it missed an event.
+const sseHeartbeat = 15 * time.Second
+
+// StreamScanEvents streams the scan's status changes, subdomain counts and
+// tool progress as Server-Sent Events, starting with the current status.
+// The stream ends once the scan finishes.
+func (h *ScanHandler) StreamScanEvents(c *gin.Context) {
+	scanID := c.Param("id")
+
+	// subscribe before reading the status, so no change falls in between
+	events, stop := h.scans(c).SubscribeScanEvents(scanID)
+	defer stop()
+
+	scan, err := h.scans(c).GetScanSummary(scanID)
+	if err != nil {
+		if errors.Is(err, services.ErrScanNotFound) {
+			c.JSON(404, gin.H{"error": "Scan not found"})
+			return
+		}
+		h.logger.Error("Failed to get scan", logger.Fields{"error": err, "scan_id": scanID})
+		c.JSON(500, gin.H{"error": "Failed to get scan"})
+		return
+	}
+
+	// SSEvent sets the text/event-stream content type
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	var lastStatus models.ScanStatus
+	// send writes event and reports whether the stream should go on
+	send := func(event services.ScanEvent) bool {
+		if status, ok := event.Data.(services.StatusEvent); ok {
+			if status.Status == lastStatus {
+				return true
+			}
+			lastStatus = status.Status
+		}
+		c.SSEvent(event.Type, event)
+		c.Writer.Flush()
+		return event.Type != services.ScanEventStatus || !lastStatus.IsTerminal()
+	}
+
+	if !send(statusEvent(scan)) {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event := <-events:
+			if !send(event) {
+				return
+			}
+		case <-heartbeat.C:
+			if scan, err := h.scans(c).GetScanSummary(scanID); err == nil && !send(statusEvent(scan)) {
+				return
+			}
+			c.Writer.WriteString(": keepalive\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+func statusEvent(scan *models.Scan) services.ScanEvent {
+	return services.ScanEvent{
+		Type: services.ScanEventStatus,
+		Data: services.StatusEvent{Status: scan.Status, ErrorMessage: scan.ErrorMessage},
+	}
+}
+
+func (h *ScanHandler) ListWebhookDeliveries(c *gin.Context) {
+	scanID := c.Param("id")
+
+	deliveries, err := h.scans(c).ListWebhookDeliveries(scanID)
+	if err != nil {
+		if errors.Is(err, services.ErrScanNotFound) {
+			c.JSON(404, gin.H{"error": "Scan not found"})
+			return
+		}
+		h.logger.Error("Failed to get webhook deliveries", logger.Fields{"error": err, "scan_id": scanID})
+		c.JSON(500, gin.H{"error": "Failed to get webhook deliveries"})
+		return
+	}
+
+	c.JSON(200, gin.H{"scan_id": scanID, "deliveries": newWebhookDeliveryDTOs(deliveries)})
+}
+
+// RedeliverWebhook posts a recorded delivery's payload again as a new
+// delivery, which is returned while it is still pending.
+func (h *ScanHandler) RedeliverWebhook(c *gin.Context) {
+	scanID := c.Param("id")
+	deliveryID, err := strconv.ParseUint(c.Param("delivery"), 10, 0)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid delivery id"})
+		return
+	}
+
+	delivery, err := h.scans(c).RedeliverWebhook(scanID, uint(deliveryID))
+	if err != nil {
+		if errors.Is(err, services.ErrScanNotFound) {
+			c.JSON(404, gin.H{"error": "Scan not found"})
+			return
+		}
+		if errors.Is(err, services.ErrWebhookDeliveryNotFound) {
+			c.JSON(404, gin.H{"error": "Webhook delivery not found"})
+			return
+		}
+		if errors.Is(err, services.ErrWebhookNotFound) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("Failed to redeliver webhook", logger.Fields{"error": err, "scan_id": scanID, "delivery_id": deliveryID})
+		c.JSON(500, gin.H{"error": "Failed to redeliver webhook"})
+		return
+	}
+
+	c.JSON(202, newWebhookDeliveryDTO(delivery))
+}
+
 func (h *ScanHandler) GetQueueStatus(c *gin.Context) {
 	queue := engine.GetGlobalQueue()
 	running, queued, maxConcurrent := queue.GetStatus()
+	hosts := hostlimit.Global()
+
+	// other teams' scans are left out of the list, not of the counts
+	visible := func(string) bool { return true }
+	if token, ok := TokenFrom(c); ok && !token.Admin {
+		scans := h.scans(c)
+		visible = func(id string) bool {
+			_, err := scans.GetScanSummary(id)
+			return err == nil
+		}
+	}
+	queuedScans := make([]QueuedScanDTO, 0, queued)
+	for _, ticket := range queue.Queued() {
+		if !visible(ticket.ID) {
+			continue
+		}
+		queuedScans = append(queuedScans, QueuedScanDTO{ScanID: ticket.ID, Priority: ticket.Priority})
+	}
 
 	c.JSON(200, gin.H{
-		"running":        running,
-		"queued":         queued,
-		"max_concurrent": maxConcurrent,
-		"available":      maxConcurrent - running,
+		"running":                  running,
+		"queued":                   queued,
+		"queued_scans":             queuedScans,
+		"max_concurrent":           maxConcurrent,
+		"available":                maxConcurrent - running,
+		"host_requests_per_second": hosts.PerSecond(),
+		"hosts":                    hosts.Stats(),
+		"resources":                h.resourceGuard.Status(),
 	})
 }
 
@@ -166,7 +698,13 @@ func (h *ScanHandler) GetScanSubdomains(c *gin.Context) {
 		pagination.Limit = 200
 	}
 
-	scan, err := h.scanService.GetScanByUUID(scanID)
+	status := models.SubdomainStatus(c.Query("status"))
+	if status != "" && !status.IsValid() {
+		c.JSON(400, gin.H{"error": "Unknown subdomain status"})
+		return
+	}
+
+	scan, err := h.scans(c).GetScanSummary(scanID)
 	if err != nil {
 		if errors.Is(err, services.ErrScanNotFound) {
 			h.logger.Warn("Scan not found", logger.Fields{"scan_id": scanID})
@@ -178,25 +716,21 @@ func (h *ScanHandler) GetScanSubdomains(c *gin.Context) {
 		return
 	}
 
-	if scan == nil {
-		h.logger.Error("Scan not found", logger.Fields{"scan_id": scanID})
-		c.JSON(404, gin.H{"error": "Scan not found"})
+	subdomains, total, err := h.scans(c).ListSubdomains(scanID, status, pagination.Page, pagination.Limit)
+	if err != nil {
+		h.logger.Error("Failed to list subdomains", logger.Fields{"error": err, "scan_id": scanID})
+		c.JSON(500, gin.H{"error": "Failed to list subdomains"})
 		return
 	}
-
-	// Paginate subdomains
-	totalSubdomains := len(scan.Subdomains)
-	offset := (pagination.Page - 1) * pagination.Limit
-	end := offset + pagination.Limit
-
-	if offset > totalSubdomains {
-		offset = totalSubdomains
-	}
-	if end > totalSubdomains {
-		end = totalSubdomains
+	stats, err := h.scans(c).GetSubdomainStats(scanID)
+	if err != nil {
+		h.logger.Error("Failed to count subdomains", logger.Fields{"error": err, "scan_id": scanID})
+		c.JSON(500, gin.H{"error": "Failed to count subdomains"})
+		return
 	}
+	totalSubdomains := int(total)
 
-	paginatedSubdomains := scan.Subdomains[offset:end]
+	paginatedSubdomains := newSubdomainDTOs(subdomains)
 
 	totalPages := totalSubdomains / pagination.Limit
 	if totalSubdomains%pagination.Limit != 0 {
@@ -207,6 +741,8 @@ func (h *ScanHandler) GetScanSubdomains(c *gin.Context) {
 		"scan_id":    scan.UUID,
 		"domain":     scan.Domain,
 		"subdomains": paginatedSubdomains,
+		// every status, including those without subdomains
+		"status_counts": newStatusCountsDTO(stats.ByStatus),
 		"pagination": PaginationMeta{
 			Page:       pagination.Page,
 			Limit:      pagination.Limit,
@@ -219,3 +755,124 @@ func (h *ScanHandler) GetScanSubdomains(c *gin.Context) {
 
 	c.JSON(200, response)
 }
+
+// GetScanFindings lists a page of the scan's nuclei findings, only those of
+// the ?severity= given (repeated or comma-separated) and ?template= id if
+// set, most likely exploited first with ?sort=epss or most severe with
+// ?sort=cvss.
+func (h *ScanHandler) GetScanFindings(c *gin.Context) {
+	scanID := c.Param("id")
+
+	var pagination PaginationRequest
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		h.logger.Warn("Failed to bind pagination params, using defaults", logger.Fields{"error": err})
+	}
+	if pagination.Page < 1 {
+		pagination.Page = 1
+	}
+	if pagination.Limit < 1 {
+		pagination.Limit = 50
+	}
+	if pagination.Limit > 200 {
+		pagination.Limit = 200
+	}
+
+	filter := models.FindingFilter{TemplateID: c.Query("template"), Sort: c.Query("sort")}
+	if filter.Sort != "" && filter.Sort != models.FindingSortEPSS && filter.Sort != models.FindingSortCVSS {
+		c.JSON(400, gin.H{"error": "sort must be epss or cvss"})
+		return
+	}
+	for _, value := range c.QueryArray("severity") {
+		for _, severity := range strings.Split(value, ",") {
+			severity = strings.ToLower(strings.TrimSpace(severity))
+			if severity == "" {
+				continue
+			}
+			if !slices.Contains(models.FindingSeverities, severity) {
+				c.JSON(400, gin.H{"error": "severity must be one of " + strings.Join(models.FindingSeverities, ", ")})
+				return
+			}
+			filter.Severities = append(filter.Severities, severity)
+		}
+	}
+
+	findings, total, err := h.scans(c).ListFindings(scanID, filter, pagination.Page, pagination.Limit)
+	if err != nil {
+		if errors.Is(err, services.ErrScanNotFound) {
+			c.JSON(404, gin.H{"error": "Scan not found"})
+			return
+		}
+		h.logger.Error("Failed to list findings", logger.Fields{"error": err, "scan_id": scanID})
+		c.JSON(500, gin.H{"error": "Failed to list findings"})
+		return
+	}
+
+	totalPages := int(total) / pagination.Limit
+	if int(total)%pagination.Limit != 0 {
+		totalPages++
+	}
+	c.JSON(200, gin.H{
+		"scan_id":  scanID,
+		"findings": newScanFindingDTOs(findings),
+		"pagination": PaginationMeta{
+			Page:       pagination.Page,
+			Limit:      pagination.Limit,
+			Total:      int(total),
+			TotalPages: totalPages,
+			HasNext:    pagination.Page < totalPages,
+			HasPrev:    pagination.Page > 1,
+		},
+	})
+}
+
+// ExportFindings converts the scan's nuclei findings to ?format=sarif or
+// markdown, or lists them as JSON by default.
+func (h *ScanHandler) ExportFindings(c *gin.Context) {
+	scanID := c.Param("id")
+	format := c.Query("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "sarif" && format != "markdown" {
+		c.JSON(400, gin.H{"error": "format must be json, sarif or markdown"})
+		return
+	}
+
+	scan, err := h.scans(c).GetScanByUUID(scanID)
+	if err != nil {
+		if errors.Is(err, services.ErrScanNotFound) {
+			c.JSON(404, gin.H{"error": "Scan not found"})
+			return
+		}
+		h.logger.Error("Failed to get scan:", logger.Fields{"error": err})
+		c.JSON(500, gin.H{"error": "Failed to get scan"})
+		return
+	}
+
+	findings := []export.Finding{}
+	for _, s := range scan.Subdomains {
+		for _, vuln := range s.Vulns {
+			if f, ok := export.ParseVuln(s.Domain, vuln); ok {
+				findings = append(findings, f)
+			}
+		}
+	}
+
+	filename := "scan-" + scan.UUID
+	switch format {
+	case "sarif":
+		body, err := export.SARIF(findings)
+		if err != nil {
+			h.logger.Error("Failed to render SARIF", logger.Fields{"error": err, "scan_id": scanID})
+			c.JSON(500, gin.H{"error": "Failed to export findings"})
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.sarif"`)
+		c.Data(200, "application/sarif+json", body)
+	case "markdown":
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.md"`)
+		c.Data(200, "text/markdown; charset=utf-8", export.Markdown("Findings for "+scan.Domain, findings))
+	default:
+		c.JSON(200, gin.H{"scan_id": scan.UUID, "findings": newFindingDTOs(findings)})
+	}
+}
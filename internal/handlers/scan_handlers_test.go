@@ -1,15 +1,22 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"pipeliner/internal/config"
 	"pipeliner/internal/models"
 	"pipeliner/internal/services"
+	perrors "pipeliner/pkg/errors"
+	"pipeliner/pkg/queue"
+	"pipeliner/pkg/testutil"
+	"pipeliner/pkg/tools"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -26,16 +33,16 @@ func (m *MockScanService) StartScan(scan *models.Scan) (string, error) {
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockScanService) ListScans() ([]models.Scan, error) {
-	args := m.Called()
+func (m *MockScanService) ListScansByCursor(cursor string, limit int) ([]models.Scan, string, error) {
+	args := m.Called(cursor, limit)
 	if args.Get(0) == nil {
-		return nil, args.Error(1)
+		return nil, "", args.Error(2)
 	}
-	return args.Get(0).([]models.Scan), args.Error(1)
+	return args.Get(0).([]models.Scan), args.String(1), args.Error(2)
 }
 
-func (m *MockScanService) ListScansWithPagination(page, limit int) ([]models.Scan, int64, error) {
-	args := m.Called(page, limit)
+func (m *MockScanService) ListScansFiltered(filter models.ScanFilter, page, limit int) ([]models.Scan, int64, error) {
+	args := m.Called(filter, page, limit)
 	if args.Get(0) == nil {
 		return nil, 0, args.Error(2)
 	}
@@ -50,11 +57,129 @@ func (m *MockScanService) GetScanByUUID(id string) (*models.Scan, error) {
 	return args.Get(0).(*models.Scan), args.Error(1)
 }
 
+func (m *MockScanService) GetScanSummary(id string) (*models.Scan, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Scan), args.Error(1)
+}
+
+func (m *MockScanService) GetScanByDirectory(dir string) (*models.Scan, error) {
+	args := m.Called(dir)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Scan), args.Error(1)
+}
+
+func (m *MockScanService) ListSubdomains(id string, status models.SubdomainStatus, page, limit int) ([]models.Subdomain, int64, error) {
+	args := m.Called(id, status, page, limit)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]models.Subdomain), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockScanService) GetSubdomainStats(id string) (models.SubdomainStats, error) {
+	args := m.Called(id)
+	return args.Get(0).(models.SubdomainStats), args.Error(1)
+}
+
+func (m *MockScanService) ListFindings(id string, filter models.FindingFilter, page, limit int) ([]models.Finding, int64, error) {
+	args := m.Called(id, filter, page, limit)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]models.Finding), args.Get(1).(int64), args.Error(2)
+}
+
 func (m *MockScanService) DeleteScan(id string) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
+func (m *MockScanService) CancelScan(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockScanService) RetryFailedTools(id string) ([]string, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockScanService) RerunScan(id string) (string, error) {
+	args := m.Called(id)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockScanService) EstimateScan(ctx context.Context, scanType, domain string) (*services.ScanEstimate, error) {
+	args := m.Called(scanType, domain)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*services.ScanEstimate), args.Error(1)
+}
+
+func (m *MockScanService) GetHookExecutions(id string) ([]models.HookExecution, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.HookExecution), args.Error(1)
+}
+
+func (m *MockScanService) GetScanDAG(id string) (*tools.DAGSnapshot, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*tools.DAGSnapshot), args.Error(1)
+}
+
+func (m *MockScanService) PauseScan(id string, hard bool) error {
+	args := m.Called(id, hard)
+	return args.Error(0)
+}
+
+func (m *MockScanService) ResumeScan(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockScanService) ListWebhookDeliveries(id string) ([]models.WebhookDelivery, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockScanService) SubscribeScanEvents(id string) (<-chan services.ScanEvent, func()) {
+	args := m.Called(id)
+	return args.Get(0).(<-chan services.ScanEvent), args.Get(1).(func())
+}
+
+// ForTeam returns the mock itself; scoping is tested against the real
+// service in auth_test.go.
+func (m *MockScanService) ForTeam(teamID uint, admin bool) services.ScanServiceMethods {
+	return m
+}
+
+func (m *MockScanService) Close() {}
+
+func (m *MockScanService) RedeliverWebhook(id string, deliveryID uint) (*models.WebhookDelivery, error) {
+	args := m.Called(id, deliveryID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookDelivery), args.Error(1)
+}
+
 func TestStartScan(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -81,6 +206,59 @@ func TestStartScan(t *testing.T) {
 				m.AssertNumberOfCalls(t, "StartScan", 1)
 			},
 		},
+		{
+			name:        "Priority",
+			requestBody: `{"scan_type":"subdomain_alive","domain":"example.com","priority":10}`,
+			setupMock: func(m *MockScanService) {
+				m.On("StartScan", mock.MatchedBy(func(scan *models.Scan) bool {
+					return scan.Priority == 10
+				})).Return("123e4567-e89b-12d3-a456-426614174000", nil)
+			},
+			expectedStatus: 200,
+			expectedBody:   `{"scan_id":"123e4567-e89b-12d3-a456-426614174000"}`,
+		},
+		{
+			name:        "Duplicate - Warning",
+			requestBody: `{"scan_type":"subdomain_alive","domain":"example.com"}`,
+			setupMock: func(m *MockScanService) {
+				m.On("StartScan", mock.Anything).Run(func(args mock.Arguments) {
+					args.Get(0).(*models.Scan).DuplicateOf = []string{"earlier"}
+				}).Return("123e4567-e89b-12d3-a456-426614174000", nil)
+			},
+			expectedStatus: 200,
+			expectedBody:   `{"scan_id":"123e4567-e89b-12d3-a456-426614174000","warning":"A scan of this module and domain is already queued, running or recently finished","duplicate_of":["earlier"]}`,
+		},
+		{
+			name:        "Duplicate - Rejected",
+			requestBody: `{"scan_type":"subdomain_alive","domain":"example.com"}`,
+			setupMock: func(m *MockScanService) {
+				m.On("StartScan", mock.MatchedBy(func(scan *models.Scan) bool {
+					return !scan.Force
+				})).Return("", &services.DuplicateScanError{ScanIDs: []string{"earlier"}})
+			},
+			expectedStatus: 409,
+			expectedBody:   `{"duplicate_of":["earlier"],"error":"A scan of this module and domain is already queued, running or recently finished; send \"force\": true to start it anyway"}`,
+		},
+		{
+			name:        "Low On Resources",
+			requestBody: `{"scan_type":"subdomain_alive","domain":"example.com"}`,
+			setupMock: func(m *MockScanService) {
+				m.On("StartScan", mock.Anything).Return("", services.ErrResourcePressure)
+			},
+			expectedStatus: 507,
+			expectedBody:   `{"error":"scans are paused until the server has enough free disk space and memory again"}`,
+		},
+		{
+			name:        "Duplicate - Forced",
+			requestBody: `{"scan_type":"subdomain_alive","domain":"example.com","force":true}`,
+			setupMock: func(m *MockScanService) {
+				m.On("StartScan", mock.MatchedBy(func(scan *models.Scan) bool {
+					return scan.Force
+				})).Return("123e4567-e89b-12d3-a456-426614174000", nil)
+			},
+			expectedStatus: 200,
+			expectedBody:   `{"scan_id":"123e4567-e89b-12d3-a456-426614174000"}`,
+		},
 		{
 			name:           "Invalid JSON - Malformed",
 			requestBody:    `{"scan_type":"subdomain_alive","domain":}`,
@@ -115,6 +293,16 @@ func TestStartScan(t *testing.T) {
 			expectedStatus: 500,
 			expectedBody:   `{"error":"Failed to start scan"}`,
 		},
+		{
+			name:        "Invalid Module Config",
+			requestBody: `{"scan_type":"subdomain_alive","domain":"example.com"}`,
+			setupMock: func(m *MockScanService) {
+				m.On("StartScan", mock.AnythingOfType("*models.Scan")).
+					Return("", perrors.NewConfigDecodeError("config/subdomain_alive.yaml", "tools[0].timeout", "time.Duration", errors.New(`time: invalid duration "5 minutes"`)))
+			},
+			expectedStatus: 422,
+			expectedBody:   `{"error":"config/subdomain_alive.yaml: key \"tools[0].timeout\" (expected time.Duration): time: invalid duration \"5 minutes\""}`,
+		},
 		{
 			name:           "Empty Request Body",
 			requestBody:    `{}`,
@@ -332,3 +520,767 @@ func BenchmarkStartScan(b *testing.B) {
 		router.ServeHTTP(w, req)
 	}
 }
+
+func TestCancelScan(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		scanID         string
+		setupMock      func(*MockScanService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Queued Scan Cancelled",
+			scanID: "uuid-123",
+			setupMock: func(m *MockScanService) {
+				m.On("CancelScan", "uuid-123").Return(nil)
+			},
+			expectedStatus: 200,
+			expectedBody:   `{"scan_id":"uuid-123","status":"cancelled"}`,
+		},
+		{
+			name:   "Scan Not Found",
+			scanID: "missing-id",
+			setupMock: func(m *MockScanService) {
+				m.On("CancelScan", "missing-id").Return(services.ErrScanNotFound)
+			},
+			expectedStatus: 404,
+			expectedBody:   `{"error":"Scan not found"}`,
+		},
+		{
+			name:   "Scan Already Running",
+			scanID: "uuid-456",
+			setupMock: func(m *MockScanService) {
+				m.On("CancelScan", "uuid-456").Return(services.ErrScanNotCancellable)
+			},
+			expectedStatus: 409,
+			expectedBody:   `{"error":"Only queued or running scans can be cancelled"}`,
+		},
+		{
+			name:   "Service Error",
+			scanID: "uuid-987",
+			setupMock: func(m *MockScanService) {
+				m.On("CancelScan", "uuid-987").Return(errors.New("db error"))
+			},
+			expectedStatus: 500,
+			expectedBody:   `{"error":"Failed to cancel scan"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockScanService)
+			tt.setupMock(mockService)
+
+			handler := NewScanHandler(mockService)
+			router := gin.New()
+			router.POST("/api/scans/:id/cancel", handler.CancelScan)
+
+			url := fmt.Sprintf("/api/scans/%s/cancel", tt.scanID)
+			req, _ := http.NewRequest("POST", url, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRetryFailedTools(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		setupMock      func(*MockScanService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Failed Tools Queued",
+			setupMock: func(m *MockScanService) {
+				m.On("RetryFailedTools", "uuid-123").Return([]string{"nuclei", "ffuf"}, nil)
+			},
+			expectedStatus: 202,
+			expectedBody:   `{"scan_id":"uuid-123","status":"queued","tools":["nuclei","ffuf"]}`,
+		},
+		{
+			name: "Nothing To Retry",
+			setupMock: func(m *MockScanService) {
+				m.On("RetryFailedTools", "uuid-123").Return(nil, services.ErrNothingToRetry)
+			},
+			expectedStatus: 409,
+			expectedBody:   `{"error":"Only scans that completed with warnings can be retried"}`,
+		},
+		{
+			name: "Scan Directory Gone",
+			setupMock: func(m *MockScanService) {
+				m.On("RetryFailedTools", "uuid-123").Return(nil, fmt.Errorf("%w: /scans/old", services.ErrScanDirMissing))
+			},
+			expectedStatus: 409,
+			expectedBody:   `{"error":"scan directory no longer exists: /scans/old"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockScanService)
+			tt.setupMock(mockService)
+
+			handler := NewScanHandler(mockService)
+			router := gin.New()
+			router.POST("/api/scans/:id/retry-failed", handler.RetryFailedTools)
+
+			req, _ := http.NewRequest("POST", "/api/scans/uuid-123/retry-failed", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRerunScan(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		setupMock      func(*MockScanService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Rerun Queued",
+			setupMock: func(m *MockScanService) {
+				m.On("RerunScan", "uuid-123").Return("uuid-456", nil)
+			},
+			expectedStatus: 202,
+			expectedBody:   `{"scan_id":"uuid-456","rerun_of":"uuid-123","status":"queued"}`,
+		},
+		{
+			name: "Scan Not Found",
+			setupMock: func(m *MockScanService) {
+				m.On("RerunScan", "uuid-123").Return("", services.ErrScanNotFound)
+			},
+			expectedStatus: 404,
+			expectedBody:   `{"error":"Scan not found"}`,
+		},
+		{
+			name: "Scan Still Running",
+			setupMock: func(m *MockScanService) {
+				m.On("RerunScan", "uuid-123").Return("", services.ErrScanNotFinished)
+			},
+			expectedStatus: 409,
+			expectedBody:   `{"error":"Only finished scans can be re-run"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockScanService)
+			tt.setupMock(mockService)
+
+			handler := NewScanHandler(mockService)
+			router := gin.New()
+			router.POST("/api/scans/:id/rerun", handler.RerunScan)
+
+			req, _ := http.NewRequest("POST", "/api/scans/uuid-123/rerun", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestEstimateScan(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		body           string
+		setupMock      func(*MockScanService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Estimated",
+			body: `{"scan_type":"recon","domain":"example.com"}`,
+			setupMock: func(m *MockScanService) {
+				m.On("EstimateScan", "recon", "example.com").Return(&services.ScanEstimate{
+					Module:                   "recon",
+					Domain:                   "example.com",
+					Source:                   services.EstimateSourceRun,
+					Subdomains:               12,
+					Tools:                    []services.ToolEstimate{{Name: "httpx", Iterations: 12}},
+					EstimatedDurationSeconds: 360,
+					DurationSamples:          3,
+				}, nil)
+			},
+			expectedStatus: 200,
+			expectedBody:   `{"module":"recon","domain":"example.com","source":"estimate_run","subdomains":12,"tools":[{"name":"httpx","iterations":12}],"estimated_duration_seconds":360,"duration_samples":3}`,
+		},
+		{
+			name:           "Missing Domain",
+			body:           `{"scan_type":"recon"}`,
+			setupMock:      func(m *MockScanService) {},
+			expectedStatus: 400,
+			expectedBody:   `{"error":"Invalid request payload"}`,
+		},
+		{
+			name: "Unknown Module",
+			body: `{"scan_type":"nope","domain":"example.com"}`,
+			setupMock: func(m *MockScanService) {
+				m.On("EstimateScan", "nope", "example.com").Return(nil, fmt.Errorf("%w: nope", services.ErrConfigNotFound))
+			},
+			expectedStatus: 404,
+			expectedBody:   `{"error":"scan module not found: nope"}`,
+		},
+		{
+			name: "Invalid Module",
+			body: `{"scan_type":"recon","domain":"example.com"}`,
+			setupMock: func(m *MockScanService) {
+				m.On("EstimateScan", "recon", "example.com").Return(nil, fmt.Errorf("%w: no tools", perrors.ErrInvalidConfig))
+			},
+			expectedStatus: 422,
+			expectedBody:   `{"error":"invalid configuration: no tools"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockScanService)
+			tt.setupMock(mockService)
+
+			handler := NewScanHandler(mockService)
+			router := gin.New()
+			router.POST("/api/scans/estimate", handler.EstimateScan)
+
+			req, _ := http.NewRequest("POST", "/api/scans/estimate", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetScanHooks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		scanID         string
+		setupMock      func(*MockScanService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Hooks Recorded",
+			scanID: "uuid-123",
+			setupMock: func(m *MockScanService) {
+				m.On("GetHookExecutions", "uuid-123").Return([]models.HookExecution{
+					{ID: 1, ScanID: "uuid-123", HookName: "nuclei_notifier", Scope: "tool", Target: "nuclei", Status: "warned", Error: "discord unavailable", DurationMs: 12},
+				}, nil)
+			},
+			expectedStatus: 200,
+			expectedBody: `{"scan_id":"uuid-123","hooks":[{"id":1,"scan_id":"uuid-123","hook_name":"nuclei_notifier","scope":"tool","target":"nuclei",` +
+				`"status":"warned","error":"discord unavailable","started_at":0,"finished_at":0,"duration_ms":12}]}`,
+		},
+		{
+			name:   "Scan Not Found",
+			scanID: "missing-id",
+			setupMock: func(m *MockScanService) {
+				m.On("GetHookExecutions", "missing-id").Return(nil, services.ErrScanNotFound)
+			},
+			expectedStatus: 404,
+			expectedBody:   `{"error":"Scan not found"}`,
+		},
+		{
+			name:   "Service Error",
+			scanID: "uuid-987",
+			setupMock: func(m *MockScanService) {
+				m.On("GetHookExecutions", "uuid-987").Return(nil, errors.New("db error"))
+			},
+			expectedStatus: 500,
+			expectedBody:   `{"error":"Failed to get hook executions"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockScanService)
+			tt.setupMock(mockService)
+
+			handler := NewScanHandler(mockService)
+			router := gin.New()
+			router.GET("/api/scans/:id/hooks", handler.GetScanHooks)
+
+			url := fmt.Sprintf("/api/scans/%s/hooks", tt.scanID)
+			req, _ := http.NewRequest("GET", url, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestStartBatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockScanService)
+	mockService.On("StartScan", mock.MatchedBy(func(s *models.Scan) bool { return s.Domain == "b.example.com" })).
+		Return("uuid-b", nil).Once()
+	mockService.On("StartScan", mock.MatchedBy(func(s *models.Scan) bool { return s.Domain == "a.example.com" })).
+		Return("", services.ErrQueueFull).Once()
+
+	handler := NewScanHandler(mockService)
+	handler.checkModule = func(module string) error { return nil }
+	router := gin.New()
+	router.POST("/api/scans/batch", handler.StartBatch)
+	router.POST("/api/scans/:id/cancel", handler.CancelScan)
+
+	body := "domain,module,tags,priority\na.example.com,quick_scan,,\nb.example.com,quick_scan,prod,3\nbad domain,quick_scan,,\n"
+	req, _ := http.NewRequest("POST", "/api/scans/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	var resp BatchScanResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 3, resp.Total)
+	assert.Equal(t, 1, resp.Created)
+	assert.Equal(t, 2, resp.Failed)
+
+	// highest priority is submitted first
+	assert.Equal(t, BatchScanResult{Line: 3, Domain: "b.example.com", Module: "quick_scan", ScanID: "uuid-b"}, resp.Results[0])
+	assert.Equal(t, services.ErrQueueFull.Error(), resp.Results[1].Error)
+	assert.Contains(t, resp.Results[2].Error, "invalid domain")
+	mockService.AssertExpectations(t)
+
+	req, _ = http.NewRequest("POST", "/api/scans/batch", strings.NewReader("domain,module\n"))
+	req.Header.Set("Content-Type", "application/xml")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
+
+func TestGetScanDAG(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	snapshot := &tools.DAGSnapshot{Tools: []tools.DAGNode{
+		{Name: "subfinder", State: tools.DAGCompleted},
+		{Name: "httpx", State: tools.DAGRunning},
+		{Name: "nuclei", State: tools.DAGBlocked, BlockedBy: []string{"httpx"}},
+	}}
+
+	mockService := new(MockScanService)
+	mockService.On("GetScanDAG", "uuid-123").Return(snapshot, nil)
+	mockService.On("GetScanDAG", "uuid-456").Return(nil, services.ErrDAGUnavailable)
+	mockService.On("GetScanDAG", "missing-id").Return(nil, services.ErrScanNotFound)
+
+	handler := NewScanHandler(mockService)
+	router := gin.New()
+	router.GET("/api/scans/:id/dag", handler.GetScanDAG)
+
+	for id, status := range map[string]int{"uuid-123": 200, "uuid-456": 404, "missing-id": 404} {
+		req, _ := http.NewRequest("GET", "/api/scans/"+id+"/dag", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, id)
+	}
+
+	req, _ := http.NewRequest("GET", "/api/scans/uuid-123/dag", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `{"name":"nuclei","state":"blocked","blocked_by":["httpx"]}`)
+}
+
+func TestGetScanSubdomains(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockScanService)
+	mockService.On("GetScanSummary", "uuid-123").Return(&models.Scan{UUID: "uuid-123", Domain: "example.com"}, nil)
+	mockService.On("ListSubdomains", "uuid-123", models.SubdomainStatus(""), 2, 2).Return([]models.Subdomain{{Domain: "c.example.com"}}, int64(3), nil)
+	mockService.On("ListSubdomains", "uuid-123", models.SubdomainAlive, 1, 50).Return([]models.Subdomain{{Domain: "a.example.com", Status: models.SubdomainAlive, LastSeenAt: 1717243200}}, int64(1), nil)
+	mockService.On("GetSubdomainStats", "uuid-123").Return(models.SubdomainStats{Total: 3, ByStatus: map[models.SubdomainStatus]int64{models.SubdomainAlive: 1, models.SubdomainDiscovered: 2}}, nil)
+	mockService.On("GetScanSummary", "missing-id").Return(nil, services.ErrScanNotFound)
+
+	handler := NewScanHandler(mockService)
+	router := gin.New()
+	router.GET("/api/scans/:id/subdomains", handler.GetScanSubdomains)
+
+	req, _ := http.NewRequest("GET", "/api/scans/uuid-123/subdomains?page=2&limit=2", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"scan_id":"uuid-123","domain":"example.com","subdomains":[{"domain":"c.example.com"}],`+
+		`"status_counts":{"discovered":2,"alive":1,"unresponsive":0,"dead":0},`+
+		`"pagination":{"page":2,"limit":2,"total":3,"total_pages":2,"has_next":false,"has_prev":true}}`, w.Body.String())
+
+	req, _ = http.NewRequest("GET", "/api/scans/uuid-123/subdomains?status=alive", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"subdomains":[{"domain":"a.example.com","status":"alive","last_seen_at":1717243200}]`)
+
+	req, _ = http.NewRequest("GET", "/api/scans/uuid-123/subdomains?status=asleep", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/scans/missing-id/subdomains", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetScanFindings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockScanService)
+	mockService.On("ListFindings", "uuid-123", models.FindingFilter{}, 1, 50).Return([]models.Finding{{
+		ID: 1, Subdomain: "api.example.com", TemplateID: "env-file", Name: "Env File", Severity: "critical",
+		OriginalSeverity: "high", Labels: []string{"payments"}, MatchedAt: "https://api.example.com/.env", Tags: []string{"exposure"}, CVEs: []string{"CVE-2021-44228"},
+		CVSSScore: 10, EPSSScore: 0.97, EPSSPercentile: 0.999, Timestamp: 1717243200,
+	}}, int64(1), nil)
+	mockService.On("ListFindings", "uuid-123", models.FindingFilter{Sort: models.FindingSortEPSS}, 1, 50).Return([]models.Finding{}, int64(0), nil)
+	mockService.On("ListFindings", "uuid-123", models.FindingFilter{Severities: []string{"critical", "high", "medium"}, TemplateID: "env-file"}, 2, 10).
+		Return([]models.Finding{}, int64(12), nil)
+	mockService.On("ListFindings", "missing-id", models.FindingFilter{}, 1, 50).Return(nil, int64(0), services.ErrScanNotFound)
+
+	handler := NewScanHandler(mockService)
+	router := gin.New()
+	router.GET("/api/scans/:id/findings", handler.GetScanFindings)
+
+	req, _ := http.NewRequest("GET", "/api/scans/uuid-123/findings", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"scan_id":"uuid-123","findings":[{"id":1,"subdomain":"api.example.com","template_id":"env-file","name":"Env File",`+
+		`"severity":"critical","original_severity":"high","labels":["payments"],"matched_at":"https://api.example.com/.env","tags":["exposure"],"cves":["CVE-2021-44228"],`+
+		`"cvss_score":10,"epss_score":0.97,"epss_percentile":0.999,"timestamp":1717243200}],`+
+		`"pagination":{"page":1,"limit":50,"total":1,"total_pages":1,"has_next":false,"has_prev":false}}`, w.Body.String())
+
+	req, _ = http.NewRequest("GET", "/api/scans/uuid-123/findings?severity=Critical,high&severity=medium&template=env-file&page=2&limit=10", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"pagination":{"page":2,"limit":10,"total":12,"total_pages":2,"has_next":false,"has_prev":true}`)
+
+	req, _ = http.NewRequest("GET", "/api/scans/uuid-123/findings?severity=severe", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/scans/uuid-123/findings?sort=epss", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/scans/uuid-123/findings?sort=severity", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/scans/missing-id/findings", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestPauseAndResumeScan(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockScanService)
+	mockService.On("PauseScan", "uuid-123", true).Return(nil)
+	mockService.On("PauseScan", "uuid-456", false).Return(services.ErrScanNotRunning)
+	mockService.On("PauseScan", "missing-id", false).Return(services.ErrScanNotFound)
+	mockService.On("ResumeScan", "uuid-123").Return(nil)
+	mockService.On("ResumeScan", "uuid-456").Return(services.ErrScanNotPaused)
+	mockService.On("GetScanByUUID", "uuid-123").Return(&models.Scan{UUID: "uuid-123", Status: "running"}, nil)
+
+	handler := NewScanHandler(mockService)
+	router := gin.New()
+	router.POST("/api/scans/:id/pause", handler.PauseScan)
+	router.POST("/api/scans/:id/resume", handler.ResumeScan)
+
+	tests := []struct {
+		path   string
+		body   string
+		status int
+	}{
+		{"/api/scans/uuid-123/pause", `{"hard":true}`, 200},
+		{"/api/scans/uuid-456/pause", "", 409},
+		{"/api/scans/missing-id/pause", "", 404},
+		{"/api/scans/uuid-123/pause", `{"hard":`, 400},
+		{"/api/scans/uuid-123/resume", "", 200},
+		{"/api/scans/uuid-456/resume", "", 409},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tt.status, w.Code, tt.path+" "+tt.body)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockScanService)
+	mockService.On("ListWebhookDeliveries", "uuid-123").Return([]models.WebhookDelivery{
+		{ID: 7, ScanID: "uuid-123", URL: "https://soar.example.com/hook", Event: "completed", Status: "failed", Attempts: 5},
+	}, nil)
+	mockService.On("ListWebhookDeliveries", "missing-id").Return(nil, services.ErrScanNotFound)
+	mockService.On("RedeliverWebhook", "uuid-123", uint(7)).Return(&models.WebhookDelivery{ID: 8, RedeliveryOf: 7, Status: "pending"}, nil)
+	mockService.On("RedeliverWebhook", "uuid-123", uint(9)).Return(nil, services.ErrWebhookDeliveryNotFound)
+
+	handler := NewScanHandler(mockService)
+	router := gin.New()
+	router.GET("/api/scans/:id/webhook-deliveries", handler.ListWebhookDeliveries)
+	router.POST("/api/scans/:id/webhook-deliveries/:delivery/redeliver", handler.RedeliverWebhook)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{"GET", "/api/scans/uuid-123/webhook-deliveries", 200, `"attempts":5`},
+		{"GET", "/api/scans/missing-id/webhook-deliveries", 404, ""},
+		{"POST", "/api/scans/uuid-123/webhook-deliveries/7/redeliver", 202, `"redelivery_of":7`},
+		{"POST", "/api/scans/uuid-123/webhook-deliveries/9/redeliver", 404, ""},
+		{"POST", "/api/scans/uuid-123/webhook-deliveries/abc/redeliver", 400, ""},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tt.status, w.Code, tt.path)
+		assert.Contains(t, w.Body.String(), tt.body, tt.path)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestListScans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	scans := []models.Scan{{UUID: "uuid-2", CreatedAt: 200}, {UUID: "uuid-1", CreatedAt: 100}}
+
+	mockService := new(MockScanService)
+	mockService.On("ListScansFiltered", models.ScanFilter{}, 2, 2).Return(scans, int64(5), nil)
+	mockService.On("ListScansFiltered", models.ScanFilter{
+		Statuses:      []models.ScanStatus{models.ScanRunning, models.ScanFailed},
+		Domain:        "example.com",
+		CreatedAfter:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		CreatedBefore: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+	}, 1, 10).Return(scans[:1], int64(1), nil)
+	mockService.On("ListScansByCursor", "", 2).Return(scans, "next-page", nil)
+	mockService.On("ListScansByCursor", "next-page", 10).Return(scans[1:], "", nil)
+	mockService.On("ListScansByCursor", "garbage", 10).Return(nil, "", services.ErrInvalidCursor)
+
+	handler := NewScanHandler(mockService)
+	router := gin.New()
+	router.GET("/api/scans", handler.ListScans)
+
+	tests := []struct {
+		path   string
+		status int
+		body   []string
+	}{
+		{"/api/scans?page=2&limit=2", 200, []string{`"total":5`, `"total_pages":3`, `"has_next":true`, `"has_prev":true`}},
+		{"/api/scans?cursor=&limit=2", 200, []string{`"next_cursor":"next-page"`, `"has_next":true`, `"uuid":"uuid-2"`}},
+		{"/api/scans?cursor=next-page", 200, []string{`"has_next":false`, `"uuid":"uuid-1"`}},
+		{"/api/scans?cursor=garbage", 400, []string{"Invalid cursor"}},
+		{
+			"/api/scans?status=running,failed&domain=example.com&from=2024-01-01&to=2024-01-31",
+			200,
+			[]string{`"total":1`, `"filter":{"status":["running","failed"],"domain":"example.com","created_after":1704067200,"created_before":1706745600}`},
+		},
+		{"/api/scans?status=done", 400, []string{`status \"done\" is not one of`}},
+		{"/api/scans?from=01/02/2024", 400, []string{"from:"}},
+		{"/api/scans?from=2024-02-01&to=2024-01-01", 400, []string{"from must be before to"}},
+		{"/api/scans?cursor=&domain=example.com", 400, []string{"cannot be combined"}},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tt.status, w.Code, tt.path)
+		for _, body := range tt.body {
+			assert.Contains(t, w.Body.String(), body, tt.path)
+		}
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestExportFindings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	scan := &models.Scan{
+		UUID:   "uuid-123",
+		Domain: "example.com",
+		Subdomains: []models.Subdomain{
+			{Domain: "api.example.com", Vulns: []string{"[HIGH] exposed-panel - https://api.example.com/admin"}},
+		},
+	}
+
+	tests := []struct {
+		format      string
+		status      int
+		contentType string
+		contains    string
+	}{
+		{format: "", status: 200, contentType: "application/json", contains: `"template":"exposed-panel"`},
+		{format: "sarif", status: 200, contentType: "application/sarif+json", contains: `"ruleId": "exposed-panel"`},
+		{format: "markdown", status: 200, contentType: "text/markdown", contains: "## High (1)"},
+		{format: "csv", status: 400, contains: "format must be"},
+	}
+
+	for _, tt := range tests {
+		t.Run("format="+tt.format, func(t *testing.T) {
+			mockService := new(MockScanService)
+			mockService.On("GetScanByUUID", "uuid-123").Return(scan, nil).Maybe()
+
+			handler := NewScanHandler(mockService)
+			router := gin.New()
+			router.GET("/api/scans/:id/findings/export", handler.ExportFindings)
+
+			req, _ := http.NewRequest("GET", "/api/scans/uuid-123/findings/export?format="+tt.format, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Header().Get("Content-Type"), tt.contentType)
+			assert.Contains(t, w.Body.String(), tt.contains)
+		})
+	}
+}
+
+func TestStreamScanEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	events := make(chan services.ScanEvent, 8)
+	events <- services.ScanEvent{Type: services.ScanEventStatus, Data: services.StatusEvent{Status: "running"}}
+	events <- services.ScanEvent{Type: services.ScanEventProgress, Data: services.ProgressEvent{Tool: "httpx", Status: "Started"}}
+	events <- services.ScanEvent{Type: services.ScanEventSubdomains, Data: services.SubdomainsEvent{Added: 2, Total: 5}}
+	events <- services.ScanEvent{Type: services.ScanEventStatus, Data: services.StatusEvent{Status: "completed"}}
+	events <- services.ScanEvent{Type: services.ScanEventProgress, Data: services.ProgressEvent{Tool: "late"}}
+
+	stopped := false
+	mockService := new(MockScanService)
+	mockService.On("SubscribeScanEvents", "uuid-123").Return((<-chan services.ScanEvent)(events), func() { stopped = true })
+	mockService.On("GetScanSummary", "uuid-123").Return(&models.Scan{UUID: "uuid-123", Status: "running"}, nil)
+	mockService.On("SubscribeScanEvents", "missing-id").Return((<-chan services.ScanEvent)(make(chan services.ScanEvent)), func() {})
+	mockService.On("GetScanSummary", "missing-id").Return(nil, services.ErrScanNotFound)
+
+	handler := NewScanHandler(mockService)
+	router := gin.New()
+	router.GET("/api/scans/:id/events", handler.StreamScanEvents)
+
+	req, _ := http.NewRequest("GET", "/api/scans/uuid-123/events", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream"))
+	assert.True(t, stopped)
+
+	var types []string
+	var payloads []map[string]interface{}
+	for _, block := range strings.Split(strings.TrimSpace(w.Body.String()), "\n\n") {
+		var event string
+		var payload map[string]interface{}
+		for _, line := range strings.Split(block, "\n") {
+			if name, ok := strings.CutPrefix(line, "event:"); ok {
+				event = name
+			}
+			if data, ok := strings.CutPrefix(line, "data:"); ok {
+				assert.NoError(t, json.Unmarshal([]byte(data), &payload))
+			}
+		}
+		types = append(types, event)
+		payloads = append(payloads, payload)
+	}
+	// the repeated running status is dropped and nothing follows completed
+	assert.Equal(t, []string{"status", "progress", "subdomains", "status"}, types)
+	assert.Equal(t, "status", payloads[0]["type"])
+	assert.Equal(t, "running", payloads[0]["data"].(map[string]interface{})["status"])
+	assert.Equal(t, float64(5), payloads[2]["data"].(map[string]interface{})["total"])
+	assert.Equal(t, "completed", payloads[3]["data"].(map[string]interface{})["status"])
+
+	req, _ = http.NewRequest("GET", "/api/scans/missing-id/events", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGetQueueStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	q := testutil.ResetGlobalQueueForTests(t, 1)
+
+	release := make(chan struct{})
+	holding := make(chan struct{})
+	go q.ExecuteWithQueue(context.Background(), 0, func() error {
+		close(holding)
+		<-release
+		return nil
+	})
+	<-holding
+	defer close(release)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i, ticket := range []queue.Ticket{{ID: "low"}, {ID: "high", Priority: 5}} {
+		go q.ExecuteWithLease(ctx, ticket, func(*queue.Lease) error { return nil })
+		assert.Eventually(t, func() bool { _, queued, _ := q.GetStatus(); return queued == i+1 }, time.Second, time.Millisecond)
+	}
+
+	router := gin.New()
+	guard := services.NewResourceGuard(config.DefaultResourceGuardConfig(), nil)
+	router.GET("/queue/status", NewScanHandler(new(MockScanService)).WithResourceGuard(guard).GetQueueStatus)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/queue/status", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	var body struct {
+		Running     int                           `json:"running"`
+		Queued      int                           `json:"queued"`
+		QueuedScans []QueuedScanDTO               `json:"queued_scans"`
+		Resources   *services.ResourceGuardStatus `json:"resources"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, &services.ResourceGuardStatus{}, body.Resources)
+	assert.Equal(t, 1, body.Running)
+	assert.Equal(t, 2, body.Queued)
+	assert.Equal(t, []QueuedScanDTO{{ScanID: "high", Priority: 5}, {ScanID: "low"}}, body.QueuedScans)
+}
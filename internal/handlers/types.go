@@ -1,15 +1,64 @@
 package handlers
 
-import "pipeliner/pkg/tools"
+import (
+	"pipeliner/internal/models"
+	"pipeliner/pkg/tools"
+)
 
 type ScanRequest struct {
-	ScanType          string `json:"scan_type" binding:"required"`
-	Domain            string `json:"domain" binding:"required"`
-	SensitivePatterns string `json:"sensitive_patterns"`
+	ScanType          string                  `json:"scan_type" binding:"required"`
+	Domain            string                  `json:"domain" binding:"required"`
+	SensitivePatterns string                  `json:"sensitive_patterns"`
+	SensitiveFilters  models.SensitiveFilters `json:"sensitive_filters"`
+	Webhooks          []WebhookRequest        `json:"webhooks" binding:"dive"`
+	// Priority moves the scan ahead of queued scans of a lower one.
+	Priority int `json:"priority"`
+	// Force starts the scan even if the server rejects duplicate scans.
+	Force bool `json:"force"`
+}
+
+// WebhookRequest registers a URL to be told about the scan. Payloads are
+// signed with Secret when set; an empty Events list means every event.
+type WebhookRequest struct {
+	URL    string   `json:"url" binding:"required,url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
 }
 
 type ScanResponse struct {
 	ScanID string `json:"scan_id" `
+	// Warning says the scan duplicates the scans in DuplicateOf.
+	Warning     string   `json:"warning,omitempty"`
+	DuplicateOf []string `json:"duplicate_of,omitempty"`
+}
+
+type BatchScanResult struct {
+	Line   int    `json:"line"`
+	Domain string `json:"domain"`
+	Module string `json:"module"`
+	ScanID string `json:"scan_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// DuplicateOf lists the scans this one duplicates.
+	DuplicateOf []string `json:"duplicate_of,omitempty"`
+}
+
+// BatchScanResponse lists results in submission order, highest priority first.
+type BatchScanResponse struct {
+	Total   int               `json:"total"`
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Results []BatchScanResult `json:"results"`
+}
+
+// PauseScanRequest is optional; Hard also suspends the tools already running.
+// EstimateScanRequest names the scan to size; nothing is persisted.
+type EstimateScanRequest struct {
+	ScanType string `json:"scan_type" binding:"required"`
+	Domain   string `json:"domain" binding:"required"`
+}
+
+type PauseScanRequest struct {
+	Hard bool `json:"hard"`
 }
 
 type ConfigsRequest struct {
@@ -34,6 +83,21 @@ type PaginationMeta struct {
 }
 
 type PaginatedScansResponse struct {
-	Scans      interface{}    `json:"scans"`
+	Scans      []ScanDTO      `json:"scans"`
 	Pagination PaginationMeta `json:"pagination"`
+	// Filter is the filter the list was narrowed by, if any.
+	Filter *ScanFilterDTO `json:"filter,omitempty"`
+}
+
+// CursorPaginationMeta describes a page requested with ?cursor=. NextCursor
+// is empty on the last page.
+type CursorPaginationMeta struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasNext    bool   `json:"has_next"`
+}
+
+type CursorScansResponse struct {
+	Scans      []ScanDTO            `json:"scans"`
+	Pagination CursorPaginationMeta `json:"pagination"`
 }
@@ -1,8 +1,11 @@
 package web
 
 import (
+	"errors"
+	"pipeliner/internal/handlers"
 	"pipeliner/internal/services"
 	"pipeliner/pkg/logger"
+	"pipeliner/pkg/tools"
 	"pipeliner/templates"
 
 	"github.com/gin-gonic/gin"
@@ -22,7 +25,11 @@ func NewConfigWebHandler(configService services.ConfigServiceMethods) *ConfigWeb
 }
 
 func (h *ConfigWebHandler) ConfigPage(c *gin.Context) {
-	configs := h.configService.GetScanModules()
+	modules := h.configService.GetScanModules()
+	configs := make([]tools.ChainConfig, len(modules))
+	for i, module := range modules {
+		configs[i] = module.ChainConfig
+	}
 
 	h.logger.Info("ConfigPage called", logger.Fields{
 		"config_count": len(configs),
@@ -41,3 +48,68 @@ func (h *ConfigWebHandler) ConfigPage(c *gin.Context) {
 	c.Status(200)
 	templates.CurrentConfig(configs).Render(c, c.Writer)
 }
+
+func (h *ConfigWebHandler) EditPage(c *gin.Context) {
+	name := c.Param("name")
+	source, err := h.configService.GetModuleSource(name)
+	if err != nil {
+		if errors.Is(err, services.ErrConfigNotFound) {
+			c.String(404, "Scan module not found")
+			return
+		}
+		h.logger.Error("Failed to read scan module", logger.Fields{"module": name, "error": err})
+		c.String(500, "Failed to read scan module")
+		return
+	}
+
+	changes, err := h.configService.ListModuleChanges(name)
+	if err != nil {
+		h.logger.Warn("Failed to list module changes", logger.Fields{"module": name, "error": err})
+	}
+
+	form := templates.ConfigEditForm{
+		Name:     name,
+		Content:  source.Content,
+		Checksum: source.Checksum,
+		Editable: source.Editable,
+	}
+	c.Status(200)
+	templates.ConfigEditPage(form, changes).Render(c, c.Writer)
+}
+
+func (h *ConfigWebHandler) Validate(c *gin.Context) {
+	issues := h.configService.ValidateModuleSource(c.Param("name"), []byte(c.PostForm("content")))
+	c.Status(200)
+	templates.ConfigValidationResult(issues, "").Render(c, c.Writer)
+}
+
+// Save responds 200 with a result fragment either way so htmx swaps it in.
+func (h *ConfigWebHandler) Save(c *gin.Context) {
+	name := c.Param("name")
+	change, err := h.configService.SaveModuleSource(name, []byte(c.PostForm("content")), c.PostForm("checksum"), handlers.ConfigAuthor(c))
+	if err != nil {
+		var validationErr *services.ConfigValidationError
+		var message string
+		switch {
+		case errors.As(err, &validationErr):
+			c.Status(200)
+			templates.ConfigValidationResult(validationErr.Issues, "").Render(c, c.Writer)
+			return
+		case errors.Is(err, services.ErrConfigReadOnly):
+			message = "This module is read-only."
+		case errors.Is(err, services.ErrConfigChanged), errors.Is(err, services.ErrConfigChecksumMissing):
+			message = "The module was changed by someone else. Reload the page before saving."
+		case errors.Is(err, services.ErrConfigNotFound):
+			message = "Scan module not found."
+		default:
+			h.logger.Error("Failed to save scan module", logger.Fields{"module": name, "error": err})
+			message = "Failed to save scan module."
+		}
+		c.Status(200)
+		templates.ConfigValidationResult(nil, message).Render(c, c.Writer)
+		return
+	}
+
+	c.Status(200)
+	templates.ConfigSaveResult(change).Render(c, c.Writer)
+}
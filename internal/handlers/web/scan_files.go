@@ -0,0 +1,92 @@
+package web
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"pipeliner/internal/handlers"
+	"pipeliner/internal/services"
+	"pipeliner/pkg/blobstore"
+	"pipeliner/pkg/logger"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ScanFileHandler serves scan artifacts by the URI scan records hold for
+// them, from whichever store keeps them. Keys start with the name of their
+// scan's directory in scansDir.
+type ScanFileHandler struct {
+	store       blobstore.Store
+	scanService services.ScanServiceMethods
+	scansDir    string
+	logger      *logger.Logger
+}
+
+func NewScanFileHandler(store blobstore.Store, scanService services.ScanServiceMethods, scansDir string) *ScanFileHandler {
+	return &ScanFileHandler{
+		store:       store,
+		scanService: scanService,
+		scansDir:    scansDir,
+		logger:      logger.NewLogger(logrus.InfoLevel),
+	}
+}
+
+func (h *ScanFileHandler) ServeFile(c *gin.Context) {
+	key, ok := h.store.Key(strings.TrimPrefix(c.Param("path"), "/"))
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if !h.visible(c, key) {
+		return
+	}
+
+	if file, ok := blobstore.LocalPath(h.store, key); ok {
+		c.File(file)
+		return
+	}
+
+	r, err := h.store.Get(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, blobstore.ErrNotFound) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		h.logger.Error("Failed to read scan file", logger.Fields{"error": err, "key": key})
+		c.Status(http.StatusBadGateway)
+		return
+	}
+	defer r.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.DataFromReader(http.StatusOK, -1, contentType, r, nil)
+}
+
+// visible reports whether the request's team can see the scan the file at
+// key belongs to. Files of other teams' scans get the 404 the scan API
+// answers with. Requests without a token see every file, as they see every
+// scan.
+func (h *ScanFileHandler) visible(c *gin.Context, key string) bool {
+	if _, ok := handlers.TokenFrom(c); !ok {
+		return true
+	}
+	dir, _, _ := strings.Cut(key, "/")
+	_, err := handlers.ScansFor(c, h.scanService).GetScanByDirectory(filepath.Join(h.scansDir, dir))
+	switch {
+	case errors.Is(err, services.ErrScanNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scan not found"})
+		return false
+	case err != nil:
+		h.logger.Error("Failed to get scan of file", logger.Fields{"error": err, "key": key})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get scan"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pipeliner/internal/dao"
+	"pipeliner/internal/handlers"
+	"pipeliner/internal/models"
+	"pipeliner/internal/services"
+	"pipeliner/pkg/blobstore"
+	"pipeliner/pkg/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestScanFileHandler_OnlyServesTheTeamsScans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Scan{}, &models.Subdomain{}, &models.Team{}, &models.APIToken{}))
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	scansDir := t.TempDir()
+	store := blobstore.NewLocal(scansDir)
+	teams := services.NewTeamService(dao.NewTeamDAO(db))
+	scanDao := dao.NewScanDAO(db)
+	tokens := make(map[string]string)
+	for _, name := range []string{"a", "b"} {
+		team, err := teams.CreateTeam(name)
+		require.NoError(t, err)
+		tokens[name], _, err = teams.CreateToken(name, "ci", false)
+		require.NoError(t, err)
+		dir := "full_recon_" + name + ".example.com"
+		require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-" + name, Status: models.ScanCompleted, TeamID: team.ID, ScanDir: filepath.Join(scansDir, dir)}))
+		require.NoError(t, store.Put(context.Background(), dir+"/www.png", strings.NewReader("png")))
+	}
+
+	svc := services.NewScanService(scanDao, dao.NewSubdomainDAO(db), services.WithQueue(queue.New(1)))
+	router := gin.New()
+	router.GET("/scan-files/*path", handlers.RequireToken(teams), NewScanFileHandler(store, svc, scansDir).ServeFile)
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/scan-files/full_recon_a.example.com/www.png", tokens["a"])
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "png", w.Body.String())
+
+	// the other team's files get the scan API's 404
+	w = get("/scan-files/full_recon_a.example.com/www.png", tokens["b"])
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":"Scan not found"}`, w.Body.String())
+	// as do files of no scan
+	assert.Equal(t, http.StatusNotFound, get("/scan-files/full_recon_c.example.com/www.png", tokens["a"]).Code)
+}
@@ -2,10 +2,15 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"pipeliner/internal/handlers"
+	"pipeliner/internal/i18n"
+	"pipeliner/internal/models"
 	"pipeliner/internal/services"
 	"pipeliner/pkg/logger"
 	"pipeliner/templates"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -25,6 +30,11 @@ func NewScanWebHandler(scanService services.ScanServiceMethods, configService se
 	}
 }
 
+// scans is the scan service as the request's team sees it.
+func (h *ScanWebHandler) scans(c *gin.Context) services.ScanServiceMethods {
+	return handlers.ScansFor(c, h.scanService)
+}
+
 func (h *ScanWebHandler) ScansPage(c *gin.Context) {
 	var pagination struct {
 		Page  int `form:"page"`
@@ -45,7 +55,13 @@ func (h *ScanWebHandler) ScansPage(c *gin.Context) {
 		pagination.Limit = 100
 	}
 
-	scans, total, err := h.scanService.ListScansWithPagination(pagination.Page, pagination.Limit)
+	filter, err := models.ParseScanFilter(c.Request.URL.Query())
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	scans, total, err := h.scans(c).ListScansFiltered(filter, pagination.Page, pagination.Limit)
 	if err != nil {
 		h.logger.Error("Failed to list scans", logger.Fields{"error": err})
 		c.Status(500)
@@ -72,7 +88,7 @@ func (h *ScanWebHandler) ScansPage(c *gin.Context) {
 		"total":      total,
 	})
 
-	if err := templates.GetScans(scans, paginationMeta).Render(c, c.Writer); err != nil {
+	if err := templates.GetScans(scans, paginationMeta, filter).Render(c, c.Writer); err != nil {
 		h.logger.Error("Failed to render scans template", logger.Fields{"error": err})
 		c.Status(500)
 		return
@@ -103,7 +119,7 @@ func (h *ScanWebHandler) ScanDetailPage(c *gin.Context) {
 		return
 	}
 
-	scan, err := h.scanService.GetScanByUUID(scanID)
+	scan, err := h.scans(c).GetScanByUUID(scanID)
 	if err != nil {
 		h.logger.Error("Failed to load scan detail", logger.Fields{"error": err, "scan_id": scanID})
 		c.Status(http.StatusInternalServerError)
@@ -116,14 +132,19 @@ func (h *ScanWebHandler) ScanDetailPage(c *gin.Context) {
 		return
 	}
 
+	hooks, err := h.scans(c).GetHookExecutions(scanID)
+	if err != nil {
+		h.logger.Warn("Failed to load hook executions", logger.Fields{"error": err, "scan_id": scanID})
+	}
+
 	if c.GetHeader("HX-Request") != "" {
-		if err := templates.ScanDetailContent(scan).Render(c, c.Writer); err != nil {
+		if err := templates.ScanDetailContent(scan, hooks).Render(c, c.Writer); err != nil {
 			h.logger.Error("Failed to render scan detail partial", logger.Fields{"error": err, "scan_id": scanID})
 			c.Status(http.StatusInternalServerError)
 			return
 		}
 	} else {
-		if err := templates.ScanDetailPage(scan).Render(c, c.Writer); err != nil {
+		if err := templates.ScanDetailPage(scan, hooks).Render(c, c.Writer); err != nil {
 			h.logger.Error("Failed to render scan detail page", logger.Fields{"error": err, "scan_id": scanID})
 			c.Status(http.StatusInternalServerError)
 			return
@@ -133,6 +154,30 @@ func (h *ScanWebHandler) ScanDetailPage(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
+// RerunScan starts a scan with the parameters of a finished one and sends
+// the browser to it.
+func (h *ScanWebHandler) RerunScan(c *gin.Context) {
+	scanID := c.Param("id")
+	newID, err := h.scans(c).RerunScan(scanID)
+	if err != nil {
+		h.logger.Warn("Failed to re-run scan", logger.Fields{"error": err, "scan_id": scanID})
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrScanNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrScanNotFinished):
+			status = http.StatusConflict
+		case errors.Is(err, services.ErrQueueFull):
+			status = http.StatusTooManyRequests
+		}
+		c.String(status, i18n.T(c.Request.Context(), "detail.action.rerun_failed"))
+		return
+	}
+
+	c.Header("HX-Redirect", "/scans/"+newID)
+	c.Status(http.StatusOK)
+}
+
 func (h *ScanWebHandler) ScreenShotsPage(c *gin.Context) {
 	scanID := c.Param("id")
 	if scanID == "" {
@@ -141,7 +186,7 @@ func (h *ScanWebHandler) ScreenShotsPage(c *gin.Context) {
 		return
 	}
 
-	scan, err := h.scanService.GetScanByUUID(scanID)
+	scan, err := h.scans(c).GetScanByUUID(scanID)
 	if err != nil {
 		h.logger.Error("Failed to load scan for screenshots", logger.Fields{"error": err, "scan_id": scanID})
 		c.Status(http.StatusInternalServerError)
@@ -203,33 +248,39 @@ func (h *ScanWebHandler) SubdomainsPage(c *gin.Context) {
 		pagination.Limit = 200
 	}
 
-	scan, err := h.scanService.GetScanByUUID(scanID)
+	status := models.SubdomainStatus(c.Query("status"))
+	if status != "" && !status.IsValid() {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	scan, err := h.scans(c).GetScanSummary(scanID)
 	if err != nil {
+		if errors.Is(err, services.ErrScanNotFound) {
+			h.logger.Warn("Scan not found for subdomains", logger.Fields{"scan_id": scanID})
+			c.Status(http.StatusNotFound)
+			return
+		}
 		h.logger.Error("Failed to load scan for subdomains", logger.Fields{"error": err, "scan_id": scanID})
 		c.Status(http.StatusInternalServerError)
 		return
 	}
 
-	if scan == nil {
-		h.logger.Warn("Scan not found for subdomains", logger.Fields{"scan_id": scanID})
-		c.Status(http.StatusNotFound)
+	paginatedSubdomains, total, err := h.scans(c).ListSubdomains(scanID, status, pagination.Page, pagination.Limit)
+	if err != nil {
+		h.logger.Error("Failed to list subdomains", logger.Fields{"error": err, "scan_id": scanID})
+		c.Status(http.StatusInternalServerError)
 		return
 	}
 
-	// Paginate subdomains
-	totalSubdomains := len(scan.Subdomains)
-	offset := (pagination.Page - 1) * pagination.Limit
-	end := offset + pagination.Limit
-
-	if offset > totalSubdomains {
-		offset = totalSubdomains
-	}
-	if end > totalSubdomains {
-		end = totalSubdomains
+	stats, err := h.scans(c).GetSubdomainStats(scanID)
+	if err != nil {
+		h.logger.Error("Failed to count subdomains", logger.Fields{"error": err, "scan_id": scanID})
+		c.Status(http.StatusInternalServerError)
+		return
 	}
 
-	paginatedSubdomains := scan.Subdomains[offset:end]
-
+	totalSubdomains := int(total)
 	totalPages := totalSubdomains / pagination.Limit
 	if totalSubdomains%pagination.Limit != 0 {
 		totalPages++
@@ -251,7 +302,7 @@ func (h *ScanWebHandler) SubdomainsPage(c *gin.Context) {
 		"page":             pagination.Page,
 	})
 
-	if err := templates.ScanSubdomainsPage(scan, paginatedSubdomains, paginationMeta).Render(c, c.Writer); err != nil {
+	if err := templates.ScanSubdomainsPage(scan, paginatedSubdomains, stats, status, paginationMeta).Render(c, c.Writer); err != nil {
 		h.logger.Error("Failed to render subdomains page", logger.Fields{"error": err, "scan_id": scanID})
 		c.Status(http.StatusInternalServerError)
 		return
@@ -259,3 +310,47 @@ func (h *ScanWebHandler) SubdomainsPage(c *gin.Context) {
 
 	c.Status(http.StatusOK)
 }
+
+// DAGFragment renders the tool graph of a running hybrid scan; it renders
+// nothing when the scan has no graph state.
+func (h *ScanWebHandler) DAGFragment(c *gin.Context) {
+	scanID := c.Param("id")
+	snapshot, err := h.scans(c).GetScanDAG(scanID)
+	if err != nil && !errors.Is(err, services.ErrDAGUnavailable) {
+		h.logger.Warn("Failed to load scan graph", logger.Fields{"error": err, "scan_id": scanID})
+	}
+
+	c.Status(http.StatusOK)
+	if err := templates.ScanDAGFragment(snapshot).Render(c, c.Writer); err != nil {
+		h.logger.Error("Failed to render scan graph", logger.Fields{"error": err, "scan_id": scanID})
+	}
+}
+
+// WebhooksFragment renders the scan's webhook deliveries; it renders nothing
+// when there are none.
+func (h *ScanWebHandler) WebhooksFragment(c *gin.Context) {
+	scanID := c.Param("id")
+	deliveries, err := h.scans(c).ListWebhookDeliveries(scanID)
+	if err != nil {
+		h.logger.Warn("Failed to load webhook deliveries", logger.Fields{"error": err, "scan_id": scanID})
+	}
+
+	c.Status(http.StatusOK)
+	if err := templates.ScanWebhookDeliveries(scanID, deliveries).Render(c, c.Writer); err != nil {
+		h.logger.Error("Failed to render webhook deliveries", logger.Fields{"error": err, "scan_id": scanID})
+	}
+}
+
+// RedeliverWebhook sends a delivery again and re-renders the deliveries.
+func (h *ScanWebHandler) RedeliverWebhook(c *gin.Context) {
+	scanID := c.Param("id")
+	deliveryID, err := strconv.ParseUint(c.Param("delivery"), 10, 0)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	if _, err := h.scans(c).RedeliverWebhook(scanID, uint(deliveryID)); err != nil {
+		h.logger.Warn("Failed to redeliver webhook", logger.Fields{"error": err, "scan_id": scanID, "delivery_id": deliveryID})
+	}
+	h.WebhooksFragment(c)
+}
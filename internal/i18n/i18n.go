@@ -0,0 +1,198 @@
+// Package i18n translates web UI and notification strings using message
+// catalogs embedded per locale.
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"pipeliner/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultLocale is used when nothing else matches, and for keys missing from
+// a catalog.
+const DefaultLocale = "en"
+
+// CookieName holds a locale that overrides Accept-Language.
+const CookieName = "pipeliner_lang"
+
+//go:embed locales/*.json
+var localesFS embed.FS
+
+type localeKey struct{}
+
+var (
+	loadOnce sync.Once
+	catalogs map[string]map[string]string
+	loadErr  error
+
+	devMode atomic.Bool
+	log     = logger.NewLogger(logrus.InfoLevel)
+)
+
+// SetDevMode turns on a warning for every lookup that falls back to English
+// or to the bare key.
+func SetDevMode(on bool) {
+	devMode.Store(on)
+}
+
+func load() (map[string]map[string]string, error) {
+	loadOnce.Do(func() {
+		entries, err := localesFS.ReadDir("locales")
+		if err != nil {
+			loadErr = err
+			return
+		}
+		catalogs = make(map[string]map[string]string, len(entries))
+		for _, entry := range entries {
+			data, err := localesFS.ReadFile(path.Join("locales", entry.Name()))
+			if err != nil {
+				loadErr = err
+				return
+			}
+			messages := make(map[string]string)
+			if err := json.Unmarshal(data, &messages); err != nil {
+				loadErr = fmt.Errorf("parse %s: %w", entry.Name(), err)
+				return
+			}
+			catalogs[strings.TrimSuffix(entry.Name(), ".json")] = messages
+		}
+	})
+	return catalogs, loadErr
+}
+
+// Locales lists the locales that have a catalog.
+func Locales() []string {
+	cats, _ := load()
+	locales := make([]string, 0, len(cats))
+	for locale := range cats {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Lookup translates key into locale, formatting args into the message with
+// fmt.Sprintf. Keys missing from the locale use the English message, and the
+// key itself when English lacks it too.
+func Lookup(locale, key string, args ...any) string {
+	cats, err := load()
+	if err != nil {
+		log.Error("Failed to load message catalogs", logger.Fields{"error": err})
+	}
+
+	msg, ok := cats[locale][key]
+	if !ok {
+		msg, ok = cats[DefaultLocale][key]
+		if devMode.Load() {
+			log.Warn("Missing translation", logger.Fields{"locale": locale, "key": key, "fallback": ok})
+		}
+		if !ok {
+			msg = key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// T translates key into the locale carried by ctx.
+func T(ctx context.Context, key string, args ...any) string {
+	return Lookup(Locale(ctx), key, args...)
+}
+
+// Status is the label for a scan status.
+func Status(ctx context.Context, status string) string {
+	return labelOr(ctx, "status."+status, status)
+}
+
+// Severity is the label for a finding severity (critical, high, ...).
+func Severity(ctx context.Context, severity string) string {
+	return labelOr(ctx, "severity."+strings.ToLower(severity), severity)
+}
+
+// Finding localizes the severity prefix of a recorded finding such as
+// "[CRITICAL] template - https://host/path".
+func Finding(ctx context.Context, vuln string) string {
+	rest, ok := strings.CutPrefix(vuln, "[")
+	if !ok {
+		return vuln
+	}
+	severity, rest, ok := strings.Cut(rest, "]")
+	if !ok {
+		return vuln
+	}
+	return "[" + Severity(ctx, severity) + "]" + rest
+}
+
+// labelOr looks up key and returns fallback instead of the bare key for
+// values no catalog knows about.
+func labelOr(ctx context.Context, key, fallback string) string {
+	if msg := T(ctx, key); msg != key {
+		return msg
+	}
+	return fallback
+}
+
+// WithLocale returns a context that T translates into locale.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// Locale is the locale carried by ctx, or DefaultLocale.
+func Locale(ctx context.Context) string {
+	if ctx != nil {
+		if locale, ok := ctx.Value(localeKey{}).(string); ok {
+			return locale
+		}
+	}
+	return DefaultLocale
+}
+
+// Negotiate picks a supported locale: the cookie value if it has a catalog,
+// otherwise the best Accept-Language match, otherwise DefaultLocale.
+func Negotiate(cookie, acceptLanguage string) string {
+	cats, _ := load()
+	if _, ok := cats[cookie]; ok {
+		return cookie
+	}
+
+	best, bestQ := DefaultLocale, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		// de-CH falls back to de
+		base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if _, ok := cats[base]; ok && q > bestQ {
+			best, bestQ = base, q
+		}
+	}
+	return best
+}
+
+// Middleware stores the request's locale in its context for the templates.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cookie, _ := c.Cookie(CookieName)
+		locale := Negotiate(cookie, c.GetHeader("Accept-Language"))
+		c.Request = c.Request.WithContext(WithLocale(c.Request.Context(), locale))
+		c.Next()
+	}
+}
@@ -0,0 +1,48 @@
+package i18n
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalogsCoverEnglishKeys(t *testing.T) {
+	cats, err := load()
+	require.NoError(t, err)
+	require.Contains(t, cats, DefaultLocale)
+
+	for locale, messages := range cats {
+		for key := range cats[DefaultLocale] {
+			assert.Contains(t, messages, key, "locale %s", locale)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		cookie, accept, want string
+	}{
+		{"", "", "en"},
+		{"", "de-CH,de;q=0.9,en;q=0.8", "de"},
+		{"", "fr-FR,en;q=0.5,de;q=0.7", "de"},
+		{"en", "de", "en"},
+		{"xx", "de", "de"},
+		{"", "fr", "en"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Negotiate(tt.cookie, tt.accept), tt.cookie+"|"+tt.accept)
+	}
+}
+
+func TestLookupFallsBack(t *testing.T) {
+	de := WithLocale(context.Background(), "de")
+
+	assert.Equal(t, "Läuft", Status(de, "running"))
+	assert.Equal(t, "Running", Status(context.Background(), "running"))
+	assert.Equal(t, "weird", Status(de, "weird"))
+	assert.Equal(t, "no.such.key", T(de, "no.such.key"))
+	assert.Equal(t, "3 Tools", T(de, "start.config.tools", 3))
+	assert.Equal(t, "[Kritisch] cve-2024-1 - https://a.example.com", Finding(de, "[CRITICAL] cve-2024-1 - https://a.example.com"))
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+	lastSuccess := r.NewGaugeVec("test_last_success_timestamp_seconds", "Last success.\nPer domain.", "domain")
+	duration := r.NewHistogramVec("test_duration_seconds", "Duration.", []float64{60, 300}, "module", "status")
+
+	lastSuccess.Set(1717243200, `b."example".com`)
+	lastSuccess.Set(1717200000, "a.example.com")
+	duration.Observe(30, "quick_scan", "completed")
+	duration.Observe(120, "quick_scan", "completed")
+	duration.Observe(900, "quick_scan", "completed")
+
+	var out strings.Builder
+	_, err := r.WriteTo(&out)
+	assert.NoError(t, err)
+	assert.Equal(t, `# HELP test_last_success_timestamp_seconds Last success.\nPer domain.
+# TYPE test_last_success_timestamp_seconds gauge
+test_last_success_timestamp_seconds{domain="a.example.com"} 1.7172e+09
+test_last_success_timestamp_seconds{domain="b.\"example\".com"} 1.7172432e+09
+# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{module="quick_scan",status="completed",le="60"} 1
+test_duration_seconds_bucket{module="quick_scan",status="completed",le="300"} 2
+test_duration_seconds_bucket{module="quick_scan",status="completed",le="+Inf"} 3
+test_duration_seconds_sum{module="quick_scan",status="completed"} 1050
+test_duration_seconds_count{module="quick_scan",status="completed"} 3
+`, out.String())
+
+	lastSuccess.Delete("a.example.com")
+	_, ok := lastSuccess.Value("a.example.com")
+	assert.False(t, ok)
+	assert.Equal(t, uint64(3), duration.Count("quick_scan", "completed"))
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	assert.NotContains(t, rec.Body.String(), "a.example.com")
+}
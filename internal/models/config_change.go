@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// ConfigChange records one edit of a scan module made through the web UI.
+type ConfigChange struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	Module           string    `gorm:"index" json:"module"`
+	Author           string    `json:"author"`
+	PreviousChecksum string    `json:"previous_checksum"`
+	NewChecksum      string    `json:"new_checksum"`
+	ChangedAt        time.Time `json:"changed_at"`
+}
+
+// ConfigIssue is one problem found while validating a module.
+type ConfigIssue struct {
+	Key      string `json:"key,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Message  string `json:"message"`
+}
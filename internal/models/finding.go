@@ -0,0 +1,46 @@
+package models
+
+// FindingSeverities are the severities nuclei reports, most severe first.
+var FindingSeverities = []string{"critical", "high", "medium", "low", "info", "unknown"}
+
+// Finding is one nuclei result of a scan. The same template matching at the
+// same place is stored once per scan.
+type Finding struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	ScanID     string `gorm:"type:varchar(36);uniqueIndex:idx_findings_scan_match,priority:1" json:"scan_id"`
+	Subdomain  string `json:"subdomain"`
+	TemplateID string `gorm:"uniqueIndex:idx_findings_scan_match,priority:2;index" json:"template_id"`
+	MatchedAt  string `gorm:"uniqueIndex:idx_findings_scan_match,priority:3" json:"matched_at"`
+	Name       string `json:"name"`
+	// Severity is lower case, e.g. critical or info, after the severity
+	// rules; OriginalSeverity is the template's. Labels are the ones the
+	// rules added.
+	Severity         string   `gorm:"index" json:"severity"`
+	OriginalSeverity string   `json:"original_severity"`
+	Labels           []string `gorm:"serializer:json" json:"labels,omitempty"`
+	Description      string   `gorm:"type:text" json:"description,omitempty"`
+	Tags             []string `gorm:"serializer:json" json:"tags,omitempty"`
+	// CVEs are the template's CVE ids. The scores are the highest the local
+	// vulndb dataset has for them, or else the template's own; 0 when
+	// unknown.
+	CVEs           []string `gorm:"serializer:json" json:"cves,omitempty"`
+	CVSSScore      float64  `gorm:"index" json:"cvss_score,omitempty"`
+	EPSSScore      float64  `gorm:"index" json:"epss_score,omitempty"`
+	EPSSPercentile float64  `json:"epss_percentile,omitempty"`
+	// Timestamp is when nuclei reported it, in unix seconds.
+	Timestamp int64 `json:"timestamp"`
+}
+
+// FindingFilter narrows a scan's findings. Empty fields match everything.
+type FindingFilter struct {
+	Severities []string
+	TemplateID string
+	// Sort is FindingSortEPSS or FindingSortCVSS to list the highest score
+	// first, or empty for the order they were found in.
+	Sort string
+}
+
+const (
+	FindingSortEPSS = "epss"
+	FindingSortCVSS = "cvss"
+)
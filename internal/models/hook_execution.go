@@ -0,0 +1,16 @@
+package models
+
+// HookExecution records one pre, post, stage, pre-run or cleanup hook run
+// during a scan.
+type HookExecution struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	ScanID     string `gorm:"type:varchar(36);index" json:"scan_id"`
+	HookName   string `json:"hook_name"`
+	Scope      string `json:"scope"`  // tool, pre_tool, stage, pre_run or cleanup
+	Target     string `json:"target"` // tool or stage name, or "scan" for pre_run and cleanup
+	Status     string `json:"status"` // succeeded, failed, warned, missing
+	Error      string `gorm:"type:text" json:"error,omitempty"`
+	StartedAt  int64  `json:"started_at"`
+	FinishedAt int64  `json:"finished_at"`
+	DurationMs int64  `json:"duration_ms"`
+}
@@ -1,31 +1,155 @@
 package models
 
+// Subdomain is a host found by a scan, one row per scan and domain.
 type Subdomain struct {
-	Domain              string   `json:"domain"`
-	OpenPorts           []string `json:"open_ports,omitempty"`
-	PotentialFalsePorts []string `json:"potential_false_ports,omitempty"`
-	Vulns               []string `json:"vulns,omitempty"`
-	DirFuzzing          []string `json:"dir_fuzzing,omitempty"`
-	Screenshot          string   `json:"screenshot,omitempty"`
-	Status              string   `json:"status,omitempty"` // alive, dead, etc.
+	ID                  uint            `gorm:"primaryKey" json:"-"`
+	ScanID              string          `gorm:"type:varchar(36);uniqueIndex:idx_subdomains_scan_domain,priority:1" json:"-"`
+	Domain              string          `gorm:"uniqueIndex:idx_subdomains_scan_domain,priority:2" json:"domain"`
+	OpenPorts           []string        `gorm:"serializer:json" json:"open_ports,omitempty"`
+	PotentialFalsePorts []string        `gorm:"serializer:json" json:"potential_false_ports,omitempty"`
+	Vulns               []string        `gorm:"serializer:json" json:"vulns,omitempty"`
+	DirFuzzing          []string        `gorm:"serializer:json" json:"dir_fuzzing,omitempty"`
+	Screenshot          string          `json:"screenshot,omitempty"`
+	Status              SubdomainStatus `gorm:"default:discovered;check:chk_subdomains_liveness,status IN ('discovered','alive','unresponsive','dead')" json:"status,omitempty"`
+	// LastSeenAt is when httpx last got an answer from the host, in unix
+	// seconds.
+	LastSeenAt int64 `json:"last_seen_at,omitempty"`
+	// filled from httpx -json output
+	StatusCode    int      `json:"status_code,omitempty"`
+	Title         string   `json:"title,omitempty"`
+	Technologies  []string `gorm:"serializer:json" json:"technologies,omitempty"`
+	ContentLength int      `json:"content_length,omitempty"`
+
+	Sensitive []SensitiveFinding `gorm:"serializer:json" json:"sensitive,omitempty"`
+	Redirects []Redirect         `gorm:"serializer:json" json:"redirects,omitempty"`
+}
+
+// Redirect is a fuzzed path that answered with a redirect. Location is
+// absolute, resolved against URL; External says it leaves the registrable
+// domain of URL's host.
+type Redirect struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	Location string `json:"location"`
+	External bool   `json:"external,omitempty"`
+}
+
+// SubdomainStats counts a scan's subdomains by what was found on them.
+type SubdomainStats struct {
+	Total           int64
+	WithPorts       int64
+	WithVulns       int64
+	WithScreenshots int64
+	ByStatus        map[SubdomainStatus]int64 `gorm:"-"`
+}
+
+// SensitiveFinding is a fuzzed path that matched a sensitive pattern.
+// Suppressed says which filter kept it from being alerted, if any.
+type SensitiveFinding struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+	Length int    `json:"length"`
+	// Severity is the pattern's after the severity rules, which may also
+	// add Labels.
+	Severity         string   `json:"severity"`
+	OriginalSeverity string   `json:"original_severity,omitempty"`
+	Labels           []string `json:"labels,omitempty"`
+	Description      string   `json:"description"`
+	Category         string   `json:"category"`
+	Pattern          string   `json:"pattern"`
+	Alerted          bool     `json:"alerted"`
+	Suppressed       string   `json:"suppressed,omitempty"`
+}
+
+// SensitiveFilters are the secondary checks a sensitive hit must pass to be
+// alerted. The zero value alerts on every hit.
+type SensitiveFilters struct {
+	MinLength        int  `json:"min_length,omitempty"`
+	ExcludeRedirects bool `json:"exclude_redirects,omitempty"`
+	// Soft404 skips hits whose length matches the host's most common
+	// response length.
+	Soft404 bool `json:"soft_404,omitempty"`
 }
 
 type ToolFailure struct {
+	ToolName      string `json:"tool_name"`
+	Error         string `json:"error"`
+	TimedOutStage string `json:"timed_out_stage,omitempty"`
+	// Diagnostics is the tool's on_failure output file in the scan directory.
+	Diagnostics string `json:"diagnostics,omitempty"`
+}
+
+type HookWarning struct {
+	HookName string `json:"hook_name"`
 	ToolName string `json:"tool_name"`
 	Error    string `json:"error"`
 }
 
+// ToolWarning is a line a tool wrote to stderr that matched one of its
+// warning patterns.
+type ToolWarning struct {
+	ToolName string `json:"tool_name"`
+	Pattern  string `json:"pattern"`
+	Line     string `json:"line"`
+}
+
 type Scan struct {
-	UUID              string        `gorm:"primaryKey;type:varchar(36)" json:"uuid"`
-	ScanType          string        `json:"scan_type"`
-	Status            string        `json:"status"`
-	Domain            string        `json:"domain"`
-	NumberOfDomains   int           `json:"number_of_domains"`
-	Subdomains        []Subdomain   `gorm:"serializer:json" json:"subdomains"`
-	ScreenshotsPath   string        `json:"screenshots_path"`
-	SensitivePatterns string        `gorm:"type:text" json:"sensitive_patterns,omitempty"`
-	ErrorMessage      string        `gorm:"type:text" json:"error_message,omitempty"`
-	FailedTools       []ToolFailure `gorm:"serializer:json" json:"failed_tools,omitempty"`
-	CreatedAt         int64         `json:"created_at"`
-	UpdatedAt         int64         `json:"updated_at"`
+	UUID            string      `gorm:"primaryKey;type:varchar(36);index:idx_scans_created_at_uuid,priority:2" json:"uuid"`
+	ScanType        string      `json:"scan_type"`
+	Status          ScanStatus  `gorm:"index:idx_scans_status;default:queued;check:chk_scans_status,status IN ('queued','running','paused','completed','completed_with_warnings','failed','cancelled')" json:"status"`
+	Domain          string      `json:"domain"`
+	NumberOfDomains int         `json:"number_of_domains"`
+	Subdomains      []Subdomain `gorm:"-" json:"subdomains"` // own table, see SubdomainDAO
+	// SeverityCounts mirrors the vulns in Subdomains by severity so summaries
+	// can be served without loading them.
+	SeverityCounts    map[string]int   `gorm:"serializer:json" json:"severity_counts,omitempty"`
+	ScreenshotsPath   string           `json:"screenshots_path,omitempty"`
+	SensitivePatterns string           `gorm:"type:text" json:"sensitive_patterns,omitempty"`
+	SensitiveFilters  SensitiveFilters `gorm:"serializer:json" json:"sensitive_filters"`
+	ErrorMessage      string           `gorm:"type:text" json:"error_message,omitempty"`
+	FailedTools       []ToolFailure    `gorm:"serializer:json" json:"failed_tools,omitempty"`
+	HookWarnings      []HookWarning    `gorm:"serializer:json" json:"hook_warnings,omitempty"`
+	ToolWarnings      []ToolWarning    `gorm:"serializer:json" json:"tool_warnings,omitempty"`
+	EmptyOutputTools  []string         `gorm:"serializer:json" json:"empty_output_tools,omitempty"`
+	Tags              []string         `gorm:"serializer:json" json:"tags,omitempty"`
+	Priority          int              `json:"priority,omitempty"`
+	TeamID            uint             `gorm:"index" json:"team_id"`
+	ConfigSource      string           `json:"config_source,omitempty"`
+	ConfigRevision    string           `json:"config_revision,omitempty"`
+	ConfigChecksum    string           `json:"config_checksum,omitempty"`
+	TemplatesRef      string           `json:"templates_ref,omitempty"`
+	CreatedAt         int64            `gorm:"index:idx_scans_created_at_uuid,priority:1" json:"created_at"`
+	UpdatedAt         int64            `json:"updated_at"`
+
+	// ToolLogs maps each tool to its log under the scan directory, set
+	// with the other artifacts.
+	ToolLogs map[string]string `gorm:"serializer:json" json:"tool_logs,omitempty"`
+
+	// HTTPHeaders are the headers the scan's HTTP tools sent, with redacted
+	// values hidden.
+	HTTPHeaders map[string]string `gorm:"serializer:json" json:"http_headers,omitempty"`
+
+	// Proxy is the proxy the scan's traffic went through, without its
+	// password.
+	Proxy string `json:"proxy,omitempty"`
+
+	// ScanDir is the scan's working directory on the server, kept so its
+	// failed tools can be retried in place.
+	ScanDir string `json:"-"`
+
+	// RerunOf is the scan this one re-ran with the same parameters.
+	RerunOf string `gorm:"type:varchar(36);index" json:"rerun_of,omitempty"`
+
+	// ReprocessedAt is when the scan's outputs were last parsed again by
+	// pipeliner reprocess, in unix seconds.
+	ReprocessedAt int64 `json:"reprocessed_at,omitempty"`
+
+	// Webhooks registered with the scan request; StartScan stores them in
+	// their own table.
+	Webhooks []ScanWebhook `gorm:"-" json:"-"`
+
+	// Force starts the scan even when it duplicates another and duplicates
+	// are rejected. StartScan sets DuplicateOf to the scans it duplicates.
+	Force       bool     `gorm:"-" json:"-"`
+	DuplicateOf []string `gorm:"-" json:"-"`
 }
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ScanFilter narrows the scan list. Empty fields match everything.
+type ScanFilter struct {
+	Statuses []ScanStatus
+	// Domain matches any scan whose domain contains it, ignoring case.
+	Domain   string
+	ScanType string
+	// CreatedAfter is inclusive and CreatedBefore exclusive.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// IsZero reports whether the filter matches every scan.
+func (f ScanFilter) IsZero() bool {
+	return len(f.Statuses) == 0 && f.Domain == "" && f.ScanType == "" &&
+		f.CreatedAfter.IsZero() && f.CreatedBefore.IsZero()
+}
+
+// ParseScanFilter reads a filter from the query parameters status (repeated
+// or comma-separated), domain, scan_type, from and to. Dates are either
+// 2006-01-02, in UTC, or RFC 3339; a to date includes the whole day.
+func ParseScanFilter(query url.Values) (ScanFilter, error) {
+	filter := ScanFilter{
+		Domain:   strings.TrimSpace(query.Get("domain")),
+		ScanType: strings.TrimSpace(query.Get("scan_type")),
+	}
+
+	for _, value := range query["status"] {
+		for _, status := range strings.Split(value, ",") {
+			status = strings.ToLower(strings.TrimSpace(status))
+			if status == "" {
+				continue
+			}
+			if !slices.Contains(ScanStatuses, ScanStatus(status)) {
+				return ScanFilter{}, fmt.Errorf("status %q is not one of %s", status, joinStatuses(ScanStatuses))
+			}
+			filter.Statuses = append(filter.Statuses, ScanStatus(status))
+		}
+	}
+
+	var err error
+	if filter.CreatedAfter, err = parseFilterDate(query.Get("from"), false); err != nil {
+		return ScanFilter{}, fmt.Errorf("from: %w", err)
+	}
+	if filter.CreatedBefore, err = parseFilterDate(query.Get("to"), true); err != nil {
+		return ScanFilter{}, fmt.Errorf("to: %w", err)
+	}
+	if !filter.CreatedAfter.IsZero() && !filter.CreatedBefore.IsZero() && !filter.CreatedAfter.Before(filter.CreatedBefore) {
+		return ScanFilter{}, fmt.Errorf("from must be before to")
+	}
+	return filter, nil
+}
+
+// parseFilterDate reads a date or timestamp. A bare date ending a range
+// stands for the midnight after it.
+func parseFilterDate(value string, end bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if day, err := time.Parse(time.DateOnly, value); err == nil {
+		if end {
+			day = day.AddDate(0, 0, 1)
+		}
+		return day, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a date (2006-01-02) nor an RFC 3339 time", value)
+	}
+	return t, nil
+}
+
+// Values encodes the filter as query parameters ParseScanFilter reads back.
+func (f ScanFilter) Values() url.Values {
+	values := url.Values{}
+	if len(f.Statuses) > 0 {
+		values.Set("status", joinStatuses(f.Statuses))
+	}
+	if f.Domain != "" {
+		values.Set("domain", f.Domain)
+	}
+	if f.ScanType != "" {
+		values.Set("scan_type", f.ScanType)
+	}
+	if !f.CreatedAfter.IsZero() {
+		values.Set("from", f.CreatedAfter.UTC().Format(time.RFC3339))
+	}
+	if !f.CreatedBefore.IsZero() {
+		values.Set("to", f.CreatedBefore.UTC().Format(time.RFC3339))
+	}
+	return values
+}
+
+func joinStatuses(statuses []ScanStatus) string {
+	names := make([]string, len(statuses))
+	for i, status := range statuses {
+		names[i] = string(status)
+	}
+	return strings.Join(names, ",")
+}
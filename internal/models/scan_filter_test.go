@@ -0,0 +1,40 @@
+package models
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScanFilter(t *testing.T) {
+	query, _ := url.ParseQuery("status=running,FAILED&status=queued&domain=%20example.com&from=2024-01-01&to=2024-01-31T12:00:00%2B02:00")
+	filter, err := ParseScanFilter(query)
+	require.NoError(t, err)
+	assert.Equal(t, []ScanStatus{ScanRunning, ScanFailed, ScanQueued}, filter.Statuses)
+	assert.Equal(t, "example.com", filter.Domain)
+	assert.True(t, filter.CreatedAfter.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, filter.CreatedBefore.Equal(time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)))
+
+	// Values reads back as the same filter
+	again, err := ParseScanFilter(filter.Values())
+	require.NoError(t, err)
+	assert.Equal(t, filter.Statuses, again.Statuses)
+	assert.True(t, again.CreatedBefore.Equal(filter.CreatedBefore))
+
+	filter, err = ParseScanFilter(url.Values{"to": {"2024-01-31"}})
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), filter.CreatedBefore)
+
+	empty, err := ParseScanFilter(url.Values{"status": {""}})
+	require.NoError(t, err)
+	assert.True(t, empty.IsZero())
+
+	for _, bad := range []string{"status=done", "from=yesterday", "to=2024-13-01", "from=2024-02-01&to=2024-01-31"} {
+		query, _ := url.ParseQuery(bad)
+		_, err := ParseScanFilter(query)
+		assert.Error(t, err, bad)
+	}
+}
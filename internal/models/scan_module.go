@@ -0,0 +1,47 @@
+package models
+
+import (
+	"pipeliner/pkg/tools"
+	"time"
+)
+
+// ScanModule is a module with what the start page shows about it. The
+// module's own fields are inlined in its JSON.
+type ScanModule struct {
+	tools.ChainConfig
+	Stages   []ModuleStage  `json:"stages"`
+	Binaries []ModuleBinary `json:"binaries"`
+	// AverageDurationSeconds is over the module's last DurationSamples
+	// finished scans; both are 0 before it has any.
+	AverageDurationSeconds int64 `json:"average_duration_seconds"`
+	DurationSamples        int   `json:"duration_samples"`
+}
+
+// ModuleStage lists a module's tools in one stage, in module order. Tools
+// whose type has no stage are under "other".
+type ModuleStage struct {
+	Stage string   `json:"stage"`
+	Tools []string `json:"tools"`
+}
+
+// ModuleBinary is a command a module's tools run and whether the server
+// found it in PATH.
+type ModuleBinary struct {
+	Command   string `json:"command"`
+	Installed bool   `json:"installed"`
+}
+
+func (m ScanModule) AverageDuration() time.Duration {
+	return time.Duration(m.AverageDurationSeconds) * time.Second
+}
+
+// MissingBinaries counts the module's commands that are not installed.
+func (m ScanModule) MissingBinaries() int {
+	missing := 0
+	for _, binary := range m.Binaries {
+		if !binary.Installed {
+			missing++
+		}
+	}
+	return missing
+}
@@ -0,0 +1,118 @@
+package models
+
+// ScanStatus is where a scan is in its life. ScanStatusManager is the only
+// writer, and only moves a scan along scanTransitions.
+type ScanStatus string
+
+const (
+	ScanQueued                ScanStatus = "queued"
+	ScanRunning               ScanStatus = "running"
+	ScanPaused                ScanStatus = "paused"
+	ScanCompleted             ScanStatus = "completed"
+	ScanCompletedWithWarnings ScanStatus = "completed_with_warnings"
+	ScanFailed                ScanStatus = "failed"
+	ScanCancelled             ScanStatus = "cancelled"
+)
+
+// ScanStatuses lists every scan status; the check constraint on
+// scans.status allows exactly these.
+var ScanStatuses = []ScanStatus{ScanQueued, ScanRunning, ScanPaused, ScanCompleted, ScanCompletedWithWarnings, ScanFailed, ScanCancelled}
+
+// FinishedScanStatuses are the statuses of scans that ran to the end.
+var FinishedScanStatuses = []ScanStatus{ScanCompleted, ScanCompletedWithWarnings}
+
+var scanTransitions = map[ScanStatus][]ScanStatus{
+	ScanQueued:  {ScanRunning, ScanFailed, ScanCancelled},
+	ScanRunning: {ScanPaused, ScanCompleted, ScanCompletedWithWarnings, ScanFailed, ScanCancelled},
+	// a soft pause lets the running tools finish, which may end the scan
+	ScanPaused: {ScanRunning, ScanCompleted, ScanCompletedWithWarnings, ScanFailed, ScanCancelled},
+	// retrying the failed tools queues the scan again
+	ScanCompletedWithWarnings: {ScanQueued},
+}
+
+// IsTerminal reports whether a scan with this status is done for good.
+// Only a retry takes a scan out of completed_with_warnings.
+func (s ScanStatus) IsTerminal() bool {
+	switch s {
+	case ScanCompleted, ScanCompletedWithWarnings, ScanFailed, ScanCancelled:
+		return true
+	}
+	return false
+}
+
+// CanTransition reports whether a scan may move from s to next. Staying in
+// the same status is not a transition.
+func (s ScanStatus) CanTransition(next ScanStatus) bool {
+	for _, allowed := range scanTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ScanStatusesInto lists the statuses a scan may move to next from.
+func ScanStatusesInto(next ScanStatus) []ScanStatus {
+	var from []ScanStatus
+	for _, status := range ScanStatuses {
+		if status.CanTransition(next) {
+			from = append(from, status)
+		}
+	}
+	return from
+}
+
+// SubdomainStatus is what the last httpx probe said about a subdomain. The
+// monitor moves subdomains along subdomainTransitions.
+type SubdomainStatus string
+
+const (
+	// SubdomainDiscovered was enumerated but not probed yet.
+	SubdomainDiscovered SubdomainStatus = "discovered"
+	// SubdomainAlive answered an httpx probe.
+	SubdomainAlive SubdomainStatus = "alive"
+	// SubdomainUnresponsive answered once but is missing from a later probe.
+	SubdomainUnresponsive SubdomainStatus = "unresponsive"
+	// SubdomainDead no longer resolves.
+	SubdomainDead SubdomainStatus = "dead"
+)
+
+// SubdomainStatuses lists every subdomain status; the check constraint on
+// subdomains.status allows exactly these.
+var SubdomainStatuses = []SubdomainStatus{SubdomainDiscovered, SubdomainAlive, SubdomainUnresponsive, SubdomainDead}
+
+var subdomainTransitions = map[SubdomainStatus][]SubdomainStatus{
+	SubdomainDiscovered: {SubdomainAlive, SubdomainDead},
+	// a host that never answered is not unresponsive, only not alive yet
+	SubdomainAlive:        {SubdomainUnresponsive, SubdomainDead},
+	SubdomainUnresponsive: {SubdomainAlive, SubdomainDead},
+	SubdomainDead:         {SubdomainAlive},
+}
+
+// IsValid reports whether s is one of SubdomainStatuses.
+func (s SubdomainStatus) IsValid() bool {
+	_, ok := subdomainTransitions[s]
+	return ok
+}
+
+// CanTransition reports whether a subdomain may move from s to next.
+func (s SubdomainStatus) CanTransition(next SubdomainStatus) bool {
+	for _, allowed := range subdomainTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// SubdomainStatusesInto lists the statuses a subdomain may move to next
+// from.
+func SubdomainStatusesInto(next SubdomainStatus) []SubdomainStatus {
+	var from []SubdomainStatus
+	for _, status := range SubdomainStatuses {
+		if status.CanTransition(next) {
+			from = append(from, status)
+		}
+	}
+	return from
+}
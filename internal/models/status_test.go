@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanStatusTransitions(t *testing.T) {
+	allowed := map[ScanStatus][]ScanStatus{
+		ScanQueued:                {ScanRunning, ScanFailed, ScanCancelled},
+		ScanRunning:               {ScanPaused, ScanCompleted, ScanCompletedWithWarnings, ScanFailed, ScanCancelled},
+		ScanPaused:                {ScanRunning, ScanCompleted, ScanCompletedWithWarnings, ScanFailed, ScanCancelled},
+		ScanCompleted:             nil,
+		ScanCompletedWithWarnings: {ScanQueued},
+		ScanFailed:                nil,
+		ScanCancelled:             nil,
+	}
+
+	for _, from := range ScanStatuses {
+		for _, to := range ScanStatuses {
+			want := false
+			for _, next := range allowed[from] {
+				want = want || next == to
+			}
+			assert.Equal(t, want, from.CanTransition(to), "%s -> %s", from, to)
+		}
+	}
+	assert.False(t, ScanStatus("complete").CanTransition(ScanRunning))
+	assert.False(t, ScanRunning.CanTransition("complete"))
+
+	assert.Equal(t, []ScanStatus{ScanQueued, ScanRunning, ScanPaused}, ScanStatusesInto(ScanCancelled))
+	assert.Equal(t, []ScanStatus{ScanCompletedWithWarnings}, ScanStatusesInto(ScanQueued))
+}
+
+func TestScanStatusIsTerminal(t *testing.T) {
+	terminal := map[ScanStatus]bool{ScanCompleted: true, ScanCompletedWithWarnings: true, ScanFailed: true, ScanCancelled: true}
+	for _, status := range ScanStatuses {
+		assert.Equal(t, terminal[status], status.IsTerminal(), status)
+	}
+}
+
+func TestSubdomainStatusTransitions(t *testing.T) {
+	allowed := map[SubdomainStatus][]SubdomainStatus{
+		SubdomainDiscovered:   {SubdomainAlive, SubdomainDead},
+		SubdomainAlive:        {SubdomainUnresponsive, SubdomainDead},
+		SubdomainUnresponsive: {SubdomainAlive, SubdomainDead},
+		SubdomainDead:         {SubdomainAlive},
+	}
+
+	for _, from := range SubdomainStatuses {
+		assert.True(t, from.IsValid())
+		for _, to := range SubdomainStatuses {
+			want := false
+			for _, next := range allowed[from] {
+				want = want || next == to
+			}
+			assert.Equal(t, want, from.CanTransition(to), "%s -> %s", from, to)
+		}
+	}
+	assert.False(t, SubdomainStatus("").IsValid())
+	assert.Equal(t, []SubdomainStatus{SubdomainDiscovered, SubdomainUnresponsive, SubdomainDead}, SubdomainStatusesInto(SubdomainAlive))
+}
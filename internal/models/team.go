@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// DefaultTeamName is the team scans without one are assigned to.
+const DefaultTeamName = "default"
+
+// Team owns scans; its API tokens only see the team's own scans.
+type Team struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"uniqueIndex" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APIToken authenticates API and web requests as a member of a team. Only
+// the SHA-256 of the token is stored. Admin tokens see every team's scans.
+type APIToken struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TeamID    uint      `gorm:"index" json:"team_id"`
+	Name      string    `json:"name"`
+	TokenHash string    `gorm:"uniqueIndex" json:"-"`
+	Admin     bool      `json:"admin"`
+	CreatedAt time.Time `json:"created_at"`
+	// RevokedAt is when the token stopped being accepted, nil while it is.
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
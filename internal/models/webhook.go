@@ -0,0 +1,47 @@
+package models
+
+import "slices"
+
+// Webhook events a registration can subscribe to.
+const (
+	WebhookEventCompleted       = "completed"
+	WebhookEventFailed          = "failed"
+	WebhookEventCriticalFinding = "finding.critical"
+)
+
+// WebhookEvents lists every event a webhook can subscribe to.
+var WebhookEvents = []string{WebhookEventCompleted, WebhookEventFailed, WebhookEventCriticalFinding}
+
+// ScanWebhook is a URL notified about one scan. An empty Events list means
+// every event.
+type ScanWebhook struct {
+	ID     uint     `gorm:"primaryKey" json:"id"`
+	ScanID string   `gorm:"type:varchar(36);index" json:"scan_id"`
+	URL    string   `json:"url"`
+	Secret string   `json:"-"`
+	Events []string `gorm:"serializer:json" json:"events,omitempty"`
+}
+
+// Wants reports whether the webhook is subscribed to event.
+func (w ScanWebhook) Wants(event string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, event)
+}
+
+// WebhookDelivery records one payload posted to a webhook and the outcome of
+// its last attempt.
+type WebhookDelivery struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	ScanID string `gorm:"type:varchar(36);index" json:"scan_id"`
+	// WebhookID is the scan's registration, or zero for the global default.
+	WebhookID    uint   `json:"webhook_id"`
+	URL          string `json:"url"`
+	Event        string `json:"event"`
+	Payload      string `gorm:"type:text" json:"payload"`
+	Status       string `json:"status"` // pending, delivered, failed
+	Attempts     int    `json:"attempts"`
+	StatusCode   int    `json:"status_code,omitempty"`
+	Error        string `gorm:"type:text" json:"error,omitempty"`
+	RedeliveryOf uint   `json:"redelivery_of,omitempty"`
+	CreatedAt    int64  `json:"created_at"`
+	UpdatedAt    int64  `json:"updated_at"`
+}
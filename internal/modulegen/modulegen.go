@@ -0,0 +1,151 @@
+// Package modulegen writes new scan modules from a built-in catalog of tool
+// templates.
+package modulegen
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"pipeliner/pkg/engine"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed catalog/*.yaml
+var catalogFS embed.FS
+
+var (
+	ErrInvalidName = errors.New("module name may only contain letters, digits, '-' and '_'")
+	ErrUnknownTool = errors.New("tool is not in the catalog")
+	ErrNoTools     = errors.New("at least one tool is required")
+)
+
+// ExecutionModes are the modes a module can be generated with, default first.
+var ExecutionModes = []string{"hybrid", "sequential", "concurrent"}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// CatalogTool is a tool init-module can add to a module.
+type CatalogTool struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Requires    []string `yaml:"requires"`
+}
+
+// Spec describes the module to generate.
+type Spec struct {
+	Name          string
+	Description   string
+	ExecutionMode string
+	Tools         []string
+}
+
+// Catalog lists the available tools in the order they are written.
+func Catalog() ([]CatalogTool, error) {
+	data, err := catalogFS.ReadFile("catalog/catalog.yaml")
+	if err != nil {
+		return nil, err
+	}
+	var catalog []CatalogTool
+	if err := yaml.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("parse tool catalog: %w", err)
+	}
+	return catalog, nil
+}
+
+// Resolve adds the tools the selected ones read from and returns the full
+// set in catalog order.
+func Resolve(selected []string) ([]string, error) {
+	catalog, err := Catalog()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]CatalogTool, len(catalog))
+	for _, t := range catalog {
+		byName[t.Name] = t
+	}
+
+	want := make(map[string]bool)
+	var add func(name string) error
+	add = func(name string) error {
+		t, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownTool, name)
+		}
+		if want[name] {
+			return nil
+		}
+		want[name] = true
+		for _, req := range t.Requires {
+			if err := add(req); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, name := range selected {
+		if err := add(strings.TrimSpace(name)); err != nil {
+			return nil, err
+		}
+	}
+
+	var resolved []string
+	for _, t := range catalog {
+		if want[t.Name] {
+			resolved = append(resolved, t.Name)
+		}
+	}
+	return resolved, nil
+}
+
+// Generate renders the module YAML for spec and validates it the same way a
+// scan loads it.
+func Generate(spec Spec) ([]byte, error) {
+	if !namePattern.MatchString(spec.Name) {
+		return nil, ErrInvalidName
+	}
+	if spec.ExecutionMode == "" {
+		spec.ExecutionMode = ExecutionModes[0]
+	}
+	if !slices.Contains(ExecutionModes, spec.ExecutionMode) {
+		return nil, fmt.Errorf("invalid execution mode %q, must be one of: %s", spec.ExecutionMode, strings.Join(ExecutionModes, ", "))
+	}
+	if len(spec.Tools) == 0 {
+		return nil, ErrNoTools
+	}
+	toolNames, err := Resolve(spec.Tools)
+	if err != nil {
+		return nil, err
+	}
+
+	description := spec.Description
+	if description == "" {
+		description = "Generated by pipeliner init-module"
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "description: %q\n", description)
+	fmt.Fprintf(&buf, "name: %s\n", spec.Name)
+	fmt.Fprintf(&buf, "execution_mode: %s\n", spec.ExecutionMode)
+	buf.WriteString("tools:\n")
+	for i, name := range toolNames {
+		snippet, err := catalogFS.ReadFile(path.Join("catalog", name+".yaml"))
+		if err != nil {
+			return nil, fmt.Errorf("read template for %s: %w", name, err)
+		}
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		buf.Write(snippet)
+	}
+
+	if err := engine.ValidateModuleSource(spec.Name+".yaml", buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
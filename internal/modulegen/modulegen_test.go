@@ -0,0 +1,71 @@
+package modulegen
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"pipeliner/pkg/tools"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Every combination of catalog tools, in every execution mode, must produce
+// a module that validates and whose tools build their arguments.
+func TestGenerate_EveryCatalogCombination(t *testing.T) {
+	catalog, err := Catalog()
+	require.NoError(t, err)
+
+	options := tools.DefaultOptions()
+	options.ScanType = "generated"
+	options.Domain = "example.com"
+
+	for mask := 1; mask < 1<<len(catalog); mask++ {
+		var selected []string
+		for i, tool := range catalog {
+			if mask&(1<<i) != 0 {
+				selected = append(selected, tool.Name)
+			}
+		}
+
+		for _, mode := range ExecutionModes {
+			name := strings.Join(selected, "+") + "/" + mode
+			data, err := Generate(Spec{Name: "generated", ExecutionMode: mode, Tools: selected})
+			require.NoError(t, err, name)
+
+			v := viper.New()
+			v.SetConfigType("yaml")
+			require.NoError(t, v.ReadConfig(bytes.NewReader(data)), name)
+			var chain tools.ChainConfig
+			require.NoError(t, v.Unmarshal(&chain), name)
+			assert.Equal(t, mode, v.GetString("execution_mode"), name)
+			for _, tc := range chain.Tools {
+				_, err := tc.BuildArgs(options)
+				assert.NoError(t, err, name+": "+tc.Name)
+			}
+		}
+	}
+}
+
+func TestResolve_AddsRequiredTools(t *testing.T) {
+	resolved, err := Resolve([]string{"nuclei"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"subfinder", "httpx", "nuclei"}, resolved)
+
+	_, err = Resolve([]string{"masscan"})
+	assert.True(t, errors.Is(err, ErrUnknownTool))
+}
+
+func TestGenerate_RejectsBadInput(t *testing.T) {
+	_, err := Generate(Spec{Name: "../evil", Tools: []string{"subfinder"}})
+	assert.True(t, errors.Is(err, ErrInvalidName))
+
+	_, err = Generate(Spec{Name: "ok", Tools: nil})
+	assert.True(t, errors.Is(err, ErrNoTools))
+
+	_, err = Generate(Spec{Name: "ok", ExecutionMode: "parallel", Tools: []string{"subfinder"}})
+	assert.Error(t, err)
+}
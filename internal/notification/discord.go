@@ -1,6 +1,7 @@
 package notification
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -9,36 +10,88 @@ import (
 )
 
 type Message struct {
-	Title       string
-	Description string
-	Severity    string
-	Fields      map[string]string
-	Timestamp   time.Time
+	Title       string            `json:"title"`
+	Description string            `json:"description,omitempty"`
+	Severity    string            `json:"severity,omitempty"`
+	EventType   EventType         `json:"event_type,omitempty"`
+	Fields      map[string]string `json:"fields,omitempty"`
+	Timestamp   time.Time         `json:"timestamp"`
 }
 
-type NotificationClient struct {
+// Session is the minimal Discord surface NotificationClient needs. It is
+// satisfied by the discordgo REST adapter and by fakes in tests.
+type Session interface {
+	SendEmbed(channelID string, embed *discordgo.MessageEmbed) error
+	Close() error
+}
+
+// channelChecker is optionally implemented by sessions that can verify the bot
+// has access to a channel; ValidateChannels skips the check otherwise.
+type channelChecker interface {
+	CheckChannel(channelID string) error
+}
+
+// discordgoSession posts embeds over the REST API only. No gateway connection
+// is opened since sending messages does not need one.
+type discordgoSession struct {
 	sg *discordgo.Session
 }
 
+func NewDiscordSession(token string) (Session, error) {
+	sg, err := discordgo.New("Bot " + token)
+	if err != nil {
+		return nil, err
+	}
+	return &discordgoSession{sg: sg}, nil
+}
+
+func (d *discordgoSession) SendEmbed(channelID string, embed *discordgo.MessageEmbed) error {
+	_, err := d.sg.ChannelMessageSendEmbed(channelID, embed)
+	return err
+}
+
+func (d *discordgoSession) CheckChannel(channelID string) error {
+	_, err := d.sg.Channel(channelID)
+	return err
+}
+
+func (d *discordgoSession) Close() error {
+	if d.sg.Client != nil {
+		d.sg.Client.CloseIdleConnections()
+	}
+	return nil
+}
+
+type NotificationClient struct {
+	session Session
+	routes  ChannelRoutes
+}
+
+// NewNotificationClient builds a client from DISCORD_TOKEN and the channel routing env vars.
 func NewNotificationClient() (*NotificationClient, error) {
 	token := os.Getenv("DISCORD_TOKEN")
 	if token == "" {
 		return nil, fmt.Errorf("DISCORD_TOKEN environment variable not set")
 	}
 
-	sg, err := discordgo.New("Bot " + token)
+	session, err := NewDiscordSession(token)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := sg.Open(); err != nil {
-		return nil, err
-	}
+	return NewNotificationClientWithSession(session, LoadChannelRoutes()), nil
+}
 
-	return &NotificationClient{sg: sg}, nil
+func NewNotificationClientWithSession(session Session, routes ChannelRoutes) *NotificationClient {
+	return &NotificationClient{session: session, routes: routes}
 }
 
 func (c *NotificationClient) getSeverityColor(severity string) int {
+	return severityColor(severity)
+}
+
+// severityColor is the RGB color messages of a severity are shown with.
+func severityColor(severity string) int {
 	switch severity {
 	case "critical":
 		return 0x8B0000
@@ -56,15 +109,19 @@ func (c *NotificationClient) getSeverityColor(severity string) int {
 }
 
 func (c *NotificationClient) Send(msg Message) error {
-	if c.sg == nil {
+	if c.session == nil {
 		return fmt.Errorf("Discord client not initialized")
 	}
 
-	channelID := os.Getenv("DISCORD_CHANNEL_ID")
+	channelID := c.routes.ChannelFor(msg)
 	if channelID == "" {
-		return fmt.Errorf("DISCORD_CHANNEL_ID not set")
+		return fmt.Errorf("no Discord channel configured for message (set DISCORD_CHANNEL_ID or DISCORD_CHANNEL_ROUTES)")
 	}
 
+	return c.session.SendEmbed(channelID, c.buildEmbed(msg))
+}
+
+func (c *NotificationClient) buildEmbed(msg Message) *discordgo.MessageEmbed {
 	if msg.Timestamp.IsZero() {
 		msg.Timestamp = time.Now()
 	}
@@ -91,13 +148,38 @@ func (c *NotificationClient) Send(msg Message) error {
 		embed.Fields = fields
 	}
 
-	_, err := c.sg.ChannelMessageSendEmbed(channelID, embed)
-	return err
+	return embed
+}
+
+// ValidateChannels checks that every routed channel can be fetched by the bot.
+// It returns one joined error describing all inaccessible channels, or nil.
+func (c *NotificationClient) ValidateChannels() error {
+	if c.session == nil {
+		return fmt.Errorf("Discord client not initialized")
+	}
+
+	channels := c.routes.Channels()
+	if len(channels) == 0 {
+		return fmt.Errorf("no Discord channels configured")
+	}
+
+	checker, ok := c.session.(channelChecker)
+	if !ok {
+		return nil
+	}
+
+	var errs []error
+	for _, channelID := range channels {
+		if err := checker.CheckChannel(channelID); err != nil {
+			errs = append(errs, fmt.Errorf("channel %s is not accessible: %w", channelID, err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 func (c *NotificationClient) Close() error {
-	if c.sg != nil {
-		return c.sg.Close()
+	if c.session != nil {
+		return c.session.Close()
 	}
 	return nil
 }
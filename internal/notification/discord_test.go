@@ -0,0 +1,205 @@
+package notification
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSession struct {
+	mu           sync.Mutex
+	sent         map[string][]*discordgo.MessageEmbed
+	inaccessible map[string]bool
+	closed       bool
+}
+
+func newFakeSession() *fakeSession {
+	return &fakeSession{
+		sent:         make(map[string][]*discordgo.MessageEmbed),
+		inaccessible: make(map[string]bool),
+	}
+}
+
+func (f *fakeSession) SendEmbed(channelID string, embed *discordgo.MessageEmbed) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent[channelID] = append(f.sent[channelID], embed)
+	return nil
+}
+
+func (f *fakeSession) CheckChannel(channelID string) error {
+	if f.inaccessible[channelID] {
+		return errors.New("HTTP 403 Forbidden")
+	}
+	return nil
+}
+
+func (f *fakeSession) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestParseChannelRoutes(t *testing.T) {
+	routes := ParseChannelRoutes("default", " Critical = alerts , scan_lifecycle=scans,new_subdomain=assets,bogus,empty=")
+
+	assert.Equal(t, "default", routes.Default)
+	assert.Equal(t, map[string]string{"critical": "alerts"}, routes.BySeverity)
+	assert.Equal(t, map[EventType]string{
+		EventScanLifecycle: "scans",
+		EventNewSubdomain:  "assets",
+	}, routes.ByEventType)
+	assert.Equal(t, []string{"alerts", "assets", "default", "scans"}, routes.Channels())
+}
+
+func TestNotificationClient_SendRouting(t *testing.T) {
+	routes := ParseChannelRoutes("general", "critical=alerts,scan_lifecycle=scans,new_subdomain=assets")
+
+	tests := []struct {
+		name    string
+		msg     Message
+		channel string
+	}{
+		{
+			name:    "critical finding goes to alerts",
+			msg:     Message{Title: "RCE", Severity: "critical", EventType: EventFinding},
+			channel: "alerts",
+		},
+		{
+			name:    "severity match is case insensitive",
+			msg:     Message{Title: "RCE", Severity: "CRITICAL"},
+			channel: "alerts",
+		},
+		{
+			name:    "lifecycle event goes to scans",
+			msg:     Message{Title: "Scan started", Severity: "info", EventType: EventScanLifecycle},
+			channel: "scans",
+		},
+		{
+			name:    "new subdomain goes to assets",
+			msg:     Message{Title: "New host", EventType: EventNewSubdomain},
+			channel: "assets",
+		},
+		{
+			name:    "unrouted finding falls back to default",
+			msg:     Message{Title: "Exposed swagger", Severity: "medium", EventType: EventFinding},
+			channel: "general",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := newFakeSession()
+			client := NewNotificationClientWithSession(session, routes)
+
+			assert.NoError(t, client.Send(tt.msg))
+			assert.Len(t, session.sent, 1)
+			assert.Len(t, session.sent[tt.channel], 1)
+			assert.Equal(t, tt.msg.Title, session.sent[tt.channel][0].Title)
+		})
+	}
+}
+
+func TestNotificationClient_SendWithoutChannel(t *testing.T) {
+	session := newFakeSession()
+	client := NewNotificationClientWithSession(session, ParseChannelRoutes("", "critical=alerts"))
+
+	err := client.Send(Message{Title: "low finding", Severity: "low"})
+	assert.Error(t, err)
+	assert.Empty(t, session.sent)
+}
+
+func TestNotificationClient_ValidateChannels(t *testing.T) {
+	session := newFakeSession()
+	session.inaccessible["alerts"] = true
+	client := NewNotificationClientWithSession(session, ParseChannelRoutes("general", "critical=alerts"))
+
+	err := client.ValidateChannels()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "alerts")
+	assert.NotContains(t, err.Error(), "general")
+
+	delete(session.inaccessible, "alerts")
+	assert.NoError(t, client.ValidateChannels())
+}
+
+func TestNotificationClient_BuildEmbed(t *testing.T) {
+	client := NewNotificationClientWithSession(newFakeSession(), ParseChannelRoutes("general", ""))
+	ts := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	embed := client.buildEmbed(Message{
+		Title:       "Sensitive Endpoint Found!",
+		Description: "/.env exposed",
+		Severity:    "critical",
+		Timestamp:   ts,
+		Fields: map[string]string{
+			"Domain": "https://app.example.com",
+			"Long":   strings.Repeat("a", 2000),
+		},
+	})
+
+	assert.Equal(t, "Sensitive Endpoint Found!", embed.Title)
+	assert.Equal(t, "/.env exposed", embed.Description)
+	assert.Equal(t, 0x8B0000, embed.Color)
+	assert.Equal(t, "2024-06-01T12:00:00Z", embed.Timestamp)
+	assert.Len(t, embed.Fields, 2)
+
+	for _, field := range embed.Fields {
+		assert.True(t, field.Inline)
+		switch field.Name {
+		case "Domain":
+			assert.Equal(t, "https://app.example.com", field.Value)
+		case "Long":
+			assert.Len(t, field.Value, 1024)
+			assert.True(t, strings.HasSuffix(field.Value, "..."))
+		default:
+			t.Errorf("unexpected field %s", field.Name)
+		}
+	}
+}
+
+func TestNotificationClient_BuildEmbedDefaultsTimestamp(t *testing.T) {
+	client := NewNotificationClientWithSession(newFakeSession(), ChannelRoutes{})
+
+	embed := client.buildEmbed(Message{Title: "no timestamp"})
+
+	parsed, err := time.Parse(time.RFC3339, embed.Timestamp)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), parsed, 5*time.Second)
+	assert.Empty(t, embed.Fields)
+}
+
+func TestNotificationClient_SeverityColors(t *testing.T) {
+	client := NewNotificationClientWithSession(newFakeSession(), ChannelRoutes{})
+
+	tests := []struct {
+		severity string
+		color    int
+	}{
+		{"critical", 0x8B0000},
+		{"high", 0xFF0000},
+		{"medium", 0xFF8C00},
+		{"low", 0xFFD700},
+		{"info", 0x00BFFF},
+		{"", 0x808080},
+		{"unknown", 0x808080},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.severity, func(t *testing.T) {
+			assert.Equal(t, tt.color, client.getSeverityColor(tt.severity))
+		})
+	}
+}
+
+func TestNotificationClient_Close(t *testing.T) {
+	session := newFakeSession()
+	client := NewNotificationClientWithSession(session, ChannelRoutes{})
+
+	assert.NoError(t, client.Close())
+	assert.True(t, session.closed)
+}
@@ -0,0 +1,97 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Notifier delivers messages to a notification backend.
+type Notifier interface {
+	Send(msg Message) error
+	Close() error
+}
+
+// ErrNoBackend is returned by NewNotifier when the environment configures
+// no notification backend.
+var ErrNoBackend = errors.New("no notification backend configured")
+
+var (
+	_ Notifier = (*NotificationClient)(nil)
+	_ Notifier = (*SlackNotifier)(nil)
+	_ Notifier = (*WebhookNotifier)(nil)
+	_ Notifier = (*MultiNotifier)(nil)
+)
+
+// NewNotifier builds a notifier for every backend configured in the
+// environment: Discord when DISCORD_TOKEN is set, Slack when
+// SLACK_WEBHOOK_URL is and a webhook when PIPELINER_WEBHOOK_URL is. With
+// more than one, messages go to all of them.
+func NewNotifier() (Notifier, error) {
+	var notifiers []Notifier
+	if os.Getenv("DISCORD_TOKEN") != "" {
+		discord, err := NewNotificationClient()
+		if err != nil {
+			return nil, fmt.Errorf("discord: %w", err)
+		}
+		notifiers = append(notifiers, discord)
+	}
+	if url := os.Getenv(SlackWebhookEnv); url != "" {
+		notifiers = append(notifiers, NewSlackNotifier(url))
+	}
+	if url := os.Getenv(WebhookNotifierURLEnv); url != "" {
+		notifiers = append(notifiers, NewWebhookNotifier(url, os.Getenv(WebhookNotifierSecretEnv)))
+	}
+
+	switch len(notifiers) {
+	case 0:
+		return nil, fmt.Errorf("%w (set DISCORD_TOKEN, %s or %s)", ErrNoBackend, SlackWebhookEnv, WebhookNotifierURLEnv)
+	case 1:
+		return notifiers[0], nil
+	default:
+		return NewMultiNotifier(notifiers...), nil
+	}
+}
+
+// Validate checks the channels of every backend that can check them.
+func Validate(n Notifier) error {
+	switch n := n.(type) {
+	case *MultiNotifier:
+		var errs []error
+		for _, notifier := range n.notifiers {
+			errs = append(errs, Validate(notifier))
+		}
+		return errors.Join(errs...)
+	case interface{ ValidateChannels() error }:
+		return n.ValidateChannels()
+	default:
+		return nil
+	}
+}
+
+// MultiNotifier sends every message to all of its notifiers.
+type MultiNotifier struct {
+	notifiers []Notifier
+}
+
+func NewMultiNotifier(notifiers ...Notifier) *MultiNotifier {
+	return &MultiNotifier{notifiers: notifiers}
+}
+
+// Send tries every notifier, so one failing backend does not keep the
+// message from the others. It returns their errors joined.
+func (m *MultiNotifier) Send(msg Message) error {
+	var errs []error
+	for _, notifier := range m.notifiers {
+		errs = append(errs, notifier.Send(msg))
+	}
+	return errors.Join(errs...)
+}
+
+func (m *MultiNotifier) Close() error {
+	var errs []error
+	for _, notifier := range m.notifiers {
+		errs = append(errs, notifier.Close())
+	}
+	return errors.Join(errs...)
+}
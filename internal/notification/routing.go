@@ -0,0 +1,102 @@
+package notification
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// EventType says what a message is about, for routing it to a channel.
+type EventType string
+
+const (
+	// EventFinding is a finding from a scan's tools.
+	EventFinding EventType = "finding"
+	// EventScanLifecycle is a scan starting, finishing or running into trouble.
+	EventScanLifecycle EventType = "scan_lifecycle"
+	// EventNewSubdomain is a scan finding hosts, or its subdomain stage
+	// finding none.
+	EventNewSubdomain EventType = "new_subdomain"
+)
+
+var knownEventTypes = map[EventType]bool{
+	EventFinding:       true,
+	EventScanLifecycle: true,
+	EventNewSubdomain:  true,
+}
+
+// ChannelRoutes maps message severities and event types to Discord channel IDs.
+// Severity routes take precedence over event type routes; Default catches the rest.
+type ChannelRoutes struct {
+	Default     string
+	BySeverity  map[string]string
+	ByEventType map[EventType]string
+}
+
+// LoadChannelRoutes reads routing from DISCORD_CHANNEL_ID (default channel) and
+// DISCORD_CHANNEL_ROUTES, a comma-separated list of key=channelID pairs where key
+// is either a severity (critical, high, ...) or an event type (finding, scan_lifecycle, new_subdomain).
+func LoadChannelRoutes() ChannelRoutes {
+	return ParseChannelRoutes(os.Getenv("DISCORD_CHANNEL_ID"), os.Getenv("DISCORD_CHANNEL_ROUTES"))
+}
+
+func ParseChannelRoutes(defaultChannel, spec string) ChannelRoutes {
+	routes := ChannelRoutes{
+		Default:     strings.TrimSpace(defaultChannel),
+		BySeverity:  make(map[string]string),
+		ByEventType: make(map[EventType]string),
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		key, channelID, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		channelID = strings.TrimSpace(channelID)
+		if key == "" || channelID == "" {
+			continue
+		}
+
+		if knownEventTypes[EventType(key)] {
+			routes.ByEventType[EventType(key)] = channelID
+		} else {
+			routes.BySeverity[key] = channelID
+		}
+	}
+
+	return routes
+}
+
+// ChannelFor returns the channel a message should be posted to, or "" if no route matches.
+func (r ChannelRoutes) ChannelFor(msg Message) string {
+	if channelID, ok := r.BySeverity[strings.ToLower(msg.Severity)]; ok {
+		return channelID
+	}
+	if channelID, ok := r.ByEventType[msg.EventType]; ok {
+		return channelID
+	}
+	return r.Default
+}
+
+// Channels returns every distinct channel ID referenced by the routes, sorted.
+func (r ChannelRoutes) Channels() []string {
+	seen := make(map[string]bool)
+	var channels []string
+	add := func(channelID string) {
+		if channelID != "" && !seen[channelID] {
+			seen[channelID] = true
+			channels = append(channels, channelID)
+		}
+	}
+
+	add(r.Default)
+	for _, channelID := range r.BySeverity {
+		add(channelID)
+	}
+	for _, channelID := range r.ByEventType {
+		add(channelID)
+	}
+	sort.Strings(channels)
+	return channels
+}
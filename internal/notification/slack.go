@@ -0,0 +1,144 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SlackWebhookEnv is the Slack incoming webhook messages are posted to.
+const SlackWebhookEnv = "SLACK_WEBHOOK_URL"
+
+// Slack's limits on block text and on fields per section.
+const (
+	slackHeaderMax      = 150
+	slackTextMax        = 3000
+	slackFieldMax       = 2000
+	slackFieldsPerBlock = 10
+)
+
+const slackRequestTimeout = 10 * time.Second
+
+// SlackNotifier posts messages to a Slack incoming webhook, as one
+// attachment colored by severity.
+type SlackNotifier struct {
+	webhookURL string
+	client     *http.Client
+}
+
+func NewSlackNotifier(webhookURL string) *SlackNotifier {
+	return &SlackNotifier{
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: slackRequestTimeout},
+	}
+}
+
+type slackPayload struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func (s *SlackNotifier) Send(msg Message) error {
+	body, err := json.Marshal(buildSlackPayload(msg))
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("slack webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("slack webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *SlackNotifier) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+func buildSlackPayload(msg Message) slackPayload {
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+
+	blocks := []slackBlock{{
+		Type: "header",
+		Text: &slackText{Type: "plain_text", Text: truncate(msg.Title, slackHeaderMax)},
+	}}
+	if msg.Description != "" {
+		blocks = append(blocks, slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: truncate(slackEscape(msg.Description), slackTextMax)},
+		})
+	}
+
+	// Slack keeps field order, so sort them for a stable layout
+	keys := make([]string, 0, len(msg.Fields))
+	for key := range msg.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for start := 0; start < len(keys); start += slackFieldsPerBlock {
+		end := min(start+slackFieldsPerBlock, len(keys))
+		fields := make([]slackText, 0, end-start)
+		for _, key := range keys[start:end] {
+			text := fmt.Sprintf("*%s*\n%s", slackEscape(key), slackEscape(msg.Fields[key]))
+			fields = append(fields, slackText{Type: "mrkdwn", Text: truncate(text, slackFieldMax)})
+		}
+		blocks = append(blocks, slackBlock{Type: "section", Fields: fields})
+	}
+
+	blocks = append(blocks, slackBlock{
+		Type:     "context",
+		Elements: []slackText{{Type: "mrkdwn", Text: msg.Timestamp.UTC().Format(time.RFC3339)}},
+	})
+
+	return slackPayload{
+		Text: msg.Title,
+		Attachments: []slackAttachment{{
+			Color:  fmt.Sprintf("#%06X", severityColor(msg.Severity)),
+			Blocks: blocks,
+		}},
+	}
+}
+
+// slackEscape escapes the characters Slack's mrkdwn reserves for links and
+// mentions.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max-3] + "..."
+}
@@ -0,0 +1,117 @@
+package notification
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlackNotifier_Send(t *testing.T) {
+	var got slackPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(body, &got))
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	fields := map[string]string{"Domain": "https://app.example.com", "Template": "<script>"}
+	for i := 0; i < 9; i++ {
+		fields[fmt.Sprintf("Extra %d", i)] = "x"
+	}
+
+	slack := NewSlackNotifier(server.URL)
+	defer slack.Close()
+	require.NoError(t, slack.Send(Message{
+		Title:       "Sensitive Endpoint Found!",
+		Description: "/.env exposed",
+		Severity:    "high",
+		Fields:      fields,
+		Timestamp:   time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+	}))
+
+	assert.Equal(t, "Sensitive Endpoint Found!", got.Text)
+	require.Len(t, got.Attachments, 1)
+	attachment := got.Attachments[0]
+	assert.Equal(t, "#FF0000", attachment.Color)
+
+	// header, description, two sections of fields, timestamp
+	require.Len(t, attachment.Blocks, 5)
+	assert.Equal(t, "Sensitive Endpoint Found!", attachment.Blocks[0].Text.Text)
+	assert.Equal(t, "/.env exposed", attachment.Blocks[1].Text.Text)
+	assert.Len(t, attachment.Blocks[2].Fields, 10)
+	assert.Equal(t, "*Domain*\nhttps://app.example.com", attachment.Blocks[2].Fields[0].Text)
+	assert.Equal(t, []slackText{{Type: "mrkdwn", Text: "*Template*\n&lt;script&gt;"}}, attachment.Blocks[3].Fields)
+	assert.Equal(t, "2024-06-01T12:00:00Z", attachment.Blocks[4].Elements[0].Text)
+}
+
+func TestSlackNotifier_SendError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := NewSlackNotifier(server.URL).Send(Message{Title: "x", Description: strings.Repeat("a", 5000)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+}
+
+type recordingNotifier struct {
+	sent   []Message
+	err    error
+	closed bool
+}
+
+func (r *recordingNotifier) Send(msg Message) error {
+	r.sent = append(r.sent, msg)
+	return r.err
+}
+
+func (r *recordingNotifier) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestMultiNotifier(t *testing.T) {
+	failing := &recordingNotifier{err: errors.New("discord unavailable")}
+	working := &recordingNotifier{}
+	multi := NewMultiNotifier(failing, working)
+
+	err := multi.Send(Message{Title: "RCE"})
+	assert.ErrorContains(t, err, "discord unavailable")
+	assert.Len(t, failing.sent, 1)
+	assert.Len(t, working.sent, 1)
+
+	assert.NoError(t, multi.Close())
+	assert.True(t, failing.closed)
+	assert.True(t, working.closed)
+}
+
+func TestNewNotifier(t *testing.T) {
+	t.Setenv("DISCORD_TOKEN", "")
+	t.Setenv(SlackWebhookEnv, "")
+	t.Setenv(WebhookNotifierURLEnv, "")
+	_, err := NewNotifier()
+	assert.Error(t, err)
+
+	t.Setenv(SlackWebhookEnv, "https://hooks.slack.com/services/T/B/X")
+	notifier, err := NewNotifier()
+	require.NoError(t, err)
+	assert.IsType(t, &SlackNotifier{}, notifier)
+
+	t.Setenv("DISCORD_TOKEN", "token")
+	notifier, err = NewNotifier()
+	require.NoError(t, err)
+	require.IsType(t, &MultiNotifier{}, notifier)
+	assert.Len(t, notifier.(*MultiNotifier).notifiers, 2)
+}
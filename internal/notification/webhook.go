@@ -0,0 +1,55 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+const (
+	SignatureHeader = "X-Pipeliner-Signature"
+	EventHeader     = "X-Pipeliner-Event"
+	DeliveryHeader  = "X-Pipeliner-Delivery"
+)
+
+// SignPayload returns the signature header value for body: "sha256=" followed
+// by the hex HMAC-SHA256 of body keyed with secret.
+func SignPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// PostWebhook sends one JSON payload. Any response outside 2xx is an error;
+// the status code is returned whenever the server answered.
+func PostWebhook(ctx context.Context, client *http.Client, url, secret, event string, deliveryID uint, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pipeliner-webhook")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(deliveryID), 10))
+	if secret != "" {
+		req.Header.Set(SignatureHeader, SignPayload(secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
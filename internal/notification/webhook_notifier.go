@@ -0,0 +1,75 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// WebhookNotifierURLEnv is where WebhookNotifier posts messages.
+	WebhookNotifierURLEnv = "PIPELINER_WEBHOOK_URL"
+	// WebhookNotifierSecretEnv signs the posted messages when set.
+	WebhookNotifierSecretEnv = "PIPELINER_WEBHOOK_SECRET"
+
+	webhookNotifierTimeout = 10 * time.Second
+	webhookNotifierRetries = 3
+	// webhookNotifierBackoff is the wait before the first retry; it doubles
+	// after each one.
+	webhookNotifierBackoff = time.Second
+	webhookNotifierEvent   = "notification"
+)
+
+// WebhookNotifier posts every message as JSON to a URL of your own, signed
+// like scan webhooks when it has a secret.
+type WebhookNotifier struct {
+	url     string
+	secret  string
+	client  *http.Client
+	backoff time.Duration
+	sent    atomic.Uint64
+}
+
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:     url,
+		secret:  secret,
+		client:  &http.Client{Timeout: webhookNotifierTimeout},
+		backoff: webhookNotifierBackoff,
+	}
+}
+
+// Send posts msg, retrying network errors and 5xx responses. Retries keep
+// the delivery header, so the receiver can drop repeats.
+func (w *WebhookNotifier) Send(msg Message) error {
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	event := webhookNotifierEvent
+	if msg.EventType != "" {
+		event = string(msg.EventType)
+	}
+	delivery := uint(w.sent.Add(1))
+
+	backoff := w.backoff
+	for attempt := 0; ; attempt++ {
+		code, err := PostWebhook(context.Background(), w.client, w.url, w.secret, event, delivery, body)
+		if err == nil || attempt == webhookNotifierRetries || (code != 0 && code < 500) {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (w *WebhookNotifier) Close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
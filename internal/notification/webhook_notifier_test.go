@@ -0,0 +1,71 @@
+package notification
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookNotifier_SignsAndRetries(t *testing.T) {
+	var attempts atomic.Int32
+	var deliveries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, SignPayload("s3cret", body), r.Header.Get(SignatureHeader))
+		assert.Equal(t, "finding", r.Header.Get(EventHeader))
+		deliveries = append(deliveries, r.Header.Get(DeliveryHeader))
+
+		var msg Message
+		assert.NoError(t, json.Unmarshal(body, &msg))
+		assert.Equal(t, "Exposed .env", msg.Title)
+		assert.Equal(t, "https://a.example.com/.env", msg.Fields["URL"])
+
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL, "s3cret")
+	notifier.backoff = time.Millisecond
+	defer notifier.Close()
+
+	require.NoError(t, notifier.Send(Message{
+		Title:     "Exposed .env",
+		Severity:  "high",
+		EventType: EventFinding,
+		Fields:    map[string]string{"URL": "https://a.example.com/.env"},
+	}))
+	assert.Equal(t, int32(3), attempts.Load())
+	assert.Equal(t, []string{"1", "1", "1"}, deliveries)
+}
+
+func TestWebhookNotifier_GivesUp(t *testing.T) {
+	for _, tt := range []struct {
+		status   int
+		attempts int32
+	}{
+		{http.StatusServiceUnavailable, 1 + webhookNotifierRetries},
+		{http.StatusUnauthorized, 1},
+	} {
+		var attempts atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			assert.Empty(t, r.Header.Get(SignatureHeader))
+			w.WriteHeader(tt.status)
+		}))
+
+		notifier := NewWebhookNotifier(server.URL, "")
+		notifier.backoff = time.Millisecond
+		assert.Error(t, notifier.Send(Message{Title: "x"}))
+		assert.Equal(t, tt.attempts, attempts.Load(), "status %d", tt.status)
+		server.Close()
+	}
+}
@@ -1,45 +1,74 @@
 package services
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"pipeliner/internal/dao"
 	"pipeliner/internal/models"
 	"pipeliner/internal/notification"
+	"pipeliner/pkg/blobstore"
+	"pipeliner/pkg/export"
 	"pipeliner/pkg/logger"
 	"pipeliner/pkg/parsers"
+	"pipeliner/pkg/scoring"
+	"pipeliner/pkg/tools"
 	"sort"
+	"strconv"
 	"strings"
-	"sync"
+	"time"
 )
 
 type ArtifactProcessor struct {
-	scanDao            dao.ScanDAO
-	logger             *logger.Logger
-	scanMutexes        *sync.Map
-	notificationClient *notification.NotificationClient
+	scanDao      dao.ScanDAO
+	subdomainDao dao.SubdomainDAO
+	logger       *logger.Logger
+	scanLocks    *ScanLocks
+	findings     *findingNotifier
+	webhooks     *webhookDispatcher
+	// store holds the artifacts of every scan under its directory's name;
+	// nil reads them from the scan directories.
+	store blobstore.Store
+	// findingDao stores nuclei results as findings; nil only keeps them in
+	// the subdomains' vulns.
+	findingDao dao.FindingDAO
+	// vulns scores findings by their CVEs; nil keeps the template scores.
+	vulns *findingEnricher
+	// severity adjusts the severity of findings; nil keeps the template's
+	// and the sensitive pattern's.
+	severity *severityPolicies
 }
 
-func newArtifactProcessor(scanDao dao.ScanDAO, logger *logger.Logger, scanMutexes *sync.Map, notifClient *notification.NotificationClient) *ArtifactProcessor {
-	return &ArtifactProcessor{
-		scanDao:            scanDao,
-		logger:             logger,
-		scanMutexes:        scanMutexes,
-		notificationClient: notifClient,
+func newArtifactProcessor(scanDao dao.ScanDAO, subdomainDao dao.SubdomainDAO, logger *logger.Logger, scanLocks *ScanLocks, notifier notification.Notifier, webhooks *webhookDispatcher) *ArtifactProcessor {
+	a := &ArtifactProcessor{
+		scanDao:      scanDao,
+		subdomainDao: subdomainDao,
+		logger:       logger,
+		scanLocks:    scanLocks,
+		webhooks:     webhooks,
 	}
+	if notifier != nil {
+		a.findings = newFindingNotifier(notifier, logger)
+	}
+	return a
 }
 
-func (a *ArtifactProcessor) getScanMutex(scanID string) *sync.Mutex {
-	value, _ := a.scanMutexes.LoadOrStore(scanID, &sync.Mutex{})
-	return value.(*sync.Mutex)
+// Close sends the sensitive endpoint notifications still waiting for their
+// window and stops the goroutine sending them.
+func (a *ArtifactProcessor) Close() {
+	if a.findings != nil {
+		a.findings.close()
+	}
 }
 
 func (a *ArtifactProcessor) UpdateArtifacts(scanID, scanDir string) {
-	mu := a.getScanMutex(scanID)
-	mu.Lock()
-	defer mu.Unlock()
+	unlock := a.scanLocks.Lock(scanID)
+	defer unlock()
 
 	scan, err := a.scanDao.GetScanByUUID(scanID)
 	if err != nil {
@@ -47,14 +76,18 @@ func (a *ArtifactProcessor) UpdateArtifacts(scanID, scanDir string) {
 		return
 	}
 
-	if err := a.saveScreenShotPaths(scan, scanDir); err != nil {
-		a.logger.Error("Failed to update screenshot paths", logger.Fields{"error": err, "scan_id": scanID})
+	before := subdomainSnapshots(scan.Subdomains)
+	if scanDir == "" {
+		a.logger.Warn("Scan directory not provided for artifact persistence", logger.Fields{"scan_id": scan.UUID})
+	} else {
+		a.processArtifacts(scan, scanDir)
 	}
 
-	if err := a.saveArtifactPaths(scan, scanDir); err != nil {
-		a.logger.Error("Failed to update artifact paths", logger.Fields{"error": err, "scan_id": scanID})
+	// only the subdomains the outputs changed are written back
+	if err := a.subdomainDao.UpsertSubdomains(scanID, changedSubdomains(before, scan.Subdomains)); err != nil {
+		a.logger.Error("Failed to persist subdomain results", logger.Fields{"error": err, "scan_id": scanID})
+		return
 	}
-
 	if err := a.scanDao.UpdateScan(scan); err != nil {
 		a.logger.Error("Failed to persist artifact update", logger.Fields{"error": err, "scan_id": scanID})
 		return
@@ -63,62 +96,119 @@ func (a *ArtifactProcessor) UpdateArtifacts(scanID, scanDir string) {
 	a.logger.Info("Updated artifact paths", logger.Fields{"scan_id": scanID})
 }
 
-func (a *ArtifactProcessor) saveScreenShotPaths(scan *models.Scan, scanDir string) error {
-	if scanDir == "" {
-		a.logger.Warn("Scan directory not provided for screenshot persistence", logger.Fields{"scan_id": scan.UUID})
+// processArtifacts parses the outputs in scanDir into scan.
+func (a *ArtifactProcessor) processArtifacts(scan *models.Scan, scanDir string) {
+	store := a.scanStore(scanDir)
+	if err := a.saveScreenShotPaths(scan, store); err != nil {
+		a.logger.Error("Failed to update screenshot paths", logger.Fields{"error": err, "scan_id": scan.UUID})
+	}
+	a.saveToolLogPaths(scan, store)
+	a.saveArtifactPaths(scan, store, scanDir)
+}
+
+// subdomainSnapshots encodes each subdomain so changedSubdomains can tell
+// which ones the parsers touched. The parsers append to and sort the
+// subdomains' slices in place, so a shallow copy would change with them.
+func subdomainSnapshots(subdomains []models.Subdomain) [][]byte {
+	snapshots := make([][]byte, len(subdomains))
+	for i := range subdomains {
+		snapshots[i], _ = json.Marshal(&subdomains[i])
+	}
+	return snapshots
+}
+
+// changedSubdomains returns the subdomains that differ from their
+// snapshot. The parsers never add or remove subdomains.
+func changedSubdomains(before [][]byte, subdomains []models.Subdomain) []models.Subdomain {
+	var changed []models.Subdomain
+	for i := range subdomains {
+		after, _ := json.Marshal(&subdomains[i])
+		if i >= len(before) || !bytes.Equal(before[i], after) {
+			changed = append(changed, subdomains[i])
+		}
+	}
+	return changed
+}
+
+// scanStore is the store of one scan's artifacts.
+func (a *ArtifactProcessor) scanStore(scanDir string) blobstore.Store {
+	if a.store == nil {
+		return blobstore.Prefixed(blobstore.NewLocal(filepath.Dir(scanDir)), filepath.Base(scanDir))
+	}
+	return blobstore.Prefixed(a.store, filepath.Base(scanDir))
+}
+
+// localCopy fetches the artifact at key for the parsers, which read files.
+// ok is false when there is no such artifact.
+func (a *ArtifactProcessor) localCopy(store blobstore.Store, key string) (file string, cleanup func(), ok bool) {
+	file, cleanup, err := blobstore.LocalCopy(context.Background(), store, key)
+	if err != nil {
+		if !errors.Is(err, blobstore.ErrNotFound) {
+			a.logger.Error("Failed to fetch artifact", logger.Fields{"error": err, "file": key})
+		}
+		return "", nil, false
+	}
+	return file, cleanup, true
+}
+
+// matchArtifacts lists the artifacts in dir whose name matches pattern.
+func (a *ArtifactProcessor) matchArtifacts(store blobstore.Store, dir, pattern string) []blobstore.Object {
+	objects, err := blobstore.Match(context.Background(), store, dir, pattern)
+	if err != nil {
+		a.logger.Error("Failed to list artifacts", logger.Fields{"error": err, "pattern": pattern})
 		return nil
 	}
+	return objects
+}
 
+// saveScreenShotPaths lists the scan's screenshots and gives each subdomain
+// the one a screenshot tool's report took of it or, failing that, the one
+// named after its host.
+func (a *ArtifactProcessor) saveScreenShotPaths(scan *models.Scan, store blobstore.Store) error {
 	patterns := []string{"*.jpeg", "*.jpg", "*.png"}
 	seen := make(map[string]struct{})
 	var paths []string
-	scanDirName := filepath.Base(scanDir)
 
-	for _, pattern := range patterns {
-		matches, err := filepath.Glob(filepath.Join(scanDir, pattern))
-		if err != nil {
-			a.logger.Error("Failed to glob screenshot files", logger.Fields{"error": err, "pattern": pattern, "scan_dir": scanDir})
-			continue
-		}
-		for _, match := range matches {
-			filename := filepath.Base(match)
-			if filename == "" {
-				continue
-			}
-			key := strings.ToLower(filename)
-			if _, exists := seen[key]; exists {
-				continue
+	for _, dir := range screenshotDirs {
+		for _, pattern := range patterns {
+			for _, object := range a.matchArtifacts(store, dir, pattern) {
+				key := strings.ToLower(object.Key)
+				if _, exists := seen[key]; exists {
+					continue
+				}
+				seen[key] = struct{}{}
+				paths = append(paths, store.URI(object.Key))
 			}
-			seen[key] = struct{}{}
-			relative := filepath.Join(scanDirName, filename)
-			paths = append(paths, relative)
 		}
 	}
 
 	sort.Strings(paths)
+	reported := a.reportedScreenshots(store, paths)
 
 	for i := range scan.Subdomains {
-		domainName := strings.TrimPrefix(scan.Subdomains[i].Domain, "https://")
-		domainName = strings.TrimPrefix(domainName, "http://")
-
-		for _, screenshotPath := range paths {
-			filename := filepath.Base(screenshotPath)
-			filenameWithoutExt := strings.TrimSuffix(filename, filepath.Ext(filename))
-
-			if strings.Contains(filenameWithoutExt, domainName) || strings.Contains(domainName, filenameWithoutExt) {
-				scan.Subdomains[i].Screenshot = screenshotPath
-				a.logger.Debug("Mapped screenshot to subdomain", logger.Fields{
-					"subdomain":  scan.Subdomains[i].Domain,
-					"screenshot": screenshotPath,
-				})
-				break
+		host := screenshotHost(scan.Subdomains[i].Domain)
+
+		screenshot, ok := reported[host]
+		if !ok {
+			for _, screenshotPath := range paths {
+				if screenshotMatchesHost(path.Base(screenshotPath), host) {
+					screenshot, ok = screenshotPath, true
+					break
+				}
 			}
 		}
+		if ok {
+			scan.Subdomains[i].Screenshot = screenshot
+			a.logger.Debug("Mapped screenshot to subdomain", logger.Fields{
+				"subdomain":  scan.Subdomains[i].Domain,
+				"screenshot": screenshot,
+			})
+		}
 	}
 
 	encoded, err := json.Marshal(paths)
 	if err != nil {
-		a.logger.Error("Failed to encode screenshot paths", logger.Fields{"error": err, "scan_dir": scanDir})
+		a.logger.Error("Failed to encode screenshot paths", logger.Fields{"error": err, "scan_id": scan.UUID})
 		return err
 	}
 
@@ -126,25 +216,79 @@ func (a *ArtifactProcessor) saveScreenShotPaths(scan *models.Scan, scanDir strin
 	return nil
 }
 
-func (a *ArtifactProcessor) saveArtifactPaths(scan *models.Scan, scanDir string) error {
-	if scanDir == "" {
-		a.logger.Warn("Scan directory not provided for artifact persistence", logger.Fields{"scan_id": scan.UUID})
-		return nil
+// saveToolLogPaths lists the per-tool logs, by URI like the screenshots.
+func (a *ArtifactProcessor) saveToolLogPaths(scan *models.Scan, store blobstore.Store) {
+	objects := a.matchArtifacts(store, logger.ToolLogDir, "*.log")
+	if len(objects) == 0 {
+		return
 	}
 
-	a.processNmapOutput(scan, scanDir)
-	a.processFfufOutput(scan, scanDir)
-	a.processNucleiOutput(scan, scanDir)
+	logs := make(map[string]string, len(objects))
+	for _, object := range objects {
+		logs[strings.TrimSuffix(path.Base(object.Key), ".log")] = store.URI(object.Key)
+	}
+	scan.ToolLogs = logs
+}
 
-	return nil
+func (a *ArtifactProcessor) saveArtifactPaths(scan *models.Scan, store blobstore.Store, scanDir string) {
+	a.processHttpxOutput(scan, store)
+	a.processNmapOutput(scan, store)
+	// after nmap, which replaces the open ports it confirms
+	a.processNaabuOutput(scan, store)
+	a.processFfufOutput(scan, store, scanDir)
+	a.processNucleiOutput(scan, store)
 }
 
-func (a *ArtifactProcessor) processNmapOutput(scan *models.Scan, scanDir string) {
-	nmapPath := filepath.Join(scanDir, "nmap_output.xml")
-	if _, err := os.Stat(nmapPath); err != nil {
+// processHttpxOutput adds what httpx -json saw to the subdomains it probed.
+// The plain httpx_output.txt the monitor tails has the URLs only; the
+// monitor also moves the probed subdomains to alive.
+func (a *ArtifactProcessor) processHttpxOutput(scan *models.Scan, store blobstore.Store) {
+	httpxPath, cleanup, ok := a.localCopy(store, "httpx_output.json")
+	if !ok {
+		return
+	}
+	defer cleanup()
+
+	a.logger.Info("Found httpx output, parsing...", logger.Fields{"scan_id": scan.UUID, "file": httpxPath})
+
+	httpxParser := parsers.NewHttpxParser()
+	result, err := httpxParser.Parse(httpxPath)
+	if err != nil {
+		a.logger.Error("Failed to parse httpx output", logger.Fields{"error": err, "file": httpxPath})
 		return
 	}
 
+	results, ok := result["results"].([]parsers.HttpxResult)
+	if !ok || len(results) == 0 {
+		return
+	}
+
+	enriched := 0
+	for _, r := range results {
+		i := subdomainIndexForValue(scan, r.URL)
+		if i < 0 && r.Input != "" {
+			i = subdomainIndexForValue(scan, r.Input)
+		}
+		if i < 0 {
+			continue
+		}
+		scan.Subdomains[i].StatusCode = r.StatusCode
+		scan.Subdomains[i].Title = r.Title
+		scan.Subdomains[i].Technologies = r.Tech
+		scan.Subdomains[i].ContentLength = r.ContentLength
+		enriched++
+	}
+
+	a.logger.Info("Processed httpx results", logger.Fields{"scan_id": scan.UUID, "results": len(results), "enriched": enriched})
+}
+
+func (a *ArtifactProcessor) processNmapOutput(scan *models.Scan, store blobstore.Store) {
+	nmapPath, cleanup, ok := a.localCopy(store, "nmap_output.xml")
+	if !ok {
+		return
+	}
+	defer cleanup()
+
 	a.logger.Info("Found nmap output, parsing...", logger.Fields{"scan_id": scan.UUID, "file": nmapPath})
 
 	nmapParser := parsers.NewNmapParser()
@@ -228,116 +372,347 @@ func (a *ArtifactProcessor) processNmapOutput(scan *models.Scan, scanDir string)
 	}
 }
 
-func (a *ArtifactProcessor) processFfufOutput(scan *models.Scan, scanDir string) {
-	ffufMatches, err := filepath.Glob(filepath.Join(scanDir, "*_ffuf_output.json"))
-	if err != nil {
-		a.logger.Error("Failed to glob ffuf files", logger.Fields{"error": err, "scan_dir": scanDir})
-		return
-	}
+// naabuOutputFiles are where naabu's plain and -json output are looked for.
+var naabuOutputFiles = []string{"naabu_output.txt", "naabu_output.json"}
 
-	for _, ffufPath := range ffufMatches {
-		a.logger.Info("Found ffuf output, parsing...", logger.Fields{"scan_id": scan.UUID, "file": ffufPath})
+// processNaabuOutput adds the ports naabu found to the subdomains' open
+// ports. Ports nmap already reported are left as nmap described them; the
+// rest are marked unverified.
+func (a *ArtifactProcessor) processNaabuOutput(scan *models.Scan, store blobstore.Store) {
+	for _, name := range naabuOutputFiles {
+		naabuPath, cleanup, ok := a.localCopy(store, name)
+		if !ok {
+			continue
+		}
+
+		a.logger.Info("Found naabu output, parsing...", logger.Fields{"scan_id": scan.UUID, "file": naabuPath})
 
-		ffufParser := parsers.NewFuffParser()
-		result, err := ffufParser.Parse(ffufPath)
+		naabuParser := parsers.NewNaabuParser()
+		result, err := naabuParser.Parse(naabuPath)
+		cleanup()
 		if err != nil {
-			a.logger.Error("Failed to parse ffuf output", logger.Fields{"error": err, "file": ffufPath})
+			a.logger.Error("Failed to parse naabu output", logger.Fields{"error": err, "file": naabuPath})
 			continue
 		}
 
-		results, ok := result["results"].([]parsers.FuffResult)
+		results, ok := result["results"].([]parsers.NaabuResult)
 		if !ok {
 			continue
 		}
 
-		filename := filepath.Base(ffufPath)
-		a.logger.Info("Successfully parsed ffuf output", logger.Fields{
-			"file":          filename,
-			"total_results": len(results),
-		})
-
-		var patternsFile string
-		if scan.SensitivePatterns != "" {
-			tmpFile := filepath.Join(os.TempDir(), fmt.Sprintf("patterns_%s.txt", scan.UUID))
-			if err := os.WriteFile(tmpFile, []byte(scan.SensitivePatterns), 0644); err != nil {
-				a.logger.WithError(err).Warn("Failed to write temp patterns file")
-			} else {
-				patternsFile = tmpFile
-				defer os.Remove(tmpFile)
+		added := 0
+		for _, r := range results {
+			i := subdomainIndexForValue(scan, r.Host)
+			if i < 0 {
+				continue
+			}
+			if mergeUnverifiedPort(&scan.Subdomains[i], r.Port, r.Protocol) {
+				added++
+			}
+		}
+
+		a.logger.Info("Processed naabu results", logger.Fields{"scan_id": scan.UUID, "file": name, "results": len(results), "added": added})
+	}
+}
+
+// mergeUnverifiedPort adds port/protocol to the subdomain's open ports
+// unless nmap reported it, open or likely false, and keeps the ports sorted
+// by number.
+func mergeUnverifiedPort(subdomain *models.Subdomain, port int, protocol string) bool {
+	key := fmt.Sprintf("%d/%s", port, protocol)
+	for _, known := range [][]string{subdomain.OpenPorts, subdomain.PotentialFalsePorts} {
+		for _, entry := range known {
+			if portKey(entry) == key {
+				return false
 			}
 		}
+	}
+
+	subdomain.OpenPorts = append(subdomain.OpenPorts, key+" (unverified)")
+	sort.SliceStable(subdomain.OpenPorts, func(i, j int) bool {
+		return portNumber(subdomain.OpenPorts[i]) < portNumber(subdomain.OpenPorts[j])
+	})
+	return true
+}
+
+// portKey is the "443/tcp" of an open port entry like "443/tcp (https)".
+func portKey(entry string) string {
+	key, _, _ := strings.Cut(entry, " ")
+	return key
+}
 
+func portNumber(entry string) int {
+	number, _, _ := strings.Cut(entry, "/")
+	n, _ := strconv.Atoi(number)
+	return n
+}
+
+func (a *ArtifactProcessor) processFfufOutput(scan *models.Scan, store blobstore.Store, scanDir string) {
+	var patternsFile string
+	if scan.SensitivePatterns != "" {
+		tmpFile := filepath.Join(os.TempDir(), fmt.Sprintf("patterns_%s.txt", scan.UUID))
+		if err := os.WriteFile(tmpFile, []byte(scan.SensitivePatterns), 0644); err != nil {
+			a.logger.WithError(err).Warn("Failed to write temp patterns file")
+		} else {
+			patternsFile = tmpFile
+			defer os.Remove(tmpFile)
+		}
+	}
+
+	// tools declaring output_per_value leave a manifest, so no filename guessing is needed
+	if manifests := a.outputManifests(store, "ffuf"); len(manifests) > 0 {
+		for _, manifest := range manifests {
+			for value, file := range manifest.Outputs {
+				key, ok := artifactKey(scanDir, file)
+				if !ok {
+					continue
+				}
+				results, ok := a.parseFfufArtifact(scan, store, key)
+				if !ok {
+					continue
+				}
+				if i := subdomainIndexForValue(scan, value); i >= 0 {
+					a.addFfufResults(scan, i, results, patternsFile)
+				}
+			}
+		}
+		return
+	}
+
+	for _, object := range a.matchArtifacts(store, "", "*_ffuf_output.json") {
+		results, ok := a.parseFfufArtifact(scan, store, object.Key)
+		if !ok {
+			continue
+		}
+
+		filename := path.Base(object.Key)
 		for i := range scan.Subdomains {
 			domainClean := strings.Replace(scan.Subdomains[i].Domain, "://", ".", -1)
 			domainClean = strings.Replace(domainClean, "https.", "", -1)
 			domainClean = strings.Replace(domainClean, "http.", "", -1)
 
 			if strings.HasPrefix(filename, domainClean+"_") {
-				addedCount := 0
-				sensitiveCount := 0
-				for _, r := range results {
-					if r.Status >= 200 && r.Status < 400 {
-						pathInfo := fmt.Sprintf("%s [%d]", r.URL, r.Status)
-
-						found := false
-						for _, existing := range scan.Subdomains[i].DirFuzzing {
-							if existing == pathInfo {
-								found = true
-								break
-							}
-						}
-						if !found {
-							scan.Subdomains[i].DirFuzzing = append(scan.Subdomains[i].DirFuzzing, pathInfo)
-							addedCount++
-
-							if sensitivePattern, found := parsers.DetectSensitivePattern(r.URL, patternsFile); found {
-								sensitiveCount++
-								a.logger.Warn("Sensitive endpoint detected!", logger.Fields{
-									"url":         r.URL,
-									"status":      r.Status,
-									"severity":    sensitivePattern.Severity,
-									"description": sensitivePattern.Description,
-									"category":    sensitivePattern.Category,
-								})
-
-								if a.notificationClient != nil {
-									emoji := parsers.GetSeverityEmoji(sensitivePattern.Severity)
-									msg := notification.Message{
-										Title:       fmt.Sprintf("%s Sensitive Endpoint Found!", emoji),
-										Description: fmt.Sprintf("**%s**\n`%s` [%d]", sensitivePattern.Description, r.URL, r.Status),
-										Severity:    sensitivePattern.Severity,
-										Fields: map[string]string{
-											"Category": sensitivePattern.Category,
-											"Pattern":  sensitivePattern.Pattern,
-											"Domain":   scan.Subdomains[i].Domain,
-											"Status":   fmt.Sprintf("%d", r.Status),
-										},
-									}
-									if err := a.notificationClient.Send(msg); err != nil {
-										a.logger.WithError(err).Error("Failed to send sensitive finding notification")
-									}
-								}
-							}
-						}
+				a.addFfufResults(scan, i, results, patternsFile)
+				break
+			}
+		}
+	}
+}
+
+// artifactKey is the key of a file a manifest names, relative to the scan
+// directory or absolute within it.
+func artifactKey(scanDir, file string) (string, bool) {
+	if filepath.IsAbs(file) {
+		rel, err := filepath.Rel(scanDir, file)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return "", false
+		}
+		file = rel
+	}
+	return filepath.ToSlash(filepath.Clean(file)), true
+}
+
+// outputManifests loads the output_per_value manifests of the scan written
+// by tools whose name starts with toolPrefix, e.g. "ffuf" or "ffuf-directories".
+func (a *ArtifactProcessor) outputManifests(store blobstore.Store, toolPrefix string) []*tools.OutputManifest {
+	var manifests []*tools.OutputManifest
+	for _, object := range a.matchArtifacts(store, "", tools.OutputManifestFile(toolPrefix+"*")) {
+		file, cleanup, ok := a.localCopy(store, object.Key)
+		if !ok {
+			continue
+		}
+		manifest, err := tools.ReadOutputManifest(file)
+		cleanup()
+		if err != nil {
+			a.logger.Warn("Ignoring unreadable output manifest", logger.Fields{"error": err, "file": object.Key})
+			continue
+		}
+		// tools run once record only Files, found by the filename fallback
+		if len(manifest.Outputs) == 0 {
+			continue
+		}
+		manifests = append(manifests, manifest)
+	}
+	return manifests
+}
+
+func (a *ArtifactProcessor) parseFfufArtifact(scan *models.Scan, store blobstore.Store, key string) ([]parsers.FuffResult, bool) {
+	file, cleanup, ok := a.localCopy(store, key)
+	if !ok {
+		return nil, false
+	}
+	defer cleanup()
+	return a.parseFfufResults(scan, file)
+}
+
+func (a *ArtifactProcessor) parseFfufResults(scan *models.Scan, ffufPath string) ([]parsers.FuffResult, bool) {
+	a.logger.Info("Found ffuf output, parsing...", logger.Fields{"scan_id": scan.UUID, "file": ffufPath})
+
+	ffufParser := parsers.NewFuffParser()
+	result, err := ffufParser.Parse(ffufPath)
+	if err != nil {
+		a.logger.Error("Failed to parse ffuf output", logger.Fields{"error": err, "file": ffufPath})
+		return nil, false
+	}
+
+	results, ok := result["results"].([]parsers.FuffResult)
+	if !ok {
+		return nil, false
+	}
+
+	a.logger.Info("Successfully parsed ffuf output", logger.Fields{
+		"file":          filepath.Base(ffufPath),
+		"total_results": len(results),
+	})
+	return results, true
+}
+
+func (a *ArtifactProcessor) addFfufResults(scan *models.Scan, i int, results []parsers.FuffResult, patternsFile string) {
+	addedCount := 0
+	sensitiveCount := 0
+	suppressedCount := 0
+	filters := scan.SensitiveFilters
+	var baseline int
+	var hasBaseline bool
+	if filters.Soft404 {
+		baseline, hasBaseline = soft404Baseline(results)
+	}
+	policy := a.severity.forScan(scan.UUID)
+	var external []models.Redirect
+	for _, r := range results {
+		if r.Status >= 200 && r.Status < 400 {
+			pathInfo := fmt.Sprintf("%s [%d]", r.URL, r.Status)
+
+			found := false
+			for _, existing := range scan.Subdomains[i].DirFuzzing {
+				if existing == pathInfo {
+					found = true
+					break
+				}
+			}
+			if !found {
+				scan.Subdomains[i].DirFuzzing = append(scan.Subdomains[i].DirFuzzing, pathInfo)
+				addedCount++
+				if redirect, ok := newRedirect(r); ok {
+					scan.Subdomains[i].Redirects = append(scan.Subdomains[i].Redirects, redirect)
+					if redirect.External {
+						external = append(external, redirect)
+					}
+				}
+
+				if sensitivePattern, found := parsers.DetectSensitivePattern(r.URL, patternsFile); found {
+					sensitiveCount++
+					reason := suppressReason(filters, r, baseline, hasBaseline)
+					score := policy.Apply(scoring.Subject{
+						Host:     r.URL,
+						Tool:     "ffuf",
+						Category: sensitivePattern.Category,
+					}, sensitivePattern.Severity)
+					originalSeverity := sensitivePattern.Severity
+					sensitivePattern.Severity = score.Severity
+					scan.Subdomains[i].Sensitive = append(scan.Subdomains[i].Sensitive, models.SensitiveFinding{
+						URL:              r.URL,
+						Status:           r.Status,
+						Length:           r.Length,
+						Severity:         score.Severity,
+						OriginalSeverity: originalSeverity,
+						Labels:           score.Labels,
+						Description:      sensitivePattern.Description,
+						Category:         sensitivePattern.Category,
+						Pattern:          sensitivePattern.Pattern,
+						Alerted:          reason == "",
+						Suppressed:       reason,
+					})
+					if reason != "" {
+						suppressedCount++
+						a.logger.Info("Sensitive endpoint suppressed", logger.Fields{"url": r.URL, "reason": reason})
+						continue
+					}
+
+					a.logger.Warn("Sensitive endpoint detected!", logger.Fields{
+						"url":         r.URL,
+						"status":      r.Status,
+						"severity":    sensitivePattern.Severity,
+						"description": sensitivePattern.Description,
+						"category":    sensitivePattern.Category,
+					})
+
+					if a.findings != nil {
+						a.findings.notify(sensitiveHit{
+							scanID:  scan.UUID,
+							domain:  scan.Subdomains[i].Domain,
+							url:     r.URL,
+							status:  r.Status,
+							pattern: sensitivePattern,
+							labels:  score.Labels,
+						})
 					}
 				}
-				a.logger.Info("Added ffuf results to subdomain", logger.Fields{
-					"subdomain": scan.Subdomains[i].Domain,
-					"added":     addedCount,
-					"sensitive": sensitiveCount,
-					"total":     len(scan.Subdomains[i].DirFuzzing),
-				})
-				break
 			}
 		}
 	}
+	a.addRedirectFindings(scan, i, external)
+	a.logger.Info("Added ffuf results to subdomain", logger.Fields{
+		"subdomain":  scan.Subdomains[i].Domain,
+		"added":      addedCount,
+		"sensitive":  sensitiveCount,
+		"suppressed": suppressedCount,
+		"total":      len(scan.Subdomains[i].DirFuzzing),
+	})
+}
+
+// subdomainIndexForValue finds the subdomain a replacement value (a host or
+// URL) was run against, ignoring scheme and trailing slash.
+func subdomainIndexForValue(scan *models.Scan, value string) int {
+	host := hostOf(value)
+	for i := range scan.Subdomains {
+		if hostOf(scan.Subdomains[i].Domain) == host {
+			return i
+		}
+	}
+	return -1
+}
+
+func hostOf(value string) string {
+	if _, rest, ok := strings.Cut(value, "://"); ok {
+		value = rest
+	}
+	return strings.ToLower(strings.TrimRight(value, "/"))
+}
+
+// newFinding is a nuclei result as a finding of host, until it is matched
+// to one of the scan's subdomains. Its severity is the template's until the
+// severity rules run.
+func newFinding(result parsers.NucleiResult, host string) models.Finding {
+	var timestamp int64
+	if t, err := time.Parse(time.RFC3339Nano, result.Timestamp); err == nil {
+		timestamp = t.Unix()
+	}
+	severity := parsers.GetNucleiSeverity(result.Info)
+	finding := models.Finding{
+		Subdomain:        host,
+		TemplateID:       result.TemplateID,
+		Name:             parsers.GetNucleiTemplateName(result.Info),
+		Severity:         severity,
+		OriginalSeverity: severity,
+		MatchedAt:        result.MatchedAt,
+		Description:      parsers.GetNucleiDescription(result.Info),
+		Tags:             parsers.GetNucleiTagList(result.Info),
+		CVEs:             parsers.GetNucleiCVEs(result.Info),
+		Timestamp:        timestamp,
+	}
+	finding.CVSSScore, _ = parsers.GetNucleiClassificationScore(result.Info, "cvss-score")
+	finding.EPSSScore, _ = parsers.GetNucleiClassificationScore(result.Info, "epss-score")
+	finding.EPSSPercentile, _ = parsers.GetNucleiClassificationScore(result.Info, "epss-percentile")
+	return finding
 }
 
-func (a *ArtifactProcessor) processNucleiOutput(scan *models.Scan, scanDir string) {
-	nucleiPath := filepath.Join(scanDir, "nuclei_output.json")
-	if _, err := os.Stat(nucleiPath); err != nil {
+func (a *ArtifactProcessor) processNucleiOutput(scan *models.Scan, store blobstore.Store) {
+	nucleiPath, cleanup, ok := a.localCopy(store, "nuclei_output.json")
+	if !ok {
 		return
 	}
+	defer cleanup()
 
 	a.logger.Info("Found nuclei output, parsing...", logger.Fields{"scan_id": scan.UUID, "file": nucleiPath})
 
@@ -355,22 +730,34 @@ func (a *ArtifactProcessor) processNucleiOutput(scan *models.Scan, scanDir strin
 	}
 
 	a.logger.Info("Processing nuclei results", logger.Fields{"scan_id": scan.UUID, "result_count": len(results)})
+	if a.vulns != nil {
+		a.vulns.load()
+	}
 
+	policy := a.severity.forScan(scan.UUID)
+	findings := make([]models.Finding, 0, len(results))
 	for _, nucleiResult := range results {
 		host := nucleiResult.Host
 		if host == "" {
 			host = nucleiResult.URL
 		}
 
-		severity := parsers.GetNucleiSeverity(nucleiResult.Info)
 		templateName := parsers.GetNucleiTemplateName(nucleiResult.Info)
+		finding := newFinding(nucleiResult, hostOf(host))
+		if a.vulns != nil {
+			a.vulns.enrich(&finding)
+		}
+		score := policy.Apply(scoring.Subject{Host: host, Tool: "nuclei", Tags: finding.Tags}, finding.Severity)
+		finding.Severity, finding.Labels = score.Severity, score.Labels
+		severity := finding.Severity
 
 		for i := range scan.Subdomains {
 			subdomainHost := strings.TrimPrefix(scan.Subdomains[i].Domain, "https://")
 			subdomainHost = strings.TrimPrefix(subdomainHost, "http://")
 
 			if strings.Contains(host, subdomainHost) || strings.Contains(nucleiResult.URL, subdomainHost) {
-				vulnEntry := fmt.Sprintf("[%s] %s - %s", strings.ToUpper(severity), templateName, nucleiResult.MatchedAt)
+				finding.Subdomain = scan.Subdomains[i].Domain
+				vulnEntry := export.FormatVuln(severity, templateName, nucleiResult.MatchedAt)
 
 				found := false
 				for _, existing := range scan.Subdomains[i].Vulns {
@@ -382,10 +769,33 @@ func (a *ArtifactProcessor) processNucleiOutput(scan *models.Scan, scanDir strin
 
 				if !found {
 					scan.Subdomains[i].Vulns = append(scan.Subdomains[i].Vulns, vulnEntry)
+					if scan.SeverityCounts == nil {
+						scan.SeverityCounts = make(map[string]int)
+					}
+					scan.SeverityCounts[strings.ToLower(severity)]++
+					if a.webhooks != nil && strings.EqualFold(severity, "critical") {
+						a.webhooks.criticalFinding(scan.UUID, webhookFinding{
+							Host:      host,
+							Template:  templateName,
+							Severity:  severity,
+							MatchedAt: nucleiResult.MatchedAt,
+						})
+					}
 				}
 				break
 			}
 		}
+		findings = append(findings, finding)
+	}
+
+	if a.findingDao != nil {
+		// the whole output is parsed on every update; known findings are skipped
+		added, err := a.findingDao.AddFindings(scan.UUID, findings)
+		if err != nil {
+			a.logger.Error("Failed to store nuclei findings", logger.Fields{"error": err, "scan_id": scan.UUID})
+		} else if added > 0 {
+			a.logger.Debug("Stored nuclei findings", logger.Fields{"scan_id": scan.UUID, "added": added})
+		}
 	}
 
 	a.logger.Info("Processed nuclei results", logger.Fields{
@@ -0,0 +1,408 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+	"pipeliner/internal/vulndb"
+	"pipeliner/pkg/blobstore"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/tools"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifactProcessor_FfufManifestAttribution(t *testing.T) {
+	scanDir := t.TempDir()
+
+	// names that the filename heuristic could never attribute
+	require.NoError(t, os.WriteFile(filepath.Join(scanDir, "run_1.json"),
+		[]byte(`{"results":[{"url":"https://a.example.com/admin","status":200}]}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(scanDir, "run_2.json"),
+		[]byte(`{"results":[{"url":"https://b.example.com/.git","status":301},{"url":"https://b.example.com/missing","status":404}]}`), 0644))
+	require.NoError(t, tools.WriteOutputManifest(filepath.Join(scanDir, tools.OutputManifestFile("ffuf-directories")), &tools.OutputManifest{
+		Tool: "ffuf-directories",
+		Outputs: map[string]string{
+			"https://a.example.com":  "run_1.json",
+			"https://b.example.com/": "run_2.json",
+		},
+	}))
+
+	scan := &models.Scan{
+		UUID: "scan-1",
+		Subdomains: []models.Subdomain{
+			{Domain: "a.example.com"},
+			{Domain: "https://b.example.com"},
+		},
+	}
+
+	a := newArtifactProcessor(nil, nil, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), nil, nil)
+	a.processFfufOutput(scan, a.scanStore(scanDir), scanDir)
+
+	assert.Equal(t, []string{"https://a.example.com/admin [200]"}, scan.Subdomains[0].DirFuzzing)
+	assert.Equal(t, []string{"https://b.example.com/.git [301]"}, scan.Subdomains[1].DirFuzzing)
+}
+
+func TestArtifactProcessor_NucleiSeverityCounts(t *testing.T) {
+	scanDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scanDir, "nuclei_output.json"), []byte(
+		`{"template-id":"panel","info":{"name":"exposed-panel","severity":"high"},"host":"a.example.com","matched-at":"https://a.example.com/admin"}
+{"template-id":"git","info":{"name":"git-config","severity":"medium"},"host":"a.example.com","matched-at":"https://a.example.com/.git/config"}
+{"template-id":"cors","info":{"name":"cors","severity":"high"},"host":"b.example.com","matched-at":"https://b.example.com/"}
+`), 0644))
+
+	scan := &models.Scan{
+		UUID:       "scan-1",
+		Subdomains: []models.Subdomain{{Domain: "a.example.com"}, {Domain: "https://b.example.com"}},
+	}
+
+	a := newArtifactProcessor(nil, nil, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), nil, nil)
+	a.processNucleiOutput(scan, a.scanStore(scanDir))
+	// reprocessing the same output must not count findings twice
+	a.processNucleiOutput(scan, a.scanStore(scanDir))
+
+	assert.Equal(t, map[string]int{"high": 2, "medium": 1}, scan.SeverityCounts)
+}
+
+func TestArtifactProcessor_NucleiFindings(t *testing.T) {
+	scanDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scanDir, "nuclei_output.json"), []byte(
+		`{"template-id":"env-file","info":{"name":"Env File","severity":"critical","description":" Exposed .env ","tags":["exposure","config"]},"host":"a.example.com","matched-at":"https://a.example.com/.env","timestamp":"2024-06-01T12:00:00.123456789Z"}
+{"template-id":"tech-detect","info":{"name":"Tech","severity":"info","tags":"tech, nginx"},"host":"other.example.net","matched-at":"https://other.example.net/"}
+`), 0644))
+
+	db := newTestDB(t)
+	findingDao := dao.NewFindingDAO(db)
+	scan := &models.Scan{UUID: "scan-1", Subdomains: []models.Subdomain{{Domain: "https://a.example.com"}}}
+
+	a := newArtifactProcessor(nil, nil, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), nil, nil)
+	a.findingDao = findingDao
+	a.processNucleiOutput(scan, a.scanStore(scanDir))
+	a.processNucleiOutput(scan, a.scanStore(scanDir))
+
+	findings, err := findingDao.ListFindings("scan-1", models.FindingFilter{}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, findings, 2)
+	assert.Equal(t, models.Finding{
+		ID: 1, ScanID: "scan-1", Subdomain: "https://a.example.com", TemplateID: "env-file", Name: "Env File",
+		Severity: "critical", OriginalSeverity: "critical", MatchedAt: "https://a.example.com/.env", Description: "Exposed .env",
+		Tags: []string{"exposure", "config"}, Timestamp: 1717243200,
+	}, findings[0])
+	// findings on hosts that are not subdomains of the scan are kept too
+	assert.Equal(t, "other.example.net", findings[1].Subdomain)
+	assert.Equal(t, []string{"tech", "nginx"}, findings[1].Tags)
+	assert.Zero(t, findings[1].Timestamp)
+
+	// the legacy list is still filled
+	assert.Equal(t, []string{"[CRITICAL] Env File - https://a.example.com/.env"}, scan.Subdomains[0].Vulns)
+}
+
+func TestArtifactProcessor_NucleiFindingScores(t *testing.T) {
+	scanDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scanDir, "nuclei_output.json"), []byte(
+		`{"template-id":"log4j","info":{"name":"Log4Shell","severity":"critical","classification":{"cve-id":["cve-2021-44228","CVE-2021-45046"],"cvss-score":9.8,"epss-score":0.5}},"host":"a.example.com","matched-at":"https://a.example.com/"}
+{"template-id":"old-cve","info":{"name":"Old","severity":"high","classification":{"cve-id":"CVE-2017-5638","cvss-score":"10","epss-score":0.97,"epss-percentile":0.99}},"host":"a.example.com","matched-at":"https://a.example.com/struts"}
+{"template-id":"panel","info":{"name":"Panel","severity":"info"},"host":"a.example.com","matched-at":"https://a.example.com/admin"}
+`), 0644))
+
+	db := newTestDB(t)
+	findingDao := dao.NewFindingDAO(db)
+	scan := &models.Scan{UUID: "scan-1", Subdomains: []models.Subdomain{{Domain: "a.example.com"}}}
+	vulnDBPath := filepath.Join(t.TempDir(), "vulndb.json.gz")
+
+	a := newArtifactProcessor(nil, nil, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), nil, nil)
+	a.findingDao = findingDao
+	a.vulns = newFindingEnricher(vulndb.NewCache(vulnDBPath), a.logger)
+
+	// without a dataset the template's scores are kept
+	a.processNucleiOutput(scan, a.scanStore(scanDir))
+	assert.True(t, a.vulns.warned.Load())
+	findings, err := findingDao.ListFindings("scan-1", models.FindingFilter{Sort: models.FindingSortEPSS}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, findings, 3)
+	assert.Equal(t, "old-cve", findings[0].TemplateID)
+	assert.Equal(t, []string{"CVE-2017-5638"}, findings[0].CVEs)
+	assert.Equal(t, 10.0, findings[0].CVSSScore)
+	assert.Equal(t, 0.99, findings[0].EPSSPercentile)
+	assert.Equal(t, []string{"CVE-2021-44228", "CVE-2021-45046"}, findings[1].CVEs)
+	assert.Equal(t, 0.5, findings[1].EPSSScore)
+
+	// the dataset wins over the template, with the highest score of any CVE
+	require.NoError(t, vulndb.Write(vulnDBPath, &vulndb.Dataset{Scores: map[string]vulndb.Score{
+		"CVE-2021-44228": {EPSS: 0.97, Percentile: 0.999, CVSS: 10},
+		"CVE-2021-45046": {EPSS: 0.98, Percentile: 0.9995, CVSS: 9},
+	}}))
+	scan.UUID = "scan-2"
+	a.processNucleiOutput(scan, a.scanStore(scanDir))
+	assert.False(t, a.vulns.warned.Load())
+	findings, err = findingDao.ListFindings("scan-2", models.FindingFilter{Sort: models.FindingSortEPSS}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, findings, 3)
+	assert.Equal(t, "log4j", findings[0].TemplateID)
+	assert.Equal(t, 0.98, findings[0].EPSSScore)
+	assert.Equal(t, 0.9995, findings[0].EPSSPercentile)
+	assert.Equal(t, 10.0, findings[0].CVSSScore)
+	assert.Equal(t, "panel", findings[2].TemplateID)
+	assert.Zero(t, findings[2].EPSSScore)
+}
+
+func TestArtifactProcessor_HttpxEnrichment(t *testing.T) {
+	scanDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scanDir, "httpx_output.json"), []byte(
+		`{"url":"https://a.example.com","input":"a.example.com","status_code":200,"title":"Login","tech":["Nginx","PHP"],"content_length":512,"webserver":"nginx"}
+not json
+{"url":"http://b.example.com:8080","input":"b.example.com","status_code":403,"title":"Forbidden"}
+{"url":"https://unknown.example.com","status_code":200}
+`), 0644))
+
+	scan := &models.Scan{
+		UUID:       "scan-1",
+		Subdomains: []models.Subdomain{{Domain: "https://a.example.com"}, {Domain: "b.example.com"}, {Domain: "c.example.com"}},
+	}
+
+	a := newArtifactProcessor(nil, nil, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), nil, nil)
+	a.processHttpxOutput(scan, a.scanStore(scanDir))
+
+	assert.Equal(t, 200, scan.Subdomains[0].StatusCode)
+	assert.Equal(t, "Login", scan.Subdomains[0].Title)
+	assert.Equal(t, []string{"Nginx", "PHP"}, scan.Subdomains[0].Technologies)
+	assert.Equal(t, 512, scan.Subdomains[0].ContentLength)
+	// matched by input when the URL carries a port
+	assert.Equal(t, 403, scan.Subdomains[1].StatusCode)
+	assert.Zero(t, scan.Subdomains[2].StatusCode)
+}
+
+func TestArtifactProcessor_NaabuPortMerge(t *testing.T) {
+	scanDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scanDir, "naabu_output.txt"), []byte("a.example.com:8443\na.example.com:443\nb.example.com:8080\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(scanDir, "naabu_output.json"), []byte(
+		`{"host":"a.example.com","port":22,"protocol":"tcp"}
+{"host":"b.example.com","port":8080,"protocol":"tcp"}
+`), 0644))
+
+	scan := &models.Scan{
+		UUID: "scan-1",
+		Subdomains: []models.Subdomain{
+			{Domain: "https://a.example.com", OpenPorts: []string{"80/tcp (http)", "443/tcp (https)"}},
+			{Domain: "b.example.com"},
+		},
+	}
+
+	a := newArtifactProcessor(nil, nil, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), nil, nil)
+	a.processNaabuOutput(scan, a.scanStore(scanDir))
+	// reprocessing must not add the ports twice
+	a.processNaabuOutput(scan, a.scanStore(scanDir))
+
+	assert.Equal(t, []string{"22/tcp (unverified)", "80/tcp (http)", "443/tcp (https)", "8443/tcp (unverified)"}, scan.Subdomains[0].OpenPorts)
+	assert.Equal(t, []string{"8080/tcp (unverified)"}, scan.Subdomains[1].OpenPorts)
+}
+
+func TestArtifactProcessor_ToolLogs(t *testing.T) {
+	scanDir := filepath.Join(t.TempDir(), "full_recon_example.com")
+	require.NoError(t, os.MkdirAll(filepath.Join(scanDir, logger.ToolLogDir), 0755))
+	for _, name := range []string{"nuclei.log", "chaos-client.log", "nuclei.log.1"} {
+		require.NoError(t, os.WriteFile(filepath.Join(scanDir, logger.ToolLogDir, name), []byte("x\n"), 0644))
+	}
+
+	scan := &models.Scan{UUID: "scan-1"}
+	a := newArtifactProcessor(nil, nil, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), nil, nil)
+	a.saveToolLogPaths(scan, a.scanStore(scanDir))
+
+	assert.Equal(t, map[string]string{
+		"nuclei":       "full_recon_example.com/logs/nuclei.log",
+		"chaos-client": "full_recon_example.com/logs/chaos-client.log",
+	}, scan.ToolLogs)
+}
+
+// remoteStore hides that a Local store is on disk, as an S3 bucket would be.
+type remoteStore struct {
+	blobstore.Store
+}
+
+func (r remoteStore) URI(key string) string {
+	return "mem://" + key
+}
+
+func (r remoteStore) Key(uri string) (string, bool) {
+	return strings.CutPrefix(uri, "mem://")
+}
+
+func TestArtifactProcessor_ReadsThroughStore(t *testing.T) {
+	ctx := context.Background()
+	store := remoteStore{blobstore.NewLocal(t.TempDir())}
+	for key, body := range map[string]string{
+		"full_recon_example.com/a.example.com.png":  "png",
+		"full_recon_example.com/logs/nuclei.log":    "x\n",
+		"full_recon_example.com/nuclei_output.json": `{"template-id":"panel","info":{"name":"exposed-panel","severity":"high"},"host":"a.example.com","matched-at":"https://a.example.com/admin"}` + "\n",
+	} {
+		require.NoError(t, store.Put(ctx, key, strings.NewReader(body)))
+	}
+
+	scan := &models.Scan{UUID: "scan-1", Subdomains: []models.Subdomain{{Domain: "a.example.com"}}}
+	a := newArtifactProcessor(nil, nil, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), nil, nil)
+	a.store = store
+	// the scan directory is only named; nothing is read from it
+	scanStore := a.scanStore(filepath.Join(t.TempDir(), "full_recon_example.com"))
+
+	require.NoError(t, a.saveScreenShotPaths(scan, scanStore))
+	a.saveToolLogPaths(scan, scanStore)
+	a.processNucleiOutput(scan, scanStore)
+
+	assert.Equal(t, `["mem://full_recon_example.com/a.example.com.png"]`, scan.ScreenshotsPath)
+	assert.Equal(t, "mem://full_recon_example.com/a.example.com.png", scan.Subdomains[0].Screenshot)
+	assert.Equal(t, map[string]string{"nuclei": "mem://full_recon_example.com/logs/nuclei.log"}, scan.ToolLogs)
+	assert.Equal(t, map[string]int{"high": 1}, scan.SeverityCounts)
+}
+
+// memoryScanDir is the scan directory whose artifacts memoryArtifacts holds.
+const memoryScanDir = "/scans/full_recon_example.com"
+
+// memoryArtifacts is a processor reading files, by their name in the scan
+// directory, from memory.
+func memoryArtifacts(t *testing.T, files map[string]string) (*ArtifactProcessor, blobstore.Store) {
+	t.Helper()
+	store := blobstore.NewMemory()
+	for name, body := range files {
+		require.NoError(t, store.Put(context.Background(), path.Join(filepath.Base(memoryScanDir), name), strings.NewReader(body)))
+	}
+	a := newArtifactProcessor(nil, nil, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), nil, nil)
+	a.store = store
+	return a, a.scanStore(memoryScanDir)
+}
+
+func TestArtifactProcessor_NmapPortAttribution(t *testing.T) {
+	var manyPorts strings.Builder
+	for port := 8000; port < 8021; port++ {
+		fmt.Fprintf(&manyPorts, `<port protocol="tcp" portid="%d"><state state="open"/><service name="http-alt"/></port>`, port)
+	}
+	a, store := memoryArtifacts(t, map[string]string{"nmap_output.xml": `<?xml version="1.0"?>
+<nmaprun>
+<host>
+<hostnames><hostname name="a.example.com" type="user"/><hostname name="cdn.example.net" type="PTR"/></hostnames>
+<ports>
+<port protocol="tcp" portid="80"><state state="open"/><service name="http"/></port>
+<port protocol="tcp" portid="443"><state state="open"/><service name="https"/></port>
+<port protocol="tcp" portid="22"><state state="closed"/><service name="ssh"/></port>
+</ports>
+</host>
+<host>
+<hostnames><hostname name="b.example.com" type="user"/></hostnames>
+<ports>` + manyPorts.String() + `</ports>
+</host>
+<host><ports><port protocol="tcp" portid="25"><state state="open"/><service name="smtp"/></port></ports></host>
+</nmaprun>
+`})
+
+	scan := &models.Scan{UUID: "scan-1", Subdomains: []models.Subdomain{
+		{Domain: "https://a.example.com"},
+		{Domain: "https://b.example.com"},
+		{Domain: "https://cdn.example.net"},
+	}}
+	a.processNmapOutput(scan, store)
+
+	assert.Equal(t, []string{"80/tcp (http)", "443/tcp (https)"}, scan.Subdomains[0].OpenPorts)
+	assert.Empty(t, scan.Subdomains[0].PotentialFalsePorts)
+	// every port open is what a CDN or WAF answers with
+	assert.Empty(t, scan.Subdomains[1].OpenPorts)
+	assert.Len(t, scan.Subdomains[1].PotentialFalsePorts, 21)
+	// reverse DNS names are not the scan's hosts
+	assert.Empty(t, scan.Subdomains[2].OpenPorts)
+}
+
+func TestArtifactProcessor_NmapIncompleteOutput(t *testing.T) {
+	// nmap is still writing
+	a, store := memoryArtifacts(t, map[string]string{"nmap_output.xml": `<?xml version="1.0"?><nmaprun><host>`})
+
+	scan := &models.Scan{UUID: "scan-1", Subdomains: []models.Subdomain{{Domain: "https://a.example.com", OpenPorts: []string{"80/tcp (http)"}}}}
+	a.processNmapOutput(scan, store)
+
+	assert.Equal(t, []string{"80/tcp (http)"}, scan.Subdomains[0].OpenPorts)
+}
+
+func TestArtifactProcessor_FfufDedup(t *testing.T) {
+	a, store := memoryArtifacts(t, map[string]string{
+		"a.example.com_ffuf_output.json": `{"results":[{"url":"https://a.example.com/docs","status":200},{"url":"https://a.example.com/docs","status":200},{"url":"https://a.example.com/old","status":302,"redirectlocation":"/new"},{"url":"https://a.example.com/nope","status":404}]}`,
+		"b.example.com_ffuf_output.json": `{"results":[{"url":"https://b.example.com/docs","status":200}]}`,
+	})
+
+	scan := &models.Scan{UUID: "scan-1", Subdomains: []models.Subdomain{{Domain: "https://a.example.com"}, {Domain: "c.example.com"}}}
+	a.processFfufOutput(scan, store, memoryScanDir)
+	// every update parses the whole output again
+	a.processFfufOutput(scan, store, memoryScanDir)
+
+	assert.Equal(t, []string{"https://a.example.com/docs [200]", "https://a.example.com/old [302]"}, scan.Subdomains[0].DirFuzzing)
+	assert.Len(t, scan.Subdomains[0].Redirects, 1)
+	assert.Empty(t, scan.Subdomains[1].DirFuzzing)
+}
+
+func TestArtifactProcessor_NucleiSeverityParsing(t *testing.T) {
+	a, store := memoryArtifacts(t, map[string]string{"nuclei_output.json": `{"template-id":"panel","info":{"name":"Panel","severity":"HIGH"},"host":"a.example.com","matched-at":"https://a.example.com/admin"}
+{"template-id":"banner","info":{"name":"Banner"},"host":"a.example.com","matched-at":"https://a.example.com/"}
+{"template-id":"odd","info":{"name":"Odd","severity":3},"url":"https://a.example.com/odd","matched-at":"https://a.example.com/odd"}
+`})
+
+	scan := &models.Scan{UUID: "scan-1", Subdomains: []models.Subdomain{{Domain: "https://a.example.com"}}}
+	a.processNucleiOutput(scan, store)
+
+	// severities are lowercased and default to info
+	assert.Equal(t, map[string]int{"high": 1, "info": 2}, scan.SeverityCounts)
+	assert.Equal(t, []string{
+		"[HIGH] Panel - https://a.example.com/admin",
+		"[INFO] Banner - https://a.example.com/",
+		"[INFO] Odd - https://a.example.com/odd",
+	}, scan.Subdomains[0].Vulns)
+}
+
+func TestArtifactProcessor_ScreenshotPathsEncoding(t *testing.T) {
+	a, store := memoryArtifacts(t, map[string]string{
+		"a.example.com.png":             "png",
+		`screenshots/b "quoted" 1.jpeg`: "jpeg",
+		"screenshots/a.example.com.png": "png",
+		"notes.txt":                     "text",
+	})
+
+	scan := &models.Scan{UUID: "scan-1", Subdomains: []models.Subdomain{{Domain: "https://a.example.com"}}}
+	require.NoError(t, a.saveScreenShotPaths(scan, store))
+
+	var paths []string
+	require.NoError(t, json.Unmarshal([]byte(scan.ScreenshotsPath), &paths))
+	assert.Equal(t, []string{
+		"full_recon_example.com/a.example.com.png",
+		"full_recon_example.com/screenshots/a.example.com.png",
+		`full_recon_example.com/screenshots/b "quoted" 1.jpeg`,
+	}, paths)
+	assert.Equal(t, "full_recon_example.com/a.example.com.png", scan.Subdomains[0].Screenshot)
+}
+
+func TestArtifactProcessor_UpdateWithoutScanDir(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Status: "running"}))
+	_, err := subdomainDao.AddSubdomains("scan-1", []models.Subdomain{{Domain: "https://a.example.com"}})
+	require.NoError(t, err)
+
+	a, _ := memoryArtifacts(t, map[string]string{"nuclei_output.json": `{"template-id":"panel","info":{"name":"Panel","severity":"high"},"host":"a.example.com","matched-at":"https://a.example.com/admin"}` + "\n"})
+	a.scanDao, a.subdomainDao = scanDao, subdomainDao
+
+	// nothing is parsed, but the scan is still saved
+	a.UpdateArtifacts("scan-1", "")
+	scan, err := scanDao.GetScanByUUID("scan-1")
+	require.NoError(t, err)
+	assert.Empty(t, scan.SeverityCounts)
+	assert.Empty(t, scan.Subdomains[0].Vulns)
+
+	a.UpdateArtifacts("scan-1", memoryScanDir)
+	scan, err = scanDao.GetScanByUUID("scan-1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"high": 1}, scan.SeverityCounts)
+	assert.Equal(t, []string{"[HIGH] Panel - https://a.example.com/admin"}, scan.Subdomains[0].Vulns)
+}
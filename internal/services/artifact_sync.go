@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"pipeliner/pkg/blobstore"
+	"pipeliner/pkg/logger"
+)
+
+// artifactSyncer uploads a scan's files to the artifact store. Tools keep
+// writing to the scan directory; a file is uploaded once it has stopped
+// changing between two syncs, or when the scan ends.
+type artifactSyncer struct {
+	local    blobstore.Store
+	remote   blobstore.Store
+	logger   *logger.Logger
+	scanID   string
+	seen     map[string]blobstore.Object
+	uploaded map[string]blobstore.Object
+}
+
+func newArtifactSyncer(store blobstore.Store, scanID, scanDir string, logger *logger.Logger) *artifactSyncer {
+	return &artifactSyncer{
+		local:    blobstore.NewLocal(scanDir),
+		remote:   blobstore.Prefixed(store, filepath.Base(scanDir)),
+		logger:   logger,
+		scanID:   scanID,
+		seen:     make(map[string]blobstore.Object),
+		uploaded: make(map[string]blobstore.Object),
+	}
+}
+
+// sync uploads the files that closed since the last sync, or every changed
+// file when final is set, and returns how many it uploaded.
+func (s *artifactSyncer) sync(final bool) int {
+	ctx := context.Background()
+	objects, err := s.local.List(ctx, "")
+	if err != nil {
+		s.logger.Error("Failed to list scan files for upload", logger.Fields{"error": err, "scan_id": s.scanID})
+		return 0
+	}
+
+	uploaded := 0
+	for _, object := range objects {
+		previous, seen := s.seen[object.Key]
+		s.seen[object.Key] = object
+		if s.uploaded[object.Key] == object {
+			continue
+		}
+		if !final && (!seen || previous != object) {
+			continue
+		}
+		if err := s.upload(ctx, object.Key); err != nil {
+			s.logger.Error("Failed to upload scan file", logger.Fields{"error": err, "file": object.Key, "scan_id": s.scanID})
+			continue
+		}
+		s.uploaded[object.Key] = object
+		uploaded++
+	}
+	return uploaded
+}
+
+func (s *artifactSyncer) upload(ctx context.Context, key string) error {
+	file, ok := blobstore.LocalPath(s.local, key)
+	if !ok {
+		return blobstore.ErrNotFound
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.remote.Put(ctx, key, f)
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pipeliner/pkg/blobstore"
+	"pipeliner/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifactSyncer_UploadsFilesOnceTheyStopChanging(t *testing.T) {
+	scanDir := filepath.Join(t.TempDir(), "full_recon_example.com")
+	require.NoError(t, os.MkdirAll(filepath.Join(scanDir, logger.ToolLogDir), 0755))
+	remote := blobstore.NewLocal(t.TempDir())
+	syncer := newArtifactSyncer(remote, "scan-1", scanDir, logger.NewLogger(logrus.ErrorLevel))
+
+	write := func(name, body string) {
+		require.NoError(t, os.WriteFile(filepath.Join(scanDir, name), []byte(body), 0644))
+	}
+	read := func(key string) string {
+		r, err := remote.Get(context.Background(), "full_recon_example.com/"+key)
+		if err != nil {
+			return ""
+		}
+		defer r.Close()
+		data, _ := io.ReadAll(r)
+		return string(data)
+	}
+
+	write("nmap_output.xml", "<nmaprun/>")
+	write("logs/httpx.log", "a")
+	// first seen, not yet known to be closed
+	assert.Equal(t, 0, syncer.sync(false))
+
+	write("logs/httpx.log", "ab")
+	assert.Equal(t, 1, syncer.sync(false))
+	assert.Equal(t, "<nmaprun/>", read("nmap_output.xml"))
+	assert.Empty(t, read("logs/httpx.log"))
+
+	// unchanged files are not uploaded again
+	assert.Equal(t, 1, syncer.sync(false))
+	assert.Equal(t, "ab", read("logs/httpx.log"))
+	assert.Equal(t, 0, syncer.sync(false))
+
+	write("logs/httpx.log", "abc")
+	assert.Equal(t, 1, syncer.sync(true))
+	assert.Equal(t, "abc", read("logs/httpx.log"))
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"fmt"
+	"pipeliner/internal/notification"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/tools"
+	"strconv"
+)
+
+// artifactRefresh asks a scan's running artifact monitor for an update pass
+// between its ticks. Each request is a func to call after the pass, or nil.
+type artifactRefresh struct {
+	requests chan func()
+	done     chan struct{}
+}
+
+// watchRefreshes registers the artifact monitor of scanID for refreshes
+// until stop is called.
+func (m *ScanMonitor) watchRefreshes(scanID string) (<-chan func(), func()) {
+	refresh := &artifactRefresh{requests: make(chan func()), done: make(chan struct{})}
+	m.refreshMu.Lock()
+	if m.refreshes == nil {
+		m.refreshes = make(map[string]*artifactRefresh)
+	}
+	m.refreshes[scanID] = refresh
+	m.refreshMu.Unlock()
+
+	return refresh.requests, func() {
+		m.refreshMu.Lock()
+		if m.refreshes[scanID] == refresh {
+			delete(m.refreshes, scanID)
+		}
+		m.refreshMu.Unlock()
+		close(refresh.done)
+	}
+}
+
+// refreshArtifacts has the scan's artifact monitor parse its outputs now,
+// then calls then, which may be nil. Without a running monitor the outputs
+// are parsed here. It blocks until the monitor takes the request.
+func (m *ScanMonitor) refreshArtifacts(scanID, scanDir string, then func()) {
+	m.refreshMu.Lock()
+	refresh := m.refreshes[scanID]
+	m.refreshMu.Unlock()
+
+	if refresh != nil {
+		select {
+		case refresh.requests <- then:
+			return
+		case <-refresh.done:
+		}
+	}
+	m.updateArtifacts(scanID, scanDir)
+	if then != nil {
+		then()
+	}
+}
+
+// chunkCompleted parses the scan's outputs after a chunk of a chunked
+// replacement run, so the findings so far show before the tool is done,
+// and sends the tool's progress if it has notify_chunks.
+func (e *ScanExecutor) chunkCompleted(scanID, domain, scanDir string, progress tools.ChunkProgress) {
+	var then func()
+	if progress.Notify {
+		then = func() { e.notifyChunk(scanID, domain, progress) }
+	}
+	go e.scanService.monitor.refreshArtifacts(scanID, scanDir, then)
+}
+
+// notifyChunk sends a chunk's progress, e.g. "ffuf 2,500/40,000 done, 3
+// sensitive hits so far".
+func (e *ScanExecutor) notifyChunk(scanID, domain string, progress tools.ChunkProgress) {
+	notifier := e.scanService.notifier
+	if notifier == nil {
+		return
+	}
+	hits := 0
+	if scan, err := e.scanService.scanDao.GetScanByUUID(scanID); err == nil {
+		for _, subdomain := range scan.Subdomains {
+			for _, finding := range subdomain.Sensitive {
+				if finding.Alerted {
+					hits++
+				}
+			}
+		}
+	} else {
+		e.scanService.logger.Warn("Failed to count sensitive hits", logger.Fields{"scan_id": scanID, "error": err})
+	}
+
+	msg := notification.Message{
+		Title:       fmt.Sprintf("%s %s/%s done, %d sensitive hits so far", progress.Tool, formatCount(progress.Done), formatCount(progress.Total), hits),
+		Description: fmt.Sprintf("Chunk %d of the tool's replacement values finished.", progress.Chunk),
+		Severity:    "info",
+		EventType:   notification.EventScanLifecycle,
+		Fields: map[string]string{
+			"Scan":     scanID,
+			"Domain":   domain,
+			"Tool":     progress.Tool,
+			"Failures": strconv.Itoa(progress.Failures),
+		},
+	}
+	if err := notifier.Send(msg); err != nil {
+		e.scanService.logger.Warn("Failed to send chunk progress", logger.Fields{"scan_id": scanID, "error": err})
+	}
+}
+
+// formatCount writes n with thousands separators.
+func formatCount(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0 && s[i-1] != '-'; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pipeliner/internal/config"
+	"pipeliner/internal/models"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/testutil"
+	"pipeliner/pkg/tools"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkCompleted_ParsesEachChunkAndNotifies(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Status: models.ScanRunning, Domain: "example.com"}))
+	hosts := []string{"a.example.com", "b.example.com", "c.example.com"}
+	var subdomains []models.Subdomain
+	for _, host := range hosts {
+		subdomains = append(subdomains, models.Subdomain{Domain: host})
+	}
+	_, err := subdomainDao.AddSubdomains("scan-1", subdomains)
+	require.NoError(t, err)
+
+	log := logger.NewLogger(logrus.ErrorLevel)
+	locks := NewScanLocks()
+	artifacts := newArtifactProcessor(scanDao, subdomainDao, log, locks, nil, nil)
+	m := newScanMonitor(subdomainDao, log, locks, artifacts, newScanStatusManager(scanDao, log, nil), config.DefaultMonitorConfig(), nil)
+	// the ticks never come, so only the chunks update the artifacts
+	m.after = testutil.NewFakeClock(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)).After
+
+	scanDir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.monitorArtifacts("scan-1", scanDir, ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	require.Eventually(t, func() bool {
+		m.refreshMu.Lock()
+		defer m.refreshMu.Unlock()
+		return m.refreshes["scan-1"] != nil
+	}, 2*time.Second, time.Millisecond)
+
+	notifier := &recordingNotifier{}
+	e := newScanExecutor(&scanService{scanDao: scanDao, logger: log, notifier: notifier, monitor: m})
+
+	// three chunks of 1,500 values, each with a sensitive hit on its host
+	outputs := make(map[string]string)
+	for i, host := range hosts {
+		file := fmt.Sprintf("run_%d.json", i+1)
+		require.NoError(t, os.WriteFile(filepath.Join(scanDir, file),
+			[]byte(fmt.Sprintf(`{"results":[{"url":"https://%s/.git/config","status":200}]}`, host)), 0644))
+		outputs["https://"+host] = file
+		require.NoError(t, tools.WriteOutputManifest(filepath.Join(scanDir, tools.OutputManifestFile("ffuf")), &tools.OutputManifest{Tool: "ffuf", Outputs: outputs}))
+
+		e.chunkCompleted("scan-1", "example.com", scanDir, tools.ChunkProgress{Tool: "ffuf", Chunk: i + 1, Done: (i + 1) * 1500, Total: 4500, Notify: true})
+		require.Eventually(t, func() bool { return len(notifier.sent()) == i+1 }, 2*time.Second, time.Millisecond)
+
+		sent := notifier.sent()[i]
+		assert.Equal(t, fmt.Sprintf("ffuf %s/4,500 done, %d sensitive hits so far", formatCount((i+1)*1500), i+1), sent.Title)
+		assert.Equal(t, "scan-1", sent.Fields["Scan"])
+	}
+
+	scan, err := scanDao.GetScanByUUID("scan-1")
+	require.NoError(t, err)
+	for _, subdomain := range scan.Subdomains {
+		assert.Len(t, subdomain.Sensitive, 1, subdomain.Domain)
+	}
+}
+
+func TestChunkCompleted_WithoutNotifyOnlyParses(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Status: models.ScanRunning}))
+	_, err := subdomainDao.AddSubdomains("scan-1", []models.Subdomain{{Domain: "a.example.com"}})
+	require.NoError(t, err)
+
+	scanDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scanDir, "run_1.json"), []byte(`{"results":[{"url":"https://a.example.com/admin","status":200}]}`), 0644))
+	require.NoError(t, tools.WriteOutputManifest(filepath.Join(scanDir, tools.OutputManifestFile("ffuf")), &tools.OutputManifest{Tool: "ffuf", Outputs: map[string]string{"https://a.example.com": "run_1.json"}}))
+
+	log := logger.NewLogger(logrus.ErrorLevel)
+	locks := NewScanLocks()
+	artifacts := newArtifactProcessor(scanDao, subdomainDao, log, locks, nil, nil)
+	m := newScanMonitor(subdomainDao, log, locks, artifacts, newScanStatusManager(scanDao, log, nil), config.DefaultMonitorConfig(), nil)
+	notifier := &recordingNotifier{}
+	e := newScanExecutor(&scanService{scanDao: scanDao, logger: log, notifier: notifier, monitor: m})
+
+	// no monitor is running, so the chunk is parsed on its own
+	e.chunkCompleted("scan-1", "", scanDir, tools.ChunkProgress{Tool: "ffuf", Chunk: 1, Done: 1, Total: 2})
+	require.Eventually(t, func() bool {
+		scan, err := scanDao.GetScanByUUID("scan-1")
+		return err == nil && len(scan.Subdomains) == 1 && len(scan.Subdomains[0].DirFuzzing) == 1
+	}, 2*time.Second, time.Millisecond)
+	assert.Empty(t, notifier.sent())
+}
+
+func TestFormatCount(t *testing.T) {
+	for n, want := range map[int]string{0: "0", 999: "999", 1000: "1,000", 40000: "40,000", 1234567: "1,234,567", -2500: "-2,500"} {
+		assert.Equal(t, want, formatCount(n))
+	}
+}
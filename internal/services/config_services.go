@@ -1,53 +1,147 @@
 package services
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+	"pipeliner/internal/utils"
+	"pipeliner/pkg/engine"
+	perrors "pipeliner/pkg/errors"
 	"pipeliner/pkg/logger"
 	"pipeliner/pkg/tools"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
 
+var (
+	ErrConfigNotFound = errors.New("scan module not found")
+	ErrConfigReadOnly = errors.New("scan module is read-only")
+	ErrConfigChanged  = errors.New("scan module changed since it was loaded")
+	// ErrConfigChecksumMissing is returned for saves that do not say which
+	// version of the module they replace.
+	ErrConfigChecksumMissing = errors.New("the checksum of the module being replaced is required")
+)
+
+// ConfigValidationError carries the problems that kept a module from saving.
+type ConfigValidationError struct {
+	Issues []models.ConfigIssue
+}
+
+func (e *ConfigValidationError) Error() string {
+	return fmt.Sprintf("module has %d validation issue(s)", len(e.Issues))
+}
+
+// ModuleSource is the raw YAML of a scan module.
+type ModuleSource struct {
+	Name     string `json:"name"`
+	Content  string `json:"content"`
+	Checksum string `json:"checksum"`
+	Editable bool   `json:"editable"`
+	Source   string `json:"source"`
+	Revision string `json:"revision,omitempty"`
+}
+
+// moduleDurationSamples is how many recent scans of a module its average
+// duration is taken over.
+const moduleDurationSamples = 20
+
 type ConfigServiceMethods interface {
-	GetScanModules() []tools.ChainConfig
+	GetScanModules() []models.ScanModule
+	ModuleOrigin() utils.ModuleOrigin
+	GetModuleSource(name string) (*ModuleSource, error)
+	ValidateModuleSource(name string, content []byte) []models.ConfigIssue
+	SaveModuleSource(name string, content []byte, previousChecksum, author string) (*models.ConfigChange, error)
+	ListModuleChanges(name string) ([]models.ConfigChange, error)
 }
 
 type configService struct {
 	log        *logger.Logger
 	configPath string
+	changes    dao.ConfigChangeDAO
+	scans      dao.ScanDAO
+	editable   bool
+	lookPath   func(string) (string, error)
 }
 
-func NewConfigService() ConfigServiceMethods {
-	configPath, err := filepath.Abs("./config")
-	if err != nil {
-		configPath = "./config"
+type ConfigServiceOption func(*configService)
+
+// WithConfigChanges records module edits through changes.
+func WithConfigChanges(changes dao.ConfigChangeDAO) ConfigServiceOption {
+	return func(c *configService) {
+		c.changes = changes
+	}
+}
+
+// WithScanHistory lets modules report how long their past scans took.
+func WithScanHistory(scans dao.ScanDAO) ConfigServiceOption {
+	return func(c *configService) {
+		c.scans = scans
 	}
+}
+
+// WithConfigEdits allows modules to be saved; they are read-only by default.
+func WithConfigEdits(enabled bool) ConfigServiceOption {
+	return func(c *configService) {
+		c.editable = enabled
+	}
+}
+
+// WithConfigPath reads modules from path instead of the current module
+// origin, which is ./config unless a git config source is in use.
+func WithConfigPath(path string) ConfigServiceOption {
+	return func(c *configService) {
+		c.configPath = path
+	}
+}
 
-	return &configService{
-		log:        logger.NewLogger(logrus.Level(logrus.InfoLevel)),
-		configPath: configPath,
+func NewConfigService(opts ...ConfigServiceOption) ConfigServiceMethods {
+	c := &configService{
+		log:      logger.NewLogger(logrus.Level(logrus.InfoLevel)),
+		lookPath: exec.LookPath,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ModuleOrigin reports where modules are read from.
+func (c *configService) ModuleOrigin() utils.ModuleOrigin {
+	if c.configPath != "" {
+		return utils.ModuleOrigin{Dir: c.configPath, Source: "local"}
+	}
+	return utils.CurrentModuleOrigin()
 }
 
-func (c *configService) GetScanModules() []tools.ChainConfig {
+// GetScanModules lists the modules with their tools by stage, whether the
+// binaries they run are installed, and their average scan duration.
+func (c *configService) GetScanModules() []models.ScanModule {
+	configPath := c.ModuleOrigin().Dir
 
-	files, err := os.ReadDir(c.configPath)
+	files, err := os.ReadDir(configPath)
 	if err != nil {
 		c.log.WithError(err).Error("Failed to read config directory")
 		return nil
 	}
 
-	toolConfig := make([]tools.ChainConfig, 0)
+	modules := make([]models.ScanModule, 0)
 
 	for _, file := range files {
 		if file.IsDir() || !strings.HasSuffix(file.Name(), ".yaml") {
 			continue
 		}
 
-		data, err := os.ReadFile(c.configPath + "/" + file.Name())
+		data, err := os.ReadFile(configPath + "/" + file.Name())
 		if err != nil {
 			c.log.WithError(err).WithField("file", file.Name()).Error("Failed to read config file")
 			continue
@@ -59,9 +153,224 @@ func (c *configService) GetScanModules() []tools.ChainConfig {
 			continue
 		}
 
-		toolConfig = append(toolConfig, meta)
+		modules = append(modules, c.describeModule(meta))
 	}
 
-	return toolConfig
+	return modules
+}
+
+func (c *configService) describeModule(config tools.ChainConfig) models.ScanModule {
+	module := models.ScanModule{ChainConfig: config, Stages: []models.ModuleStage{}, Binaries: []models.ModuleBinary{}}
+
+	byStage := make(map[tools.Stage][]string)
+	seen := make(map[string]bool)
+	for i := range config.Tools {
+		tool := &config.Tools[i]
+		byStage[tool.Stage()] = append(byStage[tool.Stage()], tool.Name)
 
+		if tool.Command == "" || seen[tool.Command] {
+			continue
+		}
+		seen[tool.Command] = true
+		_, err := c.lookPath(tool.Command)
+		module.Binaries = append(module.Binaries, models.ModuleBinary{Command: tool.Command, Installed: err == nil})
+	}
+	for _, stage := range tools.Stages {
+		if names := byStage[stage]; len(names) > 0 {
+			module.Stages = append(module.Stages, models.ModuleStage{Stage: string(stage), Tools: names})
+		}
+	}
+	if names := byStage[""]; len(names) > 0 {
+		module.Stages = append(module.Stages, models.ModuleStage{Stage: "other", Tools: names})
+	}
+
+	if c.scans != nil {
+		average, samples, err := c.scans.AverageScanDuration(config.Name, moduleDurationSamples)
+		if err != nil {
+			c.log.Warn("Failed to load module scan durations", logger.Fields{"module": config.Name, "error": err})
+		}
+		module.AverageDurationSeconds = int64(average / time.Second)
+		module.DurationSamples = samples
+	}
+	return module
+}
+
+var moduleNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func (c *configService) modulePath(name string) (string, error) {
+	if !moduleNamePattern.MatchString(name) {
+		return "", ErrConfigNotFound
+	}
+	path := filepath.Join(c.ModuleOrigin().Dir, name+".yaml")
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return c.modulePathByName(name)
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+// modulePathByName finds a module whose name: differs from its file name.
+func (c *configService) modulePathByName(name string) (string, error) {
+	configPath := c.ModuleOrigin().Dir
+	files, err := os.ReadDir(configPath)
+	if err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".yaml") {
+			continue
+		}
+		path := filepath.Join(configPath, file.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var meta tools.ChainConfig
+		if yaml.Unmarshal(data, &meta) == nil && meta.Name == name {
+			return path, nil
+		}
+	}
+	return "", ErrConfigNotFound
+}
+
+func (c *configService) GetModuleSource(name string) (*ModuleSource, error) {
+	path, err := c.modulePath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	origin := c.ModuleOrigin()
+	return &ModuleSource{
+		Name:     name,
+		Content:  string(data),
+		Checksum: checksum(data),
+		Editable: c.writable(path) == nil,
+		Source:   origin.Source,
+		Revision: origin.Revision,
+	}, nil
+}
+
+func (c *configService) ValidateModuleSource(name string, content []byte) []models.ConfigIssue {
+	return configIssues(engine.ValidateModuleSource(name+".yaml", content))
+}
+
+// SaveModuleSource replaces a module after it passes validation. The save is
+// refused without previousChecksum, or if the file changed since it was read.
+func (c *configService) SaveModuleSource(name string, content []byte, previousChecksum, author string) (*models.ConfigChange, error) {
+	path, err := c.modulePath(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.writable(path); err != nil {
+		return nil, err
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	currentChecksum := checksum(current)
+	if previousChecksum == "" {
+		return nil, ErrConfigChecksumMissing
+	}
+	if previousChecksum != currentChecksum {
+		return nil, ErrConfigChanged
+	}
+
+	if issues := c.ValidateModuleSource(name, content); len(issues) > 0 {
+		return nil, &ConfigValidationError{Issues: issues}
+	}
+
+	// write next to the module and rename so scans never read a partial
+	// file; the new file keeps the module's permissions
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, info.Mode().Perm()); err != nil {
+		return nil, fmt.Errorf("failed to write module %s: %w", name, err)
+	}
+	// WriteFile leaves the mode of a leftover temp file alone and applies the umask
+	if err := os.Chmod(tmp, info.Mode().Perm()); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("failed to write module %s: %w", name, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("failed to replace module %s: %w", name, err)
+	}
+
+	change := &models.ConfigChange{
+		Module:           name,
+		Author:           author,
+		PreviousChecksum: currentChecksum,
+		NewChecksum:      checksum(content),
+		ChangedAt:        time.Now(),
+	}
+	c.log.Info("Scan module updated", logger.Fields{"module": name, "author": author, "checksum": change.NewChecksum})
+
+	if c.changes != nil {
+		if err := c.changes.SaveConfigChange(change); err != nil {
+			c.log.Error("Failed to record module change", logger.Fields{"module": name, "error": err})
+		}
+	}
+	return change, nil
+}
+
+func (c *configService) ListModuleChanges(name string) ([]models.ConfigChange, error) {
+	if _, err := c.modulePath(name); err != nil {
+		return nil, err
+	}
+	if c.changes == nil {
+		return nil, nil
+	}
+	return c.changes.ListConfigChanges(name)
+}
+
+// writable applies the write-protection rules: edits must be enabled for the
+// server, the modules must not come from git and the module file itself must
+// not be read-only.
+func (c *configService) writable(path string) error {
+	if !c.editable || c.ModuleOrigin().Managed() {
+		return ErrConfigReadOnly
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0200 == 0 {
+		return ErrConfigReadOnly
+	}
+	return nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// configIssues flattens a validation error into one issue per problem.
+func configIssues(err error) []models.ConfigIssue {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var issues []models.ConfigIssue
+		for _, e := range joined.Unwrap() {
+			issues = append(issues, configIssues(e)...)
+		}
+		return issues
+	}
+
+	var decodeErr *perrors.ConfigDecodeError
+	if errors.As(err, &decodeErr) {
+		return []models.ConfigIssue{{Key: decodeErr.Key, Expected: decodeErr.Expected, Message: decodeErr.Err.Error()}}
+	}
+	return []models.ConfigIssue{{Message: err.Error()}}
 }
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const testModule = `name: quick
+execution_mode: sequential
+tools:
+  - name: subfinder
+    command: subfinder
+`
+
+func newTestConfigService(t *testing.T, opts ...ConfigServiceOption) (ConfigServiceMethods, string) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ConfigChange{}))
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "quick.yaml"), []byte(testModule), 0644))
+
+	opts = append([]ConfigServiceOption{WithConfigPath(dir), WithConfigChanges(dao.NewConfigChangeDAO(db))}, opts...)
+	return NewConfigService(opts...), dir
+}
+
+func TestConfigService_ValidateReportsKeyAndType(t *testing.T) {
+	svc, _ := newTestConfigService(t)
+
+	issues := svc.ValidateModuleSource("quick", []byte(testModule+"    retries: lots\n"))
+	require.Len(t, issues, 1)
+	assert.Equal(t, "tools[0].retries", issues[0].Key)
+	assert.Equal(t, "int", issues[0].Expected)
+
+	assert.Empty(t, svc.ValidateModuleSource("quick", []byte(testModule)))
+}
+
+func TestConfigService_SaveIsReadOnlyByDefault(t *testing.T) {
+	svc, _ := newTestConfigService(t)
+
+	source, err := svc.GetModuleSource("quick")
+	require.NoError(t, err)
+	assert.False(t, source.Editable)
+
+	_, err = svc.SaveModuleSource("quick", []byte(testModule), source.Checksum, "alice")
+	assert.ErrorIs(t, err, ErrConfigReadOnly)
+}
+
+func TestConfigService_SaveRecordsChange(t *testing.T) {
+	svc, dir := newTestConfigService(t, WithConfigEdits(true))
+
+	source, err := svc.GetModuleSource("quick")
+	require.NoError(t, err)
+
+	updated := []byte(testModule + "    retries: 2\n")
+	change, err := svc.SaveModuleSource("quick", updated, source.Checksum, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, source.Checksum, change.PreviousChecksum)
+
+	data, err := os.ReadFile(filepath.Join(dir, "quick.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, string(updated), string(data))
+
+	changes, err := svc.ListModuleChanges("quick")
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "alice", changes[0].Author)
+	assert.Equal(t, change.NewChecksum, changes[0].NewChecksum)
+
+	// the old checksum no longer matches the file
+	_, err = svc.SaveModuleSource("quick", []byte(testModule), source.Checksum, "bob")
+	assert.ErrorIs(t, err, ErrConfigChanged)
+	// and a save has to say which version it replaces
+	_, err = svc.SaveModuleSource("quick", []byte(testModule), "", "bob")
+	assert.ErrorIs(t, err, ErrConfigChecksumMissing)
+}
+
+func TestConfigService_SaveKeepsFileMode(t *testing.T) {
+	svc, dir := newTestConfigService(t, WithConfigEdits(true))
+	path := filepath.Join(dir, "quick.yaml")
+	require.NoError(t, os.Chmod(path, 0600))
+	source, err := svc.GetModuleSource("quick")
+	require.NoError(t, err)
+
+	_, err = svc.SaveModuleSource("quick", []byte(testModule+"    retries: 2\n"), source.Checksum, "alice")
+	require.NoError(t, err)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestConfigService_SaveRejectsInvalidModule(t *testing.T) {
+	svc, dir := newTestConfigService(t, WithConfigEdits(true))
+	source, err := svc.GetModuleSource("quick")
+	require.NoError(t, err)
+
+	_, err = svc.SaveModuleSource("quick", []byte("name: quick\nexecution_mode: sideways\ntools: []\n"), source.Checksum, "alice")
+	var validationErr *ConfigValidationError
+	require.ErrorAs(t, err, &validationErr)
+
+	data, err := os.ReadFile(filepath.Join(dir, "quick.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, testModule, string(data))
+}
+
+func TestConfigService_RejectsWriteProtectedFile(t *testing.T) {
+	svc, dir := newTestConfigService(t, WithConfigEdits(true))
+	require.NoError(t, os.Chmod(filepath.Join(dir, "quick.yaml"), 0444))
+
+	_, err := svc.SaveModuleSource("quick", []byte(testModule), "", "alice")
+	assert.ErrorIs(t, err, ErrConfigReadOnly)
+
+	_, err = svc.GetModuleSource("../quick")
+	assert.ErrorIs(t, err, ErrConfigNotFound)
+}
+
+func TestConfigService_GetScanModulesMetadata(t *testing.T) {
+	scanDao, _ := newTestDAOs(t)
+	svc, dir := newTestConfigService(t, WithScanHistory(scanDao))
+	svc.(*configService).lookPath = func(command string) (string, error) {
+		if command == "nuclei" {
+			return "", exec.ErrNotFound
+		}
+		return "/usr/bin/" + command, nil
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "quick.yaml"), []byte(`name: quick
+execution_mode: hybrid
+tools:
+  - name: nuclei
+    command: nuclei
+    type: vuln
+  - name: subfinder
+    command: subfinder
+    type: domain_enum
+  - name: httpx
+    command: httpx
+    type: recon
+  - name: httpx-tls
+    command: httpx
+    type: recon
+  - name: notes
+    command: echo
+`), 0644))
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "s1", ScanType: "quick", Status: "completed"}))
+
+	modules := svc.GetScanModules()
+	require.Len(t, modules, 1)
+	module := modules[0]
+	assert.Equal(t, "hybrid", module.ExecutionMode)
+	assert.Equal(t, []models.ModuleStage{
+		{Stage: "subdomain_enum", Tools: []string{"subfinder"}},
+		{Stage: "recon", Tools: []string{"httpx", "httpx-tls"}},
+		{Stage: "vuln_scan", Tools: []string{"nuclei"}},
+		{Stage: "other", Tools: []string{"notes"}},
+	}, module.Stages)
+	assert.Equal(t, []models.ModuleBinary{
+		{Command: "nuclei"},
+		{Command: "subfinder", Installed: true},
+		{Command: "httpx", Installed: true},
+		{Command: "echo", Installed: true},
+	}, module.Binaries)
+	assert.Equal(t, 1, module.MissingBinaries())
+	assert.Equal(t, 1, module.DurationSamples)
+
+	// the module's own fields stay at the top level of the API response
+	data, err := json.Marshal(module)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Name":"quick"`)
+	assert.Contains(t, string(data), `"stages":[`)
+}
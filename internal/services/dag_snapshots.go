@@ -0,0 +1,35 @@
+package services
+
+import (
+	"pipeliner/pkg/tools"
+	"sync"
+)
+
+// dagSnapshots keeps the latest dependency graph state of each hybrid scan
+// run by this process. It is not persisted.
+type dagSnapshots struct {
+	mu   sync.Mutex
+	byID map[string]*tools.DAGSnapshot
+}
+
+func newDAGSnapshots() *dagSnapshots {
+	return &dagSnapshots{byID: make(map[string]*tools.DAGSnapshot)}
+}
+
+func (d *dagSnapshots) set(scanID string, snap *tools.DAGSnapshot) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.byID[scanLockKey(scanID)] = snap
+}
+
+func (d *dagSnapshots) get(scanID string) *tools.DAGSnapshot {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.byID[scanLockKey(scanID)]
+}
+
+func (d *dagSnapshots) remove(scanID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.byID, scanLockKey(scanID))
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"pipeliner/internal/notification"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/tools"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WithDiscoveryNotifications notifies once when the monitor saves the
+// first live subdomain of a scan, if firstSubdomain, and when a scan's
+// subdomain stage ends without a single subdomain, if noSubdomains.
+func WithDiscoveryNotifications(firstSubdomain, noSubdomains bool) ScanServiceOption {
+	return func(s *scanService) {
+		s.discovery = &discoveryNotifier{firstSubdomain: firstSubdomain, noSubdomains: noSubdomains}
+	}
+}
+
+// discoveryNotifier tells whether a scan is finding hosts at all: the
+// first one is a sign the scan works, none after subdomain enumeration
+// usually a missing provider key or a broken module.
+type discoveryNotifier struct {
+	notifier       notification.Notifier
+	logger         *logger.Logger
+	firstSubdomain bool
+	noSubdomains   bool
+}
+
+// subdomainsSaved is called with the hosts the monitor just saved and the
+// scan's total after them. Only the batch that starts a scan's subdomains
+// is notified about, so a restarted monitor does not notify again.
+func (d *discoveryNotifier) subdomainsSaved(scanID string, hosts []string, added int, total int64) {
+	if d == nil || !d.firstSubdomain || added == 0 || int64(added) != total || len(hosts) == 0 {
+		return
+	}
+	d.send(scanID, notification.Message{
+		Title:       fmt.Sprintf("First live host found: %s", hosts[0]),
+		Description: "The scan is finding hosts.",
+		Severity:    "info",
+		EventType:   notification.EventNewSubdomain,
+		Fields: map[string]string{
+			"Scan": scanID,
+			"Host": hosts[0],
+		},
+	})
+}
+
+// stageCompleted checks the outputs of a completed subdomain stage in
+// dir, and notifies with their line counts when none has a subdomain.
+func (d *discoveryNotifier) stageCompleted(scanID, domain, dir string, stage tools.Stage) {
+	if d == nil || !d.noSubdomains || stage != tools.StageSubdomain || dir == "" {
+		return
+	}
+	artifacts := tools.NewArtifacts(dir)
+	outputs, err := artifacts.ListByStage(tools.StageSubdomain)
+	if err != nil {
+		d.logger.Warn("Failed to list subdomain outputs", logger.Fields{"scan_id": scanID, "error": err})
+		return
+	}
+
+	lines := make(map[string]int)
+	total := 0
+	for _, output := range outputs {
+		count, err := countLines(artifacts, output.Name)
+		if err != nil {
+			d.logger.Warn("Failed to read subdomain output", logger.Fields{"scan_id": scanID, "file": output.Name, "error": err})
+			continue
+		}
+		lines[output.Name] += count
+		total += count
+	}
+	if total > 0 {
+		return
+	}
+
+	fields := map[string]string{"Scan": scanID, "Domain": domain}
+	names := make([]string, 0, len(lines))
+	for name := range lines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	counts := make([]string, 0, len(names))
+	for _, name := range names {
+		counts = append(counts, name+": "+strconv.Itoa(lines[name])+" lines")
+	}
+	if len(counts) > 0 {
+		fields["Outputs"] = strings.Join(counts, "\n")
+	}
+
+	description := "Subdomain enumeration finished without a single subdomain, so the later stages have nothing to scan. Check the module's config and the tools' provider keys."
+	if len(outputs) == 0 {
+		description = "Subdomain enumeration finished and no tool recorded an output. Check the module's config and the tools' provider keys."
+	}
+	d.send(scanID, notification.Message{
+		Title:       fmt.Sprintf("No subdomains found for %s", domain),
+		Description: description,
+		Severity:    "medium",
+		EventType:   notification.EventNewSubdomain,
+		Fields:      fields,
+	})
+}
+
+func (d *discoveryNotifier) send(scanID string, msg notification.Message) {
+	if d.notifier == nil {
+		return
+	}
+	if err := d.notifier.Send(msg); err != nil {
+		d.logger.Warn("Failed to send discovery notification", logger.Fields{"scan_id": scanID, "error": err})
+	}
+}
+
+// countLines counts the non-blank lines of the artifact name.
+func countLines(artifacts *tools.Artifacts, name string) (int, error) {
+	file, err := artifacts.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) != "" {
+			count++
+		}
+	}
+	return count, scanner.Err()
+}
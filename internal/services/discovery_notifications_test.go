@@ -0,0 +1,116 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pipeliner/internal/config"
+	"pipeliner/internal/models"
+	"pipeliner/internal/notification"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/tools"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDiscoveryNotifier(firstSubdomain, noSubdomains bool) (*discoveryNotifier, *recordingNotifier) {
+	notifier := &recordingNotifier{}
+	return &discoveryNotifier{
+		notifier:       notifier,
+		logger:         logger.NewLogger(logrus.ErrorLevel),
+		firstSubdomain: firstSubdomain,
+		noSubdomains:   noSubdomains,
+	}, notifier
+}
+
+func TestDiscoveryNotifier_FirstSubdomainOnce(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Status: models.ScanRunning}))
+	log := logger.NewLogger(logrus.ErrorLevel)
+	m := newScanMonitor(subdomainDao, log, NewScanLocks(), nil, newScanStatusManager(scanDao, log, nil), config.DefaultMonitorConfig(), nil)
+	discovery, notifier := newTestDiscoveryNotifier(true, false)
+	m.discovery = discovery
+
+	httpxPath := filepath.Join(t.TempDir(), "httpx_output.txt")
+	var lastSize int64
+	appendHosts := func(hosts string) {
+		f, err := os.OpenFile(httpxPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		require.NoError(t, err)
+		_, err = f.WriteString(hosts)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		m.processSubdomainUpdate("scan-1", httpxPath, &lastSize)
+	}
+
+	appendHosts("https://a.example.com\nhttps://b.example.com\n")
+	appendHosts("https://c.example.com\n")
+
+	sent := notifier.sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, "First live host found: a.example.com", sent[0].Title)
+	assert.Equal(t, notification.EventNewSubdomain, sent[0].EventType)
+	assert.Equal(t, "scan-1", sent[0].Fields["Scan"])
+
+	// a monitor started again for the scan does not notify again
+	lastSize = 0
+	appendHosts("https://d.example.com\n")
+	assert.Len(t, notifier.sent(), 1)
+}
+
+func TestDiscoveryNotifier_NoSubdomains(t *testing.T) {
+	writeOutput := func(t *testing.T, dir, tool, content string) {
+		t.Helper()
+		name := tool + "_output.txt"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+		require.NoError(t, tools.WriteOutputManifest(filepath.Join(dir, tools.OutputManifestFile(tool)), &tools.OutputManifest{
+			Tool:  tool,
+			Stage: tools.StageSubdomain,
+			Files: []string{name},
+		}))
+	}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		outputs map[string]string
+		stage   tools.Stage
+		notify  bool
+		fields  string
+	}{
+		{name: "empty outputs", enabled: true, outputs: map[string]string{"subfinder": "", "assetfinder": "\n\n"}, stage: tools.StageSubdomain, notify: true,
+			fields: "assetfinder_output.txt: 0 lines\nsubfinder_output.txt: 0 lines"},
+		{name: "no outputs", enabled: true, stage: tools.StageSubdomain, notify: true},
+		{name: "subdomains found", enabled: true, outputs: map[string]string{"subfinder": "", "assetfinder": "a.example.com\n"}, stage: tools.StageSubdomain},
+		{name: "other stage", enabled: true, outputs: map[string]string{"subfinder": ""}, stage: tools.StageRecon},
+		{name: "disabled", outputs: map[string]string{"subfinder": ""}, stage: tools.StageSubdomain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for tool, content := range tt.outputs {
+				writeOutput(t, dir, tool, content)
+			}
+			discovery, notifier := newTestDiscoveryNotifier(false, tt.enabled)
+			discovery.stageCompleted("scan-1", "example.com", dir, tt.stage)
+
+			sent := notifier.sent()
+			if !tt.notify {
+				assert.Empty(t, sent)
+				return
+			}
+			require.Len(t, sent, 1)
+			assert.Equal(t, "No subdomains found for example.com", sent[0].Title)
+			assert.Equal(t, notification.EventNewSubdomain, sent[0].EventType)
+			assert.Equal(t, tt.fields, sent[0].Fields["Outputs"])
+		})
+	}
+}
+
+func TestDiscoveryNotifier_NilIsOff(t *testing.T) {
+	var discovery *discoveryNotifier
+	discovery.subdomainsSaved("scan-1", []string{"a.example.com"}, 1, 1)
+	discovery.stageCompleted("scan-1", "example.com", t.TempDir(), tools.StageSubdomain)
+}
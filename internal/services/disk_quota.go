@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"pipeliner/pkg/logger"
+)
+
+// errDiskQuotaExceeded ends the run of a scan whose directory outgrew the
+// disk quota. The monitor has marked the scan failed already.
+var errDiskQuotaExceeded = errors.New("disk quota exceeded")
+
+// WithDiskQuota fails a running scan once its directory holds more than
+// quotaMB megabytes, and stops any of its tools whose output alone grows
+// past that. 0 is no limit.
+func WithDiskQuota(quotaMB int) ScanServiceOption {
+	return func(s *scanService) {
+		s.diskQuotaMB = quotaMB
+	}
+}
+
+// monitorDiskQuota sums the size of scanDir every DiskQuotaInterval. Once it
+// is over the quota, the scan is marked failed and stop ends its run.
+func (m *ScanMonitor) monitorDiskQuota(scanID, scanDir string, ctx context.Context, stop func()) {
+	quota := int64(m.diskQuotaMB) << 20
+	for {
+		select {
+		case <-m.after(m.config.DiskQuotaInterval):
+		case <-ctx.Done():
+			return
+		}
+
+		size, err := dirSize(scanDir)
+		if err != nil {
+			m.logger.Warn("Failed to measure scan directory", logger.Fields{"error": err, "dir": scanDir, "scan_id": scanID})
+			continue
+		}
+		if size > quota {
+			m.logger.Error("Scan directory over the disk quota, stopping scan", logger.Fields{
+				"scan_id":  scanID,
+				"dir":      scanDir,
+				"size_mb":  size >> 20,
+				"quota_mb": m.diskQuotaMB,
+			})
+			m.statusManager.MarkFailedWithReason(scanID, errDiskQuotaExceeded.Error())
+			stop()
+			return
+		}
+	}
+}
+
+// dirSize is the total size of the regular files under dir. Files removed
+// while it walks are skipped.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path != dir {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
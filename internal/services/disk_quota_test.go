@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pipeliner/internal/config"
+	"pipeliner/internal/models"
+	"pipeliner/pkg/engine"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/queue"
+	"pipeliner/pkg/testutil"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSizedFile(t *testing.T, path string, size int) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, make([]byte, size), 0644))
+}
+
+func TestScanMonitor_DiskQuota(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Status: models.ScanRunning}))
+
+	log := logger.NewLogger(logrus.ErrorLevel)
+	m := newScanMonitor(subdomainDao, log, NewScanLocks(), nil, newScanStatusManager(scanDao, log, nil), config.DefaultMonitorConfig(), nil)
+	m.diskQuotaMB = 1
+	clock := testutil.NewFakeClock(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+	m.after = clock.After
+
+	scanDir := t.TempDir()
+	writeSizedFile(t, filepath.Join(scanDir, "subfinder_output.txt"), 600<<10)
+
+	var stopped atomic.Bool
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.monitorDiskQuota("scan-1", scanDir, ctx, func() { stopped.Store(true) })
+	}()
+
+	// under the quota, nothing happens
+	clock.WaitForWaiters(t, 1)
+	clock.Advance(30 * time.Second)
+	clock.WaitForWaiters(t, 1)
+	assert.False(t, stopped.Load())
+
+	// files in subdirectories count too
+	writeSizedFile(t, filepath.Join(scanDir, "ffuf", "a.example.com_ffuf_output.json"), 600<<10)
+	clock.Advance(30 * time.Second)
+	<-done
+
+	assert.True(t, stopped.Load())
+	scan, err := scanDao.GetScanByUUID("scan-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanFailed, scan.Status)
+	assert.Equal(t, "disk quota exceeded", scan.ErrorMessage)
+}
+
+func TestExecute_DiskQuotaFailsScan(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	var scanID atomic.Value
+	scanID.Store("")
+	factory := func(...engine.OptFunc) (ScanEngine, error) {
+		return &fakeEngine{scanDir: t.TempDir(), logger: logger.NewLogger(logrus.ErrorLevel), run: func(scanDir string) error {
+			writeSizedFile(t, filepath.Join(scanDir, "ffuf_output.json"), 2<<20)
+			// as a tool the run's cancellation killed
+			deadline := time.Now().Add(5 * time.Second)
+			for time.Now().Before(deadline) {
+				if scan, _ := scanDao.GetScanSummary(scanID.Load().(string)); scan != nil && scan.Status == models.ScanFailed {
+					return errors.New("signal: killed")
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			return nil
+		}}, nil
+	}
+	monitor := config.DefaultMonitorConfig()
+	monitor.DiskQuotaInterval = 10 * time.Millisecond
+	svc := NewScanService(scanDao, subdomainDao, WithQueue(queue.New(1)), WithEngineFactory(factory), WithMonitorConfig(monitor), WithDiskQuota(1))
+
+	id, err := svc.StartScan(&models.Scan{ScanType: "full", Domain: "example.com"})
+	require.NoError(t, err)
+	scanID.Store(id)
+	scan := waitForFinished(t, scanDao, id)
+
+	assert.Equal(t, models.ScanFailed, scan.Status)
+	assert.Equal(t, "disk quota exceeded", scan.ErrorMessage)
+}
@@ -0,0 +1,277 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// exportPageSize is how many subdomains or findings an export loads at a
+// time, so a scan of any size is written in bounded memory.
+var exportPageSize = 1000
+
+// exportCSVColumns are the columns of a CSV export, one row per subdomain.
+var exportCSVColumns = []string{
+	"domain", "status", "status_code", "title", "content_length", "technologies", "open_ports",
+	"potential_false_ports", "vulns", "dir_fuzzing", "sensitive", "redirects", "screenshot", "last_seen_at",
+}
+
+// ExportServiceMethods assembles everything about a scan into one report.
+type ExportServiceMethods interface {
+	// Export loads the scan to export, or returns ErrScanNotFound, before
+	// anything is written.
+	Export(id string) (*ScanExport, error)
+	ForTeam(teamID uint, admin bool) ExportServiceMethods
+}
+
+type exportService struct {
+	scanDao      dao.ScanDAO
+	subdomainDao dao.SubdomainDAO
+	findingDao   dao.FindingDAO
+}
+
+func NewExportService(scanDao dao.ScanDAO, subdomainDao dao.SubdomainDAO, findingDao dao.FindingDAO) ExportServiceMethods {
+	return &exportService{scanDao: scanDao, subdomainDao: subdomainDao, findingDao: findingDao}
+}
+
+// ForTeam returns the service as teamID sees it, as ScanServiceMethods'
+// ForTeam does.
+func (s *exportService) ForTeam(teamID uint, admin bool) ExportServiceMethods {
+	scoped := *s
+	if !admin {
+		scoped.scanDao = s.scanDao.ForTeam(teamID)
+	}
+	return &scoped
+}
+
+func (s *exportService) Export(id string) (*ScanExport, error) {
+	scan, err := s.scanDao.GetScanSummary(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScanNotFound
+		}
+		return nil, err
+	}
+	return &ScanExport{Scan: scan, subdomainDao: s.subdomainDao, findingDao: s.findingDao}, nil
+}
+
+// ScanExport writes a scan's report. Subdomains and findings are read a
+// page at a time while writing.
+type ScanExport struct {
+	Scan         *models.Scan
+	subdomainDao dao.SubdomainDAO
+	findingDao   dao.FindingDAO
+}
+
+// exportedScan is the scan metadata at the top of a JSON export.
+type exportedScan struct {
+	UUID            string               `json:"uuid"`
+	ScanType        string               `json:"scan_type"`
+	Status          models.ScanStatus    `json:"status"`
+	Domain          string               `json:"domain"`
+	NumberOfDomains int                  `json:"number_of_domains"`
+	SeverityCounts  map[string]int       `json:"severity_counts,omitempty"`
+	ErrorMessage    string               `json:"error_message,omitempty"`
+	HookWarnings    []models.HookWarning `json:"hook_warnings,omitempty"`
+	ToolWarnings    []models.ToolWarning `json:"tool_warnings,omitempty"`
+	Tags            []string             `json:"tags,omitempty"`
+	ConfigRevision  string               `json:"config_revision,omitempty"`
+	TemplatesRef    string               `json:"templates_ref,omitempty"`
+	RerunOf         string               `json:"rerun_of,omitempty"`
+	ReprocessedAt   int64                `json:"reprocessed_at,omitempty"`
+	CreatedAt       int64                `json:"created_at"`
+	UpdatedAt       int64                `json:"updated_at"`
+}
+
+// WriteJSON writes the scan, its failed tools, subdomains and findings as
+// one JSON object.
+func (e *ScanExport) WriteJSON(w io.Writer) error {
+	scan := e.Scan
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	failedTools := scan.FailedTools
+	if failedTools == nil {
+		failedTools = []models.ToolFailure{}
+	}
+	bw.WriteString(`{"scan":`)
+	if err := enc.Encode(exportedScan{
+		UUID:            scan.UUID,
+		ScanType:        scan.ScanType,
+		Status:          scan.Status,
+		Domain:          scan.Domain,
+		NumberOfDomains: scan.NumberOfDomains,
+		SeverityCounts:  scan.SeverityCounts,
+		ErrorMessage:    scan.ErrorMessage,
+		HookWarnings:    scan.HookWarnings,
+		ToolWarnings:    scan.ToolWarnings,
+		Tags:            scan.Tags,
+		ConfigRevision:  scan.ConfigRevision,
+		TemplatesRef:    scan.TemplatesRef,
+		RerunOf:         scan.RerunOf,
+		ReprocessedAt:   scan.ReprocessedAt,
+		CreatedAt:       scan.CreatedAt,
+		UpdatedAt:       scan.UpdatedAt,
+	}); err != nil {
+		return err
+	}
+	bw.WriteString(`,"failed_tools":`)
+	if err := enc.Encode(failedTools); err != nil {
+		return err
+	}
+
+	bw.WriteString(`,"subdomains":[`)
+	first := true
+	err := e.eachSubdomain(func(subdomains []models.Subdomain) error {
+		for i := range subdomains {
+			if !first {
+				bw.WriteByte(',')
+			}
+			first = false
+			if err := enc.Encode(&subdomains[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	bw.WriteString(`],"findings":[`)
+	first = true
+	err = e.eachFinding(func(findings []models.Finding) error {
+		for i := range findings {
+			if !first {
+				bw.WriteByte(',')
+			}
+			first = false
+			if err := enc.Encode(&findings[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	bw.WriteString("]}\n")
+	return bw.Flush()
+}
+
+// WriteCSV writes one row per subdomain, with the values of multi-valued
+// columns joined by ';'. Each page of rows is flushed to w as it is
+// written.
+func (e *ScanExport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportCSVColumns); err != nil {
+		return err
+	}
+	err := e.eachSubdomain(func(subdomains []models.Subdomain) error {
+		for _, sub := range subdomains {
+			if err := cw.Write(subdomainCSVRow(sub)); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func subdomainCSVRow(sub models.Subdomain) []string {
+	sensitive := make([]string, 0, len(sub.Sensitive))
+	for _, s := range sub.Sensitive {
+		sensitive = append(sensitive, fmt.Sprintf("%s [%d] %s", s.URL, s.Status, s.Severity))
+	}
+	redirects := make([]string, 0, len(sub.Redirects))
+	for _, r := range sub.Redirects {
+		redirects = append(redirects, fmt.Sprintf("%s [%d] -> %s", r.URL, r.Status, r.Location))
+	}
+	row := []string{
+		sub.Domain,
+		string(sub.Status),
+		formatOptionalInt(int64(sub.StatusCode)),
+		sub.Title,
+		formatOptionalInt(int64(sub.ContentLength)),
+		strings.Join(sub.Technologies, ";"),
+		strings.Join(sub.OpenPorts, ";"),
+		strings.Join(sub.PotentialFalsePorts, ";"),
+		strings.Join(sub.Vulns, ";"),
+		strings.Join(sub.DirFuzzing, ";"),
+		strings.Join(sensitive, ";"),
+		strings.Join(redirects, ";"),
+		sub.Screenshot,
+		formatOptionalInt(sub.LastSeenAt),
+	}
+	for i, cell := range row {
+		row[i] = csvSafe(cell)
+	}
+	return row
+}
+
+func formatOptionalInt(v int64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+// csvSafe keeps a spreadsheet from running a cell as a formula, since
+// titles and URLs come from the scanned hosts.
+func csvSafe(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func (e *ScanExport) eachSubdomain(fn func([]models.Subdomain) error) error {
+	for page := 1; ; page++ {
+		subdomains, err := e.subdomainDao.GetSubdomainsPaginated(e.Scan.UUID, "", page, exportPageSize)
+		if err != nil {
+			return fmt.Errorf("load subdomains: %w", err)
+		}
+		if len(subdomains) > 0 {
+			if err := fn(subdomains); err != nil {
+				return err
+			}
+		}
+		if len(subdomains) < exportPageSize {
+			return nil
+		}
+	}
+}
+
+func (e *ScanExport) eachFinding(fn func([]models.Finding) error) error {
+	if e.findingDao == nil {
+		return nil
+	}
+	for page := 1; ; page++ {
+		findings, err := e.findingDao.ListFindings(e.Scan.UUID, models.FindingFilter{}, page, exportPageSize)
+		if err != nil {
+			return fmt.Errorf("load findings: %w", err)
+		}
+		if len(findings) > 0 {
+			if err := fn(findings); err != nil {
+				return err
+			}
+		}
+		if len(findings) < exportPageSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestExportService(t *testing.T) (ExportServiceMethods, dao.ScanDAO, dao.SubdomainDAO, dao.FindingDAO) {
+	t.Helper()
+	db := newTestDB(t)
+	scanDao, subdomainDao, findingDao := dao.NewScanDAO(db), dao.NewSubdomainDAO(db), dao.NewFindingDAO(db)
+	return NewExportService(scanDao, subdomainDao, findingDao), scanDao, subdomainDao, findingDao
+}
+
+func TestExportService_JSON(t *testing.T) {
+	exportPageSize = 2
+	t.Cleanup(func() { exportPageSize = 1000 })
+	exports, scanDao, subdomainDao, findingDao := newTestExportService(t)
+
+	require.NoError(t, scanDao.SaveScan(&models.Scan{
+		UUID:        "scan-1",
+		ScanType:    "full",
+		Domain:      "example.com",
+		Status:      models.ScanCompletedWithWarnings,
+		FailedTools: []models.ToolFailure{{ToolName: "nuclei", Error: "exit status 1"}},
+	}))
+	var subdomains []models.Subdomain
+	for i := 0; i < 5; i++ {
+		subdomains = append(subdomains, models.Subdomain{Domain: fmt.Sprintf("h%d.example.com", i)})
+	}
+	subdomains[0].OpenPorts = []string{"80", "443"}
+	subdomains[0].DirFuzzing = []string{"https://h0.example.com/admin [302]"}
+	subdomains[0].Vulns = []string{"[HIGH] exposed-panel - https://h0.example.com/admin"}
+	_, err := subdomainDao.AddSubdomains("scan-1", subdomains)
+	require.NoError(t, err)
+	var findings []models.Finding
+	for i := 0; i < 3; i++ {
+		findings = append(findings, models.Finding{Subdomain: "h0.example.com", TemplateID: fmt.Sprintf("t-%d", i), MatchedAt: "https://h0.example.com/", Severity: "high"})
+	}
+	_, err = findingDao.AddFindings("scan-1", findings)
+	require.NoError(t, err)
+
+	export, err := exports.Export("scan-1")
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, export.WriteJSON(&buf))
+
+	var report struct {
+		Scan struct {
+			UUID   string `json:"uuid"`
+			Status string `json:"status"`
+		} `json:"scan"`
+		FailedTools []models.ToolFailure `json:"failed_tools"`
+		Subdomains  []models.Subdomain   `json:"subdomains"`
+		Findings    []models.Finding     `json:"findings"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report), buf.String())
+	assert.Equal(t, "scan-1", report.Scan.UUID)
+	assert.Equal(t, "completed_with_warnings", report.Scan.Status)
+	assert.Equal(t, []models.ToolFailure{{ToolName: "nuclei", Error: "exit status 1"}}, report.FailedTools)
+	// every page, in the order found
+	require.Len(t, report.Subdomains, 5)
+	assert.Equal(t, "h4.example.com", report.Subdomains[4].Domain)
+	assert.Equal(t, []string{"80", "443"}, report.Subdomains[0].OpenPorts)
+	assert.Len(t, report.Findings, 3)
+}
+
+func TestExportService_EmptyScanIsValidJSON(t *testing.T) {
+	exports, scanDao, _, _ := newTestExportService(t)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Domain: "example.com", Status: models.ScanQueued}))
+
+	export, err := exports.Export("scan-1")
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, export.WriteJSON(&buf))
+
+	var report map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report), buf.String())
+	assert.Equal(t, []any{}, report["failed_tools"])
+	assert.Equal(t, []any{}, report["subdomains"])
+	assert.Equal(t, []any{}, report["findings"])
+}
+
+func TestExportService_CSV(t *testing.T) {
+	exports, scanDao, subdomainDao, _ := newTestExportService(t)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Domain: "example.com", Status: models.ScanCompleted}))
+	_, err := subdomainDao.AddSubdomains("scan-1", []models.Subdomain{
+		{
+			Domain:       "a.example.com",
+			Status:       models.SubdomainAlive,
+			StatusCode:   200,
+			Title:        "=HYPERLINK(\"https://evil.example.net\")",
+			Technologies: []string{"nginx", "PHP"},
+			OpenPorts:    []string{"80", "443"},
+			Vulns:        []string{"[HIGH] exposed-panel - https://a.example.com/admin"},
+			Redirects:    []models.Redirect{{URL: "https://a.example.com/out", Status: 301, Location: "https://b.example.net/", External: true}},
+		},
+		{Domain: "b.example.com"},
+	})
+	require.NoError(t, err)
+
+	export, err := exports.Export("scan-1")
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, export.WriteCSV(&buf))
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, exportCSVColumns, rows[0])
+	row := make(map[string]string)
+	for i, column := range rows[0] {
+		row[column] = rows[1][i]
+	}
+	assert.Equal(t, "a.example.com", row["domain"])
+	assert.Equal(t, "alive", row["status"])
+	assert.Equal(t, "200", row["status_code"])
+	assert.Equal(t, "nginx;PHP", row["technologies"])
+	assert.Equal(t, "80;443", row["open_ports"])
+	assert.Equal(t, "https://a.example.com/out [301] -> https://b.example.net/", row["redirects"])
+	// not run as a formula by a spreadsheet
+	assert.Equal(t, "'=HYPERLINK(\"https://evil.example.net\")", row["title"])
+	assert.Equal(t, "b.example.com", rows[2][0])
+}
+
+func TestExportService_ForTeam(t *testing.T) {
+	exports, scanDao, _, _ := newTestExportService(t)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Domain: "example.com", Status: models.ScanCompleted, TeamID: 1}))
+
+	_, err := exports.ForTeam(2, false).Export("scan-1")
+	assert.ErrorIs(t, err, ErrScanNotFound)
+	_, err = exports.ForTeam(2, true).Export("scan-1")
+	assert.NoError(t, err)
+	_, err = exports.Export("missing")
+	assert.ErrorIs(t, err, ErrScanNotFound)
+}
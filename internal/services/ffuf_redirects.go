@@ -0,0 +1,97 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"pipeliner/internal/models"
+	"pipeliner/pkg/export"
+	"pipeliner/pkg/hostlimit"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/parsers"
+	"pipeliner/pkg/scoring"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// externalRedirectTemplate is the template id of the findings for
+	// redirects that leave the target's registrable domain.
+	externalRedirectTemplate = "ffuf-external-redirect"
+	externalRedirectName     = "External redirect"
+	externalRedirectSeverity = "low"
+)
+
+// newRedirect is the redirect of an ffuf result, with its location resolved
+// against the request URL. It is false for results without a redirect or
+// with a location that does not parse.
+func newRedirect(r parsers.FuffResult) (models.Redirect, bool) {
+	location := strings.TrimSpace(r.RedirectLocation)
+	if location == "" {
+		return models.Redirect{}, false
+	}
+	base, err := url.Parse(r.URL)
+	if err != nil {
+		return models.Redirect{}, false
+	}
+	ref, err := url.Parse(location)
+	if err != nil {
+		return models.Redirect{}, false
+	}
+	resolved := base.ResolveReference(ref)
+	return models.Redirect{
+		URL:      r.URL,
+		Status:   r.Status,
+		Location: resolved.String(),
+		External: leavesDomain(base.Hostname(), resolved.Hostname()),
+	}, true
+}
+
+// leavesDomain reports whether a redirect from host from to host to crosses
+// registrable domains, e.g. to an SSO provider or a controllable location.
+func leavesDomain(from, to string) bool {
+	if to == "" {
+		return false
+	}
+	return !strings.EqualFold(hostlimit.Key(from), hostlimit.Key(to))
+}
+
+// addRedirectFindings records the external redirects of subdomain i as
+// findings, scored like the other ffuf results.
+func (a *ArtifactProcessor) addRedirectFindings(scan *models.Scan, i int, redirects []models.Redirect) {
+	if len(redirects) == 0 {
+		return
+	}
+	policy := a.severity.forScan(scan.UUID)
+	findings := make([]models.Finding, 0, len(redirects))
+	for _, redirect := range redirects {
+		score := policy.Apply(scoring.Subject{Host: redirect.URL, Tool: "ffuf", Category: "redirect"}, externalRedirectSeverity)
+		vulnEntry := export.FormatVuln(score.Severity, externalRedirectName, redirect.URL)
+		if !slices.Contains(scan.Subdomains[i].Vulns, vulnEntry) {
+			scan.Subdomains[i].Vulns = append(scan.Subdomains[i].Vulns, vulnEntry)
+			if scan.SeverityCounts == nil {
+				scan.SeverityCounts = make(map[string]int)
+			}
+			scan.SeverityCounts[score.Severity]++
+		}
+		findings = append(findings, models.Finding{
+			Subdomain:        scan.Subdomains[i].Domain,
+			TemplateID:       externalRedirectTemplate,
+			MatchedAt:        redirect.URL,
+			Name:             externalRedirectName,
+			Severity:         score.Severity,
+			OriginalSeverity: externalRedirectSeverity,
+			Labels:           score.Labels,
+			Description:      fmt.Sprintf("Redirects with %d to %s, outside %s", redirect.Status, redirect.Location, hostlimit.Key(redirect.URL)),
+			Tags:             []string{"redirect"},
+			Timestamp:        time.Now().Unix(),
+		})
+		a.logger.Info("External redirect found", logger.Fields{"scan_id": scan.UUID, "url": redirect.URL, "location": redirect.Location})
+	}
+
+	if a.findingDao != nil {
+		if _, err := a.findingDao.AddFindings(scan.UUID, findings); err != nil {
+			a.logger.Error("Failed to store redirect findings", logger.Fields{"error": err, "scan_id": scan.UUID})
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"testing"
+
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/parsers"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRedirect(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		location string
+		want     string
+		external bool
+	}{
+		{"relative path", "https://a.example.com/admin/", "login", "https://a.example.com/admin/login", false},
+		{"absolute path", "https://a.example.com/admin", "/login?next=%2Fadmin", "https://a.example.com/login?next=%2Fadmin", false},
+		{"parent path", "https://a.example.com/a/b/c", "../d", "https://a.example.com/a/d", false},
+		{"same registrable domain", "https://a.example.com/sso", "https://login.example.com/auth", "https://login.example.com/auth", false},
+		{"other domain", "https://a.example.com/sso", "https://example.okta.com/app", "https://example.okta.com/app", true},
+		{"protocol relative", "https://a.example.com/r", "//evil.example.net/x", "https://evil.example.net/x", true},
+		{"suffix lookalike", "https://a.example.com/r", "https://example.com.evil.net/", "https://example.com.evil.net/", true},
+		{"scheme change only", "http://a.example.com/", "https://a.example.com/", "https://a.example.com/", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redirect, ok := newRedirect(parsers.FuffResult{URL: tt.url, Status: 302, RedirectLocation: tt.location})
+			require.True(t, ok)
+			assert.Equal(t, tt.want, redirect.Location)
+			assert.Equal(t, tt.external, redirect.External)
+			assert.Equal(t, 302, redirect.Status)
+		})
+	}
+
+	_, ok := newRedirect(parsers.FuffResult{URL: "https://a.example.com/", Status: 200})
+	assert.False(t, ok, "no redirect")
+}
+
+func TestArtifactProcessor_FfufRedirects(t *testing.T) {
+	findingDao := dao.NewFindingDAO(newTestDB(t))
+	a := newArtifactProcessor(nil, nil, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), nil, nil)
+	a.findingDao = findingDao
+
+	scan := &models.Scan{UUID: "scan-1", Subdomains: []models.Subdomain{{Domain: "a.example.com"}}}
+	results := []parsers.FuffResult{
+		{URL: "https://a.example.com/admin", Status: 302, RedirectLocation: "/login"},
+		{URL: "https://a.example.com/out", Status: 301, RedirectLocation: "https://attacker.example.net/"},
+		{URL: "https://a.example.com/index", Status: 200},
+	}
+	a.addFfufResults(scan, 0, results, "")
+	// the whole output is parsed again on every update
+	a.addFfufResults(scan, 0, results, "")
+
+	assert.Equal(t, []models.Redirect{
+		{URL: "https://a.example.com/admin", Status: 302, Location: "https://a.example.com/login"},
+		{URL: "https://a.example.com/out", Status: 301, Location: "https://attacker.example.net/", External: true},
+	}, scan.Subdomains[0].Redirects)
+	assert.Equal(t, []string{"[LOW] External redirect - https://a.example.com/out"}, scan.Subdomains[0].Vulns)
+	assert.Equal(t, map[string]int{"low": 1}, scan.SeverityCounts)
+
+	findings, err := findingDao.ListFindings("scan-1", models.FindingFilter{}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	assert.Equal(t, externalRedirectTemplate, findings[0].TemplateID)
+	assert.Equal(t, "low", findings[0].Severity)
+	assert.Equal(t, "a.example.com", findings[0].Subdomain)
+	assert.Contains(t, findings[0].Description, "https://attacker.example.net/")
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"pipeliner/internal/models"
+	"pipeliner/internal/vulndb"
+	"pipeliner/pkg/logger"
+	"sync/atomic"
+)
+
+// findingEnricher fills in the scores of findings from the local vulndb
+// dataset. Nothing is downloaded during a scan; a missing dataset leaves
+// findings with the scores of their nuclei templates.
+type findingEnricher struct {
+	cache  *vulndb.Cache
+	logger *logger.Logger
+	// warned is set while the dataset is unavailable, so the warning is
+	// logged once rather than on every monitor pass.
+	warned atomic.Bool
+}
+
+func newFindingEnricher(cache *vulndb.Cache, logger *logger.Logger) *findingEnricher {
+	return &findingEnricher{cache: cache, logger: logger}
+}
+
+// load picks up a refreshed dataset before a batch of findings is enriched.
+func (e *findingEnricher) load() {
+	if err := e.cache.Load(); err != nil {
+		if !e.warned.Swap(true) {
+			e.logger.Warn("Vulnerability dataset unavailable, findings only get the scores of their templates; run refresh-vulndb to download it",
+				logger.Fields{"path": e.cache.Path(), "error": err})
+		}
+		return
+	}
+	e.warned.Store(false)
+}
+
+// enrich sets the finding's scores to the highest the dataset has for any
+// of its CVEs. Scores the dataset does not know are left as they are.
+func (e *findingEnricher) enrich(f *models.Finding) {
+	var best vulndb.Score
+	for _, cve := range f.CVEs {
+		score, ok := e.cache.Lookup(cve)
+		if !ok {
+			continue
+		}
+		best.EPSS = max(best.EPSS, score.EPSS)
+		best.Percentile = max(best.Percentile, score.Percentile)
+		best.CVSS = max(best.CVSS, score.CVSS)
+	}
+	if best.EPSS > 0 {
+		f.EPSSScore, f.EPSSPercentile = best.EPSS, best.Percentile
+	}
+	if best.CVSS > 0 {
+		f.CVSSScore = best.CVSS
+	}
+}
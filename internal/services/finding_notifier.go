@@ -0,0 +1,240 @@
+package services
+
+import (
+	"fmt"
+	"pipeliner/internal/notification"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/parsers"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The first findingBurst sensitive hits on a subdomain within findingWindow
+// are sent one by one; the rest are summed up in one message when the window
+// closes. Sends are spaced by findingSendInterval to stay under Discord's
+// rate limit.
+var (
+	findingBurst        = 3
+	findingWindow       = 30 * time.Second
+	findingSendInterval = 500 * time.Millisecond
+)
+
+const (
+	findingQueueSize      = 1024
+	findingSummaryEntries = 10
+)
+
+type sensitiveHit struct {
+	scanID string
+	domain string
+	url    string
+	status int
+	// pattern carries the hit's severity after the severity rules, which
+	// may also add labels.
+	pattern parsers.SensitivePattern
+	labels  []string
+}
+
+type findingWindowState struct {
+	opened time.Time
+	sent   int
+	held   []sensitiveHit
+}
+
+// findingNotifier sends sensitive endpoint notifications from its own
+// goroutine, so a slow Discord never holds up artifact processing or the
+// scan lock.
+type findingNotifier struct {
+	client   notification.Notifier
+	logger   *logger.Logger
+	hits     chan sensitiveHit
+	burst    int
+	window   time.Duration
+	interval time.Duration
+	// done asks run to send what it has and stop, which it reports by
+	// closing stopped
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+
+	// only touched by run
+	windows  map[string]*findingWindowState
+	lastSend time.Time
+}
+
+func newFindingNotifier(client notification.Notifier, logger *logger.Logger) *findingNotifier {
+	n := &findingNotifier{
+		client:   client,
+		logger:   logger,
+		hits:     make(chan sensitiveHit, findingQueueSize),
+		burst:    findingBurst,
+		window:   findingWindow,
+		interval: findingSendInterval,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		windows:  make(map[string]*findingWindowState),
+	}
+	go n.run()
+	return n
+}
+
+// close sends the queued hits and the held summaries right away, without
+// waiting for their windows to close, and stops the notifier.
+func (n *findingNotifier) close() {
+	n.closeOnce.Do(func() { close(n.done) })
+	<-n.stopped
+}
+
+// notify queues hit without blocking. Hits are dropped when the queue is
+// full rather than stalling the caller.
+func (n *findingNotifier) notify(hit sensitiveHit) {
+	select {
+	case n.hits <- hit:
+	default:
+		n.logger.Warn("Finding notification queue full, dropping notification", logger.Fields{"scan_id": hit.scanID, "url": hit.url})
+	}
+}
+
+func (n *findingNotifier) run() {
+	defer close(n.stopped)
+	ticker := time.NewTicker(n.window / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case hit := <-n.hits:
+			n.add(hit, time.Now())
+		case now := <-ticker.C:
+			n.flush(now)
+		case <-n.done:
+			n.drain()
+			return
+		}
+	}
+}
+
+// drain adds the hits still queued and sends everything held.
+func (n *findingNotifier) drain() {
+	for {
+		select {
+		case hit := <-n.hits:
+			n.add(hit, time.Now())
+		default:
+			for key, w := range n.windows {
+				n.sendHeld(w)
+				delete(n.windows, key)
+			}
+			return
+		}
+	}
+}
+
+func (n *findingNotifier) add(hit sensitiveHit, now time.Time) {
+	key := hit.scanID + "|" + hit.domain
+	w := n.windows[key]
+	if w != nil && now.Sub(w.opened) >= n.window {
+		n.sendHeld(w)
+		w = nil
+	}
+	if w == nil {
+		w = &findingWindowState{opened: now}
+		n.windows[key] = w
+	}
+
+	if w.sent < n.burst {
+		w.sent++
+		n.send(hitMessage(hit))
+		return
+	}
+	w.held = append(w.held, hit)
+}
+
+// flush closes the windows that are over and sends their held hits.
+func (n *findingNotifier) flush(now time.Time) {
+	for key, w := range n.windows {
+		if now.Sub(w.opened) >= n.window {
+			n.sendHeld(w)
+			delete(n.windows, key)
+		}
+	}
+}
+
+func (n *findingNotifier) sendHeld(w *findingWindowState) {
+	if len(w.held) > 0 {
+		n.send(summaryMessage(w.held))
+		w.held = nil
+	}
+}
+
+func (n *findingNotifier) send(msg notification.Message) {
+	if wait := time.Until(n.lastSend.Add(n.interval)); wait > 0 {
+		time.Sleep(wait)
+	}
+	if err := n.client.Send(msg); err != nil {
+		n.logger.WithError(err).Error("Failed to send sensitive finding notification")
+	}
+	n.lastSend = time.Now()
+}
+
+func hitMessage(hit sensitiveHit) notification.Message {
+	emoji := parsers.GetSeverityEmoji(hit.pattern.Severity)
+	msg := notification.Message{
+		Title:       fmt.Sprintf("%s Sensitive Endpoint Found!", emoji),
+		Description: fmt.Sprintf("**%s**\n`%s` [%d]", hit.pattern.Description, hit.url, hit.status),
+		Severity:    hit.pattern.Severity,
+		EventType:   notification.EventFinding,
+		Fields: map[string]string{
+			"Category": hit.pattern.Category,
+			"Pattern":  hit.pattern.Pattern,
+			"Domain":   hit.domain,
+			"Status":   fmt.Sprintf("%d", hit.status),
+		},
+	}
+	if len(hit.labels) > 0 {
+		msg.Fields["Labels"] = strings.Join(hit.labels, ", ")
+	}
+	return msg
+}
+
+// summaryMessage coalesces hits on one subdomain, reported with the highest
+// severity among them.
+func summaryMessage(hits []sensitiveHit) notification.Message {
+	severity := hits[0].pattern.Severity
+	var lines []string
+	for i, hit := range hits {
+		if severityRank(hit.pattern.Severity) > severityRank(severity) {
+			severity = hit.pattern.Severity
+		}
+		if i < findingSummaryEntries {
+			lines = append(lines, fmt.Sprintf("`%s` [%d] %s", hit.url, hit.status, hit.pattern.Description))
+		}
+	}
+	if len(hits) > findingSummaryEntries {
+		lines = append(lines, fmt.Sprintf("…and %d more", len(hits)-findingSummaryEntries))
+	}
+
+	return notification.Message{
+		Title:       fmt.Sprintf("%s %d More Sensitive Endpoints Found", parsers.GetSeverityEmoji(severity), len(hits)),
+		Description: strings.Join(lines, "\n"),
+		Severity:    severity,
+		EventType:   notification.EventFinding,
+		Fields: map[string]string{
+			"Domain": hits[0].domain,
+			"Count":  fmt.Sprintf("%d", len(hits)),
+		},
+	}
+}
+
+func severityRank(severity string) int {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return 4
+	case "high":
+		return 3
+	case "medium":
+		return 2
+	case "low":
+		return 1
+	}
+	return 0
+}
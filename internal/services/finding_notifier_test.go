@@ -0,0 +1,140 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pipeliner/internal/models"
+	"pipeliner/internal/notification"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/parsers"
+	"pipeliner/pkg/tools"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowSession takes delay for every embed, like a rate limited Discord.
+type slowSession struct {
+	delay  time.Duration
+	mu     sync.Mutex
+	embeds []*discordgo.MessageEmbed
+}
+
+func (s *slowSession) SendEmbed(channelID string, embed *discordgo.MessageEmbed) error {
+	time.Sleep(s.delay)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.embeds = append(s.embeds, embed)
+	return nil
+}
+
+func (s *slowSession) Close() error { return nil }
+
+func (s *slowSession) sent() []*discordgo.MessageEmbed {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*discordgo.MessageEmbed(nil), s.embeds...)
+}
+
+func TestArtifactProcessor_SlowNotifierDoesNotDelayUpdate(t *testing.T) {
+	oldWindow, oldInterval := findingWindow, findingSendInterval
+	findingWindow, findingSendInterval = time.Second, 0
+	t.Cleanup(func() { findingWindow, findingSendInterval = oldWindow, oldInterval })
+
+	const hits = 50
+	scanDir := t.TempDir()
+	var results []string
+	for i := 0; i < hits; i++ {
+		results = append(results, fmt.Sprintf(`{"url":"https://a.example.com/dump_%d.sql","status":200}`, i))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(scanDir, "run_1.json"),
+		[]byte(`{"results":[`+strings.Join(results, ",")+`]}`), 0644))
+	require.NoError(t, tools.WriteOutputManifest(filepath.Join(scanDir, tools.OutputManifestFile("ffuf")), &tools.OutputManifest{
+		Tool:    "ffuf",
+		Outputs: map[string]string{"https://a.example.com": "run_1.json"},
+	}))
+
+	scanDao, subdomainDao := newTestDAOs(t)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Subdomains: []models.Subdomain{{Domain: "a.example.com"}}}))
+
+	session := &slowSession{delay: 100 * time.Millisecond}
+	client := notification.NewNotificationClientWithSession(session, notification.ChannelRoutes{Default: "findings"})
+	a := newArtifactProcessor(scanDao, subdomainDao, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), client, nil)
+
+	start := time.Now()
+	a.UpdateArtifacts("scan-1", scanDir)
+	// sending 50 messages inline would take at least 5s
+	assert.Less(t, time.Since(start), time.Second)
+
+	scan, err := scanDao.GetScanByUUID("scan-1")
+	require.NoError(t, err)
+	assert.Len(t, scan.Subdomains[0].DirFuzzing, hits)
+
+	// the first findingBurst hits go out on their own, the rest in one summary
+	require.Eventually(t, func() bool { return len(session.sent()) == findingBurst+1 }, 3*time.Second, 20*time.Millisecond)
+	sent := session.sent()
+	assert.Contains(t, sent[0].Title, "Sensitive Endpoint Found")
+	assert.Contains(t, sent[findingBurst].Title, fmt.Sprintf("%d More Sensitive Endpoints", hits-findingBurst))
+	assert.Contains(t, sent[findingBurst].Description, fmt.Sprintf("and %d more", hits-findingBurst-findingSummaryEntries))
+}
+
+func TestFindingNotifier_WindowsArePerSubdomain(t *testing.T) {
+	session := &slowSession{}
+	n := &findingNotifier{
+		client:  notification.NewNotificationClientWithSession(session, notification.ChannelRoutes{Default: "findings"}),
+		logger:  logger.NewLogger(logrus.ErrorLevel),
+		burst:   1,
+		window:  time.Minute,
+		windows: make(map[string]*findingWindowState),
+	}
+
+	now := time.Now()
+	n.add(sensitiveHit{scanID: "scan-1", domain: "a.example.com", pattern: sensitivePattern("high")}, now)
+	n.add(sensitiveHit{scanID: "scan-1", domain: "a.example.com", pattern: sensitivePattern("critical")}, now)
+	n.add(sensitiveHit{scanID: "scan-1", domain: "a.example.com", pattern: sensitivePattern("low")}, now)
+	n.add(sensitiveHit{scanID: "scan-1", domain: "b.example.com", pattern: sensitivePattern("low")}, now)
+	assert.Len(t, session.sent(), 2)
+
+	// nothing is flushed before the window closes
+	n.flush(now.Add(30 * time.Second))
+	assert.Len(t, session.sent(), 2)
+
+	n.flush(now.Add(time.Minute))
+	sent := session.sent()
+	require.Len(t, sent, 3)
+	assert.Contains(t, sent[2].Title, "2 More Sensitive Endpoints")
+	assert.Empty(t, n.windows)
+}
+
+func sensitivePattern(severity string) parsers.SensitivePattern {
+	return parsers.SensitivePattern{Pattern: "/x", Severity: severity, Description: "test"}
+}
+
+func TestFindingNotifier_CloseSendsHeldHits(t *testing.T) {
+	oldWindow, oldInterval := findingWindow, findingSendInterval
+	findingWindow, findingSendInterval = time.Hour, 0
+	t.Cleanup(func() { findingWindow, findingSendInterval = oldWindow, oldInterval })
+
+	session := &slowSession{}
+	n := newFindingNotifier(notification.NewNotificationClientWithSession(session, notification.ChannelRoutes{Default: "findings"}), logger.NewLogger(logrus.ErrorLevel))
+	for i := 0; i < findingBurst+2; i++ {
+		n.notify(sensitiveHit{scanID: "scan-1", domain: "a.example.com", url: fmt.Sprintf("https://a.example.com/%d", i), pattern: sensitivePattern("high")})
+	}
+
+	// the window is an hour long, so only closing sends the summary
+	n.close()
+	sent := session.sent()
+	require.Len(t, sent, findingBurst+1)
+	assert.Contains(t, sent[findingBurst].Title, "2 More Sensitive Endpoints")
+
+	// closing again does not block
+	n.close()
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// pendingScans tracks the cancel funcs of scans still waiting for a queue
+// slot. Whoever takes an entry first owns the scan: the executor to run it,
+// or CancelScan to drop it.
+type pendingScans struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func newPendingScans() *pendingScans {
+	return &pendingScans{cancels: make(map[string]context.CancelFunc)}
+}
+
+func (p *pendingScans) add(scanID string, cancel context.CancelFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cancels[scanLockKey(scanID)] = cancel
+}
+
+func (p *pendingScans) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.cancels)
+}
+
+func (p *pendingScans) take(scanID string) (context.CancelFunc, bool) {
+	key := scanLockKey(scanID)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cancel, ok := p.cancels[key]
+	if ok {
+		delete(p.cancels, key)
+	}
+	return cancel, ok
+}
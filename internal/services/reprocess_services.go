@@ -0,0 +1,296 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+	"pipeliner/internal/utils"
+	"pipeliner/internal/vulndb"
+	"pipeliner/pkg/blobstore"
+	"pipeliner/pkg/engine"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/scoring"
+	"pipeliner/pkg/tools"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// reprocessPageSize is how many scans ReprocessAll lists at a time.
+const reprocessPageSize = 100
+
+// ReprocessResult is what parsing a scan's outputs again changed, or would
+// have in a dry run.
+type ReprocessResult struct {
+	ScanID string
+	// Skipped says why the scan was left alone, e.g. it is still running.
+	Skipped string
+	// Subdomains are the domains whose results changed.
+	Subdomains []string
+	// Findings is how many findings the scan did not have yet.
+	Findings int
+	Err      error
+}
+
+// Changed reports whether reprocessing changed any results.
+func (r ReprocessResult) Changed() bool {
+	return len(r.Subdomains) > 0 || r.Findings > 0
+}
+
+// ReprocessServiceMethods runs the artifact parsers again over the
+// directories of finished scans, so old scans pick up parser improvements.
+type ReprocessServiceMethods interface {
+	// Reprocess parses one scan's outputs again. It returns ErrScanNotFound
+	// for unknown scans; a scan that cannot be reprocessed is Skipped.
+	Reprocess(scanID string, dryRun bool) (ReprocessResult, error)
+	// ReprocessAll reprocesses every scan that is no longer active, of
+	// scanType unless it is empty, newest first, passing each result to
+	// report.
+	ReprocessAll(scanType string, dryRun bool, report func(ReprocessResult)) error
+}
+
+// ReprocessOption configures the reprocess service.
+type ReprocessOption func(*reprocessService)
+
+// WithReprocessArtifactStore reads the scans' outputs from store, as
+// WithArtifactStore does for the scan service.
+func WithReprocessArtifactStore(store blobstore.Store) ReprocessOption {
+	return func(s *reprocessService) {
+		s.store = store
+	}
+}
+
+// WithReprocessVulnDB scores the findings by their CVEs from cache.
+func WithReprocessVulnDB(cache *vulndb.Cache) ReprocessOption {
+	return func(s *reprocessService) {
+		s.vulnDB = cache
+	}
+}
+
+// WithReprocessSeverityRules scores the findings by rules.
+func WithReprocessSeverityRules(rules []scoring.Rule) ReprocessOption {
+	return func(s *reprocessService) {
+		s.severityRules = rules
+	}
+}
+
+type reprocessService struct {
+	scanDao       dao.ScanDAO
+	subdomainDao  dao.SubdomainDAO
+	findingDao    dao.FindingDAO
+	logger        *logger.Logger
+	store         blobstore.Store
+	vulnDB        *vulndb.Cache
+	severityRules []scoring.Rule
+	// loadModule decodes the module a scan ran with, for its severity rules
+	loadModule func(origin utils.ModuleOrigin, scanType string) (*tools.ChainConfig, error)
+	now        func() time.Time
+}
+
+func NewReprocessService(scanDao dao.ScanDAO, subdomainDao dao.SubdomainDAO, findingDao dao.FindingDAO, opts ...ReprocessOption) ReprocessServiceMethods {
+	s := &reprocessService{
+		scanDao:      scanDao,
+		subdomainDao: subdomainDao,
+		findingDao:   findingDao,
+		logger:       logger.NewLogger(logrus.WarnLevel),
+		loadModule:   engine.LoadModuleAt,
+		now:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *reprocessService) ReprocessAll(scanType string, dryRun bool, report func(ReprocessResult)) error {
+	// the ids are listed up front so reprocessing does not move the pages
+	filter := models.ScanFilter{
+		ScanType: scanType,
+		Statuses: []models.ScanStatus{models.ScanCompleted, models.ScanCompletedWithWarnings, models.ScanFailed, models.ScanCancelled},
+	}
+	var ids []string
+	for page := 1; ; page++ {
+		scans, _, err := s.scanDao.ListScansFiltered(filter, page, reprocessPageSize)
+		if err != nil {
+			return fmt.Errorf("list scans: %w", err)
+		}
+		for _, scan := range scans {
+			ids = append(ids, scan.UUID)
+		}
+		if len(scans) < reprocessPageSize {
+			break
+		}
+	}
+
+	for _, id := range ids {
+		result, err := s.Reprocess(id, dryRun)
+		if err != nil {
+			result = ReprocessResult{ScanID: id, Err: err}
+		}
+		report(result)
+	}
+	return nil
+}
+
+func (s *reprocessService) Reprocess(scanID string, dryRun bool) (ReprocessResult, error) {
+	result := ReprocessResult{ScanID: scanID}
+	scan, err := s.scanDao.GetScanByUUID(scanID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return result, ErrScanNotFound
+		}
+		return result, err
+	}
+
+	// an active scan's outputs are still being parsed by its monitor
+	if !scan.Status.IsTerminal() {
+		result.Skipped = fmt.Sprintf("scan is %s", scan.Status)
+		return result, nil
+	}
+	if scan.ScanDir == "" {
+		result.Skipped = "no scan directory recorded"
+		return result, nil
+	}
+	if s.store == nil {
+		if _, err := os.Stat(scan.ScanDir); err != nil {
+			result.Skipped = fmt.Sprintf("scan directory %s is gone", scan.ScanDir)
+			return result, nil
+		}
+	}
+
+	// scored as the scan was, so the findings match the entries it stored
+	policy, err := s.severityPolicy(scan)
+	if errors.Is(err, ErrModuleUnavailable) {
+		result.Skipped = err.Error()
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	findings := &reprocessFindings{FindingDAO: s.findingDao, scanID: scanID, dryRun: dryRun}
+	// without a notifier or webhooks, old findings are not announced again
+	processor := newArtifactProcessor(s.scanDao, s.subdomainDao, s.logger, NewScanLocks(), nil, nil)
+	processor.store = s.store
+	if s.findingDao != nil {
+		processor.findingDao = findings
+	}
+	if s.vulnDB != nil {
+		processor.vulns = newFindingEnricher(s.vulnDB, s.logger)
+	}
+	if processor.severity, err = newSeverityPolicies(s.severityRules); err != nil {
+		return result, err
+	}
+	processor.severity.set(scanID, policy)
+
+	before := subdomainSnapshots(scan.Subdomains)
+	processor.processArtifacts(scan, scan.ScanDir)
+	if findings.err != nil {
+		return result, fmt.Errorf("store findings: %w", findings.err)
+	}
+
+	changed := changedSubdomains(before, scan.Subdomains)
+	for _, subdomain := range changed {
+		result.Subdomains = append(result.Subdomains, subdomain.Domain)
+	}
+	result.Findings = findings.added
+	if dryRun {
+		return result, nil
+	}
+
+	if err := s.subdomainDao.UpsertSubdomains(scanID, changed); err != nil {
+		return result, fmt.Errorf("persist subdomains: %w", err)
+	}
+	scan.ReprocessedAt = s.now().Unix()
+	if err := s.scanDao.UpdateScan(scan); err != nil {
+		return result, fmt.Errorf("persist scan: %w", err)
+	}
+	return result, nil
+}
+
+// severityPolicy is the global severity rules with those of the module the
+// scan ran with, as the engine builds them. Scans from before modules were
+// recorded ran with the current ones.
+func (s *reprocessService) severityPolicy(scan *models.Scan) (*scoring.Policy, error) {
+	global, err := scoring.Compile(s.severityRules)
+	if err != nil {
+		return nil, fmt.Errorf("global severity rules: %w", err)
+	}
+	origin := utils.CurrentModuleOrigin()
+	if scan.ConfigSource != "" {
+		var ok bool
+		if origin, ok = utils.ModuleOriginFor(scan.ConfigSource, scan.ConfigRevision); !ok {
+			return nil, fmt.Errorf("%w: %s at %s", ErrModuleUnavailable, scan.ConfigSource, scan.ConfigRevision)
+		}
+	}
+	chainConfig, err := s.loadModule(origin, scan.ScanType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: module %s: %w", ErrModuleUnavailable, scan.ScanType, err)
+	}
+	policy, err := global.With(chainConfig.SeverityRules)
+	if err != nil {
+		return nil, fmt.Errorf("module %s severity rules: %w", scan.ScanType, err)
+	}
+	return policy, nil
+}
+
+// reprocessFindings counts the findings the parsers add to a scan. In a dry
+// run nothing is stored; findings are counted against the scan's stored
+// ones by template and matched-at, as AddFindings matches them.
+type reprocessFindings struct {
+	dao.FindingDAO
+	scanID string
+	dryRun bool
+	known  map[[2]string]bool
+	added  int
+	err    error
+}
+
+func (f *reprocessFindings) AddFindings(scanID string, findings []models.Finding) (int, error) {
+	if !f.dryRun {
+		added, err := f.FindingDAO.AddFindings(scanID, findings)
+		f.added += added
+		if err != nil && f.err == nil {
+			f.err = err
+		}
+		return added, err
+	}
+
+	if f.known == nil {
+		known, err := f.storedFindings()
+		if err != nil {
+			f.err = err
+			return 0, err
+		}
+		f.known = known
+	}
+	added := 0
+	for _, finding := range findings {
+		key := [2]string{finding.TemplateID, finding.MatchedAt}
+		if !f.known[key] {
+			f.known[key] = true
+			added++
+		}
+	}
+	f.added += added
+	return added, nil
+}
+
+func (f *reprocessFindings) storedFindings() (map[[2]string]bool, error) {
+	known := make(map[[2]string]bool)
+	for page := 1; ; page++ {
+		findings, err := f.ListFindings(f.scanID, models.FindingFilter{}, page, exportPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, finding := range findings {
+			known[[2]string{finding.TemplateID, finding.MatchedAt}] = true
+		}
+		if len(findings) < exportPageSize {
+			return known, nil
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+	"pipeliner/internal/utils"
+	"pipeliner/pkg/scoring"
+	"pipeliner/pkg/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// moduleWithRules stands in for loading a scan's module, which has rules.
+func moduleWithRules(rules ...scoring.Rule) func(utils.ModuleOrigin, string) (*tools.ChainConfig, error) {
+	return func(utils.ModuleOrigin, string) (*tools.ChainConfig, error) {
+		return &tools.ChainConfig{SeverityRules: rules}, nil
+	}
+}
+
+func TestReprocessService_Reprocess(t *testing.T) {
+	db := newTestDB(t)
+	scanDao, subdomainDao, findingDao := dao.NewScanDAO(db), dao.NewSubdomainDAO(db), dao.NewFindingDAO(db)
+
+	scanDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scanDir, "httpx_output.json"), []byte(
+		`{"url":"https://a.example.com","input":"a.example.com","status_code":200,"title":"Login","tech":["Nginx"]}`+"\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(scanDir, "nuclei_output.json"), []byte(
+		`{"template-id":"env-file","info":{"name":"Env File","severity":"high"},"host":"a.example.com","matched-at":"https://a.example.com/.env"}
+{"template-id":"panel","info":{"name":"Panel","severity":"info"},"host":"b.example.com","matched-at":"https://b.example.com/admin"}
+`), 0644))
+
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", ScanType: "web_scan", Status: models.ScanCompleted, ScanDir: scanDir}))
+	require.NoError(t, subdomainDao.UpsertSubdomains("scan-1", []models.Subdomain{
+		{Domain: "a.example.com"},
+		{Domain: "b.example.com", Vulns: []string{"[INFO] Panel - https://b.example.com/admin"}},
+		{Domain: "c.example.com"},
+	}))
+	// found when the scan ran, before the parser knew about env files
+	_, err := findingDao.AddFindings("scan-1", []models.Finding{{TemplateID: "panel", MatchedAt: "https://b.example.com/admin"}})
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	svc := NewReprocessService(scanDao, subdomainDao, findingDao).(*reprocessService)
+	svc.loadModule = moduleWithRules()
+	svc.now = func() time.Time { return now }
+
+	result, err := svc.Reprocess("scan-1", true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.example.com"}, result.Subdomains)
+	assert.Equal(t, 1, result.Findings)
+	stored, err := scanDao.GetScanByUUID("scan-1")
+	require.NoError(t, err)
+	assert.Zero(t, stored.ReprocessedAt, "a dry run writes nothing")
+	assert.Zero(t, stored.Subdomains[0].StatusCode)
+	count, err := findingDao.CountFindings("scan-1", models.FindingFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	result, err = svc.Reprocess("scan-1", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.example.com"}, result.Subdomains)
+	assert.Equal(t, 1, result.Findings)
+	stored, err = scanDao.GetScanByUUID("scan-1")
+	require.NoError(t, err)
+	assert.Equal(t, now.Unix(), stored.ReprocessedAt)
+	assert.Equal(t, 200, stored.Subdomains[0].StatusCode)
+	assert.Equal(t, []string{"[HIGH] Env File - https://a.example.com/.env"}, stored.Subdomains[0].Vulns)
+	assert.Equal(t, map[string]int{"high": 1}, stored.SeverityCounts)
+	count, err = findingDao.CountFindings("scan-1", models.FindingFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// reprocessing again changes nothing
+	result, err = svc.Reprocess("scan-1", false)
+	require.NoError(t, err)
+	assert.False(t, result.Changed())
+	count, err = findingDao.CountFindings("scan-1", models.FindingFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	_, err = svc.Reprocess("missing", false)
+	assert.ErrorIs(t, err, ErrScanNotFound)
+}
+
+func TestReprocessService_ReprocessAll(t *testing.T) {
+	db := newTestDB(t)
+	scanDao, subdomainDao, findingDao := dao.NewScanDAO(db), dao.NewSubdomainDAO(db), dao.NewFindingDAO(db)
+
+	scanDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scanDir, "httpx_output.json"), []byte(
+		`{"url":"https://a.example.com","input":"a.example.com","status_code":200}`+"\n"), 0644))
+	scans := []models.Scan{
+		{UUID: "done", ScanType: "web_scan", Status: models.ScanCompleted, ScanDir: scanDir, CreatedAt: 4},
+		{UUID: "no-dir", ScanType: "web_scan", Status: models.ScanFailed, CreatedAt: 3},
+		{UUID: "gone", ScanType: "web_scan", Status: models.ScanCancelled, ScanDir: filepath.Join(scanDir, "gone"), CreatedAt: 2},
+		{UUID: "running", ScanType: "web_scan", Status: models.ScanRunning, ScanDir: scanDir, CreatedAt: 1},
+		{UUID: "other-module", ScanType: "subdomain", Status: models.ScanCompleted, ScanDir: scanDir, CreatedAt: 5},
+	}
+	for i := range scans {
+		require.NoError(t, scanDao.SaveScan(&scans[i]))
+		require.NoError(t, subdomainDao.UpsertSubdomains(scans[i].UUID, []models.Subdomain{{Domain: "a.example.com"}}))
+	}
+
+	var results []ReprocessResult
+	svc := NewReprocessService(scanDao, subdomainDao, findingDao).(*reprocessService)
+	svc.loadModule = moduleWithRules()
+	require.NoError(t, svc.ReprocessAll("web_scan", false, func(r ReprocessResult) { results = append(results, r) }))
+
+	require.Len(t, results, 3, "running scans are not listed")
+	assert.Equal(t, "done", results[0].ScanID)
+	assert.Equal(t, []string{"a.example.com"}, results[0].Subdomains)
+	assert.Equal(t, "no scan directory recorded", results[1].Skipped)
+	assert.Contains(t, results[2].Skipped, "is gone")
+
+	// a running scan asked for by id is skipped
+	result, err := svc.Reprocess("running", false)
+	require.NoError(t, err)
+	assert.Equal(t, "scan is running", result.Skipped)
+	other, err := scanDao.GetScanByUUID("other-module")
+	require.NoError(t, err)
+	assert.Zero(t, other.ReprocessedAt)
+}
+
+func TestReprocessService_ReprocessKeepsModuleSeverityRules(t *testing.T) {
+	db := newTestDB(t)
+	scanDao, subdomainDao, findingDao := dao.NewScanDAO(db), dao.NewSubdomainDAO(db), dao.NewFindingDAO(db)
+
+	scanDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scanDir, "nuclei_output.json"), []byte(
+		`{"template-id":"env-file","info":{"name":"Env File","severity":"medium"},"host":"pay.example.com","matched-at":"https://pay.example.com/.env"}`+"\n"), 0644))
+	// as the scan stored it, with its module's rule raising the finding
+	require.NoError(t, scanDao.SaveScan(&models.Scan{
+		UUID: "scan-1", ScanType: "web_scan", Status: models.ScanCompleted, ScanDir: scanDir,
+		SeverityCounts: map[string]int{"critical": 1},
+	}))
+	require.NoError(t, subdomainDao.UpsertSubdomains("scan-1", []models.Subdomain{
+		{Domain: "pay.example.com", Vulns: []string{"[CRITICAL] Env File - https://pay.example.com/.env"}},
+	}))
+	_, err := findingDao.AddFindings("scan-1", []models.Finding{{TemplateID: "env-file", MatchedAt: "https://pay.example.com/.env", Severity: "critical"}})
+	require.NoError(t, err)
+
+	svc := NewReprocessService(scanDao, subdomainDao, findingDao).(*reprocessService)
+	var loaded string
+	rules := moduleWithRules(scoring.Rule{Host: "pay.example.com", Severity: "critical"})
+	svc.loadModule = func(origin utils.ModuleOrigin, scanType string) (*tools.ChainConfig, error) {
+		loaded = scanType
+		return rules(origin, scanType)
+	}
+
+	result, err := svc.Reprocess("scan-1", false)
+	require.NoError(t, err)
+	assert.Equal(t, "web_scan", loaded)
+	assert.False(t, result.Changed())
+	stored, err := scanDao.GetScanByUUID("scan-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[CRITICAL] Env File - https://pay.example.com/.env"}, stored.Subdomains[0].Vulns)
+	assert.Equal(t, map[string]int{"critical": 1}, stored.SeverityCounts)
+
+	// without its module the findings cannot be scored as they were
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-2", ScanType: "web_scan", Status: models.ScanCompleted, ScanDir: scanDir, ConfigSource: "git@example.com:modules.git", ConfigRevision: "pruned"}))
+	result, err = svc.Reprocess("scan-2", false)
+	require.NoError(t, err)
+	assert.Contains(t, result.Skipped, "no longer available")
+}
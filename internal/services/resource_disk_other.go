@@ -0,0 +1,9 @@
+//go:build !(linux || darwin)
+
+package services
+
+import "errors"
+
+func freeDiskBytes(path string) (uint64, error) {
+	return 0, errors.New("free disk space is not available on this platform")
+}
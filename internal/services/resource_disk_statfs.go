@@ -0,0 +1,15 @@
+//go:build linux || darwin
+
+package services
+
+import "golang.org/x/sys/unix"
+
+// freeDiskBytes is the space unprivileged processes can still write on the
+// filesystem holding path.
+func freeDiskBytes(path string) (uint64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
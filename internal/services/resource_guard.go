@@ -0,0 +1,370 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pipeliner/internal/config"
+	"pipeliner/internal/metrics"
+	"pipeliner/internal/notification"
+	"pipeliner/pkg/logger"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrResourcePressure rejects new scans while the resource guard has the
+// running ones paused.
+var ErrResourcePressure = errors.New("scans are paused until the server has enough free disk space and memory again")
+
+// ResourceSample is what the server has of the resources the guard checks.
+type ResourceSample struct {
+	FreeDiskBytes uint64
+	MemoryBytes   uint64
+}
+
+// ResourceSampler measures the resources the guard checks.
+type ResourceSampler interface {
+	Sample() (ResourceSample, error)
+}
+
+// NewSystemSampler measures the free space of the filesystem holding dir,
+// or of its nearest parent while dir does not exist yet, and the server's
+// resident memory.
+func NewSystemSampler(dir string) ResourceSampler {
+	return systemSampler{dir: dir}
+}
+
+type systemSampler struct {
+	dir string
+}
+
+func (s systemSampler) Sample() (ResourceSample, error) {
+	dir := s.dir
+	free, err := freeDiskBytes(dir)
+	for errors.Is(err, os.ErrNotExist) && filepath.Dir(dir) != dir {
+		dir = filepath.Dir(dir)
+		free, err = freeDiskBytes(dir)
+	}
+	if err != nil {
+		return ResourceSample{}, fmt.Errorf("free disk space of %s: %w", s.dir, err)
+	}
+	return ResourceSample{FreeDiskBytes: free, MemoryBytes: residentMemory()}, nil
+}
+
+// residentMemory is the server's resident set size where /proc has it, and
+// the memory the Go runtime holds otherwise.
+func residentMemory() uint64 {
+	if statm, err := os.ReadFile("/proc/self/statm"); err == nil {
+		if fields := strings.Fields(string(statm)); len(fields) > 1 {
+			if pages, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+				return pages * uint64(os.Getpagesize())
+			}
+		}
+	}
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.Sys
+}
+
+// ResourceGuardStatus is the guard's state as of its last check.
+type ResourceGuardStatus struct {
+	Paused bool `json:"paused"`
+	// Reason is which threshold paused the scans.
+	Reason string `json:"reason,omitempty"`
+	// PausedSince is when the scans were paused, in unix seconds.
+	PausedSince int64 `json:"paused_since,omitempty"`
+	// HeldScans are the scans the guard paused and resumes.
+	HeldScans  []string `json:"held_scans,omitempty"`
+	FreeDiskMB uint64   `json:"free_disk_mb"`
+	MemoryMB   uint64   `json:"memory_mb"`
+	CheckedAt  int64    `json:"checked_at,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// ResourceGuard pauses the running scans and refuses new ones while the
+// scan root is short of disk space or the server uses too much memory, and
+// resumes the scans it paused once both are back past the resume
+// thresholds. Scans paused by a user are left alone.
+type ResourceGuard struct {
+	config  config.ResourceGuardConfig
+	sampler ResourceSampler
+	logger  *logger.Logger
+	now     func() time.Time
+
+	// set by the scan service the guard is given to
+	scans      *scanService
+	notifier   notification.Notifier
+	freeDisk   *metrics.GaugeVec
+	memory     *metrics.GaugeVec
+	pausedFlag *metrics.GaugeVec
+
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	// mu guards status and held only; the guard pauses and resumes scans
+	// without it, as the scan service may call back into the guard
+	mu     sync.Mutex
+	status ResourceGuardStatus
+	held   map[string]bool
+}
+
+func NewResourceGuard(cfg config.ResourceGuardConfig, sampler ResourceSampler) *ResourceGuard {
+	return &ResourceGuard{
+		config:  cfg,
+		sampler: sampler,
+		logger:  logger.NewLogger(logrus.InfoLevel),
+		now:     time.Now,
+		stop:    make(chan struct{}),
+		held:    make(map[string]bool),
+	}
+}
+
+// WithResourceGuard pauses and refuses scans as guard finds resources
+// running low, checking every guard interval.
+func WithResourceGuard(guard *ResourceGuard) ScanServiceOption {
+	return func(s *scanService) {
+		s.resourceGuard = guard
+	}
+}
+
+// attach gives the guard the scans it pauses and where it reports, once
+// the service is built.
+func (g *ResourceGuard) attach(s *scanService, notifier notification.Notifier, registry *metrics.Registry) {
+	g.scans = s
+	g.notifier = notifier
+	g.logger = s.logger
+	if registry != nil {
+		g.freeDisk = registry.NewGaugeVec("pipeliner_resource_free_disk_bytes", "Free disk space on the scan root at the last resource check.")
+		g.memory = registry.NewGaugeVec("pipeliner_resource_memory_bytes", "Resident memory of the server at the last resource check.")
+		g.pausedFlag = registry.NewGaugeVec("pipeliner_resource_guard_paused", "1 while scans are paused for lack of disk space or memory.")
+	}
+}
+
+// run checks right away and then every check interval, until Stop.
+func (g *ResourceGuard) run() {
+	ticker := time.NewTicker(g.config.CheckInterval)
+	defer ticker.Stop()
+	for {
+		g.check()
+		select {
+		case <-ticker.C:
+		case <-g.stop:
+			return
+		}
+	}
+}
+
+// Stop ends the guard's checks. A check under way finishes; scans it
+// paused stay paused.
+func (g *ResourceGuard) Stop() {
+	if g == nil {
+		return
+	}
+	g.stopOnce.Do(func() { close(g.stop) })
+}
+
+// Status is the guard's state as of its last check. A nil guard is never
+// paused.
+func (g *ResourceGuard) Status() ResourceGuardStatus {
+	if g == nil {
+		return ResourceGuardStatus{}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	status := g.status
+	status.HeldScans = g.heldScans()
+	return status
+}
+
+// Paused reports whether scans are paused for lack of resources. A nil
+// guard never pauses.
+func (g *ResourceGuard) Paused() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.status.Paused
+}
+
+func (g *ResourceGuard) check() {
+	sample, err := g.sampler.Sample()
+	g.mu.Lock()
+
+	now := g.now()
+	g.status.CheckedAt = now.Unix()
+	if err != nil {
+		// keep the last decision rather than guess
+		g.status.Error = err.Error()
+		g.mu.Unlock()
+		g.logger.Warn("Failed to check free disk space and memory", logger.Fields{"error": err})
+		return
+	}
+	g.status.Error = ""
+	g.status.FreeDiskMB = sample.FreeDiskBytes >> 20
+	g.status.MemoryMB = sample.MemoryBytes >> 20
+	if g.freeDisk != nil {
+		g.freeDisk.Set(float64(sample.FreeDiskBytes))
+		g.memory.Set(float64(sample.MemoryBytes))
+	}
+
+	reason := g.pressure(sample)
+	wasPaused := g.status.Paused
+	var release []string
+	switch {
+	case !wasPaused && reason != "":
+		g.status.Paused, g.status.Reason, g.status.PausedSince = true, reason, now.Unix()
+	case wasPaused && g.recovered(sample):
+		release = g.heldScans()
+		g.held = make(map[string]bool)
+		g.status.Paused, g.status.Reason, g.status.PausedSince = false, "", 0
+	case wasPaused && reason != "":
+		g.status.Reason = reason
+	}
+	paused := g.status.Paused
+	status := g.status
+	if g.pausedFlag != nil {
+		flag := 0.0
+		if paused {
+			flag = 1
+		}
+		g.pausedFlag.Set(flag)
+	}
+	g.mu.Unlock()
+
+	switch {
+	case paused && !wasPaused:
+		g.logger.Error("Pausing scans, server is low on resources", logger.Fields{"reason": reason})
+		held := g.holdRunning()
+		g.notify(notification.Message{
+			Title:       "Scans paused: server is low on resources",
+			Description: reason + ". Running scans start no new tools and new scans are refused until it recovers.",
+			Severity:    "high",
+			EventType:   notification.EventScanLifecycle,
+			Fields:      map[string]string{"Paused scans": strconv.Itoa(held)},
+			Timestamp:   now,
+		})
+	case wasPaused && !paused:
+		g.logger.Info("Resuming scans, server resources recovered", logger.Fields{"scans": release})
+		g.resumeScans(release)
+		g.notify(notification.Message{
+			Title:       "Scans resumed: server resources recovered",
+			Description: fmt.Sprintf("%d MB of disk space free, %d MB of memory in use.", status.FreeDiskMB, status.MemoryMB),
+			Severity:    "info",
+			EventType:   notification.EventScanLifecycle,
+			Fields:      map[string]string{"Resumed scans": strconv.Itoa(len(release))},
+			Timestamp:   now,
+		})
+	case paused:
+		// scans that started or were resumed since the last check
+		g.holdRunning()
+	}
+}
+
+// pressure says which limit sample is past, or is empty.
+func (g *ResourceGuard) pressure(sample ResourceSample) string {
+	if limit := uint64(g.config.MinFreeDiskMB) << 20; limit > 0 && sample.FreeDiskBytes < limit {
+		return fmt.Sprintf("only %d MB of disk space free on the scan root, below %d MB", sample.FreeDiskBytes>>20, g.config.MinFreeDiskMB)
+	}
+	if limit := uint64(g.config.MaxMemoryMB) << 20; limit > 0 && sample.MemoryBytes > limit {
+		return fmt.Sprintf("server uses %d MB of memory, above %d MB", sample.MemoryBytes>>20, g.config.MaxMemoryMB)
+	}
+	return ""
+}
+
+// recovered reports whether sample is back past both resume thresholds.
+func (g *ResourceGuard) recovered(sample ResourceSample) bool {
+	if g.config.MinFreeDiskMB > 0 && sample.FreeDiskBytes < uint64(g.config.ResumeFreeDiskMB)<<20 {
+		return false
+	}
+	if g.config.MaxMemoryMB > 0 && sample.MemoryBytes > uint64(g.config.ResumeMemoryMB)<<20 {
+		return false
+	}
+	return true
+}
+
+// scanStarted pauses a scan that got its queue slot while the guard has
+// scans paused, before it starts any tool.
+func (g *ResourceGuard) scanStarted(scanID string) {
+	if g.Paused() {
+		g.hold(scanID)
+	}
+}
+
+// holdRunning pauses every running scan that is not paused yet and returns
+// how many scans the guard holds. The guard keeps the queue slots, so
+// queued scans do not start in their place.
+func (g *ResourceGuard) holdRunning() int {
+	g.mu.Lock()
+	for id := range g.held {
+		if g.scans.running.get(id) == nil {
+			delete(g.held, id)
+		}
+	}
+	g.mu.Unlock()
+	for _, id := range g.scans.running.ids() {
+		g.hold(id)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.held)
+}
+
+// hold pauses a running scan and records it as the guard's. A scan paused
+// just as the guard resumed the others is resumed right away.
+func (g *ResourceGuard) hold(scanID string) {
+	ctrl := g.scans.running.get(scanID)
+	if ctrl == nil {
+		return
+	}
+	if !g.scans.pause(scanID, ctrl, false, false, "scan paused, server is low on resources") {
+		return
+	}
+	g.mu.Lock()
+	paused := g.status.Paused
+	if paused {
+		g.held[scanID] = true
+	}
+	g.mu.Unlock()
+	if !paused {
+		g.resumeScans([]string{scanID})
+	}
+}
+
+// resumeScans resumes scans the guard paused. Ones a user resumed or that
+// finished meanwhile are skipped.
+func (g *ResourceGuard) resumeScans(ids []string) {
+	for _, id := range ids {
+		if err := g.scans.ResumeScan(id); err != nil && !errors.Is(err, ErrScanNotPaused) && !errors.Is(err, ErrScanNotFound) {
+			g.logger.Error("Failed to resume scan", logger.Fields{"scan_id": id, "error": err})
+		}
+	}
+}
+
+func (g *ResourceGuard) heldScans() []string {
+	held := make([]string, 0, len(g.held))
+	for id := range g.held {
+		held = append(held, id)
+	}
+	sort.Strings(held)
+	return held
+}
+
+func (g *ResourceGuard) notify(msg notification.Message) {
+	g.mu.Lock()
+	notifier := g.notifier
+	g.mu.Unlock()
+	if notifier == nil {
+		return
+	}
+	if err := notifier.Send(msg); err != nil {
+		g.logger.Warn("Failed to send resource notification", logger.Fields{"error": err})
+	}
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pipeliner/internal/config"
+	"pipeliner/internal/metrics"
+	"pipeliner/internal/models"
+	"pipeliner/pkg/queue"
+	"pipeliner/pkg/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSampler struct {
+	mu     sync.Mutex
+	sample ResourceSample
+}
+
+func (f *fakeSampler) set(freeDiskMB, memoryMB uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sample = ResourceSample{FreeDiskBytes: freeDiskMB << 20, MemoryBytes: memoryMB << 20}
+}
+
+func (f *fakeSampler) Sample() (ResourceSample, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sample, nil
+}
+
+// holdLease runs a scan's queue slot until the test ends and returns its
+// lease.
+func holdLease(t *testing.T, q queue.Queue, id string) *queue.Lease {
+	t.Helper()
+	leases := make(chan *queue.Lease)
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	go q.ExecuteWithLease(context.Background(), queue.Ticket{ID: id}, func(lease *queue.Lease) error {
+		leases <- lease
+		<-done
+		return nil
+	})
+	return <-leases
+}
+
+func TestResourceGuard_PausesAndResumes(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	q := queue.New(3)
+	sampler := &fakeSampler{}
+	sampler.set(10000, 100)
+	cfg := config.DefaultResourceGuardConfig()
+	cfg.CheckInterval = time.Hour
+	cfg.MaxMemoryMB, cfg.ResumeMemoryMB = 1000, 800
+	guard := NewResourceGuard(cfg, sampler)
+	registry := metrics.NewRegistry()
+	svc := NewScanService(scanDao, subdomainDao, WithQueue(q), WithResourceGuard(guard), WithSLOMetrics(registry, config.DefaultSLOConfig())).(*scanService)
+	notifier := &recordingNotifier{}
+	guard.mu.Lock()
+	guard.notifier = notifier
+	guard.mu.Unlock()
+
+	running := func(id string) *runControl {
+		require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: id, Status: models.ScanRunning}))
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		ctrl := &runControl{ctx: ctx, cancel: cancel, gate: tools.NewPauseGate(), lease: holdLease(t, q, id), report: func(tools.ProgressEvent) {}}
+		svc.running.add(id, ctrl)
+		return ctrl
+	}
+	status := func(id string) models.ScanStatus {
+		scan, err := svc.GetScanByUUID(id)
+		require.NoError(t, err)
+		return scan.Status
+	}
+
+	scan := running("scan-1")
+	userPaused := running("scan-2")
+	require.NoError(t, svc.PauseScan("scan-2", false))
+
+	guard.check()
+	assert.False(t, guard.Paused())
+	assert.Equal(t, uint64(10000), guard.Status().FreeDiskMB)
+
+	sampler.set(500, 100)
+	guard.check()
+	require.True(t, guard.Paused())
+	assert.Contains(t, guard.Status().Reason, "500 MB of disk space")
+	assert.Equal(t, []string{"scan-1"}, guard.Status().HeldScans, "a scan the user paused is not the guard's")
+	assert.True(t, scan.gate.Paused())
+	assert.True(t, scan.lease.Held(), "the guard keeps the queue slot")
+	assert.Equal(t, models.ScanPaused, status("scan-1"))
+	_, err := svc.StartScan(&models.Scan{ScanType: "full", Domain: "example.com"})
+	assert.ErrorIs(t, err, ErrResourcePressure)
+	require.Len(t, notifier.sent(), 1)
+	assert.Contains(t, notifier.sent()[0].Title, "paused")
+
+	// a scan that gets its slot now is held before it starts a tool
+	late := running("scan-3")
+	guard.scanStarted("scan-3")
+	assert.True(t, late.gate.Paused())
+
+	// above the limit but below the resume threshold, nothing changes
+	sampler.set(1500, 100)
+	guard.check()
+	assert.True(t, guard.Paused())
+	assert.Len(t, notifier.sent(), 1)
+
+	sampler.set(3000, 100)
+	guard.check()
+	assert.False(t, guard.Paused())
+	assert.Empty(t, guard.Status().HeldScans)
+	assert.False(t, scan.gate.Paused())
+	assert.False(t, late.gate.Paused())
+	assert.Equal(t, models.ScanRunning, status("scan-1"))
+	assert.True(t, userPaused.gate.Paused(), "the user's pause stays")
+	assert.Equal(t, models.ScanPaused, status("scan-2"))
+	require.Len(t, notifier.sent(), 2)
+	assert.Contains(t, notifier.sent()[1].Title, "resumed")
+
+	sampler.set(3000, 1200)
+	guard.check()
+	assert.True(t, guard.Paused())
+	assert.Contains(t, guard.Status().Reason, "1200 MB of memory")
+	var out strings.Builder
+	_, err = registry.WriteTo(&out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "pipeliner_resource_guard_paused 1")
+	assert.Contains(t, out.String(), "pipeliner_resource_memory_bytes 1.2582912e+09")
+}
+
+func TestResourceGuard_PausesScansWithoutItsLock(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	q := queue.New(1)
+	sampler := &fakeSampler{}
+	sampler.set(10000, 100)
+	cfg := config.DefaultResourceGuardConfig()
+	cfg.CheckInterval = time.Hour
+	guard := NewResourceGuard(cfg, sampler)
+	svc := NewScanService(scanDao, subdomainDao, WithQueue(q), WithResourceGuard(guard)).(*scanService)
+	t.Cleanup(svc.Close)
+
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Status: models.ScanRunning}))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	// progress reporting reads the guard, as the scan's stats page does
+	var reported []bool
+	report := func(tools.ProgressEvent) { reported = append(reported, guard.Paused()) }
+	ctrl := &runControl{ctx: ctx, cancel: cancel, gate: tools.NewPauseGate(), lease: holdLease(t, q, "scan-1"), report: report}
+	svc.running.add("scan-1", ctrl)
+
+	checked := make(chan struct{})
+	go func() {
+		defer close(checked)
+		sampler.set(500, 100)
+		guard.check()
+		sampler.set(10000, 100)
+		guard.check()
+	}()
+	select {
+	case <-checked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the guard paused the scan while holding its lock")
+	}
+	assert.Equal(t, []bool{true, false}, reported)
+	assert.False(t, ctrl.gate.Paused())
+}
+
+func TestResourceGuard_StopEndsChecks(t *testing.T) {
+	sampler := &fakeSampler{}
+	sampler.set(10000, 100)
+	cfg := config.DefaultResourceGuardConfig()
+	cfg.CheckInterval = time.Millisecond
+	guard := NewResourceGuard(cfg, sampler)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		guard.run()
+	}()
+	guard.Stop()
+	guard.Stop()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("run kept checking after Stop")
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"pipeliner/pkg/queue"
+	"pipeliner/pkg/tools"
+	"sync"
+)
+
+// runControl is what PauseScan, ResumeScan and CancelScan need of a scan
+// that is running.
+type runControl struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	gate   *tools.PauseGate
+	lease  *queue.Lease
+	report func(tools.ProgressEvent)
+
+	mu        sync.Mutex
+	resuming  bool
+	finished  bool
+	cancelled bool
+}
+
+// requestCancel marks the scan cancelled unless its run already finished,
+// and reports whether it did.
+func (c *runControl) requestCancel() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.finished {
+		return false
+	}
+	c.cancelled = true
+	return true
+}
+
+// finish ends the run for CancelScan and reports whether a cancel got in
+// first, in which case the executor records the scan as cancelled whatever
+// the tools returned.
+func (c *runControl) finish() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.finished = true
+	return c.cancelled
+}
+
+// runningScans tracks the scans currently executing in this process.
+type runningScans struct {
+	mu   sync.Mutex
+	byID map[string]*runControl
+}
+
+func newRunningScans() *runningScans {
+	return &runningScans{byID: make(map[string]*runControl)}
+}
+
+func (r *runningScans) add(scanID string, ctrl *runControl) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byID[scanLockKey(scanID)] = ctrl
+}
+
+func (r *runningScans) get(scanID string) *runControl {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.byID[scanLockKey(scanID)]
+}
+
+func (r *runningScans) ids() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]string, 0, len(r.byID))
+	for id := range r.byID {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (r *runningScans) remove(scanID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.byID, scanLockKey(scanID))
+}
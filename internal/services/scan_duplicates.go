@@ -0,0 +1,70 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"pipeliner/internal/models"
+	"strings"
+	"time"
+)
+
+// ErrDuplicateScan is what a *DuplicateScanError is.
+var ErrDuplicateScan = errors.New("scan duplicates a queued, running or recent scan")
+
+// DuplicateScanError rejects a scan of the same module and domain as
+// ScanIDs.
+type DuplicateScanError struct {
+	ScanIDs []string
+}
+
+func (e *DuplicateScanError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrDuplicateScan, strings.Join(e.ScanIDs, ", "))
+}
+
+func (e *DuplicateScanError) Is(target error) bool {
+	return target == ErrDuplicateScan
+}
+
+// WithDuplicateCheck sets what StartScan counts as a duplicate: a queued,
+// running or paused scan of the same module and domain, or one that
+// finished less than window ago. Duplicates are only reported in the
+// scan's DuplicateOf, unless strict, which rejects them with a
+// *DuplicateScanError when the scan is not forced.
+func WithDuplicateCheck(window time.Duration, strict bool) ScanServiceOption {
+	return func(s *scanService) {
+		s.duplicateWindow = window
+		s.strictDuplicates = strict
+	}
+}
+
+// findDuplicates lists the scans the new scan duplicates, oldest first.
+func (s *scanService) findDuplicates(scan *models.Scan) ([]string, error) {
+	var finishedSince time.Time
+	if s.duplicateWindow > 0 {
+		finishedSince = time.Now().Add(-s.duplicateWindow)
+	}
+	candidates, err := s.scanDao.ListRecentScans(scan.ScanType, finishedSince)
+	if err != nil {
+		return nil, err
+	}
+	domain := normalizeDomain(scan.Domain)
+	var ids []string
+	for _, candidate := range candidates {
+		if normalizeDomain(candidate.Domain) == domain {
+			ids = append(ids, candidate.UUID)
+		}
+	}
+	return ids, nil
+}
+
+// normalizeDomain reduces a domain, or a URL of one, to its lower-case host.
+func normalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if _, rest, ok := strings.Cut(domain, "://"); ok {
+		domain = rest
+	}
+	if i := strings.IndexAny(domain, "/?#"); i >= 0 {
+		domain = domain[:i]
+	}
+	return strings.TrimSuffix(domain, ".")
+}
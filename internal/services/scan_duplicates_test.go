@@ -0,0 +1,80 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"pipeliner/internal/models"
+	"pipeliner/pkg/engine"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/queue"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanService_DuplicateScans(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	now := time.Now()
+	for i, scan := range []models.Scan{
+		{UUID: "running", ScanType: "full", Domain: "Example.com", Status: models.ScanRunning},
+		{UUID: "inside-window", ScanType: "full", Domain: "example.com.", Status: models.ScanCompleted, UpdatedAt: now.Add(-9 * time.Minute).Unix()},
+		{UUID: "outside-window", ScanType: "full", Domain: "example.com", Status: models.ScanCompleted, UpdatedAt: now.Add(-11 * time.Minute).Unix()},
+		{UUID: "recent-failure", ScanType: "full", Domain: "example.com", Status: models.ScanFailed, UpdatedAt: now.Unix()},
+		{UUID: "other-module", ScanType: "quick", Domain: "example.com", Status: models.ScanQueued},
+		{UUID: "other-domain", ScanType: "full", Domain: "api.example.com", Status: models.ScanQueued},
+	} {
+		scan.CreatedAt = now.Add(time.Duration(i-10) * time.Minute).Unix()
+		require.NoError(t, scanDao.SaveScan(&scan))
+	}
+	factory := func(...engine.OptFunc) (ScanEngine, error) {
+		return &fakeEngine{scanDir: t.TempDir(), logger: logger.NewLogger(logrus.ErrorLevel)}, nil
+	}
+	newService := func(window time.Duration, strict bool) ScanServiceMethods {
+		return NewScanService(scanDao, subdomainDao, WithQueue(queue.New(1)), WithEngineFactory(factory), WithDuplicateCheck(window, strict))
+	}
+
+	t.Run("window boundary", func(t *testing.T) {
+		scan := &models.Scan{ScanType: "full", Domain: "https://EXAMPLE.com/"}
+		id, err := newService(10*time.Minute, false).StartScan(scan)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"running", "inside-window"}, scan.DuplicateOf)
+		waitForFinished(t, scanDao, id)
+		require.NoError(t, scanDao.DeleteScan(id))
+	})
+
+	t.Run("no window only counts unfinished scans", func(t *testing.T) {
+		scan := &models.Scan{ScanType: "full", Domain: "example.com"}
+		id, err := newService(0, false).StartScan(scan)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"running"}, scan.DuplicateOf)
+		waitForFinished(t, scanDao, id)
+		require.NoError(t, scanDao.DeleteScan(id))
+	})
+
+	t.Run("strict rejects unless forced", func(t *testing.T) {
+		svc := newService(10*time.Minute, true)
+		_, err := svc.StartScan(&models.Scan{ScanType: "full", Domain: "example.com"})
+		var duplicate *DuplicateScanError
+		require.True(t, errors.As(err, &duplicate), "error = %v", err)
+		assert.ErrorIs(t, err, ErrDuplicateScan)
+		assert.Equal(t, []string{"running", "inside-window"}, duplicate.ScanIDs)
+
+		forced := &models.Scan{ScanType: "full", Domain: "example.com", Force: true}
+		id, err := svc.StartScan(forced)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"running", "inside-window"}, forced.DuplicateOf)
+		waitForFinished(t, scanDao, id)
+
+		// a rerun is always forced
+		rerun, err := svc.RerunScan(id)
+		require.NoError(t, err)
+		waitForFinished(t, scanDao, rerun)
+
+		id, err = svc.StartScan(&models.Scan{ScanType: "full", Domain: "example.net"})
+		require.NoError(t, err)
+		waitForFinished(t, scanDao, id)
+	})
+}
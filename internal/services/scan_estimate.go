@@ -0,0 +1,179 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"pipeliner/internal/models"
+	"pipeliner/pkg/engine"
+	perrors "pipeliner/pkg/errors"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/tools"
+	"strings"
+	"time"
+)
+
+// estimateDurationSamples is how many past scans of a module a duration
+// estimate is scaled from.
+const estimateDurationSamples = 20
+
+// Where a ScanEstimate's subdomain count comes from.
+const (
+	// EstimateSourceRun is a run of the module's estimate tools.
+	EstimateSourceRun = "estimate_run"
+	// EstimateSourceInventory is the domain's last finished scan.
+	EstimateSourceInventory = "inventory"
+	// EstimateSourceNone means there was nothing to go on.
+	EstimateSourceNone = "none"
+)
+
+// ScanEstimate predicts the workload of a scan before it is started.
+type ScanEstimate struct {
+	Module     string         `json:"module"`
+	Domain     string         `json:"domain"`
+	Source     string         `json:"source"`
+	Subdomains int            `json:"subdomains"`
+	Tools      []ToolEstimate `json:"tools"`
+	// EstimatedDurationSeconds scales the module's last DurationSamples
+	// finished scans to Subdomains; both are 0 without history.
+	EstimatedDurationSeconds int64 `json:"estimated_duration_seconds"`
+	DurationSamples          int   `json:"duration_samples"`
+}
+
+// ToolEstimate is how many times a tool of the full scan would run: once
+// per subdomain for tools with replace, once otherwise.
+type ToolEstimate struct {
+	Name       string `json:"name"`
+	Iterations int    `json:"iterations"`
+}
+
+// EstimateScan sizes a scan without creating one. The subdomain count comes
+// from running the module's estimate tools in a temporary directory or,
+// for modules without any, from the domain's last finished scan.
+func (s *scanService) EstimateScan(ctx context.Context, scanType, domain string) (*ScanEstimate, error) {
+	chainConfig, err := engine.LoadModule(scanType)
+	if err != nil {
+		if errors.Is(err, perrors.ErrInvalidConfig) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %s", ErrConfigNotFound, scanType)
+	}
+
+	estimate := &ScanEstimate{Module: scanType, Domain: domain, Source: EstimateSourceNone, Tools: []ToolEstimate{}}
+	if len(tools.EstimateTools(chainConfig.Tools)) > 0 {
+		count, err := s.runEstimate(ctx, scanType, domain)
+		if err != nil {
+			return nil, err
+		}
+		estimate.Source, estimate.Subdomains = EstimateSourceRun, count
+	} else {
+		last, err := s.scanDao.LatestFinishedScan(domain)
+		if err != nil {
+			return nil, err
+		}
+		if last != nil {
+			estimate.Source, estimate.Subdomains = EstimateSourceInventory, last.NumberOfDomains
+		}
+	}
+
+	for _, tool := range chainConfig.Tools {
+		if tool.Estimate {
+			continue
+		}
+		iterations := 1
+		if tool.Replace != "" {
+			iterations = estimate.Subdomains
+		}
+		estimate.Tools = append(estimate.Tools, ToolEstimate{Name: tool.Name, Iterations: iterations})
+	}
+
+	history, err := s.scanDao.ListFinishedScans(scanType, estimateDurationSamples)
+	if err != nil {
+		return nil, err
+	}
+	duration := projectDuration(history, estimate.Subdomains)
+	estimate.EstimatedDurationSeconds = int64(duration / time.Second)
+	estimate.DurationSamples = len(history)
+	return estimate, nil
+}
+
+// runEstimate runs the estimate tools in a directory that is removed
+// afterwards and counts the distinct subdomains they found. Tools that
+// failed do not spoil what the others found.
+func (s *scanService) runEstimate(ctx context.Context, scanType, domain string) (int, error) {
+	dir, err := os.MkdirTemp("", "pipeliner-estimate-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	eng, err := s.newEngine(engine.WithContext(ctx), engine.WithEstimate(dir))
+	if err != nil {
+		return 0, err
+	}
+	if err := eng.PrepareScan(&tools.Options{ScanType: scanType, Domain: domain}); err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := eng.Cleanup(); err != nil {
+			s.logger.Warn("Estimate cleanup hooks failed", logger.Fields{"module": scanType, "error": err})
+		}
+	}()
+
+	s.logger.Info("Running scan estimate", logger.Fields{"module": scanType, "domain": domain})
+	if err := eng.RunHTTP(scanType, domain); err != nil {
+		var partial *tools.PartialExecutionError
+		if !errors.As(err, &partial) {
+			return 0, err
+		}
+		s.logger.Warn("Some estimate tools failed", logger.Fields{"module": scanType, "error": err})
+	}
+	return countSubdomainOutputs(eng.ScanDirectory())
+}
+
+// countSubdomainOutputs counts the distinct lines in the outputs of the
+// subdomain stage.
+func countSubdomainOutputs(dir string) (int, error) {
+	artifacts := tools.NewArtifacts(dir)
+	files, err := artifacts.ListByStage(tools.StageSubdomain)
+	if err != nil {
+		return 0, err
+	}
+	seen := make(map[string]bool)
+	for _, file := range files {
+		f, err := artifacts.Open(file.Name)
+		if err != nil {
+			return 0, err
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				seen[line] = true
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return 0, err
+		}
+	}
+	return len(seen), nil
+}
+
+// projectDuration scales past scans to a subdomain count by their time per
+// subdomain, or averages them when they have no counts to scale by.
+func projectDuration(history []models.Scan, subdomains int) time.Duration {
+	if len(history) == 0 {
+		return 0
+	}
+	var seconds, domains int64
+	for _, scan := range history {
+		seconds += scan.UpdatedAt - scan.CreatedAt
+		domains += int64(scan.NumberOfDomains)
+	}
+	if domains == 0 || subdomains == 0 {
+		return time.Duration(seconds/int64(len(history))) * time.Second
+	}
+	return time.Duration(seconds*int64(subdomains)/domains) * time.Second
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pipeliner/internal/models"
+	"pipeliner/internal/utils"
+	"pipeliner/pkg/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const estimateModule = `name: sized
+execution_mode: sequential
+tools:
+  - name: subfinder
+    command: subfinder
+    estimate: true
+  - name: httpx
+    command: httpx
+    replace: "{{domain}}"
+  - name: report
+    command: report
+`
+
+func useModuleDir(t *testing.T, modules map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range modules {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name+".yaml"), []byte(content), 0644))
+	}
+	previous := utils.CurrentModuleOrigin()
+	utils.SetModuleOrigin(utils.ModuleOrigin{Dir: dir, Source: "local"})
+	t.Cleanup(func() { utils.SetModuleOrigin(previous) })
+}
+
+func TestEstimateScan_RunsEstimateTools(t *testing.T) {
+	useModuleDir(t, map[string]string{"sized": estimateModule})
+
+	svc, _, _ := newFakeEngineService(t, func(scanDir string) error {
+		output := "a.example.com\nb.example.com\n\na.example.com\n"
+		if err := os.WriteFile(filepath.Join(scanDir, "subfinder_output.txt"), []byte(output), 0644); err != nil {
+			return err
+		}
+		return tools.WriteOutputManifest(filepath.Join(scanDir, tools.OutputManifestFile("subfinder")),
+			&tools.OutputManifest{Tool: "subfinder", Stage: tools.StageSubdomain, Files: []string{"subfinder_output.txt"}})
+	})
+
+	estimate, err := svc.EstimateScan(context.Background(), "sized", "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, EstimateSourceRun, estimate.Source)
+	assert.Equal(t, 2, estimate.Subdomains)
+	assert.Equal(t, []ToolEstimate{{Name: "httpx", Iterations: 2}, {Name: "report", Iterations: 1}}, estimate.Tools)
+	assert.Zero(t, estimate.DurationSamples)
+	assert.Zero(t, estimate.EstimatedDurationSeconds)
+}
+
+func TestEstimateScan_FallsBackToInventory(t *testing.T) {
+	useModuleDir(t, map[string]string{"plain": `name: plain
+execution_mode: sequential
+tools:
+  - name: httpx
+    command: httpx
+    replace: "{{domain}}"
+`})
+
+	svc, scanDao, _ := newFakeEngineService(t, func(string) error {
+		t.Error("estimate tools ran for a module without any")
+		return nil
+	})
+
+	estimate, err := svc.EstimateScan(context.Background(), "plain", "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, EstimateSourceNone, estimate.Source)
+	assert.Equal(t, []ToolEstimate{{Name: "httpx", Iterations: 0}}, estimate.Tools)
+
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "prev", ScanType: "full", Domain: "example.com", Status: "completed", NumberOfDomains: 5}))
+	estimate, err = svc.EstimateScan(context.Background(), "plain", "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, EstimateSourceInventory, estimate.Source)
+	assert.Equal(t, 5, estimate.Subdomains)
+	assert.Equal(t, []ToolEstimate{{Name: "httpx", Iterations: 5}}, estimate.Tools)
+}
+
+func TestEstimateScan_UnknownModule(t *testing.T) {
+	useModuleDir(t, nil)
+	svc, _, _ := newFakeEngineService(t, nil)
+
+	_, err := svc.EstimateScan(context.Background(), "missing", "example.com")
+	assert.ErrorIs(t, err, ErrConfigNotFound)
+}
+
+func TestProjectDuration(t *testing.T) {
+	history := []models.Scan{
+		{CreatedAt: 0, UpdatedAt: 100, NumberOfDomains: 10},
+		{CreatedAt: 0, UpdatedAt: 300, NumberOfDomains: 30},
+	}
+	assert.Equal(t, 200*time.Second, projectDuration(history, 20))
+	// no subdomains to scale by
+	assert.Equal(t, 200*time.Second, projectDuration(history, 0))
+	assert.Zero(t, projectDuration(nil, 20))
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"pipeliner/internal/models"
+	"sync"
+	"time"
+)
+
+// Types of the events published for a scan while it runs.
+const (
+	// ScanEventStatus carries a StatusEvent.
+	ScanEventStatus = "status"
+	// ScanEventSubdomains carries a SubdomainsEvent.
+	ScanEventSubdomains = "subdomains"
+	// ScanEventProgress carries a ProgressEvent.
+	ScanEventProgress = "progress"
+)
+
+// scanEventBuffer is how many events a subscriber may fall behind by before
+// it misses some.
+const scanEventBuffer = 64
+
+// ScanEvent is something that happened to a scan, for live progress views.
+type ScanEvent struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+type StatusEvent struct {
+	Status       models.ScanStatus `json:"status"`
+	ErrorMessage string            `json:"error_message,omitempty"`
+	FailedTools  []string          `json:"failed_tools,omitempty"`
+}
+
+type SubdomainsEvent struct {
+	// Added is how many of the subdomains found since the last event were new.
+	Added int   `json:"added"`
+	Total int64 `json:"total"`
+}
+
+type ProgressEvent struct {
+	Tool      string    `json:"tool"`
+	Status    string    `json:"status"`
+	Message   string    `json:"message,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// scanEvents hands the events of each scan to whoever subscribed to it. A
+// nil *scanEvents drops everything.
+type scanEvents struct {
+	mu   sync.Mutex
+	subs map[string]map[chan ScanEvent]struct{}
+}
+
+func newScanEvents() *scanEvents {
+	return &scanEvents{subs: make(map[string]map[chan ScanEvent]struct{})}
+}
+
+// subscribe returns the scan's future events and a func to stop them.
+func (e *scanEvents) subscribe(scanID string) (<-chan ScanEvent, func()) {
+	key := scanLockKey(scanID)
+	ch := make(chan ScanEvent, scanEventBuffer)
+
+	e.mu.Lock()
+	if e.subs[key] == nil {
+		e.subs[key] = make(map[chan ScanEvent]struct{})
+	}
+	e.subs[key][ch] = struct{}{}
+	e.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			delete(e.subs[key], ch)
+			if len(e.subs[key]) == 0 {
+				delete(e.subs, key)
+			}
+		})
+	}
+}
+
+// publish never blocks: a subscriber whose buffer is full misses the event.
+func (e *scanEvents) publish(scanID, eventType string, data interface{}) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for ch := range e.subs[scanLockKey(scanID)] {
+		select {
+		case ch <- ScanEvent{Type: eventType, Data: data}:
+		default:
+		}
+	}
+}
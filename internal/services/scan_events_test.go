@@ -0,0 +1,58 @@
+package services
+
+import (
+	"testing"
+
+	"pipeliner/internal/models"
+	"pipeliner/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanEvents_PublishesToSubscribersOfTheScan(t *testing.T) {
+	events := newScanEvents()
+	first, stopFirst := events.subscribe("scan-1")
+	other, stopOther := events.subscribe("scan-2")
+	defer stopOther()
+
+	events.publish("SCAN-1", ScanEventSubdomains, SubdomainsEvent{Added: 1, Total: 1})
+	require.Len(t, first, 1)
+	assert.Equal(t, ScanEvent{Type: ScanEventSubdomains, Data: SubdomainsEvent{Added: 1, Total: 1}}, <-first)
+	assert.Len(t, other, 0)
+
+	// a full buffer drops events instead of blocking the scan
+	for i := 0; i < scanEventBuffer+10; i++ {
+		events.publish("scan-1", ScanEventProgress, ProgressEvent{Tool: "httpx"})
+	}
+	assert.Len(t, first, scanEventBuffer)
+
+	stopFirst()
+	stopFirst()
+	events.publish("scan-1", ScanEventProgress, ProgressEvent{Tool: "httpx"})
+	assert.Len(t, first, scanEventBuffer)
+	assert.NotContains(t, events.subs, "scan-1")
+
+	var none *scanEvents
+	none.publish("scan-1", ScanEventProgress, ProgressEvent{})
+}
+
+func TestScanStatusManager_PublishesStatusChanges(t *testing.T) {
+	scanDao := newTestScanDAO(t)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Status: "queued"}))
+
+	events := newScanEvents()
+	ch, stop := events.subscribe("scan-1")
+	defer stop()
+	m := newScanStatusManager(scanDao, logger.NewLogger(logrus.ErrorLevel), events)
+
+	require.NoError(t, m.MarkRunning("scan-1"))
+	m.MarkFailedWithReason("scan-1", "nuclei crashed")
+	// finished scans are not moved back to running, so nothing is published
+	require.NoError(t, m.MarkRunning("scan-1"))
+
+	require.Len(t, ch, 2)
+	assert.Equal(t, StatusEvent{Status: "running"}, (<-ch).Data)
+	assert.Equal(t, StatusEvent{Status: "failed", ErrorMessage: "nuclei crashed"}, (<-ch).Data)
+}
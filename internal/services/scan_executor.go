@@ -2,15 +2,48 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"pipeliner/internal/models"
+	"pipeliner/internal/notification"
+	"pipeliner/internal/utils"
 	"pipeliner/pkg/engine"
 	"pipeliner/pkg/logger"
+	"pipeliner/pkg/queue"
+	"pipeliner/pkg/scoring"
 	"pipeliner/pkg/tools"
+	"slices"
+	"sync"
+	"sync/atomic"
 
 	"github.com/sirupsen/logrus"
 )
 
+// errScanCancelled ends the run of a scan CancelScan stopped.
+var errScanCancelled = errors.New("scan cancelled")
+
+// ScanEngine is what the executor needs from the engine running a scan.
+type ScanEngine interface {
+	PrepareScan(options *tools.Options) error
+	RunHTTP(scanType, domain string) error
+	Cleanup() error
+	ScanDirectory() string
+	Logger() *logger.Logger
+	ModuleOrigin() utils.ModuleOrigin
+	ConfigChecksum() string
+	TemplatesRef() string
+	HTTPHeaders() map[string]string
+	Proxy() string
+	SeverityPolicy() *scoring.Policy
+}
+
+// EngineFactory builds the engine for one run of a scan.
+type EngineFactory func(opts ...engine.OptFunc) (ScanEngine, error)
+
+func newPiplinerEngine(opts ...engine.OptFunc) (ScanEngine, error) {
+	return engine.NewPiplinerEngine(opts...)
+}
+
 type ScanExecutor struct {
 	scanService *scanService
 }
@@ -19,9 +52,27 @@ func newScanExecutor(s *scanService) *ScanExecutor {
 	return &ScanExecutor{scanService: s}
 }
 
-func (e *ScanExecutor) Execute(scanID, scanType, domain string) {
+// Execute runs a scan; opts adjust its engine, as retries do. priority sets
+// the niceness of its tools under the service's nice policy.
+func (e *ScanExecutor) Execute(ctx context.Context, scanID, scanType, domain string, priority int, opts ...engine.OptFunc) {
 	var scanLogger *logger.ScanLogger
 	var scanDir string
+	hookWarnings := &hookWarningCollector{}
+	toolWarnings := &toolWarningCollector{}
+	emptyOutputs := &emptyOutputCollector{}
+	// set once the scan is marked completed with warnings
+	partial := false
+	summary := newSummaryWriter(e.scanService.scanDao, e.scanService.logger, scanID)
+
+	// runs after the recover below so the final status write is done
+	defer e.scanService.scanLocks.Release(scanID)
+
+	// runs after the recover below so a panic is reported as failed
+	defer e.scanService.webhooks.scanFinished(scanID)
+	defer e.scanService.slo.scanFinished(scanID)
+
+	// after the recover too, so the summary has the final status
+	defer summary.write()
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -39,32 +90,139 @@ func (e *ScanExecutor) Execute(scanID, scanType, domain string) {
 		}
 	}()
 
-	queue := engine.GetGlobalQueue()
-	err := queue.ExecuteWithQueue(func() error {
-		if err := e.scanService.statusManager.UpdateStatus(scanID, "running"); err != nil {
+	err := e.scanService.queue.ExecuteWithLease(ctx, queue.Ticket{ID: scanID, Priority: priority}, func(lease *queue.Lease) error {
+		// claim the scan; if CancelScan got here first it is no longer ours to run
+		release, ok := e.scanService.pending.take(scanID)
+		if !ok {
+			return context.Canceled
+		}
+		defer release()
+
+		if err := e.scanService.statusManager.MarkRunning(scanID); err != nil {
 			e.scanService.logger.Error("Failed to update scan to running", logger.Fields{"scan_id": scanID, "error": err})
 		}
 
 		e.scanService.logger.Info("Starting scan execution", logger.Fields{"scan_id": scanID, "scan_type": scanType, "domain": domain})
 
-		eng, err := engine.NewPiplinerEngine()
+		gate := tools.NewPauseGate()
+		onToolProgress := func(event tools.ProgressEvent) {
+			e.scanService.events.publish(scanID, ScanEventProgress, ProgressEvent{
+				Tool:      event.Tool,
+				Status:    event.Status,
+				Message:   event.Message,
+				Timestamp: event.Timestamp,
+			})
+		}
+		onProgress := func(event tools.ProgressEvent) {
+			if event.DAG != nil {
+				e.scanService.dags.set(scanID, event.DAG)
+				return
+			}
+			onToolProgress(event)
+			if event.Status == tools.ProgressSkipped {
+				summary.toolProgress(event)
+			}
+			if event.Status == tools.ProgressCompletedEmpty {
+				emptyOutputs.add(event.Tool)
+				go e.notifyEmptyOutput(scanID, domain, event)
+			}
+			if scanLogger != nil {
+				scanLogger.WithFields(logger.Fields{
+					"tool":   event.Tool,
+					"status": event.Status,
+				}).Info(event.Message)
+			}
+		}
+
+		// the engine stops with ctx, which CancelScan cancels through release
+		eng, err := e.scanService.newEngine(append([]engine.OptFunc{engine.WithContext(ctx)}, opts...)...)
 		if err != nil {
 			e.scanService.logger.Error("Failed to create engine", logger.Fields{"error": err, "scan_id": scanID})
 			return err
 		}
 
+		// cancellable from here on, and pausable once the tools start; the
+		// monitors keep running while paused
+		ctrl := &runControl{ctx: ctx, cancel: release, gate: gate, lease: lease, report: onProgress}
+		e.scanService.running.add(scanID, ctrl)
+		defer e.scanService.running.remove(scanID)
+		e.scanService.resourceGuard.scanStarted(scanID)
+
 		if err := eng.PrepareScan(&tools.Options{
-			ScanType: scanType,
-			Domain:   domain,
+			ScanType:      scanType,
+			Domain:        domain,
+			ScanID:        scanID,
+			OnHookWarning: hookWarnings.add,
+			OnToolWarning: func(w tools.ToolWarning) {
+				if toolWarnings.add(w) && w.Notify {
+					go e.notifyToolWarning(scanID, domain, w)
+				}
+			},
+			OnHookExecution: func(exec tools.HookExecution) {
+				e.scanService.recordHookExecution(scanID, exec)
+				if exec.Scope == tools.HookScopeCleanup && scanLogger != nil {
+					logCleanupHook(scanLogger, exec)
+				}
+			},
+			OnProgress: onProgress,
+			OnToolProgress: func(event tools.ProgressEvent) {
+				summary.toolProgress(event)
+				onToolProgress(event)
+			},
+			OnStageComplete: func(stage tools.Stage) {
+				summary.stageCompleted(stage)
+				e.scanService.discovery.stageCompleted(scanID, domain, scanDir, stage)
+			},
+			OnChunkComplete: func(progress tools.ChunkProgress) {
+				e.chunkCompleted(scanID, domain, scanDir, progress)
+			},
+			Pause:          gate,
+			Priority:       priority,
+			NicePolicy:     e.scanService.nicePolicy,
+			SeverityPolicy: e.scanService.artifacts.severity.global,
+			DiskQuotaMB:    e.scanService.diskQuotaMB,
 		}); err != nil {
+			if ctrl.finish() {
+				return errScanCancelled
+			}
 			e.scanService.logger.Error("PrepareScan failed", logger.Fields{"error": err, "scan_id": scanID})
 			return err
 		}
 
-		ctx, cancel := context.WithCancel(context.Background())
+		// normally run below once the monitors are done; this covers panics
+		cleanedUp := false
+		defer func() {
+			if !cleanedUp {
+				e.cleanup(scanID, eng)
+			}
+		}()
+
+		e.scanService.artifacts.severity.set(scanID, eng.SeverityPolicy())
+		defer e.scanService.artifacts.severity.remove(scanID)
+
+		if err := e.scanService.statusManager.RecordModuleOrigin(scanID, eng.ModuleOrigin(), eng.ConfigChecksum()); err != nil {
+			e.scanService.logger.Error("Failed to record module revision", logger.Fields{"scan_id": scanID, "error": err})
+		}
+		if ref := eng.TemplatesRef(); ref != "" {
+			if err := e.scanService.statusManager.RecordTemplatesRef(scanID, ref); err != nil {
+				e.scanService.logger.Error("Failed to record templates ref", logger.Fields{"scan_id": scanID, "error": err})
+			}
+		}
+		if err := e.scanService.statusManager.RecordHTTPHeaders(scanID, eng.HTTPHeaders()); err != nil {
+			e.scanService.logger.Error("Failed to record http headers", logger.Fields{"scan_id": scanID, "error": err})
+		}
+		if err := e.scanService.statusManager.RecordProxy(scanID, eng.Proxy()); err != nil {
+			e.scanService.logger.Error("Failed to record proxy", logger.Fields{"scan_id": scanID, "error": err})
+		}
+
+		monitorCtx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
 		scanDir = eng.ScanDirectory()
+		summary.setDir(scanDir)
+		if err := e.scanService.statusManager.RecordScanDirectory(scanID, scanDir); err != nil {
+			e.scanService.logger.Error("Failed to record scan directory", logger.Fields{"scan_id": scanID, "error": err})
+		}
 
 		if scanDir != "" {
 			var logErr error
@@ -72,6 +230,8 @@ func (e *ScanExecutor) Execute(scanID, scanType, domain string) {
 			if logErr != nil {
 				e.scanService.logger.Error("Failed to create scan logger", logger.Fields{"error": logErr, "scan_id": scanID})
 			} else {
+				// the tools log through the engine's logger
+				eng.Logger().AddHook(scanLogger.ToolLogHook())
 				scanLogger.WithFields(logger.Fields{
 					"scan_id":   scanID,
 					"scan_type": scanType,
@@ -81,14 +241,21 @@ func (e *ScanExecutor) Execute(scanID, scanType, domain string) {
 		}
 
 		var monitoringDone chan struct{}
+		var quotaExceeded atomic.Bool
 		if scanDir != "" {
 			monitoringDone = make(chan struct{})
-			go e.scanService.monitor.MonitorScanProgress(scanID, scanType, scanDir, ctx, monitoringDone)
+			stop := func() {
+				quotaExceeded.Store(true)
+				release()
+			}
+			go e.scanService.monitor.MonitorScanProgress(scanID, scanType, scanDir, monitorCtx, monitoringDone, stop)
 		} else {
 			e.scanService.logger.Warn("Scan directory not available for monitoring", logger.Fields{"scan_id": scanID})
 		}
 
 		runErr := eng.RunHTTP(scanType, domain)
+		e.scanService.running.remove(scanID)
+		cancelled := ctrl.finish()
 
 		cancel()
 
@@ -98,8 +265,35 @@ func (e *ScanExecutor) Execute(scanID, scanType, domain string) {
 			e.scanService.logger.Info("Monitors completed, finalizing scan status", logger.Fields{"scan_id": scanID})
 		}
 
+		// only a complete httpx run says which hosts went away
+		if runErr == nil && !cancelled && !quotaExceeded.Load() && scanDir != "" {
+			e.scanService.monitor.reconcileLiveness(context.Background(), scanID, scanDir)
+		}
+
+		// after the monitors, which may still read files the hooks remove
+		e.cleanup(scanID, eng)
+		cleanedUp = true
+
+		if err := e.scanService.statusManager.RecordHookWarnings(scanID, hookWarnings.list()); err != nil {
+			e.scanService.logger.Error("Failed to record hook warnings", logger.Fields{"scan_id": scanID, "error": err})
+		}
+		if err := e.scanService.statusManager.RecordToolWarnings(scanID, toolWarnings.list()); err != nil {
+			e.scanService.logger.Error("Failed to record tool warnings", logger.Fields{"scan_id": scanID, "error": err})
+		}
+		if err := e.scanService.statusManager.RecordEmptyOutputs(scanID, emptyOutputs.list()); err != nil {
+			e.scanService.logger.Error("Failed to record empty outputs", logger.Fields{"scan_id": scanID, "error": err})
+		}
+
+		if cancelled {
+			return errScanCancelled
+		}
+		if quotaExceeded.Load() {
+			return errDiskQuotaExceeded
+		}
+
 		if runErr != nil {
-			if partialErr, ok := runErr.(*tools.PartialExecutionError); ok {
+			var partialErr *tools.PartialExecutionError
+			if errors.As(runErr, &partialErr) {
 				e.scanService.logger.Warn("Scan completed with some tool failures", logger.Fields{
 					"scan_id":      scanID,
 					"failed_count": len(partialErr.FailedTools),
@@ -117,6 +311,7 @@ func (e *ScanExecutor) Execute(scanID, scanType, domain string) {
 				if err := e.scanService.statusManager.MarkCompletedWithWarnings(scanID, partialErr.FailedTools); err != nil {
 					e.scanService.logger.Error("Failed to mark scan as completed with warnings", logger.Fields{"scan_id": scanID, "error": err})
 				}
+				partial = true
 				return nil
 			}
 			return runErr
@@ -125,6 +320,39 @@ func (e *ScanExecutor) Execute(scanID, scanType, domain string) {
 		return runErr
 	})
 
+	if errors.Is(err, context.Canceled) {
+		e.scanService.logger.Info("Scan cancelled before it started", logger.Fields{"scan_id": scanID})
+		return
+	}
+
+	// still queued, so the next server start queues it again
+	if errors.Is(err, queue.ErrClosed) {
+		e.scanService.logger.Info("Server shutting down, scan left queued", logger.Fields{"scan_id": scanID})
+		return
+	}
+
+	if errors.Is(err, errScanCancelled) {
+		e.scanService.logger.Info("Scan cancelled", logger.Fields{"scan_id": scanID})
+		if scanLogger != nil {
+			scanLogger.WithFields(logger.Fields{"scan_id": scanID}).Warn("Scan cancelled")
+			scanLogger.Close()
+		}
+		// CancelScan set the status already, and nothing moves a cancelled scan
+		return
+	}
+
+	if errors.Is(err, errDiskQuotaExceeded) {
+		if scanLogger != nil {
+			scanLogger.LogScanFailure("disk quota exceeded", err, map[string]interface{}{
+				"scan_type": scanType,
+				"domain":    domain,
+			})
+			scanLogger.Close()
+		}
+		// the monitor marked it failed when it stopped the scan
+		return
+	}
+
 	if err != nil {
 		e.scanService.logger.Error("Scan execution failed", logger.Fields{"scan_id": scanID, "error": err})
 
@@ -140,6 +368,10 @@ func (e *ScanExecutor) Execute(scanID, scanType, domain string) {
 		return
 	}
 
+	if partial {
+		return
+	}
+
 	if scanLogger != nil {
 		scanLogger.LogScanSuccess()
 		scanLogger.Close()
@@ -151,6 +383,143 @@ func (e *ScanExecutor) Execute(scanID, scanType, domain string) {
 	}
 }
 
-func (s *scanService) startScanExecution(scan *models.Scan) {
-	s.executor.Execute(scan.UUID, scan.ScanType, scan.Domain)
+// cleanup runs the module's cleanup hooks, whatever the scan's outcome.
+func (e *ScanExecutor) cleanup(scanID string, eng ScanEngine) {
+	if err := eng.Cleanup(); err != nil {
+		e.scanService.logger.Warn("Cleanup hooks failed", logger.Fields{"scan_id": scanID, "error": err})
+	}
+}
+
+// notifyEmptyOutput warns that a tool exited cleanly with nothing to show,
+// which usually means it was rate limited or its API key is missing.
+func (e *ScanExecutor) notifyEmptyOutput(scanID, domain string, event tools.ProgressEvent) {
+	notifier := e.scanService.notifier
+	if notifier == nil {
+		return
+	}
+	msg := notification.Message{
+		Title:       fmt.Sprintf("%s produced no output", event.Tool),
+		Description: "The tool exited cleanly but its output is empty.",
+		Severity:    "low",
+		EventType:   notification.EventScanLifecycle,
+		Fields: map[string]string{
+			"Scan":   scanID,
+			"Domain": domain,
+			"Tool":   event.Tool,
+		},
+	}
+	if err := notifier.Send(msg); err != nil {
+		e.scanService.logger.Warn("Failed to send empty output warning", logger.Fields{"scan_id": scanID, "error": err})
+	}
+}
+
+// notifyToolWarning sends a warning found in a tool's stderr, for the tools
+// with notify_warnings.
+func (e *ScanExecutor) notifyToolWarning(scanID, domain string, w tools.ToolWarning) {
+	notifier := e.scanService.notifier
+	if notifier == nil {
+		return
+	}
+	msg := notification.Message{
+		Title:       fmt.Sprintf("%s warned", w.Tool),
+		Description: w.Line,
+		Severity:    "low",
+		EventType:   notification.EventScanLifecycle,
+		Fields: map[string]string{
+			"Scan":   scanID,
+			"Domain": domain,
+			"Tool":   w.Tool,
+		},
+	}
+	if err := notifier.Send(msg); err != nil {
+		e.scanService.logger.Warn("Failed to send tool warning", logger.Fields{"scan_id": scanID, "error": err})
+	}
+}
+
+func logCleanupHook(scanLogger *logger.ScanLogger, exec tools.HookExecution) {
+	entry := scanLogger.WithFields(logger.Fields{
+		"hook":        exec.Hook,
+		"status":      exec.Status,
+		"duration_ms": exec.FinishedAt.Sub(exec.StartedAt).Milliseconds(),
+	})
+	if exec.Err != nil {
+		entry.WithError(exec.Err).Warn("Cleanup hook finished")
+		return
+	}
+	entry.Info("Cleanup hook finished")
+}
+
+func (s *scanService) startScanExecution(ctx context.Context, scan *models.Scan) {
+	s.executor.Execute(ctx, scan.UUID, scan.ScanType, scan.Domain, scan.Priority)
+}
+
+// hookWarningCollector gathers non-critical hook failures reported by the
+// strategies, which may call it from several goroutines.
+type hookWarningCollector struct {
+	mu       sync.Mutex
+	warnings []tools.HookWarning
+}
+
+func (c *hookWarningCollector) add(w tools.HookWarning) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.warnings = append(c.warnings, w)
+}
+
+func (c *hookWarningCollector) list() []tools.HookWarning {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]tools.HookWarning(nil), c.warnings...)
+}
+
+// maxToolWarnings is how many warnings a scan keeps of each tool, so a
+// tool that warns about every host does not bloat the scan record.
+const maxToolWarnings = 50
+
+// toolWarningCollector gathers the warnings in the tools' stderr, which
+// runs of a tool report from several goroutines.
+type toolWarningCollector struct {
+	mu       sync.Mutex
+	warnings []tools.ToolWarning
+	perTool  map[string]int
+}
+
+// add keeps w unless it repeats an earlier warning or its tool is over
+// maxToolWarnings, and reports whether it did.
+func (c *toolWarningCollector) add(w tools.ToolWarning) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.perTool == nil {
+		c.perTool = make(map[string]int)
+	}
+	if c.perTool[w.Tool] >= maxToolWarnings || slices.Contains(c.warnings, w) {
+		return false
+	}
+	c.perTool[w.Tool]++
+	c.warnings = append(c.warnings, w)
+	return true
+}
+
+func (c *toolWarningCollector) list() []tools.ToolWarning {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]tools.ToolWarning(nil), c.warnings...)
+}
+
+// emptyOutputCollector gathers the tools that reported ProgressCompletedEmpty.
+type emptyOutputCollector struct {
+	mu    sync.Mutex
+	tools []string
+}
+
+func (c *emptyOutputCollector) add(tool string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tools = append(c.tools, tool)
+}
+
+func (c *emptyOutputCollector) list() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.tools...)
 }
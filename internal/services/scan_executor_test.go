@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+	"pipeliner/internal/utils"
+	"pipeliner/pkg/engine"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/queue"
+	"pipeliner/pkg/scoring"
+	"pipeliner/pkg/tools"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEngine stands in for the pipeliner engine; run plays the tool chain.
+type fakeEngine struct {
+	scanDir string
+	logger  *logger.Logger
+	run     func(scanDir string) error
+	options *tools.Options
+}
+
+func (f *fakeEngine) PrepareScan(options *tools.Options) error {
+	f.options = options
+	return nil
+}
+func (f *fakeEngine) Cleanup() error                   { return nil }
+func (f *fakeEngine) ScanDirectory() string            { return f.scanDir }
+func (f *fakeEngine) Logger() *logger.Logger           { return f.logger }
+func (f *fakeEngine) ModuleOrigin() utils.ModuleOrigin { return utils.ModuleOrigin{} }
+func (f *fakeEngine) ConfigChecksum() string           { return "c0ffee" }
+func (f *fakeEngine) TemplatesRef() string             { return "" }
+func (f *fakeEngine) HTTPHeaders() map[string]string   { return nil }
+func (f *fakeEngine) Proxy() string                    { return "" }
+func (f *fakeEngine) SeverityPolicy() *scoring.Policy {
+	if f.options == nil {
+		return nil
+	}
+	return f.options.SeverityPolicy
+}
+func (f *fakeEngine) RunHTTP(scanType, domain string) error {
+	if f.run == nil {
+		return nil
+	}
+	return f.run(f.scanDir)
+}
+
+func newFakeEngineService(t *testing.T, run func(scanDir string) error) (ScanServiceMethods, dao.ScanDAO, dao.SubdomainDAO) {
+	t.Helper()
+	scanDao, subdomainDao := newTestDAOs(t)
+	factory := func(...engine.OptFunc) (ScanEngine, error) {
+		return &fakeEngine{scanDir: t.TempDir(), logger: logger.NewLogger(logrus.ErrorLevel), run: run}, nil
+	}
+	svc := NewScanService(scanDao, subdomainDao, WithQueue(queue.New(1)), WithEngineFactory(factory))
+	return svc, scanDao, subdomainDao
+}
+
+func waitForFinished(t *testing.T, scanDao dao.ScanDAO, id string) *models.Scan {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		scan, err := scanDao.GetScanByUUID(id)
+		require.NoError(t, err)
+		switch scan.Status {
+		case "completed", "completed_with_warnings", "failed", "cancelled":
+			return scan
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("scan %s still %q", id, scan.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestScanExecutor_Completed(t *testing.T) {
+	svc, scanDao, _ := newFakeEngineService(t, nil)
+
+	id, err := svc.StartScan(&models.Scan{ScanType: "full", Domain: "example.com"})
+	require.NoError(t, err)
+
+	scan := waitForFinished(t, scanDao, id)
+	assert.Equal(t, models.ScanCompleted, scan.Status)
+	assert.NotEmpty(t, scan.ScanDir)
+	assert.Equal(t, "c0ffee", scan.ConfigChecksum)
+}
+
+func TestScanExecutor_HigherPriorityStartsFirst(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	var mu sync.Mutex
+	var ran []string // scan directories, in the order the scans ran
+	factory := func(...engine.OptFunc) (ScanEngine, error) {
+		return &fakeEngine{scanDir: t.TempDir(), logger: logger.NewLogger(logrus.ErrorLevel), run: func(scanDir string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, scanDir)
+			return nil
+		}}, nil
+	}
+	q := queue.New(1)
+	svc := NewScanService(scanDao, subdomainDao, WithQueue(q), WithEngineFactory(factory))
+
+	// hold the only slot until every scan is queued
+	release := make(chan struct{})
+	holding := make(chan struct{})
+	go q.ExecuteWithQueue(context.Background(), 0, func() error {
+		close(holding)
+		<-release
+		return nil
+	})
+	<-holding
+
+	var ids []string
+	for i, priority := range []int{0, 0, 10} {
+		id, err := svc.StartScan(&models.Scan{ScanType: "full", Domain: fmt.Sprintf("%d.example.com", i), Priority: priority})
+		require.NoError(t, err)
+		ids = append(ids, id)
+		waitForQueued(t, q, i+1)
+	}
+	assert.Equal(t, []queue.Ticket{{ID: ids[2], Priority: 10}, {ID: ids[0]}, {ID: ids[1]}}, q.Queued())
+
+	close(release)
+	var dirs []string
+	for _, id := range []string{ids[2], ids[0], ids[1]} {
+		dirs = append(dirs, waitForFinished(t, scanDao, id).ScanDir)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, dirs, ran)
+}
+
+func TestScanExecutor_PartialFailure(t *testing.T) {
+	svc, scanDao, _ := newFakeEngineService(t, func(string) error {
+		return &tools.PartialExecutionError{
+			FailedTools: []tools.ToolError{{Tool: "ffuf", Err: errors.New("exit status 1")}},
+		}
+	})
+
+	id, err := svc.StartScan(&models.Scan{ScanType: "full", Domain: "example.com"})
+	require.NoError(t, err)
+
+	scan := waitForFinished(t, scanDao, id)
+	assert.Equal(t, models.ScanCompletedWithWarnings, scan.Status)
+	require.Len(t, scan.FailedTools, 1)
+	assert.Equal(t, "ffuf", scan.FailedTools[0].ToolName)
+}
+
+func TestScanExecutor_Panic(t *testing.T) {
+	svc, scanDao, _ := newFakeEngineService(t, func(string) error {
+		panic("tool chain exploded")
+	})
+
+	id, err := svc.StartScan(&models.Scan{ScanType: "full", Domain: "example.com"})
+	require.NoError(t, err)
+
+	scan := waitForFinished(t, scanDao, id)
+	assert.Equal(t, models.ScanFailed, scan.Status)
+	assert.Contains(t, scan.ErrorMessage, "tool chain exploded")
+}
+
+func TestScanExecutor_SubdomainsSavedBeforeCompletion(t *testing.T) {
+	// written as the run ends, before the monitor's first poll sees it
+	svc, scanDao, subdomainDao := newFakeEngineService(t, func(scanDir string) error {
+		return os.WriteFile(filepath.Join(scanDir, "httpx_output.txt"),
+			[]byte("https://a.example.com\nhttps://b.example.com\n"), 0644)
+	})
+
+	id, err := svc.StartScan(&models.Scan{ScanType: "full", Domain: "example.com"})
+	require.NoError(t, err)
+
+	scan := waitForFinished(t, scanDao, id)
+	assert.Equal(t, models.ScanCompleted, scan.Status)
+
+	count, err := subdomainDao.CountByScan(id)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestScanExecutor_RecordsToolWarnings(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	factory := func(...engine.OptFunc) (ScanEngine, error) {
+		eng := &fakeEngine{scanDir: t.TempDir(), logger: logger.NewLogger(logrus.ErrorLevel)}
+		eng.run = func(string) error {
+			warn := tools.ToolWarning{Tool: "subfinder", Pattern: "(?i)could not run source", Line: "[WRN] Could not run source chaos: quota exceeded"}
+			// a retried tool reports its warnings again
+			eng.options.OnToolWarning(warn)
+			eng.options.OnToolWarning(warn)
+			eng.options.OnToolWarning(tools.ToolWarning{Tool: "nuclei", Pattern: "(?i)could not load template", Line: "[ERR] Could not load template a.yaml"})
+			return nil
+		}
+		return eng, nil
+	}
+	svc := NewScanService(scanDao, subdomainDao, WithQueue(queue.New(1)), WithEngineFactory(factory))
+
+	id, err := svc.StartScan(&models.Scan{ScanType: "full", Domain: "example.com"})
+	require.NoError(t, err)
+	scan := waitForFinished(t, scanDao, id)
+
+	assert.Equal(t, models.ScanCompleted, scan.Status)
+	assert.Equal(t, []models.ToolWarning{
+		{ToolName: "subfinder", Pattern: "(?i)could not run source", Line: "[WRN] Could not run source chaos: quota exceeded"},
+		{ToolName: "nuclei", Pattern: "(?i)could not load template", Line: "[ERR] Could not load template a.yaml"},
+	}, scan.ToolWarnings)
+}
+
+func TestToolWarningCollector_CapsEachTool(t *testing.T) {
+	c := &toolWarningCollector{}
+	for i := 0; i < maxToolWarnings+5; i++ {
+		c.add(tools.ToolWarning{Tool: "subfinder", Line: fmt.Sprintf("warning %d", i)})
+	}
+	assert.True(t, c.add(tools.ToolWarning{Tool: "nuclei", Line: "warning 0"}))
+	assert.Len(t, c.list(), maxToolWarnings+1)
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"strings"
+	"sync"
+)
+
+// ScanLocks hands out one mutex per scan so the monitor and artifact processor
+// serialize their read-modify-write cycles on the same scan record. A scan's
+// mutex is counted while it is held or waited for and dropped after, so it
+// is never replaced while in use.
+type ScanLocks struct {
+	mu    sync.Mutex
+	locks map[string]*scanLock
+}
+
+type scanLock struct {
+	sync.Mutex
+	refs int
+}
+
+func NewScanLocks() *ScanLocks {
+	return &ScanLocks{locks: make(map[string]*scanLock)}
+}
+
+func scanLockKey(scanID string) string {
+	return strings.ToLower(strings.TrimSpace(scanID))
+}
+
+// Lock locks the mutex for scanID, creating it on first use, and returns
+// the function that unlocks it.
+func (l *ScanLocks) Lock(scanID string) (unlock func()) {
+	key := scanLockKey(scanID)
+
+	l.mu.Lock()
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &scanLock{}
+		l.locks[key] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(l.locks, key)
+		}
+	}
+}
+
+// Release waits for any current holder of scanID's mutex to unlock, once
+// the scan has reached a terminal state.
+func (l *ScanLocks) Release(scanID string) {
+	l.Lock(scanID)()
+}
+
+// Len reports how many scans currently have a mutex allocated.
+func (l *ScanLocks) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.locks)
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanLocks_LockNormalizesKey(t *testing.T) {
+	locks := NewScanLocks()
+
+	unlock := locks.Lock("ABC-123")
+	locked := make(chan struct{})
+	go func() {
+		locks.Lock(" abc-123 ")()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("the same scan got a second mutex")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, 1, locks.Len())
+
+	unlock()
+	<-locked
+}
+
+func TestScanLocks_DropsUnusedMutexes(t *testing.T) {
+	locks := NewScanLocks()
+
+	unlock := locks.Lock("scan-1")
+	locks.Lock("scan-2")()
+	assert.Equal(t, 1, locks.Len())
+
+	unlock()
+	assert.Equal(t, 0, locks.Len())
+
+	// releasing an unknown scan is a no-op
+	locks.Release("missing")
+	assert.Equal(t, 0, locks.Len())
+}
+
+func TestScanLocks_ReleaseWaitsForHolder(t *testing.T) {
+	locks := NewScanLocks()
+
+	unlock := locks.Lock("scan-1")
+
+	released := make(chan struct{})
+	go func() {
+		locks.Release("scan-1")
+		close(released)
+	}()
+
+	select {
+	case <-released:
+		t.Fatal("Release returned while the lock was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("Release did not return after unlock")
+	}
+}
+
+func TestScanLocks_ReleaseKeepsTheMutexForWaiters(t *testing.T) {
+	locks := NewScanLocks()
+
+	unlock := locks.Lock("scan-1")
+	go locks.Release("scan-1")
+	// let Release start waiting before the next caller comes along
+	assert.Eventually(t, func() bool {
+		locks.mu.Lock()
+		defer locks.mu.Unlock()
+		return locks.locks["scan-1"].refs == 2
+	}, time.Second, time.Millisecond)
+
+	// the scan is still locked, so a caller after Release has to wait too
+	locked := make(chan struct{})
+	go func() {
+		locks.Lock("scan-1")()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("Lock got a second mutex for a locked scan")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("Lock did not return after unlock")
+	}
+	assert.Eventually(t, func() bool { return locks.Len() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestScanLocks_ConcurrentLockDuringRelease(t *testing.T) {
+	locks := NewScanLocks()
+	var wg sync.WaitGroup
+	var holders, maxHolders int
+	var count sync.Mutex
+
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			unlock := locks.Lock("scan-1")
+			count.Lock()
+			holders++
+			maxHolders = max(maxHolders, holders)
+			count.Unlock()
+			time.Sleep(time.Millisecond)
+			count.Lock()
+			holders--
+			count.Unlock()
+			unlock()
+		}()
+		go func() {
+			defer wg.Done()
+			locks.Release("scan-1")
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("concurrent Lock/Release deadlocked")
+	}
+
+	assert.Equal(t, 1, maxHolders)
+	assert.Equal(t, 0, locks.Len())
+}
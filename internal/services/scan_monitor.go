@@ -2,8 +2,12 @@ package services
 
 import (
 	"context"
+	"io"
+	"io/fs"
+	"net"
 	"os"
 	"path/filepath"
+	"pipeliner/internal/config"
 	"pipeliner/internal/dao"
 	"pipeliner/internal/models"
 	"pipeliner/pkg/logger"
@@ -14,28 +18,60 @@ import (
 	"github.com/fsnotify/fsnotify"
 )
 
+// scanFiles opens the files a scan writes by their path on disk.
+type scanFiles interface {
+	Stat(name string) (fs.FileInfo, error)
+	Open(name string) (fs.File, error)
+}
+
+// osFiles reads scan files from the local disk.
+type osFiles struct{}
+
+func (osFiles) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+func (osFiles) Open(name string) (fs.File, error)     { return os.Open(name) }
+
 type ScanMonitor struct {
-	scanDao     dao.ScanDAO
-	logger      *logger.Logger
-	scanMutexes *sync.Map
-	artifacts   *ArtifactProcessor
+	subdomainDao  dao.SubdomainDAO
+	logger        *logger.Logger
+	scanLocks     *ScanLocks
+	artifacts     *ArtifactProcessor
+	statusManager *ScanStatusManager
+	config        config.MonitorConfig
+	events        *scanEvents
+	// diskQuotaMB is the most a scan directory may hold; 0 is no limit
+	diskQuotaMB int
+	// discovery, if set, notifies about the scan's first subdomain
+	discovery *discoveryNotifier
+	// after is time.After outside tests
+	after func(time.Duration) <-chan time.Time
+	// lookupHost is net.DefaultResolver.LookupHost outside tests
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	// files is osFiles outside tests
+	files scanFiles
+
+	refreshMu sync.Mutex
+	// refreshes reach the running artifact monitors, by scan
+	refreshes map[string]*artifactRefresh
 }
 
-func newScanMonitor(scanDao dao.ScanDAO, logger *logger.Logger, scanMutexes *sync.Map, artifacts *ArtifactProcessor) *ScanMonitor {
+func newScanMonitor(subdomainDao dao.SubdomainDAO, logger *logger.Logger, scanLocks *ScanLocks, artifacts *ArtifactProcessor, statusManager *ScanStatusManager, cfg config.MonitorConfig, events *scanEvents) *ScanMonitor {
 	return &ScanMonitor{
-		scanDao:     scanDao,
-		logger:      logger,
-		scanMutexes: scanMutexes,
-		artifacts:   artifacts,
+		subdomainDao:  subdomainDao,
+		logger:        logger,
+		scanLocks:     scanLocks,
+		artifacts:     artifacts,
+		statusManager: statusManager,
+		config:        cfg,
+		events:        events,
+		after:         time.After,
+		lookupHost:    net.DefaultResolver.LookupHost,
+		files:         osFiles{},
 	}
 }
 
-func (m *ScanMonitor) getScanMutex(scanID string) *sync.Mutex {
-	value, _ := m.scanMutexes.LoadOrStore(scanID, &sync.Mutex{})
-	return value.(*sync.Mutex)
-}
-
-func (m *ScanMonitor) MonitorScanProgress(scanID, scanType, scanDir string, ctx context.Context, done chan struct{}) {
+// MonitorScanProgress watches scanDir until ctx is done. stop is called to
+// end the scan's run if its directory outgrows the disk quota.
+func (m *ScanMonitor) MonitorScanProgress(scanID, scanType, scanDir string, ctx context.Context, done chan struct{}, stop func()) {
 	defer close(done)
 
 	if scanDir == "" {
@@ -57,6 +93,14 @@ func (m *ScanMonitor) MonitorScanProgress(scanID, scanType, scanDir string, ctx
 		m.monitorArtifacts(scanID, scanDir, ctx)
 	}()
 
+	if m.diskQuotaMB > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.monitorDiskQuota(scanID, scanDir, ctx, stop)
+		}()
+	}
+
 	wg.Wait()
 	m.logger.Info("All monitors finished", logger.Fields{"scan_id": scanID})
 }
@@ -74,11 +118,19 @@ func (m *ScanMonitor) monitorArtifacts(scanID, scanDir string, ctx context.Conte
 		return
 	}
 
-	m.artifacts.UpdateArtifacts(scanID, scanDir)
+	// with an artifact store, the processor reads what has been uploaded
+	var syncer *artifactSyncer
+	if m.artifacts.store != nil {
+		syncer = newArtifactSyncer(m.artifacts.store, scanID, scanDir, m.logger)
+		syncer.sync(false)
+	}
+
+	m.updateArtifacts(scanID, scanDir)
 
-	ticker := time.NewTicker(3 * time.Second)
-	defer ticker.Stop()
+	refreshes, stopRefreshes := m.watchRefreshes(scanID)
+	defer stopRefreshes()
 
+	tick := m.after(m.config.ArtifactInterval)
 	updatePending := false
 	var mu sync.Mutex
 
@@ -97,7 +149,7 @@ func (m *ScanMonitor) monitorArtifacts(scanID, scanDir string, ctx context.Conte
 				if ext == ".jpeg" || ext == ".jpg" || ext == ".png" {
 					isArtifact = true
 				}
-				if filename == "nmap_output.xml" {
+				if filename == "nmap_output.xml" || filename == "httpx_output.json" {
 					isArtifact = true
 				}
 				if strings.HasSuffix(filename, "_ffuf_output.json") {
@@ -111,13 +163,29 @@ func (m *ScanMonitor) monitorArtifacts(scanID, scanDir string, ctx context.Conte
 				}
 			}
 
-		case <-ticker.C:
+		case <-tick:
 			mu.Lock()
+			if syncer != nil && syncer.sync(false) > 0 {
+				updatePending = true
+			}
 			if updatePending {
-				m.artifacts.UpdateArtifacts(scanID, scanDir)
+				m.updateArtifacts(scanID, scanDir)
 				updatePending = false
 			}
 			mu.Unlock()
+			tick = m.after(m.config.ArtifactInterval)
+
+		case then := <-refreshes:
+			mu.Lock()
+			if syncer != nil {
+				syncer.sync(false)
+			}
+			m.updateArtifacts(scanID, scanDir)
+			updatePending = false
+			mu.Unlock()
+			if then != nil {
+				then()
+			}
 
 		case err, ok := <-watcher.Errors:
 			if !ok {
@@ -127,12 +195,22 @@ func (m *ScanMonitor) monitorArtifacts(scanID, scanDir string, ctx context.Conte
 
 		case <-ctx.Done():
 			m.logger.Info("Stopping artifact monitor, performing final update", logger.Fields{"dir": scanDir, "scan_id": scanID})
-			m.artifacts.UpdateArtifacts(scanID, scanDir)
+			if syncer != nil {
+				syncer.sync(true)
+			}
+			m.updateArtifacts(scanID, scanDir)
 			return
 		}
 	}
 }
 
+// updateArtifacts stores the scan's artifacts and marks the hosts httpx
+// -json answered for alive.
+func (m *ScanMonitor) updateArtifacts(scanID, scanDir string) {
+	m.artifacts.UpdateArtifacts(scanID, scanDir)
+	m.recordHttpxJSON(scanID, scanDir)
+}
+
 func (m *ScanMonitor) monitorSubdomains(scanID, scanDir string, ctx context.Context) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -143,21 +221,22 @@ func (m *ScanMonitor) monitorSubdomains(scanID, scanDir string, ctx context.Cont
 
 	httpxPath := filepath.Join(scanDir, "httpx_output.txt")
 
-	timeout := time.After(5 * time.Minute)
-	ticker := time.NewTicker(500 * time.Millisecond)
-	defer ticker.Stop()
-
-	fileExists := false
-	for !fileExists {
+	timeout := m.after(m.config.FileWaitTimeout)
+	for {
+		if _, err := m.files.Stat(httpxPath); err == nil {
+			break
+		}
 		select {
 		case <-timeout:
-			m.logger.Warn("Timeout waiting for httpx_output.txt", logger.Fields{"scan_id": scanID})
+			m.logger.Warn("Timeout waiting for httpx_output.txt", logger.Fields{"scan_id": scanID, "timeout": m.config.FileWaitTimeout})
 			return
-		case <-ticker.C:
-			if _, err := os.Stat(httpxPath); err == nil {
-				fileExists = true
-			}
+		case <-m.after(m.config.FilePollInterval):
 		case <-ctx.Done():
+			// the scan may have written it since the last poll
+			if _, err := m.files.Stat(httpxPath); err == nil {
+				var lastSize int64
+				m.processSubdomainUpdate(scanID, httpxPath, &lastSize)
+			}
 			return
 		}
 	}
@@ -174,9 +253,7 @@ func (m *ScanMonitor) monitorSubdomains(scanID, scanDir string, ctx context.Cont
 
 	m.processSubdomainUpdate(scanID, httpxPath, &lastSize)
 
-	updateTicker := time.NewTicker(2 * time.Second)
-	defer updateTicker.Stop()
-
+	tick := m.after(m.config.SubdomainInterval)
 	updatePending := false
 
 	for {
@@ -191,13 +268,14 @@ func (m *ScanMonitor) monitorSubdomains(scanID, scanDir string, ctx context.Cont
 				mu.Unlock()
 			}
 
-		case <-updateTicker.C:
+		case <-tick:
 			mu.Lock()
 			if updatePending {
 				m.processSubdomainUpdate(scanID, httpxPath, &lastSize)
 				updatePending = false
 			}
 			mu.Unlock()
+			tick = m.after(m.config.SubdomainInterval)
 
 		case err, ok := <-watcher.Errors:
 			if !ok {
@@ -214,7 +292,7 @@ func (m *ScanMonitor) monitorSubdomains(scanID, scanDir string, ctx context.Cont
 }
 
 func (m *ScanMonitor) processSubdomainUpdate(scanID, filePath string, lastSize *int64) {
-	file, err := os.Open(filePath)
+	file, err := m.files.Open(filePath)
 	if err != nil {
 		m.logger.Error("Failed to open httpx_output.txt", logger.Fields{"error": err, "file": filePath, "scan_id": scanID})
 		return
@@ -232,7 +310,7 @@ func (m *ScanMonitor) processSubdomainUpdate(scanID, filePath string, lastSize *
 		return
 	}
 
-	if _, err := file.Seek(*lastSize, 0); err != nil {
+	if err := skipTo(file, *lastSize); err != nil {
 		m.logger.Error("Failed to seek httpx_output.txt", logger.Fields{"error": err, "file": filePath, "scan_id": scanID})
 		return
 	}
@@ -245,51 +323,59 @@ func (m *ScanMonitor) processSubdomainUpdate(scanID, filePath string, lastSize *
 	}
 	newContent = newContent[:n]
 
-	lines := strings.Split(string(newContent), "\n")
-	var validLines []string
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
-			validLines = append(validLines, trimmed)
-		}
-	}
+	validLines := httpxLines(string(newContent))
 
 	if len(validLines) > 0 {
-		mu := m.getScanMutex(scanID)
-		mu.Lock()
-		defer mu.Unlock()
-
-		scan, err := m.scanDao.GetScanByUUID(scanID)
-		if err != nil {
-			m.logger.Error("Failed to load scan for subdomain update", logger.Fields{"error": err, "scan_id": scanID})
-			return
-		}
+		unlock := m.scanLocks.Lock(scanID)
+		defer unlock()
 
+		subdomains := make([]models.Subdomain, 0, len(validLines))
+		results := make(map[string]probeResult, len(validLines))
+		hosts := make([]string, 0, len(validLines))
 		for _, line := range validLines {
-			subdomain := models.Subdomain{
+			subdomains = append(subdomains, models.Subdomain{
 				Domain: line,
-				Status: "discovered",
-			}
-			scan.Subdomains = append(scan.Subdomains, subdomain)
+				Status: models.SubdomainDiscovered,
+			})
+			results[hostOf(line)] = probeAnswered
+			hosts = append(hosts, hostOf(line))
 		}
 
-		scan.NumberOfDomains = len(scan.Subdomains)
+		added, err := m.subdomainDao.AddSubdomains(scanID, subdomains)
+		if err != nil {
+			m.logger.Error("Failed to update scan with new subdomains", logger.Fields{"error": err, "scan_id": scanID})
+			return
+		}
+		// httpx only writes the hosts that answered
+		if err := m.applyProbes(scanID, results); err != nil {
+			m.logger.Error("Failed to mark subdomains alive", logger.Fields{"error": err, "scan_id": scanID})
+		}
 
-		if scan.Status != "completed" && scan.Status != "failed" {
-			scan.Status = "running"
+		if err := m.statusManager.MarkRunning(scanID); err != nil {
+			m.logger.Error("Failed to mark scan running", logger.Fields{"error": err, "scan_id": scanID})
 		}
 
-		if err := m.scanDao.UpdateScan(scan); err != nil {
-			m.logger.Error("Failed to update scan with new subdomains", logger.Fields{"error": err, "scan_id": scanID})
-			return
+		if total, err := m.subdomainDao.CountByScan(scanID); err == nil {
+			m.events.publish(scanID, ScanEventSubdomains, SubdomainsEvent{Added: added, Total: total})
+			m.discovery.subdomainsSaved(scanID, hosts, added, total)
 		}
 
 		m.logger.Info("Added new subdomains", logger.Fields{
 			"scan_id": scanID,
 			"count":   len(validLines),
-			"total":   len(scan.Subdomains),
+			"new":     added,
 		})
 	}
 
 	*lastSize = currentSize
 }
+
+// skipTo moves file to offset, reading up to it when it cannot seek.
+func skipTo(file fs.File, offset int64) error {
+	if seeker, ok := file.(io.Seeker); ok {
+		_, err := seeker.Seek(offset, io.SeekStart)
+		return err
+	}
+	_, err := io.CopyN(io.Discard, file, offset)
+	return err
+}
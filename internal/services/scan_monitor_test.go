@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"pipeliner/internal/config"
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/testutil"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingSubdomainDAO counts the AddSubdomains calls, one per update.
+type countingSubdomainDAO struct {
+	dao.SubdomainDAO
+	adds atomic.Int32
+}
+
+func (c *countingSubdomainDAO) AddSubdomains(scanID string, subdomains []models.Subdomain) (int, error) {
+	c.adds.Add(1)
+	return c.SubdomainDAO.AddSubdomains(scanID, subdomains)
+}
+
+func waitForAdds(t *testing.T, c *countingSubdomainDAO, want int32) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for c.adds.Load() < want {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d subdomain updates, want %d", c.adds.Load(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScanMonitor_DebounceBatchesWrites(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Status: "running"}))
+	counting := &countingSubdomainDAO{SubdomainDAO: subdomainDao}
+
+	scanDir := t.TempDir()
+	httpxPath := filepath.Join(scanDir, "httpx_output.txt")
+	require.NoError(t, os.WriteFile(httpxPath, []byte("https://a.example.com\n"), 0644))
+
+	cfg := config.DefaultMonitorConfig()
+	cfg.SubdomainInterval = 10 * time.Second
+	log := logger.NewLogger(logrus.ErrorLevel)
+	m := newScanMonitor(counting, log, NewScanLocks(), nil, newScanStatusManager(scanDao, log, nil), cfg, nil)
+	clock := testutil.NewFakeClock(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+	m.after = clock.After
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.monitorSubdomains("scan-1", scanDir, ctx)
+	}()
+
+	// the file wait timeout and the first tick
+	clock.WaitForWaiters(t, 2)
+	waitForAdds(t, counting, 1)
+
+	f, err := os.OpenFile(httpxPath, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	for _, host := range []string{"b", "c", "d"} {
+		_, err := f.WriteString("https://" + host + ".example.com\n")
+		require.NoError(t, err)
+		require.NoError(t, f.Sync())
+	}
+	require.NoError(t, f.Close())
+	// give the watcher time to deliver the writes; the clock holds the tick
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(1), counting.adds.Load(), "no update before the window ends")
+
+	clock.Advance(cfg.SubdomainInterval)
+	waitForAdds(t, counting, 2)
+	clock.WaitForWaiters(t, 2)
+	assert.Equal(t, int32(2), counting.adds.Load(), "three writes, one update")
+
+	count, err := subdomainDao.CountByScan("scan-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+
+	cancel()
+	<-done
+}
+
+// mapFiles serves scan files from memory by their path on disk.
+type mapFiles fstest.MapFS
+
+func (m mapFiles) Stat(name string) (fs.FileInfo, error) {
+	return fstest.MapFS(m).Stat(strings.TrimPrefix(filepath.ToSlash(name), "/"))
+}
+
+func (m mapFiles) Open(name string) (fs.File, error) {
+	return fstest.MapFS(m).Open(strings.TrimPrefix(filepath.ToSlash(name), "/"))
+}
+
+func TestScanMonitor_TailsHttpxOutput(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Status: "running"}))
+	counting := &countingSubdomainDAO{SubdomainDAO: subdomainDao}
+
+	files := mapFiles{"scans/scan-1/httpx_output.txt": {Data: []byte("https://a.example.com\n# comment\n")}}
+	log := logger.NewLogger(logrus.ErrorLevel)
+	m := newScanMonitor(counting, log, NewScanLocks(), nil, newScanStatusManager(scanDao, log, nil), config.DefaultMonitorConfig(), nil)
+	m.files = files
+
+	httpxPath := "/scans/scan-1/httpx_output.txt"
+	var lastSize int64
+	m.processSubdomainUpdate("scan-1", httpxPath, &lastSize)
+	assert.Equal(t, int64(len(files["scans/scan-1/httpx_output.txt"].Data)), lastSize)
+
+	// only what was appended since is read
+	files["scans/scan-1/httpx_output.txt"].Data = append(files["scans/scan-1/httpx_output.txt"].Data, "https://b.example.com\n"...)
+	m.processSubdomainUpdate("scan-1", httpxPath, &lastSize)
+	m.processSubdomainUpdate("scan-1", httpxPath, &lastSize)
+	assert.Equal(t, int32(2), counting.adds.Load(), "an unchanged file adds nothing")
+
+	subdomains, err := subdomainDao.ListByScan("scan-1")
+	require.NoError(t, err)
+	require.Len(t, subdomains, 2)
+	assert.Equal(t, "https://a.example.com", subdomains[0].Domain)
+	assert.Equal(t, "https://b.example.com", subdomains[1].Domain)
+}
+
+func TestScanMonitor_ReadsHttpxOutputWrittenBeforeCancel(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Status: "running"}))
+
+	files := mapFiles{}
+	log := logger.NewLogger(logrus.ErrorLevel)
+	m := newScanMonitor(subdomainDao, log, NewScanLocks(), nil, newScanStatusManager(scanDao, log, nil), config.DefaultMonitorConfig(), nil)
+	m.files = files
+	clock := testutil.NewFakeClock(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+	m.after = clock.After
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.monitorSubdomains("scan-1", "/scans/scan-1", ctx)
+	}()
+
+	// the file wait timeout and the first poll
+	clock.WaitForWaiters(t, 2)
+	files["scans/scan-1/httpx_output.txt"] = &fstest.MapFile{Data: []byte("https://a.example.com\n")}
+	cancel()
+	<-done
+
+	count, err := subdomainDao.CountByScan("scan-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestScanMonitor_GivesUpWithoutHttpxOutput(t *testing.T) {
+	cfg := config.DefaultMonitorConfig()
+	// a nil DAO fails the test if anything is added
+	m := newScanMonitor(nil, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), nil, nil, cfg, nil)
+	m.files = mapFiles{}
+	clock := testutil.NewFakeClock(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+	m.after = clock.After
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.monitorSubdomains("scan-1", "/scans/scan-1", context.Background())
+	}()
+
+	clock.WaitForWaiters(t, 2)
+	clock.Advance(cfg.FileWaitTimeout)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("monitor kept waiting after the file wait timeout")
+	}
+}
+
+func TestScanMonitor_NoScanDir(t *testing.T) {
+	m := newScanMonitor(nil, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), nil, nil, config.DefaultMonitorConfig(), nil)
+
+	done := make(chan struct{})
+	m.MonitorScanProgress("scan-1", "full_recon", "", context.Background(), done, nil)
+
+	select {
+	case <-done:
+	default:
+		t.Fatal("done was not closed")
+	}
+}
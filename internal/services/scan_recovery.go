@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"pipeliner/internal/models"
+	"pipeliner/internal/utils"
+	"pipeliner/pkg/engine"
+	"pipeliner/pkg/logger"
+)
+
+// interruptedReason is the error of the scans a stopped server was running.
+const interruptedReason = "Interrupted by a server restart"
+
+// WithRestartRecovery picks up where the previous server stopped when the
+// service starts: scans it left running or paused are marked failed, and
+// scans still waiting in its queue are queued again.
+func WithRestartRecovery() ScanServiceOption {
+	return func(s *scanService) {
+		s.recoverOnStart = true
+	}
+}
+
+// recoverScans marks the unfinished scans of a previous process failed or
+// queues them again, in the order they were created, and reports how many
+// of each.
+func (s *scanService) recoverScans() (requeued, interrupted int, err error) {
+	scans, err := s.scanDao.ListUnfinishedScans()
+	if err != nil {
+		return 0, 0, fmt.Errorf("list unfinished scans: %w", err)
+	}
+
+	for _, scan := range scans {
+		if scan.Status != models.ScanQueued {
+			// its tools died with the process
+			s.statusManager.MarkFailedWithReason(scan.UUID, interruptedReason)
+			interrupted++
+			continue
+		}
+
+		opts, err := s.requeueOptions(&scan)
+		if err != nil {
+			s.statusManager.MarkFailedWithReason(scan.UUID, fmt.Sprintf("Could not queue again after a server restart: %v", err))
+			interrupted++
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		s.pending.add(scan.UUID, cancel)
+		go s.executor.Execute(ctx, scan.UUID, scan.ScanType, scan.Domain, scan.Priority, opts...)
+		requeued++
+		s.logger.Info("Queued scan again after restart", logger.Fields{"scan_id": scan.UUID, "domain": scan.Domain})
+	}
+	return requeued, interrupted, nil
+}
+
+// requeueOptions are the engine options a queued scan was started with. A
+// queued scan with failed tools is a retry of them.
+func (s *scanService) requeueOptions(scan *models.Scan) ([]engine.OptFunc, error) {
+	if len(scan.FailedTools) == 0 {
+		return nil, nil
+	}
+	if scan.ScanDir == "" {
+		return nil, fmt.Errorf("%w: not recorded for scan %s", ErrScanDirMissing, scan.UUID)
+	}
+	origin, ok := utils.ModuleOriginFor(scan.ConfigSource, scan.ConfigRevision)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s at %s", ErrModuleUnavailable, scan.ConfigSource, scan.ConfigRevision)
+	}
+	failed := make([]string, len(scan.FailedTools))
+	for i, failure := range scan.FailedTools {
+		failed[i] = failure.ToolName
+	}
+	return []engine.OptFunc{engine.WithModuleOrigin(origin), engine.WithRetryFailed(scan.ScanDir, failed)}, nil
+}
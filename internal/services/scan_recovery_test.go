@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"pipeliner/internal/models"
+	"pipeliner/pkg/engine"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/queue"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestartRecovery(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	for _, scan := range []models.Scan{
+		{UUID: "queued-1", Domain: "a.example.com", ScanType: "full", Status: models.ScanQueued, CreatedAt: 100},
+		{UUID: "running", Domain: "b.example.com", ScanType: "full", Status: models.ScanRunning, CreatedAt: 200},
+		{UUID: "paused", Domain: "c.example.com", ScanType: "full", Status: models.ScanPaused, CreatedAt: 300},
+		{UUID: "queued-2", Domain: "d.example.com", ScanType: "full", Status: models.ScanQueued, CreatedAt: 400},
+		// a retry, whose directory was never recorded
+		{UUID: "retry", Domain: "e.example.com", ScanType: "full", Status: models.ScanQueued, CreatedAt: 500,
+			FailedTools: []models.ToolFailure{{ToolName: "ffuf"}}},
+		{UUID: "done", Domain: "f.example.com", ScanType: "full", Status: models.ScanCompleted, CreatedAt: 50},
+	} {
+		require.NoError(t, scanDao.SaveScan(&scan))
+	}
+
+	var mu sync.Mutex
+	engines := 0
+	factory := func(...engine.OptFunc) (ScanEngine, error) {
+		mu.Lock()
+		engines++
+		mu.Unlock()
+		return &fakeEngine{scanDir: t.TempDir(), logger: logger.NewLogger(logrus.ErrorLevel)}, nil
+	}
+	NewScanService(scanDao, subdomainDao, WithQueue(queue.New(1)), WithEngineFactory(factory), WithRestartRecovery())
+
+	for _, id := range []string{"queued-1", "queued-2"} {
+		assert.Equal(t, models.ScanCompleted, waitForFinished(t, scanDao, id).Status, id)
+	}
+	for _, id := range []string{"running", "paused"} {
+		scan, err := scanDao.GetScanSummary(id)
+		require.NoError(t, err)
+		assert.Equal(t, models.ScanFailed, scan.Status, id)
+		assert.Equal(t, interruptedReason, scan.ErrorMessage, id)
+	}
+	retry, err := scanDao.GetScanSummary("retry")
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanFailed, retry.Status)
+	assert.Contains(t, retry.ErrorMessage, "Could not queue again after a server restart")
+	done, err := scanDao.GetScanSummary("done")
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanCompleted, done.Status)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, engines)
+}
+
+func TestShutdownLeavesWaitingScansQueued(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	q := queue.New(1)
+	release := make(chan struct{})
+	factory := func(...engine.OptFunc) (ScanEngine, error) {
+		return &fakeEngine{scanDir: t.TempDir(), logger: logger.NewLogger(logrus.ErrorLevel), run: func(string) error {
+			<-release
+			return nil
+		}}, nil
+	}
+	svc := NewScanService(scanDao, subdomainDao, WithQueue(q), WithEngineFactory(factory))
+
+	first, err := svc.StartScan(&models.Scan{ScanType: "full", Domain: "a.example.com"})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		running, _, _ := q.GetStatus()
+		return running == 1
+	}, 5*time.Second, time.Millisecond)
+	second, err := svc.StartScan(&models.Scan{ScanType: "full", Domain: "b.example.com"})
+	require.NoError(t, err)
+	waitForQueued(t, q, 1)
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- q.Shutdown(context.Background()) }()
+	waitForQueued(t, q, 0)
+	close(release)
+	require.NoError(t, <-shutdown)
+
+	assert.Equal(t, models.ScanCompleted, waitForFinished(t, scanDao, first).Status)
+	scan, err := scanDao.GetScanSummary(second)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanQueued, scan.Status)
+}
@@ -1,12 +1,25 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"os"
+	"pipeliner/internal/config"
 	"pipeliner/internal/dao"
+	"pipeliner/internal/metrics"
 	"pipeliner/internal/models"
 	"pipeliner/internal/notification"
+	"pipeliner/internal/utils"
+	"pipeliner/internal/vulndb"
+	"pipeliner/pkg/blobstore"
+	"pipeliner/pkg/engine"
+	perrors "pipeliner/pkg/errors"
 	"pipeliner/pkg/logger"
-	"sync"
+	"pipeliner/pkg/queue"
+	"pipeliner/pkg/scoring"
+	"pipeliner/pkg/tools"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
@@ -16,60 +29,314 @@ import (
 type ScanServiceMethods interface {
 	StartScan(scan *models.Scan) (string, error)
 	GetScanByUUID(id string) (*models.Scan, error)
-	ListScans() ([]models.Scan, error)
-	ListScansWithPagination(page, limit int) ([]models.Scan, int64, error)
+	GetScanSummary(id string) (*models.Scan, error)
+	// GetScanByDirectory returns the scan, without its subdomains, whose
+	// tools write to dir.
+	GetScanByDirectory(dir string) (*models.Scan, error)
+	ListSubdomains(id string, status models.SubdomainStatus, page, limit int) ([]models.Subdomain, int64, error)
+	GetSubdomainStats(id string) (models.SubdomainStats, error)
+	ListFindings(id string, filter models.FindingFilter, page, limit int) ([]models.Finding, int64, error)
+	ListScansFiltered(filter models.ScanFilter, page, limit int) ([]models.Scan, int64, error)
+	ListScansByCursor(cursor string, limit int) ([]models.Scan, string, error)
 	DeleteScan(id string) error
+	CancelScan(id string) error
+	RetryFailedTools(id string) ([]string, error)
+	RerunScan(id string) (string, error)
+	EstimateScan(ctx context.Context, scanType, domain string) (*ScanEstimate, error)
+	GetHookExecutions(id string) ([]models.HookExecution, error)
+	GetScanDAG(id string) (*tools.DAGSnapshot, error)
+	PauseScan(id string, hard bool) error
+	ResumeScan(id string) error
+	ListWebhookDeliveries(id string) ([]models.WebhookDelivery, error)
+	RedeliverWebhook(id string, deliveryID uint) (*models.WebhookDelivery, error)
+	SubscribeScanEvents(id string) (<-chan ScanEvent, func())
+	ForTeam(teamID uint, admin bool) ScanServiceMethods
+	// Close sends the notifications still held back and stops the
+	// service's background work. Call it once the scans have stopped.
+	Close()
 }
 
 type scanService struct {
-	scanDao            dao.ScanDAO
-	logger             *logger.Logger
-	scanMutexes        *sync.Map
-	notificationClient *notification.NotificationClient
+	scanDao         dao.ScanDAO
+	subdomainDao    dao.SubdomainDAO
+	logger          *logger.Logger
+	scanLocks       *ScanLocks
+	notifier        notification.Notifier
+	queue           queue.Queue
+	pending         *pendingScans
+	dags            *dagSnapshots
+	events          *scanEvents
+	running         *runningScans
+	maxBacklog      int
+	releaseOnPause  bool
+	defaultWebhooks []models.ScanWebhook
+	newEngine       EngineFactory
+	monitorConfig   config.MonitorConfig
+	artifactStore   blobstore.Store
+	findingDao      dao.FindingDAO
+	vulnDB          *vulndb.Cache
+	nicePolicy      *tools.NicePolicy
+	sloConfig       config.SLOConfig
+	metrics         *metrics.Registry
+	recoverOnStart  bool
+	severityRules   []scoring.Rule
+	// team owns the scans started through this service
+	team             uint
+	duplicateWindow  time.Duration
+	strictDuplicates bool
+	diskQuotaMB      int
+	resourceGuard    *ResourceGuard
+	discovery        *discoveryNotifier
 
 	executor      *ScanExecutor
 	monitor       *ScanMonitor
 	statusManager *ScanStatusManager
 	artifacts     *ArtifactProcessor
+	webhooks      *webhookDispatcher
+	slo           *scanSLO
 }
 
-var ErrScanNotFound = errors.New("scan not found")
+var (
+	ErrScanNotFound       = errors.New("scan not found")
+	ErrScanNotCancellable = errors.New("scan is not queued or running")
+	ErrQueueFull          = errors.New("too many scans waiting in the queue")
+	ErrDAGUnavailable     = errors.New("no dependency graph state for scan")
+	ErrScanNotRunning     = errors.New("scan is not running")
+	ErrScanNotPaused      = errors.New("scan is not paused")
+	ErrInvalidCursor      = errors.New("invalid cursor")
+	ErrNothingToRetry     = errors.New("scan has no failed tools to retry")
+	ErrScanNotFinished    = errors.New("scan has not finished yet")
+	ErrScanDirMissing     = errors.New("scan directory no longer exists")
+	ErrModuleUnavailable  = errors.New("module revision the scan ran with is no longer available")
 
-func NewScanService(scanDao dao.ScanDAO) ScanServiceMethods {
-	notifClient, err := notification.NewNotificationClient()
-	if err != nil {
-		logger.NewLogger(logrus.InfoLevel).WithError(err).Warn("Failed to initialize notification client - notifications disabled")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrWebhookNotFound         = errors.New("webhook is no longer registered")
+	ErrUnknownWebhookEvent     = errors.New("unknown webhook event")
+)
+
+type ScanServiceOption func(*scanService)
+
+// WithQueue runs scans through q instead of the process-wide global queue.
+func WithQueue(q queue.Queue) ScanServiceOption {
+	return func(s *scanService) {
+		s.queue = q
+	}
+}
+
+// WithMaxBacklog rejects new scans with ErrQueueFull while max scans are
+// already waiting for a slot. Zero means no limit.
+func WithMaxBacklog(max int) ScanServiceOption {
+	return func(s *scanService) {
+		s.maxBacklog = max
+	}
+}
+
+// WithReleaseSlotOnPause gives a paused scan's queue slot to the next queued
+// scan; the scan waits in line for a slot again when it is resumed.
+func WithReleaseSlotOnPause(release bool) ScanServiceOption {
+	return func(s *scanService) {
+		s.releaseOnPause = release
+	}
+}
+
+// WithDefaultWebhooks notifies the given webhooks about every scan, in
+// addition to the ones registered with each scan.
+func WithDefaultWebhooks(webhooks ...models.ScanWebhook) ScanServiceOption {
+	return func(s *scanService) {
+		s.defaultWebhooks = append(s.defaultWebhooks, webhooks...)
+	}
+}
+
+// WithMonitorConfig sets how often running scans are checked for new
+// results.
+func WithMonitorConfig(cfg config.MonitorConfig) ScanServiceOption {
+	return func(s *scanService) {
+		s.monitorConfig = cfg
+	}
+}
+
+// WithEngineFactory makes the service run scans on engines from f instead
+// of the pipeliner engine.
+func WithEngineFactory(f EngineFactory) ScanServiceOption {
+	return func(s *scanService) {
+		s.newEngine = f
+	}
+}
+
+// WithArtifactStore keeps scan artifacts in store, under the name of each
+// scan's directory. Tools still write to the scan directory; the monitor
+// uploads files once they stop changing.
+func WithArtifactStore(store blobstore.Store) ScanServiceOption {
+	return func(s *scanService) {
+		s.artifactStore = store
 	}
+}
 
+// WithFindingDAO stores each nuclei result of a scan as a finding, besides
+// the vulns list of its subdomain.
+func WithFindingDAO(findingDao dao.FindingDAO) ScanServiceOption {
+	return func(s *scanService) {
+		s.findingDao = findingDao
+	}
+}
+
+// WithVulnDB scores nuclei findings by their CVEs from the dataset cache
+// reads, reloading it when the file is refreshed.
+func WithVulnDB(cache *vulndb.Cache) ScanServiceOption {
+	return func(s *scanService) {
+		s.vulnDB = cache
+	}
+}
+
+// WithNicePolicy runs the tools of each scan at the niceness policy gives
+// its priority.
+func WithNicePolicy(policy tools.NicePolicy) ScanServiceOption {
+	return func(s *scanService) {
+		s.nicePolicy = &policy
+	}
+}
+
+// WithSLOMetrics exports the scan SLO metrics to registry and checks them
+// every cfg.CheckInterval, notifying about stale domains and stuck scans.
+func WithSLOMetrics(registry *metrics.Registry, cfg config.SLOConfig) ScanServiceOption {
+	return func(s *scanService) {
+		s.metrics = registry
+		s.sloConfig = cfg
+	}
+}
+
+func NewScanService(scanDao dao.ScanDAO, subdomainDao dao.SubdomainDAO, opts ...ScanServiceOption) ScanServiceMethods {
 	log := logger.NewLogger(logrus.InfoLevel)
-	scanMutexes := &sync.Map{}
+
+	notifier, err := notification.NewNotifier()
+	if err != nil {
+		log.WithError(err).Warn("Failed to initialize notifications - notifications disabled")
+	} else if err := notification.Validate(notifier); err != nil {
+		log.WithError(err).Warn("Some Discord channels are not accessible")
+	}
 
 	svc := &scanService{
-		scanDao:            scanDao,
-		logger:             log,
-		scanMutexes:        scanMutexes,
-		notificationClient: notifClient,
+		scanDao:       scanDao,
+		subdomainDao:  subdomainDao,
+		logger:        log,
+		scanLocks:     NewScanLocks(),
+		notifier:      notifier,
+		pending:       newPendingScans(),
+		dags:          newDAGSnapshots(),
+		events:        newScanEvents(),
+		running:       newRunningScans(),
+		newEngine:     newPiplinerEngine,
+		monitorConfig: config.DefaultMonitorConfig(),
 	}
 
-	svc.statusManager = newScanStatusManager(scanDao, log)
-	svc.artifacts = newArtifactProcessor(scanDao, log, svc.scanMutexes, notifClient)
-	svc.monitor = newScanMonitor(scanDao, log, svc.scanMutexes, svc.artifacts)
+	for _, opt := range opts {
+		opt(svc)
+	}
+	if svc.queue == nil {
+		svc.queue = queue.Global()
+	}
+
+	svc.statusManager = newScanStatusManager(scanDao, log, svc.events)
+	svc.webhooks = newWebhookDispatcher(scanDao, log, svc.defaultWebhooks)
+	svc.artifacts = newArtifactProcessor(scanDao, subdomainDao, log, svc.scanLocks, notifier, svc.webhooks)
+	svc.artifacts.store = svc.artifactStore
+	svc.artifacts.findingDao = svc.findingDao
+	if svc.vulnDB != nil {
+		svc.artifacts.vulns = newFindingEnricher(svc.vulnDB, log)
+	}
+	if svc.artifacts.severity, err = newSeverityPolicies(svc.severityRules); err != nil {
+		log.Error("Ignoring invalid severity rules", logger.Fields{"error": err})
+		svc.artifacts.severity, _ = newSeverityPolicies(nil)
+	}
+	svc.monitor = newScanMonitor(subdomainDao, log, svc.scanLocks, svc.artifacts, svc.statusManager, svc.monitorConfig, svc.events)
+	svc.monitor.diskQuotaMB = svc.diskQuotaMB
+	if svc.discovery != nil {
+		svc.discovery.notifier, svc.discovery.logger = notifier, log
+		svc.monitor.discovery = svc.discovery
+	}
 	svc.executor = newScanExecutor(svc)
+	if svc.metrics != nil {
+		svc.slo = newScanSLO(scanDao, log, notifier, svc.sloConfig, svc.metrics)
+		go svc.slo.run()
+	}
+	if svc.resourceGuard != nil {
+		svc.resourceGuard.attach(svc, notifier, svc.metrics)
+		go svc.resourceGuard.run()
+	}
+	if svc.recoverOnStart {
+		if requeued, interrupted, err := svc.recoverScans(); err != nil {
+			log.Error("Failed to recover unfinished scans", logger.Fields{"error": err})
+		} else if requeued+interrupted > 0 {
+			log.Info("Recovered unfinished scans", logger.Fields{"requeued": requeued, "failed": interrupted})
+		}
+	}
 
 	return svc
 }
 
+func (s *scanService) Close() {
+	s.resourceGuard.Stop()
+	s.artifacts.Close()
+}
+
 func (s *scanService) StartScan(scan *models.Scan) (string, error) {
+	// a module that exists but fails to decode is rejected here; other load
+	// failures still surface on the scan once it runs
+	if err := engine.ValidateModule(scan.ScanType); errors.Is(err, perrors.ErrInvalidConfig) {
+		s.logger.Warn("Rejected scan with invalid module config", logger.Fields{"error": err, "module": scan.ScanType})
+		return "", err
+	}
+
+	for _, webhook := range scan.Webhooks {
+		if err := validateWebhookEvents(webhook.Events); err != nil {
+			return "", err
+		}
+	}
+
+	duplicates, err := s.findDuplicates(scan)
+	if err != nil {
+		return "", err
+	}
+	if len(duplicates) > 0 && s.strictDuplicates && !scan.Force {
+		return "", &DuplicateScanError{ScanIDs: duplicates}
+	}
+	if len(duplicates) > 0 {
+		s.logger.Warn("Scan duplicates queued, running or recent scans", logger.Fields{"module": scan.ScanType, "domain": scan.Domain, "duplicate_of": duplicates})
+	}
+	scan.DuplicateOf = duplicates
+
+	if s.maxBacklog > 0 && s.pending.count() >= s.maxBacklog {
+		return "", ErrQueueFull
+	}
+	if s.resourceGuard.Paused() {
+		return "", ErrResourcePressure
+	}
+
 	id := uuid.New().String()
 	scan.UUID = id
-	scan.Status = "queued"
+	scan.Status = models.ScanQueued
+	if scan.TeamID == 0 {
+		scan.TeamID = s.team
+	}
 
 	if err := s.scanDao.SaveScan(scan); err != nil {
 		s.logger.Error("SaveScan failed", logger.Fields{"error": err})
 		return "", err
 	}
 
-	go s.startScanExecution(scan)
+	for i := range scan.Webhooks {
+		scan.Webhooks[i].ScanID = id
+	}
+	if err := s.scanDao.SaveWebhooks(scan.Webhooks); err != nil {
+		s.logger.Error("SaveWebhooks failed", logger.Fields{"error": err, "scan_id": id})
+		s.statusManager.MarkFailedWithReason(id, fmt.Sprintf("Failed to register webhooks: %v", err))
+		return "", err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.pending.add(id, cancel)
+
+	go s.startScanExecution(ctx, scan)
 
 	return id, nil
 }
@@ -85,14 +352,403 @@ func (s *scanService) GetScanByUUID(id string) (*models.Scan, error) {
 	return scan, nil
 }
 
-func (s *scanService) ListScans() ([]models.Scan, error) {
-	return s.scanDao.ListScans()
+// GetScanSummary is GetScanByUUID without the subdomains.
+func (s *scanService) GetScanSummary(id string) (*models.Scan, error) {
+	scan, err := s.scanDao.GetScanSummary(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScanNotFound
+		}
+		return nil, err
+	}
+	return scan, nil
+}
+
+func (s *scanService) GetScanByDirectory(dir string) (*models.Scan, error) {
+	scan, err := s.scanDao.GetScanByDir(dir)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScanNotFound
+		}
+		return nil, err
+	}
+	return scan, nil
+}
+
+// ListSubdomains returns a page of the scan's subdomains in the order they
+// were found, and how many it has in all. A non-empty status keeps only
+// the subdomains in it, and counts only those.
+func (s *scanService) ListSubdomains(id string, status models.SubdomainStatus, page, limit int) ([]models.Subdomain, int64, error) {
+	if _, err := s.GetScanSummary(id); err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	if status == "" {
+		count, err := s.subdomainDao.CountByScan(id)
+		if err != nil {
+			return nil, 0, err
+		}
+		total = count
+	} else {
+		counts, err := s.subdomainDao.CountByStatus(id)
+		if err != nil {
+			return nil, 0, err
+		}
+		total = counts[status]
+	}
+	subdomains, err := s.subdomainDao.GetSubdomainsPaginated(id, status, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	return subdomains, total, nil
 }
 
-func (s *scanService) ListScansWithPagination(page, limit int) ([]models.Scan, int64, error) {
-	return s.scanDao.ListScansWithPagination(page, limit)
+// ListFindings returns a page of the scan's findings that match filter, in
+// the order they were found, and how many match in all. Without a finding
+// DAO there are none.
+func (s *scanService) ListFindings(id string, filter models.FindingFilter, page, limit int) ([]models.Finding, int64, error) {
+	if _, err := s.GetScanSummary(id); err != nil {
+		return nil, 0, err
+	}
+	if s.findingDao == nil {
+		return nil, 0, nil
+	}
+	total, err := s.findingDao.CountFindings(id, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	findings, err := s.findingDao.ListFindings(id, filter, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	return findings, total, nil
+}
+
+// GetSubdomainStats counts the scan's subdomains with ports, vulns and
+// screenshots, and in each status.
+func (s *scanService) GetSubdomainStats(id string) (models.SubdomainStats, error) {
+	if _, err := s.GetScanSummary(id); err != nil {
+		return models.SubdomainStats{}, err
+	}
+	return s.subdomainDao.StatsByScan(id)
+}
+
+// ListScansFiltered returns a page of the scans matching filter, newest
+// first, and how many match in all.
+func (s *scanService) ListScansFiltered(filter models.ScanFilter, page, limit int) ([]models.Scan, int64, error) {
+	return s.scanDao.ListScansFiltered(filter, page, limit)
+}
+
+// ListScansByCursor pages through scans newest first without counting or
+// skipping rows. An empty cursor starts at the newest scan; the returned
+// cursor is empty on the last page.
+func (s *scanService) ListScansByCursor(cursor string, limit int) ([]models.Scan, string, error) {
+	var after *dao.ScanCursor
+	if cursor != "" {
+		var err error
+		if after, err = dao.ParseScanCursor(cursor); err != nil {
+			return nil, "", fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+		}
+	}
+
+	scans, next, err := s.scanDao.ListScansAfter(after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	if next == nil {
+		return scans, "", nil
+	}
+	return scans, next.String(), nil
 }
 
 func (s *scanService) DeleteScan(id string) error {
-	return s.scanDao.DeleteScan(id)
+	if err := s.scanDao.DeleteScan(id); err != nil {
+		return err
+	}
+	if cancel, ok := s.pending.take(id); ok {
+		cancel()
+	}
+	s.dags.remove(id)
+	return nil
+}
+
+// CancelScan removes a scan from the queue, or stops it if it is already
+// running. A running scan's tools are killed and its monitors do their final
+// update before the executor records it as cancelled.
+func (s *scanService) CancelScan(id string) error {
+	if _, err := s.GetScanSummary(id); err != nil {
+		return err
+	}
+
+	if cancel, ok := s.pending.take(id); ok {
+		cancel()
+		if err := s.statusManager.MarkCancelled(id); err != nil {
+			return err
+		}
+		s.logger.Info("Queued scan cancelled", logger.Fields{"scan_id": id})
+		return nil
+	}
+
+	// a scan finishing right now keeps its own status
+	ctrl := s.running.get(id)
+	if ctrl == nil || !ctrl.requestCancel() {
+		return ErrScanNotCancellable
+	}
+	ctrl.cancel()
+
+	if err := s.statusManager.MarkCancelled(id); err != nil {
+		s.logger.Error("Failed to update scan to cancelled", logger.Fields{"scan_id": id, "error": err})
+	}
+	ctrl.report(tools.ProgressEvent{Status: tools.ProgressCancelled, Message: "scan cancelled, stopping tools", Timestamp: time.Now()})
+	s.logger.Info("Running scan cancelled", logger.Fields{"scan_id": id})
+	return nil
+}
+
+// RetryFailedTools runs the failed tools of a scan that completed with
+// warnings again, in its directory and with the module revision it ran
+// with. It returns the tools being retried.
+func (s *scanService) RetryFailedTools(id string) ([]string, error) {
+	scan, err := s.GetScanByUUID(id)
+	if err != nil {
+		return nil, err
+	}
+	if scan.Status != models.ScanCompletedWithWarnings || len(scan.FailedTools) == 0 {
+		return nil, ErrNothingToRetry
+	}
+	if scan.ScanDir == "" {
+		return nil, fmt.Errorf("%w: not recorded for scan %s", ErrScanDirMissing, id)
+	}
+	if info, err := os.Stat(scan.ScanDir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%w: %s", ErrScanDirMissing, scan.ScanDir)
+	}
+	origin, ok := utils.ModuleOriginFor(scan.ConfigSource, scan.ConfigRevision)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s at %s", ErrModuleUnavailable, scan.ConfigSource, scan.ConfigRevision)
+	}
+	if s.maxBacklog > 0 && s.pending.count() >= s.maxBacklog {
+		return nil, ErrQueueFull
+	}
+	if s.resourceGuard.Paused() {
+		return nil, ErrResourcePressure
+	}
+
+	failed := make([]string, len(scan.FailedTools))
+	for i, failure := range scan.FailedTools {
+		failed[i] = failure.ToolName
+	}
+
+	// only one retry gets the scan out of completed_with_warnings
+	updated, err := s.statusManager.MarkRequeued(id)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrNothingToRetry
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.pending.add(id, cancel)
+
+	s.logger.Info("Retrying failed tools", logger.Fields{"scan_id": id, "tools": failed})
+	go s.executor.Execute(ctx, id, scan.ScanType, scan.Domain, scan.Priority,
+		engine.WithModuleOrigin(origin),
+		engine.WithRetryFailed(scan.ScanDir, failed))
+
+	return failed, nil
+}
+
+// RerunScan starts a new scan with the module, domain, sensitive patterns,
+// tags and webhooks of a finished scan, and returns its id. The new scan's
+// RerunOf names the one it re-ran.
+func (s *scanService) RerunScan(id string) (string, error) {
+	source, err := s.GetScanSummary(id)
+	if err != nil {
+		return "", err
+	}
+	if !source.Status.IsTerminal() {
+		return "", ErrScanNotFinished
+	}
+	webhooks, err := s.scanDao.ListWebhooks(id)
+	if err != nil {
+		return "", err
+	}
+
+	rerun := &models.Scan{
+		ScanType:          source.ScanType,
+		Domain:            source.Domain,
+		SensitivePatterns: source.SensitivePatterns,
+		SensitiveFilters:  source.SensitiveFilters,
+		Tags:              source.Tags,
+		Priority:          source.Priority,
+		TeamID:            source.TeamID,
+		RerunOf:           id,
+		Force:             true,
+	}
+	for _, webhook := range webhooks {
+		rerun.Webhooks = append(rerun.Webhooks, models.ScanWebhook{URL: webhook.URL, Secret: webhook.Secret, Events: webhook.Events})
+	}
+
+	newID, err := s.StartScan(rerun)
+	if err != nil {
+		return "", err
+	}
+	s.logger.Info("Re-running scan", logger.Fields{"scan_id": newID, "rerun_of": id})
+	return newID, nil
+}
+
+func (s *scanService) GetHookExecutions(id string) ([]models.HookExecution, error) {
+	if _, err := s.GetScanSummary(id); err != nil {
+		return nil, err
+	}
+	return s.scanDao.ListHookExecutions(id)
+}
+
+// ListWebhookDeliveries returns the scan's webhook deliveries, newest first.
+func (s *scanService) ListWebhookDeliveries(id string) ([]models.WebhookDelivery, error) {
+	if _, err := s.GetScanSummary(id); err != nil {
+		return nil, err
+	}
+	return s.scanDao.ListWebhookDeliveries(id)
+}
+
+// RedeliverWebhook posts a recorded delivery's payload again and returns the
+// new delivery.
+func (s *scanService) RedeliverWebhook(id string, deliveryID uint) (*models.WebhookDelivery, error) {
+	if _, err := s.GetScanSummary(id); err != nil {
+		return nil, err
+	}
+	delivery, err := s.webhooks.redeliver(id, deliveryID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	return delivery, err
+}
+
+// GetScanDAG returns the latest dependency graph state of a hybrid scan run
+// since the server started.
+func (s *scanService) GetScanDAG(id string) (*tools.DAGSnapshot, error) {
+	if _, err := s.GetScanSummary(id); err != nil {
+		return nil, err
+	}
+	snap := s.dags.get(id)
+	if snap == nil {
+		return nil, ErrDAGUnavailable
+	}
+	return snap, nil
+}
+
+// SubscribeScanEvents returns the scan's events from now on and a func to
+// stop them, which must be called once done reading. Slow readers miss
+// events rather than hold up the scan.
+func (s *scanService) SubscribeScanEvents(id string) (<-chan ScanEvent, func()) {
+	return s.events.subscribe(id)
+}
+
+// PauseScan stops a running scan from starting new tools. Tools already
+// running finish, unless hard is set, in which case their processes are
+// suspended until the scan is resumed.
+func (s *scanService) PauseScan(id string, hard bool) error {
+	if _, err := s.GetScanSummary(id); err != nil {
+		return err
+	}
+	message := "scan paused, no new tools will start"
+	if hard {
+		message = "scan paused, running tools suspended"
+	}
+	ctrl := s.running.get(id)
+	if ctrl == nil || !s.pause(id, ctrl, hard, s.releaseOnPause, message) {
+		return ErrScanNotRunning
+	}
+	return nil
+}
+
+// pause closes the scan's gate, giving up its queue slot if releaseSlot is
+// set. It reports false if the scan was paused already.
+func (s *scanService) pause(id string, ctrl *runControl, hard, releaseSlot bool, message string) bool {
+	if !ctrl.gate.Pause(hard) {
+		return false
+	}
+
+	if err := s.statusManager.MarkPaused(id); err != nil {
+		s.logger.Error("Failed to update scan to paused", logger.Fields{"scan_id": id, "error": err})
+	}
+	if releaseSlot && ctrl.lease.Release() {
+		s.logger.Info("Released queue slot of paused scan", logger.Fields{"scan_id": id})
+	}
+
+	ctrl.report(tools.ProgressEvent{Status: tools.ProgressPaused, Message: message, Timestamp: time.Now()})
+	s.logger.Info("Scan paused", logger.Fields{"scan_id": id, "hard": hard})
+	return true
+}
+
+// ResumeScan lets a paused scan continue. A scan that gave up its queue slot
+// stays paused until it gets one back.
+func (s *scanService) ResumeScan(id string) error {
+	if _, err := s.GetScanSummary(id); err != nil {
+		return err
+	}
+	ctrl := s.running.get(id)
+	if ctrl == nil || !ctrl.gate.Paused() {
+		return ErrScanNotPaused
+	}
+
+	ctrl.mu.Lock()
+	defer ctrl.mu.Unlock()
+	if ctrl.resuming {
+		return nil
+	}
+
+	if ctrl.lease.Held() {
+		s.resume(id, ctrl)
+		return nil
+	}
+
+	ctrl.resuming = true
+	s.logger.Info("Paused scan waiting for a queue slot", logger.Fields{"scan_id": id})
+	go func() {
+		err := ctrl.lease.Reacquire(ctrl.ctx)
+
+		ctrl.mu.Lock()
+		defer ctrl.mu.Unlock()
+		ctrl.resuming = false
+		if err != nil {
+			return
+		}
+		s.resume(id, ctrl)
+	}()
+	return nil
+}
+
+func (s *scanService) resume(id string, ctrl *runControl) {
+	if !ctrl.gate.Resume() {
+		return
+	}
+	if err := s.statusManager.MarkRunning(id); err != nil {
+		s.logger.Error("Failed to update scan to running", logger.Fields{"scan_id": id, "error": err})
+	}
+	ctrl.report(tools.ProgressEvent{Status: tools.ProgressResumed, Message: "scan resumed", Timestamp: time.Now()})
+	s.logger.Info("Scan resumed", logger.Fields{"scan_id": id})
+}
+
+// recordHookExecution persists a hook run reported by the engine.
+func (s *scanService) recordHookExecution(scanID string, exec tools.HookExecution) {
+	record := &models.HookExecution{
+		ScanID:   scanID,
+		HookName: exec.Hook,
+		Scope:    exec.Scope,
+		Target:   exec.Target,
+		Status:   exec.Status,
+	}
+	if exec.Err != nil {
+		record.Error = exec.Err.Error()
+	}
+	if !exec.StartedAt.IsZero() {
+		record.StartedAt = exec.StartedAt.Unix()
+		record.FinishedAt = exec.FinishedAt.Unix()
+		record.DurationMs = exec.FinishedAt.Sub(exec.StartedAt).Milliseconds()
+	}
+
+	if err := s.scanDao.SaveHookExecution(record); err != nil {
+		s.logger.Error("Failed to record hook execution", logger.Fields{"error": err, "scan_id": scanID, "hook": exec.Hook})
+	}
 }
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+	"pipeliner/pkg/queue"
+	"pipeliner/pkg/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestScanDAO(t *testing.T) dao.ScanDAO {
+	t.Helper()
+	scanDao, _ := newTestDAOs(t)
+	return scanDao
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Scan{}, &models.ScanWebhook{}, &models.WebhookDelivery{}, &models.Subdomain{}, &models.Finding{}))
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return db
+}
+
+func newTestDAOs(t *testing.T) (dao.ScanDAO, dao.SubdomainDAO) {
+	t.Helper()
+	db := newTestDB(t)
+	return dao.NewScanDAO(db), dao.NewSubdomainDAO(db)
+}
+
+func waitForQueued(t *testing.T, q queue.Queue, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, queued, _ := q.GetStatus(); queued == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("queue never reached %d waiting scans", want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScanService_CancelQueuedScan(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	q := queue.New(1)
+	svc := NewScanService(scanDao, subdomainDao, WithQueue(q))
+
+	// hold the only slot so every scan started below stays queued
+	release := make(chan struct{})
+	holding := make(chan struct{})
+	go q.ExecuteWithQueue(context.Background(), 0, func() error {
+		close(holding)
+		<-release
+		return nil
+	})
+	<-holding
+	defer close(release)
+
+	second, err := svc.StartScan(&models.Scan{ScanType: "full", Domain: "second.example.com"})
+	require.NoError(t, err)
+	waitForQueued(t, q, 1)
+
+	third, err := svc.StartScan(&models.Scan{ScanType: "full", Domain: "third.example.com"})
+	require.NoError(t, err)
+	waitForQueued(t, q, 2)
+
+	require.NoError(t, svc.CancelScan(second))
+	waitForQueued(t, q, 1)
+
+	scan, err := svc.GetScanByUUID(second)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanCancelled, scan.Status)
+
+	assert.ErrorIs(t, svc.CancelScan(second), ErrScanNotCancellable)
+	assert.ErrorIs(t, svc.CancelScan("missing"), ErrScanNotFound)
+
+	scan, err = svc.GetScanByUUID(third)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanQueued, scan.Status)
+
+	// deleting a queued scan also pulls it out of the queue
+	require.NoError(t, svc.DeleteScan(third))
+	waitForQueued(t, q, 0)
+}
+
+func TestScanService_CancelRunningScan(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	svc := NewScanService(scanDao, subdomainDao, WithQueue(queue.New(1))).(*scanService)
+
+	running := func(id string) (*runControl, context.Context) {
+		require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: id, Status: "running"}))
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		ctrl := &runControl{ctx: ctx, cancel: cancel, report: func(tools.ProgressEvent) {}}
+		svc.running.add(id, ctrl)
+		return ctrl, ctx
+	}
+	status := func(id string) models.ScanStatus {
+		scan, err := svc.GetScanByUUID(id)
+		require.NoError(t, err)
+		return scan.Status
+	}
+
+	ctrl, ctx := running("cancelled")
+	require.NoError(t, svc.CancelScan("cancelled"))
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	assert.Equal(t, models.ScanCancelled, status("cancelled"))
+	assert.True(t, ctrl.finish(), "the executor should see the cancel")
+
+	// the run ended before the cancel got to it
+	ctrl, ctx = running("finishing")
+	assert.False(t, ctrl.finish())
+	assert.ErrorIs(t, svc.CancelScan("finishing"), ErrScanNotCancellable)
+	assert.NoError(t, ctx.Err())
+	assert.Equal(t, models.ScanRunning, status("finishing"))
+
+	// whichever gets there first decides, never both
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("race-%d", i)
+		ctrl, _ := running(id)
+
+		var wg sync.WaitGroup
+		var cancelErr error
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cancelErr = svc.CancelScan(id)
+		}()
+		cancelled := ctrl.finish()
+		wg.Wait()
+
+		if cancelled {
+			assert.NoError(t, cancelErr)
+			assert.Equal(t, models.ScanCancelled, status(id))
+		} else {
+			assert.ErrorIs(t, cancelErr, ErrScanNotCancellable)
+			assert.Equal(t, models.ScanRunning, status(id))
+		}
+	}
+}
+
+func TestScanService_BacklogGuard(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	q := queue.New(1)
+	svc := NewScanService(scanDao, subdomainDao, WithQueue(q), WithMaxBacklog(1))
+
+	release := make(chan struct{})
+	holding := make(chan struct{})
+	go q.ExecuteWithQueue(context.Background(), 0, func() error {
+		close(holding)
+		<-release
+		return nil
+	})
+	<-holding
+	defer close(release)
+
+	first, err := svc.StartScan(&models.Scan{ScanType: "full", Domain: "first.example.com"})
+	require.NoError(t, err)
+	waitForQueued(t, q, 1)
+
+	_, err = svc.StartScan(&models.Scan{ScanType: "full", Domain: "second.example.com"})
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	// a cancelled scan frees its place in the backlog
+	require.NoError(t, svc.CancelScan(first))
+	waitForQueued(t, q, 0)
+	_, err = svc.StartScan(&models.Scan{ScanType: "full", Domain: "second.example.com"})
+	assert.NoError(t, err)
+}
+
+func TestScanService_RetryFailedTools(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	q := queue.New(1)
+	svc := NewScanService(scanDao, subdomainDao, WithQueue(q))
+
+	release := make(chan struct{})
+	holding := make(chan struct{})
+	go q.ExecuteWithQueue(context.Background(), 0, func() error {
+		close(holding)
+		<-release
+		return nil
+	})
+	<-holding
+	defer close(release)
+
+	failed := []models.ToolFailure{{ToolName: "nuclei", Error: "exit status 1"}}
+	scans := []*models.Scan{
+		{UUID: "clean", Status: "completed", ScanDir: t.TempDir(), ConfigSource: "local"},
+		{UUID: "gone", Status: "completed_with_warnings", FailedTools: failed, ScanDir: filepath.Join(t.TempDir(), "removed"), ConfigSource: "local"},
+		{UUID: "pruned", Status: "completed_with_warnings", FailedTools: failed, ScanDir: t.TempDir(), ConfigSource: "https://example.com/modules.git@main", ConfigRevision: "0123abc"},
+		{UUID: "partial", Status: "completed_with_warnings", FailedTools: failed, ScanDir: t.TempDir(), ConfigSource: "local", ScanType: "full", Domain: "example.com"},
+	}
+	for _, scan := range scans {
+		require.NoError(t, scanDao.SaveScan(scan))
+	}
+
+	_, err := svc.RetryFailedTools("clean")
+	assert.ErrorIs(t, err, ErrNothingToRetry)
+	_, err = svc.RetryFailedTools("gone")
+	assert.ErrorIs(t, err, ErrScanDirMissing)
+	_, err = svc.RetryFailedTools("pruned")
+	assert.ErrorIs(t, err, ErrModuleUnavailable)
+	_, err = svc.RetryFailedTools("missing")
+	assert.ErrorIs(t, err, ErrScanNotFound)
+
+	retried, err := svc.RetryFailedTools("partial")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"nuclei"}, retried)
+	waitForQueued(t, q, 1)
+
+	scan, err := svc.GetScanByUUID("partial")
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanQueued, scan.Status)
+
+	// the queued retry owns the scan until it finishes
+	_, err = svc.RetryFailedTools("partial")
+	assert.ErrorIs(t, err, ErrNothingToRetry)
+
+	require.NoError(t, svc.CancelScan("partial"))
+	waitForQueued(t, q, 0)
+}
+
+func TestScanService_RerunScan(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	q := queue.New(1)
+	svc := NewScanService(scanDao, subdomainDao, WithQueue(q))
+
+	release := make(chan struct{})
+	holding := make(chan struct{})
+	go q.ExecuteWithQueue(context.Background(), 0, func() error {
+		close(holding)
+		<-release
+		return nil
+	})
+	<-holding
+	defer close(release)
+
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "running", Status: models.ScanRunning, ScanType: "full", Domain: "example.com"}))
+	require.NoError(t, scanDao.SaveScan(&models.Scan{
+		UUID:              "failed",
+		Status:            models.ScanFailed,
+		ScanType:          "full",
+		Domain:            "example.com",
+		SensitivePatterns: "secret",
+		SensitiveFilters:  models.SensitiveFilters{MinLength: 10},
+		Tags:              []string{"weekly"},
+		ErrorMessage:      "boom",
+	}))
+	require.NoError(t, scanDao.SaveWebhooks([]models.ScanWebhook{{ScanID: "failed", URL: "https://hooks.example.com", Events: []string{models.WebhookEventCompleted}}}))
+
+	_, err := svc.RerunScan("running")
+	assert.ErrorIs(t, err, ErrScanNotFinished)
+	_, err = svc.RerunScan("missing")
+	assert.ErrorIs(t, err, ErrScanNotFound)
+
+	id, err := svc.RerunScan("failed")
+	require.NoError(t, err)
+	assert.NotEqual(t, "failed", id)
+	waitForQueued(t, q, 1)
+
+	rerun, err := svc.GetScanByUUID(id)
+	require.NoError(t, err)
+	assert.Equal(t, "failed", rerun.RerunOf)
+	assert.Equal(t, models.ScanQueued, rerun.Status)
+	assert.Equal(t, "full", rerun.ScanType)
+	assert.Equal(t, "example.com", rerun.Domain)
+	assert.Equal(t, "secret", rerun.SensitivePatterns)
+	assert.Equal(t, 10, rerun.SensitiveFilters.MinLength)
+	assert.Equal(t, []string{"weekly"}, rerun.Tags)
+	assert.Empty(t, rerun.ErrorMessage)
+
+	webhooks, err := scanDao.ListWebhooks(id)
+	require.NoError(t, err)
+	require.Len(t, webhooks, 1)
+	assert.Equal(t, "https://hooks.example.com", webhooks[0].URL)
+
+	require.NoError(t, svc.CancelScan(id))
+	waitForQueued(t, q, 0)
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"pipeliner/internal/config"
+	"pipeliner/internal/dao"
+	"pipeliner/internal/metrics"
+	"pipeliner/internal/models"
+	"pipeliner/internal/notification"
+	"pipeliner/pkg/logger"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The metric names and labels are what dashboards and alert rules match
+// on; do not rename them.
+const (
+	metricDomainLastSuccess   = "pipeliner_domain_last_success_timestamp_seconds"
+	metricScanDuration        = "pipeliner_scan_duration_seconds"
+	metricScanOldestRunning   = "pipeliner_scan_oldest_running_seconds"
+	metricScanStuckThreshold  = "pipeliner_scan_stuck_threshold_seconds"
+	stuckDurationSampleWindow = 50
+	staleDomainsListed        = 10
+)
+
+var scanDurationBuckets = []float64{60, 300, 900, 1800, 3600, 7200, 14400, 28800, 86400}
+
+// scanSLO keeps the scan SLO metrics and, every check interval, notifies
+// about domains without a recent successful scan and scans running longer
+// than their module usually takes. A scan's duration is from when it was
+// created to when it finished, as for estimates.
+type scanSLO struct {
+	scanDao  dao.ScanDAO
+	logger   *logger.Logger
+	notifier notification.Notifier
+	config   config.SLOConfig
+	now      func() time.Time
+
+	lastSuccess    *metrics.GaugeVec
+	duration       *metrics.HistogramVec
+	oldestRunning  *metrics.GaugeVec
+	stuckThreshold *metrics.GaugeVec
+
+	// only touched by check; a domain or scan is notified about once until
+	// it recovers
+	staleNotified map[string]bool
+	stuckNotified map[string]bool
+}
+
+func newScanSLO(scanDao dao.ScanDAO, logger *logger.Logger, notifier notification.Notifier, cfg config.SLOConfig, registry *metrics.Registry) *scanSLO {
+	return &scanSLO{
+		scanDao:  scanDao,
+		logger:   logger,
+		notifier: notifier,
+		config:   cfg,
+		now:      time.Now,
+		lastSuccess: registry.NewGaugeVec(metricDomainLastSuccess,
+			"When the newest completed or completed_with_warnings scan of the domain finished, in unix seconds.", "domain"),
+		duration: registry.NewHistogramVec(metricScanDuration,
+			"Seconds from creating a scan to its final status.", scanDurationBuckets, "module", "status"),
+		oldestRunning: registry.NewGaugeVec(metricScanOldestRunning,
+			"Seconds since the longest running scan of the module was created.", "module"),
+		stuckThreshold: registry.NewGaugeVec(metricScanStuckThreshold,
+			"Duration percentile of the module's recent successful scans past which a running scan counts as stuck.", "module"),
+		staleNotified: make(map[string]bool),
+		stuckNotified: make(map[string]bool),
+	}
+}
+
+// run checks right away and then every check interval, for the life of
+// the process.
+func (s *scanSLO) run() {
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+	for {
+		s.check()
+		<-ticker.C
+	}
+}
+
+// scanFinished records a scan that reached its final status. It does
+// nothing without WithSLOMetrics.
+func (s *scanSLO) scanFinished(scanID string) {
+	if s == nil {
+		return
+	}
+	scan, err := s.scanDao.GetScanSummary(scanID)
+	if err != nil || scan == nil {
+		s.logger.Error("Failed to load scan for SLO metrics", logger.Fields{"scan_id": scanID, "error": err})
+		return
+	}
+	if !scan.Status.IsTerminal() {
+		return
+	}
+	s.duration.Observe(float64(max(scan.UpdatedAt-scan.CreatedAt, 0)), scan.ScanType, string(scan.Status))
+	if slices.Contains(models.FinishedScanStatuses, scan.Status) {
+		s.lastSuccess.Set(float64(scan.UpdatedAt), scan.Domain)
+	}
+}
+
+func (s *scanSLO) check() {
+	now := s.now()
+	s.checkStale(now)
+	s.checkRunning(now)
+}
+
+// checkStale refreshes the last success of every domain from the database,
+// which also covers scans finished before the server started.
+func (s *scanSLO) checkStale(now time.Time) {
+	finished, err := s.scanDao.LastFinishedByDomain()
+	if err != nil {
+		s.logger.Error("Failed to load last successful scans", logger.Fields{"error": err})
+		return
+	}
+
+	var stale []string
+	for domain, at := range finished {
+		s.lastSuccess.Set(float64(at), domain)
+		if s.config.StaleAfter <= 0 {
+			continue
+		}
+		if now.Sub(time.Unix(at, 0)) < s.config.StaleAfter {
+			delete(s.staleNotified, domain)
+			continue
+		}
+		if !s.staleNotified[domain] {
+			s.staleNotified[domain] = true
+			stale = append(stale, domain)
+		}
+	}
+	if len(stale) == 0 {
+		return
+	}
+	sort.Strings(stale)
+
+	listed := stale
+	if len(listed) > staleDomainsListed {
+		listed = listed[:staleDomainsListed]
+	}
+	description := strings.Join(listed, "\n")
+	if more := len(stale) - len(listed); more > 0 {
+		description += fmt.Sprintf("\n... and %d more", more)
+	}
+	s.notify(notification.Message{
+		Title:       fmt.Sprintf("No successful scan of %d domain(s) in %s", len(stale), formatSLODuration(s.config.StaleAfter)),
+		Description: description,
+		Severity:    "medium",
+		EventType:   notification.EventScanLifecycle,
+		Fields:      map[string]string{"Threshold": formatSLODuration(s.config.StaleAfter)},
+		Timestamp:   now,
+	})
+}
+
+// checkRunning updates how long each module's oldest running scan has been
+// going and notifies about scans past their module's stuck threshold.
+func (s *scanSLO) checkRunning(now time.Time) {
+	running, _, err := s.scanDao.ListScansFiltered(models.ScanFilter{Statuses: []models.ScanStatus{models.ScanRunning}}, 1, 100)
+	if err != nil {
+		s.logger.Error("Failed to load running scans", logger.Fields{"error": err})
+		return
+	}
+
+	s.oldestRunning.Reset()
+	s.stuckThreshold.Reset()
+	thresholds := make(map[string]time.Duration)
+	stillRunning := make(map[string]bool, len(running))
+	for _, scan := range running {
+		stillRunning[scan.UUID] = true
+		age := now.Sub(time.Unix(scan.CreatedAt, 0))
+		if oldest, ok := s.oldestRunning.Value(scan.ScanType); !ok || age.Seconds() > oldest {
+			s.oldestRunning.Set(age.Seconds(), scan.ScanType)
+		}
+
+		if s.config.StuckPercentile <= 0 {
+			continue
+		}
+		threshold, ok := thresholds[scan.ScanType]
+		if !ok {
+			threshold = s.stuckAfter(scan.ScanType)
+			thresholds[scan.ScanType] = threshold
+			if threshold > 0 {
+				s.stuckThreshold.Set(threshold.Seconds(), scan.ScanType)
+			}
+		}
+		if threshold <= 0 || age <= threshold || s.stuckNotified[scan.UUID] {
+			continue
+		}
+		s.stuckNotified[scan.UUID] = true
+		s.notify(notification.Message{
+			Title:       fmt.Sprintf("Scan of %s is running longer than usual", scan.Domain),
+			Description: fmt.Sprintf("It has been running for %s; %g%% of recent %s scans finished within %s.", formatSLODuration(age), s.config.StuckPercentile, scan.ScanType, formatSLODuration(threshold)),
+			Severity:    "medium",
+			EventType:   notification.EventScanLifecycle,
+			Fields: map[string]string{
+				"Scan":   scan.UUID,
+				"Domain": scan.Domain,
+				"Module": scan.ScanType,
+			},
+			Timestamp: now,
+		})
+	}
+	for id := range s.stuckNotified {
+		if !stillRunning[id] {
+			delete(s.stuckNotified, id)
+		}
+	}
+}
+
+// stuckAfter is the configured percentile of the module's recent
+// successful scan durations, or 0 without enough of them.
+func (s *scanSLO) stuckAfter(module string) time.Duration {
+	scans, err := s.scanDao.ListFinishedScans(module, stuckDurationSampleWindow)
+	if err != nil {
+		s.logger.Error("Failed to load scan durations", logger.Fields{"module": module, "error": err})
+		return 0
+	}
+	if len(scans) < s.config.StuckMinSamples {
+		return 0
+	}
+	durations := make([]int64, len(scans))
+	for i, scan := range scans {
+		durations[i] = max(scan.UpdatedAt-scan.CreatedAt, 0)
+	}
+	slices.Sort(durations)
+	// nearest rank
+	rank := int(math.Ceil(s.config.StuckPercentile / 100 * float64(len(durations))))
+	return time.Duration(durations[max(rank-1, 0)]) * time.Second
+}
+
+func (s *scanSLO) notify(msg notification.Message) {
+	s.logger.Warn(msg.Title, logger.Fields{"description": msg.Description})
+	if s.notifier == nil {
+		return
+	}
+	if err := s.notifier.Send(msg); err != nil {
+		s.logger.Warn("Failed to send SLO notification", logger.Fields{"error": err})
+	}
+}
+
+func formatSLODuration(d time.Duration) string {
+	if d >= 48*time.Hour && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%d days", d/(24*time.Hour))
+	}
+	return d.Round(time.Minute).String()
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pipeliner/internal/config"
+	"pipeliner/internal/dao"
+	"pipeliner/internal/metrics"
+	"pipeliner/internal/models"
+	"pipeliner/internal/notification"
+	"pipeliner/pkg/engine"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/queue"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type recordingNotifier struct {
+	mu       sync.Mutex
+	messages []notification.Message
+}
+
+func (n *recordingNotifier) Send(msg notification.Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+func (n *recordingNotifier) Close() error { return nil }
+
+func (n *recordingNotifier) sent() []notification.Message {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]notification.Message(nil), n.messages...)
+}
+
+func TestScanSLO_UpdatesMetricsWhenScanFinishes(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	registry := metrics.NewRegistry()
+	factory := func(...engine.OptFunc) (ScanEngine, error) {
+		return &fakeEngine{scanDir: t.TempDir(), logger: logger.NewLogger(logrus.ErrorLevel)}, nil
+	}
+	svc := NewScanService(scanDao, subdomainDao, WithQueue(queue.New(1)), WithEngineFactory(factory),
+		WithSLOMetrics(registry, config.DefaultSLOConfig()))
+
+	id, err := svc.StartScan(&models.Scan{ScanType: "full", Domain: "example.com"})
+	require.NoError(t, err)
+	scan := waitForFinished(t, scanDao, id)
+	require.Equal(t, models.ScanCompleted, scan.Status)
+
+	slo := svc.(*scanService).slo
+	// the metrics are updated after the final status is written
+	require.Eventually(t, func() bool {
+		return slo.duration.Count("full", "completed") == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	last, ok := slo.lastSuccess.Value("example.com")
+	require.True(t, ok)
+	assert.Equal(t, float64(scan.UpdatedAt), last)
+
+	var out strings.Builder
+	_, err = registry.WriteTo(&out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), `pipeliner_domain_last_success_timestamp_seconds{domain="example.com"}`)
+	assert.Contains(t, out.String(), `pipeliner_scan_duration_seconds_count{module="full",status="completed"} 1`)
+}
+
+func newTestScanSLO(t *testing.T, cfg config.SLOConfig) (*scanSLO, *gorm.DB, *recordingNotifier, *time.Time) {
+	t.Helper()
+	db := newTestDB(t)
+	notifier := &recordingNotifier{}
+	slo := newScanSLO(dao.NewScanDAO(db), logger.NewLogger(logrus.ErrorLevel), notifier, cfg, metrics.NewRegistry())
+	now := time.Unix(1_000_000, 0)
+	slo.now = func() time.Time { return now }
+	return slo, db, notifier, &now
+}
+
+func createScanAt(t *testing.T, db *gorm.DB, scan models.Scan) {
+	t.Helper()
+	require.NoError(t, db.Create(&scan).Error)
+	// gorm stamps UpdatedAt on create
+	require.NoError(t, db.Model(&models.Scan{}).Where("uuid = ?", scan.UUID).UpdateColumn("updated_at", scan.UpdatedAt).Error)
+}
+
+func TestScanSLO_NotifiesStaleDomainsOnce(t *testing.T) {
+	slo, db, notifier, now := newTestScanSLO(t, config.SLOConfig{StaleAfter: 24 * time.Hour})
+	day := int64(24 * 60 * 60)
+	createScanAt(t, db, models.Scan{UUID: "fresh", Domain: "fresh.com", Status: models.ScanCompleted, CreatedAt: now.Unix() - 100, UpdatedAt: now.Unix() - 60})
+	createScanAt(t, db, models.Scan{UUID: "stale", Domain: "stale.com", Status: models.ScanCompleted, CreatedAt: now.Unix() - 2*day, UpdatedAt: now.Unix() - 2*day + 60})
+
+	slo.check()
+	sent := notifier.sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, "No successful scan of 1 domain(s) in 24h0m0s", sent[0].Title)
+	assert.Equal(t, "stale.com", sent[0].Description)
+	last, _ := slo.lastSuccess.Value("stale.com")
+	assert.Equal(t, float64(now.Unix()-2*day+60), last)
+
+	// still stale: no repeat
+	*now = now.Add(time.Hour)
+	slo.check()
+	assert.Len(t, notifier.sent(), 1)
+
+	// fresh.com goes stale a day later
+	*now = now.Add(24 * time.Hour)
+	slo.check()
+	sent = notifier.sent()
+	require.Len(t, sent, 2)
+	assert.Equal(t, "fresh.com", sent[1].Description)
+
+	// a new success ends the episode, so going stale again notifies again
+	createScanAt(t, db, models.Scan{UUID: "again", Domain: "stale.com", Status: models.ScanCompletedWithWarnings, CreatedAt: now.Unix() - 60, UpdatedAt: now.Unix()})
+	slo.check()
+	*now = now.Add(25 * time.Hour)
+	slo.check()
+	sent = notifier.sent()
+	require.Len(t, sent, 3)
+	assert.Equal(t, "stale.com", sent[2].Description)
+}
+
+func TestScanSLO_NotifiesStuckScans(t *testing.T) {
+	slo, db, notifier, now := newTestScanSLO(t, config.SLOConfig{StuckPercentile: 95, StuckMinSamples: 3})
+	for i, duration := range []int64{100, 200, 300} {
+		createScanAt(t, db, models.Scan{UUID: fmt.Sprintf("done-%d", i), Domain: "example.com", ScanType: "full",
+			Status: models.ScanCompleted, CreatedAt: int64(i) * 1000, UpdatedAt: int64(i)*1000 + duration})
+	}
+	createScanAt(t, db, models.Scan{UUID: "running", Domain: "example.com", ScanType: "full", Status: models.ScanRunning, CreatedAt: now.Unix() - 250})
+	createScanAt(t, db, models.Scan{UUID: "unknown", Domain: "example.com", ScanType: "quick", Status: models.ScanRunning, CreatedAt: now.Unix() - 5000})
+
+	slo.check()
+	assert.Empty(t, notifier.sent())
+	oldest, _ := slo.oldestRunning.Value("full")
+	assert.Equal(t, float64(250), oldest)
+	threshold, _ := slo.stuckThreshold.Value("full")
+	assert.Equal(t, float64(300), threshold)
+	// too few quick scans to tell
+	_, ok := slo.stuckThreshold.Value("quick")
+	assert.False(t, ok)
+
+	*now = now.Add(time.Minute)
+	slo.check()
+	slo.check()
+	sent := notifier.sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, "running", sent[0].Fields["Scan"])
+	assert.Equal(t, "full", sent[0].Fields["Module"])
+}
@@ -1,32 +1,248 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"pipeliner/internal/dao"
 	"pipeliner/internal/models"
+	"pipeliner/internal/utils"
+	perrors "pipeliner/pkg/errors"
 	"pipeliner/pkg/logger"
 	"pipeliner/pkg/tools"
+	"slices"
 )
 
+// ErrStatusTransition is returned when a scan's status may not move to
+// the one asked for, usually because it already finished.
+var ErrStatusTransition = errors.New("scan status transition not allowed")
+
+// ScanStatusManager is the only writer of scan statuses. Every change goes
+// through models.ScanStatus.CanTransition, checked against the stored status
+// in the same statement that writes the new one.
 type ScanStatusManager struct {
 	scanDao dao.ScanDAO
 	logger  *logger.Logger
+	events  *scanEvents
 }
 
-func newScanStatusManager(scanDao dao.ScanDAO, logger *logger.Logger) *ScanStatusManager {
+func newScanStatusManager(scanDao dao.ScanDAO, logger *logger.Logger, events *scanEvents) *ScanStatusManager {
 	return &ScanStatusManager{
 		scanDao: scanDao,
 		logger:  logger,
+		events:  events,
+	}
+}
+
+// publish tells the scan's subscribers about a status change.
+func (m *ScanStatusManager) publish(scanID string, event StatusEvent) {
+	m.events.publish(scanID, ScanEventStatus, event)
+}
+
+// transition moves the scan to status if it may get there from where it is.
+func (m *ScanStatusManager) transition(scanID string, status models.ScanStatus) (bool, error) {
+	updated, err := m.scanDao.UpdateStatusFrom(scanID, status, models.ScanStatusesInto(status))
+	if updated {
+		m.publish(scanID, StatusEvent{Status: status})
 	}
+	return updated, err
 }
 
-func (m *ScanStatusManager) UpdateStatus(scanID, status string) error {
-	scan, err := m.scanDao.GetScanByUUID(scanID)
+// transitionScan saves the scan with its new status if it may get there
+// from where it is.
+func (m *ScanStatusManager) transitionScan(scan *models.Scan) error {
+	updated, err := m.scanDao.TransitionScan(scan, models.ScanStatusesInto(scan.Status))
 	if err != nil {
 		return err
 	}
-	scan.Status = status
-	return m.scanDao.UpdateScan(scan)
+	if !updated {
+		return fmt.Errorf("%w: scan %s to %s", ErrStatusTransition, scan.UUID, scan.Status)
+	}
+	return nil
+}
+
+// MarkRunning moves a queued or paused scan to running.
+func (m *ScanStatusManager) MarkRunning(scanID string) error {
+	_, err := m.transition(scanID, models.ScanRunning)
+	return err
+}
+
+// MarkPaused moves a running scan to paused.
+func (m *ScanStatusManager) MarkPaused(scanID string) error {
+	_, err := m.transition(scanID, models.ScanPaused)
+	return err
+}
+
+// MarkCancelled moves a queued, running or paused scan to cancelled. Scans
+// that already finished are left untouched.
+func (m *ScanStatusManager) MarkCancelled(scanID string) error {
+	updated, err := m.transition(scanID, models.ScanCancelled)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return fmt.Errorf("scan %s has already finished", scanID)
+	}
+	return nil
+}
+
+// MarkRequeued queues a scan that completed with warnings again, for a
+// retry. Only one of concurrent retries gets it.
+func (m *ScanStatusManager) MarkRequeued(scanID string) (bool, error) {
+	return m.transition(scanID, models.ScanQueued)
+}
+
+// RecordHookWarnings stores tolerated non-critical hook failures on the scan.
+func (m *ScanStatusManager) RecordHookWarnings(scanID string, warnings []tools.HookWarning) error {
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	scan, err := m.scanDao.GetScanSummary(scanID)
+	if err != nil {
+		return fmt.Errorf("load scan: %w", err)
+	}
+
+	for _, w := range warnings {
+		scan.HookWarnings = append(scan.HookWarnings, models.HookWarning{
+			HookName: w.Hook,
+			ToolName: w.Tool,
+			Error:    w.Err.Error(),
+		})
+	}
+
+	if err := m.scanDao.UpdateScan(scan); err != nil {
+		return fmt.Errorf("persist hook warnings: %w", err)
+	}
+	return nil
+}
+
+// RecordToolWarnings stores the warnings found in the tools' stderr on the
+// scan, once per tool and line.
+func (m *ScanStatusManager) RecordToolWarnings(scanID string, warnings []tools.ToolWarning) error {
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	scan, err := m.scanDao.GetScanSummary(scanID)
+	if err != nil {
+		return fmt.Errorf("load scan: %w", err)
+	}
+	for _, w := range warnings {
+		warning := models.ToolWarning{ToolName: w.Tool, Pattern: w.Pattern, Line: w.Line}
+		// retries and replacement values repeat the same lines
+		if !slices.Contains(scan.ToolWarnings, warning) {
+			scan.ToolWarnings = append(scan.ToolWarnings, warning)
+		}
+	}
+
+	if err := m.scanDao.UpdateScan(scan); err != nil {
+		return fmt.Errorf("persist tool warnings: %w", err)
+	}
+	return nil
+}
+
+// RecordEmptyOutputs stores the tools that exited cleanly without output.
+func (m *ScanStatusManager) RecordEmptyOutputs(scanID string, toolNames []string) error {
+	if len(toolNames) == 0 {
+		return nil
+	}
+
+	scan, err := m.scanDao.GetScanSummary(scanID)
+	if err != nil {
+		return fmt.Errorf("load scan: %w", err)
+	}
+	for _, name := range toolNames {
+		// a retried tool may report again
+		if !slices.Contains(scan.EmptyOutputTools, name) {
+			scan.EmptyOutputTools = append(scan.EmptyOutputTools, name)
+		}
+	}
+
+	if err := m.scanDao.UpdateScan(scan); err != nil {
+		return fmt.Errorf("persist empty outputs: %w", err)
+	}
+	return nil
+}
+
+// RecordModuleOrigin stores where the scan's module was loaded from and the
+// checksum of the file it was parsed from.
+func (m *ScanStatusManager) RecordModuleOrigin(scanID string, origin utils.ModuleOrigin, checksum string) error {
+	scan, err := m.scanDao.GetScanSummary(scanID)
+	if err != nil {
+		return fmt.Errorf("load scan: %w", err)
+	}
+
+	scan.ConfigSource = origin.Source
+	scan.ConfigRevision = origin.Revision
+	scan.ConfigChecksum = checksum
+	if err := m.scanDao.UpdateScan(scan); err != nil {
+		return fmt.Errorf("persist module origin: %w", err)
+	}
+	return nil
+}
+
+// RecordScanDirectory stores the directory the scan's tools write to.
+func (m *ScanStatusManager) RecordScanDirectory(scanID, dir string) error {
+	scan, err := m.scanDao.GetScanSummary(scanID)
+	if err != nil {
+		return fmt.Errorf("load scan: %w", err)
+	}
+
+	scan.ScanDir = dir
+	if err := m.scanDao.UpdateScan(scan); err != nil {
+		return fmt.Errorf("persist scan directory: %w", err)
+	}
+	return nil
+}
+
+// RecordHTTPHeaders stores the headers the scan's tools sent.
+func (m *ScanStatusManager) RecordHTTPHeaders(scanID string, headers map[string]string) error {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	scan, err := m.scanDao.GetScanSummary(scanID)
+	if err != nil {
+		return fmt.Errorf("load scan: %w", err)
+	}
+
+	scan.HTTPHeaders = headers
+	if err := m.scanDao.UpdateScan(scan); err != nil {
+		return fmt.Errorf("persist http headers: %w", err)
+	}
+	return nil
+}
+
+// RecordProxy stores the proxy the scan's tools went through.
+func (m *ScanStatusManager) RecordProxy(scanID, proxy string) error {
+	if proxy == "" {
+		return nil
+	}
+
+	scan, err := m.scanDao.GetScanSummary(scanID)
+	if err != nil {
+		return fmt.Errorf("load scan: %w", err)
+	}
+
+	scan.Proxy = proxy
+	if err := m.scanDao.UpdateScan(scan); err != nil {
+		return fmt.Errorf("persist proxy: %w", err)
+	}
+	return nil
+}
+
+// RecordTemplatesRef stores the nuclei-templates ref the scan ran with.
+func (m *ScanStatusManager) RecordTemplatesRef(scanID, ref string) error {
+	scan, err := m.scanDao.GetScanSummary(scanID)
+	if err != nil {
+		return fmt.Errorf("load scan: %w", err)
+	}
+
+	scan.TemplatesRef = ref
+	if err := m.scanDao.UpdateScan(scan); err != nil {
+		return fmt.Errorf("persist templates ref: %w", err)
+	}
+	return nil
 }
 
 func (m *ScanStatusManager) MarkFailed(scanID string) {
@@ -34,7 +250,7 @@ func (m *ScanStatusManager) MarkFailed(scanID string) {
 }
 
 func (m *ScanStatusManager) MarkFailedWithReason(scanID string, reason string) {
-	scan, err := m.scanDao.GetScanByUUID(scanID)
+	scan, err := m.scanDao.GetScanSummary(scanID)
 	if err != nil {
 		m.logger.Error("Failed to load scan for failure update", logger.Fields{"error": err, "scan_id": scanID})
 		return
@@ -44,12 +260,14 @@ func (m *ScanStatusManager) MarkFailedWithReason(scanID string, reason string) {
 		return
 	}
 
-	scan.Status = "failed"
+	scan.Status = models.ScanFailed
 	scan.ErrorMessage = reason
 
-	if err := m.scanDao.UpdateScan(scan); err != nil {
+	if err := m.transitionScan(scan); err != nil {
 		m.logger.Error("Failed to persist failed scan status", logger.Fields{"error": err, "scan_id": scanID})
+		return
 	}
+	m.publish(scanID, StatusEvent{Status: models.ScanFailed, ErrorMessage: reason})
 
 	m.logger.Error("Scan marked as failed", logger.Fields{
 		"scan_id": scanID,
@@ -58,7 +276,7 @@ func (m *ScanStatusManager) MarkFailedWithReason(scanID string, reason string) {
 }
 
 func (m *ScanStatusManager) MarkCompleted(scanID string) error {
-	scan, err := m.scanDao.GetScanByUUID(scanID)
+	scan, err := m.scanDao.GetScanSummary(scanID)
 	if err != nil {
 		return fmt.Errorf("load scan: %w", err)
 	}
@@ -66,17 +284,20 @@ func (m *ScanStatusManager) MarkCompleted(scanID string) error {
 		return fmt.Errorf("scan %s not found", scanID)
 	}
 
-	scan.Status = "completed"
+	scan.Status = models.ScanCompleted
+	// a retry that fixed every failed tool
+	scan.FailedTools = nil
 
-	if err := m.scanDao.UpdateScan(scan); err != nil {
+	if err := m.transitionScan(scan); err != nil {
 		return fmt.Errorf("persist scan completion: %w", err)
 	}
+	m.publish(scanID, StatusEvent{Status: models.ScanCompleted})
 
 	return nil
 }
 
 func (m *ScanStatusManager) MarkCompletedWithWarnings(scanID string, failedTools []tools.ToolError) error {
-	scan, err := m.scanDao.GetScanByUUID(scanID)
+	scan, err := m.scanDao.GetScanSummary(scanID)
 	if err != nil {
 		return fmt.Errorf("load scan: %w", err)
 	}
@@ -84,19 +305,33 @@ func (m *ScanStatusManager) MarkCompletedWithWarnings(scanID string, failedTools
 		return fmt.Errorf("scan %s not found", scanID)
 	}
 
-	scan.Status = "completed_with_warnings"
+	scan.Status = models.ScanCompletedWithWarnings
 
 	scan.FailedTools = make([]models.ToolFailure, 0, len(failedTools))
 	for _, tool := range failedTools {
-		scan.FailedTools = append(scan.FailedTools, models.ToolFailure{
+		failure := models.ToolFailure{
 			ToolName: tool.Tool,
 			Error:    tool.Err.Error(),
-		})
+		}
+		var stageErr *perrors.StageTimeoutError
+		if errors.As(tool.Err, &stageErr) {
+			failure.TimedOutStage = stageErr.Stage
+		}
+		var diagnosed *tools.DiagnosedError
+		if errors.As(tool.Err, &diagnosed) {
+			failure.Diagnostics = diagnosed.Diagnostics
+		}
+		scan.FailedTools = append(scan.FailedTools, failure)
 	}
 
-	if err := m.scanDao.UpdateScan(scan); err != nil {
+	if err := m.transitionScan(scan); err != nil {
 		return fmt.Errorf("persist scan completion with warnings: %w", err)
 	}
+	failed := make([]string, 0, len(scan.FailedTools))
+	for _, failure := range scan.FailedTools {
+		failed = append(failed, failure.ToolName)
+	}
+	m.publish(scanID, StatusEvent{Status: models.ScanCompletedWithWarnings, FailedTools: failed})
 
 	return nil
 }
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"testing"
+
+	"pipeliner/internal/models"
+	"pipeliner/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanStatusManager_EnforcesTransitions(t *testing.T) {
+	scanDao, _ := newTestDAOs(t)
+	m := newScanStatusManager(scanDao, logger.NewLogger(logrus.ErrorLevel), nil)
+	status := func(id string) models.ScanStatus {
+		scan, err := scanDao.GetScanSummary(id)
+		require.NoError(t, err)
+		return scan.Status
+	}
+
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "cancelled", Status: models.ScanCancelled}))
+	// the engine finishing after a cancel must not revive the scan
+	assert.ErrorIs(t, m.MarkCompleted("cancelled"), ErrStatusTransition)
+	m.MarkFailedWithReason("cancelled", "context canceled")
+	require.NoError(t, m.MarkRunning("cancelled"))
+	assert.Equal(t, models.ScanCancelled, status("cancelled"))
+
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "queued", Status: models.ScanQueued}))
+	require.NoError(t, m.MarkPaused("queued"))
+	assert.Equal(t, models.ScanQueued, status("queued"), "only running scans pause")
+	requeued, err := m.MarkRequeued("queued")
+	require.NoError(t, err)
+	assert.False(t, requeued)
+
+	require.NoError(t, m.MarkRunning("queued"))
+	require.NoError(t, m.MarkCompletedWithWarnings("queued", nil))
+	requeued, err = m.MarkRequeued("queued")
+	require.NoError(t, err)
+	assert.True(t, requeued)
+	assert.Equal(t, models.ScanQueued, status("queued"))
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/tools"
+	"sync"
+	"time"
+)
+
+// ScanSummaryFile is written into the scan directory as the scan runs, for
+// tooling that works on scan directories instead of the API. Its format is
+// published in docs/scan-summary.schema.json.
+const ScanSummaryFile = "summary.json"
+
+const scanSummaryVersion = 1
+
+// ScanSummary is the content of summary.json. It is rewritten whenever a
+// stage completes and once more when the scan ends, so an interrupted scan
+// leaves the snapshot of its last completed stage.
+type ScanSummary struct {
+	Version         int            `json:"version"`
+	ScanID          string         `json:"scan_id"`
+	Domain          string         `json:"domain"`
+	Module          string         `json:"module"`
+	Status          string         `json:"status"`
+	ErrorMessage    string         `json:"error_message,omitempty"`
+	StartedAt       time.Time      `json:"started_at"`
+	FinishedAt      *time.Time     `json:"finished_at,omitempty"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	CompletedStages []string       `json:"completed_stages"`
+	Tools           []ToolSummary  `json:"tools"`
+	Artifacts       []ArtifactFile `json:"artifacts"`
+	Subdomains      int            `json:"subdomains"`
+	// Findings counts the nuclei findings by severity.
+	Findings map[string]int `json:"findings"`
+}
+
+// Tool statuses in summary.json.
+const (
+	ToolRunning        = "running"
+	ToolCompleted      = "completed"
+	ToolCompletedEmpty = "completed_empty"
+	ToolFailed         = "failed"
+	ToolSkipped        = "skipped"
+)
+
+type ToolSummary struct {
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Nice is the niceness the tool ran at, when the scan's priority set
+	// one.
+	Nice *int `json:"nice,omitempty"`
+	// Resumed is set when the tool continued an interrupted run.
+	Resumed bool `json:"resumed,omitempty"`
+}
+
+// ArtifactFile is an output file a tool recorded in its manifest.
+type ArtifactFile struct {
+	Tool  string `json:"tool"`
+	Stage string `json:"stage,omitempty"`
+	Value string `json:"value,omitempty"`
+	// Name is relative to the scan directory.
+	Name string `json:"name"`
+}
+
+// summaryWriter keeps summary.json of one scan run up to date from the
+// tools' progress and the scan record.
+type summaryWriter struct {
+	scanDao dao.ScanDAO
+	logger  *logger.Logger
+	scanID  string
+
+	mu        sync.Mutex
+	dir       string
+	startedAt time.Time
+	stages    []string
+	tools     []*ToolSummary
+	now       func() time.Time
+}
+
+func newSummaryWriter(scanDao dao.ScanDAO, logger *logger.Logger, scanID string) *summaryWriter {
+	return &summaryWriter{scanDao: scanDao, logger: logger, scanID: scanID, startedAt: time.Now(), now: time.Now}
+}
+
+// setDir is where the summary goes, once the engine has made the directory.
+func (w *summaryWriter) setDir(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.dir = dir
+}
+
+func (w *summaryWriter) toolProgress(event tools.ProgressEvent) {
+	var status string
+	switch event.Status {
+	case "Started":
+		status = ToolRunning
+	case "Completed":
+		status = ToolCompleted
+	case tools.ProgressCompletedEmpty:
+		status = ToolCompletedEmpty
+	case "Failed":
+		status = ToolFailed
+	case tools.ProgressSkipped:
+		status = ToolSkipped
+	default:
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	tool := w.tool(event.Tool)
+	at := event.Timestamp
+	if status == ToolRunning {
+		tool.StartedAt = &at
+		tool.Nice = event.Nice
+		tool.Resumed = event.Resumed
+	} else {
+		tool.FinishedAt = &at
+	}
+	// CompletedEmpty is reported before the tool's own Completed
+	if !(status == ToolCompleted && tool.Status == ToolCompletedEmpty) {
+		tool.Status = status
+	}
+}
+
+func (w *summaryWriter) tool(name string) *ToolSummary {
+	for _, tool := range w.tools {
+		if tool.Name == name {
+			return tool
+		}
+	}
+	tool := &ToolSummary{Name: name}
+	w.tools = append(w.tools, tool)
+	return tool
+}
+
+func (w *summaryWriter) stageCompleted(stage tools.Stage) {
+	w.mu.Lock()
+	w.stages = append(w.stages, string(stage))
+	w.mu.Unlock()
+	w.write()
+}
+
+// write replaces summary.json in one rename, so readers never see half of
+// it. Failures are logged: the summary must not fail the scan.
+func (w *summaryWriter) write() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.dir == "" {
+		return
+	}
+
+	scan, err := w.scanDao.GetScanSummary(w.scanID)
+	if err != nil || scan == nil {
+		w.logger.Warn("Failed to load scan for its summary", logger.Fields{"scan_id": w.scanID, "error": err})
+		return
+	}
+
+	data, err := json.MarshalIndent(w.build(scan), "", "  ")
+	if err != nil {
+		w.logger.Warn("Failed to encode scan summary", logger.Fields{"scan_id": w.scanID, "error": err})
+		return
+	}
+	path := filepath.Join(w.dir, ScanSummaryFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		w.logger.Warn("Failed to write scan summary", logger.Fields{"scan_id": w.scanID, "error": err})
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		w.logger.Warn("Failed to write scan summary", logger.Fields{"scan_id": w.scanID, "error": err})
+	}
+}
+
+func (w *summaryWriter) build(scan *models.Scan) *ScanSummary {
+	now := w.now()
+	summary := &ScanSummary{
+		Version:         scanSummaryVersion,
+		ScanID:          scan.UUID,
+		Domain:          scan.Domain,
+		Module:          scan.ScanType,
+		Status:          string(scan.Status),
+		ErrorMessage:    scan.ErrorMessage,
+		StartedAt:       w.startedAt,
+		UpdatedAt:       now,
+		DurationSeconds: now.Sub(w.startedAt).Seconds(),
+		CompletedStages: append([]string{}, w.stages...),
+		Tools:           []ToolSummary{},
+		Artifacts:       []ArtifactFile{},
+		Subdomains:      scan.NumberOfDomains,
+		Findings:        scan.SeverityCounts,
+	}
+	if scan.Status.IsTerminal() {
+		summary.FinishedAt = &now
+	}
+	if summary.Findings == nil {
+		summary.Findings = map[string]int{}
+	}
+
+	errs := make(map[string]string, len(scan.FailedTools))
+	for _, failure := range scan.FailedTools {
+		errs[failure.ToolName] = failure.Error
+	}
+	artifacts := tools.NewArtifacts(w.dir)
+	for _, tool := range w.tools {
+		entry := *tool
+		entry.Error = errs[tool.Name]
+		summary.Tools = append(summary.Tools, entry)
+
+		files, err := artifacts.ListByTool(tool.Name)
+		if err != nil {
+			w.logger.Warn("Failed to read output manifest", logger.Fields{"scan_id": w.scanID, "tool": tool.Name, "error": err})
+			continue
+		}
+		for _, file := range files {
+			summary.Artifacts = append(summary.Artifacts, ArtifactFile{Tool: file.Tool, Stage: string(file.Stage), Value: file.Value, Name: file.Name})
+		}
+	}
+	return summary
+}
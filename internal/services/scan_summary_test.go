@@ -0,0 +1,110 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"pipeliner/internal/models"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/tools"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var summarySchema = sync.OnceValues(func() (*jsonschema.Schema, error) {
+	return jsonschema.NewCompiler().Compile("../../docs/scan-summary.schema.json")
+})
+
+// readSummary reads the summary.json of a scan directory, checking it
+// against the published schema.
+func readSummary(t *testing.T, dir string) (*ScanSummary, error) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, ScanSummaryFile))
+	if err != nil {
+		return nil, err
+	}
+	schema, err := summarySchema()
+	require.NoError(t, err)
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	require.NoError(t, err)
+	if err := schema.Validate(doc); err != nil {
+		t.Errorf("%s does not match docs/scan-summary.schema.json: %v", ScanSummaryFile, err)
+	}
+
+	var summary ScanSummary
+	require.NoError(t, json.Unmarshal(data, &summary))
+	return &summary, nil
+}
+
+func TestSummaryWriter(t *testing.T) {
+	scanDao, _ := newTestDAOs(t)
+	scan := &models.Scan{UUID: "scan-1", ScanType: "full", Domain: "example.com", Status: "running"}
+	require.NoError(t, scanDao.SaveScan(scan))
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "subfinder_output.txt"), nil, 0644))
+	require.NoError(t, tools.WriteOutputManifest(filepath.Join(dir, tools.OutputManifestFile("subfinder")),
+		&tools.OutputManifest{Tool: "subfinder", Stage: tools.StageSubdomain, Files: []string{"subfinder_output.txt"}}))
+
+	w := newSummaryWriter(scanDao, logger.NewLogger(logrus.ErrorLevel), "scan-1")
+	// nowhere to write yet
+	w.stageCompleted(tools.StageSubdomain)
+	_, err := readSummary(t, dir)
+	assert.True(t, os.IsNotExist(err))
+
+	w.setDir(dir)
+	now := time.Now()
+	w.toolProgress(tools.ProgressEvent{Tool: "subfinder", Status: "Started", Timestamp: now})
+	w.toolProgress(tools.ProgressEvent{Tool: "subfinder", Status: tools.ProgressCompletedEmpty, Timestamp: now})
+	w.toolProgress(tools.ProgressEvent{Tool: "subfinder", Status: "Completed", Timestamp: now})
+	nice := 5
+	w.toolProgress(tools.ProgressEvent{Tool: "httpx", Status: "Started", Timestamp: now, Nice: &nice, Resumed: true})
+	w.toolProgress(tools.ProgressEvent{Tool: "httpx", Status: "Running", Timestamp: now})
+	w.stageCompleted(tools.StageSubdomain)
+
+	summary, err := readSummary(t, dir)
+	require.NoError(t, err)
+	assert.Equal(t, "running", summary.Status)
+	assert.Nil(t, summary.FinishedAt)
+	assert.Equal(t, []string{string(tools.StageSubdomain), string(tools.StageSubdomain)}, summary.CompletedStages)
+	require.Len(t, summary.Tools, 2)
+	assert.Equal(t, ToolCompletedEmpty, summary.Tools[0].Status)
+	assert.Equal(t, ToolRunning, summary.Tools[1].Status)
+	assert.Nil(t, summary.Tools[0].Nice)
+	require.NotNil(t, summary.Tools[1].Nice)
+	assert.Equal(t, 5, *summary.Tools[1].Nice)
+	assert.False(t, summary.Tools[0].Resumed)
+	assert.True(t, summary.Tools[1].Resumed)
+	assert.Equal(t, []ArtifactFile{{Tool: "subfinder", Stage: string(tools.StageSubdomain), Name: "subfinder_output.txt"}}, summary.Artifacts)
+}
+
+func TestScanExecutor_WritesSummary(t *testing.T) {
+	var dir string
+	svc, scanDao, _ := newFakeEngineService(t, func(scanDir string) error {
+		dir = scanDir
+		return nil
+	})
+
+	id, err := svc.StartScan(&models.Scan{ScanType: "full", Domain: "example.com"})
+	require.NoError(t, err)
+	waitForFinished(t, scanDao, id)
+
+	// written after the final status, which waitForFinished may beat
+	var summary *ScanSummary
+	require.Eventually(t, func() bool {
+		summary, err = readSummary(t, dir)
+		return err == nil && summary.FinishedAt != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "completed", summary.Status)
+	assert.Equal(t, id, summary.ScanID)
+	assert.Equal(t, "example.com", summary.Domain)
+	assert.Equal(t, "full", summary.Module)
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"net/url"
+	"path"
+	"pipeliner/pkg/blobstore"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/parsers"
+	"regexp"
+	"strings"
+)
+
+// screenshotDirs are where the screenshot tools leave their images: the
+// scan directory, or the screenshots directory aquatone and gowitness
+// default to.
+var screenshotDirs = []string{"", "screenshots"}
+
+// screenshotReports are the reports that say which URL each screenshot is
+// of, in the order they are trusted.
+var screenshotReports = []struct {
+	file   string
+	parser parsers.CommandParser
+}{
+	{parsers.GowitnessReportFile, parsers.NewGowitnessParser()},
+	{parsers.AquatoneSessionFile, parsers.NewAquatoneParser()},
+}
+
+// screenshotScheme is the scheme the tools put in front of the host when
+// they name a screenshot after its URL, e.g. "https---" or "http__".
+var screenshotScheme = regexp.MustCompile(`^https?[-_]+`)
+
+// reportedScreenshots maps hosts to the screenshots, among paths, that the
+// screenshot tools' reports in store took of them.
+func (a *ArtifactProcessor) reportedScreenshots(store blobstore.Store, paths []string) map[string]string {
+	byName := make(map[string]string, len(paths))
+	for _, p := range paths {
+		byName[strings.ToLower(path.Base(p))] = p
+	}
+
+	reported := make(map[string]string)
+	for _, report := range screenshotReports {
+		file, cleanup, ok := a.localCopy(store, report.file)
+		if !ok {
+			continue
+		}
+		result, err := report.parser.Parse(file)
+		cleanup()
+		if err != nil {
+			a.logger.Warn("Ignoring unreadable screenshot report", logger.Fields{"error": err, "file": report.file})
+			continue
+		}
+		results, _ := result["results"].([]parsers.ScreenshotResult)
+		for _, r := range results {
+			screenshot, ok := byName[strings.ToLower(path.Base(r.Screenshot))]
+			if !ok {
+				continue
+			}
+			host := screenshotHost(r.URL)
+			if _, exists := reported[host]; !exists && host != "" {
+				reported[host] = screenshot
+			}
+		}
+	}
+	return reported
+}
+
+// screenshotHost is the host of a subdomain or URL, in lower case and
+// without scheme, port or path.
+func screenshotHost(value string) string {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+// screenshotMatchesHost reports whether a screenshot file is named after
+// host, as in "host.png", "https---host-443.jpeg" (gowitness) or
+// "http__host__1a2b3c4d.png" (aquatone). A longer host that only starts
+// with host, like host.backup.net, does not match.
+func screenshotMatchesHost(filename, host string) bool {
+	if host == "" {
+		return false
+	}
+	name := strings.ToLower(strings.TrimSuffix(filename, path.Ext(filename)))
+	for _, candidate := range []string{name, screenshotScheme.ReplaceAllString(name, "")} {
+		rest, ok := strings.CutPrefix(candidate, host)
+		if !ok {
+			continue
+		}
+		// what follows the host is a port or hash, never more of a hostname
+		if rest == "" || (strings.IndexAny(rest, "-_") == 0 && !strings.Contains(rest, ".")) {
+			return true
+		}
+	}
+	return false
+}
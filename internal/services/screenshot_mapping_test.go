@@ -0,0 +1,100 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pipeliner/internal/models"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/parsers"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeScreenshots(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("png"), 0644))
+	}
+}
+
+func screenshotsOf(scan *models.Scan) map[string]string {
+	got := make(map[string]string)
+	for _, s := range scan.Subdomains {
+		if s.Screenshot != "" {
+			got[s.Domain] = filepath.Base(s.Screenshot)
+		}
+	}
+	return got
+}
+
+func TestSaveScreenShotPaths_PrefixCollision(t *testing.T) {
+	scanDir := t.TempDir()
+	// the longer host sorts first, so a substring match gave it to both
+	writeScreenshots(t, scanDir,
+		"https---api.example.com.backup.net-443.jpeg",
+		"https---api.example.com-443.jpeg",
+		"http__www.example.com__da39a3ee5e6b4b0d.png",
+		"example.com.png",
+	)
+
+	scan := &models.Scan{UUID: "scan-1", Subdomains: []models.Subdomain{
+		{Domain: "api.example.com"},
+		{Domain: "https://api.example.com.backup.net"},
+		{Domain: "www.example.com"},
+		{Domain: "example.com"},
+		{Domain: "dev.example.com"},
+	}}
+	a := newArtifactProcessor(nil, nil, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), nil, nil)
+	require.NoError(t, a.saveScreenShotPaths(scan, a.scanStore(scanDir)))
+
+	assert.Equal(t, map[string]string{
+		"api.example.com":                    "https---api.example.com-443.jpeg",
+		"https://api.example.com.backup.net": "https---api.example.com.backup.net-443.jpeg",
+		"www.example.com":                    "http__www.example.com__da39a3ee5e6b4b0d.png",
+		"example.com":                        "example.com.png",
+	}, screenshotsOf(scan))
+}
+
+func TestSaveScreenShotPaths_Reports(t *testing.T) {
+	for _, report := range []string{parsers.GowitnessReportFile, parsers.AquatoneSessionFile} {
+		t.Run(report, func(t *testing.T) {
+			scanDir := t.TempDir()
+			data, err := os.ReadFile(filepath.Join("..", "..", "pkg", "parsers", "testdata", report))
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(filepath.Join(scanDir, report), data, 0644))
+			// aquatone's names replace the dots, so only its session says whose they are
+			writeScreenshots(t, scanDir,
+				"https---api.example.com.backup.net-443.jpeg",
+				"https---api.example.com-8443-login.jpeg",
+				"screenshots/http__www_example_com__da39a3ee5e6b4b0d.png",
+				"screenshots/https__api_example_com__4e1243bd22c66e76.png",
+			)
+
+			scan := &models.Scan{UUID: "scan-1", Subdomains: []models.Subdomain{
+				{Domain: "api.example.com"},
+				{Domain: "api.example.com.backup.net"},
+				{Domain: "www.example.com"},
+			}}
+			a := newArtifactProcessor(nil, nil, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), nil, nil)
+			require.NoError(t, a.saveScreenShotPaths(scan, a.scanStore(scanDir)))
+
+			want := map[string]map[string]string{
+				parsers.GowitnessReportFile: {
+					"api.example.com":            "https---api.example.com-8443-login.jpeg",
+					"api.example.com.backup.net": "https---api.example.com.backup.net-443.jpeg",
+				},
+				parsers.AquatoneSessionFile: {
+					"api.example.com":            "https__api_example_com__4e1243bd22c66e76.png",
+					"api.example.com.backup.net": "https---api.example.com.backup.net-443.jpeg",
+					"www.example.com":            "http__www_example_com__da39a3ee5e6b4b0d.png",
+				},
+			}[report]
+			assert.Equal(t, want, screenshotsOf(scan))
+		})
+	}
+}
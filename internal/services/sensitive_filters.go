@@ -0,0 +1,72 @@
+package services
+
+import (
+	"fmt"
+	"pipeliner/internal/models"
+	"pipeliner/pkg/parsers"
+	"sort"
+)
+
+// A host's soft-404 baseline needs a cluster of at least soft404MinCluster
+// similar lengths covering half of its results.
+const soft404MinCluster = 5
+
+// soft404Baseline is the median of the largest cluster of similar response
+// lengths among a host's ffuf results, which for a host answering unknown
+// paths with a custom 200 page is that page's length.
+func soft404Baseline(results []parsers.FuffResult) (int, bool) {
+	if len(results) < soft404MinCluster {
+		return 0, false
+	}
+	lengths := make([]int, 0, len(results))
+	for _, r := range results {
+		lengths = append(lengths, r.Length)
+	}
+	sort.Ints(lengths)
+
+	bestStart, bestSize := 0, 0
+	end := 0
+	for start := range lengths {
+		for end < len(lengths) && lengths[end]-lengths[start] <= lengthTolerance(lengths[start]) {
+			end++
+		}
+		if end-start > bestSize {
+			bestStart, bestSize = start, end-start
+		}
+	}
+	if bestSize < soft404MinCluster || bestSize*2 < len(lengths) {
+		return 0, false
+	}
+	return lengths[bestStart+bestSize/2], true
+}
+
+// lengthTolerance allows for error pages that echo the requested path.
+func lengthTolerance(length int) int {
+	return max(length/50, 16)
+}
+
+func similarLength(a, b int) bool {
+	d := a - b
+	if d < 0 {
+		d = -d
+	}
+	return d <= lengthTolerance(min(a, b))
+}
+
+// suppressReason names the filter that stops r from being alerted, or
+// returns "" when it should be.
+func suppressReason(filters models.SensitiveFilters, r parsers.FuffResult, baseline int, hasBaseline bool) string {
+	if filters.ExcludeRedirects && r.Status >= 300 && r.Status < 400 {
+		if r.RedirectLocation != "" {
+			return fmt.Sprintf("redirect: %d to %s", r.Status, r.RedirectLocation)
+		}
+		return fmt.Sprintf("redirect: %d", r.Status)
+	}
+	if filters.MinLength > 0 && r.Length < filters.MinLength {
+		return fmt.Sprintf("min_length: %d bytes, below %d", r.Length, filters.MinLength)
+	}
+	if filters.Soft404 && hasBaseline && similarLength(r.Length, baseline) {
+		return fmt.Sprintf("soft_404: %d bytes, baseline %d", r.Length, baseline)
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"pipeliner/internal/models"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/parsers"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSoft404Baseline(t *testing.T) {
+	var results []parsers.FuffResult
+	// a custom error page echoing the path varies by a few bytes
+	for i := 0; i < 8; i++ {
+		results = append(results, parsers.FuffResult{Length: 4210 + i})
+	}
+	results = append(results, parsers.FuffResult{Length: 120}, parsers.FuffResult{Length: 90000})
+
+	baseline, ok := soft404Baseline(results)
+	require.True(t, ok)
+	assert.InDelta(t, 4214, baseline, 1)
+
+	// distinct lengths form no cluster
+	_, ok = soft404Baseline([]parsers.FuffResult{{Length: 100}, {Length: 2000}, {Length: 5000}, {Length: 9000}, {Length: 30000}})
+	assert.False(t, ok)
+}
+
+func TestArtifactProcessor_SensitiveFilters(t *testing.T) {
+	results := []parsers.FuffResult{
+		{URL: "https://a.example.com/admin", Status: 302, Length: 0, RedirectLocation: "/login"},
+		{URL: "https://a.example.com/.env", Status: 200, Length: 12},
+		{URL: "https://a.example.com/backup.zip", Status: 200, Length: 4212},
+		{URL: "https://a.example.com/.git/config", Status: 200, Length: 250},
+	}
+	for i := 0; i < 6; i++ {
+		results = append(results, parsers.FuffResult{URL: fmt.Sprintf("https://a.example.com/page%d", i), Status: 200, Length: 4210 + i})
+	}
+
+	tests := []struct {
+		name       string
+		filters    models.SensitiveFilters
+		suppressed map[string]string
+	}{
+		{
+			name:       "no filters",
+			suppressed: map[string]string{},
+		},
+		{
+			name:    "all filters",
+			filters: models.SensitiveFilters{MinLength: 100, ExcludeRedirects: true, Soft404: true},
+			suppressed: map[string]string{
+				"https://a.example.com/admin":      "redirect: 302 to /login",
+				"https://a.example.com/.env":       "min_length: 12 bytes, below 100",
+				"https://a.example.com/backup.zip": "soft_404: 4212 bytes, baseline 4212",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan := &models.Scan{UUID: "scan-1", SensitiveFilters: tt.filters, Subdomains: []models.Subdomain{{Domain: "a.example.com"}}}
+			a := newArtifactProcessor(nil, nil, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), nil, nil)
+			a.addFfufResults(scan, 0, results, "")
+
+			findings := scan.Subdomains[0].Sensitive
+			require.Len(t, findings, 4)
+			for _, f := range findings {
+				assert.Equal(t, tt.suppressed[f.URL], f.Suppressed, f.URL)
+				assert.Equal(t, f.Suppressed == "", f.Alerted, f.URL)
+			}
+		})
+	}
+}
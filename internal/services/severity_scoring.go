@@ -0,0 +1,58 @@
+package services
+
+import (
+	"fmt"
+	"pipeliner/pkg/scoring"
+	"sync"
+)
+
+// WithSeverityRules scores the findings of every scan by rules, before the
+// severity_rules of the scan's module.
+func WithSeverityRules(rules []scoring.Rule) ScanServiceOption {
+	return func(s *scanService) {
+		s.severityRules = rules
+	}
+}
+
+// severityPolicies holds the severity policy of each running scan, which
+// the engine builds from the global rules and its module's. Scans without
+// one, e.g. of another process, get the global rules.
+type severityPolicies struct {
+	global *scoring.Policy
+	mu     sync.Mutex
+	scans  map[string]*scoring.Policy
+}
+
+func newSeverityPolicies(rules []scoring.Rule) (*severityPolicies, error) {
+	global, err := scoring.Compile(rules)
+	if err != nil {
+		return nil, fmt.Errorf("global severity rules: %w", err)
+	}
+	return &severityPolicies{global: global, scans: make(map[string]*scoring.Policy)}, nil
+}
+
+func (p *severityPolicies) set(scanID string, policy *scoring.Policy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.scans[scanID] = policy
+}
+
+func (p *severityPolicies) remove(scanID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.scans, scanID)
+}
+
+// forScan is nil-safe, so an ArtifactProcessor without policies leaves the
+// severities as they are.
+func (p *severityPolicies) forScan(scanID string) *scoring.Policy {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if policy, ok := p.scans[scanID]; ok {
+		return policy
+	}
+	return p.global
+}
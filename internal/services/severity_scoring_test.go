@@ -0,0 +1,91 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/parsers"
+	"pipeliner/pkg/scoring"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifactProcessor_NucleiSeverityRules(t *testing.T) {
+	scanDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scanDir, "nuclei_output.json"), []byte(
+		`{"template-id":"env-file","info":{"name":"Env File","severity":"critical","tags":["exposure","config"]},"host":"a.example.com","matched-at":"https://a.example.com/.env"}
+{"template-id":"panel","info":{"name":"Panel","severity":"medium","tags":["panel"]},"host":"staging.example.com","matched-at":"https://staging.example.com/admin"}
+`), 0644))
+
+	db := newTestDB(t)
+	findingDao := dao.NewFindingDAO(db)
+	a := newArtifactProcessor(nil, nil, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), nil, nil)
+	a.findingDao = findingDao
+	var err error
+	a.severity, err = newSeverityPolicies([]scoring.Rule{{Host: "staging.*", Adjust: -1, Labels: []string{"staging"}}})
+	require.NoError(t, err)
+	module, err := a.severity.global.With([]scoring.Rule{{Tags: []string{"exposure"}, Severity: "high", Labels: []string{"triaged"}}})
+	require.NoError(t, err)
+	a.severity.set("scan-1", module)
+
+	scan := &models.Scan{UUID: "scan-1", Subdomains: []models.Subdomain{{Domain: "a.example.com"}, {Domain: "staging.example.com"}}}
+	a.processNucleiOutput(scan, a.scanStore(scanDir))
+
+	assert.Equal(t, map[string]int{"high": 1, "low": 1}, scan.SeverityCounts)
+	assert.Equal(t, []string{"[HIGH] Env File - https://a.example.com/.env"}, scan.Subdomains[0].Vulns)
+	findings, err := findingDao.ListFindings("scan-1", models.FindingFilter{}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, findings, 2)
+	assert.Equal(t, "high", findings[0].Severity)
+	assert.Equal(t, "critical", findings[0].OriginalSeverity)
+	assert.Equal(t, []string{"triaged"}, findings[0].Labels)
+	assert.Equal(t, "low", findings[1].Severity)
+	assert.Equal(t, "medium", findings[1].OriginalSeverity)
+	assert.Equal(t, []string{"staging"}, findings[1].Labels)
+
+	// a scan without a policy of its own gets the global rules
+	a.severity.remove("scan-1")
+	scan = &models.Scan{UUID: "scan-2", Subdomains: []models.Subdomain{{Domain: "a.example.com"}}}
+	a.processNucleiOutput(scan, a.scanStore(scanDir))
+	findings, err = findingDao.ListFindings("scan-2", models.FindingFilter{}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, findings, 2)
+	assert.Equal(t, "critical", findings[0].Severity)
+	assert.Empty(t, findings[0].Labels)
+}
+
+func TestArtifactProcessor_SensitiveSeverityRules(t *testing.T) {
+	notifier := &recordingNotifier{}
+	a := newArtifactProcessor(nil, nil, logger.NewLogger(logrus.ErrorLevel), NewScanLocks(), notifier, nil)
+	var err error
+	a.severity, err = newSeverityPolicies([]scoring.Rule{{Tool: "ffuf", Category: "source code", Severity: "low", Labels: []string{"known"}}})
+	require.NoError(t, err)
+
+	scan := &models.Scan{UUID: "scan-1", Subdomains: []models.Subdomain{{Domain: "a.example.com"}}}
+	a.addFfufResults(scan, 0, []parsers.FuffResult{
+		{URL: "https://a.example.com/.git/config", Status: 200, Length: 120},
+		{URL: "https://a.example.com/.env", Status: 200, Length: 80},
+	}, "")
+
+	sensitive := scan.Subdomains[0].Sensitive
+	require.Len(t, sensitive, 2)
+	assert.Equal(t, "low", sensitive[0].Severity)
+	assert.Equal(t, "critical", sensitive[0].OriginalSeverity)
+	assert.Equal(t, []string{"known"}, sensitive[0].Labels)
+	assert.Equal(t, "critical", sensitive[1].Severity)
+	assert.Empty(t, sensitive[1].Labels)
+
+	require.Eventually(t, func() bool { return len(notifier.sent()) == 2 }, 3*time.Second, 10*time.Millisecond)
+	sent := notifier.sent()
+	assert.Equal(t, "low", sent[0].Severity)
+	assert.Equal(t, "known", sent[0].Fields["Labels"])
+	assert.Equal(t, "critical", sent[1].Severity)
+	assert.NotContains(t, sent[1].Fields, "Labels")
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"pipeliner/internal/models"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/parsers"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// livenessLookupWorkers bounds the DNS lookups of one reconciliation.
+	livenessLookupWorkers = 16
+	livenessLookupTimeout = 5 * time.Second
+)
+
+// probeResult is what an httpx run says about one subdomain.
+type probeResult int
+
+const (
+	// probeAnswered is a host in the httpx output.
+	probeAnswered probeResult = iota
+	// probeSilent is missing from the output but still resolves.
+	probeSilent
+	// probeUnresolved is missing from the output and does not resolve.
+	probeUnresolved
+)
+
+var probeStatuses = map[probeResult]models.SubdomainStatus{
+	probeAnswered:   models.SubdomainAlive,
+	probeSilent:     models.SubdomainUnresponsive,
+	probeUnresolved: models.SubdomainDead,
+}
+
+// nextSubdomainStatus is the status a subdomain in current moves to after a
+// probe, and whether it moves at all. A host that never answered stays
+// discovered when it is silent.
+func nextSubdomainStatus(current models.SubdomainStatus, result probeResult) (models.SubdomainStatus, bool) {
+	next := probeStatuses[result]
+	return next, current.CanTransition(next)
+}
+
+// applyProbes moves the scan's subdomains along results, which is keyed by
+// hostOf the domain. Answered hosts get their LastSeenAt set; others keep
+// theirs. The caller holds the scan lock.
+func (m *ScanMonitor) applyProbes(scanID string, results map[string]probeResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	subdomains, err := m.subdomainDao.ListByScan(scanID)
+	if err != nil {
+		return err
+	}
+
+	moves := make(map[models.SubdomainStatus][]string)
+	var seen []string
+	for _, s := range subdomains {
+		result, ok := results[hostOf(s.Domain)]
+		if !ok {
+			continue
+		}
+		if result == probeAnswered {
+			seen = append(seen, s.Domain)
+		}
+		if next, ok := nextSubdomainStatus(s.Status, result); ok {
+			moves[next] = append(moves[next], s.Domain)
+		}
+	}
+
+	for _, status := range models.SubdomainStatuses {
+		if _, err := m.subdomainDao.UpdateStatusFrom(scanID, moves[status], status, models.SubdomainStatusesInto(status)); err != nil {
+			return err
+		}
+	}
+	return m.subdomainDao.MarkSeen(scanID, seen, time.Now())
+}
+
+// recordHttpxJSON marks the hosts in httpx_output.json alive.
+func (m *ScanMonitor) recordHttpxJSON(scanID, scanDir string) {
+	hosts, ok := httpxJSONHosts(scanDir)
+	if !ok {
+		return
+	}
+
+	unlock := m.scanLocks.Lock(scanID)
+	defer unlock()
+
+	if err := m.applyProbes(scanID, answered(hosts)); err != nil {
+		m.logger.Error("Failed to record httpx results", logger.Fields{"error": err, "scan_id": scanID})
+	}
+}
+
+// reconcileLiveness compares the scan's subdomains with the finished httpx
+// output. Hosts an earlier run saw but this one did not are looked up:
+// those that no longer resolve are dead, the others unresponsive. Nothing
+// happens when httpx left no output.
+func (m *ScanMonitor) reconcileLiveness(ctx context.Context, scanID, scanDir string) {
+	hosts, ok := httpxTextHosts(scanDir)
+	jsonHosts, jsonOK := httpxJSONHosts(scanDir)
+	if !ok && !jsonOK {
+		return
+	}
+	for host := range jsonHosts {
+		hosts[host] = true
+	}
+
+	subdomains, err := m.subdomainDao.ListByScan(scanID)
+	if err != nil {
+		m.logger.Error("Failed to load subdomains for liveness check", logger.Fields{"error": err, "scan_id": scanID})
+		return
+	}
+	var missing []string
+	for _, s := range subdomains {
+		if host := hostOf(s.Domain); !hosts[host] && s.Status != models.SubdomainDead {
+			missing = append(missing, host)
+		}
+	}
+
+	results := answered(hosts)
+	for host, result := range m.resolveMissing(ctx, missing) {
+		results[host] = result
+	}
+
+	unlock := m.scanLocks.Lock(scanID)
+	defer unlock()
+
+	if err := m.applyProbes(scanID, results); err != nil {
+		m.logger.Error("Failed to update subdomain liveness", logger.Fields{"error": err, "scan_id": scanID})
+		return
+	}
+	m.logger.Info("Updated subdomain liveness", logger.Fields{"scan_id": scanID, "answered": len(hosts), "missing": len(missing)})
+}
+
+// resolveMissing looks up the hosts httpx did not answer for. Hosts whose
+// lookup fails for another reason than not existing are left out.
+func (m *ScanMonitor) resolveMissing(ctx context.Context, hosts []string) map[string]probeResult {
+	results := make(map[string]probeResult, len(hosts))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, livenessLookupWorkers)
+
+	for _, host := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			lookupCtx, cancel := context.WithTimeout(ctx, livenessLookupTimeout)
+			defer cancel()
+
+			result := probeSilent
+			if _, err := m.lookupHost(lookupCtx, hostname(host)); err != nil {
+				var dnsErr *net.DNSError
+				if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+					return
+				}
+				result = probeUnresolved
+			}
+			mu.Lock()
+			results[host] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func answered(hosts map[string]bool) map[string]probeResult {
+	results := make(map[string]probeResult, len(hosts))
+	for host := range hosts {
+		results[host] = probeAnswered
+	}
+	return results
+}
+
+// hostname strips the port and path hostOf leaves on a value.
+func hostname(host string) string {
+	host, _, _ = strings.Cut(host, "/")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// httpxTextHosts reads the hosts in httpx_output.txt; ok is false when the
+// file does not exist.
+func httpxTextHosts(scanDir string) (hosts map[string]bool, ok bool) {
+	content, err := os.ReadFile(filepath.Join(scanDir, "httpx_output.txt"))
+	if err != nil {
+		return map[string]bool{}, false
+	}
+	hosts = make(map[string]bool)
+	for _, line := range httpxLines(string(content)) {
+		hosts[hostOf(line)] = true
+	}
+	return hosts, true
+}
+
+// httpxJSONHosts reads the hosts in httpx_output.json by URL and input; ok
+// is false when the file does not exist or cannot be parsed.
+func httpxJSONHosts(scanDir string) (hosts map[string]bool, ok bool) {
+	httpxPath := filepath.Join(scanDir, "httpx_output.json")
+	if _, err := os.Stat(httpxPath); err != nil {
+		return nil, false
+	}
+	result, err := parsers.NewHttpxParser().Parse(httpxPath)
+	if err != nil {
+		return nil, false
+	}
+
+	results, _ := result["results"].([]parsers.HttpxResult)
+	hosts = make(map[string]bool, len(results))
+	for _, r := range results {
+		if r.URL != "" {
+			hosts[hostOf(r.URL)] = true
+		}
+		if r.Input != "" {
+			hosts[hostOf(r.Input)] = true
+		}
+	}
+	return hosts, true
+}
+
+// httpxLines returns the non-empty, non-comment lines of httpx text output.
+func httpxLines(content string) []string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			lines = append(lines, trimmed)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"pipeliner/internal/config"
+	"pipeliner/internal/models"
+	"pipeliner/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextSubdomainStatus(t *testing.T) {
+	tests := []struct {
+		current models.SubdomainStatus
+		result  probeResult
+		want    models.SubdomainStatus
+		moves   bool
+	}{
+		{models.SubdomainDiscovered, probeAnswered, models.SubdomainAlive, true},
+		{models.SubdomainDiscovered, probeSilent, models.SubdomainUnresponsive, false},
+		{models.SubdomainDiscovered, probeUnresolved, models.SubdomainDead, true},
+		{models.SubdomainAlive, probeAnswered, models.SubdomainAlive, false},
+		{models.SubdomainAlive, probeSilent, models.SubdomainUnresponsive, true},
+		{models.SubdomainAlive, probeUnresolved, models.SubdomainDead, true},
+		{models.SubdomainUnresponsive, probeAnswered, models.SubdomainAlive, true},
+		{models.SubdomainUnresponsive, probeSilent, models.SubdomainUnresponsive, false},
+		{models.SubdomainUnresponsive, probeUnresolved, models.SubdomainDead, true},
+		{models.SubdomainDead, probeAnswered, models.SubdomainAlive, true},
+		{models.SubdomainDead, probeSilent, models.SubdomainUnresponsive, false},
+		{models.SubdomainDead, probeUnresolved, models.SubdomainDead, false},
+	}
+
+	for _, tt := range tests {
+		next, moves := nextSubdomainStatus(tt.current, tt.result)
+		assert.Equal(t, tt.moves, moves, "%s after %d", tt.current, tt.result)
+		if moves {
+			assert.Equal(t, tt.want, next, "%s after %d", tt.current, tt.result)
+		}
+	}
+}
+
+func newLivenessMonitor(t *testing.T, subdomains []models.Subdomain) (*ScanMonitor, func() map[string]models.Subdomain) {
+	t.Helper()
+	scanDao, subdomainDao := newTestDAOs(t)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Status: models.ScanRunning, Subdomains: subdomains}))
+
+	log := logger.NewLogger(logrus.ErrorLevel)
+	m := newScanMonitor(subdomainDao, log, NewScanLocks(), nil, newScanStatusManager(scanDao, log, nil), config.DefaultMonitorConfig(), nil)
+
+	byDomain := func() map[string]models.Subdomain {
+		list, err := subdomainDao.ListByScan("scan-1")
+		require.NoError(t, err)
+		got := make(map[string]models.Subdomain, len(list))
+		for _, s := range list {
+			got[s.Domain] = s
+		}
+		return got
+	}
+	return m, byDomain
+}
+
+func TestScanMonitor_HttpxTextMarksAlive(t *testing.T) {
+	m, byDomain := newLivenessMonitor(t, []models.Subdomain{{Domain: "https://b.example.com", Status: models.SubdomainUnresponsive, LastSeenAt: 100}})
+
+	httpxPath := filepath.Join(t.TempDir(), "httpx_output.txt")
+	require.NoError(t, os.WriteFile(httpxPath, []byte("https://a.example.com\nhttps://b.example.com\n"), 0644))
+	var lastSize int64
+	m.processSubdomainUpdate("scan-1", httpxPath, &lastSize)
+
+	got := byDomain()
+	assert.Equal(t, models.SubdomainAlive, got["https://a.example.com"].Status)
+	assert.NotZero(t, got["https://a.example.com"].LastSeenAt)
+	// answering again brings an unresponsive host back
+	assert.Equal(t, models.SubdomainAlive, got["https://b.example.com"].Status)
+	assert.Greater(t, got["https://b.example.com"].LastSeenAt, int64(100))
+}
+
+func TestScanMonitor_HttpxJSONMarksAlive(t *testing.T) {
+	m, byDomain := newLivenessMonitor(t, []models.Subdomain{{Domain: "a.example.com"}, {Domain: "b.example.com"}})
+
+	scanDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scanDir, "httpx_output.json"), []byte(
+		`{"url":"http://a.example.com:8080","input":"a.example.com","status_code":200}
+`), 0644))
+	m.recordHttpxJSON("scan-1", scanDir)
+
+	got := byDomain()
+	assert.Equal(t, models.SubdomainAlive, got["a.example.com"].Status)
+	assert.Equal(t, models.SubdomainDiscovered, got["b.example.com"].Status)
+}
+
+func TestScanMonitor_ReconcileLiveness(t *testing.T) {
+	m, byDomain := newLivenessMonitor(t, []models.Subdomain{
+		{Domain: "https://up.example.com", Status: models.SubdomainAlive, LastSeenAt: 100},
+		{Domain: "https://quiet.example.com", Status: models.SubdomainAlive, LastSeenAt: 100},
+		{Domain: "https://gone.example.com", Status: models.SubdomainAlive, LastSeenAt: 100},
+		{Domain: "https://flaky.example.com", Status: models.SubdomainAlive, LastSeenAt: 100},
+		{Domain: "never.example.com", Status: models.SubdomainDiscovered},
+	})
+	var mu sync.Mutex
+	var looked []string
+	m.lookupHost = func(_ context.Context, host string) ([]string, error) {
+		mu.Lock()
+		looked = append(looked, host)
+		mu.Unlock()
+		switch host {
+		case "quiet.example.com", "never.example.com":
+			return []string{"192.0.2.1"}, nil
+		case "gone.example.com":
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return nil, errors.New("i/o timeout")
+	}
+
+	scanDir := t.TempDir()
+	// nothing to compare against before httpx wrote its output
+	m.reconcileLiveness(context.Background(), "scan-1", scanDir)
+	assert.Empty(t, looked)
+
+	require.NoError(t, os.WriteFile(filepath.Join(scanDir, "httpx_output.txt"), []byte("https://up.example.com\n"), 0644))
+	m.reconcileLiveness(context.Background(), "scan-1", scanDir)
+
+	got := byDomain()
+	assert.Equal(t, models.SubdomainAlive, got["https://up.example.com"].Status)
+	assert.Greater(t, got["https://up.example.com"].LastSeenAt, int64(100))
+	assert.Equal(t, models.SubdomainUnresponsive, got["https://quiet.example.com"].Status)
+	assert.Equal(t, int64(100), got["https://quiet.example.com"].LastSeenAt)
+	assert.Equal(t, models.SubdomainDead, got["https://gone.example.com"].Status)
+	assert.Equal(t, int64(100), got["https://gone.example.com"].LastSeenAt)
+	// a failed lookup is not a missing name
+	assert.Equal(t, models.SubdomainAlive, got["https://flaky.example.com"].Status)
+	// never answered, so not unresponsive either
+	assert.Equal(t, models.SubdomainDiscovered, got["never.example.com"].Status)
+}
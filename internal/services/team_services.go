@@ -0,0 +1,172 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTeamNotFound  = errors.New("team not found")
+	ErrInvalidToken  = errors.New("invalid API token")
+	ErrTokenRevoked  = errors.New("API token has been revoked")
+	ErrTokenNotFound = errors.New("API token not found")
+)
+
+// WithDefaultTeam assigns scans started without a team, e.g. while API
+// tokens are not required, to the given team.
+func WithDefaultTeam(teamID uint) ScanServiceOption {
+	return func(s *scanService) {
+		s.team = teamID
+	}
+}
+
+// ForTeam returns the service as seen by a member of the team: scans it
+// starts belong to the team, and other teams' scans are not found. An
+// admin sees every team's scans.
+func (s *scanService) ForTeam(teamID uint, admin bool) ScanServiceMethods {
+	scoped := *s
+	scoped.team = teamID
+	if !admin {
+		scoped.scanDao = s.scanDao.ForTeam(teamID)
+	}
+	return &scoped
+}
+
+type TeamServiceMethods interface {
+	CreateTeam(name string) (*models.Team, error)
+	ListTeams() ([]models.Team, error)
+	// CreateToken returns a new token of the team and its record; only the
+	// record is stored, so the token cannot be shown again.
+	CreateToken(team, name string, admin bool) (string, *models.APIToken, error)
+	Authenticate(token string) (*models.APIToken, error)
+	// HasTokens reports whether any token was ever created. Revoking
+	// tokens does not change it, so revoking the last one does not open
+	// the server.
+	HasTokens() (bool, error)
+	// ListTokens lists the tokens of a team, or of every team for "".
+	ListTokens(team string) ([]models.APIToken, error)
+	RevokeToken(id uint) error
+}
+
+type teamService struct {
+	teamDao dao.TeamDAO
+	// created is set once a token is seen; tokens are never deleted, so it
+	// stays set
+	created atomic.Bool
+}
+
+func NewTeamService(teamDao dao.TeamDAO) TeamServiceMethods {
+	return &teamService{teamDao: teamDao}
+}
+
+func (s *teamService) CreateTeam(name string) (*models.Team, error) {
+	team := &models.Team{Name: strings.TrimSpace(name)}
+	if team.Name == "" {
+		return nil, errors.New("team name is required")
+	}
+	if err := s.teamDao.CreateTeam(team); err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+func (s *teamService) ListTeams() ([]models.Team, error) {
+	return s.teamDao.ListTeams()
+}
+
+func (s *teamService) CreateToken(team, name string, admin bool) (string, *models.APIToken, error) {
+	owner, err := s.teamDao.GetTeamByName(team)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil, ErrTeamNotFound
+		}
+		return "", nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(secret)
+	record := &models.APIToken{TeamID: owner.ID, Name: name, TokenHash: hashToken(token), Admin: admin}
+	if err := s.teamDao.CreateToken(record); err != nil {
+		return "", nil, err
+	}
+	return token, record, nil
+}
+
+// Authenticate returns the record of a token made by CreateToken, or
+// ErrInvalidToken, or ErrTokenRevoked once the token is revoked.
+//
+// Hashing before the lookup is the timing defense: the lookup's timing can
+// at most tell a caller how much of its token's SHA-256 hash matches a
+// stored one, and it cannot choose tokens to get closer to one.
+func (s *teamService) Authenticate(token string) (*models.APIToken, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+	record, err := s.teamDao.GetTokenByHash(hashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if record.RevokedAt != nil {
+		return nil, ErrTokenRevoked
+	}
+	return record, nil
+}
+
+func (s *teamService) HasTokens() (bool, error) {
+	if s.created.Load() {
+		return true, nil
+	}
+	count, err := s.teamDao.CountTokens()
+	if err != nil {
+		return false, err
+	}
+	if count > 0 {
+		s.created.Store(true)
+	}
+	return count > 0, nil
+}
+
+func (s *teamService) ListTokens(team string) ([]models.APIToken, error) {
+	var teamID uint
+	if team != "" {
+		owner, err := s.teamDao.GetTeamByName(team)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrTeamNotFound
+			}
+			return nil, err
+		}
+		teamID = owner.ID
+	}
+	return s.teamDao.ListTokens(teamID)
+}
+
+func (s *teamService) RevokeToken(id uint) error {
+	if err := s.teamDao.RevokeToken(id, time.Now()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTokenNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
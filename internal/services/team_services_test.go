@@ -0,0 +1,111 @@
+package services
+
+import (
+	"testing"
+
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+	"pipeliner/pkg/engine"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/queue"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanService_ForTeam(t *testing.T) {
+	scanDao, subdomainDao := newTestDAOs(t)
+	factory := func(...engine.OptFunc) (ScanEngine, error) {
+		return &fakeEngine{scanDir: t.TempDir(), logger: logger.NewLogger(logrus.ErrorLevel)}, nil
+	}
+	svc := NewScanService(scanDao, subdomainDao, WithQueue(queue.New(1)), WithEngineFactory(factory), WithDefaultTeam(1))
+
+	id, err := svc.StartScan(&models.Scan{ScanType: "full", Domain: "example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), waitForFinished(t, scanDao, id).TeamID)
+
+	teamTwo := svc.ForTeam(2, false)
+	_, err = teamTwo.GetScanSummary(id)
+	assert.ErrorIs(t, err, ErrScanNotFound)
+	_, err = teamTwo.RerunScan(id)
+	assert.ErrorIs(t, err, ErrScanNotFound)
+
+	own, err := teamTwo.StartScan(&models.Scan{ScanType: "full", Domain: "example.org"})
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), waitForFinished(t, scanDao, own).TeamID)
+	scans, total, err := teamTwo.ListScansFiltered(models.ScanFilter{}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, own, scans[0].UUID)
+
+	// an admin of team 2 sees team 1's scan, and its rerun stays team 1's
+	rerun, err := svc.ForTeam(2, true).RerunScan(id)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), waitForFinished(t, scanDao, rerun).TeamID)
+}
+
+func TestTeamService_Tokens(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, db.AutoMigrate(&models.Team{}, &models.APIToken{}))
+	teams := NewTeamService(dao.NewTeamDAO(db))
+
+	_, _, err := teams.CreateToken("red", "ci", false)
+	assert.ErrorIs(t, err, ErrTeamNotFound)
+
+	team, err := teams.CreateTeam("red")
+	require.NoError(t, err)
+	token, record, err := teams.CreateToken("red", "ci", true)
+	require.NoError(t, err)
+	assert.NotEqual(t, token, record.TokenHash)
+
+	got, err := teams.Authenticate(token)
+	require.NoError(t, err)
+	assert.Equal(t, team.ID, got.TeamID)
+	assert.True(t, got.Admin)
+
+	for _, bad := range []string{"", token + "x"} {
+		_, err := teams.Authenticate(bad)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	}
+}
+
+func TestTeamService_RevokeToken(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, db.AutoMigrate(&models.Team{}, &models.APIToken{}))
+	teams := NewTeamService(dao.NewTeamDAO(db))
+
+	for _, name := range []string{"red", "blue"} {
+		_, err := teams.CreateTeam(name)
+		require.NoError(t, err)
+	}
+	token, record, err := teams.CreateToken("red", "ci", false)
+	require.NoError(t, err)
+	_, _, err = teams.CreateToken("blue", "ci", false)
+	require.NoError(t, err)
+
+	red, err := teams.ListTokens("red")
+	require.NoError(t, err)
+	require.Len(t, red, 1)
+	assert.Equal(t, record.ID, red[0].ID)
+	all, err := teams.ListTokens("")
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+	_, err = teams.ListTokens("green")
+	assert.ErrorIs(t, err, ErrTeamNotFound)
+
+	require.NoError(t, teams.RevokeToken(record.ID))
+	_, err = teams.Authenticate(token)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+	// revoking again keeps the first time
+	red, err = teams.ListTokens("red")
+	require.NoError(t, err)
+	require.NotNil(t, red[0].RevokedAt)
+	revokedAt := *red[0].RevokedAt
+	require.NoError(t, teams.RevokeToken(record.ID))
+	red, err = teams.ListTokens("red")
+	require.NoError(t, err)
+	assert.True(t, revokedAt.Equal(*red[0].RevokedAt))
+
+	assert.ErrorIs(t, teams.RevokeToken(999), ErrTokenNotFound)
+}
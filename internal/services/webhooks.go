@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+	"pipeliner/internal/notification"
+	"pipeliner/pkg/logger"
+	"slices"
+	"time"
+)
+
+const (
+	webhookMaxAttempts = 5
+	webhookTimeout     = 10 * time.Second
+)
+
+// webhookBackoff is the wait before the second attempt; it doubles after
+// each failure.
+var webhookBackoff = 2 * time.Second
+
+// webhookPayload is the JSON body posted for every event.
+type webhookPayload struct {
+	Event   string          `json:"event"`
+	ScanID  string          `json:"scan_id"`
+	SentAt  int64           `json:"sent_at"`
+	Scan    *models.Scan    `json:"scan,omitempty"`
+	Finding *webhookFinding `json:"finding,omitempty"`
+}
+
+type webhookFinding struct {
+	Host      string `json:"host"`
+	Template  string `json:"template"`
+	Severity  string `json:"severity"`
+	MatchedAt string `json:"matched_at"`
+}
+
+// webhookDispatcher posts scan events to the scan's webhooks and the global
+// defaults, recording every delivery so failed ones can be inspected and
+// sent again.
+type webhookDispatcher struct {
+	scanDao  dao.ScanDAO
+	logger   *logger.Logger
+	client   *http.Client
+	defaults []models.ScanWebhook
+}
+
+func newWebhookDispatcher(scanDao dao.ScanDAO, logger *logger.Logger, defaults []models.ScanWebhook) *webhookDispatcher {
+	return &webhookDispatcher{
+		scanDao:  scanDao,
+		logger:   logger,
+		client:   &http.Client{Timeout: webhookTimeout},
+		defaults: defaults,
+	}
+}
+
+// scanFinished sends completed or failed once the scan reached its final
+// status. Cancelled scans are not reported.
+func (d *webhookDispatcher) scanFinished(scanID string) {
+	scan, err := d.scanDao.GetScanByUUID(scanID)
+	if err != nil {
+		d.logger.Error("Failed to load scan for webhooks", logger.Fields{"scan_id": scanID, "error": err})
+		return
+	}
+
+	var event string
+	switch scan.Status {
+	case models.ScanCompleted, models.ScanCompletedWithWarnings:
+		event = models.WebhookEventCompleted
+	case models.ScanFailed:
+		event = models.WebhookEventFailed
+	default:
+		return
+	}
+	d.notify(scanID, webhookPayload{Event: event, ScanID: scanID, Scan: scan})
+}
+
+// criticalFinding sends finding.critical for a newly recorded finding.
+func (d *webhookDispatcher) criticalFinding(scanID string, finding webhookFinding) {
+	d.notify(scanID, webhookPayload{Event: models.WebhookEventCriticalFinding, ScanID: scanID, Finding: &finding})
+}
+
+func (d *webhookDispatcher) notify(scanID string, payload webhookPayload) {
+	targets, err := d.targets(scanID)
+	if err != nil {
+		d.logger.Error("Failed to load scan webhooks", logger.Fields{"scan_id": scanID, "error": err})
+		return
+	}
+
+	payload.SentAt = time.Now().Unix()
+	var body []byte
+	for _, target := range targets {
+		if !target.Wants(payload.Event) {
+			continue
+		}
+		if body == nil {
+			if body, err = json.Marshal(payload); err != nil {
+				d.logger.Error("Failed to encode webhook payload", logger.Fields{"scan_id": scanID, "error": err})
+				return
+			}
+		}
+
+		delivery := &models.WebhookDelivery{
+			ScanID:    scanID,
+			WebhookID: target.ID,
+			URL:       target.URL,
+			Event:     payload.Event,
+			Payload:   string(body),
+			Status:    "pending",
+		}
+		if err := d.scanDao.SaveWebhookDelivery(delivery); err != nil {
+			d.logger.Error("Failed to record webhook delivery", logger.Fields{"scan_id": scanID, "url": target.URL, "error": err})
+			continue
+		}
+		go d.deliver(delivery, target.Secret)
+	}
+}
+
+// redeliver sends a recorded delivery again as a new delivery.
+func (d *webhookDispatcher) redeliver(scanID string, deliveryID uint) (*models.WebhookDelivery, error) {
+	previous, err := d.scanDao.GetWebhookDelivery(scanID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := d.targets(scanID)
+	if err != nil {
+		return nil, err
+	}
+	var target *models.ScanWebhook
+	for i := range targets {
+		if targets[i].ID == previous.WebhookID && targets[i].URL == previous.URL {
+			target = &targets[i]
+			break
+		}
+	}
+	if target == nil {
+		return nil, ErrWebhookNotFound
+	}
+
+	delivery := &models.WebhookDelivery{
+		ScanID:       scanID,
+		WebhookID:    target.ID,
+		URL:          target.URL,
+		Event:        previous.Event,
+		Payload:      previous.Payload,
+		Status:       "pending",
+		RedeliveryOf: previous.ID,
+	}
+	if err := d.scanDao.SaveWebhookDelivery(delivery); err != nil {
+		return nil, err
+	}
+	go d.deliver(delivery, target.Secret)
+	return delivery, nil
+}
+
+// targets are the scan's own webhooks followed by the global defaults, which
+// carry ID zero.
+func (d *webhookDispatcher) targets(scanID string) ([]models.ScanWebhook, error) {
+	webhooks, err := d.scanDao.ListWebhooks(scanID)
+	if err != nil {
+		return nil, err
+	}
+	return append(webhooks, d.defaults...), nil
+}
+
+// deliver posts the payload until it is accepted or the attempts run out,
+// saving the delivery after every attempt.
+func (d *webhookDispatcher) deliver(delivery *models.WebhookDelivery, secret string) {
+	backoff := webhookBackoff
+	for {
+		delivery.Attempts++
+		code, err := notification.PostWebhook(context.Background(), d.client, delivery.URL, secret, delivery.Event, delivery.ID, []byte(delivery.Payload))
+		delivery.StatusCode = code
+		delivery.Error = ""
+		switch {
+		case err == nil:
+			delivery.Status = "delivered"
+		case delivery.Attempts >= webhookMaxAttempts || !retryable(code):
+			delivery.Status = "failed"
+			delivery.Error = err.Error()
+		default:
+			delivery.Error = err.Error()
+		}
+
+		if saveErr := d.scanDao.SaveWebhookDelivery(delivery); saveErr != nil {
+			d.logger.Error("Failed to record webhook attempt", logger.Fields{"scan_id": delivery.ScanID, "delivery_id": delivery.ID, "error": saveErr})
+		}
+		if delivery.Status != "pending" {
+			if delivery.Status == "failed" {
+				d.logger.Warn("Webhook delivery failed", logger.Fields{
+					"scan_id":     delivery.ScanID,
+					"delivery_id": delivery.ID,
+					"url":         delivery.URL,
+					"attempts":    delivery.Attempts,
+					"error":       delivery.Error,
+				})
+			}
+			return
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// retryable reports whether a failed attempt is worth repeating: network
+// errors, rate limiting and server errors are, other client errors are not.
+func retryable(code int) bool {
+	return code == 0 || code == http.StatusTooManyRequests || code >= 500
+}
+
+// validateWebhookEvents rejects event filters naming unknown events.
+func validateWebhookEvents(events []string) error {
+	for _, event := range events {
+		if !slices.Contains(models.WebhookEvents, event) {
+			return fmt.Errorf("%w: %q", ErrUnknownWebhookEvent, event)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pipeliner/internal/models"
+	"pipeliner/internal/notification"
+	"pipeliner/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookDispatcher_RetriesSignsAndRedelivers(t *testing.T) {
+	previous := webhookBackoff
+	webhookBackoff = time.Millisecond
+	t.Cleanup(func() { webhookBackoff = previous })
+
+	var calls atomic.Int32
+	var signatureOK atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signatureOK.Store(r.Header.Get(notification.SignatureHeader) == notification.SignPayload("s3cret", body))
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	scanDao := newTestScanDAO(t)
+	require.NoError(t, scanDao.SaveScan(&models.Scan{UUID: "scan-1", Status: "completed"}))
+	require.NoError(t, scanDao.SaveWebhooks([]models.ScanWebhook{
+		{ScanID: "scan-1", URL: server.URL, Secret: "s3cret", Events: []string{models.WebhookEventCompleted}},
+		{ScanID: "scan-1", URL: server.URL, Events: []string{models.WebhookEventFailed}},
+	}))
+
+	d := newWebhookDispatcher(scanDao, logger.NewLogger(logrus.ErrorLevel), nil)
+	d.scanFinished("scan-1")
+
+	delivered := func(want int) []models.WebhookDelivery {
+		var deliveries []models.WebhookDelivery
+		require.Eventually(t, func() bool {
+			var err error
+			deliveries, err = scanDao.ListWebhookDeliveries("scan-1")
+			require.NoError(t, err)
+			done := 0
+			for _, delivery := range deliveries {
+				if delivery.Status == "delivered" {
+					done++
+				}
+			}
+			return done == want
+		}, 2*time.Second, 5*time.Millisecond)
+		return deliveries
+	}
+
+	deliveries := delivered(1)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, models.WebhookEventCompleted, deliveries[0].Event)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusNoContent, deliveries[0].StatusCode)
+	assert.Contains(t, deliveries[0].Payload, `"uuid":"scan-1"`)
+	assert.True(t, signatureOK.Load())
+
+	again, err := d.redeliver("scan-1", deliveries[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, deliveries[0].ID, again.RedeliveryOf)
+	deliveries = delivered(2)
+	assert.Equal(t, deliveries[1].Payload, deliveries[0].Payload)
+}
@@ -0,0 +1,182 @@
+package state
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"pipeliner/internal/dao"
+)
+
+// ExportOptions say where the files of the instance being exported are.
+type ExportOptions struct {
+	ScanRoot   string
+	ModulesDir string
+	// SkipScreenshots leaves the screenshots out of the scan directories,
+	// usually most of their size.
+	SkipScreenshots bool
+}
+
+// Export writes the instance's tables, scan directories and modules to an
+// archive at file, followed by a manifest with their checksums.
+func Export(states dao.StateDAO, file string, opts ExportOptions) (manifest *Manifest, err error) {
+	scanRoot, err := filepath.Abs(opts.ScanRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := createArchive(file)
+	if err != nil {
+		return nil, fmt.Errorf("create %s: %w", file, err)
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("write %s: %w", file, closeErr)
+		}
+		if err != nil {
+			os.Remove(file)
+		}
+	}()
+
+	w := &archiveWriter{tar: tar.NewWriter(out)}
+	manifest = &Manifest{
+		SchemaVersion:      SchemaVersion,
+		CreatedAt:          time.Now().UTC(),
+		ScanRoot:           scanRoot,
+		Tables:             make(map[string]int64),
+		ScreenshotsSkipped: opts.SkipScreenshots,
+	}
+
+	for _, table := range states.Tables() {
+		rows, err := w.addTable(states, table)
+		if err != nil {
+			return nil, fmt.Errorf("export table %s: %w", table, err)
+		}
+		manifest.Tables[table] = rows
+	}
+
+	var skip func(string) bool
+	if opts.SkipScreenshots {
+		skip = isScreenshot
+	}
+	if err := w.addDir(scanRoot, scansDir, skip); err != nil {
+		return nil, fmt.Errorf("export scan directories: %w", err)
+	}
+	if err := w.addDir(opts.ModulesDir, modulesDir, nil); err != nil {
+		return nil, fmt.Errorf("export modules: %w", err)
+	}
+
+	manifest.Files = w.files
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := w.write(manifestName, int64(len(data)), time.Now(), bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	if err := w.tar.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// archiveWriter adds entries to the archive, keeping their checksums for
+// the manifest.
+type archiveWriter struct {
+	tar   *tar.Writer
+	files []File
+}
+
+func (w *archiveWriter) write(name string, size int64, modTime time.Time, r io.Reader) error {
+	header := &tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	}
+	if err := w.tar.WriteHeader(header); err != nil {
+		return fmt.Errorf("add %s: %w", name, err)
+	}
+	if _, err := io.Copy(w.tar, r); err != nil {
+		return fmt.Errorf("add %s: %w", name, err)
+	}
+	return nil
+}
+
+// addFile adds the file at src as name.
+func (w *archiveWriter) addFile(name, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// a file still being written is taken as it was when opened
+	sum := sha256.New()
+	if err := w.write(name, info.Size(), info.ModTime(), io.TeeReader(io.LimitReader(f, info.Size()), sum)); err != nil {
+		return err
+	}
+	w.files = append(w.files, File{Path: name, Size: info.Size(), SHA256: hex.EncodeToString(sum.Sum(nil))})
+	return nil
+}
+
+// addTable adds the rows of table as JSON lines and returns how many there
+// were. tar needs the size up front, so they go through a temporary file.
+func (w *archiveWriter) addTable(states dao.StateDAO, table string) (int64, error) {
+	tmp, err := os.CreateTemp("", "pipeliner-"+table+"-*.jsonl")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var rows int64
+	enc := json.NewEncoder(tmp)
+	err = states.ExportTable(table, func(row dao.StateRow) error {
+		rows++
+		return enc.Encode(row)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	return rows, w.addFile(path.Join(tablesDir, table+".jsonl"), tmp.Name())
+}
+
+// addDir adds the regular files under root that skip, if set, keeps, at
+// the same place under prefix. A missing root adds nothing.
+func (w *archiveWriter) addDir(root, prefix string, skip func(name string) bool) error {
+	if _, err := os.Stat(root); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() || (skip != nil && skip(entry.Name())) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		return w.addFile(path.Join(prefix, filepath.ToSlash(rel)), file)
+	})
+}
@@ -0,0 +1,304 @@
+package state
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"pipeliner/internal/dao"
+)
+
+var (
+	// ErrNotFresh is returned when the instance being imported into already
+	// has scans, which the import would mix with the archive's.
+	ErrNotFresh = errors.New("instance is not empty")
+	// ErrIncompatibleSchema is returned for an archive whose tables this
+	// version cannot read.
+	ErrIncompatibleSchema = errors.New("incompatible schema version")
+	// ErrCorruptArchive is returned when the archive does not match its
+	// manifest.
+	ErrCorruptArchive = errors.New("archive does not match its manifest")
+)
+
+// scansTable holds the scan directories moved to the new scan root.
+const scansTable = "scans"
+
+// ImportOptions say where the files of the instance being restored go.
+type ImportOptions struct {
+	ScanRoot string
+	// ModulesDir receives the archive's modules; empty leaves them out.
+	ModulesDir string
+}
+
+// Import restores an archive written by Export into an empty instance. The
+// archive is unpacked and checked against its manifest first; the tables
+// are restored in one transaction, and the scan directories and modules
+// moved into place after it commits. Scan directories recorded under the
+// old scan root are recorded under the new one.
+func Import(states dao.StateDAO, file string, opts ImportOptions) (*Manifest, error) {
+	scanRoot, err := filepath.Abs(opts.ScanRoot)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFresh(states, scanRoot); err != nil {
+		return nil, err
+	}
+
+	// next to the scan root, so the scan directories are renamed, not copied
+	if err := os.MkdirAll(filepath.Dir(scanRoot), 0755); err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(scanRoot), ".pipeliner-import-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, err := unpack(file, staging)
+	if err != nil {
+		return nil, err
+	}
+
+	err = states.Transaction(func(tx dao.StateDAO) error {
+		return restoreTables(tx, staging, manifest, scanRoot)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := moveScans(filepath.Join(staging, scansDir), scanRoot); err != nil {
+		return nil, fmt.Errorf("restore scan directories: %w", err)
+	}
+	if err := copyModules(filepath.Join(staging, modulesDir), opts.ModulesDir); err != nil {
+		return nil, fmt.Errorf("restore modules: %w", err)
+	}
+	return manifest, nil
+}
+
+func checkFresh(states dao.StateDAO, scanRoot string) error {
+	for _, table := range states.Tables() {
+		rows, err := states.CountRows(table)
+		if err != nil {
+			return err
+		}
+		if rows > 0 {
+			return fmt.Errorf("%w: table %s has %d rows", ErrNotFresh, table, rows)
+		}
+	}
+
+	entries, err := os.ReadDir(scanRoot)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("%w: %s is not empty", ErrNotFresh, scanRoot)
+	}
+	return nil
+}
+
+// unpack extracts the archive into dir and checks every entry against the
+// manifest.
+func unpack(file, dir string) (*Manifest, error) {
+	in, err := openArchive(file)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", file, err)
+	}
+	defer in.Close()
+
+	var manifest *Manifest
+	sums := make(map[string]File)
+	r := tar.NewReader(in)
+	for {
+		header, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", file, err)
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		name := path.Clean(header.Name)
+		if header.Typeflag != tar.TypeReg || !fs.ValidPath(name) {
+			return nil, fmt.Errorf("%w: unexpected entry %q", ErrCorruptArchive, header.Name)
+		}
+
+		if name == manifestName {
+			manifest = &Manifest{}
+			if err := json.NewDecoder(r).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("%w: read manifest: %v", ErrCorruptArchive, err)
+			}
+			continue
+		}
+
+		sum, err := extract(r, filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, fmt.Errorf("extract %s: %w", name, err)
+		}
+		sums[name] = sum
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("%w: no %s, not a pipeliner state export", ErrCorruptArchive, manifestName)
+	}
+	if manifest.SchemaVersion != SchemaVersion {
+		return nil, fmt.Errorf("%w: the archive has version %d, this pipeliner reads %d", ErrIncompatibleSchema, manifest.SchemaVersion, SchemaVersion)
+	}
+	for _, want := range manifest.Files {
+		got, ok := sums[want.Path]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is missing", ErrCorruptArchive, want.Path)
+		}
+		if got.Size != want.Size || got.SHA256 != want.SHA256 {
+			return nil, fmt.Errorf("%w: checksum of %s", ErrCorruptArchive, want.Path)
+		}
+		delete(sums, want.Path)
+	}
+	for name := range sums {
+		return nil, fmt.Errorf("%w: %s is not in the manifest", ErrCorruptArchive, name)
+	}
+	return manifest, nil
+}
+
+func extract(r io.Reader, file string) (File, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return File{}, err
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return File{}, err
+	}
+	defer f.Close()
+
+	sum := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, sum), r)
+	if err != nil {
+		return File{}, err
+	}
+	return File{Size: size, SHA256: hex.EncodeToString(sum.Sum(nil))}, f.Close()
+}
+
+func restoreTables(states dao.StateDAO, dir string, manifest *Manifest, scanRoot string) error {
+	known := make(map[string]bool)
+	for _, table := range states.Tables() {
+		known[table] = true
+	}
+	for table := range manifest.Tables {
+		if !known[table] {
+			return fmt.Errorf("%w: unknown table %s", ErrIncompatibleSchema, table)
+		}
+	}
+
+	for _, table := range states.Tables() {
+		if _, ok := manifest.Tables[table]; !ok {
+			continue
+		}
+		if err := restoreTable(states, dir, table, manifest.ScanRoot, scanRoot); err != nil {
+			return fmt.Errorf("import table %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func restoreTable(states dao.StateDAO, dir, table, oldRoot, newRoot string) error {
+	f, err := os.Open(filepath.Join(dir, tablesDir, table+".jsonl"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	return states.ImportTable(table, func() (dao.StateRow, error) {
+		var row dao.StateRow
+		if err := dec.Decode(&row); err != nil {
+			return nil, err
+		}
+		if table == scansTable {
+			if err := remapScanDir(row, oldRoot, newRoot); err != nil {
+				return nil, err
+			}
+		}
+		return row, nil
+	})
+}
+
+// remapScanDir moves a scan's directory from the old scan root to the same
+// place under the new one. A directory outside the old root, which the
+// archive does not have, keeps its name.
+func remapScanDir(row dao.StateRow, oldRoot, newRoot string) error {
+	var dir string
+	if raw, ok := row["scan_dir"]; ok {
+		if err := json.Unmarshal(raw, &dir); err != nil {
+			return err
+		}
+	}
+	if dir == "" {
+		return nil
+	}
+
+	rel, err := filepath.Rel(oldRoot, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = filepath.Base(dir)
+	}
+	remapped, err := json.Marshal(filepath.Join(newRoot, rel))
+	if err != nil {
+		return err
+	}
+	row["scan_dir"] = remapped
+	return nil
+}
+
+func moveScans(from, scanRoot string) error {
+	entries, err := os.ReadDir(from)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(scanRoot, 0755); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.Rename(filepath.Join(from, entry.Name()), filepath.Join(scanRoot, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyModules copies the archive's modules over those in modulesDir.
+func copyModules(from, modulesDir string) error {
+	if _, err := os.Stat(from); modulesDir == "" || errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return filepath.WalkDir(from, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(from, file)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(modulesDir, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	})
+}
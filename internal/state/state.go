@@ -0,0 +1,175 @@
+// Package state moves a pipeliner instance to another server: the database,
+// the scan directories and the scan modules, in one archive.
+package state
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the layout of the tables in an export. Bump it when a
+// model changes in a way an older export cannot be imported into.
+const SchemaVersion = 1
+
+// manifestName is the archive's last entry, written once the checksums of
+// everything before it are known.
+const manifestName = "manifest.json"
+
+// Directories of the archive.
+const (
+	tablesDir  = "db"
+	scansDir   = "scans"
+	modulesDir = "config"
+)
+
+// Manifest describes an export.
+type Manifest struct {
+	SchemaVersion int       `json:"schema_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	// ScanRoot is the directory the scans were in, for moving the scan
+	// directories recorded in the database under the new one.
+	ScanRoot           string           `json:"scan_root"`
+	Tables             map[string]int64 `json:"tables"`
+	ScreenshotsSkipped bool             `json:"screenshots_skipped,omitempty"`
+	Files              []File           `json:"files"`
+}
+
+// File is an entry of the archive with its SHA-256.
+type File struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// isScreenshot matches the screenshots the artifact processor lists.
+func isScreenshot(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".png", ".jpg", ".jpeg":
+		return true
+	}
+	return false
+}
+
+// createArchive opens file for writing, compressed as its extension says:
+// .zst with the zstd command, .gz or .tgz with gzip, anything else as a
+// plain tar.
+func createArchive(file string) (io.WriteCloser, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasSuffix(file, ".zst"):
+		cmd := exec.Command("zstd", "-q", "-c", "-")
+		cmd.Stdout = f
+		cmd.Stderr = os.Stderr
+		stdin, err := cmd.StdinPipe()
+		if err == nil {
+			err = cmd.Start()
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("run zstd: %w", err)
+		}
+		return &commandWriter{WriteCloser: stdin, cmd: cmd, file: f}, nil
+	case strings.HasSuffix(file, ".gz"), strings.HasSuffix(file, ".tgz"):
+		return &gzipWriter{Writer: gzip.NewWriter(f), file: f}, nil
+	}
+	return f, nil
+}
+
+// openArchive is the reading side of createArchive.
+func openArchive(file string) (io.ReadCloser, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasSuffix(file, ".zst"):
+		cmd := exec.Command("zstd", "-q", "-d", "-c")
+		cmd.Stdin = f
+		cmd.Stderr = os.Stderr
+		stdout, err := cmd.StdoutPipe()
+		if err == nil {
+			err = cmd.Start()
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("run zstd: %w", err)
+		}
+		return &commandReader{ReadCloser: stdout, cmd: cmd, file: f}, nil
+	case strings.HasSuffix(file, ".gz"), strings.HasSuffix(file, ".tgz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &gzipReader{Reader: gz, file: f}, nil
+	}
+	return f, nil
+}
+
+type commandWriter struct {
+	io.WriteCloser
+	cmd  *exec.Cmd
+	file *os.File
+}
+
+func (w *commandWriter) Close() error {
+	err := w.WriteCloser.Close()
+	if waitErr := w.cmd.Wait(); err == nil && waitErr != nil {
+		err = fmt.Errorf("zstd: %w", waitErr)
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+type commandReader struct {
+	io.ReadCloser
+	cmd  *exec.Cmd
+	file *os.File
+}
+
+func (r *commandReader) Close() error {
+	// drain so zstd is not killed by a broken pipe
+	io.Copy(io.Discard, r.ReadCloser)
+	err := r.cmd.Wait()
+	if err != nil {
+		err = fmt.Errorf("zstd: %w", err)
+	}
+	r.file.Close()
+	return err
+}
+
+type gzipWriter struct {
+	*gzip.Writer
+	file *os.File
+}
+
+func (w *gzipWriter) Close() error {
+	err := w.Writer.Close()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+type gzipReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r *gzipReader) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
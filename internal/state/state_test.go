@@ -0,0 +1,250 @@
+package state
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pipeliner/internal/dao"
+	"pipeliner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestInstance(t *testing.T) (*gorm.DB, dao.StateDAO) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(dao.StateModels...))
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	states, err := dao.NewStateDAO(db)
+	require.NoError(t, err)
+	return db, states
+}
+
+func writeFile(t *testing.T, file, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+	require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+}
+
+// seed fills an instance with a finished scan and everything hanging off it.
+func seed(t *testing.T, db *gorm.DB, scanRoot, modulesDir string) {
+	t.Helper()
+
+	scanDir := filepath.Join(scanRoot, "quick_scan_example.com_2025-01-02_03-04-05")
+	require.NoError(t, db.Create(&models.Scan{
+		UUID:              "3f2b6a34-1f0e-4c1b-9a55-3d9f8c1e2a10",
+		ScanType:          "quick_scan",
+		Status:            models.ScanCompletedWithWarnings,
+		Domain:            "example.com",
+		SeverityCounts:    map[string]int{"critical": 1},
+		SensitivePatterns: "/.env|critical|Env file|Configuration",
+		SensitiveFilters:  models.SensitiveFilters{MinLength: 10, Soft404: true},
+		FailedTools:       []models.ToolFailure{{ToolName: "nuclei", Error: "exit status 1"}},
+		Tags:              []string{"prod"},
+		ToolLogs:          map[string]string{"httpx": "quick_scan_example.com_2025-01-02_03-04-05/logs/httpx.log"},
+		ScanDir:           scanDir,
+		CreatedAt:         1735787045,
+		UpdatedAt:         1735787999,
+	}).Error)
+	require.NoError(t, db.Create(&models.Subdomain{
+		ScanID:       "3f2b6a34-1f0e-4c1b-9a55-3d9f8c1e2a10",
+		Domain:       "api.example.com",
+		OpenPorts:    []string{"443"},
+		Status:       models.SubdomainAlive,
+		Technologies: []string{"nginx"},
+		Sensitive:    []models.SensitiveFinding{{URL: "https://api.example.com/.env", Status: 200, Severity: "critical", Suppressed: "soft_404"}},
+	}).Error)
+	require.NoError(t, db.Create(&models.HookExecution{ScanID: "3f2b6a34-1f0e-4c1b-9a55-3d9f8c1e2a10", HookName: "cleanup_files", Status: "succeeded"}).Error)
+	require.NoError(t, db.Create(&models.ScanWebhook{ScanID: "3f2b6a34-1f0e-4c1b-9a55-3d9f8c1e2a10", URL: "https://hooks.example.com", Secret: "s3cret", Events: []string{models.WebhookEventCompleted}}).Error)
+	require.NoError(t, db.Create(&models.WebhookDelivery{ScanID: "3f2b6a34-1f0e-4c1b-9a55-3d9f8c1e2a10", WebhookID: 1, URL: "https://hooks.example.com", Event: models.WebhookEventCompleted, Status: "delivered", Attempts: 1, CreatedAt: 1735787999}).Error)
+	require.NoError(t, db.Create(&models.ConfigChange{Module: "quick_scan", Author: "alice", NewChecksum: "abc", ChangedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)}).Error)
+
+	writeFile(t, filepath.Join(scanDir, "httpx_output.txt"), "https://api.example.com\n")
+	writeFile(t, filepath.Join(scanDir, "logs", "httpx.log"), "probing\n")
+	writeFile(t, filepath.Join(scanDir, "screenshots", "api.example.com.png"), "png")
+	writeFile(t, filepath.Join(modulesDir, "quick_scan.yaml"), "description: quick\n")
+}
+
+func dump(t *testing.T, states dao.StateDAO) map[string][]dao.StateRow {
+	t.Helper()
+	tables := make(map[string][]dao.StateRow)
+	for _, table := range states.Tables() {
+		require.NoError(t, states.ExportTable(table, func(row dao.StateRow) error {
+			tables[table] = append(tables[table], row)
+			return nil
+		}))
+	}
+	return tables
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	archives := []string{"state.tar", "state.tar.gz"}
+	if _, err := exec.LookPath("zstd"); err == nil {
+		archives = append(archives, "state.tar.zst")
+	}
+
+	for _, name := range archives {
+		t.Run(name, func(t *testing.T) {
+			oldDir, newDir := t.TempDir(), t.TempDir()
+			oldDB, oldStates := newTestInstance(t)
+			seed(t, oldDB, filepath.Join(oldDir, "scans"), filepath.Join(oldDir, "config"))
+
+			archive := filepath.Join(t.TempDir(), name)
+			manifest, err := Export(oldStates, archive, ExportOptions{
+				ScanRoot:   filepath.Join(oldDir, "scans"),
+				ModulesDir: filepath.Join(oldDir, "config"),
+			})
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), manifest.Tables["scans"])
+			assert.Len(t, manifest.Files, len(oldStates.Tables())+4)
+
+			newDB, newStates := newTestInstance(t)
+			newRoot := filepath.Join(newDir, "data", "scans")
+			_, err = Import(newStates, archive, ImportOptions{ScanRoot: newRoot, ModulesDir: filepath.Join(newDir, "config")})
+			require.NoError(t, err)
+
+			want, got := dump(t, oldStates), dump(t, newStates)
+			newScanDir := filepath.Join(newRoot, "quick_scan_example.com_2025-01-02_03-04-05")
+			assert.JSONEq(t, mustJSON(t, newScanDir), string(got["scans"][0]["scan_dir"]))
+			want["scans"][0]["scan_dir"] = got["scans"][0]["scan_dir"]
+			assert.Equal(t, want, got)
+
+			var scan models.Scan
+			require.NoError(t, newDB.First(&scan).Error)
+			assert.Equal(t, newScanDir, scan.ScanDir)
+			assert.Equal(t, []string{"prod"}, scan.Tags)
+			var webhook models.ScanWebhook
+			require.NoError(t, newDB.First(&webhook).Error)
+			assert.Equal(t, "s3cret", webhook.Secret)
+
+			for file, content := range map[string]string{
+				filepath.Join(newScanDir, "httpx_output.txt"):                   "https://api.example.com\n",
+				filepath.Join(newScanDir, "screenshots", "api.example.com.png"): "png",
+				filepath.Join(newDir, "config", "quick_scan.yaml"):              "description: quick\n",
+			} {
+				data, err := os.ReadFile(file)
+				require.NoError(t, err)
+				assert.Equal(t, content, string(data))
+			}
+			entries, err := os.ReadDir(filepath.Join(newDir, "data"))
+			require.NoError(t, err)
+			assert.Len(t, entries, 1, "staging directory left behind")
+
+			// new rows get ids after the imported ones
+			next := models.HookExecution{ScanID: scan.UUID, HookName: "template_update"}
+			require.NoError(t, newDB.Create(&next).Error)
+			assert.Equal(t, uint(2), next.ID)
+		})
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestExportSkipScreenshots(t *testing.T) {
+	dir := t.TempDir()
+	db, states := newTestInstance(t)
+	seed(t, db, filepath.Join(dir, "scans"), filepath.Join(dir, "config"))
+
+	archive := filepath.Join(dir, "state.tar")
+	manifest, err := Export(states, archive, ExportOptions{
+		ScanRoot:        filepath.Join(dir, "scans"),
+		ModulesDir:      filepath.Join(dir, "config"),
+		SkipScreenshots: true,
+	})
+	require.NoError(t, err)
+	assert.True(t, manifest.ScreenshotsSkipped)
+	for _, file := range manifest.Files {
+		assert.NotContains(t, file.Path, ".png")
+	}
+}
+
+func TestImportRejectsInstanceWithScans(t *testing.T) {
+	dir := t.TempDir()
+	db, states := newTestInstance(t)
+	seed(t, db, filepath.Join(dir, "scans"), filepath.Join(dir, "config"))
+	archive := filepath.Join(dir, "state.tar")
+	_, err := Export(states, archive, ExportOptions{ScanRoot: filepath.Join(dir, "scans")})
+	require.NoError(t, err)
+
+	_, err = Import(states, archive, ImportOptions{ScanRoot: filepath.Join(t.TempDir(), "scans")})
+	assert.ErrorIs(t, err, ErrNotFresh)
+
+	_, fresh := newTestInstance(t)
+	_, err = Import(fresh, archive, ImportOptions{ScanRoot: filepath.Join(dir, "scans")})
+	assert.ErrorIs(t, err, ErrNotFresh)
+}
+
+func TestImportRejectsCorruptArchive(t *testing.T) {
+	dir := t.TempDir()
+	db, states := newTestInstance(t)
+	seed(t, db, filepath.Join(dir, "scans"), filepath.Join(dir, "config"))
+	archive := filepath.Join(dir, "state.tar")
+	_, err := Export(states, archive, ExportOptions{ScanRoot: filepath.Join(dir, "scans"), ModulesDir: filepath.Join(dir, "config")})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(archive)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(archive, bytes.Replace(data, []byte("description: quick"), []byte("description: QUICK"), 1), 0644))
+
+	_, fresh := newTestInstance(t)
+	newRoot := filepath.Join(t.TempDir(), "scans")
+	_, err = Import(fresh, archive, ImportOptions{ScanRoot: newRoot})
+	assert.ErrorIs(t, err, ErrCorruptArchive)
+
+	rows, err := fresh.CountRows("scans")
+	require.NoError(t, err)
+	assert.Zero(t, rows)
+	assert.NoDirExists(t, newRoot)
+}
+
+func TestImportRejectsOtherSchemaVersion(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "state.tar")
+	f, err := os.Create(archive)
+	require.NoError(t, err)
+	w := &archiveWriter{tar: tar.NewWriter(f)}
+	manifest := mustJSON(t, Manifest{SchemaVersion: SchemaVersion + 1, Tables: map[string]int64{}})
+	require.NoError(t, w.write(manifestName, int64(len(manifest)), time.Now(), bytes.NewReader([]byte(manifest))))
+	require.NoError(t, w.tar.Close())
+	require.NoError(t, f.Close())
+
+	_, states := newTestInstance(t)
+	_, err = Import(states, archive, ImportOptions{ScanRoot: filepath.Join(t.TempDir(), "scans")})
+	assert.ErrorIs(t, err, ErrIncompatibleSchema)
+}
+
+func TestImportRejectsUnknownColumn(t *testing.T) {
+	_, states := newTestInstance(t)
+	rows := []dao.StateRow{{"uuid": json.RawMessage(`"x"`), "colour": json.RawMessage(`"red"`)}}
+	err := states.ImportTable("scans", func() (dao.StateRow, error) {
+		if len(rows) == 0 {
+			return nil, io.EOF
+		}
+		row := rows[0]
+		rows = rows[1:]
+		return row, nil
+	})
+	assert.ErrorContains(t, err, `no column "colour"`)
+}
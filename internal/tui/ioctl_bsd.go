@@ -0,0 +1,14 @@
+//go:build darwin || freebsd || netbsd || openbsd
+
+package tui
+
+import "golang.org/x/sys/unix"
+
+const (
+	ioctlGetTermios = unix.TIOCGETA
+	ioctlSetTermios = unix.TIOCSETA
+)
+
+func dup2(oldfd, newfd int) error {
+	return unix.Dup2(oldfd, newfd)
+}
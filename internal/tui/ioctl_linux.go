@@ -0,0 +1,13 @@
+package tui
+
+import "golang.org/x/sys/unix"
+
+const (
+	ioctlGetTermios = unix.TCGETS
+	ioctlSetTermios = unix.TCSETS
+)
+
+// dup2 uses dup3, which every linux architecture has.
+func dup2(oldfd, newfd int) error {
+	return unix.Dup3(oldfd, newfd, 0)
+}
@@ -0,0 +1,277 @@
+// Package tui shows a running CLI scan in the terminal: its tools with their
+// status, the progress of each stage and a scrolling log.
+package tui
+
+import (
+	"fmt"
+	"pipeliner/pkg/tools"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLogLines is how much of the log the view keeps to scroll back through.
+const maxLogLines = 2000
+
+// minLogLines is the log the view keeps on screen when there are more
+// tools than fit.
+const minLogLines = 5
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+type toolRow struct {
+	name     string
+	stage    tools.Stage
+	status   string
+	started  time.Time
+	finished time.Time
+}
+
+// Model is the state of the view. The progress events and log lines of the
+// scan feed it; Render draws it.
+type Model struct {
+	mu      sync.Mutex
+	title   string
+	began   time.Time
+	rows    []*toolRow
+	byName  map[string]*toolRow
+	note    string
+	logs    []string
+	scroll  int
+	verbose bool
+}
+
+func NewModel(title string, began time.Time) *Model {
+	return &Model{title: title, began: began, byName: make(map[string]*toolRow)}
+}
+
+// SetTools lists the module's tools, in order, before any of them starts.
+func (m *Model) SetTools(configs []tools.ToolConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range configs {
+		m.row(configs[i].Name).stage = configs[i].Stage()
+	}
+}
+
+func (m *Model) row(name string) *toolRow {
+	r, ok := m.byName[name]
+	if !ok {
+		r = &toolRow{name: name, status: "Pending"}
+		m.byName[name] = r
+		m.rows = append(m.rows, r)
+	}
+	return r
+}
+
+// Progress applies a progress event, from a tool or from the strategy
+// running it.
+func (m *Model) Progress(event tools.ProgressEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch event.Status {
+	case tools.ProgressDAGChanged:
+		return
+	case tools.ProgressCoolingDown:
+		m.note = fmt.Sprintf("Cooling down after %s: %s", event.Tool, event.Message)
+		return
+	case tools.ProgressPaused, tools.ProgressCancelled:
+		m.note = event.Status
+		if event.Message != "" {
+			m.note += ": " + event.Message
+		}
+		return
+	case tools.ProgressCooldownFinished, tools.ProgressResumed:
+		m.note = ""
+		return
+	}
+	if event.Tool == "" {
+		return
+	}
+
+	r := m.row(event.Tool)
+	switch event.Status {
+	case "Started":
+		// periodic scans run every tool again
+		r.started, r.finished = event.Timestamp, time.Time{}
+		r.status = "Running"
+	case "Running":
+		if r.started.IsZero() {
+			r.started = event.Timestamp
+		}
+	case "Completed", "Failed", tools.ProgressCompletedEmpty, tools.ProgressSkipped:
+		r.status = event.Status
+		r.finished = event.Timestamp
+	default:
+		r.status = event.Status
+	}
+}
+
+// Log adds a line of the scan's log.
+func (m *Model) Log(line string) {
+	line = strings.TrimRight(ansiEscape.ReplaceAllString(line, ""), "\r\n")
+	line = strings.ReplaceAll(line, "\t", "    ")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logs = append(m.logs, line)
+	if len(m.logs) > maxLogLines {
+		m.logs = append(m.logs[:0], m.logs[len(m.logs)-maxLogLines:]...)
+	}
+	// keep the lines being read in place while scrolled back
+	if m.scroll > 0 {
+		m.scroll = min(m.scroll+1, len(m.logs))
+	}
+}
+
+// Scroll moves the log view back (positive) or forward through the log.
+func (m *Model) Scroll(lines int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scroll = max(0, min(m.scroll+lines, len(m.logs)))
+}
+
+// ToggleVerbose flips whether debug logs are shown and returns the new
+// setting.
+func (m *Model) ToggleVerbose() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.verbose = !m.verbose
+	return m.verbose
+}
+
+// SetNote replaces the line under the title, e.g. while the scan stops.
+func (m *Model) SetNote(note string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.note = note
+}
+
+func finished(status string) bool {
+	switch status {
+	case "Completed", "Failed", tools.ProgressCompletedEmpty, tools.ProgressSkipped:
+		return true
+	}
+	return false
+}
+
+func (r *toolRow) duration(now time.Time) string {
+	if r.started.IsZero() {
+		return "-"
+	}
+	end := now
+	if !r.finished.IsZero() {
+		end = r.finished
+	}
+	return end.Sub(r.started).Round(time.Second).String()
+}
+
+// stageLines are the progress bars of the stages that have tools, in the
+// order a scan reaches them.
+func (m *Model) stageLines(width int) []string {
+	var lines []string
+	for _, stage := range append(append([]tools.Stage(nil), tools.Stages...), "") {
+		total, done := 0, 0
+		for _, r := range m.rows {
+			if r.stage != stage {
+				continue
+			}
+			total++
+			if finished(r.status) {
+				done++
+			}
+		}
+		if total == 0 {
+			continue
+		}
+		name := string(stage)
+		if name == "" {
+			name = "other"
+		}
+		barWidth := max(10, min(40, width-30))
+		filled := barWidth * done / total
+		lines = append(lines, fmt.Sprintf("%-15s [%s%s] %d/%d", name,
+			strings.Repeat("#", filled), strings.Repeat("-", barWidth-filled), done, total))
+	}
+	return lines
+}
+
+// Table is the tools with their stage, status and how long they ran.
+func (m *Model) Table(now time.Time) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.table(now)
+}
+
+func (m *Model) table(now time.Time) []string {
+	nameWidth := len("TOOL")
+	for _, r := range m.rows {
+		nameWidth = max(nameWidth, utf8.RuneCountInString(r.name))
+	}
+	format := fmt.Sprintf("%%-%ds  %%-15s  %%-15s  %%s", nameWidth)
+	lines := []string{fmt.Sprintf(format, "TOOL", "STAGE", "STATUS", "DURATION")}
+	for _, r := range m.rows {
+		lines = append(lines, fmt.Sprintf(format, r.name, r.stage, r.status, r.duration(now)))
+	}
+	return lines
+}
+
+// Render draws the view in width columns and height lines.
+func (m *Model) Render(width, height int, now time.Time) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lines := []string{fmt.Sprintf("%s  (%s)", m.title, now.Sub(m.began).Round(time.Second))}
+	lines = append(lines, m.note, "")
+	lines = append(lines, m.stageLines(width)...)
+	lines = append(lines, "")
+
+	table := m.table(now)
+	footer := "q cancel scan   v verbose logs: off   up/down pgup/pgdn scroll log"
+	if m.verbose {
+		footer = strings.Replace(footer, "verbose logs: off", "verbose logs: on", 1)
+	}
+	// title, blank, log header and footer around the log
+	room := height - len(lines) - 3 - minLogLines
+	if room < len(table) {
+		hidden := len(table) - max(room-1, 1)
+		table = append(table[:len(table)-hidden], fmt.Sprintf("... %d more tools", hidden))
+	}
+	lines = append(lines, table...)
+	lines = append(lines, "", "LOG"+scrollMark(m.scroll))
+
+	logHeight := max(height-len(lines)-1, 0)
+	end := len(m.logs) - m.scroll
+	start := max(end-logHeight, 0)
+	logs := m.logs[start:end]
+	lines = append(lines, logs...)
+	for i := len(logs); i < logHeight; i++ {
+		lines = append(lines, "")
+	}
+	lines = append(lines, footer)
+
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	for i, line := range lines {
+		lines[i] = truncate(line, width)
+	}
+	return lines
+}
+
+func scrollMark(scroll int) string {
+	if scroll == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%d lines back)", scroll)
+}
+
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width])
+}
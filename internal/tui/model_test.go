@@ -0,0 +1,93 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"pipeliner/pkg/tools"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestModel(began time.Time) *Model {
+	m := NewModel("pipeliner: full_recon on example.com", began)
+	m.SetTools([]tools.ToolConfig{
+		{Name: "subfinder", Type: "domain_enum"},
+		{Name: "chaos", Type: "domain_enum"},
+		{Name: "httpx", Type: "fingerprint"},
+		{Name: "nuclei", Type: "vuln"},
+	})
+	return m
+}
+
+func TestModel_ProgressAndStages(t *testing.T) {
+	began := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := newTestModel(began)
+
+	m.Progress(tools.ProgressEvent{Tool: "subfinder", Status: "Started", Timestamp: began})
+	m.Progress(tools.ProgressEvent{Tool: "subfinder", Status: "Running", Timestamp: began.Add(2 * time.Second)})
+	m.Progress(tools.ProgressEvent{Tool: "subfinder", Status: "Completed", Timestamp: began.Add(90 * time.Second)})
+	m.Progress(tools.ProgressEvent{Tool: "chaos", Status: "Started", Timestamp: began.Add(10 * time.Second)})
+	m.Progress(tools.ProgressEvent{Tool: "subfinder", Status: tools.ProgressCoolingDown, Message: "waiting 30s before starting the next tools", Timestamp: began})
+	// the DAG snapshots are for the web UI's graph
+	m.Progress(tools.ProgressEvent{Status: tools.ProgressDAGChanged})
+
+	lines := m.Render(100, 30, began.Add(2*time.Minute))
+	view := strings.Join(lines, "\n")
+
+	assert.Equal(t, "pipeliner: full_recon on example.com  (2m0s)", lines[0])
+	assert.Equal(t, "Cooling down after subfinder: waiting 30s before starting the next tools", lines[1])
+	assert.Contains(t, view, "subdomain_enum  [")
+	assert.Contains(t, view, "] 1/2")
+	assert.Contains(t, view, "] 0/1")
+	assert.Contains(t, view, "subfinder  subdomain_enum   Completed        1m30s")
+	assert.Contains(t, view, "chaos      subdomain_enum   Running          1m50s")
+	assert.Contains(t, view, "nuclei     vuln_scan        Pending          -")
+	assert.Len(t, lines, 30)
+
+	m.Progress(tools.ProgressEvent{Tool: "subfinder", Status: tools.ProgressCooldownFinished})
+	assert.Empty(t, m.Render(100, 30, began)[1])
+}
+
+func TestModel_LogScrollsAndTruncates(t *testing.T) {
+	m := newTestModel(time.Now())
+	for i := 1; i <= 50; i++ {
+		m.Log(fmt.Sprintf("\x1b[36mINFO\x1b[0m line %d\n", i))
+	}
+
+	lines := m.Render(40, 24, time.Now())
+	assert.Equal(t, "INFO line 50", lines[len(lines)-2])
+	assert.True(t, strings.HasPrefix(lines[len(lines)-1], "q cancel scan"))
+	for _, line := range lines {
+		assert.LessOrEqual(t, len([]rune(line)), 40)
+	}
+
+	m.Scroll(10)
+	lines = m.Render(40, 24, time.Now())
+	assert.Equal(t, "INFO line 40", lines[len(lines)-2])
+	assert.Contains(t, strings.Join(lines, "\n"), "LOG (10 lines back)")
+
+	// new lines do not move the view while scrolled back
+	m.Log("INFO line 51")
+	assert.Equal(t, "INFO line 40", m.Render(40, 24, time.Now())[len(lines)-2])
+
+	m.Scroll(-100)
+	assert.Equal(t, "INFO line 51", m.Render(40, 24, time.Now())[len(lines)-2])
+}
+
+func TestModel_KeepsRoomForTheLog(t *testing.T) {
+	m := NewModel("scan", time.Now())
+	var configs []tools.ToolConfig
+	for i := 0; i < 40; i++ {
+		configs = append(configs, tools.ToolConfig{Name: fmt.Sprintf("tool-%d", i), Type: "recon"})
+	}
+	m.SetTools(configs)
+	m.Log("last log line")
+
+	lines := m.Render(80, 24, time.Now())
+	assert.Len(t, lines, 24)
+	assert.Contains(t, strings.Join(lines, "\n"), "more tools")
+	assert.Contains(t, strings.Join(lines, "\n"), "last log line")
+}
@@ -0,0 +1,127 @@
+package tui
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// refreshInterval is how often the view is redrawn.
+const refreshInterval = 250 * time.Millisecond
+
+// Options are what the key bindings do.
+type Options struct {
+	// OnCancel is called for q, to stop the scan.
+	OnCancel func()
+	// OnVerbose is called for v with whether debug logs are now shown.
+	OnVerbose func(verbose bool)
+}
+
+// UI draws a Model on the terminal until Stop.
+type UI struct {
+	model *Model
+	opts  Options
+	term  *terminal
+
+	stop    chan struct{}
+	drawn   sync.WaitGroup
+	logs    sync.WaitGroup
+	stopped sync.Once
+}
+
+// Start takes over the terminal. Everything written to stdout and stderr
+// from then on, logs included, goes to the view's log instead.
+func Start(m *Model, opts Options) (*UI, error) {
+	term, err := openTerminal()
+	if err != nil {
+		return nil, err
+	}
+	ui := &UI{model: m, opts: opts, term: term, stop: make(chan struct{})}
+
+	ui.logs.Add(1)
+	go ui.readLogs(term.captured)
+	go ui.readKeys()
+	ui.drawn.Add(1)
+	go ui.draw()
+	return ui, nil
+}
+
+// Stop gives the terminal back and prints the final tool table.
+func (ui *UI) Stop() {
+	ui.stopped.Do(func() {
+		close(ui.stop)
+		ui.drawn.Wait()
+		ui.term.restore()
+		ui.logs.Wait()
+		io.WriteString(os.Stdout, strings.Join(ui.model.Table(time.Now()), "\n")+"\n")
+	})
+}
+
+func (ui *UI) readLogs(r io.Reader) {
+	defer ui.logs.Done()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		ui.model.Log(scanner.Text())
+	}
+}
+
+func (ui *UI) draw() {
+	defer ui.drawn.Done()
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		width, height := ui.term.size()
+		lines := ui.model.Render(width, height, time.Now())
+		// redraw in place, clearing what each line leaves of the last frame
+		ui.term.write("\x1b[H" + strings.Join(lines, "\x1b[K\n") + "\x1b[K\x1b[J")
+
+		select {
+		case <-ui.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// readKeys handles the key bindings. Ctrl-C still interrupts the process as
+// it would without the view.
+func (ui *UI) readKeys() {
+	buf := make([]byte, 16)
+	for {
+		n, err := ui.term.in.Read(buf)
+		if err != nil {
+			return
+		}
+		select {
+		case <-ui.stop:
+			return
+		default:
+		}
+
+		_, height := ui.term.size()
+		switch key := string(buf[:n]); key {
+		case "q", "Q":
+			ui.model.SetNote("Cancelling the scan...")
+			if ui.opts.OnCancel != nil {
+				ui.opts.OnCancel()
+			}
+		case "v", "V":
+			verbose := ui.model.ToggleVerbose()
+			if ui.opts.OnVerbose != nil {
+				ui.opts.OnVerbose(verbose)
+			}
+		case "\x1b[A", "k":
+			ui.model.Scroll(1)
+		case "\x1b[B", "j":
+			ui.model.Scroll(-1)
+		case "\x1b[5~":
+			ui.model.Scroll(height / 2)
+		case "\x1b[6~":
+			ui.model.Scroll(-height / 2)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package tui
+
+import (
+	"errors"
+	"os"
+)
+
+type terminal struct {
+	in       *os.File
+	captured *os.File
+}
+
+// Available is false: the view needs a unix terminal.
+func Available() bool {
+	return false
+}
+
+func openTerminal() (*terminal, error) {
+	return nil, errors.New("the terminal view is not supported on this platform")
+}
+
+func (t *terminal) restore() {}
+
+func (t *terminal) size() (width, height int) {
+	return 80, 24
+}
+
+func (t *terminal) write(s string) {}
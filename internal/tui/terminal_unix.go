@@ -0,0 +1,109 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package tui
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// terminal is the terminal in raw mode, with stdout and stderr redirected
+// to captured.
+type terminal struct {
+	in       *os.File
+	out      *os.File
+	captured *os.File
+	pipe     *os.File
+	stderr   int
+	original *unix.Termios
+}
+
+// Available reports whether stdin and stdout are a terminal the view can
+// take over.
+func Available() bool {
+	for _, fd := range []int{unix.Stdin, unix.Stdout} {
+		if _, err := unix.IoctlGetTermios(fd, ioctlGetTermios); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func openTerminal() (*terminal, error) {
+	original, err := unix.IoctlGetTermios(unix.Stdin, ioctlGetTermios)
+	if err != nil {
+		return nil, fmt.Errorf("stdin is not a terminal: %w", err)
+	}
+	// keys arrive one at a time and unechoed; Ctrl-C still sends SIGINT
+	raw := *original
+	raw.Lflag &^= unix.ICANON | unix.ECHO
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(unix.Stdin, ioctlSetTermios, &raw); err != nil {
+		return nil, fmt.Errorf("failed to set up the terminal: %w", err)
+	}
+
+	t := &terminal{in: os.Stdin, original: original, stderr: -1}
+	if err := t.capture(); err != nil {
+		t.restore()
+		return nil, err
+	}
+	// alternate screen, cursor hidden
+	t.write("\x1b[?1049h\x1b[?25l")
+	return t, nil
+}
+
+// capture points stdout and stderr at a pipe, keeping the terminal in out.
+func (t *terminal) capture() error {
+	stdout, err := unix.Dup(unix.Stdout)
+	if err != nil {
+		return err
+	}
+	t.out = os.NewFile(uintptr(stdout), "/dev/tty")
+	if t.stderr, err = unix.Dup(unix.Stderr); err != nil {
+		return err
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	t.captured, t.pipe = r, w
+	for _, fd := range []int{unix.Stdout, unix.Stderr} {
+		if err := dup2(int(w.Fd()), fd); err != nil {
+			return fmt.Errorf("failed to capture output: %w", err)
+		}
+	}
+	return nil
+}
+
+func (t *terminal) restore() {
+	if t.out != nil {
+		t.write("\x1b[?25h\x1b[?1049l")
+		dup2(int(t.out.Fd()), unix.Stdout)
+		t.out.Close()
+	}
+	if t.stderr >= 0 {
+		dup2(t.stderr, unix.Stderr)
+		unix.Close(t.stderr)
+	}
+	// the reader of captured sees EOF once no descriptor holds the pipe
+	if t.pipe != nil {
+		t.pipe.Close()
+	}
+	unix.IoctlSetTermios(unix.Stdin, ioctlSetTermios, t.original)
+}
+
+func (t *terminal) size() (width, height int) {
+	ws, err := unix.IoctlGetWinsize(int(t.out.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 || ws.Row == 0 {
+		return 80, 24
+	}
+	return int(ws.Col), int(ws.Row)
+}
+
+func (t *terminal) write(s string) {
+	t.out.WriteString(s)
+}
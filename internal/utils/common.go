@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	perrors "pipeliner/pkg/errors"
 	"pipeliner/pkg/logger"
 	"strings"
 	"time"
@@ -20,6 +21,8 @@ type ConfigOptions struct {
 	ConfigType  string
 	EnvPrefix   string
 	DefaultsMap map[string]interface{}
+	// Exclusive searches ConfigPath only.
+	Exclusive bool
 }
 
 var projectConfigPath, _ = filepath.Abs("./config")
@@ -27,12 +30,18 @@ var projectConfigPath, _ = filepath.Abs("./config")
 var projectRoot, _ = filepath.Abs(".")
 
 func NewViperConfig(scanType string) (*viper.Viper, error) {
+	return NewModuleConfig(CurrentModuleOrigin(), scanType)
+}
 
+// NewModuleConfig loads a scan module from origin. Modules of a managed
+// origin are not looked up in the local fallback directories.
+func NewModuleConfig(origin ModuleOrigin, scanType string) (*viper.Viper, error) {
 	return NewViperConfigWithOptions(ConfigOptions{
-		ConfigPath: projectConfigPath,
+		ConfigPath: origin.Dir,
 		ConfigName: scanType,
 		ConfigType: "yaml",
 		EnvPrefix:  "PIPELINER",
+		Exclusive:  origin.Managed(),
 	})
 }
 
@@ -42,10 +51,12 @@ func NewViperConfigWithOptions(opts ConfigOptions) (*viper.Viper, error) {
 	v.SetConfigType(opts.ConfigType)
 
 	configPaths := []string{opts.ConfigPath}
-	if opts.ConfigPath != "./config" {
-		configPaths = append(configPaths, "./config")
+	if !opts.Exclusive {
+		if opts.ConfigPath != "./config" {
+			configPaths = append(configPaths, "./config")
+		}
+		configPaths = append(configPaths, "/etc/pipeliner", "$HOME/.pipeliner")
 	}
-	configPaths = append(configPaths, "/etc/pipeliner", "$HOME/.pipeliner")
 
 	for _, path := range configPaths {
 		v.AddConfigPath(path)
@@ -69,7 +80,7 @@ func NewViperConfigWithOptions(opts ConfigOptions) (*viper.Viper, error) {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
 			return nil, fmt.Errorf("config file '%s' not found in paths: %v", opts.ConfigName, configPaths)
 		}
-		return nil, fmt.Errorf("error reading config file: %w", err)
+		return nil, fmt.Errorf("%w: error reading config file %s: %w", perrors.ErrInvalidConfig, v.ConfigFileUsed(), err)
 	}
 
 	utilsLogger.Infof("Loaded config file: %s", v.ConfigFileUsed())
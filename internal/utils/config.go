@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+
+	perrors "pipeliner/pkg/errors"
+
+	"github.com/spf13/viper"
+)
+
+// decodeKeyPattern pulls the key path out of a mapstructure error message,
+// e.g. "error decoding 'tools[0].timeout': ..." or "'retries' expected type ...".
+var decodeKeyPattern = regexp.MustCompile(`'([^']+)'`)
+
+// DecodeConfig unmarshals v into out. Each key that fails to decode is
+// reported as a *errors.ConfigDecodeError carrying the config file, the key
+// path and the Go type the key should have held.
+func DecodeConfig(v *viper.Viper, out interface{}) error {
+	return DecodeConfigNamed(v, out, v.ConfigFileUsed())
+}
+
+// DecodeConfigNamed is DecodeConfig for configs not read from a file, such as
+// YAML submitted for validation; file names the source in errors.
+func DecodeConfigNamed(v *viper.Viper, out interface{}, file string) error {
+	err := v.Unmarshal(out)
+	if err == nil {
+		return nil
+	}
+
+	var decodeErrs []error
+	for _, leaf := range leafErrors(err) {
+		decodeErrs = append(decodeErrs, newDecodeError(file, reflect.TypeOf(out), leaf))
+	}
+	return errors.Join(decodeErrs...)
+}
+
+func newDecodeError(file string, target reflect.Type, err error) error {
+	match := decodeKeyPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return perrors.NewConfigDecodeError(file, "", "", err)
+	}
+	// untagged fields are reported by Go field name; viper keys are lowercase
+	key := strings.ToLower(match[1])
+
+	// "error decoding 'key': <cause>" wraps the decode hook's error; keep the cause
+	if strings.HasPrefix(err.Error(), "error decoding ") {
+		if cause := errors.Unwrap(err); cause != nil {
+			err = cause
+		}
+	}
+
+	expected := ""
+	if t := typeAtKey(target, key); t != nil {
+		expected = t.String()
+	}
+	return perrors.NewConfigDecodeError(file, key, expected, err)
+}
+
+// leafErrors flattens the errors.Join trees mapstructure builds per struct,
+// including the "decoding failed" summary it wraps them in.
+func leafErrors(err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		if inner := errors.Unwrap(err); inner != nil {
+			if _, isJoin := inner.(interface{ Unwrap() []error }); isJoin {
+				return leafErrors(inner)
+			}
+		}
+		return []error{err}
+	}
+	var leaves []error
+	for _, e := range joined.Unwrap() {
+		leaves = append(leaves, leafErrors(e)...)
+	}
+	return leaves
+}
+
+// typeAtKey walks a mapstructure key path such as "tools[1].timeout" or
+// "stage_timeouts[recon]" through t and returns the type found there.
+func typeAtKey(t reflect.Type, key string) reflect.Type {
+	for _, segment := range strings.Split(key, ".") {
+		name, indexes := segment, 0
+		if i := strings.Index(segment, "["); i >= 0 {
+			name, indexes = segment[:i], strings.Count(segment[i:], "[")
+		}
+
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if name != "" {
+			if t.Kind() != reflect.Struct {
+				return nil
+			}
+			field, ok := structFieldByKey(t, name)
+			if !ok {
+				return nil
+			}
+			t = field.Type
+		}
+		for ; indexes > 0; indexes-- {
+			switch t.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+				t = t.Elem()
+			default:
+				return nil
+			}
+		}
+	}
+	return t
+}
+
+func structFieldByKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if tag == key || (tag == "" && strings.EqualFold(field.Name, key)) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
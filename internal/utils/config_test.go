@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	perrors "pipeliner/pkg/errors"
+	"pipeliner/pkg/tools"
+)
+
+func TestDecodeConfig_ReportsKeyAndType(t *testing.T) {
+	dir := t.TempDir()
+	config := `execution_mode: sequential
+tools:
+  - name: subfinder
+    command: subfinder
+  - name: httpx
+    command: httpx
+    timeout: 5 minutes
+    retries: many
+`
+	if err := os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewViperConfigWithOptions(ConfigOptions{ConfigPath: dir, ConfigName: "broken", ConfigType: "yaml"})
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+
+	var chainConfig tools.ChainConfig
+	err = DecodeConfig(v, &chainConfig)
+	if err == nil {
+		t.Fatal("expected a decode error")
+	}
+	if !errors.Is(err, perrors.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+
+	var decodeErr *perrors.ConfigDecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected ConfigDecodeError, got %T", err)
+	}
+	if decodeErr.File != filepath.Join(dir, "broken.yaml") {
+		t.Errorf("unexpected file %q", decodeErr.File)
+	}
+
+	msg := err.Error()
+	for _, want := range []string{`"tools[1].timeout"`, "time.Duration", `"tools[1].retries"`, "int"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error %q does not mention %s", msg, want)
+		}
+	}
+}
+
+func TestNewViperConfig_ParseErrorIsInvalidConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bad.yaml"), []byte("tools: [\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewViperConfigWithOptions(ConfigOptions{ConfigPath: dir, ConfigName: "bad", ConfigType: "yaml"})
+	if !errors.Is(err, perrors.ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "bad.yaml") {
+		t.Errorf("error %q does not name the file", err)
+	}
+}
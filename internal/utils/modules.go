@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"os"
+	"sync"
+)
+
+// ModuleOrigin is where scan modules are currently read from. Revision is
+// empty for the local config directory.
+type ModuleOrigin struct {
+	Dir      string `json:"-"`
+	Source   string `json:"source"`
+	Revision string `json:"revision,omitempty"`
+}
+
+// Managed reports whether the modules come from a remote source and must not
+// be edited in place.
+func (o ModuleOrigin) Managed() bool {
+	return o.Revision != ""
+}
+
+var (
+	moduleOriginMu sync.RWMutex
+	moduleOrigin   = ModuleOrigin{Dir: projectConfigPath, Source: "local"}
+	// earlier git checkouts by revision; pruned ones fail the Stat in
+	// ModuleOriginFor
+	pastOrigins = map[string]ModuleOrigin{}
+	// holds on git revisions by scans still using their checkouts
+	heldRevisions = map[string]int{}
+)
+
+// CurrentModuleOrigin returns the module directory scans load from.
+func CurrentModuleOrigin() ModuleOrigin {
+	moduleOriginMu.RLock()
+	defer moduleOriginMu.RUnlock()
+	return moduleOrigin
+}
+
+// SetModuleOrigin points module lookups at a new directory, such as a
+// validated checkout of a git config source.
+func SetModuleOrigin(origin ModuleOrigin) {
+	moduleOriginMu.Lock()
+	defer moduleOriginMu.Unlock()
+	moduleOrigin = origin
+	if origin.Managed() {
+		pastOrigins[origin.Revision] = origin
+	}
+}
+
+// ModuleOriginFor finds the modules a scan recorded it ran with. Local
+// modules resolve to the local directory as it is now; a git revision
+// resolves while its checkout is still on disk.
+func ModuleOriginFor(source, revision string) (ModuleOrigin, bool) {
+	moduleOriginMu.RLock()
+	defer moduleOriginMu.RUnlock()
+
+	if moduleOrigin.Source == source && moduleOrigin.Revision == revision {
+		return moduleOrigin, true
+	}
+	origin, ok := pastOrigins[revision]
+	if !ok || origin.Source != source {
+		return ModuleOrigin{}, false
+	}
+	if _, err := os.Stat(origin.Dir); err != nil {
+		return ModuleOrigin{}, false
+	}
+	return origin, true
+}
+
+// HoldModuleOrigin keeps the checkout of origin's revision from being
+// pruned until release is called. Local modules are not held.
+func HoldModuleOrigin(origin ModuleOrigin) (release func()) {
+	if !origin.Managed() {
+		return func() {}
+	}
+	moduleOriginMu.Lock()
+	defer moduleOriginMu.Unlock()
+	heldRevisions[origin.Revision]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			moduleOriginMu.Lock()
+			defer moduleOriginMu.Unlock()
+			if heldRevisions[origin.Revision]--; heldRevisions[origin.Revision] == 0 {
+				delete(heldRevisions, origin.Revision)
+			}
+		})
+	}
+}
+
+// ModuleRevisionHeld reports whether a scan still holds revision's checkout.
+func ModuleRevisionHeld(revision string) bool {
+	moduleOriginMu.RLock()
+	defer moduleOriginMu.RUnlock()
+	return heldRevisions[revision] > 0
+}
@@ -0,0 +1,68 @@
+package vulndb
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Cache serves lookups from a dataset file and reloads it when the file
+// changes, so a refresh is picked up without a restart.
+type Cache struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	dataset *Dataset
+}
+
+func NewCache(path string) *Cache {
+	return &Cache{path: path}
+}
+
+func (c *Cache) Path() string {
+	return c.path
+}
+
+// Load reads the dataset file if it changed since the last load. After an
+// error the cache keeps serving the dataset it had, if any.
+func (c *Cache) Load() error {
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dataset != nil && info.ModTime().Equal(c.modTime) {
+		return nil
+	}
+	dataset, err := Read(c.path)
+	if err != nil {
+		return err
+	}
+	c.dataset, c.modTime = dataset, info.ModTime()
+	return nil
+}
+
+// Lookup is the score of a CVE, or false if the loaded dataset has none.
+func (c *Cache) Lookup(cve string) (Score, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dataset == nil {
+		return Score{}, false
+	}
+	score, ok := c.dataset.Scores[cve]
+	return score, ok
+}
+
+// Stat is the number of CVEs in the loaded dataset and when it was
+// downloaded, or false before a load has succeeded.
+func (c *Cache) Stat() (cves int, updatedAt time.Time, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dataset == nil {
+		return 0, time.Time{}, false
+	}
+	return len(c.dataset.Scores), c.dataset.UpdatedAt, true
+}
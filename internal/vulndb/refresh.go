@@ -0,0 +1,81 @@
+package vulndb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// RefreshOptions say where a refresh downloads the dataset from. Sources
+// that are not http(s) URLs are read as local files, for hosts without
+// internet access.
+type RefreshOptions struct {
+	EPSSURL string
+	// CVSSURL is optional; without it findings only get the CVSS score of
+	// their nuclei template.
+	CVSSURL string
+	Client  *http.Client
+}
+
+// Refresh downloads the EPSS scores, and the CVSS scores if a source is
+// set, and replaces the dataset at path with them.
+func Refresh(ctx context.Context, path string, opts RefreshOptions) (*Dataset, error) {
+	if opts.EPSSURL == "" {
+		opts.EPSSURL = DefaultEPSSURL
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 5 * time.Minute}
+	}
+
+	dataset := &Dataset{Version: FormatVersion, UpdatedAt: time.Now().UTC(), Scores: make(map[string]Score)}
+
+	epss, err := open(ctx, opts.Client, opts.EPSSURL)
+	if err != nil {
+		return nil, err
+	}
+	dataset.EPSSDate, err = ParseEPSS(epss, dataset.Scores)
+	epss.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", opts.EPSSURL, err)
+	}
+
+	if opts.CVSSURL != "" {
+		cvss, err := open(ctx, opts.Client, opts.CVSSURL)
+		if err != nil {
+			return nil, err
+		}
+		err = ParseCVSS(cvss, dataset.Scores)
+		cvss.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", opts.CVSSURL, err)
+		}
+	}
+
+	if err := Write(path, dataset); err != nil {
+		return nil, err
+	}
+	return dataset, nil
+}
+
+func open(ctx context.Context, client *http.Client, source string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.Open(source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("downloading %s: %s", source, resp.Status)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,246 @@
+// Package vulndb keeps a local copy of EPSS scores and CVSS base scores by
+// CVE, so findings can be ranked by how likely they are to be exploited
+// without calling out to anything while a scan runs.
+package vulndb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatVersion is the version of the dataset file this build reads and
+// writes.
+const FormatVersion = 1
+
+// DefaultPath is where the server and refresh-vulndb keep the dataset
+// unless VULNDB_PATH says otherwise.
+const DefaultPath = "data/vulndb.json.gz"
+
+// DefaultEPSSURL is FIRST's daily export of every CVE's EPSS score.
+const DefaultEPSSURL = "https://epss.cyentia.com/epss_scores-current.csv.gz"
+
+// ErrUnsupportedVersion is returned for a dataset written by a newer or
+// older format.
+var ErrUnsupportedVersion = errors.New("unsupported vulndb format version")
+
+// Score is what the dataset knows about one CVE. Zero fields are unknown.
+type Score struct {
+	// EPSS is the probability of exploitation in the next 30 days and
+	// Percentile its rank among all scored CVEs, both 0 to 1.
+	EPSS       float64 `json:"epss,omitempty"`
+	Percentile float64 `json:"percentile,omitempty"`
+	// CVSS is the base score, 0 to 10.
+	CVSS float64 `json:"cvss,omitempty"`
+}
+
+// Dataset is the content of the dataset file, stored as gzipped JSON.
+type Dataset struct {
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// EPSSDate is the day the EPSS scores were computed for.
+	EPSSDate string           `json:"epss_date,omitempty"`
+	Scores   map[string]Score `json:"scores"`
+}
+
+// Read loads a dataset file.
+func Read(path string) (*Dataset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer gz.Close()
+
+	var dataset Dataset
+	if err := json.NewDecoder(gz).Decode(&dataset); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if dataset.Version != FormatVersion {
+		return nil, fmt.Errorf("%s: %w %d, expected %d", path, ErrUnsupportedVersion, dataset.Version, FormatVersion)
+	}
+	return &dataset, nil
+}
+
+// Write saves the dataset to path in one rename, so a reader never sees a
+// half-written file.
+func Write(path string, dataset *Dataset) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	gz := gzip.NewWriter(tmp)
+	out := *dataset
+	out.Version = FormatVersion
+	if err := json.NewEncoder(gz).Encode(&out); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ParseEPSS reads the EPSS export, gzipped or not: a comment line with the
+// score date, a cve,epss,percentile header and one row per CVE.
+func ParseEPSS(r io.Reader, scores map[string]Score) (date string, err error) {
+	r, err = maybeGunzip(r)
+	if err != nil {
+		return "", err
+	}
+	br := bufio.NewReader(r)
+	if line, err := br.Peek(1); err == nil && line[0] == '#' {
+		comment, err := br.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("epss: %w", err)
+		}
+		date = epssScoreDate(comment)
+	}
+
+	rows := csv.NewReader(br)
+	rows.FieldsPerRecord = -1
+	header, err := rows.Read()
+	if err != nil {
+		return "", fmt.Errorf("epss: reading header: %w", err)
+	}
+	cveCol, epssCol, pctCol := column(header, "cve"), column(header, "epss"), column(header, "percentile")
+	if cveCol < 0 || epssCol < 0 {
+		return "", fmt.Errorf("epss: header %q has no cve and epss columns", strings.Join(header, ","))
+	}
+
+	for {
+		row, err := rows.Read()
+		if err == io.EOF {
+			return date, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("epss: %w", err)
+		}
+		cve, ok := field(row, cveCol)
+		if !ok {
+			continue
+		}
+		score := scores[cve]
+		if score.EPSS, err = floatField(row, epssCol); err != nil {
+			return "", fmt.Errorf("epss: %s: %w", cve, err)
+		}
+		if pctCol >= 0 {
+			if score.Percentile, err = floatField(row, pctCol); err != nil {
+				return "", fmt.Errorf("epss: %s: %w", cve, err)
+			}
+		}
+		scores[cve] = score
+	}
+}
+
+// ParseCVSS reads CVSS base scores as CSV with a cve,cvss header, gzipped
+// or not.
+func ParseCVSS(r io.Reader, scores map[string]Score) error {
+	r, err := maybeGunzip(r)
+	if err != nil {
+		return err
+	}
+	rows := csv.NewReader(r)
+	rows.FieldsPerRecord = -1
+	rows.Comment = '#'
+	header, err := rows.Read()
+	if err != nil {
+		return fmt.Errorf("cvss: reading header: %w", err)
+	}
+	cveCol, cvssCol := column(header, "cve"), column(header, "cvss")
+	if cveCol < 0 || cvssCol < 0 {
+		return fmt.Errorf("cvss: header %q has no cve and cvss columns", strings.Join(header, ","))
+	}
+
+	for {
+		row, err := rows.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cvss: %w", err)
+		}
+		cve, ok := field(row, cveCol)
+		if !ok {
+			continue
+		}
+		cvss, err := floatField(row, cvssCol)
+		if err != nil {
+			return fmt.Errorf("cvss: %s: %w", cve, err)
+		}
+		score := scores[cve]
+		score.CVSS = cvss
+		scores[cve] = score
+	}
+}
+
+// maybeGunzip decompresses r if it starts with the gzip magic number.
+func maybeGunzip(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+// epssScoreDate picks the day out of a comment like
+// "#model_version:v2023.03.01,score_date:2024-06-01T00:00:00+0000".
+func epssScoreDate(comment string) string {
+	for _, part := range strings.Split(strings.TrimSpace(strings.TrimPrefix(comment, "#")), ",") {
+		if value, ok := strings.CutPrefix(part, "score_date:"); ok {
+			date, _, _ := strings.Cut(value, "T")
+			return date
+		}
+	}
+	return ""
+}
+
+func column(header []string, name string) int {
+	for i, h := range header {
+		if strings.EqualFold(strings.TrimSpace(h), name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// field is the CVE id in a row, upper case, or false for a blank one.
+func field(row []string, col int) (string, bool) {
+	if col >= len(row) {
+		return "", false
+	}
+	cve := strings.ToUpper(strings.TrimSpace(row[col]))
+	return cve, cve != ""
+}
+
+func floatField(row []string, col int) (float64, error) {
+	if col >= len(row) || strings.TrimSpace(row[col]) == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(strings.TrimSpace(row[col]), 64)
+}
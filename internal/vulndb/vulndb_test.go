@@ -0,0 +1,159 @@
+package vulndb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const epssCSV = `#model_version:v2023.03.01,score_date:2024-06-01T00:00:00+0000
+cve,epss,percentile
+CVE-2021-44228,0.97565,0.99992
+cve-2023-1234,0.00043,0.09
+`
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestParseEPSS(t *testing.T) {
+	for name, input := range map[string][]byte{
+		"plain":   []byte(epssCSV),
+		"gzipped": gzipped(t, epssCSV),
+	} {
+		t.Run(name, func(t *testing.T) {
+			scores := make(map[string]Score)
+			date, err := ParseEPSS(bytes.NewReader(input), scores)
+			require.NoError(t, err)
+			assert.Equal(t, "2024-06-01", date)
+			assert.Equal(t, map[string]Score{
+				"CVE-2021-44228": {EPSS: 0.97565, Percentile: 0.99992},
+				"CVE-2023-1234":  {EPSS: 0.00043, Percentile: 0.09},
+			}, scores)
+		})
+	}
+
+	_, err := ParseEPSS(strings.NewReader("cve,score\nCVE-2021-44228,1\n"), map[string]Score{})
+	assert.Error(t, err)
+	_, err = ParseEPSS(strings.NewReader("cve,epss\nCVE-2021-44228,high\n"), map[string]Score{})
+	assert.Error(t, err)
+}
+
+func TestParseCVSS(t *testing.T) {
+	scores := map[string]Score{"CVE-2021-44228": {EPSS: 0.9}}
+	require.NoError(t, ParseCVSS(strings.NewReader("# from NVD\ncve,cvss,vector\nCVE-2021-44228,10.0,AV:N\nCVE-2020-0001,5.5,\n"), scores))
+	assert.Equal(t, Score{EPSS: 0.9, CVSS: 10}, scores["CVE-2021-44228"])
+	assert.Equal(t, Score{CVSS: 5.5}, scores["CVE-2020-0001"])
+}
+
+func TestWriteRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "vulndb.json.gz")
+	dataset := &Dataset{
+		UpdatedAt: time.Date(2024, 6, 1, 6, 0, 0, 0, time.UTC),
+		EPSSDate:  "2024-06-01",
+		Scores:    map[string]Score{"CVE-2021-44228": {EPSS: 0.97565, Percentile: 0.99992, CVSS: 10}},
+	}
+	require.NoError(t, Write(path, dataset))
+
+	got, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, FormatVersion, got.Version)
+	assert.Equal(t, dataset.Scores, got.Scores)
+	assert.Equal(t, "2024-06-01", got.EPSSDate)
+
+	// the file is gzipped JSON with the format version
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	gz, err := gzip.NewReader(bytes.NewReader(raw))
+	require.NoError(t, err)
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.NewDecoder(gz).Decode(&fields))
+	assert.JSONEq(t, "1", string(fields["version"]))
+	assert.JSONEq(t, `{"CVE-2021-44228":{"epss":0.97565,"percentile":0.99992,"cvss":10}}`, string(fields["scores"]))
+
+	require.NoError(t, os.WriteFile(path, gzipped(t, `{"version":2,"scores":{}}`), 0644))
+	_, err = Read(path)
+	assert.True(t, errors.Is(err, ErrUnsupportedVersion), "got %v", err)
+}
+
+func TestRefresh(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/epss.csv.gz":
+			w.Write(gzipped(t, epssCSV))
+		case "/cvss.csv":
+			w.Write([]byte("cve,cvss\nCVE-2021-44228,10.0\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "vulndb.json.gz")
+	dataset, err := Refresh(context.Background(), path, RefreshOptions{EPSSURL: server.URL + "/epss.csv.gz", CVSSURL: server.URL + "/cvss.csv"})
+	require.NoError(t, err)
+	assert.Len(t, dataset.Scores, 2)
+
+	cache := NewCache(path)
+	require.NoError(t, cache.Load())
+	score, ok := cache.Lookup("CVE-2021-44228")
+	require.True(t, ok)
+	assert.Equal(t, Score{EPSS: 0.97565, Percentile: 0.99992, CVSS: 10}, score)
+
+	// a failed download leaves the old dataset in place
+	_, err = Refresh(context.Background(), path, RefreshOptions{EPSSURL: server.URL + "/missing"})
+	assert.Error(t, err)
+	_, err = Read(path)
+	assert.NoError(t, err)
+}
+
+func TestCache_ReloadsChangedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vulndb.json.gz")
+	cache := NewCache(path)
+	assert.True(t, os.IsNotExist(cache.Load()))
+	_, ok := cache.Lookup("CVE-2021-44228")
+	assert.False(t, ok)
+	_, _, ok = cache.Stat()
+	assert.False(t, ok)
+
+	updated := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, Write(path, &Dataset{UpdatedAt: updated, Scores: map[string]Score{"CVE-2021-44228": {EPSS: 0.5}}}))
+	require.NoError(t, cache.Load())
+	score, _ := cache.Lookup("CVE-2021-44228")
+	assert.Equal(t, 0.5, score.EPSS)
+	cves, updatedAt, ok := cache.Stat()
+	assert.True(t, ok)
+	assert.Equal(t, 1, cves)
+	assert.True(t, updated.Equal(updatedAt))
+
+	require.NoError(t, Write(path, &Dataset{Scores: map[string]Score{"CVE-2021-44228": {EPSS: 0.9}}}))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, later, later))
+	require.NoError(t, cache.Load())
+	score, _ = cache.Lookup("CVE-2021-44228")
+	assert.Equal(t, 0.9, score.EPSS)
+
+	// a broken file keeps the last good dataset
+	require.NoError(t, os.WriteFile(path, []byte("not gzip"), 0644))
+	assert.Error(t, cache.Load())
+	score, _ = cache.Lookup("CVE-2021-44228")
+	assert.Equal(t, 0.9, score.EPSS)
+}
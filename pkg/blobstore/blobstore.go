@@ -0,0 +1,156 @@
+// Package blobstore keeps scan artifacts on local disk, in object storage or,
+// for tests, in memory. Keys are slash-separated paths; stores are polled
+// through List rather than watched.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// ErrNotFound is returned by Get for a key that does not exist.
+var ErrNotFound = errors.New("blob not found")
+
+// Store reads and writes blobs by key.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns the blobs whose key starts with prefix, sorted by key.
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// URI is how scan records refer to the blob at key.
+	URI(key string) string
+	// Key is the key of a URI, or ok is false if the URI is not in this store.
+	Key(uri string) (key string, ok bool)
+}
+
+// Object is a blob listed by a Store.
+type Object struct {
+	Key  string
+	Size int64
+	// ModTime is in unix nanoseconds, as precise as the store keeps it.
+	ModTime int64
+}
+
+// prefixed is a Store whose keys all live under prefix.
+type prefixed struct {
+	store  Store
+	prefix string
+}
+
+// Prefixed scopes s to the keys under prefix, such as one scan's directory.
+func Prefixed(s Store, prefix string) Store {
+	return &prefixed{store: s, prefix: strings.Trim(prefix, "/") + "/"}
+}
+
+func (p *prefixed) Put(ctx context.Context, key string, r io.Reader) error {
+	return p.store.Put(ctx, p.prefix+key, r)
+}
+
+func (p *prefixed) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return p.store.Get(ctx, p.prefix+key)
+}
+
+func (p *prefixed) List(ctx context.Context, prefix string) ([]Object, error) {
+	objects, err := p.store.List(ctx, p.prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i := range objects {
+		objects[i].Key = strings.TrimPrefix(objects[i].Key, p.prefix)
+	}
+	return objects, nil
+}
+
+func (p *prefixed) URI(key string) string {
+	return p.store.URI(p.prefix + key)
+}
+
+func (p *prefixed) Key(uri string) (string, bool) {
+	key, ok := p.store.Key(uri)
+	if !ok || !strings.HasPrefix(key, p.prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(key, p.prefix), true
+}
+
+// Match lists the blobs directly under dir (or the top level for "")
+// whose name matches pattern, as in path.Match.
+func Match(ctx context.Context, s Store, dir, pattern string) ([]Object, error) {
+	prefix := ""
+	if dir != "" {
+		prefix = strings.Trim(dir, "/") + "/"
+	}
+	objects, err := s.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var matched []Object
+	for _, o := range objects {
+		name := strings.TrimPrefix(o.Key, prefix)
+		if strings.Contains(name, "/") {
+			continue
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			matched = append(matched, o)
+		}
+	}
+	return matched, nil
+}
+
+// localPather is implemented by stores whose blobs are plain files.
+type localPather interface {
+	path(key string) (string, error)
+}
+
+// LocalPath is the file holding the blob at key when s keeps its blobs on
+// local disk, whether or not it exists.
+func LocalPath(s Store, key string) (string, bool) {
+	if p, ok := s.(*prefixed); ok {
+		s, key = p.store, p.prefix+key
+	}
+	local, ok := s.(localPather)
+	if !ok {
+		return "", false
+	}
+	file, err := local.path(key)
+	return file, err == nil
+}
+
+// LocalCopy returns a file holding the blob at key, for readers that need a
+// path. Blobs on local disk are used in place; others are downloaded to a
+// temporary file that cleanup removes.
+func LocalCopy(ctx context.Context, s Store, key string) (file string, cleanup func(), err error) {
+	if file, ok := LocalPath(s, key); ok {
+		if info, err := os.Stat(file); err != nil || info.IsDir() {
+			return "", nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return file, func() {}, nil
+	}
+
+	r, err := s.Get(ctx, key)
+	if err != nil {
+		return "", nil, err
+	}
+	defer r.Close()
+
+	tmp, err := os.CreateTemp("", "blob-*-"+path.Base(key))
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.Remove(tmp.Name()) }
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		cleanup()
+		return "", nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return tmp.Name(), cleanup, nil
+}
@@ -0,0 +1,244 @@
+package blobstore
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSign_AWSTestSuiteGetVanilla(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := credentials{accessKeyID: "AKIDEXAMPLE", secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	creds.sign(req, hashHex(nil), "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %s\nwant %s", got, want)
+	}
+}
+
+func readBlob(t *testing.T, s Store, key string) string {
+	t.Helper()
+	r, err := s.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", key, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func keys(objects []Object) string {
+	var names []string
+	for _, o := range objects {
+		names = append(names, o.Key)
+	}
+	return strings.Join(names, ",")
+}
+
+// testStore checks the behavior every Store shares.
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	for key, body := range map[string]string{
+		"scan-1/nmap_output.xml":       "<nmaprun/>",
+		"scan-1/a.example.com.png":     "png",
+		"scan-1/tool_logs/httpx.log":   "log",
+		"scan-10/nuclei_output.json":   "{}",
+		"scan-1/name with spaces+.txt": "odd",
+	} {
+		if err := s.Put(ctx, key, strings.NewReader(body)); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+
+	if got := readBlob(t, s, "scan-1/nmap_output.xml"); got != "<nmaprun/>" {
+		t.Errorf("Get = %q", got)
+	}
+	if got := readBlob(t, s, "scan-1/name with spaces+.txt"); got != "odd" {
+		t.Errorf("Get = %q", got)
+	}
+	if _, err := s.Get(ctx, "scan-1/missing.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get missing = %v, want ErrNotFound", err)
+	}
+
+	objects, err := s.List(ctx, "scan-1/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := keys(objects); got != "scan-1/a.example.com.png,scan-1/name with spaces+.txt,scan-1/nmap_output.xml,scan-1/tool_logs/httpx.log" {
+		t.Errorf("List = %s", got)
+	}
+
+	scan := Prefixed(s, "scan-1")
+	matched, err := Match(ctx, scan, "", "*.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := keys(matched); got != "a.example.com.png" {
+		t.Errorf("Match = %s", got)
+	}
+	if matched, _ := Match(ctx, scan, "tool_logs", "*.log"); keys(matched) != "tool_logs/httpx.log" {
+		t.Errorf("Match tool_logs = %s", keys(matched))
+	}
+
+	uri := scan.URI("a.example.com.png")
+	if key, ok := s.Key(uri); !ok || key != "scan-1/a.example.com.png" {
+		t.Errorf("Key(%s) = %s, %v", uri, key, ok)
+	}
+	if key, ok := scan.Key(uri); !ok || key != "a.example.com.png" {
+		t.Errorf("scoped Key(%s) = %s, %v", uri, key, ok)
+	}
+
+	file, cleanup, err := LocalCopy(ctx, scan, "nmap_output.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	if data, _ := os.ReadFile(file); string(data) != "<nmaprun/>" {
+		t.Errorf("LocalCopy holds %q", data)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	root := t.TempDir()
+	s := NewLocal(root)
+	testStore(t, s)
+
+	// local URIs are the relative paths scan records always held
+	if uri := Prefixed(s, "scan-1").URI("a.example.com.png"); uri != "scan-1/a.example.com.png" {
+		t.Errorf("URI = %s", uri)
+	}
+	// blobs are used in place
+	file, _, err := LocalCopy(context.Background(), Prefixed(s, "scan-1"), "nmap_output.xml")
+	if err != nil || !strings.HasPrefix(file, root) {
+		t.Errorf("LocalCopy = %s, %v; want a file under %s", file, err, root)
+	}
+	if _, err := s.Get(context.Background(), "../outside"); err == nil {
+		t.Error("Get escaped the root")
+	}
+	if _, ok := s.Key("scan-1/../../etc/passwd"); ok {
+		t.Error("Key accepted a path leaving the root")
+	}
+}
+
+func TestMemory(t *testing.T) {
+	s := NewMemory()
+	testStore(t, s)
+
+	// nothing is on disk, so LocalCopy downloads
+	if _, ok := LocalPath(Prefixed(s, "scan-1"), "nmap_output.xml"); ok {
+		t.Error("memory blobs have a local path")
+	}
+	if uri := Prefixed(s, "scan-1").URI("a.example.com.png"); uri != "scan-1/a.example.com.png" {
+		t.Errorf("URI = %s", uri)
+	}
+}
+
+// fakeS3 is an in-memory bucket speaking enough of the S3 API, with listing
+// pages of two keys.
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		f.t.Errorf("unsigned request %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != "artifacts" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPut:
+		if r.ContentLength < 0 {
+			f.t.Errorf("PUT %s without a length", key)
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = string(data)
+	case r.Method == http.MethodGet && key != "":
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, data)
+	case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		var names []string
+		for name := range f.objects {
+			if strings.HasPrefix(name, r.URL.Query().Get("prefix")) && name > r.URL.Query().Get("continuation-token") {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		var page listBucketResult
+		if len(names) > 2 {
+			page.IsTruncated = true
+			page.NextContinuationToken = names[1]
+			names = names[:2]
+		}
+		for _, name := range names {
+			page.Contents = append(page.Contents, struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			}{name, int64(len(f.objects[name])), time.Now()})
+		}
+		xml.NewEncoder(w).Encode(page)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestS3(t *testing.T) {
+	fake := &fakeS3{t: t, objects: make(map[string]string)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	s, err := NewS3(S3Config{Bucket: "artifacts", Prefix: "/pipeliner/", Endpoint: server.URL, AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+
+	if _, ok := fake.objects["pipeliner/scan-1/nmap_output.xml"]; !ok {
+		t.Error("objects are not kept under the prefix")
+	}
+	if uri := Prefixed(s, "scan-1").URI("a.example.com.png"); uri != "s3://artifacts/pipeliner/scan-1/a.example.com.png" {
+		t.Errorf("URI = %s", uri)
+	}
+	if _, ok := s.Key("s3://other/pipeliner/scan-1/a.example.com.png"); ok {
+		t.Error("Key accepted another bucket")
+	}
+}
+
+func TestNewS3_RequiresBucketAndCredentials(t *testing.T) {
+	if _, err := NewS3(S3Config{AccessKeyID: "AKID", SecretAccessKey: "secret"}); err == nil {
+		t.Error("accepted a config without bucket")
+	}
+	if _, err := NewS3(S3Config{Bucket: "artifacts"}); err == nil {
+		t.Error("accepted a config without credentials")
+	}
+}
@@ -0,0 +1,206 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// S3Config locates a bucket and the credentials to reach it. Endpoint is
+// for S3-compatible services such as MinIO, which are addressed path-style;
+// without it the bucket is reached at AWS.
+type S3Config struct {
+	Bucket   string
+	Prefix   string
+	Region   string
+	Endpoint string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// S3 keeps blobs in a bucket, under Prefix. Its URIs are s3://bucket/key.
+type S3 struct {
+	bucket   string
+	prefix   string
+	region   string
+	endpoint *url.URL // nil for AWS
+	creds    credentials
+	client   *http.Client
+	now      func() time.Time
+}
+
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3 bucket is required")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("s3 access key id and secret access key are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	s := &S3{
+		bucket: cfg.Bucket,
+		region: cfg.Region,
+		creds:  credentials{accessKeyID: cfg.AccessKeyID, secretAccessKey: cfg.SecretAccessKey, sessionToken: cfg.SessionToken},
+		client: &http.Client{Timeout: 5 * time.Minute},
+		now:    time.Now,
+	}
+	if prefix := strings.Trim(cfg.Prefix, "/"); prefix != "" {
+		s.prefix = prefix + "/"
+	}
+	if cfg.Endpoint != "" {
+		endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+		if err != nil || endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+		}
+		s.endpoint = endpoint
+	}
+	return s, nil
+}
+
+// url is the address of an object, or of the bucket for "".
+func (s *S3) url(object string) *url.URL {
+	if s.endpoint != nil {
+		u := *s.endpoint
+		u.Path += "/" + s.bucket
+		if object != "" {
+			u.Path += "/" + object
+		}
+		return &u
+	}
+	return &url.URL{Scheme: "https", Host: s.bucket + ".s3." + s.region + ".amazonaws.com", Path: "/" + object}
+}
+
+// do signs and sends a request, returning an error for any status other
+// than 2xx.
+func (s *S3) do(ctx context.Context, method string, u *url.URL, body io.Reader, size int64) (*http.Response, error) {
+	u.RawPath = escapePath(u.Path)
+	u.RawQuery = canonicalQuery(u.Query())
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	s.creds.sign(req, unsignedPayload, s.region, "s3", s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, u.Path)
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return nil, fmt.Errorf("s3 %s %s: %s: %s", method, u.Path, resp.Status, strings.TrimSpace(string(detail)))
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader) error {
+	// S3 wants the length up front
+	size := int64(-1)
+	switch v := r.(type) {
+	case *os.File:
+		if info, err := v.Stat(); err == nil {
+			size = info.Size()
+		}
+	case interface{ Len() int }:
+		size = int64(v.Len())
+	}
+	if size < 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		r, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, s.url(s.prefix+key), r, size)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.url(s.prefix+key), nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		u := s.url("")
+		query := url.Values{"list-type": {"2"}, "prefix": {s.prefix + prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u.RawQuery = query.Encode()
+
+		resp, err := s.do(ctx, http.MethodGet, u, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		var page listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode s3 listing: %w", err)
+		}
+
+		for _, c := range page.Contents {
+			objects = append(objects, Object{
+				Key:     strings.TrimPrefix(c.Key, s.prefix),
+				Size:    c.Size,
+				ModTime: c.LastModified.UnixNano(),
+			})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (s *S3) URI(key string) string {
+	return "s3://" + s.bucket + "/" + s.prefix + key
+}
+
+func (s *S3) Key(uri string) (string, bool) {
+	rest, ok := strings.CutPrefix(uri, "s3://"+s.bucket+"/"+s.prefix)
+	if !ok || rest == "" {
+		return "", false
+	}
+	return rest, true
+}
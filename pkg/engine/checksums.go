@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pipeliner/internal/notification"
+	perrors "pipeliner/pkg/errors"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/tools"
+)
+
+// checkBinaries is the preflight for pinned binaries: the global allowlist
+// and the module's checksums are merged, and every pinned tool command must
+// match before the scan starts.
+func (e *PiplinerEngine) checkBinaries(chainConfig *tools.ChainConfig) error {
+	pins, err := tools.ChecksumAllowlist()
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", tools.ChecksumsFileEnv, err)
+	}
+	if len(chainConfig.Checksums) > 0 && pins == nil {
+		pins = make(map[string]string, len(chainConfig.Checksums))
+	}
+	for command, sum := range chainConfig.Checksums {
+		pins[command] = sum
+	}
+	if len(pins) == 0 {
+		e.verifier = nil
+		return nil
+	}
+
+	e.verifier = tools.NewBinaryVerifier(pins)
+	e.verifier.OnMismatch = e.reportMismatch
+	for _, tc := range chainConfig.Tools {
+		if err := e.verifier.Verify(tc.Command); err != nil {
+			return err
+		}
+	}
+	e.logger.Info("Pinned tool binaries verified", logger.Fields{"pins": len(pins)})
+	return nil
+}
+
+// verifiedContext hands the runners a verifier that also cancels ctx, so a
+// binary swapped mid-scan stops the remaining tools.
+func (e *PiplinerEngine) verifiedContext(ctx context.Context) (context.Context, context.CancelCauseFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	if e.verifier == nil {
+		return ctx, cancel
+	}
+	verifier := *e.verifier
+	verifier.OnMismatch = func(err *perrors.ChecksumMismatchError) {
+		e.reportMismatch(err)
+		cancel(err)
+	}
+	return tools.WithBinaryVerifier(ctx, &verifier), cancel
+}
+
+// mismatchCause is the checksum error that stopped ctx, if any.
+func mismatchCause(ctx context.Context) error {
+	if cause := context.Cause(ctx); errors.Is(cause, perrors.ErrChecksumMismatch) {
+		return cause
+	}
+	return nil
+}
+
+func (e *PiplinerEngine) reportMismatch(err *perrors.ChecksumMismatchError) {
+	e.logger.Error("Binary checksum mismatch", logger.Fields{
+		"command":  err.Command,
+		"path":     err.Path,
+		"expected": err.Expected,
+		"actual":   err.Actual,
+	})
+	if e.notifier == nil {
+		return
+	}
+	msg := notification.Message{
+		Title:       "Binary checksum mismatch",
+		Description: "A pinned tool binary changed; the scan was aborted.",
+		Severity:    "critical",
+		EventType:   notification.EventScanLifecycle,
+		Fields: map[string]string{
+			"Command":  err.Command,
+			"Path":     err.Path,
+			"Expected": err.Expected,
+			"Actual":   err.Actual,
+		},
+	}
+	if sendErr := e.notifier.Send(msg); sendErr != nil {
+		e.logger.Warn("Failed to send checksum alert", logger.Fields{"error": sendErr})
+	}
+}
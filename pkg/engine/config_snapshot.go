@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"pipeliner/pkg/errors"
+	"pipeliner/pkg/logger"
+
+	"github.com/spf13/viper"
+)
+
+// configSnapshot is the module file a scan's config was parsed from, with
+// its checksum at the time. Every run of the scan, periodic ones included,
+// uses the config parsed then; edits take effect with the next scan.
+type configSnapshot struct {
+	path     string
+	checksum string
+	// reported is the last differing checksum logged, so each edit is
+	// logged once
+	reported string
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// readConfigSnapshot parses the module file config found again from the
+// bytes it checksums, so the snapshot matches what was parsed even if the
+// file is being saved right then.
+func readConfigSnapshot(config *viper.Viper) (configSnapshot, error) {
+	path := config.ConfigFileUsed()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return configSnapshot{}, fmt.Errorf("%w: error reading config file %s: %w", errors.ErrInvalidConfig, path, err)
+	}
+	if err := config.ReadConfig(bytes.NewReader(data)); err != nil {
+		return configSnapshot{}, fmt.Errorf("%w: error reading config file %s: %w", errors.ErrInvalidConfig, path, err)
+	}
+	return configSnapshot{path: path, checksum: checksum(data)}, nil
+}
+
+// checkConfigSnapshot logs when the module file on disk no longer matches
+// the config the scan runs with, so operators know a new scan or a restart
+// is needed for the change.
+func (e *PiplinerEngine) checkConfigSnapshot() {
+	snapshot := &e.configSnapshot
+	if snapshot.path == "" {
+		return
+	}
+	current := "missing"
+	if data, err := os.ReadFile(snapshot.path); err == nil {
+		current = checksum(data)
+	}
+	if current == snapshot.checksum || current == snapshot.reported {
+		return
+	}
+	snapshot.reported = current
+	e.logger.Warn("Module config changed on disk since the scan started; runs keep the config loaded then, the change applies to the next scan", logger.Fields{
+		"config":   snapshot.path,
+		"loaded":   snapshot.checksum,
+		"on_disk":  current,
+		"scan_dir": e.scanDir,
+	})
+}
+
+// ConfigChecksum is the sha256 of the module file the scan's config was
+// parsed from, empty before PrepareScan.
+func (e *PiplinerEngine) ConfigChecksum() string {
+	return e.configSnapshot.checksum
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pipeliner/internal/utils"
+	"pipeliner/pkg/tools"
+)
+
+func TestPeriodicRunsUseConfigSnapshot(t *testing.T) {
+	moduleDir := t.TempDir()
+	modulePath := filepath.Join(moduleDir, "snapshot.yaml")
+	module := []byte("execution_mode: sequential\ntools:\n  - name: subfinder\n    command: subfinder\n")
+	if err := os.WriteFile(modulePath, module, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	commands := &commandRecorder{}
+	eng, err := NewPiplinerEngine(WithContext(ctx), WithRunner(commands), WithPeriodic(1), WithModuleOrigin(utils.ModuleOrigin{Dir: moduleDir}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	eng.logger.SetOutput(&logs)
+	eng.scanDir = t.TempDir()
+	options := tools.DefaultOptions()
+	options.ScanType = "snapshot"
+	options.Domain = "example.com"
+	if err := eng.PrepareScan(options); err != nil {
+		t.Fatal(err)
+	}
+	if eng.ConfigChecksum() != checksum(module) {
+		t.Fatalf("checksum = %s, want the module file's", eng.ConfigChecksum())
+	}
+
+	first := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	if err := eng.runIteration(first); err != nil {
+		t.Fatal(err)
+	}
+
+	// an edit mid-scan, saved halfway
+	if err := os.WriteFile(modulePath, []byte("execution_mode: sequential\ntools:\n  - name: httpx\n    comm"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		if err := eng.runIteration(first.Add(time.Duration(i) * time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := strings.Join(commands.commands, ","); got != "subfinder,subfinder,subfinder" {
+		t.Errorf("ran %s, want the snapshot's subfinder on every tick", got)
+	}
+	if n := strings.Count(logs.String(), "Module config changed on disk"); n != 1 {
+		t.Errorf("logged the change %d times, want once:\n%s", n, logs.String())
+	}
+}
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"pipeliner/internal/notification"
@@ -9,6 +10,7 @@ import (
 	output "pipeliner/pkg/io_utils"
 	"pipeliner/pkg/logger"
 	"pipeliner/pkg/runner"
+	"pipeliner/pkg/scoring"
 	"pipeliner/pkg/tools"
 	"time"
 
@@ -21,10 +23,37 @@ type EnginePiplinerOpts struct {
 	options  *tools.Options
 	config   *viper.Viper
 	runner   tools.CommandRunner
-	periodic int
-	notifier *notification.NotificationClient
+	notifier notification.Notifier
 	scanDir  string
 	logger   *logger.Logger
+
+	// chainConfig is decoded from config by PrepareScan and kept for every
+	// run of the scan
+	chainConfig *tools.ChainConfig
+	// configSnapshot is the module file chainConfig came from
+	configSnapshot configSnapshot
+	// moduleOrigin is where PrepareScan loaded the module from
+	moduleOrigin utils.ModuleOrigin
+	// releaseModule lets moduleOrigin's checkout be pruned again; nil
+	// until PrepareScan holds it
+	releaseModule func()
+	// verifier checks pinned binaries; nil when nothing is pinned
+	verifier *tools.BinaryVerifier
+	// templatesCache holds templates_ref checkouts; DefaultTemplatesCache when nil
+	templatesCache *TemplatesCache
+	// retryFailed, when set, runs only these tools and the dependencies
+	// they still need, in the existing scanDir
+	retryFailed []string
+	// resume skips, on the first run, every tool whose output is already
+	// in scanDir
+	resume bool
+	// estimate runs only the module's estimate tools, without hooks
+	estimate bool
+
+	// schedule starts the runs after the first; nil runs the scan once
+	schedule     Schedule
+	scheduleSpec string
+	clock        Clock
 }
 
 type OptFunc func(*EnginePiplinerOpts)
@@ -42,6 +71,17 @@ func NewPiplinerEngine(optFuncs ...OptFunc) (*PiplinerEngine, error) {
 		optFunc(&engineOpts)
 	}
 
+	if engineOpts.scheduleSpec != "" {
+		schedule, err := ParseSchedule(engineOpts.scheduleSpec)
+		if err != nil {
+			return nil, err
+		}
+		engineOpts.schedule = schedule
+	}
+	if engineOpts.clock == nil {
+		engineOpts.clock = realClock{}
+	}
+
 	if engineOpts.runner == nil {
 		baseRunner := runner.NewSimpleRunner()
 		engineOpts.runner = runner.NewReplacementCommandRunner(baseRunner)
@@ -69,13 +109,25 @@ func WithOptions(options *tools.Options) OptFunc {
 	}
 }
 
+// WithPeriodic runs the scan again every period hours; 0 runs it once.
 func WithPeriodic(period int) OptFunc {
 	return func(epo *EnginePiplinerOpts) {
-		epo.periodic = period
+		epo.schedule = nil
+		if period > 0 {
+			epo.schedule = Every(time.Duration(period) * time.Hour)
+		}
+	}
+}
+
+// WithSchedule runs the scan again as spec says, an interval or a cron
+// expression read by ParseSchedule. It replaces WithPeriodic.
+func WithSchedule(spec string) OptFunc {
+	return func(epo *EnginePiplinerOpts) {
+		epo.scheduleSpec = spec
 	}
 }
 
-func WithNotificationClient(client *notification.NotificationClient) OptFunc {
+func WithNotificationClient(client notification.Notifier) OptFunc {
 	return func(opts *EnginePiplinerOpts) {
 		opts.notifier = client
 	}
@@ -87,7 +139,42 @@ func WithContext(ctx context.Context) OptFunc {
 	}
 }
 
-func (e *PiplinerEngine) PrepareScan(options *tools.Options) error {
+// WithModuleOrigin loads the module from origin instead of the current
+// module directory.
+func WithModuleOrigin(origin utils.ModuleOrigin) OptFunc {
+	return func(opts *EnginePiplinerOpts) {
+		opts.moduleOrigin = origin
+	}
+}
+
+// WithRetryFailed runs the failed tools of a finished scan again in its
+// directory, along with any dependency of theirs whose output is missing.
+func WithRetryFailed(scanDir string, failed []string) OptFunc {
+	return func(opts *EnginePiplinerOpts) {
+		opts.scanDir = scanDir
+		opts.retryFailed = failed
+	}
+}
+
+// WithResume continues an interrupted scan in scanDir, skipping the tools
+// whose output it already holds.
+func WithResume(scanDir string) OptFunc {
+	return func(opts *EnginePiplinerOpts) {
+		opts.scanDir = scanDir
+		opts.resume = true
+	}
+}
+
+// WithEstimate runs only the module's tools tagged estimate, in scanDir
+// and without post or stage hooks, to size a scan before starting it.
+func WithEstimate(scanDir string) OptFunc {
+	return func(opts *EnginePiplinerOpts) {
+		opts.scanDir = scanDir
+		opts.estimate = true
+	}
+}
+
+func (e *PiplinerEngine) PrepareScan(options *tools.Options) (err error) {
 	if options == nil {
 		return fmt.Errorf("options cannot be nil")
 	}
@@ -95,27 +182,82 @@ func (e *PiplinerEngine) PrepareScan(options *tools.Options) error {
 	e.options.Logger = e.logger
 
 	if e.options.ScanType != "" {
-		var err error
-		e.config, err = utils.NewViperConfig(e.options.ScanType)
+		origin := e.moduleOrigin
+		if origin.Dir == "" {
+			origin = utils.CurrentModuleOrigin()
+		}
+		// the hooks run scripts from the checkout, so it is kept until
+		// Cleanup
+		e.releaseModule = utils.HoldModuleOrigin(origin)
+		defer func() {
+			if err != nil {
+				e.release()
+			}
+		}()
+		config, chainConfig, snapshot, err := loadModuleConfig(origin, e.options.ScanType)
 		if err != nil {
 			e.logger.Error("Failed to load config", logger.Fields{"error": err})
-			return errors.ErrInvalidConfig
+			return err
+		}
+		e.config = config
+		e.chainConfig = chainConfig
+		e.configSnapshot = snapshot
+		e.moduleOrigin = origin
+		e.options.HTTPHeaders = tools.MergeHeaders(chainConfig.HTTPHeaders, e.options.HTTPHeaders)
+		if len(chainConfig.SeverityRules) > 0 {
+			policy, err := e.options.SeverityPolicy.With(chainConfig.SeverityRules)
+			if err != nil {
+				e.logger.Error("Invalid severity rules", logger.Fields{"error": err})
+				return err
+			}
+			e.options.SeverityPolicy = policy
+		}
+
+		if err := e.checkBinaries(chainConfig); err != nil {
+			e.logger.Error("Binary verification failed", logger.Fields{"error": err})
+			return err
 		}
-		err = utils.ValidateConfig(e.config)
+
+		subprocessHooks, err := tools.NewSubprocessHooks(chainConfig.SubprocessHooks, origin.Dir)
 		if err != nil {
-			e.logger.Error("Failed to validate config", logger.Fields{"error": err})
-			return errors.ErrInvalidConfig
+			e.logger.Error("Invalid subprocess hook", logger.Fields{"error": err})
+			return err
 		}
+		e.options.SubprocessHooks = subprocessHooks
 
-		dir, err := utils.CreateScanDirectory(e.options.ScanType, e.options.Domain)
+		stageHooks, err := tools.NewConfiguredHooks(chainConfig.Hooks)
 		if err != nil {
-			e.logger.Error("Failed to create scan directory", logger.Fields{"error": err})
-			return fmt.Errorf("failed to create scan directory: %w", err)
+			e.logger.Error("Invalid module hook", logger.Fields{"error": err})
+			return err
+		}
+		e.options.StageHooks = stageHooks
+
+		if err := e.prepareProxy(chainConfig); err != nil {
+			e.logger.Error("Proxy check failed", logger.Fields{"error": err})
+			return err
+		}
+
+		if err := e.prepareTemplates(chainConfig); err != nil {
+			e.logger.Error("Failed to prepare nuclei templates", logger.Fields{"error": err})
+			return err
+		}
+
+		dir := e.scanDir
+		if dir == "" {
+			dir, err = utils.CreateScanDirectory(e.options.ScanType, e.options.Domain)
+			if err != nil {
+				e.logger.Error("Failed to create scan directory", logger.Fields{"error": err})
+				return fmt.Errorf("failed to create scan directory: %w", err)
+			}
+			e.scanDir = dir
 		}
-		e.scanDir = dir
 		e.options.WorkingDir = dir
+		e.prepareRunAs(dir, chainConfig.Tools)
 
-		go output.WatchDirectoryWithPath(e.ctx, dir)
+		// a dry run writes no output to watch
+		if !e.options.DryRun {
+			go output.WatchDirectoryWithPath(e.ctx, dir)
+		}
 	}
 	return nil
 }
@@ -149,63 +291,149 @@ func (e *PiplinerEngine) RunHTTP(scanType, domain string) (err error) {
 	return nil
 }
 
-func (e *PiplinerEngine) Run() error {
-	ticker := time.NewTicker(time.Hour * time.Duration(e.periodic))
-	defer ticker.Stop()
+// Cleanup runs the module's cleanup hooks. They get their own
+// DefaultCleanupTimeout that cancelling the scan does not cut short.
+// Afterwards the module's git checkout may be pruned.
+func (e *PiplinerEngine) Cleanup() error {
+	defer e.release()
+	if e.chainConfig == nil || len(e.chainConfig.Cleanup) == 0 {
+		return nil
+	}
+	if e.options != nil && e.options.DryRun {
+		for _, hook := range e.chainConfig.Cleanup {
+			e.logger.Info("Dry run: would run cleanup hook", logger.Fields{"hook": hook.Hook})
+		}
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(e.ctx), tools.DefaultCleanupTimeout)
+	defer cancel()
+	return tools.ExecuteCleanupHooks(ctx, e.chainConfig.Cleanup, e.options)
+}
+
+// release lets the module's checkout be pruned again.
+func (e *PiplinerEngine) release() {
+	if e.releaseModule != nil {
+		e.releaseModule()
+		e.releaseModule = nil
+	}
+}
 
+func (e *PiplinerEngine) Run() error {
 	e.logger.Info("Starting Pipeliner Engine")
-	if err := e.runTools(); err != nil {
+	if err := e.runIteration(e.clock.Now()); err != nil {
 		e.logger.Error("Initial tool run failed", logger.Fields{"error": err})
 		return fmt.Errorf("initial tool run failed: %w", err)
 	}
+	if e.options.DryRun || e.schedule == nil {
+		return nil
+	}
+	// later runs start over
+	e.resume = false
 
 	for {
+		// runs are back to back at most: starts that fell while the last
+		// run was still going are skipped
+		now := e.clock.Now()
+		next := e.schedule.Next(now)
+		if next.IsZero() {
+			e.logger.Info("Schedule has no more runs, stopping", logger.Fields{"schedule": e.schedule.String()})
+			return nil
+		}
+		e.logger.Info("Next periodic run scheduled", logger.Fields{"schedule": e.schedule.String(), "next_run": next.Format(time.RFC3339)})
+
 		select {
 		case <-e.ctx.Done():
 			e.logger.Info("Stopping Pipeliner Engine")
 			return nil
-		case <-ticker.C:
+		case <-e.clock.After(next.Sub(now)):
 			e.logger.Info("Running periodic pipeline")
-			if err := e.runTools(); err != nil {
+			started := e.clock.Now()
+			if err := e.runIteration(started); err != nil {
 				e.logger.Error("Periodic pipeline failed", logger.Fields{"error": err})
 				return fmt.Errorf("periodic pipeline failed: %w", err)
 			}
+			if missed := e.schedule.Next(started); !missed.IsZero() && missed.Before(e.clock.Now()) {
+				e.logger.Warn("Skipping scheduled runs that started while the previous run was still going", logger.Fields{"missed_run": missed.Format(time.RFC3339)})
+			}
 		}
 	}
-
 }
 
 func (e *PiplinerEngine) runTools() error {
-	chainConfig := tools.ChainConfig{
-		ExecutionMode: e.config.GetString("execution_mode"),
-	}
-	if err := e.unmarshalConfig(&chainConfig); err != nil {
-		e.logger.Error("Failed to parse tool chain config", logger.Fields{"error": err})
-		return errors.ErrInvalidConfig
+	chainConfig := e.chainConfig
+	if chainConfig == nil {
+		chainConfig = &tools.ChainConfig{
+			ExecutionMode: e.config.GetString("execution_mode"),
+		}
+		if err := utils.DecodeConfig(e.config, chainConfig); err != nil {
+			e.logger.Error("Failed to parse tool chain config", logger.Fields{"error": err})
+			return err
+		}
+		// later runs reuse it rather than read the config again
+		e.chainConfig = chainConfig
 	}
+	e.checkConfigSnapshot()
 
 	e.logger.Info("Loaded tools from config", logger.Fields{"tool_count": len(chainConfig.Tools)})
 
-	toolInstances, err := e.createToolInstances(chainConfig.Tools)
+	toolConfigs := chainConfig.Tools
+	if e.retryFailed != nil {
+		toolConfigs, e.options.Satisfied = tools.RetryTools(chainConfig.Tools, e.retryFailed, e.scanDir)
+		e.logger.Info("Retrying failed tools", logger.Fields{"failed": e.retryFailed, "tool_count": len(toolConfigs)})
+	}
+	e.options.Resume = nil
+	if (e.resume || e.retryFailed != nil) && !e.options.DryRun {
+		e.options.Resume = e.interruptedRuns(toolConfigs)
+	}
+	if e.resume {
+		toolConfigs = e.resumeTools(toolConfigs)
+	}
+	if e.estimate {
+		toolConfigs = tools.EstimateTools(chainConfig.Tools)
+		e.options.SkipHooks = true
+		if len(toolConfigs) == 0 {
+			return fmt.Errorf("module %s has no tools tagged estimate", chainConfig.Name)
+		}
+		e.logger.Info("Running estimate tools", logger.Fields{"tool_count": len(toolConfigs)})
+	}
+
+	toolInstances, err := e.createToolInstances(chainConfig.Tools, toolConfigs)
 	if err != nil {
 		e.logger.Error("Failed to create tool instances", logger.Fields{"error": err})
 		return err
 	}
 
+	stageTimeouts := chainConfig.StageBudgets()
+	stageCooldowns := chainConfig.StageCooldowns()
+
 	var strategy tools.ExecutionStrategy
 	switch chainConfig.ExecutionMode {
 	case "concurrent":
 		e.logger.Info("Using concurrent execution strategy")
-		strategy = &tools.ConcurrentStrategy{}
+		strategy = &tools.ConcurrentStrategy{StageTimeouts: stageTimeouts, MaxConcurrency: chainConfig.MaxConcurrency}
 	case "hybrid":
 		e.logger.Info("Using hybrid execution strategy")
-		strategy = &tools.HybridStrategy{}
+		strategy = &tools.HybridStrategy{StageTimeouts: stageTimeouts, StageCooldowns: stageCooldowns, MaxConcurrency: chainConfig.MaxConcurrency}
 	default:
 		e.logger.Info("Using sequential execution strategy")
-		strategy = &tools.SequentialStrategy{}
+		strategy = &tools.SequentialStrategy{StageTimeouts: stageTimeouts, StageCooldowns: stageCooldowns}
+	}
+
+	ctx, cancel := e.verifiedContext(e.ctx)
+	defer cancel(nil)
+
+	if e.options.DryRun {
+		e.logDryRunHooks(chainConfig.PreRun, toolInstances)
+		e.options.SkipHooks = true
+	} else if err := tools.ExecutePreRunHooks(ctx, chainConfig.PreRun, e.options); err != nil {
+		e.logger.Error("Pre-run hooks failed", logger.Fields{"error": err})
+		return err
 	}
 
-	if err := strategy.Run(e.ctx, toolInstances, e.options); err != nil {
+	if err := strategy.Run(ctx, toolInstances, e.options); err != nil {
+		if mismatch := mismatchCause(ctx); mismatch != nil {
+			err = mismatch
+		}
 		e.logger.Error("Strategy execution failed", logger.Fields{"error": err})
 		return err
 	}
@@ -213,19 +441,125 @@ func (e *PiplinerEngine) runTools() error {
 	return nil
 }
 
-func (e *PiplinerEngine) unmarshalConfig(chainConfig *tools.ChainConfig) error {
-	if err := e.config.Unmarshal(chainConfig); err != nil {
-		e.logger.Error("Failed to parse tool chain config", logger.Fields{"error": err})
-		return errors.ErrInvalidConfig
+// logDryRunHooks lists the hooks a dry run leaves out.
+func (e *PiplinerEngine) logDryRunHooks(preRun []tools.PhaseHookConfig, toolInstances []tools.Tool) {
+	for _, hook := range preRun {
+		e.logger.Info("Dry run: would run pre-run hook", logger.Fields{"hook": hook.Hook})
 	}
-	return nil
+	for _, execution := range tools.PlanHookExecutions(toolInstances, e.options) {
+		e.logger.Info("Dry run: would run hook", logger.Fields{"hook": execution.Hook, "scope": execution.Scope, "target": execution.Target})
+	}
+}
+
+// ValidateModule loads and decodes the config for a scan module without
+// preparing a scan, so callers can reject a broken module up front.
+func ValidateModule(scanType string) error {
+	_, _, _, err := loadModuleConfig(utils.CurrentModuleOrigin(), scanType)
+	return err
 }
 
-func (e *PiplinerEngine) createToolInstances(toolConfigs []tools.ToolConfig) ([]tools.Tool, error) {
+// LoadModule returns the decoded tool chain of a scan module.
+func LoadModule(scanType string) (*tools.ChainConfig, error) {
+	_, chainConfig, _, err := loadModuleConfig(utils.CurrentModuleOrigin(), scanType)
+	return chainConfig, err
+}
+
+// LoadModuleAt returns the decoded tool chain of a scan module as origin
+// has it, such as the modules an earlier scan ran with.
+func LoadModuleAt(origin utils.ModuleOrigin, scanType string) (*tools.ChainConfig, error) {
+	_, chainConfig, _, err := loadModuleConfig(origin, scanType)
+	return chainConfig, err
+}
+
+// ValidateModuleSource checks module YAML that has not been written to the
+// config directory yet. name only labels the errors.
+func ValidateModuleSource(name string, data []byte) error {
+	config := viper.New()
+	config.SetConfigType("yaml")
+	if err := config.ReadConfig(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("%w: %s: %w", errors.ErrInvalidConfig, name, err)
+	}
+	_, err := decodeModuleConfig(config, name)
+	return err
+}
+
+func loadModuleConfig(origin utils.ModuleOrigin, scanType string) (*viper.Viper, *tools.ChainConfig, configSnapshot, error) {
+	config, err := utils.NewModuleConfig(origin, scanType)
+	if err != nil {
+		return nil, nil, configSnapshot{}, err
+	}
+	snapshot, err := readConfigSnapshot(config)
+	if err != nil {
+		return nil, nil, configSnapshot{}, err
+	}
+	chainConfig, err := decodeModuleConfig(config, snapshot.path)
+	if err != nil {
+		return nil, nil, configSnapshot{}, err
+	}
+	return config, chainConfig, snapshot, nil
+}
+
+func decodeModuleConfig(config *viper.Viper, source string) (*tools.ChainConfig, error) {
+	if err := utils.ValidateConfig(config); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", errors.ErrInvalidConfig, source, err)
+	}
+
+	chainConfig := &tools.ChainConfig{
+		ExecutionMode: config.GetString("execution_mode"),
+	}
+	if err := utils.DecodeConfigNamed(config, chainConfig, source); err != nil {
+		return nil, err
+	}
+	if err := chainConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", errors.ErrInvalidConfig, source, err)
+	}
+	return chainConfig, nil
+}
+
+// createToolInstances creates the tools to run. All of the module's tools
+// are registered so dependencies that are not run again still resolve.
+// resumeTools turns on skip_if_output_exists for every tool but those
+// continuing an interrupted run, and logs the ones that will be skipped.
+func (e *PiplinerEngine) resumeTools(toolConfigs []tools.ToolConfig) []tools.ToolConfig {
+	resumed := make([]tools.ToolConfig, len(toolConfigs))
+	var skipped []string
+	for i, config := range toolConfigs {
+		if _, interrupted := e.options.Resume[config.Name]; !interrupted {
+			config.SkipIfOutputExists = true
+			if tools.OutputExists(config, e.options.WorkingDir) {
+				skipped = append(skipped, config.Name)
+			}
+		}
+		resumed[i] = config
+	}
+	e.logger.Info("Resuming scan", logger.Fields{"dir": e.scanDir, "skipped": skipped, "tool_count": len(toolConfigs) - len(skipped)})
+	return resumed
+}
+
+// interruptedRuns finds the tools with resume_support whose state file
+// shows an unfinished run, which continue from it instead of starting
+// over.
+func (e *PiplinerEngine) interruptedRuns(toolConfigs []tools.ToolConfig) map[string]string {
+	var resume map[string]string
+	for _, config := range toolConfigs {
+		stateFile, ok := tools.InterruptedRun(config, e.options.WorkingDir)
+		if !ok {
+			continue
+		}
+		if resume == nil {
+			resume = make(map[string]string)
+		}
+		resume[config.Name] = stateFile
+		e.logger.Info("Continuing interrupted tool run", logger.Fields{"tool": config.Name, "state_file": stateFile})
+	}
+	return resume
+}
+
+func (e *PiplinerEngine) createToolInstances(moduleTools, toolConfigs []tools.ToolConfig) ([]tools.Tool, error) {
 	var toolInstances []tools.Tool
 
 	registry := tools.NewSimpleToolRegistry()
-	for _, toolConfig := range toolConfigs {
+	for _, toolConfig := range moduleTools {
 		registry.RegisterTool(toolConfig)
 	}
 
@@ -239,7 +573,7 @@ func (e *PiplinerEngine) createToolInstances(toolConfigs []tools.ToolConfig) ([]
 			}
 		}
 
-		tool := tools.NewConfigurableToolWithRegistry(toolConfig.Name, toolConfig.Type, toolConfig, e.runner, registry)
+		tool := tools.NewConfigurableToolWithRegistryAndLogger(toolConfig.Name, toolConfig.Type, toolConfig, e.runner, registry, e.logger)
 		toolInstances = append(toolInstances, tool)
 	}
 	return toolInstances, nil
@@ -249,6 +583,47 @@ func (e *PiplinerEngine) GetOptions() *tools.Options {
 	return e.options
 }
 
+// ModuleOrigin reports the source and revision of the module PrepareScan
+// loaded.
+func (e *PiplinerEngine) ModuleOrigin() utils.ModuleOrigin {
+	return e.moduleOrigin
+}
+
+// HTTPHeaders are the headers the scan's HTTP tools send, with the values
+// of the module's redact_headers hidden.
+// SeverityPolicy is the scan's global severity rules followed by its
+// module's.
+func (e *PiplinerEngine) SeverityPolicy() *scoring.Policy {
+	if e.options == nil {
+		return nil
+	}
+	return e.options.SeverityPolicy
+}
+
+func (e *PiplinerEngine) HTTPHeaders() map[string]string {
+	if e.options == nil {
+		return nil
+	}
+	var redact []string
+	if e.chainConfig != nil {
+		redact = e.chainConfig.RedactHeaders
+	}
+	return tools.RedactHeaders(e.options.HTTPHeaders, redact)
+}
+
+// Tools are the tools of the module PrepareScan loaded.
+func (e *PiplinerEngine) Tools() []tools.ToolConfig {
+	if e.chainConfig == nil {
+		return nil
+	}
+	return e.chainConfig.Tools
+}
+
+// Logger is the logger the engine and its tools write to.
+func (e *PiplinerEngine) Logger() *logger.Logger {
+	return e.logger
+}
+
 func (e *PiplinerEngine) ScanDirectory() string {
 	return e.scanDir
 }
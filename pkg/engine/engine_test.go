@@ -1,8 +1,29 @@
 package engine
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"testing"
 	"time"
+
+	"pipeliner/pkg/errors"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/tools"
+
+	"github.com/sirupsen/logrus"
 )
 
 // MockNotifier implements a DomainNotifier for testing
@@ -43,6 +64,203 @@ func (m *MockNotifier) WaitForNotifications(count int, timeout time.Duration) bo
 	return true
 }
 
+// failingRunner fails every command listed in fail and succeeds otherwise.
+type failingRunner struct {
+	fail map[string]error
+}
+
+func (r *failingRunner) Run(ctx context.Context, command string, args []string) error {
+	return r.fail[command]
+}
+
+func TestRunHTTP_PreservesPartialExecutionError(t *testing.T) {
+	runner := &failingRunner{fail: map[string]error{
+		"httpx":  fmt.Errorf("httpx crashed"),
+		"nuclei": errors.NewStageTimeoutError("vuln_scan", time.Minute, context.DeadlineExceeded),
+	}}
+
+	eng, err := NewPiplinerEngine(WithRunner(runner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eng.options = tools.DefaultOptions()
+	eng.scanDir = t.TempDir()
+	eng.chainConfig = &tools.ChainConfig{
+		ExecutionMode: "sequential",
+		Tools: []tools.ToolConfig{
+			{Name: "subfinder", Command: "subfinder"},
+			{Name: "httpx", Command: "httpx"},
+			{Name: "nuclei", Command: "nuclei"},
+		},
+	}
+
+	err = eng.RunHTTP("test", "example.com")
+
+	var partial *tools.PartialExecutionError
+	if !stderrors.As(err, &partial) {
+		t.Fatalf("expected PartialExecutionError through RunHTTP, got %v", err)
+	}
+	if len(partial.FailedTools) != 2 || partial.FailedTools[0].Tool != "httpx" || partial.FailedTools[1].Tool != "nuclei" {
+		t.Errorf("unexpected failed tools: %+v", partial.FailedTools)
+	}
+	if !stderrors.Is(err, errors.ErrStageTimeout) {
+		t.Errorf("expected errors.Is to find ErrStageTimeout in %v", err)
+	}
+}
+
+func TestCreateToolInstances_MissingCommandIsInvalidConfig(t *testing.T) {
+	eng, err := NewPiplinerEngine(WithRunner(&failingRunner{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configs := []tools.ToolConfig{{Name: "subfinder"}}
+	_, err = eng.createToolInstances(configs, configs)
+	if !stderrors.Is(err, errors.ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+// swappingRunner checks binaries like SimpleRunner and replaces the binary
+// after its first run.
+type swappingRunner struct {
+	path string
+	runs int
+}
+
+func (r *swappingRunner) Run(ctx context.Context, command string, args []string) error {
+	if err := tools.BinaryVerifierFromContext(ctx).Verify(command); err != nil {
+		return err
+	}
+	r.runs++
+	if r.runs == 1 {
+		if err := os.WriteFile(r.path, []byte("#!/bin/sh\necho swapped\n"), 0755); err != nil {
+			return err
+		}
+		return os.Chtimes(r.path, time.Now(), time.Now().Add(time.Minute))
+	}
+	return nil
+}
+
+func TestChecksums_MismatchAbortsScan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scanner")
+	content := []byte("#!/bin/sh\necho ok\n")
+	if err := os.WriteFile(path, content, 0755); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+
+	runner := &swappingRunner{path: path}
+	eng, err := NewPiplinerEngine(WithRunner(runner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eng.options = tools.DefaultOptions()
+	eng.scanDir = t.TempDir()
+	eng.chainConfig = &tools.ChainConfig{
+		ExecutionMode: "sequential",
+		Checksums:     map[string]string{path: hex.EncodeToString(sum[:])},
+		Tools: []tools.ToolConfig{
+			{Name: "first", Command: path},
+			{Name: "second", Command: path, DependsOn: []string{"first"}},
+			{Name: "third", Command: path, DependsOn: []string{"second"}},
+		},
+	}
+
+	if err := eng.checkBinaries(eng.chainConfig); err != nil {
+		t.Fatalf("preflight should pass before the swap: %v", err)
+	}
+
+	err = eng.RunHTTP("test", "example.com")
+	if !stderrors.Is(err, errors.ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+	if runner.runs != 1 {
+		t.Errorf("expected the scan to stop after the swap, ran %d times", runner.runs)
+	}
+
+	if err := eng.checkBinaries(eng.chainConfig); !stderrors.Is(err, errors.ErrChecksumMismatch) {
+		t.Errorf("expected the preflight to reject the swapped binary, got %v", err)
+	}
+}
+
+type brokenVPNHook struct{}
+
+func (h *brokenVPNHook) Name() string                        { return "vpn_up" }
+func (h *brokenVPNHook) Description() string                 { return "fails to connect" }
+func (h *brokenVPNHook) Execute(ctx tools.HookContext) error { return fmt.Errorf("tunnel down") }
+
+func TestRunHTTP_PreRunFailureRunsNoTools(t *testing.T) {
+	tools.RegisterPostHook("test-engine-vpn-up", &brokenVPNHook{})
+
+	runner := &swappingRunner{path: filepath.Join(t.TempDir(), "unused")}
+	eng, err := NewPiplinerEngine(WithRunner(runner))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var executions []tools.HookExecution
+	eng.options = tools.DefaultOptions()
+	eng.options.OnHookExecution = func(exec tools.HookExecution) { executions = append(executions, exec) }
+	eng.scanDir = t.TempDir()
+	eng.chainConfig = &tools.ChainConfig{
+		ExecutionMode: "sequential",
+		PreRun:        []tools.PhaseHookConfig{{Hook: "test-engine-vpn-up", Timeout: time.Second}},
+		Tools:         []tools.ToolConfig{{Name: "subfinder", Command: "subfinder"}},
+	}
+
+	err = eng.RunHTTP("test", "example.com")
+	if !stderrors.Is(err, errors.ErrPreRunHookFailed) {
+		t.Fatalf("expected ErrPreRunHookFailed, got %v", err)
+	}
+	if runner.runs != 0 {
+		t.Errorf("expected no tool to run, ran %d", runner.runs)
+	}
+	if len(executions) != 1 || executions[0].Status != tools.HookStatusFailed || executions[0].Scope != tools.HookScopePreRun {
+		t.Errorf("unexpected hook executions: %+v", executions)
+	}
+}
+
+type contextCheckHook struct {
+	ran    bool
+	ctxErr error
+}
+
+func (h *contextCheckHook) Name() string        { return "context_check" }
+func (h *contextCheckHook) Description() string { return "records the context it ran with" }
+func (h *contextCheckHook) Execute(ctx tools.HookContext) error {
+	h.ran = true
+	h.ctxErr = ctx.Context().Err()
+	return nil
+}
+
+func TestCleanup_RunsAfterCancelledScan(t *testing.T) {
+	hook := &contextCheckHook{}
+	tools.RegisterPostHook("test-engine-cleanup", hook)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	eng, err := NewPiplinerEngine(WithContext(ctx), WithRunner(&failingRunner{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eng.options = tools.DefaultOptions()
+	eng.chainConfig = &tools.ChainConfig{
+		Cleanup: []tools.PhaseHookConfig{{Hook: "test-engine-cleanup"}},
+	}
+
+	if err := eng.Cleanup(); err != nil {
+		t.Fatalf("cleanup failed: %v", err)
+	}
+	if !hook.ran {
+		t.Fatal("cleanup hook did not run")
+	}
+	if hook.ctxErr != nil {
+		t.Errorf("cleanup hook got the cancelled scan context: %v", hook.ctxErr)
+	}
+}
+
 // func TestNotificationSending(t *testing.T) {
 // 	// Create mock notifier
 // 	mockNotifier := NewMockNotifier()
@@ -141,3 +359,366 @@ func (m *MockNotifier) WaitForNotifications(count int, timeout time.Duration) bo
 // 		}
 // 	}
 // }
+
+// templatesArchive is a GitHub-style tar.gz of one template of size bytes,
+// plus an entry trying to escape the checkout.
+func templatesArchive(t *testing.T, ref string, size int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	files := []struct {
+		name string
+		body []byte
+	}{
+		{"nuclei-templates-" + ref + "/http/cves/cve.yaml", bytes.Repeat([]byte("a"), size)},
+		{"nuclei-templates-" + ref + "/../../evil", []byte("x")},
+	}
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(f.body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestTemplatesCache_FetchesOnceAndEvictsLeastRecentlyUsed(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		ref := strings.TrimSuffix(filepath.Base(r.URL.Path), ".tar.gz")
+		w.Write(templatesArchive(t, ref, 1000))
+	}))
+	defer server.Close()
+
+	root := t.TempDir()
+	cache := &TemplatesCache{Dir: filepath.Join(root, "cache"), MaxBytes: 2500, URL: server.URL + "/archive/%s.tar.gz"}
+	log := logger.NewLogger(logrus.ErrorLevel)
+	ctx := context.Background()
+
+	ensure := func(ref string) string {
+		t.Helper()
+		dir, err := cache.Ensure(ctx, ref, log)
+		if err != nil {
+			t.Fatalf("Ensure(%s): %v", ref, err)
+		}
+		return dir
+	}
+
+	v1 := ensure("v1")
+	if _, err := os.Stat(filepath.Join(v1, "http", "cves", "cve.yaml")); err != nil {
+		t.Fatalf("expected the template without the archive's top directory: %v", err)
+	}
+	v2 := ensure("v2")
+	time.Sleep(10 * time.Millisecond)
+	if ensure("v1") != v1 {
+		t.Fatal("expected the same directory for the same ref")
+	}
+	if got := requests.Load(); got != 2 {
+		t.Fatalf("expected a cached ref not to be downloaded again, got %d requests", got)
+	}
+
+	// v3 pushes the cache over 2500 bytes; v2 is the least recently used
+	v3 := ensure("v3")
+	for dir, want := range map[string]bool{v1: true, v2: false, v3: true} {
+		if _, err := os.Stat(dir); (err == nil) != want {
+			t.Errorf("%s: exists=%v, want %v", filepath.Base(dir), err == nil, want)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "evil")); err == nil {
+		t.Error("archive entry escaped the cache directory")
+	}
+}
+
+func TestPinTemplates(t *testing.T) {
+	toolConfigs := []tools.ToolConfig{
+		{Name: "nuclei", Command: "nuclei", Flags: []tools.FlagConfig{{Flag: "-list", Default: "urls.txt"}}},
+		{Name: "nuclei-cves", Command: "/usr/local/bin/nuclei", Flags: []tools.FlagConfig{{Flag: "-t", Default: "http/cves/, /opt/custom"}}},
+		{Name: "httpx", Command: "httpx", Flags: []tools.FlagConfig{{Flag: "-t", Default: "50"}}},
+	}
+
+	pinTemplates(toolConfigs, "/cache/v10.1.5")
+
+	flags := toolConfigs[0].Flags
+	if len(flags) != 2 || flags[1].Flag != "-t" || flags[1].Default != "/cache/v10.1.5" {
+		t.Errorf("expected -t to be added, got %+v", flags)
+	}
+	if got := toolConfigs[1].Flags[0].Default; got != "/cache/v10.1.5/http/cves,/opt/custom" {
+		t.Errorf("expected relative template paths inside the checkout, got %q", got)
+	}
+	if got := toolConfigs[2].Flags[0].Default; got != "50" {
+		t.Errorf("expected other tools to be left alone, got %q", got)
+	}
+}
+
+func TestCheckProxy(t *testing.T) {
+	var requested atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested.Store(r.URL.Host)
+		if r.Header.Get("Proxy-Authorization") == "" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer proxy.Close()
+
+	withAuth := strings.Replace(proxy.URL, "http://", "http://egress:secret@", 1)
+	if err := checkProxy(withAuth, "example.com"); err != nil {
+		t.Fatalf("a response from the proxy should pass the check: %v", err)
+	}
+	if host := requested.Load(); host != "example.com" {
+		t.Errorf("proxy was asked for %v, want example.com", host)
+	}
+
+	err := checkProxy(proxy.URL, "example.com")
+	if err == nil {
+		t.Fatal("a proxy refusing the credentials should fail the check")
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("error leaks the proxy password: %v", err)
+	}
+
+	proxy.Close()
+	if err := checkProxy(withAuth, "example.com"); err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("an unreachable proxy should fail without its password, got %v", err)
+	}
+}
+
+// dirRunner records the working directory of every command.
+type dirRunner struct {
+	dirs []string
+}
+
+func (r *dirRunner) Run(ctx context.Context, command string, args []string) error {
+	return r.RunInDir(ctx, "", command, args)
+}
+
+func (r *dirRunner) RunInDir(ctx context.Context, dir, command string, args []string) error {
+	r.dirs = append(r.dirs, dir)
+	return nil
+}
+
+func TestRunIteration_SeparateDirectories(t *testing.T) {
+	runner := &dirRunner{}
+	eng, err := NewPiplinerEngine(WithRunner(runner), WithPeriodic(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanDir := t.TempDir()
+	eng.options = tools.DefaultOptions()
+	eng.scanDir = scanDir
+	eng.chainConfig = &tools.ChainConfig{
+		ExecutionMode: "sequential",
+		IterationDirs: true,
+		Tools:         []tools.ToolConfig{{Name: "subfinder", Command: "subfinder"}},
+	}
+
+	if got := CurrentIteration(scanDir); got != scanDir {
+		t.Fatalf("flat scan current iteration = %s, want the scan dir", got)
+	}
+
+	first := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, now := range []time.Time{first, first.Add(time.Hour)} {
+		if err := eng.runIteration(now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{
+		filepath.Join(scanDir, "run_2024-06-01T12-00-00"),
+		filepath.Join(scanDir, "run_2024-06-01T13-00-00"),
+	}
+	iterations, err := Iterations(scanDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(iterations, ",") != strings.Join(want, ",") {
+		t.Errorf("iterations = %v, want %v", iterations, want)
+	}
+	if strings.Join(runner.dirs, ",") != strings.Join(want, ",") {
+		t.Errorf("tools ran in %v, want %v", runner.dirs, want)
+	}
+	if got := CurrentIteration(scanDir); got != want[1] {
+		t.Errorf("current iteration = %s, want %s", got, want[1])
+	}
+
+	// a single run keeps the flat layout
+	eng.schedule = nil
+	runner.dirs = nil
+	eng.options.WorkingDir = scanDir
+	if err := eng.runIteration(first.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.dirs) != 1 || runner.dirs[0] != scanDir {
+		t.Errorf("single run ran in %v, want %s", runner.dirs, scanDir)
+	}
+}
+
+func TestWithResume_SkipsFinishedTools(t *testing.T) {
+	scanDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(scanDir, "subfinder_output.txt"), []byte("a.example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	commands := &commandRecorder{}
+	eng, err := NewPiplinerEngine(WithRunner(commands), WithResume(scanDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eng.options = tools.DefaultOptions()
+	eng.options.WorkingDir = scanDir
+	eng.chainConfig = &tools.ChainConfig{
+		ExecutionMode: "sequential",
+		Tools: []tools.ToolConfig{
+			{Name: "subfinder", Command: "subfinder"},
+			{Name: "httpx", Command: "httpx", DependsOn: []string{"subfinder"}},
+		},
+	}
+
+	if err := eng.RunHTTP("test", "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(commands.commands, ",") != "httpx" {
+		t.Errorf("ran %v, want only httpx", commands.commands)
+	}
+}
+
+// commandRecorder records the commands it ran.
+type commandRecorder struct {
+	commands []string
+}
+
+func (r *commandRecorder) Run(ctx context.Context, command string, args []string) error {
+	r.commands = append(r.commands, command)
+	return nil
+}
+
+// argsRecorder records the arguments of each command it ran.
+type argsRecorder struct {
+	args map[string][]string
+}
+
+func (r *argsRecorder) Run(ctx context.Context, command string, args []string) error {
+	if r.args == nil {
+		r.args = make(map[string][]string)
+	}
+	r.args[command] = args
+	return nil
+}
+
+func TestInterruptedNmapRunIsResumed(t *testing.T) {
+	nmap := tools.ToolConfig{
+		Name:    "nmap",
+		Command: "nmap",
+		Flags: []tools.FlagConfig{
+			{Flag: "-iL", Default: "hosts.txt"},
+			{Flag: "-oN", Option: "Output", Default: "nmap_output.txt"},
+		},
+		ResumeSupport: &tools.ResumeSupport{Flag: "--resume", StateFile: "nmap_output.txt", Only: true, DoneMarker: "# Nmap done"},
+	}
+
+	for _, tc := range []struct {
+		name  string
+		opt   func(dir string) OptFunc
+		state string
+		want  []string
+	}{
+		{"resume", WithResume, "# Nmap 7.94 scan initiated\nNmap scan report for a.example.com\n", []string{"--resume", "nmap_output.txt"}},
+		{"retry", func(dir string) OptFunc { return WithRetryFailed(dir, []string{"nmap"}) }, "# Nmap 7.94 scan initiated\n", []string{"--resume", "nmap_output.txt"}},
+		{"retry without state", func(dir string) OptFunc { return WithRetryFailed(dir, []string{"nmap"}) }, "", []string{"-iL", "hosts.txt", "-oN", "nmap_output.txt"}},
+		{"finished", WithResume, "# Nmap 7.94 scan initiated\n# Nmap done at Sat Jun  1 12:00:00 2024 -- 1 IP address scanned\n", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			scanDir := t.TempDir()
+			if tc.state != "" {
+				if err := os.WriteFile(filepath.Join(scanDir, "nmap_output.txt"), []byte(tc.state), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			commands := &argsRecorder{}
+			eng, err := NewPiplinerEngine(WithRunner(commands), tc.opt(scanDir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			eng.options = tools.DefaultOptions()
+			eng.options.WorkingDir = scanDir
+			var resumed bool
+			eng.options.OnToolProgress = func(event tools.ProgressEvent) {
+				if event.Status == "Started" {
+					resumed = event.Resumed
+				}
+			}
+			eng.chainConfig = &tools.ChainConfig{ExecutionMode: "sequential", Tools: []tools.ToolConfig{nmap}}
+
+			if err := eng.RunHTTP("test", "example.com"); err != nil {
+				t.Fatal(err)
+			}
+			args, ran := commands.args["nmap"]
+			if tc.want == nil {
+				if ran {
+					t.Errorf("finished nmap ran again with %v", args)
+				}
+				return
+			}
+			if strings.Join(args, " ") != strings.Join(tc.want, " ") {
+				t.Errorf("nmap ran with %v, want %v", args, tc.want)
+			}
+			if want := tc.want[0] == "--resume"; resumed != want {
+				t.Errorf("Started event resumed = %v, want %v", resumed, want)
+			}
+		})
+	}
+}
+
+// namedStageHook is a stage hook that does nothing.
+type namedStageHook string
+
+func (h namedStageHook) Name() string                            { return string(h) }
+func (h namedStageHook) Description() string                     { return "does nothing" }
+func (h namedStageHook) ExecuteForStage(tools.HookContext) error { return nil }
+
+func TestValidateModuleSource_Hooks(t *testing.T) {
+	var filenames []string
+	tools.RegisterHookFactory("engine_test_notifier", func(config map[string]any) (tools.StageHook, error) {
+		var cfg struct {
+			Filename string `yaml:"filename"`
+		}
+		if err := tools.DecodeHookConfig(config, &cfg); err != nil {
+			return nil, err
+		}
+		filenames = append(filenames, cfg.Filename)
+		return namedStageHook("engine_test_notifier"), nil
+	})
+
+	module := func(hooks string) []byte {
+		return []byte("execution_mode: sequential\ntools:\n  - name: nuclei\n    command: nuclei\nhooks:\n" + hooks)
+	}
+	err := ValidateModuleSource("valid.yaml", module("  - name: engine_test_notifier\n    stage: vuln_scan\n    config:\n      filename: nuclei_output.json\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(filenames) != 1 || filenames[0] != "nuclei_output.json" {
+		t.Fatalf("factory got filenames %v", filenames)
+	}
+
+	for name, hooks := range map[string]string{
+		"unknown.yaml":    "  - name: engine_test_missing\n    stage: vuln_scan\n",
+		"bad_config.yaml": "  - name: engine_test_notifier\n    stage: vuln_scan\n    config:\n      file: nuclei_output.json\n",
+	} {
+		if err := ValidateModuleSource(name, module(hooks)); !stderrors.Is(err, errors.ErrInvalidConfig) {
+			t.Errorf("%s: expected an invalid config error, got %v", name, err)
+		}
+	}
+}
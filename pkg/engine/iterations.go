@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	output "pipeliner/pkg/io_utils"
+	"pipeliner/pkg/logger"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	iterationPrefix = "run_"
+	iterationLayout = "2006-01-02T15-04-05"
+	// CurrentIterationLink points at the latest run directory of a periodic
+	// scan with iteration_dirs.
+	CurrentIterationLink = "current"
+)
+
+// iterationDirs reports whether each run of this scan gets its own
+// directory. Single runs, retries and resumed runs keep the flat layout.
+func (e *PiplinerEngine) iterationDirs() bool {
+	return e.schedule != nil && e.chainConfig != nil && e.chainConfig.IterationDirs &&
+		e.scanDir != "" && e.retryFailed == nil && !e.resume
+}
+
+// runIteration runs the tool chain once. With iteration_dirs it runs in a
+// new run_<time> directory, watched for duplicates only while it runs.
+func (e *PiplinerEngine) runIteration(now time.Time) error {
+	if !e.iterationDirs() {
+		return e.runTools()
+	}
+
+	previous, _ := Iterations(e.scanDir)
+	dir, err := e.startIteration(now)
+	if err != nil {
+		return err
+	}
+	fields := logger.Fields{"dir": dir}
+	if len(previous) > 0 {
+		fields["previous"] = previous[len(previous)-1]
+	}
+	e.logger.Info("Starting scan iteration", fields)
+
+	ctx, cancel := context.WithCancel(e.ctx)
+	defer cancel()
+	if !e.options.DryRun {
+		go output.WatchDirectoryWithPath(ctx, dir)
+	}
+
+	return e.runTools()
+}
+
+// startIteration creates the run directory, points current at it and makes
+// it the tools' working directory.
+func (e *PiplinerEngine) startIteration(now time.Time) (string, error) {
+	dir := filepath.Join(e.scanDir, iterationPrefix+now.UTC().Format(iterationLayout))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create iteration directory: %w", err)
+	}
+	e.prepareRunAs(dir, e.chainConfig.Tools)
+
+	if err := linkCurrentIteration(e.scanDir, dir); err != nil {
+		e.logger.Warn("Failed to update current iteration link", logger.Fields{"dir": dir, "error": err})
+	}
+	e.options.WorkingDir = dir
+	return dir, nil
+}
+
+// linkCurrentIteration swaps the current link over to dir in one rename,
+// so readers never see it missing.
+func linkCurrentIteration(scanDir, dir string) error {
+	tmp := filepath.Join(scanDir, "."+CurrentIterationLink+".tmp")
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.Base(dir), tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(scanDir, CurrentIterationLink))
+}
+
+// Iterations lists the run directories of a periodic scan, oldest first.
+// Scans with the flat layout, including every scan from before
+// iteration_dirs, have none.
+func Iterations(scanDir string) ([]string, error) {
+	entries, err := os.ReadDir(scanDir)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), iterationPrefix) {
+			dirs = append(dirs, filepath.Join(scanDir, entry.Name()))
+		}
+	}
+	// the timestamp layout sorts chronologically
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// CurrentIteration is the directory the scan's latest run wrote to: where
+// current points, or scanDir itself for a flat scan.
+func CurrentIteration(scanDir string) string {
+	target, err := os.Readlink(filepath.Join(scanDir, CurrentIterationLink))
+	if err != nil {
+		return scanDir
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(scanDir, target)
+	}
+	return target
+}
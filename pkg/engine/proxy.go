@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/tools"
+	"time"
+)
+
+// proxyCheckTimeout bounds the preflight request through the proxy.
+var proxyCheckTimeout = 15 * time.Second
+
+// prepareProxy settles the scan's proxy, the caller's first, then
+// PIPELINER_PROXY, then the module's, and checks it can be reached before
+// any tool starts.
+func (e *PiplinerEngine) prepareProxy(chainConfig *tools.ChainConfig) error {
+	proxy := e.options.Proxy
+	if proxy == "" {
+		proxy = os.Getenv(tools.ProxyEnv)
+	}
+	if proxy == "" {
+		proxy = chainConfig.Proxy
+	}
+	if proxy == "" {
+		return nil
+	}
+	if err := tools.ValidateProxy(proxy); err != nil {
+		return err
+	}
+	if err := checkProxy(proxy, e.options.Domain); err != nil {
+		return err
+	}
+	e.options.Proxy = proxy
+	e.logger.Info("Scan traffic goes through proxy", logger.Fields{"proxy": tools.RedactProxy(proxy)})
+	return nil
+}
+
+// checkProxy asks the proxy for the target. Any response counts, even an
+// error status from the proxy, as long as it did not refuse the request.
+func checkProxy(proxy, domain string) error {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	client := &http.Client{
+		Timeout:   proxyCheckTimeout,
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Head("http://" + domain)
+	if err != nil {
+		return fmt.Errorf("proxy check through %s failed: %w", proxyURL.Redacted(), err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		return fmt.Errorf("proxy check through %s failed: %s", proxyURL.Redacted(), resp.Status)
+	}
+	return nil
+}
+
+// Proxy is the scan's proxy URL with its password hidden, or "" when the
+// scan does not use one.
+func (e *PiplinerEngine) Proxy() string {
+	if e.options == nil || e.options.Proxy == "" {
+		return ""
+	}
+	return tools.RedactProxy(e.options.Proxy)
+}
@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"os"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/tools"
+)
+
+// prepareRunAs checks that the tools with run_as can be started as their
+// user and hands the scan directory over so they can write their output.
+// Problems are only logged; the tool itself fails if the switch is refused.
+func (e *PiplinerEngine) prepareRunAs(dir string, toolConfigs []tools.ToolConfig) {
+	var creds []*tools.Credential
+	for _, tc := range toolConfigs {
+		if tc.RunAs == "" {
+			continue
+		}
+		cred, err := tools.ResolveRunAs(tc.RunAs)
+		if err != nil {
+			e.logger.Warn("Cannot resolve run_as user", logger.Fields{"tool": tc.Name, "run_as": tc.RunAs, "error": err})
+			continue
+		}
+		if err := tools.CanRunAs(cred); err != nil {
+			e.logger.Warn("Tool cannot drop privileges", logger.Fields{"tool": tc.Name, "run_as": tc.RunAs, "error": err})
+			continue
+		}
+		creds = append(creds, cred)
+	}
+	if len(creds) == 0 {
+		return
+	}
+
+	// one user owns the directory; with several, it works like /tmp
+	single := true
+	for _, cred := range creds[1:] {
+		if cred.UID != creds[0].UID || cred.GID != creds[0].GID {
+			single = false
+			break
+		}
+	}
+	if single {
+		if err := os.Chown(dir, int(creds[0].UID), int(creds[0].GID)); err != nil {
+			e.logger.Warn("Failed to hand scan directory to run_as user", logger.Fields{"dir": dir, "uid": creds[0].UID, "error": err})
+		}
+		return
+	}
+	if err := os.Chmod(dir, 0o777|os.ModeSticky); err != nil {
+		e.logger.Warn("Failed to open scan directory to run_as users", logger.Fields{"dir": dir, "error": err})
+	}
+}
@@ -0,0 +1,226 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule says when the runs of a periodic scan start.
+type Schedule interface {
+	// Next is the first start after t, or a zero time if there is none.
+	Next(t time.Time) time.Time
+	String() string
+}
+
+// Clock is the time source of the scheduler, replaced in tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// cronDescriptors are the shorthands ParseSchedule accepts for common cron
+// expressions.
+var cronDescriptors = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// ParseSchedule reads a schedule written as an interval ("6h", or
+// "@every 6h") or as a five-field cron expression (minute, hour, day of
+// month, month, day of week, e.g. "0 3 * * 1-5"), or one of @hourly,
+// @daily, @weekly and @monthly. Cron expressions are evaluated in the
+// local time zone.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if expr, ok := cronDescriptors[spec]; ok {
+		return parseCron(expr, spec)
+	}
+	if every, ok := strings.CutPrefix(spec, "@every "); ok {
+		return parseInterval(strings.TrimSpace(every))
+	}
+	if len(strings.Fields(spec)) == 1 {
+		return parseInterval(spec)
+	}
+	return parseCron(spec, spec)
+}
+
+// Every is a schedule that starts a run each interval d.
+func Every(d time.Duration) Schedule {
+	return intervalSchedule{every: d}
+}
+
+type intervalSchedule struct {
+	every time.Duration
+}
+
+func parseInterval(spec string) (Schedule, error) {
+	d, err := time.ParseDuration(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: not an interval or a cron expression", spec)
+	}
+	if d < time.Minute {
+		return nil, fmt.Errorf("invalid schedule %q: the interval must be at least a minute", spec)
+	}
+	return Every(d), nil
+}
+
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(s.every)
+}
+
+func (s intervalSchedule) String() string {
+	return "every " + s.every.String()
+}
+
+// cronField is the set of values a cron field matches, one bit each.
+type cronField uint64
+
+func (f cronField) has(v int) bool {
+	return f&(1<<uint(v)) != 0
+}
+
+type cronSchedule struct {
+	spec                          string
+	minute, hour, dom, month, dow cronField
+	// cron runs on days that match either day field when both are restricted
+	domAny, dowAny bool
+}
+
+// cronBounds are the ranges of the five fields; day of week takes 7 for
+// Sunday as well as 0.
+var cronBounds = [5]struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+func parseCron(expr, spec string) (Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronBounds) {
+		return nil, fmt.Errorf("invalid schedule %q: a cron expression has 5 fields, got %d", spec, len(fields))
+	}
+
+	var parsed [5]cronField
+	for i, field := range fields {
+		f, err := parseCronField(field, cronBounds[i].min, cronBounds[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %s: %w", spec, cronBounds[i].name, err)
+		}
+		parsed[i] = f
+	}
+	if parsed[4].has(7) {
+		parsed[4] |= 1
+	}
+
+	s := &cronSchedule{
+		spec:   spec,
+		minute: parsed[0],
+		hour:   parsed[1],
+		dom:    parsed[2],
+		month:  parsed[3],
+		dow:    parsed[4],
+		domAny: strings.HasPrefix(fields[2], "*"),
+		dowAny: strings.HasPrefix(fields[4], "*"),
+	}
+	if s.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("invalid schedule %q: it never runs", spec)
+	}
+	return s, nil
+}
+
+// parseCronField reads a comma-separated list of *, n and a-b, each with
+// an optional /step.
+func parseCronField(field string, min, max int) (cronField, error) {
+	var f cronField
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+
+		lo, hi := min, max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			loStr, hiStr, _ := strings.Cut(rng, "-")
+			var errLo, errHi error
+			lo, errLo = strconv.Atoi(loStr)
+			hi, errHi = strconv.Atoi(hiStr)
+			if errLo != nil || errHi != nil || lo > hi {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			v, err := strconv.Atoi(rng)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			lo, hi = v, v
+			if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			f |= 1 << uint(v)
+		}
+	}
+	return f, nil
+}
+
+// cronHorizon bounds the search for the next start of expressions that
+// rarely match, such as February 29th.
+const cronHorizon = 5
+
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+	limit := t.AddDate(cronHorizon, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case !s.month.has(int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !s.hour.has(t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !s.minute.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom, dow := s.dom.has(t.Day()), s.dow.has(int(t.Weekday()))
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (s *cronSchedule) String() string {
+	return s.spec
+}
@@ -0,0 +1,166 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pipeliner/pkg/testutil"
+	"pipeliner/pkg/tools"
+)
+
+func TestParseSchedule(t *testing.T) {
+	for spec, want := range map[string]string{
+		"6h":           "every 6h0m0s",
+		"@every 90m":   "every 1h30m0s",
+		"0 3 * * 1-5":  "0 3 * * 1-5",
+		"@daily":       "@daily",
+		"*/15 * * * *": "*/15 * * * *",
+	} {
+		schedule, err := ParseSchedule(spec)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q): %v", spec, err)
+			continue
+		}
+		if schedule.String() != want {
+			t.Errorf("ParseSchedule(%q) = %s, want %s", spec, schedule, want)
+		}
+	}
+
+	for _, spec := range []string{
+		"",
+		"soon",
+		"30s",          // too often
+		"0 3 * *",      // four fields
+		"60 * * * *",   // minute out of range
+		"0 0 31 2 *",   // never
+		"0 0 * * 8",    // day of week out of range
+		"*/0 * * * *",  // zero step
+		"5-1 * * * *",  // backwards range
+		"0 0 1 JAN *",  // names are not supported
+		"@every often", // not a duration
+	} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) should fail", spec)
+		}
+	}
+}
+
+func TestCronSchedule_Next(t *testing.T) {
+	// a Saturday
+	from := time.Date(2024, 6, 1, 12, 34, 56, 0, time.UTC)
+
+	for _, tc := range []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 6, 1, 12, 45, 0, 0, time.UTC)},
+		{"0 3 * * 1-5", time.Date(2024, 6, 3, 3, 0, 0, 0, time.UTC)},
+		{"0 3 * * 7", time.Date(2024, 6, 2, 3, 0, 0, 0, time.UTC)},
+		{"30 12 1 * *", time.Date(2024, 7, 1, 12, 30, 0, 0, time.UTC)},
+		// either day field matches when both are restricted
+		{"0 0 15 * 1", time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 6, 1, 13, 0, 0, 0, time.UTC)},
+	} {
+		schedule, err := ParseSchedule(tc.spec)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q): %v", tc.spec, err)
+		}
+		if got := schedule.Next(from); !got.Equal(tc.want) {
+			t.Errorf("%s: next run after %s = %s, want %s", tc.spec, from, got, tc.want)
+		}
+	}
+
+	// a start exactly on the schedule is not repeated
+	schedule, _ := ParseSchedule("0 * * * *")
+	on := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	if got := schedule.Next(on); !got.Equal(on.Add(time.Hour)) {
+		t.Errorf("next run after %s = %s, want an hour later", on, got)
+	}
+}
+
+// slowRunner takes d of clock time for each command.
+type slowRunner struct {
+	clock *testutil.FakeClock
+	d     time.Duration
+	runs  atomic.Int32
+}
+
+func (r *slowRunner) Run(ctx context.Context, command string, args []string) error {
+	r.runs.Add(1)
+	r.clock.Advance(r.d)
+	return nil
+}
+
+func TestRun_SkipsStartsDuringAPreviousRun(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+	runner := &slowRunner{clock: clock, d: 90 * time.Minute}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eng, err := NewPiplinerEngine(WithContext(ctx), WithRunner(runner), WithSchedule("@every 1h"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eng.clock = clock
+	eng.options = tools.DefaultOptions()
+	eng.chainConfig = &tools.ChainConfig{
+		ExecutionMode: "sequential",
+		Tools:         []tools.ToolConfig{{Name: "subfinder", Command: "subfinder"}},
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- eng.Run() }()
+
+	// the first run ends at 13:30, past the 13:00 start, so the next is 14:30
+	clock.WaitForWaiters(t, 1)
+	if runs := runner.runs.Load(); runs != 1 {
+		t.Fatalf("ran %d times before the first scheduled start, want 1", runs)
+	}
+	clock.Advance(59 * time.Minute)
+	if runs := runner.runs.Load(); runs != 1 {
+		t.Fatalf("ran %d times before 14:30, want 1", runs)
+	}
+	clock.Advance(time.Minute)
+	clock.WaitForWaiters(t, 1)
+	if runs := runner.runs.Load(); runs != 2 {
+		t.Fatalf("ran %d times by 14:30, want 2", runs)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+func TestRun_WithoutScheduleRunsOnce(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+	runner := &slowRunner{clock: clock, d: time.Minute}
+	eng, err := NewPiplinerEngine(WithRunner(runner), WithPeriodic(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eng.clock = clock
+	eng.options = tools.DefaultOptions()
+	eng.chainConfig = &tools.ChainConfig{
+		ExecutionMode: "sequential",
+		Tools:         []tools.ToolConfig{{Name: "subfinder", Command: "subfinder"}},
+	}
+
+	if err := eng.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if runs := runner.runs.Load(); runs != 1 {
+		t.Errorf("ran %d times, want 1", runs)
+	}
+}
+
+func TestNewPiplinerEngine_InvalidSchedule(t *testing.T) {
+	_, err := NewPiplinerEngine(WithSchedule("0 25 * * *"))
+	if err == nil || !strings.Contains(err.Error(), "hour") {
+		t.Errorf("expected an error naming the hour field, got %v", err)
+	}
+}
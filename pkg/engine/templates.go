@@ -0,0 +1,331 @@
+package engine
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/tools"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TemplatesCacheEnv is the directory pinned nuclei-templates releases
+	// are kept in.
+	TemplatesCacheEnv = "PIPELINER_TEMPLATES_CACHE"
+	// TemplatesCacheSizeEnv caps the cache in megabytes; the least recently
+	// used releases are evicted past it.
+	TemplatesCacheSizeEnv = "PIPELINER_TEMPLATES_CACHE_MB"
+	// TemplatesURLEnv is the tar.gz URL of a release, with %s for the ref.
+	TemplatesURLEnv = "PIPELINER_TEMPLATES_URL"
+
+	defaultTemplatesURL     = "https://github.com/projectdiscovery/nuclei-templates/archive/%s.tar.gz"
+	defaultTemplatesCacheMB = 2048
+
+	templatesMarker = ".pipeliner-templates.json"
+)
+
+// TemplatesCache keeps nuclei-templates checkouts, one directory per ref.
+type TemplatesCache struct {
+	Dir      string
+	MaxBytes int64
+	URL      string
+	Client   *http.Client
+
+	mu sync.Mutex
+}
+
+type templatesMarkerData struct {
+	Ref       string `json:"ref"`
+	Size      int64  `json:"size"`
+	FetchedAt int64  `json:"fetched_at"`
+}
+
+var (
+	defaultTemplatesCacheOnce sync.Once
+	defaultTemplatesCache     *TemplatesCache
+)
+
+// DefaultTemplatesCache is the cache configured through the environment,
+// shared by every engine in the process.
+func DefaultTemplatesCache() *TemplatesCache {
+	defaultTemplatesCacheOnce.Do(func() {
+		dir := os.Getenv(TemplatesCacheEnv)
+		if dir == "" {
+			base, err := os.UserCacheDir()
+			if err != nil {
+				base = os.TempDir()
+			}
+			dir = filepath.Join(base, "pipeliner", "nuclei-templates")
+		}
+		sizeMB := int64(defaultTemplatesCacheMB)
+		if v, err := strconv.ParseInt(os.Getenv(TemplatesCacheSizeEnv), 10, 64); err == nil && v > 0 {
+			sizeMB = v
+		}
+		templatesURL := os.Getenv(TemplatesURLEnv)
+		if templatesURL == "" {
+			templatesURL = defaultTemplatesURL
+		}
+		defaultTemplatesCache = &TemplatesCache{
+			Dir:      dir,
+			MaxBytes: sizeMB << 20,
+			URL:      templatesURL,
+			Client:   &http.Client{Timeout: 10 * time.Minute},
+		}
+	})
+	return defaultTemplatesCache
+}
+
+// WithTemplatesCache sets where templates_ref checkouts are kept.
+func WithTemplatesCache(cache *TemplatesCache) OptFunc {
+	return func(opts *EnginePiplinerOpts) {
+		opts.templatesCache = cache
+	}
+}
+
+// Ensure returns the directory holding ref, downloading it on first use.
+func (c *TemplatesCache) Ensure(ctx context.Context, ref string, log *logger.Logger) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dir := filepath.Join(c.Dir, url.PathEscape(ref))
+	if _, err := os.Stat(filepath.Join(dir, templatesMarker)); err == nil {
+		now := time.Now()
+		_ = os.Chtimes(dir, now, now)
+		return dir, nil
+	}
+
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return "", err
+	}
+	log.WithFields(logger.Fields{"ref": ref}).Info("Downloading nuclei templates")
+	if err := c.download(ctx, ref, dir); err != nil {
+		return "", fmt.Errorf("download nuclei-templates %s: %w", ref, err)
+	}
+	c.evict(dir, log)
+	return dir, nil
+}
+
+func (c *TemplatesCache) download(ctx context.Context, ref, dir string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(c.URL, url.PathEscape(ref)), nil)
+	if err != nil {
+		return err
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", req.URL, resp.Status)
+	}
+
+	tmp, err := os.MkdirTemp(c.Dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	size, err := extractTarGz(resp.Body, tmp)
+	if err != nil {
+		return err
+	}
+	marker, err := json.Marshal(templatesMarkerData{Ref: ref, Size: size, FetchedAt: time.Now().Unix()})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, templatesMarker), marker, 0644); err != nil {
+		return err
+	}
+
+	// a half-written directory from an earlier crash has no marker
+	os.RemoveAll(dir)
+	return os.Rename(tmp, dir)
+}
+
+// extractTarGz unpacks a GitHub archive into dir, dropping its top-level
+// directory. Only regular files and directories are written.
+func extractTarGz(r io.Reader, dir string) (int64, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	defer gz.Close()
+
+	var size int64
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return size, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		_, name, _ := strings.Cut(hdr.Name, "/")
+		name = filepath.Clean(name)
+		if name == "." || name == ".." || filepath.IsAbs(name) || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			continue
+		}
+		target := filepath.Join(dir, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return 0, err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return 0, err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return 0, err
+			}
+			n, err := io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return 0, err
+			}
+			size += n
+		}
+	}
+}
+
+type cachedTemplates struct {
+	dir     string
+	size    int64
+	modTime time.Time
+}
+
+// evict removes the least recently used checkouts until the cache fits in
+// MaxBytes. keep is never removed, even when it alone is over the cap.
+func (c *TemplatesCache) evict(keep string, log *logger.Logger) {
+	if c.MaxBytes <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return
+	}
+
+	var cached []cachedTemplates
+	var total int64
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".tmp-") {
+			continue
+		}
+		dir := filepath.Join(c.Dir, entry.Name())
+		data, err := os.ReadFile(filepath.Join(dir, templatesMarker))
+		if err != nil {
+			continue
+		}
+		var marker templatesMarkerData
+		if err := json.Unmarshal(data, &marker); err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		cached = append(cached, cachedTemplates{dir: dir, size: marker.Size, modTime: info.ModTime()})
+		total += marker.Size
+	}
+
+	sort.Slice(cached, func(i, j int) bool { return cached[i].modTime.Before(cached[j].modTime) })
+	for _, entry := range cached {
+		if total <= c.MaxBytes {
+			return
+		}
+		if entry.dir == keep {
+			continue
+		}
+		if err := os.RemoveAll(entry.dir); err != nil {
+			log.WithFields(logger.Fields{"dir": entry.dir, "error": err}).Warn("Failed to evict nuclei templates")
+			continue
+		}
+		total -= entry.size
+		log.WithFields(logger.Fields{"dir": entry.dir}).Info("Evicted nuclei templates")
+	}
+}
+
+// templateFlags are the nuclei flags taking template paths.
+var templateFlags = map[string]bool{"-t": true, "-templates": true, "--templates": true}
+
+// pinTemplates points every nuclei tool at dir: relative -t paths are
+// resolved inside it, and tools without -t get "-t dir".
+func pinTemplates(toolConfigs []tools.ToolConfig, dir string) {
+	for i := range toolConfigs {
+		tc := &toolConfigs[i]
+		if filepath.Base(tc.Command) != "nuclei" {
+			continue
+		}
+
+		flags := make([]tools.FlagConfig, len(tc.Flags))
+		copy(flags, tc.Flags)
+		pinned := false
+		for j, flag := range flags {
+			if !templateFlags[flag.Flag] {
+				continue
+			}
+			paths := strings.Split(flag.Default, ",")
+			for k, path := range paths {
+				path = strings.TrimSpace(path)
+				if path != "" && !filepath.IsAbs(path) {
+					path = filepath.Join(dir, path)
+				}
+				paths[k] = path
+			}
+			flags[j].Default = strings.Join(paths, ",")
+			pinned = true
+		}
+		if !pinned {
+			flags = append(flags, tools.FlagConfig{Flag: "-t", Option: "Templates", Default: dir})
+		}
+		tc.Flags = flags
+	}
+}
+
+// prepareTemplates checks out the module's templates_ref and points the
+// nuclei tools at it.
+func (e *PiplinerEngine) prepareTemplates(chainConfig *tools.ChainConfig) error {
+	if chainConfig.TemplatesRef == "" {
+		return nil
+	}
+	cache := e.templatesCache
+	if cache == nil {
+		cache = DefaultTemplatesCache()
+	}
+
+	dir, err := cache.Ensure(e.ctx, chainConfig.TemplatesRef, e.logger)
+	if err != nil {
+		return err
+	}
+	pinTemplates(chainConfig.Tools, dir)
+	e.logger.Info("Pinned nuclei templates", logger.Fields{"ref": chainConfig.TemplatesRef, "dir": dir})
+	return nil
+}
+
+// TemplatesRef is the nuclei-templates ref the module pins, if any.
+func (e *PiplinerEngine) TemplatesRef() string {
+	if e.chainConfig == nil {
+		return ""
+	}
+	return e.chainConfig.TemplatesRef
+}
@@ -3,6 +3,8 @@ package errors
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 )
 
 var (
@@ -10,6 +12,13 @@ var (
 	ErrInvalidConfig        = errors.New("invalid configuration")
 	ErrDependencyCycle      = errors.New("dependency cycle detected")
 	ErrDiscordNotConfigured = errors.New("discord client not configured")
+	ErrStageTimeout         = errors.New("stage timeout")
+	ErrToolTimeout          = errors.New("tool timeout")
+	ErrChecksumMismatch     = errors.New("binary checksum mismatch")
+	ErrPreRunHookFailed     = errors.New("pre-run hook failed")
+	ErrEmptyOutput          = errors.New("empty output")
+	ErrOutputLimit          = errors.New("output limit exceeded")
+	ErrSuccessCriteria      = errors.New("success criteria not met")
 )
 
 type ToolError struct {
@@ -32,6 +41,109 @@ func NewToolError(toolName string, err error) *ToolError {
 	}
 }
 
+// StageTimeoutError reports a tool stopped because its stage ran out of budget.
+type StageTimeoutError struct {
+	Stage  string
+	Budget time.Duration
+	Err    error
+}
+
+func (e *StageTimeoutError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("stage %s exceeded its %s budget", e.Stage, e.Budget)
+	}
+	return fmt.Sprintf("stage %s exceeded its %s budget: %v", e.Stage, e.Budget, e.Err)
+}
+
+func (e *StageTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+func (e *StageTimeoutError) Is(target error) bool {
+	return target == ErrStageTimeout
+}
+
+func NewStageTimeoutError(stage string, budget time.Duration, err error) *StageTimeoutError {
+	return &StageTimeoutError{
+		Stage:  stage,
+		Budget: budget,
+		Err:    err,
+	}
+}
+
+// ToolTimeoutError reports a tool stopped because its own timeout elapsed.
+type ToolTimeoutError struct {
+	ToolName string
+	Timeout  time.Duration
+	Err      error
+}
+
+func (e *ToolTimeoutError) Error() string {
+	return fmt.Sprintf("tool %s exceeded its %s timeout: %v", e.ToolName, e.Timeout, e.Err)
+}
+
+func (e *ToolTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ToolTimeoutError) Is(target error) bool {
+	return target == ErrToolTimeout
+}
+
+func NewToolTimeoutError(toolName string, timeout time.Duration, err error) *ToolTimeoutError {
+	return &ToolTimeoutError{
+		ToolName: toolName,
+		Timeout:  timeout,
+		Err:      err,
+	}
+}
+
+// EmptyOutputError reports a tool with fail_on_empty_output that exited
+// cleanly but left every declared output empty.
+type EmptyOutputError struct {
+	ToolName string
+	Files    []string
+}
+
+func (e *EmptyOutputError) Error() string {
+	return fmt.Sprintf("tool %s produced empty output: %s", e.ToolName, strings.Join(e.Files, ", "))
+}
+
+func (e *EmptyOutputError) Is(target error) bool {
+	return target == ErrEmptyOutput
+}
+
+func NewEmptyOutputError(toolName string, files []string) *EmptyOutputError {
+	return &EmptyOutputError{
+		ToolName: toolName,
+		Files:    files,
+	}
+}
+
+// SuccessCriteriaError reports a tool whose outputs failed one of its
+// success_criteria.
+type SuccessCriteriaError struct {
+	ToolName  string
+	Criterion string
+	Detail    string
+}
+
+func (e *SuccessCriteriaError) Error() string {
+	return fmt.Sprintf("tool %s failed success criterion %s: %s", e.ToolName, e.Criterion, e.Detail)
+}
+
+func (e *SuccessCriteriaError) Is(target error) bool {
+	return target == ErrSuccessCriteria
+}
+
+func NewSuccessCriteriaError(toolName, criterion, detail string) *SuccessCriteriaError {
+	return &SuccessCriteriaError{
+		ToolName:  toolName,
+		Criterion: criterion,
+		Detail:    detail,
+	}
+}
+
 type ConfigError struct {
 	Field   string
 	Value   interface{}
@@ -42,6 +154,10 @@ func (e *ConfigError) Error() string {
 	return fmt.Sprintf("config error for field %s (value: %v): %s", e.Field, e.Value, e.Message)
 }
 
+func (e *ConfigError) Is(target error) bool {
+	return target == ErrInvalidConfig
+}
+
 func NewConfigError(field string, value interface{}, message string) *ConfigError {
 	return &ConfigError{
 		Field:   field,
@@ -49,3 +165,75 @@ func NewConfigError(field string, value interface{}, message string) *ConfigErro
 		Message: message,
 	}
 }
+
+// ConfigDecodeError points at the key in a config file that could not be
+// decoded into the type the engine expects.
+type ConfigDecodeError struct {
+	File     string
+	Key      string
+	Expected string
+	Err      error
+}
+
+func (e *ConfigDecodeError) Error() string {
+	if e.Expected == "" {
+		return fmt.Sprintf("%s: key %q: %v", e.File, e.Key, e.Err)
+	}
+	return fmt.Sprintf("%s: key %q (expected %s): %v", e.File, e.Key, e.Expected, e.Err)
+}
+
+func (e *ConfigDecodeError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ConfigDecodeError) Is(target error) bool {
+	return target == ErrInvalidConfig
+}
+
+func NewConfigDecodeError(file, key, expected string, err error) *ConfigDecodeError {
+	return &ConfigDecodeError{
+		File:     file,
+		Key:      key,
+		Expected: expected,
+		Err:      err,
+	}
+}
+
+// ChecksumMismatchError reports a tool binary whose sha256 differs from the
+// pinned one, so it may have been replaced.
+type ChecksumMismatchError struct {
+	Command  string
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("security: refusing to run %s: %s has sha256 %s, pinned %s", e.Command, e.Path, e.Actual, e.Expected)
+}
+
+func (e *ChecksumMismatchError) Is(target error) bool {
+	return target == ErrChecksumMismatch
+}
+
+// OutputLimitError reports a tool killed because one of its output files
+// grew past its max_output_mb.
+type OutputLimitError struct {
+	File    string
+	LimitMB int
+}
+
+func (e *OutputLimitError) Error() string {
+	return fmt.Sprintf("output file %s grew past its %d MB limit, tool stopped", e.File, e.LimitMB)
+}
+
+func (e *OutputLimitError) Is(target error) bool {
+	return target == ErrOutputLimit
+}
+
+func NewOutputLimitError(file string, limitMB int) *OutputLimitError {
+	return &OutputLimitError{
+		File:    file,
+		LimitMB: limitMB,
+	}
+}
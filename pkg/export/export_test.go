@@ -0,0 +1,163 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"pipeliner/pkg/export"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func findings() []export.Finding {
+	var out []export.Finding
+	for _, stored := range []struct{ host, vuln string }{
+		{"api.example.com", "[CRITICAL] cve-2021-44228 - https://api.example.com/login"},
+		{"api.example.com", "[MEDIUM] missing-csp - https://api.example.com/"},
+		{"www.example.com", "[CRITICAL] cve-2021-44228 - https://www.example.com/"},
+		{"www.example.com", "[INFO] tech-detect|nginx - https://www.example.com/"},
+	} {
+		f, ok := export.ParseVuln(stored.host, stored.vuln)
+		if !ok {
+			panic("unparseable fixture " + stored.vuln)
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+func TestParseVuln(t *testing.T) {
+	entry := export.FormatVuln("high", "exposed-panel", "https://a.example.com/admin - login")
+	f, ok := export.ParseVuln("a.example.com", entry)
+	require.True(t, ok)
+	assert.Equal(t, export.Finding{Host: "a.example.com", Template: "exposed-panel", Severity: "high", MatchedAt: "https://a.example.com/admin - login"}, f)
+
+	for _, bad := range []string{"", "exposed-panel", "[HIGH exposed-panel - x", "[HIGH] exposed-panel"} {
+		_, ok := export.ParseVuln("a.example.com", bad)
+		assert.False(t, ok, bad)
+	}
+}
+
+// sarifSchema is the OASIS SARIF 2.1.0 schema in testdata.
+func sarifSchema(t *testing.T) *jsonschema.Schema {
+	t.Helper()
+	file, err := os.Open("testdata/sarif-schema-2.1.0.json")
+	require.NoError(t, err)
+	defer file.Close()
+	doc, err := jsonschema.UnmarshalJSON(file)
+	require.NoError(t, err)
+
+	const url = "https://docs.oasis-open.org/sarif/sarif/v2.1.0/errata01/os/schemas/sarif-schema-2.1.0.json"
+	compiler := jsonschema.NewCompiler()
+	require.NoError(t, compiler.AddResource(url, doc))
+	schema, err := compiler.Compile(url)
+	require.NoError(t, err)
+	return schema
+}
+
+// TestSARIFSchema validates the export against the SARIF 2.1.0 schema, and
+// checks what the schema cannot: levels by severity and rule indexes.
+func TestSARIFSchema(t *testing.T) {
+	schema := sarifSchema(t)
+	validate := func(data []byte) {
+		t.Helper()
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.NoError(t, schema.Validate(doc))
+	}
+
+	data, err := export.SARIF(findings())
+	require.NoError(t, err)
+	validate(data)
+
+	var log map[string]any
+	require.NoError(t, json.Unmarshal(data, &log))
+	assert.Equal(t, "2.1.0", log["version"])
+	assert.Contains(t, log["$schema"], "sarif-2.1.0")
+
+	runs := log["runs"].([]any)
+	require.Len(t, runs, 1)
+	run := runs[0].(map[string]any)
+	driver := run["tool"].(map[string]any)["driver"].(map[string]any)
+	assert.Equal(t, "nuclei", driver["name"])
+
+	rules := driver["rules"].([]any)
+	require.Len(t, rules, 3, "one rule per template")
+	for _, r := range rules {
+		rule := r.(map[string]any)
+		assert.NotEmpty(t, rule["id"])
+		assert.Contains(t, []string{"none", "note", "warning", "error"}, rule["defaultConfiguration"].(map[string]any)["level"])
+	}
+
+	results := run["results"].([]any)
+	require.Len(t, results, 4)
+	wantLevels := []string{"error", "warning", "error", "note"}
+	for i, r := range results {
+		result := r.(map[string]any)
+		assert.NotEmpty(t, result["message"].(map[string]any)["text"])
+		assert.Equal(t, wantLevels[i], result["level"])
+
+		index := int(result["ruleIndex"].(float64))
+		require.Less(t, index, len(rules))
+		assert.Equal(t, rules[index].(map[string]any)["id"], result["ruleId"])
+
+		locations := result["locations"].([]any)
+		require.Len(t, locations, 1)
+		uri := locations[0].(map[string]any)["physicalLocation"].(map[string]any)["artifactLocation"].(map[string]any)["uri"]
+		assert.True(t, strings.HasPrefix(uri.(string), "https://"), uri)
+	}
+
+	// an empty export is still a valid log
+	data, err = export.SARIF(nil)
+	require.NoError(t, err)
+	validate(data)
+	assert.Contains(t, string(data), `"results": []`)
+
+	// the schema catches what a log is missing
+	var broken map[string]any
+	require.NoError(t, json.Unmarshal(data, &broken))
+	delete(broken["runs"].([]any)[0].(map[string]any), "tool")
+	data, err = json.Marshal(broken)
+	require.NoError(t, err)
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Error(t, schema.Validate(doc))
+}
+
+func TestMarkdown(t *testing.T) {
+	want := "# Findings for example.com\n\n" +
+		"4 findings (2 critical, 1 medium, 1 info)\n\n" +
+		"## Critical (2)\n\n" +
+		"| Template | Host | Matched at |\n|---|---|---|\n" +
+		"| cve-2021-44228 | api.example.com | `https://api.example.com/login` |\n" +
+		"| cve-2021-44228 | www.example.com | `https://www.example.com/` |\n\n" +
+		"## Medium (1)\n\n" +
+		"| Template | Host | Matched at |\n|---|---|---|\n" +
+		"| missing-csp | api.example.com | `https://api.example.com/` |\n\n" +
+		"## Info (1)\n\n" +
+		"| Template | Host | Matched at |\n|---|---|---|\n" +
+		"| tech-detect\\|nginx | www.example.com | `https://www.example.com/` |\n"
+	assert.Equal(t, want, string(export.Markdown("Findings for example.com", findings())))
+
+	assert.Equal(t, "# Findings\n\nNo findings.\n", string(export.Markdown("Findings", nil)))
+}
+
+func TestMarkdownCell(t *testing.T) {
+	for value, want := range map[string]string{
+		"tech-detect|nginx":          `tech-detect\|nginx`,
+		"Log4Shell\r\nRCE":           "Log4Shell RCE",
+		" a\tb  c\n":                 "a b c",
+		"https://a.example.com/?q=1": "https://a.example.com/?q=1",
+	} {
+		assert.Equal(t, want, export.MarkdownCell(value), "%q", value)
+	}
+
+	// a line break in a finding stays in its row
+	f := export.Finding{Host: "a.example.com", Template: "env\r\nfile", Severity: "high", MatchedAt: "https://a.example.com/\r\n.env"}
+	assert.Contains(t, string(export.Markdown("Findings", []export.Finding{f})), "| env file | a.example.com | `https://a.example.com/ .env` |\n")
+}
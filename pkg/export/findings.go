@@ -0,0 +1,56 @@
+// Package export converts stored scan findings into formats other tools
+// ingest.
+package export
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Finding is one nuclei result as stored on a subdomain.
+type Finding struct {
+	Host      string
+	Template  string
+	Severity  string
+	MatchedAt string
+}
+
+// FormatVuln renders a nuclei result the way it is stored in a subdomain's
+// vulns: "[SEVERITY] template - matched-at".
+func FormatVuln(severity, template, matchedAt string) string {
+	return fmt.Sprintf("[%s] %s - %s", strings.ToUpper(severity), template, matchedAt)
+}
+
+// ParseVuln reads an entry written by FormatVuln back into a Finding.
+func ParseVuln(host, entry string) (Finding, bool) {
+	if !strings.HasPrefix(entry, "[") {
+		return Finding{}, false
+	}
+	severity, rest, ok := strings.Cut(entry[1:], "] ")
+	if !ok {
+		return Finding{}, false
+	}
+	template, matchedAt, ok := strings.Cut(rest, " - ")
+	if !ok || template == "" {
+		return Finding{}, false
+	}
+	return Finding{
+		Host:      host,
+		Template:  template,
+		Severity:  strings.ToLower(severity),
+		MatchedAt: matchedAt,
+	}, true
+}
+
+// severities is the report order, most severe first.
+var severities = []string{"critical", "high", "medium", "low", "info", "unknown"}
+
+func normalizeSeverity(severity string) string {
+	severity = strings.ToLower(severity)
+	for _, s := range severities {
+		if s == severity {
+			return s
+		}
+	}
+	return "unknown"
+}
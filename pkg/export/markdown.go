@@ -0,0 +1,53 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Markdown renders findings grouped by severity, most severe first, for
+// pasting into tickets.
+func Markdown(title string, findings []Finding) []byte {
+	groups := make(map[string][]Finding)
+	for _, f := range findings {
+		severity := normalizeSeverity(f.Severity)
+		groups[severity] = append(groups[severity], f)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", title)
+	if len(findings) == 0 {
+		buf.WriteString("No findings.\n")
+		return buf.Bytes()
+	}
+
+	fmt.Fprintf(&buf, "%d findings", len(findings))
+	var counts []string
+	for _, severity := range severities {
+		if n := len(groups[severity]); n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, severity))
+		}
+	}
+	fmt.Fprintf(&buf, " (%s)\n", strings.Join(counts, ", "))
+
+	for _, severity := range severities {
+		group := groups[severity]
+		if len(group) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "\n## %s (%d)\n\n", strings.ToUpper(severity[:1])+severity[1:], len(group))
+		buf.WriteString("| Template | Host | Matched at |\n|---|---|---|\n")
+		for _, f := range group {
+			fmt.Fprintf(&buf, "| %s | %s | `%s` |\n", MarkdownCell(f.Template), MarkdownCell(f.Host), MarkdownCell(strings.ReplaceAll(f.MatchedAt, "`", "'")))
+		}
+	}
+	return buf.Bytes()
+}
+
+// MarkdownCell keeps a value from breaking out of its table cell: pipes are
+// escaped and runs of whitespace, line breaks included, become one space.
+func MarkdownCell(value string) string {
+	value = strings.ReplaceAll(value, "|", `\|`)
+	return strings.Join(strings.Fields(value), " ")
+}
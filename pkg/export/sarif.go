@@ -0,0 +1,149 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	Properties           sarifProperties    `json:"properties"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifProperties struct {
+	Tags             []string `json:"tags,omitempty"`
+	SecuritySeverity string   `json:"security-severity,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifLevel maps nuclei severities to SARIF levels.
+func sarifLevel(severity string) string {
+	switch normalizeSeverity(severity) {
+	case "critical", "high":
+		return "error"
+	case "medium":
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// securitySeverity is the CVSS-like score code scanning tools sort by.
+func securitySeverity(severity string) string {
+	switch normalizeSeverity(severity) {
+	case "critical":
+		return "9.5"
+	case "high":
+		return "8.0"
+	case "medium":
+		return "5.5"
+	case "low":
+		return "3.0"
+	default:
+		return "0.0"
+	}
+}
+
+// SARIF renders findings as a SARIF 2.1.0 log with one rule per template.
+// Locations are the matched-at URLs.
+func SARIF(findings []Finding) ([]byte, error) {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "nuclei",
+			InformationURI: "https://github.com/projectdiscovery/nuclei",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	ruleIndex := make(map[string]int)
+	for _, f := range findings {
+		index, ok := ruleIndex[f.Template]
+		if !ok {
+			index = len(run.Tool.Driver.Rules)
+			ruleIndex[f.Template] = index
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:                   f.Template,
+				Name:                 f.Template,
+				ShortDescription:     sarifMessage{Text: f.Template},
+				DefaultConfiguration: sarifConfiguration{Level: sarifLevel(f.Severity)},
+				Properties: sarifProperties{
+					Tags:             []string{"security", normalizeSeverity(f.Severity)},
+					SecuritySeverity: securitySeverity(f.Severity),
+				},
+			})
+		}
+
+		location := f.MatchedAt
+		if location == "" {
+			location = f.Host
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    f.Template,
+			RuleIndex: index,
+			Level:     sarifLevel(f.Severity),
+			Message:   sarifMessage{Text: fmt.Sprintf("%s matched at %s", f.Template, location)},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: location},
+			}}},
+		})
+	}
+
+	return json.MarshalIndent(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}}, "", "  ")
+}
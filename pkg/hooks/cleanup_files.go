@@ -0,0 +1,83 @@
+package hooks
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/tools"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CleanupFilesHook deletes files matching the comma separated globs in its
+// "patterns" param from the scan directory. Globs cannot reach outside it.
+type CleanupFilesHook struct {
+	logger *logger.Logger
+}
+
+func NewCleanupFilesHook() *CleanupFilesHook {
+	return &CleanupFilesHook{
+		logger: logger.NewLogger(logrus.InfoLevel),
+	}
+}
+
+func (h *CleanupFilesHook) Name() string {
+	return "cleanup_files"
+}
+
+func (h *CleanupFilesHook) Description() string {
+	return "Deletes files matching the patterns param (e.g. \"*.tmp,vpn_*.ovpn\") from the scan directory"
+}
+
+// Critical is false: a leftover file should not turn a scan into a failure.
+func (h *CleanupFilesHook) Critical() bool {
+	return false
+}
+
+func (h *CleanupFilesHook) Execute(ctx tools.HookContext) error {
+	patterns, _ := ctx.OtherData["patterns"].(string)
+	if strings.TrimSpace(patterns) == "" {
+		return fmt.Errorf("no patterns configured")
+	}
+
+	root, err := filepath.Abs(ctx.OutputDir)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	removed := 0
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if filepath.IsAbs(pattern) || strings.Contains(pattern, "..") {
+			errs = append(errs, fmt.Errorf("pattern %q must stay inside the scan directory", pattern))
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(root, pattern))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pattern %q: %w", pattern, err))
+			continue
+		}
+		for _, match := range matches {
+			info, err := os.Lstat(match)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			if err := os.Remove(match); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			removed++
+		}
+	}
+
+	h.logger.WithFields(logger.Fields{"dir": root, "removed": removed}).Info("Cleaned up scan files")
+	return errors.Join(errs...)
+}
@@ -22,15 +22,30 @@ func NewCombineOutput() *CombineOutput {
 	}
 }
 
+// CombineOutputFactory builds combine_output for a module's hooks
+// section. It takes no config.
+func CombineOutputFactory(config map[string]any) (tools.StageHook, error) {
+	if err := tools.DecodeHookConfig(config, &struct{}{}); err != nil {
+		return nil, err
+	}
+	return NewCombineOutput(), nil
+}
+
 func (c *CombineOutput) Name() string {
 	return "combine_output"
 }
 
 func (c *CombineOutput) Description() string {
-	return "Combines subdomain enumeration outputs from multiple tools into a single file (httpx_input.txt) for downstream processing"
+	return "Combines the outputs of the subdomain enumeration tools into a single file (httpx_input.txt) for downstream processing"
 }
 
 func (c *CombineOutput) ExecuteForStage(ctx tools.HookContext) error {
+	artifacts := ctx.Artifacts()
+	inputs, err := artifacts.ListByStage(tools.StageSubdomain)
+	if err != nil {
+		return fmt.Errorf("failed to list subdomain outputs: %w", err)
+	}
+
 	outputFile, err := os.Create(filepath.Join(ctx.OutputDir, "httpx_input.txt"))
 	if err != nil {
 		return fmt.Errorf("failed to create httpx_input.txt: %w", err)
@@ -38,44 +53,37 @@ func (c *CombineOutput) ExecuteForStage(ctx tools.HookContext) error {
 	defer outputFile.Close()
 
 	seenDomains := make(map[string]bool)
-
-	err = filepath.Walk(ctx.OutputDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
+	for _, input := range inputs {
+		if err := c.combine(artifacts, input.Name, outputFile, seenDomains); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		if !info.IsDir() && strings.HasPrefix(info.Name(), "subdomain_") {
-			inputFile, err := os.Open(path)
-			if err != nil {
-				return fmt.Errorf("failed to open %s: %w", path, err)
-			}
-			defer inputFile.Close()
-
-			scanner := bufio.NewScanner(inputFile)
-			for scanner.Scan() {
-				domain := strings.TrimSpace(scanner.Text())
-				if domain == "" {
-					continue
-				}
-
-				if !seenDomains[domain] {
-					_, err := outputFile.WriteString(domain + "\n")
-					if err != nil {
-						return fmt.Errorf("failed to write to httpx_input.txt: %w", err)
-					}
-					seenDomains[domain] = true
-				}
-			}
+func (c *CombineOutput) combine(artifacts *tools.Artifacts, name string, outputFile *os.File, seenDomains map[string]bool) error {
+	inputFile, err := artifacts.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer inputFile.Close()
 
-			if err := scanner.Err(); err != nil {
-				return fmt.Errorf("error scanning file %s: %w", path, err)
-			}
+	scanner := bufio.NewScanner(inputFile)
+	for scanner.Scan() {
+		domain := strings.TrimSpace(scanner.Text())
+		if domain == "" || seenDomains[domain] {
+			continue
 		}
+		if _, err := outputFile.WriteString(domain + "\n"); err != nil {
+			return fmt.Errorf("failed to write to httpx_input.txt: %w", err)
+		}
+		seenDomains[domain] = true
+	}
 
-		return nil
-	})
-
-	return err
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error scanning file %s: %w", name, err)
+	}
+	return nil
 }
 
 func (c *CombineOutput) PostHook(ctx tools.HookContext) error {
@@ -9,6 +9,7 @@ import (
 	"pipeliner/internal/notification"
 	"pipeliner/pkg/logger"
 	"pipeliner/pkg/parsers"
+	"pipeliner/pkg/scoring"
 	"pipeliner/pkg/tools"
 	"strings"
 	"sync"
@@ -18,7 +19,9 @@ import (
 )
 
 type NucleiNotifierHookConfig struct {
-	Filename string
+	// Filename overrides the output read; by default it is the output the
+	// hooked tool recorded in its manifest.
+	Filename string `yaml:"filename"`
 }
 
 type NucleiNotifierHook struct {
@@ -33,12 +36,30 @@ func NewNucleiNotifierHook(config NucleiNotifierHookConfig) *NucleiNotifierHook
 	}
 }
 
+// NucleiNotifierFactory builds the notifier for a module's hooks section.
+// A stage has no output of its own, so filename is required.
+func NucleiNotifierFactory(config map[string]any) (tools.StageHook, error) {
+	var cfg NucleiNotifierHookConfig
+	if err := tools.DecodeHookConfig(config, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Filename == "" {
+		return nil, fmt.Errorf("filename is required")
+	}
+	return NewNucleiNotifierHook(cfg), nil
+}
+
 func (n *NucleiNotifierHook) Name() string {
-	return "nuclei_notifier"
+	return "notifier"
 }
 
 func (n *NucleiNotifierHook) Description() string {
-	return "Sends Discord notifications for nuclei vulnerability findings"
+	return "Sends notifications for nuclei vulnerability findings"
+}
+
+// Critical is false: a notification outage should not fail a good nuclei run.
+func (n *NucleiNotifierHook) Critical() bool {
+	return false
 }
 
 func (n *NucleiNotifierHook) Execute(ctx tools.HookContext) error {
@@ -54,13 +75,7 @@ func (n *NucleiNotifierHook) PostHook(ctx tools.HookContext) error {
 }
 
 func (n *NucleiNotifierHook) executeNotification(ctx tools.HookContext) error {
-	filename := n.Config.Filename
-
-	if !filepath.IsAbs(filename) && ctx.OutputDir != "" {
-		filename = filepath.Join(ctx.OutputDir, filename)
-	}
-
-	file, err := os.Open(filename)
+	file, filename, err := n.openOutput(ctx)
 	if err != nil {
 		n.logger.WithFields(logger.Fields{
 			"filename": filename,
@@ -70,15 +85,15 @@ func (n *NucleiNotifierHook) executeNotification(ctx tools.HookContext) error {
 	}
 	defer file.Close()
 
-	discord, err := notification.NewNotificationClient()
+	notifier, err := notification.NewNotifier()
 	if err != nil {
-		n.logger.WithError(err).Error("Error creating discord client")
+		n.logger.WithError(err).Error("Error creating notifier")
 		return err
 	}
-	defer discord.Close()
+	defer notifier.Close()
 
 	const workerCount = 3
-	findings := make(chan parsers.NucleiResult)
+	findings := make(chan scoredNucleiResult)
 
 	var wg sync.WaitGroup
 
@@ -86,13 +101,13 @@ func (n *NucleiNotifierHook) executeNotification(ctx tools.HookContext) error {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for result := range findings {
-				msg := n.buildNucleiMessage(result)
-				if err := discord.Send(msg); err != nil {
+			for finding := range findings {
+				msg := n.buildNucleiMessage(finding.result, finding.score)
+				if err := notifier.Send(msg); err != nil {
 					n.logger.WithFields(logger.Fields{
-						"template": result.TemplateID,
+						"template": finding.result.TemplateID,
 						"error":    err,
-					}).Error("Failed to send Discord notification")
+					}).Error("Failed to send notification")
 				}
 				time.Sleep(500 * time.Millisecond)
 			}
@@ -114,12 +129,12 @@ func (n *NucleiNotifierHook) executeNotification(ctx tools.HookContext) error {
 			continue
 		}
 
-		severity := parsers.GetNucleiSeverity(result.Info)
-		if severity == "info" {
+		score := scoreNucleiResult(ctx.Options, result)
+		if score.Severity == "info" {
 			continue
 		}
 
-		findings <- result
+		findings <- scoredNucleiResult{result: result, score: score}
 	}
 
 	close(findings)
@@ -132,8 +147,53 @@ func (n *NucleiNotifierHook) executeNotification(ctx tools.HookContext) error {
 	return nil
 }
 
-func (n *NucleiNotifierHook) buildNucleiMessage(result parsers.NucleiResult) notification.Message {
+// openOutput opens Config.Filename if set, otherwise the hooked tool's
+// recorded output.
+func (n *NucleiNotifierHook) openOutput(ctx tools.HookContext) (*os.File, string, error) {
+	if filename := n.Config.Filename; filename != "" {
+		if !filepath.IsAbs(filename) && ctx.OutputDir != "" {
+			filename = filepath.Join(ctx.OutputDir, filename)
+		}
+		file, err := os.Open(filename)
+		return file, filename, err
+	}
+
+	artifacts := ctx.Artifacts()
+	outputs, err := artifacts.ListByTool(ctx.ToolName)
+	if err != nil {
+		return nil, ctx.ToolName, err
+	}
+	if len(outputs) == 0 {
+		return nil, ctx.ToolName, fmt.Errorf("tool %s recorded no output", ctx.ToolName)
+	}
+	file, err := artifacts.Open(outputs[0].Name)
+	return file, outputs[0].Name, err
+}
+
+type scoredNucleiResult struct {
+	result parsers.NucleiResult
+	score  scoring.Result
+}
+
+// scoreNucleiResult applies the scan's severity rules, if any, to result.
+func scoreNucleiResult(options *tools.Options, result parsers.NucleiResult) scoring.Result {
 	severity := parsers.GetNucleiSeverity(result.Info)
+	if options == nil {
+		return scoring.Result{Severity: severity}
+	}
+	host := result.Host
+	if host == "" {
+		host = result.URL
+	}
+	return options.SeverityPolicy.Apply(scoring.Subject{
+		Host: host,
+		Tool: "nuclei",
+		Tags: parsers.GetNucleiTagList(result.Info),
+	}, severity)
+}
+
+func (n *NucleiNotifierHook) buildNucleiMessage(result parsers.NucleiResult, score scoring.Result) notification.Message {
+	severity := score.Severity
 	templateName := parsers.GetNucleiTemplateName(result.Info)
 	description := parsers.GetNucleiDescription(result.Info)
 
@@ -154,6 +214,7 @@ func (n *NucleiNotifierHook) buildNucleiMessage(result parsers.NucleiResult) not
 		Title:       fmt.Sprintf("%s %s", parsers.GetSeverityEmoji(severity), templateName),
 		Description: descText,
 		Severity:    severity,
+		EventType:   notification.EventFinding,
 		Fields: map[string]string{
 			"Severity": strings.ToUpper(severity),
 			"Host":     host,
@@ -168,6 +229,13 @@ func (n *NucleiNotifierHook) buildNucleiMessage(result parsers.NucleiResult) not
 		msg.Fields["IP"] = result.IP
 	}
 
+	if original := parsers.GetNucleiSeverity(result.Info); original != severity {
+		msg.Fields["Template Severity"] = strings.ToUpper(original)
+	}
+	if len(score.Labels) > 0 {
+		msg.Fields["Labels"] = strings.Join(score.Labels, ", ")
+	}
+
 	tags := parsers.GetNucleiTags(result.Info)
 	if tags != "" {
 		msg.Fields["Tags"] = tags
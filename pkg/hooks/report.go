@@ -0,0 +1,340 @@
+package hooks
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pipeliner/pkg/export"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/parsers"
+	"pipeliner/pkg/tools"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ReportFile is the Markdown report ReportHook writes in the scan directory.
+const ReportFile = "report.md"
+
+//go:embed templates/report.md.tmpl
+var defaultReportTemplate string
+
+// reportSeverities are the order findings are grouped in.
+var reportSeverities = []string{"critical", "high", "medium", "low", "info", "unknown"}
+
+type ReportHookConfig struct {
+	// TemplatePath replaces the built-in text/template, which is executed
+	// with a ReportData.
+	TemplatePath string
+}
+
+// ReportData is what a report template is executed with.
+type ReportData struct {
+	Target     string
+	Date       time.Time
+	Subdomains int
+	Ports      []ReportPort
+	// Sensitive are the sensitive ffuf hits by category, in category order.
+	Sensitive []ReportCategory
+	// Findings are the nuclei findings by severity, most severe first.
+	Findings       []ReportSeverity
+	SensitiveCount int
+	FindingCount   int
+}
+
+type ReportPort struct {
+	Host     string
+	Port     int
+	Protocol string
+	Service  string
+}
+
+type ReportCategory struct {
+	Category string
+	Hits     []ReportHit
+}
+
+type ReportHit struct {
+	URL         string
+	Status      int
+	Severity    string
+	Description string
+}
+
+type ReportSeverity struct {
+	Severity string
+	Findings []ReportFinding
+}
+
+type ReportFinding struct {
+	Name       string
+	TemplateID string
+	MatchedAt  string
+}
+
+// ReportHook writes a Markdown summary of the scan when the vuln stage
+// completes: open ports, sensitive endpoints and nuclei findings.
+type ReportHook struct {
+	Config ReportHookConfig
+	logger *logger.Logger
+	now    func() time.Time
+}
+
+func NewReportHook(config ReportHookConfig) *ReportHook {
+	return &ReportHook{
+		Config: config,
+		logger: logger.NewLogger(logrus.InfoLevel),
+		now:    time.Now,
+	}
+}
+
+func (r *ReportHook) Name() string {
+	return "report"
+}
+
+func (r *ReportHook) Description() string {
+	return "Writes a Markdown report (report.md) of the ports, sensitive endpoints and findings when the vuln stage completes"
+}
+
+func (r *ReportHook) ExecuteForStage(ctx tools.HookContext) error {
+	tmpl, err := r.template()
+	if err != nil {
+		return err
+	}
+	data, err := r.collect(ctx)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return fmt.Errorf("failed to render report: %w", err)
+	}
+	path := filepath.Join(ctx.OutputDir, ReportFile)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	r.logger.Infof("Wrote scan report to %s", path)
+	return nil
+}
+
+func (r *ReportHook) template() (*template.Template, error) {
+	text := defaultReportTemplate
+	if r.Config.TemplatePath != "" {
+		data, err := os.ReadFile(r.Config.TemplatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read report template: %w", err)
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New(ReportFile).Funcs(template.FuncMap{
+		"cell":  export.MarkdownCell,
+		"title": severityTitle,
+		"upper": strings.ToUpper,
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse report template: %w", err)
+	}
+	return tmpl, nil
+}
+
+func (r *ReportHook) collect(ctx tools.HookContext) (*ReportData, error) {
+	data := &ReportData{Date: r.now().UTC()}
+	if ctx.Options != nil {
+		data.Target = ctx.Options.Domain
+	}
+
+	subdomains, err := CountSubdomains(ctx, tools.StageSubdomain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count subdomains: %w", err)
+	}
+	data.Subdomains = subdomains["Subdomains"]
+
+	artifacts := ctx.Artifacts()
+	outputs, err := artifacts.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outputs: %w", err)
+	}
+	for _, output := range outputs {
+		switch {
+		case filepath.Ext(output.Name) == ".xml":
+			err = r.addPorts(data, artifacts, output.Name)
+		case strings.HasPrefix(output.Tool, "ffuf"):
+			err = r.addSensitive(data, artifacts, output.Name)
+		case output.Stage == tools.StageVuln:
+			err = r.addFindings(data, artifacts, output.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	sortReport(data)
+	return data, nil
+}
+
+// addPorts adds the open ports of an nmap XML output.
+func (r *ReportHook) addPorts(data *ReportData, artifacts *tools.Artifacts, name string) error {
+	file, err := artifacts.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var run parsers.NmapRun
+	if err := xml.NewDecoder(file).Decode(&run); err != nil {
+		return fmt.Errorf("failed to parse nmap output %s: %w", name, err)
+	}
+
+	for _, host := range run.Hosts {
+		name := nmapHostName(host)
+		for _, port := range host.Ports.PortList {
+			if port.State.State != "open" {
+				continue
+			}
+			number, _ := strconv.Atoi(port.PortID)
+			data.Ports = append(data.Ports, ReportPort{Host: name, Port: number, Protocol: port.Protocol, Service: port.Service.Name})
+		}
+	}
+	return nil
+}
+
+// nmapHostName is the name the host was scanned by, or else its address.
+func nmapHostName(host parsers.Host) string {
+	for _, hostname := range host.Hostnames.HostnameList {
+		if hostname.Type == "user" {
+			return hostname.Name
+		}
+	}
+	if len(host.Hostnames.HostnameList) > 0 {
+		return host.Hostnames.HostnameList[0].Name
+	}
+	if len(host.Addresses) > 0 {
+		return host.Addresses[0].Addr
+	}
+	return ""
+}
+
+// addSensitive adds the hits of an ffuf output that match a sensitive
+// pattern, with the status codes the artifact processor keeps.
+func (r *ReportHook) addSensitive(data *ReportData, artifacts *tools.Artifacts, name string) error {
+	file, err := artifacts.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var output parsers.FuffOutput
+	if err := json.NewDecoder(file).Decode(&output); err != nil {
+		r.logger.Warnf("Skipping unreadable ffuf output %s: %v", name, err)
+		return nil
+	}
+
+results:
+	for _, result := range output.Results {
+		if result.Status < 200 || result.Status >= 400 {
+			continue
+		}
+		pattern, found := parsers.DetectSensitivePattern(result.URL, "")
+		if !found {
+			continue
+		}
+		hit := ReportHit{URL: result.URL, Status: result.Status, Severity: pattern.Severity, Description: pattern.Description}
+		data.SensitiveCount++
+		for i := range data.Sensitive {
+			if data.Sensitive[i].Category == pattern.Category {
+				data.Sensitive[i].Hits = append(data.Sensitive[i].Hits, hit)
+				continue results
+			}
+		}
+		data.Sensitive = append(data.Sensitive, ReportCategory{Category: pattern.Category, Hits: []ReportHit{hit}})
+	}
+	return nil
+}
+
+// addFindings adds the nuclei results of a vuln stage output, once per
+// template and matched-at URL.
+func (r *ReportHook) addFindings(data *ReportData, artifacts *tools.Artifacts, name string) error {
+	seen := make(map[[2]string]bool)
+	for _, group := range data.Findings {
+		for _, finding := range group.Findings {
+			seen[[2]string{finding.TemplateID, finding.MatchedAt}] = true
+		}
+	}
+
+	return eachLine(artifacts, name, func(line string) {
+		var result parsers.NucleiResult
+		if json.Unmarshal([]byte(line), &result) != nil || result.TemplateID == "" {
+			return
+		}
+		key := [2]string{result.TemplateID, result.MatchedAt}
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+
+		severity := strings.ToLower(parsers.GetNucleiSeverity(result.Info))
+		if !slices.Contains(reportSeverities, severity) {
+			severity = "unknown"
+		}
+		finding := ReportFinding{Name: parsers.GetNucleiTemplateName(result.Info), TemplateID: result.TemplateID, MatchedAt: result.MatchedAt}
+		if finding.Name == "" {
+			finding.Name = result.TemplateID
+		}
+		for i := range data.Findings {
+			if data.Findings[i].Severity == severity {
+				data.Findings[i].Findings = append(data.Findings[i].Findings, finding)
+				data.FindingCount++
+				return
+			}
+		}
+		data.Findings = append(data.Findings, ReportSeverity{Severity: severity, Findings: []ReportFinding{finding}})
+		data.FindingCount++
+	})
+}
+
+// sortReport puts everything in a stable order, whatever order the
+// outputs were read in.
+func sortReport(data *ReportData) {
+	sort.Slice(data.Ports, func(i, j int) bool {
+		a, b := data.Ports[i], data.Ports[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		return a.Protocol < b.Protocol
+	})
+	sort.Slice(data.Sensitive, func(i, j int) bool { return data.Sensitive[i].Category < data.Sensitive[j].Category })
+	for _, category := range data.Sensitive {
+		sort.Slice(category.Hits, func(i, j int) bool { return category.Hits[i].URL < category.Hits[j].URL })
+	}
+	rank := make(map[string]int, len(reportSeverities))
+	for i, severity := range reportSeverities {
+		rank[severity] = i
+	}
+	sort.Slice(data.Findings, func(i, j int) bool { return rank[data.Findings[i].Severity] < rank[data.Findings[j].Severity] })
+	for _, group := range data.Findings {
+		sort.Slice(group.Findings, func(i, j int) bool {
+			a, b := group.Findings[i], group.Findings[j]
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			return a.MatchedAt < b.MatchedAt
+		})
+	}
+}
+
+func severityTitle(value string) string {
+	if value == "" {
+		return value
+	}
+	return strings.ToUpper(value[:1]) + value[1:]
+}
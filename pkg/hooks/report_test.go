@@ -0,0 +1,112 @@
+package hooks
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pipeliner/pkg/tools"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// writeOutput writes a tool's output and the manifest that lists it.
+func writeOutput(t *testing.T, dir, tool string, stage tools.Stage, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := &tools.OutputManifest{Tool: tool, Stage: stage, Files: []string{name}}
+	if err := tools.WriteOutputManifest(filepath.Join(dir, tools.OutputManifestFile(tool)), manifest); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newReportScanDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeOutput(t, dir, "subfinder", tools.StageSubdomain, "subfinder_output.txt", "a.example.com\nb.example.com\na.example.com\n")
+	writeOutput(t, dir, "nmap", tools.StageFingerPrinting, "nmap_output.xml", `<nmaprun>
+<host><address addr="10.0.0.2" addrtype="ipv4"/><hostnames><hostname name="b.example.com" type="user"/></hostnames>
+<ports><port protocol="tcp" portid="22"><state state="open"/><service name="ssh"/></port></ports></host>
+<host><address addr="10.0.0.1" addrtype="ipv4"/><hostnames><hostname name="a.example.com" type="user"/></hostnames>
+<ports>
+<port protocol="tcp" portid="8443"><state state="open"/><service name="https|alt"/></port>
+<port protocol="tcp" portid="443"><state state="open"/><service name="https"/></port>
+<port protocol="tcp" portid="25"><state state="filtered"/><service name="smtp"/></port>
+</ports></host>
+</nmaprun>`)
+	writeOutput(t, dir, "ffuf", tools.StageRecon, "ffuf_output.json", `{"results":[
+{"url":"https://a.example.com/.git/config","status":200},
+{"url":"https://a.example.com/actuator/env","status":200},
+{"url":"https://a.example.com/.env","status":404},
+{"url":"https://a.example.com/index.html","status":200}
+]}`)
+	writeOutput(t, dir, "nuclei", tools.StageVuln, "nuclei_output.json", `{"template-id":"tech-detect","info":{"name":"Tech Detect","severity":"info"},"host":"a.example.com","matched-at":"https://a.example.com/"}
+{"template-id":"env-file","info":{"name":"Env File","severity":"high"},"host":"a.example.com","matched-at":"https://a.example.com/.env"}
+not json
+{"template-id":"log4j","info":{"name":"Log4Shell","severity":"critical"},"host":"b.example.com","matched-at":"https://b.example.com/api"}
+{"template-id":"env-file","info":{"name":"Env File","severity":"high"},"host":"a.example.com","matched-at":"https://a.example.com/.env"}
+`)
+	return dir
+}
+
+func TestReportHook_Golden(t *testing.T) {
+	dir := newReportScanDir(t)
+	hook := NewReportHook(ReportHookConfig{})
+	hook.now = func() time.Time { return time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC) }
+
+	ctx := tools.HookContext{OutputDir: dir, ToolName: string(tools.StageVuln), Options: &tools.Options{Domain: "example.com"}}
+	if err := hook.ExecuteForStage(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, ReportFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "report.golden.md")
+	if *updateGolden {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("report differs from %s (run with -update to accept):\n%s", golden, got)
+	}
+}
+
+func TestReportHook_TemplateOverride(t *testing.T) {
+	dir := newReportScanDir(t)
+	tmpl := filepath.Join(t.TempDir(), "report.tmpl")
+	if err := os.WriteFile(tmpl, []byte(`{{ .Target }}: {{ .Subdomains }} subdomains, {{ .FindingCount }} findings{{ range .Findings }}, {{ upper .Severity }}{{ end }}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hook := NewReportHook(ReportHookConfig{TemplatePath: tmpl})
+	ctx := tools.HookContext{OutputDir: dir, ToolName: string(tools.StageVuln), Options: &tools.Options{Domain: "example.com"}}
+	if err := hook.ExecuteForStage(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, ReportFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "example.com: 2 subdomains, 3 findings, CRITICAL, HIGH, INFO"; string(got) != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	if err := os.WriteFile(tmpl, []byte(`{{ .Missing`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.ExecuteForStage(ctx); err == nil || !strings.Contains(err.Error(), "parse report template") {
+		t.Fatalf("expected a template error, got %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package hooks
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"pipeliner/internal/notification"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/parsers"
+	"pipeliner/pkg/tools"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StageCounter counts what a stage produced, by the field name each count
+// is reported under.
+type StageCounter func(ctx tools.HookContext, stage tools.Stage) (map[string]int, error)
+
+// DefaultStageCounters are the counters of the stages that have one; other
+// stages are summarized by the number of outputs their tools wrote.
+var DefaultStageCounters = map[tools.Stage]StageCounter{
+	tools.StageSubdomain:      CountSubdomains,
+	tools.StageFingerPrinting: CountOpenPorts,
+	tools.StageVuln:           CountFindings,
+}
+
+type StageSummaryHookConfig struct {
+	// Counters replaces the counters of the stages it has, on top of
+	// DefaultStageCounters.
+	Counters map[tools.Stage]StageCounter
+	// Skip lists the stages no summary is sent for.
+	Skip []tools.Stage
+}
+
+type StageSummaryHook struct {
+	Config StageSummaryHookConfig
+	logger *logger.Logger
+	now    func() time.Time
+}
+
+func NewStageSummaryHook(config StageSummaryHookConfig) *StageSummaryHook {
+	return &StageSummaryHook{
+		Config: config,
+		logger: logger.NewLogger(logrus.InfoLevel),
+		now:    time.Now,
+	}
+}
+
+// StageSummaryFactory builds stage_summary for a module's hooks section.
+// With skip set, the stage it is declared for gets no summary.
+func StageSummaryFactory(config map[string]any) (tools.StageHook, error) {
+	var cfg struct {
+		Skip bool `yaml:"skip"`
+	}
+	if err := tools.DecodeHookConfig(config, &cfg); err != nil {
+		return nil, err
+	}
+	var summary StageSummaryHookConfig
+	if cfg.Skip {
+		summary.Skip = tools.Stages
+	}
+	return NewStageSummaryHook(summary), nil
+}
+
+func (s *StageSummaryHook) Name() string {
+	return "stage_summary"
+}
+
+func (s *StageSummaryHook) Description() string {
+	return "Sends a notification with what a stage produced and how long it took when the stage completes"
+}
+
+func (s *StageSummaryHook) ExecuteForStage(ctx tools.HookContext) error {
+	stage := tools.Stage(ctx.ToolName)
+	for _, skip := range s.Config.Skip {
+		if skip == stage {
+			return nil
+		}
+	}
+
+	notifier, err := notification.NewNotifier()
+	if errors.Is(err, notification.ErrNoBackend) {
+		s.logger.Debugf("No notification backend configured, not summarizing stage %s", stage)
+		return nil
+	}
+	if err != nil {
+		s.logger.WithError(err).Error("Error creating notifier")
+		return err
+	}
+	defer notifier.Close()
+
+	counts, err := s.counter(stage)(ctx, stage)
+	if err != nil {
+		return fmt.Errorf("failed to count the outputs of stage %s: %w", stage, err)
+	}
+	return notifier.Send(s.buildSummaryMessage(ctx, stage, counts))
+}
+
+func (s *StageSummaryHook) counter(stage tools.Stage) StageCounter {
+	if counter, ok := s.Config.Counters[stage]; ok {
+		return counter
+	}
+	if counter, ok := DefaultStageCounters[stage]; ok {
+		return counter
+	}
+	return CountOutputs
+}
+
+func (s *StageSummaryHook) buildSummaryMessage(ctx tools.HookContext, stage tools.Stage, counts map[string]int) notification.Message {
+	title := fmt.Sprintf("✅ Stage %s completed", stage)
+	if ctx.Options != nil && ctx.Options.Domain != "" {
+		title = fmt.Sprintf("%s for %s", title, ctx.Options.Domain)
+	}
+
+	msg := notification.Message{
+		Title:     title,
+		EventType: notification.EventScanLifecycle,
+		Fields:    make(map[string]string, len(counts)+1),
+	}
+	for name, count := range counts {
+		msg.Fields[name] = strconv.Itoa(count)
+	}
+	if !ctx.StageStartedAt.IsZero() {
+		msg.Fields["Elapsed"] = s.now().Sub(ctx.StageStartedAt).Round(time.Second).String()
+	}
+	return msg
+}
+
+// CountOutputs counts the outputs the stage's tools wrote.
+func CountOutputs(ctx tools.HookContext, stage tools.Stage) (map[string]int, error) {
+	outputs, err := ctx.Artifacts().ListByStage(stage)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int{"Outputs": len(outputs)}, nil
+}
+
+// CountSubdomains counts the distinct subdomains found, the lines
+// combine_output writes to httpx_input.txt. They are read from the tools'
+// outputs, as combine_output runs alongside this hook.
+func CountSubdomains(ctx tools.HookContext, stage tools.Stage) (map[string]int, error) {
+	artifacts := ctx.Artifacts()
+	outputs, err := artifacts.ListByStage(stage)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, output := range outputs {
+		err := eachLine(artifacts, output.Name, func(line string) {
+			seen[line] = true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return map[string]int{"Subdomains": len(seen)}, nil
+}
+
+// CountOpenPorts counts the open ports in the stage's nmap XML outputs and
+// the hosts they are on.
+func CountOpenPorts(ctx tools.HookContext, stage tools.Stage) (map[string]int, error) {
+	artifacts := ctx.Artifacts()
+	outputs, err := artifacts.ListByStage(stage)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{"Hosts": 0, "Open ports": 0}
+	for _, output := range outputs {
+		if filepath.Ext(output.Name) != ".xml" {
+			continue
+		}
+		file, err := artifacts.Open(output.Name)
+		if err != nil {
+			return nil, err
+		}
+		var run parsers.NmapRun
+		err = xml.NewDecoder(file).Decode(&run)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse nmap output %s: %w", output.Name, err)
+		}
+
+		for _, host := range run.Hosts {
+			open := 0
+			for _, port := range host.Ports.PortList {
+				if port.State.State == "open" {
+					open++
+				}
+			}
+			if open > 0 {
+				counts["Hosts"]++
+				counts["Open ports"] += open
+			}
+		}
+	}
+	return counts, nil
+}
+
+// CountFindings counts the nuclei findings in the stage's outputs by
+// severity.
+func CountFindings(ctx tools.HookContext, stage tools.Stage) (map[string]int, error) {
+	artifacts := ctx.Artifacts()
+	outputs, err := artifacts.ListByStage(stage)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{"Findings": 0}
+	for _, output := range outputs {
+		err := eachLine(artifacts, output.Name, func(line string) {
+			var result parsers.NucleiResult
+			if json.Unmarshal([]byte(line), &result) != nil || result.TemplateID == "" {
+				return
+			}
+			severity := parsers.GetNucleiSeverity(result.Info)
+			if severity == "" {
+				severity = "unknown"
+			}
+			counts["Findings"]++
+			counts[strings.ToUpper(severity[:1])+severity[1:]]++
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+// eachLine calls fn with every non-empty line of an artifact.
+func eachLine(artifacts *tools.Artifacts, name string, fn func(line string)) error {
+	file, err := artifacts.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			fn(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error scanning file %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package hooks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"pipeliner/pkg/tools"
+)
+
+// a declared hook replaces the registered one of its factory's name
+func TestFactoriesNameHooksAfterThemselves(t *testing.T) {
+	for name, factory := range map[string]tools.HookFactory{
+		"combine_output": CombineOutputFactory,
+		"notifier":       NucleiNotifierFactory,
+		"stage_summary":  StageSummaryFactory,
+	} {
+		config := map[string]any{}
+		if name == "notifier" {
+			config["filename"] = "nuclei_output.json"
+		}
+		hook, err := factory(config)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if hook.Name() != name {
+			t.Errorf("factory %s built a hook named %s", name, hook.Name())
+		}
+	}
+}
+
+func TestStageSummaryFactory_Skip(t *testing.T) {
+	hook, err := StageSummaryFactory(map[string]any{"skip": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hook.(*StageSummaryHook).Config.Skip; len(got) != len(tools.Stages) {
+		t.Errorf("skip = %v, want every stage", got)
+	}
+	if _, err := StageSummaryFactory(map[string]any{"skp": true}); err == nil {
+		t.Error("expected an unknown config key to be rejected")
+	}
+}
+
+func TestStageSummaryHook_Message(t *testing.T) {
+	dir := newReportScanDir(t)
+	writeOutput(t, dir, "amass", tools.StageSubdomain, "amass_output.txt", "b.example.com\nc.example.com\n\n")
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		stage   tools.Stage
+		elapsed time.Duration
+		want    map[string]string
+	}{
+		// the lines combine_output writes to httpx_input.txt
+		{tools.StageSubdomain, 90 * time.Second, map[string]string{"Subdomains": "3", "Elapsed": "1m30s"}},
+		{tools.StageFingerPrinting, 2*time.Minute + 400*time.Millisecond, map[string]string{"Hosts": "2", "Open ports": "3", "Elapsed": "2m0s"}},
+		{tools.StageVuln, time.Hour, map[string]string{"Findings": "4", "Info": "1", "High": "2", "Critical": "1", "Elapsed": "1h0m0s"}},
+		{tools.StageRecon, 0, map[string]string{"Outputs": "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.stage), func(t *testing.T) {
+			hook := NewStageSummaryHook(StageSummaryHookConfig{})
+			hook.now = func() time.Time { return start.Add(tt.elapsed) }
+			ctx := tools.HookContext{OutputDir: dir, ToolName: string(tt.stage), Options: &tools.Options{Domain: "example.com"}}
+			if tt.elapsed > 0 {
+				ctx.StageStartedAt = start
+			}
+
+			counts, err := hook.counter(tt.stage)(ctx, tt.stage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := hook.buildSummaryMessage(ctx, tt.stage, counts)
+			if want := "✅ Stage " + string(tt.stage) + " completed for example.com"; msg.Title != want {
+				t.Errorf("title = %q, want %q", msg.Title, want)
+			}
+			if !reflect.DeepEqual(tt.want, msg.Fields) {
+				t.Errorf("fields = %v, want %v", msg.Fields, tt.want)
+			}
+		})
+	}
+}
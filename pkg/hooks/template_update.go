@@ -0,0 +1,80 @@
+package hooks
+
+import (
+	"fmt"
+	"os/exec"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/tools"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+type TemplateUpdateHookConfig struct {
+	// Command is used when the pre_run entry has no "command" param.
+	Command string
+}
+
+// TemplateUpdateHook runs an update command, such as refreshing nuclei
+// templates, as a pre_run step.
+type TemplateUpdateHook struct {
+	Config TemplateUpdateHookConfig
+	logger *logger.Logger
+}
+
+func NewTemplateUpdateHook(config TemplateUpdateHookConfig) *TemplateUpdateHook {
+	if config.Command == "" {
+		config.Command = "nuclei -update-templates"
+	}
+	return &TemplateUpdateHook{
+		Config: config,
+		logger: logger.NewLogger(logrus.InfoLevel),
+	}
+}
+
+func (h *TemplateUpdateHook) Name() string {
+	return "template_update"
+}
+
+func (h *TemplateUpdateHook) Description() string {
+	return "Runs an update command (default: nuclei -update-templates) before the first tool"
+}
+
+// Critical is false: scanning with the previous templates beats not
+// scanning. Set critical: true on the pre_run entry to require the update.
+func (h *TemplateUpdateHook) Critical() bool {
+	return false
+}
+
+func (h *TemplateUpdateHook) Execute(ctx tools.HookContext) error {
+	command := h.Config.Command
+	if param, ok := ctx.OtherData["command"].(string); ok && param != "" {
+		command = param
+	}
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return fmt.Errorf("no update command configured")
+	}
+
+	// pinned binaries are checked like any tool
+	if err := tools.BinaryVerifierFromContext(ctx.Context()).Verify(fields[0]); err != nil {
+		return err
+	}
+
+	h.logger.WithFields(logger.Fields{"command": command}).Info("Running template update")
+	cmd := exec.CommandContext(ctx.Context(), fields[0], fields[1:]...)
+	cmd.Dir = ctx.OutputDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", command, err, strings.TrimSpace(lastLines(string(out), 5)))
+	}
+	return nil
+}
+
+// lastLines keeps the end of a command's output for error messages.
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,141 @@
+package hooks
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/tools"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type WordlistPreparerHookConfig struct {
+	// URLs maps wordlist paths, as the tool's flag gives them, to where a
+	// missing one is downloaded from.
+	URLs map[string]string
+	// Flag is the tool flag holding the wordlist path, "-w" by default.
+	Flag   string
+	Client *http.Client
+}
+
+// WordlistPreparerHook makes sure the wordlist a tool reads exists before
+// the tool starts, downloading it when it is missing.
+type WordlistPreparerHook struct {
+	Config WordlistPreparerHookConfig
+	logger *logger.Logger
+}
+
+func NewWordlistPreparerHook(config WordlistPreparerHookConfig) *WordlistPreparerHook {
+	if config.Flag == "" {
+		config.Flag = "-w"
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	return &WordlistPreparerHook{
+		Config: config,
+		logger: logger.NewLogger(logrus.InfoLevel),
+	}
+}
+
+// ParseWordlistURLs reads "path=url" pairs separated by commas, as
+// PIPELINER_WORDLIST_URLS holds them.
+func ParseWordlistURLs(value string) map[string]string {
+	urls := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		path, url, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && path != "" && url != "" {
+			urls[strings.TrimSpace(path)] = strings.TrimSpace(url)
+		}
+	}
+	return urls
+}
+
+func (h *WordlistPreparerHook) Name() string {
+	return "wordlist_preparer"
+}
+
+func (h *WordlistPreparerHook) Description() string {
+	return "Checks the tool's wordlist (-w) exists before it starts and downloads missing ones from PIPELINER_WORDLIST_URLS"
+}
+
+func (h *WordlistPreparerHook) Execute(ctx tools.HookContext) error {
+	wordlist := ""
+	for _, flag := range ctx.ToolConfig.Flags {
+		if flag.Flag == h.Config.Flag {
+			wordlist = strings.TrimSpace(flag.Default)
+			break
+		}
+	}
+	if wordlist == "" {
+		return fmt.Errorf("tool %s has no %s flag with a wordlist", ctx.ToolName, h.Config.Flag)
+	}
+
+	path := wordlist
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ctx.OutputDir, path)
+	}
+	info, err := os.Stat(path)
+	if err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("wordlist %s is a directory", wordlist)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	url, ok := h.Config.URLs[wordlist]
+	if !ok {
+		return fmt.Errorf("wordlist %s does not exist and no download URL is configured for it", wordlist)
+	}
+	h.logger.WithFields(logger.Fields{"wordlist": wordlist, "url": url, "tool": ctx.ToolName}).Info("Downloading missing wordlist")
+	return h.download(ctx, url, path)
+}
+
+// download writes url to path through a temporary file, so a tool never
+// reads half a wordlist and concurrent downloads do not clash.
+func (h *WordlistPreparerHook) download(ctx tools.HookContext, url, path string) error {
+	req, err := http.NewRequestWithContext(ctx.Context(), http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.Config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading %s: %s", url, resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("downloading %s: %w", url, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("downloading %s: empty response", url)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,249 @@
+// Package hostlimit spaces out the requests the scans of one process send
+// to the same host, so scans of overlapping scope do not add up.
+package hostlimit
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// activeWindow is how long a scan counts as active on a host after its last
+// request there. Hosts idle for longer are forgotten.
+const activeWindow = time.Minute
+
+// Limiter keeps every host under a shared request budget. Requests are
+// given start times in the order they ask, so concurrent scans of a host
+// take turns.
+type Limiter struct {
+	interval time.Duration // between two requests to a host; 0 is no limit
+	mu       sync.Mutex
+	hosts    map[string]*hostState
+	now      func() time.Time
+	after    func(time.Duration) <-chan time.Time
+}
+
+type hostState struct {
+	next     time.Time            // earliest start of the next request
+	scans    map[string]time.Time // last request of each scan
+	requests int64
+	delayed  int64
+	waited   time.Duration
+	waiting  int
+}
+
+// HostStats is what a Limiter has seen of one host.
+type HostStats struct {
+	Host string `json:"host"`
+	// Scans are the scans that sent requests there in the last minute.
+	Scans         []string `json:"scans"`
+	Requests      int64    `json:"requests"`
+	Delayed       int64    `json:"delayed"`
+	WaitedSeconds float64  `json:"waited_seconds"`
+	Waiting       int      `json:"waiting"`
+}
+
+var (
+	globalLimiter *Limiter
+	globalMu      sync.Mutex
+)
+
+// New creates a limiter allowing perSecond requests to each host; zero or
+// less only tracks them.
+func New(perSecond float64) *Limiter {
+	var interval time.Duration
+	if perSecond > 0 {
+		interval = time.Duration(float64(time.Second) / perSecond)
+	}
+	return &Limiter{
+		interval: interval,
+		hosts:    make(map[string]*hostState),
+		now:      time.Now,
+		after:    time.After,
+	}
+}
+
+// InitGlobal sets the budget of the global limiter. Calls after the first
+// one are no-ops.
+func InitGlobal(perSecond float64) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	if globalLimiter == nil {
+		globalLimiter = New(perSecond)
+	}
+}
+
+// Global returns the limiter shared by all scans, which only tracks
+// requests unless InitGlobal set a budget.
+func Global() *Limiter {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	if globalLimiter == nil {
+		globalLimiter = New(0)
+	}
+	return globalLimiter
+}
+
+// SetGlobal replaces the global limiter; nil makes the next InitGlobal or
+// Global call create a fresh one.
+func SetGlobal(l *Limiter) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	globalLimiter = l
+}
+
+// PerSecond is the budget of each host, or 0 without one.
+func (l *Limiter) PerSecond() float64 {
+	if l.interval <= 0 {
+		return 0
+	}
+	return float64(time.Second) / float64(l.interval)
+}
+
+// Wait blocks until scan may send a request to host, or ctx is done. host
+// must be a Key.
+func (l *Limiter) Wait(ctx context.Context, scan, host string) error {
+	l.mu.Lock()
+	now := l.now()
+	h := l.record(scan, host, now)
+	if l.interval <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	start := h.next
+	if start.Before(now) {
+		start = now
+	}
+	h.next = start.Add(l.interval)
+	delay := start.Sub(now)
+	if delay <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	h.delayed++
+	h.waiting++
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		h.waiting--
+		h.waited += delay
+		l.mu.Unlock()
+	}()
+	select {
+	case <-l.after(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Note records a request of scan to host that cannot be held back, such as
+// a tool that sends its own requests. It returns the other scans active on
+// the host.
+func (l *Limiter) Note(scan, host string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	h := l.record(scan, host, now)
+	var others []string
+	for other := range h.scans {
+		if other != scan {
+			others = append(others, other)
+		}
+	}
+	sort.Strings(others)
+	return others
+}
+
+// record counts a request and forgets the scans and hosts that went quiet.
+// The caller holds l.mu.
+func (l *Limiter) record(scan, host string, now time.Time) *hostState {
+	for key, h := range l.hosts {
+		for s, last := range h.scans {
+			if now.Sub(last) > activeWindow {
+				delete(h.scans, s)
+			}
+		}
+		if len(h.scans) == 0 && h.waiting == 0 && now.After(h.next) {
+			delete(l.hosts, key)
+		}
+	}
+
+	h, ok := l.hosts[host]
+	if !ok {
+		h = &hostState{scans: make(map[string]time.Time)}
+		l.hosts[host] = h
+	}
+	h.scans[scan] = now
+	h.requests++
+	return h
+}
+
+// Stats lists the hosts with recent requests, busiest first.
+func (l *Limiter) Stats() []HostStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	stats := make([]HostStats, 0, len(l.hosts))
+	for key, h := range l.hosts {
+		s := HostStats{
+			Host:          key,
+			Scans:         []string{},
+			Requests:      h.requests,
+			Delayed:       h.delayed,
+			WaitedSeconds: h.waited.Seconds(),
+			Waiting:       h.waiting,
+		}
+		for scan, last := range h.scans {
+			if now.Sub(last) <= activeWindow {
+				s.Scans = append(s.Scans, scan)
+			}
+		}
+		sort.Strings(s.Scans)
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Requests != stats[j].Requests {
+			return stats[i].Requests > stats[j].Requests
+		}
+		return stats[i].Host < stats[j].Host
+	})
+	return stats
+}
+
+// Key is the registrable domain of a host, URL or host:port, so that
+// api.example.com and example.com share a budget. IP addresses are their
+// own key. It is empty for values that are not hosts, such as paths or
+// words.
+func Key(value string) string {
+	host := strings.ToLower(strings.TrimSpace(value))
+	if _, rest, ok := strings.Cut(host, "://"); ok {
+		host = rest
+	}
+	host, _, _ = strings.Cut(host, "/")
+	host, _, _ = strings.Cut(host, "?")
+	if _, rest, ok := strings.Cut(host, "@"); ok {
+		host = rest
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(strings.TrimSuffix(host, "."), "[]")
+
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	if !strings.Contains(host, ".") || strings.ContainsAny(host, " \t*") {
+		return ""
+	}
+	if domain, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return domain
+	}
+	return host
+}
@@ -0,0 +1,86 @@
+package hostlimit_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pipeliner/pkg/hostlimit"
+	"pipeliner/pkg/testutil"
+)
+
+func TestKey(t *testing.T) {
+	tests := map[string]string{
+		"example.com":                      "example.com",
+		"API.Example.com.":                 "example.com",
+		"https://api.example.com:8443/x?y": "example.com",
+		"http://user@www.example.co.uk/":   "example.co.uk",
+		"192.0.2.7:80":                     "192.0.2.7",
+		"[2001:db8::1]:443":                "2001:db8::1",
+		"/admin":                           "",
+		"admin":                            "",
+		"":                                 "",
+	}
+	for value, want := range tests {
+		if got := hostlimit.Key(value); got != want {
+			t.Errorf("Key(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestLimiter_TwoScansShareTheBudgetOfAHost(t *testing.T) {
+	l := hostlimit.New(100) // 10ms apart
+	const perScan = 5
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, target := range []struct{ scan, host string }{{"scan-a", "example.com"}, {"scan-b", "api.example.com"}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perScan; i++ {
+				if err := l.Wait(context.Background(), target.scan, hostlimit.Key(target.host)); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// one scan alone would be done after 4 intervals
+	if elapsed := time.Since(start); elapsed < 9*10*time.Millisecond {
+		t.Errorf("10 requests took %s, want at least 90ms", elapsed)
+	}
+	stats := l.Stats()
+	testutil.AssertEquals(t, 1, len(stats))
+	testutil.AssertEquals(t, "example.com", stats[0].Host)
+	testutil.AssertEquals(t, "scan-a,scan-b", strings.Join(stats[0].Scans, ","))
+	testutil.AssertEquals(t, int64(2*perScan), stats[0].Requests)
+	if stats[0].Delayed < perScan {
+		t.Errorf("%d requests delayed, want most of them", stats[0].Delayed)
+	}
+	testutil.AssertEquals(t, 0, stats[0].Waiting)
+}
+
+func TestLimiter_WaitStopsWithContext(t *testing.T) {
+	l := hostlimit.New(0.1) // 10s apart
+	testutil.AssertNoError(t, l.Wait(context.Background(), "scan-a", "example.com"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, "scan-b", "example.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, want deadline exceeded", err)
+	}
+}
+
+func TestLimiter_NoteReportsOtherScans(t *testing.T) {
+	l := hostlimit.New(0)
+	testutil.AssertEquals(t, 0, len(l.Note("scan-a", "example.com")))
+	testutil.AssertNoError(t, l.Wait(context.Background(), "scan-b", "example.com"))
+	testutil.AssertNoError(t, l.Wait(context.Background(), "scan-c", "other.com"))
+	testutil.AssertEquals(t, "scan-b", strings.Join(l.Note("scan-a", "example.com"), ","))
+	testutil.AssertEquals(t, float64(0), l.PerSecond())
+}
@@ -15,6 +15,8 @@ type Fields map[string]interface{}
 // Logger wraps logrus.Logger with additional functionality
 type Logger struct {
 	*logrus.Logger
+	// Sampling applies to loops that log through a Sampler.
+	Sampling SamplingConfig
 }
 
 // NewLogger creates a new structured logger
@@ -37,7 +39,7 @@ func NewLogger(level logrus.Level) *Logger {
 		})
 	}
 
-	return &Logger{Logger: logger}
+	return &Logger{Logger: logger, Sampling: SamplingFromEnv()}
 }
 
 // WithContext adds context-specific fields to the logger
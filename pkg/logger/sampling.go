@@ -0,0 +1,92 @@
+package logger
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// SampleFirstEnv is how many items of a long loop are logged in full.
+	SampleFirstEnv = "PIPELINER_LOG_SAMPLE_FIRST"
+	// SampleEveryEnv is M in "then log one item in M".
+	SampleEveryEnv = "PIPELINER_LOG_SAMPLE_EVERY"
+	// SummaryIntervalEnv is how often a sampled loop logs a progress summary.
+	SummaryIntervalEnv = "PIPELINER_LOG_SUMMARY_INTERVAL"
+)
+
+// SamplingConfig thins out the per-item Info lines of long loops such as
+// replacement runs. Errors are never sampled.
+type SamplingConfig struct {
+	First           int
+	Every           int
+	SummaryInterval time.Duration
+}
+
+var DefaultSampling = SamplingConfig{First: 50, Every: 100, SummaryInterval: time.Minute}
+
+// SamplingFromEnv is DefaultSampling with the environment's overrides.
+// PIPELINER_LOG_SAMPLE_EVERY=1 turns sampling off.
+func SamplingFromEnv() SamplingConfig {
+	cfg := DefaultSampling
+	if v, err := strconv.Atoi(os.Getenv(SampleFirstEnv)); err == nil && v >= 0 {
+		cfg.First = v
+	}
+	if v, err := strconv.Atoi(os.Getenv(SampleEveryEnv)); err == nil && v > 0 {
+		cfg.Every = v
+	}
+	if v, err := time.ParseDuration(os.Getenv(SummaryIntervalEnv)); err == nil && v > 0 {
+		cfg.SummaryInterval = v
+	}
+	return cfg
+}
+
+// Sampler decides which items of a loop get their detail logged and when a
+// summary is due. It is not safe for concurrent use.
+type Sampler struct {
+	cfg         SamplingConfig
+	now         func() time.Time
+	count       int
+	failures    int
+	lastSummary time.Time
+}
+
+// NewSampler starts a sampled loop; now may be nil for time.Now.
+func (l *Logger) NewSampler(now func() time.Time) *Sampler {
+	if now == nil {
+		now = time.Now
+	}
+	return &Sampler{cfg: l.Sampling, now: now, lastSummary: now()}
+}
+
+// Next counts an item and reports whether to log it in full.
+func (s *Sampler) Next() bool {
+	s.count++
+	if s.cfg.Every <= 1 || s.count <= s.cfg.First {
+		return true
+	}
+	return (s.count-s.cfg.First)%s.cfg.Every == 0
+}
+
+// Fail counts a failed item.
+func (s *Sampler) Fail() {
+	s.failures++
+}
+
+// SummaryDue reports whether SummaryInterval has passed since the last
+// summary, restarting the interval when it has. Loops logging every item
+// need no summary.
+func (s *Sampler) SummaryDue() bool {
+	if s.cfg.Every <= 1 || s.count <= s.cfg.First || s.cfg.SummaryInterval <= 0 {
+		return false
+	}
+	now := s.now()
+	if now.Sub(s.lastSummary) < s.cfg.SummaryInterval {
+		return false
+	}
+	s.lastSummary = now
+	return true
+}
+
+func (s *Sampler) Count() int    { return s.count }
+func (s *Sampler) Failures() int { return s.failures }
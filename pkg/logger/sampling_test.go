@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSampler(t *testing.T) {
+	l := NewLogger(logrus.InfoLevel)
+	l.Sampling = SamplingConfig{First: 10, Every: 100, SummaryInterval: time.Minute}
+
+	now := time.Unix(0, 0)
+	s := l.NewSampler(func() time.Time { return now })
+
+	detailed, summaries := 0, 0
+	for i := 0; i < 1000; i++ {
+		now = now.Add(time.Second)
+		if s.Next() {
+			detailed++
+		}
+		if s.SummaryDue() {
+			summaries++
+		}
+	}
+	// the first 10 items, then 110, 210, ... 910; a summary every minute
+	if detailed != 19 || summaries != 16 {
+		t.Fatalf("detailed = %d, summaries = %d, want 19 and 16", detailed, summaries)
+	}
+
+	l.Sampling.Every = 1
+	s = l.NewSampler(nil)
+	for i := 0; i < 500; i++ {
+		if !s.Next() || s.SummaryDue() {
+			t.Fatal("Every = 1 should log every item and need no summary")
+		}
+	}
+}
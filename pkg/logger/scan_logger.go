@@ -17,6 +17,7 @@ type ScanLogger struct {
 	scanDir     string
 	logFile     *os.File
 	errorFile   *os.File
+	toolLogs    *ToolLogHook
 	mu          sync.Mutex
 	multiWriter io.Writer
 }
@@ -45,6 +46,8 @@ func NewScanLogger(scanID, scanDir string, level logrus.Level) (*ScanLogger, err
 
 	multiWriter := io.MultiWriter(os.Stdout, logFile)
 	baseLogger.Logger.SetOutput(multiWriter)
+	toolLogs := NewToolLogHook(scanDir)
+	baseLogger.AddHook(toolLogs)
 
 	scanLogger := &ScanLogger{
 		Logger:      baseLogger,
@@ -52,6 +55,7 @@ func NewScanLogger(scanID, scanDir string, level logrus.Level) (*ScanLogger, err
 		scanDir:     scanDir,
 		logFile:     logFile,
 		errorFile:   errorFile,
+		toolLogs:    toolLogs,
 		multiWriter: multiWriter,
 	}
 
@@ -195,6 +199,10 @@ func (sl *ScanLogger) Close() error {
 		}
 	}
 
+	if err := sl.toolLogs.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close tool logs: %w", err))
+	}
+
 	if len(errs) > 0 {
 		return fmt.Errorf("errors closing scan logger: %v", errs)
 	}
@@ -202,6 +210,12 @@ func (sl *ScanLogger) Close() error {
 	return nil
 }
 
+// ToolLogHook is the hook writing the scan's per-tool logs, for other
+// loggers of the scan, like the engine's, to install as well.
+func (sl *ScanLogger) ToolLogHook() *ToolLogHook {
+	return sl.toolLogs
+}
+
 func (sl *ScanLogger) GetLogFilePath() string {
 	return filepath.Join(sl.scanDir, "scan.log")
 }
@@ -0,0 +1,134 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ToolLogDir is the scan subdirectory per-tool logs are written to.
+	ToolLogDir = "logs"
+	// ToolLogMaxSizeEnv caps each per-tool log in megabytes; past it the
+	// file is rotated to <tool>.log.1.
+	ToolLogMaxSizeEnv = "PIPELINER_TOOL_LOG_MAX_MB"
+
+	defaultToolLogMaxMB = 10
+)
+
+var unsafeToolLogName = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// ToolLogHook tees every entry carrying a tool_name field, as WithTool
+// adds, into <scan_dir>/logs/<tool>.log.
+type ToolLogHook struct {
+	dir       string
+	maxBytes  int64
+	formatter logrus.Formatter
+
+	mu    sync.Mutex
+	files map[string]*toolLogFile
+}
+
+type toolLogFile struct {
+	file *os.File
+	size int64
+}
+
+func NewToolLogHook(scanDir string) *ToolLogHook {
+	maxMB := int64(defaultToolLogMaxMB)
+	if v, err := strconv.ParseInt(os.Getenv(ToolLogMaxSizeEnv), 10, 64); err == nil && v > 0 {
+		maxMB = v
+	}
+	return &ToolLogHook{
+		dir:       filepath.Join(scanDir, ToolLogDir),
+		maxBytes:  maxMB << 20,
+		formatter: &logrus.TextFormatter{DisableColors: true, FullTimestamp: true},
+		files:     make(map[string]*toolLogFile),
+	}
+}
+
+// ToolLogFile is the log name for a tool, relative to the logs directory.
+func ToolLogFile(tool string) string {
+	return unsafeToolLogName.ReplaceAllString(tool, "_") + ".log"
+}
+
+func (h *ToolLogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *ToolLogHook) Fire(entry *logrus.Entry) error {
+	tool, _ := entry.Data["tool_name"].(string)
+	if tool == "" {
+		return nil
+	}
+	line, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	name := ToolLogFile(tool)
+	f, err := h.open(name)
+	if err != nil {
+		return err
+	}
+	if h.maxBytes > 0 && f.size > 0 && f.size+int64(len(line)) > h.maxBytes {
+		if f, err = h.rotate(name, f); err != nil {
+			return err
+		}
+	}
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	return err
+}
+
+func (h *ToolLogHook) open(name string) (*toolLogFile, error) {
+	if f, ok := h.files[name]; ok {
+		return f, nil
+	}
+	if err := os.MkdirAll(h.dir, 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(h.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	f := &toolLogFile{file: file}
+	if info, err := file.Stat(); err == nil {
+		f.size = info.Size()
+	}
+	h.files[name] = f
+	return f, nil
+}
+
+// rotate keeps one previous file, <tool>.log.1.
+func (h *ToolLogHook) rotate(name string, f *toolLogFile) (*toolLogFile, error) {
+	f.file.Close()
+	delete(h.files, name)
+	path := filepath.Join(h.dir, name)
+	if err := os.Rename(path, path+".1"); err != nil {
+		return nil, err
+	}
+	return h.open(name)
+}
+
+// Close closes the open log files; later entries reopen them.
+func (h *ToolLogHook) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var firstErr error
+	for name, f := range h.files {
+		if err := f.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(h.files, name)
+	}
+	return firstErr
+}
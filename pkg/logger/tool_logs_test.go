@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestToolLogHook(t *testing.T) {
+	scanDir := t.TempDir()
+	hook := NewToolLogHook(scanDir)
+	defer hook.Close()
+
+	l := NewLogger(logrus.InfoLevel)
+	l.SetOutput(io.Discard)
+	l.AddHook(hook)
+
+	l.WithTool("subfinder", "domain_enum").Info("Executing command: subfinder -d example.com")
+	l.WithTool("chaos/client", "domain_enum").Warn("rate limited")
+	l.WithFields(Fields{"scan_id": "scan-1"}).Info("Scan logger initialized")
+
+	data, err := os.ReadFile(filepath.Join(scanDir, ToolLogDir, "subfinder.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "Executing command: subfinder") || strings.Contains(string(data), "rate limited") {
+		t.Fatalf("subfinder.log = %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(scanDir, ToolLogDir, "chaos_client.log")); err != nil {
+		t.Fatalf("tool names should be made safe for file names: %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(scanDir, ToolLogDir))
+	if len(entries) != 2 {
+		t.Fatalf("entries without tool_name should not be written, got %d files", len(entries))
+	}
+}
+
+func TestToolLogHook_RotatesPerFile(t *testing.T) {
+	scanDir := t.TempDir()
+	hook := NewToolLogHook(scanDir)
+	hook.maxBytes = 1024
+	defer hook.Close()
+
+	l := NewLogger(logrus.InfoLevel)
+	l.SetOutput(io.Discard)
+	l.AddHook(hook)
+
+	for i := 0; i < 50; i++ {
+		l.WithTool("ffuf", "recon").Info(strings.Repeat("x", 100))
+	}
+	l.WithTool("nuclei", "vuln").Info("one line")
+
+	dir := filepath.Join(scanDir, ToolLogDir)
+	for _, name := range []string{"ffuf.log", "ffuf.log.1"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 1024 {
+			t.Errorf("%s is %d bytes, over the 1024 byte cap", name, info.Size())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "nuclei.log.1")); !os.IsNotExist(err) {
+		t.Errorf("nuclei.log should not be rotated by ffuf's volume, stat err = %v", err)
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"encoding/xml"
 	"fmt"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"pipeliner/pkg/logger"
@@ -175,6 +178,138 @@ func (p *NucleiParser) parseNucleiOutput(outputFile string) (map[string]any, err
 	return resultMap, nil
 }
 
+type HttpxParser struct {
+	logger *logger.Logger
+}
+
+func NewHttpxParser() *HttpxParser {
+	return &HttpxParser{logger: logger.NewLogger(logrus.InfoLevel)}
+}
+
+func (p *HttpxParser) Parse(outputFile string) (map[string]any, error) {
+	if p.logger == nil {
+		p.logger = logger.NewLogger(logrus.InfoLevel)
+	}
+	return p.parseHttpxOutput(outputFile)
+}
+
+func (p *HttpxParser) parseHttpxOutput(outputFile string) (map[string]any, error) {
+	if _, err := os.Stat(outputFile); os.IsNotExist(err) {
+		p.logger.Errorf("Httpx output file does not exist: %s", outputFile)
+		return nil, fmt.Errorf("httpx output file does not exist: %w", err)
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		p.logger.Errorf("Failed to read Httpx output file: %v", err)
+		return nil, fmt.Errorf("failed to read httpx output file: %w", err)
+	}
+
+	var results []HttpxResult
+	for _, line := range splitLines(data) {
+		if len(line) == 0 {
+			continue
+		}
+
+		var result HttpxResult
+		if err := json.Unmarshal(line, &result); err != nil {
+			p.logger.Warnf("Failed to parse httpx JSON line: %v", err)
+			continue
+		}
+		results = append(results, result)
+	}
+
+	resultMap := map[string]any{
+		"results": results,
+		"count":   len(results),
+	}
+
+	p.logger.Infof("Successfully parsed %d results from Httpx output", len(results))
+	return resultMap, nil
+}
+
+type NaabuParser struct {
+	logger *logger.Logger
+}
+
+func NewNaabuParser() *NaabuParser {
+	return &NaabuParser{logger: logger.NewLogger(logrus.InfoLevel)}
+}
+
+func (p *NaabuParser) Parse(outputFile string) (map[string]any, error) {
+	if p.logger == nil {
+		p.logger = logger.NewLogger(logrus.InfoLevel)
+	}
+	return p.parseNaabuOutput(outputFile)
+}
+
+// parseNaabuOutput reads both of naabu's formats, line by line, so a file
+// mixing them is read too.
+func (p *NaabuParser) parseNaabuOutput(outputFile string) (map[string]any, error) {
+	if _, err := os.Stat(outputFile); os.IsNotExist(err) {
+		p.logger.Errorf("Naabu output file does not exist: %s", outputFile)
+		return nil, fmt.Errorf("naabu output file does not exist: %w", err)
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		p.logger.Errorf("Failed to read Naabu output file: %v", err)
+		return nil, fmt.Errorf("failed to read naabu output file: %w", err)
+	}
+
+	var results []NaabuResult
+	for _, line := range splitLines(data) {
+		text := strings.TrimSpace(string(line))
+		if text == "" {
+			continue
+		}
+
+		result, err := parseNaabuLine(text)
+		if err != nil {
+			p.logger.Warnf("Failed to parse naabu line: %v", err)
+			continue
+		}
+		results = append(results, result)
+	}
+
+	resultMap := map[string]any{
+		"results": results,
+		"count":   len(results),
+	}
+
+	p.logger.Infof("Successfully parsed %d results from Naabu output", len(results))
+	return resultMap, nil
+}
+
+func parseNaabuLine(line string) (NaabuResult, error) {
+	var result NaabuResult
+	if strings.HasPrefix(line, "{") {
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			return result, err
+		}
+		if result.Host == "" {
+			result.Host = result.IP
+		}
+	} else {
+		i := strings.LastIndex(line, ":")
+		if i <= 0 {
+			return result, fmt.Errorf("%q is not host:port", line)
+		}
+		port, err := strconv.Atoi(line[i+1:])
+		if err != nil {
+			return result, fmt.Errorf("%q is not host:port", line)
+		}
+		result.Host, result.Port = line[:i], port
+	}
+	if result.Host == "" || result.Port <= 0 || result.Port > 65535 {
+		return result, fmt.Errorf("%q has no host or a bad port", line)
+	}
+	if result.Protocol == "" {
+		result.Protocol = "tcp"
+	}
+	return result, nil
+}
+
 func splitLines(data []byte) [][]byte {
 	var lines [][]byte
 	start := 0
@@ -213,6 +348,70 @@ func GetNucleiDescription(info map[string]interface{}) string {
 	return ""
 }
 
+// GetNucleiTagList returns all of a template's tags, which nuclei writes
+// as a list or as one comma-separated string.
+func GetNucleiTagList(info map[string]interface{}) []string {
+	var tags []string
+	switch value := info["tags"].(type) {
+	case []interface{}:
+		for _, t := range value {
+			if s, ok := t.(string); ok && s != "" {
+				tags = append(tags, s)
+			}
+		}
+	case string:
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				tags = append(tags, s)
+			}
+		}
+	}
+	return tags
+}
+
+var cveID = regexp.MustCompile(`(?i)^CVE-\d{4}-\d{4,}$`)
+
+// GetNucleiCVEs returns the CVE ids in a template's classification, upper
+// case and without duplicates. nuclei writes cve-id as a list or as one
+// comma-separated string.
+func GetNucleiCVEs(info map[string]interface{}) []string {
+	classification, _ := info["classification"].(map[string]interface{})
+	var values []string
+	switch value := classification["cve-id"].(type) {
+	case []interface{}:
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+	case string:
+		values = strings.Split(value, ",")
+	}
+
+	var cves []string
+	for _, v := range values {
+		v = strings.ToUpper(strings.TrimSpace(v))
+		if cveID.MatchString(v) && !slices.Contains(cves, v) {
+			cves = append(cves, v)
+		}
+	}
+	return cves
+}
+
+// GetNucleiClassificationScore returns a score from a template's
+// classification, such as cvss-score or epss-score.
+func GetNucleiClassificationScore(info map[string]interface{}, key string) (float64, bool) {
+	classification, _ := info["classification"].(map[string]interface{})
+	switch value := classification[key].(type) {
+	case float64:
+		return value, true
+	case string:
+		score, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return score, err == nil
+	}
+	return 0, false
+}
+
 func GetNucleiTags(info map[string]interface{}) string {
 	if tags, ok := info["tags"].([]interface{}); ok {
 		var tagStrs []string
@@ -0,0 +1,99 @@
+package parsers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNaabuParser(t *testing.T) {
+	tests := []struct {
+		file string
+		want []NaabuResult
+	}{
+		{
+			file: "testdata/naabu_output.txt",
+			want: []NaabuResult{
+				{Host: "a.example.com", Port: 443, Protocol: "tcp"},
+				{Host: "a.example.com", Port: 8443, Protocol: "tcp"},
+				{Host: "b.example.com", Port: 22, Protocol: "tcp"},
+			},
+		},
+		{
+			file: "testdata/naabu_output.json",
+			want: []NaabuResult{
+				{Host: "a.example.com", IP: "93.184.216.34", Port: 443, Protocol: "tcp"},
+				{Host: "a.example.com", IP: "93.184.216.34", Port: 8443, Protocol: "tcp"},
+				{Host: "93.184.216.35", IP: "93.184.216.35", Port: 53, Protocol: "udp"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			result, err := NewNaabuParser().Parse(tt.file)
+			require.NoError(t, err)
+			// malformed lines are skipped
+			assert.Equal(t, tt.want, result["results"])
+			assert.Equal(t, len(tt.want), result["count"])
+		})
+	}
+}
+
+func TestHttpxParser(t *testing.T) {
+	result, err := NewHttpxParser().Parse("testdata/httpx_output.json")
+	require.NoError(t, err)
+	// blank and malformed lines are skipped
+	want := []HttpxResult{
+		{
+			URL: "https://a.example.com", Input: "a.example.com", Host: "93.184.216.34", StatusCode: 200,
+			Title: "Example A", Tech: []string{"Nginx", "React"}, ContentLength: 1256, Webserver: "nginx/1.25.3",
+		},
+		{URL: "http://b.example.com", Input: "b.example.com", Host: "93.184.216.35", StatusCode: 301},
+	}
+	assert.Equal(t, want, result["results"])
+	assert.Equal(t, len(want), result["count"])
+
+	_, err = NewHttpxParser().Parse("testdata/missing.json")
+	assert.Error(t, err)
+}
+
+func TestGetNucleiCVEs(t *testing.T) {
+	list := map[string]interface{}{"classification": map[string]interface{}{
+		"cve-id":     []interface{}{"cve-2021-44228", "CVE-2021-45046", "CVE-2021-44228", "not-a-cve"},
+		"cvss-score": 10.0,
+		"epss-score": "0.97",
+	}}
+	assert.Equal(t, []string{"CVE-2021-44228", "CVE-2021-45046"}, GetNucleiCVEs(list))
+
+	score, ok := GetNucleiClassificationScore(list, "cvss-score")
+	assert.True(t, ok)
+	assert.Equal(t, 10.0, score)
+	score, ok = GetNucleiClassificationScore(list, "epss-score")
+	assert.True(t, ok)
+	assert.Equal(t, 0.97, score)
+	_, ok = GetNucleiClassificationScore(list, "epss-percentile")
+	assert.False(t, ok)
+
+	str := map[string]interface{}{"classification": map[string]interface{}{"cve-id": "CVE-2023-1234, CVE-2023-5678"}}
+	assert.Equal(t, []string{"CVE-2023-1234", "CVE-2023-5678"}, GetNucleiCVEs(str))
+	assert.Empty(t, GetNucleiCVEs(map[string]interface{}{"name": "tech-detect"}))
+}
+
+func TestScreenshotParsers(t *testing.T) {
+	result, err := NewGowitnessParser().Parse("testdata/gowitness.jsonl")
+	require.NoError(t, err)
+	// malformed lines and failed URLs are skipped
+	assert.Equal(t, []ScreenshotResult{
+		{URL: "https://api.example.com.backup.net", Screenshot: "https---api.example.com.backup.net-443.jpeg"},
+		{URL: "https://api.example.com:8443/login", Screenshot: "https---api.example.com-8443-login.jpeg"},
+	}, result["results"])
+
+	result, err = NewAquatoneParser().Parse("testdata/aquatone_session.json")
+	require.NoError(t, err)
+	assert.Equal(t, []ScreenshotResult{
+		{URL: "http://www.example.com/", Screenshot: "screenshots/http__www_example_com__da39a3ee5e6b4b0d.png"},
+		{URL: "https://api.example.com/", Screenshot: "screenshots/https__api_example_com__4e1243bd22c66e76.png"},
+	}, result["results"])
+}
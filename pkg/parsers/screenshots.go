@@ -0,0 +1,107 @@
+package parsers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"pipeliner/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Report files of the screenshot tools, in the directory they ran in.
+const (
+	GowitnessReportFile = "gowitness.jsonl"
+	AquatoneSessionFile = "aquatone_session.json"
+)
+
+// ScreenshotResult is a URL a screenshot tool visited and the screenshot it
+// took, as the tool named it.
+type ScreenshotResult struct {
+	URL        string `json:"url"`
+	Screenshot string `json:"screenshot"`
+}
+
+// GowitnessParser reads the JSONL report gowitness writes with
+// --write-jsonl.
+type GowitnessParser struct {
+	logger *logger.Logger
+}
+
+func NewGowitnessParser() *GowitnessParser {
+	return &GowitnessParser{logger: logger.NewLogger(logrus.InfoLevel)}
+}
+
+func (p *GowitnessParser) Parse(outputFile string) (map[string]any, error) {
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gowitness report: %w", err)
+	}
+
+	var results []ScreenshotResult
+	for _, line := range splitLines(data) {
+		if len(line) == 0 {
+			continue
+		}
+		var entry struct {
+			URL      string `json:"url"`
+			FileName string `json:"file_name"`
+			Failed   bool   `json:"failed"`
+		}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			p.logger.Warnf("Failed to parse gowitness JSON line: %v", err)
+			continue
+		}
+		if entry.Failed || entry.URL == "" || entry.FileName == "" {
+			continue
+		}
+		results = append(results, ScreenshotResult{URL: entry.URL, Screenshot: entry.FileName})
+	}
+
+	return map[string]any{
+		"results": results,
+		"count":   len(results),
+	}, nil
+}
+
+// AquatoneParser reads the pages of an aquatone session file.
+type AquatoneParser struct{}
+
+func NewAquatoneParser() *AquatoneParser {
+	return &AquatoneParser{}
+}
+
+func (p *AquatoneParser) Parse(outputFile string) (map[string]any, error) {
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read aquatone session: %w", err)
+	}
+
+	var session struct {
+		Pages map[string]struct {
+			URL            string `json:"url"`
+			ScreenshotPath string `json:"screenshotPath"`
+			HasScreenshot  bool   `json:"hasScreenshot"`
+		} `json:"pages"`
+	}
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse aquatone session: %w", err)
+	}
+
+	var results []ScreenshotResult
+	for _, page := range session.Pages {
+		if !page.HasScreenshot || page.URL == "" || page.ScreenshotPath == "" {
+			continue
+		}
+		results = append(results, ScreenshotResult{URL: page.URL, Screenshot: page.ScreenshotPath})
+	}
+	// pages are keyed by id, so sort for a stable mapping
+	sort.Slice(results, func(i, j int) bool { return results[i].URL < results[j].URL })
+
+	return map[string]any{
+		"results": results,
+		"count":   len(results),
+	}, nil
+}
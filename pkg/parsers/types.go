@@ -87,3 +87,24 @@ type NucleiResult struct {
 	CurlCommand   string                 `json:"curl-command"`
 	MatcherStatus bool                   `json:"matcher-status"`
 }
+
+// HttpxResult is one line of httpx -json output.
+type HttpxResult struct {
+	URL           string   `json:"url"`
+	Input         string   `json:"input"`
+	Host          string   `json:"host"`
+	StatusCode    int      `json:"status_code"`
+	Title         string   `json:"title"`
+	Tech          []string `json:"tech"`
+	ContentLength int      `json:"content_length"`
+	Webserver     string   `json:"webserver"`
+}
+
+// NaabuResult is an open port naabu found, from a host:port line or a line
+// of -json output.
+type NaabuResult struct {
+	Host     string `json:"host"`
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+}
@@ -0,0 +1,363 @@
+// Package queue bounds the number of scans executing concurrently.
+package queue
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"pipeliner/pkg/logger"
+	"slices"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Queue limits how many scans execute at once.
+type Queue interface {
+	ExecuteWithQueue(ctx context.Context, priority int, fn func() error) error
+	ExecuteWithLease(ctx context.Context, ticket Ticket, fn func(*Lease) error) error
+	GetStatus() (running, queued, maxConcurrent int)
+}
+
+// Ticket is what a function waits in line with: higher priorities are
+// served first, equal ones in the order they arrived. ID names the scan in
+// Queued and may be empty.
+type Ticket struct {
+	ID       string
+	Priority int
+}
+
+// ErrClosed is returned to scans waiting for, or asking for, a slot once
+// the queue is shut down.
+var ErrClosed = errors.New("scan queue is shut down")
+
+// EngineQueue manages concurrent scan execution. Waiting scans are served by
+// priority, then in FIFO order; a waiter whose context is cancelled is
+// dropped from the line.
+type EngineQueue struct {
+	maxConcurrent int
+	running       int
+	waiters       waiterHeap
+	seq           uint64 // arrival order of the waiters
+	mu            sync.Mutex
+	logger        *logger.Logger
+
+	// closing is closed by Shutdown; idle, once Shutdown waits, when the
+	// last running scan releases its slot
+	closing chan struct{}
+	closed  bool
+	idle    chan struct{}
+}
+
+var (
+	globalQueue *EngineQueue
+	globalMu    sync.Mutex
+)
+
+// New creates a standalone queue, independent of the global one
+func New(maxConcurrent int) *EngineQueue {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	return &EngineQueue{
+		maxConcurrent: maxConcurrent,
+		logger:        logger.NewLogger(logrus.InfoLevel),
+		closing:       make(chan struct{}),
+	}
+}
+
+// InitGlobal initializes the global queue with max concurrency.
+// Calls after the first one are no-ops.
+func InitGlobal(maxConcurrent int) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	initGlobalLocked(maxConcurrent)
+}
+
+func initGlobalLocked(maxConcurrent int) {
+	if globalQueue != nil {
+		return
+	}
+	globalQueue = New(maxConcurrent)
+	globalQueue.logger.Info("Scan queue initialized", logger.Fields{
+		"max_concurrent": globalQueue.maxConcurrent,
+	})
+}
+
+// Global returns the global queue instance (initializes with default if needed)
+func Global() *EngineQueue {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	initGlobalLocked(1)
+	return globalQueue
+}
+
+// SetGlobal replaces the global queue; nil makes the next
+// InitGlobal or Global call create a fresh one.
+func SetGlobal(q *EngineQueue) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	globalQueue = q
+}
+
+// ExecuteWithQueue wraps a function execution with queue management
+// It blocks until a slot is available or ctx is done, then executes the function
+func (q *EngineQueue) ExecuteWithQueue(ctx context.Context, priority int, fn func() error) error {
+	return q.ExecuteWithLease(ctx, Ticket{Priority: priority}, func(*Lease) error { return fn() })
+}
+
+// ExecuteWithLease is ExecuteWithQueue for functions that may give their slot
+// back for a while, such as a paused scan.
+func (q *EngineQueue) ExecuteWithLease(ctx context.Context, ticket Ticket, fn func(*Lease) error) error {
+	if err := q.acquire(ctx, ticket); err != nil {
+		return err
+	}
+	lease := &Lease{q: q, ticket: ticket, held: true}
+	defer lease.close()
+
+	return fn(lease)
+}
+
+// Lease is the slot held by a function run through ExecuteWithLease.
+type Lease struct {
+	q      *EngineQueue
+	ticket Ticket
+	mu     sync.Mutex
+	held   bool
+	done   bool
+}
+
+// Release hands the slot to the next waiter. It reports false if the slot
+// was not held.
+func (l *Lease) Release() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.held || l.done {
+		return false
+	}
+	l.held = false
+	l.q.release()
+	return true
+}
+
+// Reacquire waits in line for a slot again after Release, with the lease's
+// ticket. It is a no-op while the slot is held.
+func (l *Lease) Reacquire(ctx context.Context) error {
+	l.mu.Lock()
+	held := l.held
+	l.mu.Unlock()
+	if held {
+		return nil
+	}
+
+	if err := l.q.acquire(ctx, l.ticket); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held || l.done {
+		// the function returned, or someone else got the slot back, meanwhile
+		l.q.release()
+		return nil
+	}
+	l.held = true
+	return nil
+}
+
+// Held reports whether the lease currently owns a slot.
+func (l *Lease) Held() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.held
+}
+
+func (l *Lease) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.done = true
+	if l.held {
+		l.held = false
+		l.q.release()
+	}
+}
+
+func (q *EngineQueue) acquire(ctx context.Context, ticket Ticket) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrClosed
+	}
+	if q.running < q.maxConcurrent && q.waiters.Len() == 0 {
+		q.running++
+		running, queued := q.running, q.waiters.Len()
+		q.mu.Unlock()
+
+		q.logger.Info("Scan execution started", logger.Fields{
+			"running": running,
+			"queued":  queued,
+		})
+		return nil
+	}
+
+	q.seq++
+	w := &waiter{Ticket: ticket, seq: q.seq, ready: make(chan struct{})}
+	heap.Push(&q.waiters, w)
+	running, queued := q.running, q.waiters.Len()
+	q.mu.Unlock()
+
+	q.logger.Info("Scan added to queue", logger.Fields{
+		"queued":   queued,
+		"running":  running,
+		"slots":    q.maxConcurrent,
+		"priority": ticket.Priority,
+	})
+
+	var err error
+	select {
+	case <-w.ready:
+		q.logger.Info("Scan execution started", logger.Fields{"queued_before_start": queued})
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-q.closing:
+		err = ErrClosed
+	}
+
+	q.mu.Lock()
+	select {
+	case <-w.ready:
+		// a slot was handed over while we were being cancelled; pass it on
+		q.mu.Unlock()
+		q.release()
+	default:
+		heap.Remove(&q.waiters, w.index)
+		remaining := q.waiters.Len()
+		q.mu.Unlock()
+
+		q.logger.Info("Scan left queue before starting", logger.Fields{
+			"queued": remaining,
+			"reason": err.Error(),
+		})
+	}
+	return err
+}
+
+// release hands the slot to the next waiter, or frees it if nobody is queued
+// or the queue is shut down.
+func (q *EngineQueue) release() {
+	q.mu.Lock()
+	if q.waiters.Len() > 0 && !q.closed {
+		next := heap.Pop(&q.waiters).(*waiter)
+		close(next.ready)
+		q.mu.Unlock()
+		return
+	}
+	q.running--
+	running := q.running
+	if running == 0 && q.idle != nil {
+		close(q.idle)
+		q.idle = nil
+	}
+	q.mu.Unlock()
+
+	q.logger.Info("Scan execution completed, slot released", logger.Fields{
+		"running": running,
+		"queued":  0,
+	})
+}
+
+// Shutdown stops the queue from starting scans: waiting scans and later
+// ones get ErrClosed. It then waits for the running scans to finish, or
+// returns ctx's error if they have not when ctx is done.
+func (q *EngineQueue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.closing)
+	}
+	if q.running == 0 {
+		q.mu.Unlock()
+		return nil
+	}
+	if q.idle == nil {
+		q.idle = make(chan struct{})
+	}
+	idle, running := q.idle, q.running
+	q.mu.Unlock()
+
+	q.logger.Info("Waiting for running scans before shutdown", logger.Fields{"running": running})
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// GetStatus returns current queue status
+func (q *EngineQueue) GetStatus() (running, queued, maxConcurrent int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.running, q.waiters.Len(), q.maxConcurrent
+}
+
+// Queued returns the tickets of the waiting functions, in the order they
+// will start.
+func (q *EngineQueue) Queued() []Ticket {
+	q.mu.Lock()
+	waiters := slices.Clone(q.waiters)
+	q.mu.Unlock()
+
+	slices.SortFunc(waiters, func(a, b *waiter) int {
+		if startsBefore(a, b) {
+			return -1
+		}
+		return 1
+	})
+	tickets := make([]Ticket, len(waiters))
+	for i, w := range waiters {
+		tickets[i] = w.Ticket
+	}
+	return tickets
+}
+
+type waiter struct {
+	Ticket
+	seq   uint64
+	ready chan struct{} // closed when a slot is handed over
+	index int
+}
+
+func startsBefore(a, b *waiter) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return a.seq < b.seq
+}
+
+// waiterHeap is a container/heap of the waiters, the next to start first.
+type waiterHeap []*waiter
+
+func (h waiterHeap) Len() int           { return len(h) }
+func (h waiterHeap) Less(i, j int) bool { return startsBefore(h[i], h[j]) }
+
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waiterHeap) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waiterHeap) Pop() any {
+	old := *h
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return w
+}
@@ -0,0 +1,280 @@
+package queue_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pipeliner/pkg/queue"
+	"pipeliner/pkg/testutil"
+)
+
+func TestEngineQueue_LimitsConcurrency(t *testing.T) {
+	q := queue.New(2)
+
+	var running, peak atomic.Int32
+	done := make(chan struct{})
+	for i := 0; i < 6; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			_ = q.ExecuteWithQueue(context.Background(), 0, func() error {
+				n := running.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				running.Add(-1)
+				return nil
+			})
+		}()
+	}
+	for i := 0; i < 6; i++ {
+		<-done
+	}
+
+	testutil.AssertEquals(t, int32(2), peak.Load())
+}
+
+func TestEngineQueue_CancelWhileQueued(t *testing.T) {
+	q := queue.New(1)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		_ = q.ExecuteWithQueue(context.Background(), 0, func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	var ran atomic.Bool
+	go func() {
+		result <- q.ExecuteWithQueue(ctx, 0, func() error {
+			ran.Store(true)
+			return nil
+		})
+	}()
+
+	waitForQueued(t, q, 1)
+
+	cancel()
+
+	select {
+	case err := <-result:
+		testutil.AssertError(t, err)
+		testutil.AssertEquals(t, true, errors.Is(err, context.Canceled))
+	case <-time.After(time.Second):
+		t.Fatal("cancelled scan was still waiting for a slot")
+	}
+
+	testutil.AssertEquals(t, false, ran.Load())
+	running, queued, _ := q.GetStatus()
+	testutil.AssertEquals(t, 1, running)
+	testutil.AssertEquals(t, 0, queued)
+
+	close(release)
+}
+
+func waitForQueued(t *testing.T, q *queue.EngineQueue, want int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, queued, _ := q.GetStatus(); queued == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("queue never reached %d waiting scans", want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestEngineQueue_CancelledWaiterIsSkipped(t *testing.T) {
+	q := queue.New(1)
+
+	order := make(chan string, 3)
+	releaseFirst := make(chan struct{})
+	enqueue := func(ctx context.Context, name string, block chan struct{}) chan error {
+		result := make(chan error, 1)
+		go func() {
+			result <- q.ExecuteWithQueue(ctx, 0, func() error {
+				order <- name
+				if block != nil {
+					<-block
+				}
+				return nil
+			})
+		}()
+		return result
+	}
+
+	first := enqueue(context.Background(), "first", releaseFirst)
+	testutil.AssertEquals(t, "first", <-order)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	second := enqueue(ctx, "second", nil)
+	waitForQueued(t, q, 1)
+	third := enqueue(context.Background(), "third", nil)
+	waitForQueued(t, q, 2)
+
+	cancel()
+	testutil.AssertEquals(t, true, errors.Is(<-second, context.Canceled))
+	waitForQueued(t, q, 1)
+
+	close(releaseFirst)
+	testutil.AssertNoError(t, <-first)
+	testutil.AssertNoError(t, <-third)
+	testutil.AssertEquals(t, "third", <-order)
+
+	running, queued, _ := q.GetStatus()
+	testutil.AssertEquals(t, 0, running)
+	testutil.AssertEquals(t, 0, queued)
+}
+
+func TestEngineQueue_HigherPriorityStartsFirst(t *testing.T) {
+	q := queue.New(1)
+
+	order := make(chan string, 4)
+	releaseFirst := make(chan struct{})
+	enqueue := func(ticket queue.Ticket, block chan struct{}) chan error {
+		result := make(chan error, 1)
+		go func() {
+			result <- q.ExecuteWithLease(context.Background(), ticket, func(*queue.Lease) error {
+				order <- ticket.ID
+				if block != nil {
+					<-block
+				}
+				return nil
+			})
+		}()
+		return result
+	}
+
+	first := enqueue(queue.Ticket{ID: "running"}, releaseFirst)
+	testutil.AssertEquals(t, "running", <-order)
+
+	var results []chan error
+	for i, ticket := range []queue.Ticket{{ID: "low-1"}, {ID: "low-2"}, {ID: "high", Priority: 10}, {ID: "medium", Priority: 5}} {
+		results = append(results, enqueue(ticket, nil))
+		waitForQueued(t, q, i+1)
+	}
+	queued := q.Queued()
+	testutil.AssertEquals(t, 4, len(queued))
+	testutil.AssertEquals(t, queue.Ticket{ID: "high", Priority: 10}, queued[0])
+	testutil.AssertEquals(t, queue.Ticket{ID: "medium", Priority: 5}, queued[1])
+	testutil.AssertEquals(t, "low-1", queued[2].ID)
+	testutil.AssertEquals(t, "low-2", queued[3].ID)
+
+	close(releaseFirst)
+	testutil.AssertNoError(t, <-first)
+	for _, result := range results {
+		testutil.AssertNoError(t, <-result)
+	}
+	for _, want := range []string{"high", "medium", "low-1", "low-2"} {
+		testutil.AssertEquals(t, want, <-order)
+	}
+}
+
+func TestEngineQueue_LeaseReleasedWhilePaused(t *testing.T) {
+	q := queue.New(1)
+
+	paused := make(chan struct{})
+	resume := make(chan struct{})
+	first := make(chan error, 1)
+	go func() {
+		first <- q.ExecuteWithLease(context.Background(), queue.Ticket{}, func(lease *queue.Lease) error {
+			testutil.AssertEquals(t, true, lease.Release())
+			testutil.AssertEquals(t, false, lease.Release())
+			close(paused)
+			<-resume
+			return lease.Reacquire(context.Background())
+		})
+	}()
+	<-paused
+
+	// the freed slot lets a queued scan run while the first one is paused
+	testutil.AssertNoError(t, q.ExecuteWithQueue(context.Background(), 0, func() error { return nil }))
+
+	close(resume)
+	testutil.AssertNoError(t, <-first)
+
+	running, queued, _ := q.GetStatus()
+	testutil.AssertEquals(t, 0, running)
+	testutil.AssertEquals(t, 0, queued)
+}
+
+func TestEngineQueue_Shutdown(t *testing.T) {
+	q := queue.New(1)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	finished := make(chan error, 1)
+	go func() {
+		finished <- q.ExecuteWithQueue(context.Background(), 0, func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	waiting := make(chan error, 1)
+	var ran atomic.Bool
+	go func() {
+		waiting <- q.ExecuteWithQueue(context.Background(), 0, func() error {
+			ran.Store(true)
+			return nil
+		})
+	}()
+	waitForQueued(t, q, 1)
+
+	// the running scan outlives the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := q.Shutdown(ctx)
+	testutil.AssertEquals(t, true, errors.Is(err, context.DeadlineExceeded))
+
+	select {
+	case err := <-waiting:
+		testutil.AssertEquals(t, true, errors.Is(err, queue.ErrClosed))
+	case <-time.After(time.Second):
+		t.Fatal("waiting scan was not turned away")
+	}
+	err = q.ExecuteWithQueue(context.Background(), 0, func() error { return nil })
+	testutil.AssertEquals(t, true, errors.Is(err, queue.ErrClosed))
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- q.Shutdown(context.Background()) }()
+	close(release)
+	testutil.AssertNoError(t, <-finished)
+	select {
+	case err := <-shutdown:
+		testutil.AssertNoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown did not return once the running scan finished")
+	}
+
+	testutil.AssertEquals(t, false, ran.Load())
+	running, queued, _ := q.GetStatus()
+	testutil.AssertEquals(t, 0, running)
+	testutil.AssertEquals(t, 0, queued)
+}
+
+func TestResetGlobalQueueForTests(t *testing.T) {
+	q := testutil.ResetGlobalQueueForTests(t, 3)
+
+	if queue.Global() != q {
+		t.Fatal("Global did not return the reset queue")
+	}
+	_, _, maxConcurrent := queue.Global().GetStatus()
+	testutil.AssertEquals(t, 3, maxConcurrent)
+}
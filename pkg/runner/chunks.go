@@ -0,0 +1,124 @@
+package runner
+
+import (
+	"os"
+	"pipeliner/pkg/logger"
+	"pipeliner/pkg/tools"
+)
+
+// valueChunks tracks the chunks of a replacement run with a ChunkSize; a
+// nil one runs the values in one go.
+type valueChunks struct {
+	spec  tools.ReplacementSpec
+	total int
+	// skip is how many values a resumed run's checkpoint has done
+	skip  int
+	chunk int
+	// pending counts the values started since the last checkpoint
+	pending int
+}
+
+// startChunks sets up the chunks of a run over total values. A resumed run
+// continues from its checkpoint, and gets the outputs of the values it
+// skips back from the manifest; otherwise a checkpoint left by an earlier
+// run is removed.
+func (r *ReplacementCommandRunner) startChunks(spec tools.ReplacementSpec, outputs *outputNamer, total int) *valueChunks {
+	if spec.ChunkSize <= 0 {
+		return nil
+	}
+	chunks := &valueChunks{spec: spec, total: total}
+	if !spec.ResumeChunks {
+		if err := os.Remove(spec.CheckpointPath); err != nil && !os.IsNotExist(err) {
+			r.logger.WithFields(logger.Fields{"error": err}).Warn("Failed to remove old chunk checkpoint")
+		}
+		return chunks
+	}
+
+	checkpoint, err := tools.ReadChunkCheckpoint(spec.CheckpointPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			r.logger.WithFields(logger.Fields{"error": err}).Warn("Ignoring chunk checkpoint")
+		}
+		return chunks
+	}
+	if checkpoint.Total != total {
+		r.logger.WithFields(logger.Fields{
+			"checkpoint_total": checkpoint.Total,
+			"total":            total,
+		}).Warn("Replacement values changed since the checkpoint, starting over")
+		return chunks
+	}
+	chunks.skip, chunks.chunk = checkpoint.Done, checkpoint.Chunk
+	if outputs != nil && spec.ManifestPath != "" {
+		if manifest, err := tools.ReadOutputManifest(spec.ManifestPath); err == nil {
+			for value, file := range manifest.Outputs {
+				outputs.byValue[value] = file
+				outputs.used[file] = true
+			}
+		}
+	}
+	r.logger.WithFields(logger.Fields{
+		"done":  checkpoint.Done,
+		"total": total,
+		"chunk": checkpoint.Chunk,
+	}).Infof("Resuming replacement values after chunk %d, %d/%d done", checkpoint.Chunk, checkpoint.Done, total)
+	return chunks
+}
+
+// skipped reports whether the value at index, counting from 1, was done
+// before the checkpoint.
+func (c *valueChunks) skipped(index int) bool {
+	return c != nil && index <= c.skip
+}
+
+// started counts a value in and reports whether it fills the chunk.
+func (c *valueChunks) started() bool {
+	if c == nil {
+		return false
+	}
+	c.pending++
+	return c.pending >= c.spec.ChunkSize
+}
+
+// finishChunk checkpoints a chunk once all of its values are done: the first
+// done values have run, failures of them failed.
+func (r *ReplacementCommandRunner) finishChunk(c *valueChunks, outputs *outputNamer, done, failures int) {
+	if c == nil || c.pending == 0 {
+		return
+	}
+	c.chunk++
+	c.pending = 0
+	if outputs != nil {
+		r.writeManifest(c.spec, outputs)
+	}
+	checkpoint := &tools.ChunkCheckpoint{Tool: c.spec.Tool, Chunk: c.chunk, Done: done, Total: c.total}
+	if err := tools.WriteChunkCheckpoint(c.spec.CheckpointPath, checkpoint); err != nil {
+		r.logger.WithFields(logger.Fields{"error": err}).Error("Failed to write chunk checkpoint")
+	}
+	r.logger.WithFields(logger.Fields{
+		"chunk":    c.chunk,
+		"done":     done,
+		"total":    c.total,
+		"failures": failures,
+	}).Infof("Finished chunk %d, %d/%d replacement values done", c.chunk, done, c.total)
+	if c.spec.OnChunk != nil {
+		c.spec.OnChunk(tools.ChunkProgress{
+			Tool:     c.spec.Tool,
+			Stage:    c.spec.Stage,
+			Chunk:    c.chunk,
+			Done:     done,
+			Total:    c.total,
+			Failures: failures,
+		})
+	}
+}
+
+// completeChunks removes the checkpoint of a run that got through every value.
+func (r *ReplacementCommandRunner) completeChunks(c *valueChunks) {
+	if c == nil {
+		return
+	}
+	if err := os.Remove(c.spec.CheckpointPath); err != nil && !os.IsNotExist(err) {
+		r.logger.WithFields(logger.Fields{"error": err}).Warn("Failed to remove chunk checkpoint")
+	}
+}
@@ -0,0 +1,157 @@
+package runner_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"pipeliner/pkg/runner"
+	"pipeliner/pkg/tools"
+)
+
+// valueRecorder records the value each command ran for, which is the
+// argument after -u.
+type valueRecorder struct {
+	mu     sync.Mutex
+	values []string
+}
+
+func (r *valueRecorder) Run(ctx context.Context, command string, args []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values = append(r.values, args[1])
+	return nil
+}
+
+func (r *valueRecorder) ran() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.values...)
+}
+
+func chunkedSpec(t *testing.T, values int) (tools.ReplacementSpec, []string) {
+	t.Helper()
+	dir := t.TempDir()
+	var urls []string
+	for i := 1; i <= values; i++ {
+		urls = append(urls, fmt.Sprintf("https://h%d.example.com", i))
+	}
+	if err := os.WriteFile(filepath.Join(dir, "urls.txt"), []byte(strings.Join(urls, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return tools.ReplacementSpec{
+		Token:          "{{URL}}",
+		Files:          []string{"urls.txt"},
+		Dir:            dir,
+		OutputTemplate: "{{value_sanitized}}_ffuf_output.json",
+		ManifestPath:   tools.OutputManifestFile("ffuf"),
+		Tool:           "ffuf",
+		Stage:          tools.StageRecon,
+		ChunkSize:      3,
+		CheckpointPath: tools.ChunkCheckpointFile("ffuf"),
+	}, urls
+}
+
+var chunkArgs = []string{"-u", "{{URL}}", "-o", tools.OutputPlaceholder}
+
+func TestReplacementCommandRunner_Chunks(t *testing.T) {
+	for _, concurrency := range []int{1, 2} {
+		t.Run(fmt.Sprintf("concurrency %d", concurrency), func(t *testing.T) {
+			spec, urls := chunkedSpec(t, 7)
+			spec.Concurrency = concurrency
+			recorder := &valueRecorder{}
+
+			var progress []tools.ChunkProgress
+			spec.OnChunk = func(p tools.ChunkProgress) {
+				// each chunk's values are done and in the manifest and
+				// checkpoint before it is reported
+				if ran := len(recorder.ran()); ran != p.Done {
+					t.Errorf("chunk %d reported with %d values run, want %d", p.Chunk, ran, p.Done)
+				}
+				manifest, err := tools.ReadOutputManifest(filepath.Join(spec.Dir, spec.ManifestPath))
+				if err != nil || len(manifest.Outputs) != p.Done {
+					t.Errorf("manifest after chunk %d = %+v, %v", p.Chunk, manifest, err)
+				}
+				checkpoint, err := tools.ReadChunkCheckpoint(filepath.Join(spec.Dir, spec.CheckpointPath))
+				if err != nil || checkpoint.Done != p.Done || checkpoint.Chunk != p.Chunk {
+					t.Errorf("checkpoint after chunk %d = %+v, %v", p.Chunk, checkpoint, err)
+				}
+				progress = append(progress, p)
+			}
+
+			if err := runner.NewReplacementCommandRunner(recorder).RunWithReplacementSpec(context.Background(), "ffuf", chunkArgs, spec); err != nil {
+				t.Fatal(err)
+			}
+
+			want := []tools.ChunkProgress{
+				{Tool: "ffuf", Stage: tools.StageRecon, Chunk: 1, Done: 3, Total: 7},
+				{Tool: "ffuf", Stage: tools.StageRecon, Chunk: 2, Done: 6, Total: 7},
+				{Tool: "ffuf", Stage: tools.StageRecon, Chunk: 3, Done: 7, Total: 7},
+			}
+			if !reflect.DeepEqual(progress, want) {
+				t.Fatalf("chunks = %+v, want %+v", progress, want)
+			}
+			if ran := recorder.ran(); len(ran) != len(urls) {
+				t.Fatalf("ran %v, want every value once", ran)
+			}
+			// a finished run leaves no checkpoint to resume from
+			if _, err := os.Stat(filepath.Join(spec.Dir, spec.CheckpointPath)); !os.IsNotExist(err) {
+				t.Fatalf("checkpoint left after the run: %v", err)
+			}
+		})
+	}
+}
+
+func TestReplacementCommandRunner_ResumeChunks(t *testing.T) {
+	spec, urls := chunkedSpec(t, 7)
+	recorder := &valueRecorder{}
+
+	// interrupted after the first chunk
+	ctx, cancel := context.WithCancel(context.Background())
+	spec.OnChunk = func(tools.ChunkProgress) { cancel() }
+	err := runner.NewReplacementCommandRunner(recorder).RunWithReplacementSpec(ctx, "ffuf", chunkArgs, spec)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want the cancellation", err)
+	}
+	if _, ok := tools.InterruptedRun(tools.ToolConfig{Name: "ffuf", ReplaceChunkSize: 3}, spec.Dir); !ok {
+		t.Fatal("the checkpoint should mark the run interrupted")
+	}
+
+	var chunks []int
+	spec.OnChunk = func(p tools.ChunkProgress) { chunks = append(chunks, p.Chunk) }
+	spec.ResumeChunks = true
+	resumed := &valueRecorder{}
+	if err := runner.NewReplacementCommandRunner(resumed).RunWithReplacementSpec(context.Background(), "ffuf", chunkArgs, spec); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(resumed.ran(), urls[3:]) {
+		t.Fatalf("resumed run ran %v, want %v", resumed.ran(), urls[3:])
+	}
+	if !reflect.DeepEqual(chunks, []int{2, 3}) {
+		t.Fatalf("resumed run reported chunks %v, want [2 3]", chunks)
+	}
+	manifest, err := tools.ReadOutputManifest(filepath.Join(spec.Dir, spec.ManifestPath))
+	if err != nil || len(manifest.Outputs) != len(urls) {
+		t.Fatalf("manifest = %+v, %v, want the outputs of both runs", manifest, err)
+	}
+
+	// without ResumeChunks an old checkpoint is ignored
+	if err := tools.WriteChunkCheckpoint(filepath.Join(spec.Dir, spec.CheckpointPath), &tools.ChunkCheckpoint{Tool: "ffuf", Chunk: 1, Done: 3, Total: 7}); err != nil {
+		t.Fatal(err)
+	}
+	spec.ResumeChunks = false
+	fresh := &valueRecorder{}
+	if err := runner.NewReplacementCommandRunner(fresh).RunWithReplacementSpec(context.Background(), "ffuf", chunkArgs, spec); err != nil {
+		t.Fatal(err)
+	}
+	if len(fresh.ran()) != len(urls) {
+		t.Fatalf("fresh run ran %v, want every value", fresh.ran())
+	}
+}
@@ -0,0 +1,20 @@
+package runner
+
+import (
+	"os/exec"
+	"pipeliner/pkg/tools"
+	"strconv"
+)
+
+// niceCommand starts command at niceness nice, with the I/O priority that
+// goes with it where ionice is installed. nice the command is used rather
+// than setting the priority after start, so it holds for every thread and
+// child process the tool starts.
+func niceCommand(nice int, command string, args []string) (string, []string) {
+	// nice -n adds to the niceness the server runs at
+	wrapped := append([]string{"-n", strconv.Itoa(nice - serverNice()), command}, args...)
+	if _, err := exec.LookPath("ionice"); err != nil {
+		return "nice", wrapped
+	}
+	return "ionice", append([]string{"-c", "2", "-n", strconv.Itoa(tools.IOPriority(nice)), "nice"}, wrapped...)
+}
@@ -0,0 +1,15 @@
+//go:build linux
+
+package runner
+
+import "syscall"
+
+// serverNice is the niceness of the server process.
+func serverNice() int {
+	// the raw syscall returns 20 - nice
+	prio, err := syscall.Getpriority(syscall.PRIO_PROCESS, 0)
+	if err != nil {
+		return 0
+	}
+	return 20 - prio
+}
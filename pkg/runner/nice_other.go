@@ -0,0 +1,9 @@
+//go:build !linux
+
+package runner
+
+// serverNice is the niceness of the server process, taken to be the
+// default outside Linux.
+func serverNice() int {
+	return 0
+}
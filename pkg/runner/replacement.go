@@ -3,12 +3,15 @@ package runner
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"pipeliner/pkg/logger"
 	"pipeliner/pkg/tools"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 )
@@ -33,85 +36,342 @@ func (r *ReplacementCommandRunner) Run(ctx context.Context, command string, args
 	return r.baseRunner.Run(ctx, command, args)
 }
 
+// RunInDir runs a command once through the base runner, in dir.
+func (r *ReplacementCommandRunner) RunInDir(ctx context.Context, dir, command string, args []string) error {
+	r.logger.WithFields(logger.Fields{
+		"command": command,
+		"args":    args,
+		"dir":     dir,
+	}).Info("Running command")
+	return tools.RunIn(ctx, r.baseRunner, dir, command, args)
+}
+
+// Output runs a command once through the base runner and returns its output.
+func (r *ReplacementCommandRunner) Output(ctx context.Context, dir, command string, args []string) ([]byte, error) {
+	base, ok := r.baseRunner.(tools.OutputCommandRunner)
+	if !ok {
+		return nil, fmt.Errorf("runner cannot capture the output of %s", command)
+	}
+	return base.Output(ctx, dir, command, args)
+}
+
 func (r *ReplacementCommandRunner) RunWithReplacement(ctx context.Context, command string, args []string, replaceToken, replaceFromFile string) error {
+	return r.RunWithReplacementFiles(ctx, command, args, replaceToken, []string{replaceFromFile})
+}
+
+// RunWithReplacementFiles runs command once per value found across
+// replaceFromFiles. Values are streamed in file order then line order, and a
+// value already seen in an earlier line or file is skipped.
+func (r *ReplacementCommandRunner) RunWithReplacementFiles(ctx context.Context, command string, args []string, replaceToken string, replaceFromFiles []string) error {
+	return r.RunWithReplacementSpec(ctx, command, args, tools.ReplacementSpec{Token: replaceToken, Files: replaceFromFiles})
+}
+
+// RunWithReplacementSpec is RunWithReplacementFiles plus, when the spec has an
+// OutputTemplate, a per-value output file and a manifest of value to file.
+// With a Dir, the commands start there and relative paths are read from it.
+func (r *ReplacementCommandRunner) RunWithReplacementSpec(ctx context.Context, command string, args []string, spec tools.ReplacementSpec) error {
+	spec = resolveSpecPaths(spec)
 	r.logger.WithFields(logger.Fields{
-		"command":          command,
-		"args":             args,
-		"token":            replaceToken,
-		"replacement_file": replaceFromFile,
+		"command":           command,
+		"args":              args,
+		"token":             spec.Token,
+		"replacement_files": spec.Files,
+		"output_template":   spec.OutputTemplate,
 	}).Info("Running replacement command")
 
-	replacementValues, err := r.readReplacementValues(replaceFromFile)
+	var outputs *outputNamer
+	if spec.OutputTemplate != "" {
+		outputs = newOutputNamer(spec.OutputTemplate)
+		defer r.writeManifest(spec, outputs)
+	}
+
+	total := 0
+	if err := r.forEachReplacementValue(spec.Files, func(string) error { total++; return nil }); err != nil {
+		return err
+	}
+
+	// past the first values only a sample is logged in detail, with a
+	// periodic summary; failures are always logged. mu guards the sampler
+	// and failed, which concurrent values share.
+	sampler := r.logger.NewSampler(nil)
+	var (
+		mu     sync.Mutex
+		failed []error
+	)
+	concurrency := max(spec.Concurrency, 1)
+	pool := newValuePool(ctx, concurrency)
+	chunks := r.startChunks(spec, outputs, total)
+	index := 0
+	err := r.forEachReplacementValue(spec.Files, func(value string) error {
+		// no new values once the scan is cancelled
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		index++
+		if chunks.skipped(index) {
+			return nil
+		}
+
+		mu.Lock()
+		detailed := sampler.Next()
+		mu.Unlock()
+		var replacedArgs []string
+		var outputFile string
+		if outputs != nil {
+			outputFile = outputs.name(value, r.sanitizeForFilename(value), index)
+			replacedArgs = replaceOutputInArgs(args, spec.Token, value, outputFile)
+		} else {
+			replacedArgs = r.replaceInArgs(args, spec.Token, value)
+		}
+
+		runCtx := withReplacementValue(ctx, value)
+		if limit, ok := tools.OutputLimitFromContext(ctx); ok && outputs != nil {
+			limit.Files = []string{outputFile}
+			runCtx = tools.WithOutputLimit(runCtx, limit)
+		}
+		if detailed {
+			r.logger.WithFields(logger.Fields{
+				"current": index,
+				"total":   total,
+				"value":   value,
+			}).Info("Processing replacement")
+			r.logger.WithFields(logger.Fields{
+				"command": command,
+				"args":    strings.Join(replacedArgs, " "),
+				"value":   value,
+			}).Info("Executing replacement command")
+		} else {
+			runCtx = withQuietLogging(runCtx)
+		}
+
+		if err := pool.run(func() error {
+			if err := tools.WaitForHost(ctx, value); err != nil {
+				return err
+			}
+			if err := tools.RunIn(runCtx, r.baseRunner, spec.Dir, command, replacedArgs); err != nil {
+				mu.Lock()
+				sampler.Fail()
+				failed = append(failed, fmt.Errorf("%s: %w", value, err))
+				mu.Unlock()
+				r.logger.WithFields(logger.Fields{
+					"value": value,
+					"error": err,
+				}).Error("Command failed for replacement value")
+			}
+
+			mu.Lock()
+			due, processed, failures := sampler.SummaryDue(), sampler.Count(), sampler.Failures()
+			mu.Unlock()
+			if due {
+				r.logger.WithFields(logger.Fields{
+					"processed": processed,
+					"total":     total,
+					"failures":  failures,
+				}).Infof("Processed %d/%d replacement values, %d failures", processed, total, failures)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		// a chunk's values are all done before its checkpoint
+		if chunks.started() {
+			if err := pool.wait(); err != nil {
+				return err
+			}
+			mu.Lock()
+			failures := sampler.Failures()
+			mu.Unlock()
+			r.finishChunk(chunks, outputs, index, failures)
+		}
+		return nil
+	})
+	if waitErr := pool.wait(); err == nil {
+		err = waitErr
+	}
 	if err != nil {
-		return fmt.Errorf("failed to read replacement values from %s: %w", replaceFromFile, err)
+		return err
 	}
+	r.finishChunk(chunks, outputs, index, sampler.Failures())
+	r.completeChunks(chunks)
 
-	if len(replacementValues) == 0 {
+	if sampler.Count() == 0 {
 		r.logger.WithFields(logger.Fields{
-			"file": replaceFromFile,
-		}).Warn("No replacement values found in file")
+			"files": spec.Files,
+		}).Warn("No replacement values found in files")
 		return nil
 	}
 
 	r.logger.WithFields(logger.Fields{
-		"count": len(replacementValues),
-		"file":  replaceFromFile,
-	}).Info("Found replacement values")
+		"count":    sampler.Count(),
+		"failures": sampler.Failures(),
+		"files":    spec.Files,
+	}).Info("Finished replacement values")
+	if concurrency > 1 && len(failed) > 0 {
+		return fmt.Errorf("%d of %d replacement values failed: %w", len(failed), sampler.Count(), errors.Join(failed...))
+	}
+	return nil
+}
 
-	for i, value := range replacementValues {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
+// valuePool runs replacement values on up to size goroutines. With a size
+// of 1 each value runs on the caller's goroutine, one after another.
+type valuePool struct {
+	ctx   context.Context
+	slots chan struct{}
+	wg    sync.WaitGroup
 
-		r.logger.WithFields(logger.Fields{
-			"current": i + 1,
-			"total":   len(replacementValues),
-			"value":   value,
-		}).Info("Processing replacement")
+	mu   sync.Mutex
+	errs []error
+}
 
-		replacedArgs := r.replaceInArgs(args, replaceToken, value)
+func newValuePool(ctx context.Context, size int) *valuePool {
+	return &valuePool{ctx: ctx, slots: make(chan struct{}, size)}
+}
 
-		r.logger.WithFields(logger.Fields{
-			"command": command,
-			"args":    strings.Join(replacedArgs, " "),
-		}).Info("Executing replacement command")
+// run starts fn once a slot is free, or gives up without starting it when
+// ctx is done first.
+func (p *valuePool) run(fn func() error) error {
+	if cap(p.slots) == 1 {
+		return fn()
+	}
+	select {
+	case p.slots <- struct{}{}:
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer func() { <-p.slots }()
+		if err := fn(); err != nil {
+			p.mu.Lock()
+			p.errs = append(p.errs, err)
+			p.mu.Unlock()
+		}
+	}()
+	return nil
+}
 
-		err := r.baseRunner.Run(ctx, command, replacedArgs)
-		if err != nil {
-			r.logger.WithFields(logger.Fields{
-				"value": value,
-				"error": err,
-			}).Error("Command failed for replacement value")
+// wait blocks until every started value is done and returns their errors.
+func (p *valuePool) wait() error {
+	p.wg.Wait()
+	return errors.Join(p.errs...)
+}
+
+// resolveSpecPaths anchors the spec's relative files in its Dir, so they
+// do not depend on the process's working directory.
+func resolveSpecPaths(spec tools.ReplacementSpec) tools.ReplacementSpec {
+	if spec.Dir == "" {
+		return spec
+	}
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
 		}
+		return filepath.Join(spec.Dir, path)
+	}
+	files := make([]string, len(spec.Files))
+	for i, file := range spec.Files {
+		files[i] = resolve(file)
 	}
+	spec.Files = files
+	spec.ManifestPath = resolve(spec.ManifestPath)
+	spec.CheckpointPath = resolve(spec.CheckpointPath)
+	return spec
+}
+
+// SetLogger replaces the runner's logger, and with it the sampling config.
+func (r *ReplacementCommandRunner) SetLogger(l *logger.Logger) {
+	r.logger = l
+}
 
+func (r *ReplacementCommandRunner) writeManifest(spec tools.ReplacementSpec, outputs *outputNamer) {
+	if spec.ManifestPath == "" {
+		return
+	}
+	manifest := &tools.OutputManifest{Tool: spec.Tool, Stage: spec.Stage, Outputs: outputs.byValue}
+	if err := tools.WriteOutputManifest(spec.ManifestPath, manifest); err != nil {
+		r.logger.WithFields(logger.Fields{"error": err}).Error("Failed to write output manifest")
+	}
+}
+
+// outputNamer renders output_per_value names, suffixing the run index when
+// two values would otherwise share a file.
+type outputNamer struct {
+	template string
+	byValue  map[string]string
+	used     map[string]bool
+}
+
+func newOutputNamer(template string) *outputNamer {
+	return &outputNamer{
+		template: template,
+		byValue:  make(map[string]string),
+		used:     make(map[string]bool),
+	}
+}
+
+func (n *outputNamer) name(value, sanitized string, index int) string {
+	name := tools.RenderOutputName(n.template, value, sanitized, index)
+	if n.used[name] {
+		ext := filepath.Ext(name)
+		name = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, ext), index, ext)
+	}
+	n.used[name] = true
+	n.byValue[value] = name
+	return name
+}
+
+// replaceOutputInArgs is the explicit counterpart to replaceInArgs: the value
+// is substituted verbatim and the output placeholder gets the rendered name.
+func replaceOutputInArgs(args []string, token, value, outputFile string) []string {
+	replaced := make([]string, len(args))
+	for i, arg := range args {
+		arg = strings.ReplaceAll(arg, tools.OutputPlaceholder, outputFile)
+		replaced[i] = strings.ReplaceAll(arg, token, value)
+	}
+	return replaced
+}
+
+// forEachReplacementValue streams the non-empty, non-comment lines of files to
+// fn, skipping duplicates. Only the set of seen values is kept in memory.
+func (r *ReplacementCommandRunner) forEachReplacementValue(files []string, fn func(value string) error) error {
+	seen := make(map[string]struct{})
+	for _, filename := range files {
+		if err := r.streamReplacementFile(filename, seen, fn); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (r *ReplacementCommandRunner) readReplacementValues(filename string) ([]string, error) {
+func (r *ReplacementCommandRunner) streamReplacementFile(filename string, seen map[string]struct{}, fn func(value string) error) error {
 	file, err := os.Open(filename)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file %s: %w", filename, err)
+		return fmt.Errorf("failed to read replacement values from %s: %w", filename, err)
 	}
 	defer file.Close()
 
-	var values []string
 	scanner := bufio.NewScanner(file)
-
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
-		if line != "" && !strings.HasPrefix(line, "#") {
-			values = append(values, line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, dup := seen[line]; dup {
+			continue
+		}
+		seen[line] = struct{}{}
+
+		if err := fn(line); err != nil {
+			return err
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file %s: %w", filename, err)
+		return fmt.Errorf("error reading file %s: %w", filename, err)
 	}
-
-	return values, nil
+	return nil
 }
 
 func (r *ReplacementCommandRunner) replaceInArgs(args []string, token, value string) []string {
@@ -1,12 +1,24 @@
 package runner_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"pipeliner/pkg/logger"
 	"pipeliner/pkg/runner"
+	"pipeliner/pkg/tools"
+
+	"github.com/sirupsen/logrus"
 )
 
 // MockBaseRunner implements the CommandRunner interface for testing
@@ -115,3 +127,342 @@ func TestReplacementCommandRunner_Run(t *testing.T) {
 		}
 	}
 }
+
+func TestReplacementCommandRunner_RunWithReplacementFiles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	httpxFile := filepath.Join(tempDir, "httpx_output.txt")
+	probeFile := filepath.Join(tempDir, "probe_output.txt")
+	if err := os.WriteFile(httpxFile, []byte("https://a.example.com\nhttps://b.example.com\n\n# comment\nhttps://a.example.com\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if err := os.WriteFile(probeFile, []byte("https://c.example.com\nhttps://b.example.com\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	mockRunner := &MockBaseRunner{}
+	replacementRunner := runner.NewReplacementCommandRunner(mockRunner)
+
+	err := replacementRunner.RunWithReplacementFiles(context.Background(), "ffuf", []string{"-u", "{{URL}}/FUZZ"}, "{{URL}}", []string{httpxFile, probeFile})
+	if err != nil {
+		t.Fatalf("RunWithReplacementFiles failed: %v", err)
+	}
+
+	// file order, then line order, each value once
+	expectedURLs := []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"}
+	if len(mockRunner.ExecutedCommands) != len(expectedURLs) {
+		t.Fatalf("Expected %d commands, got %d", len(expectedURLs), len(mockRunner.ExecutedCommands))
+	}
+	for i, execCmd := range mockRunner.ExecutedCommands {
+		if execCmd.Args[1] != expectedURLs[i]+"/FUZZ" {
+			t.Errorf("Command %d: expected '%s/FUZZ', got '%s'", i, expectedURLs[i], execCmd.Args[1])
+		}
+	}
+}
+
+func TestReplacementCommandRunner_MissingFileFails(t *testing.T) {
+	mockRunner := &MockBaseRunner{}
+	replacementRunner := runner.NewReplacementCommandRunner(mockRunner)
+
+	err := replacementRunner.RunWithReplacementFiles(context.Background(), "ffuf", []string{"-u", "{{URL}}"}, "{{URL}}", []string{filepath.Join(t.TempDir(), "missing.txt")})
+	if err == nil {
+		t.Fatal("Expected an error for a missing replacement file")
+	}
+	if len(mockRunner.ExecutedCommands) != 0 {
+		t.Errorf("Expected no commands, got %d", len(mockRunner.ExecutedCommands))
+	}
+}
+
+func TestReplacementCommandRunner_OutputPerValue(t *testing.T) {
+	tempDir := t.TempDir()
+
+	urlFile := filepath.Join(tempDir, "urls.txt")
+	// the last two values sanitize to the same name and must not collide
+	if err := os.WriteFile(urlFile, []byte("https://a.example.com\nhttp://b.example.com\nhttps://b.example.com\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	mockRunner := &MockBaseRunner{}
+	replacementRunner := runner.NewReplacementCommandRunner(mockRunner)
+
+	manifestPath := filepath.Join(tempDir, tools.OutputManifestFile("ffuf"))
+	spec := tools.ReplacementSpec{
+		Token:          "{{URL}}",
+		Files:          []string{urlFile},
+		OutputTemplate: "{{value_sanitized}}_ffuf_output.json",
+		ManifestPath:   manifestPath,
+		Tool:           "ffuf",
+	}
+	args := []string{"-u", "{{URL}}/FUZZ", "-o", tools.OutputPlaceholder}
+
+	if err := replacementRunner.RunWithReplacementSpec(context.Background(), "ffuf", args, spec); err != nil {
+		t.Fatalf("RunWithReplacementSpec failed: %v", err)
+	}
+
+	expectedOutputs := []string{"a.example.com_ffuf_output.json", "b.example.com_ffuf_output.json", "b.example.com_ffuf_output_3.json"}
+	if len(mockRunner.ExecutedCommands) != len(expectedOutputs) {
+		t.Fatalf("Expected %d commands, got %d", len(expectedOutputs), len(mockRunner.ExecutedCommands))
+	}
+	for i, execCmd := range mockRunner.ExecutedCommands {
+		if execCmd.Args[3] != expectedOutputs[i] {
+			t.Errorf("Command %d: expected output '%s', got '%s'", i, expectedOutputs[i], execCmd.Args[3])
+		}
+	}
+
+	manifest, err := tools.ReadOutputManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	if manifest.Tool != "ffuf" {
+		t.Errorf("Expected manifest tool 'ffuf', got '%s'", manifest.Tool)
+	}
+	if got := manifest.Outputs["https://b.example.com"]; got != "b.example.com_ffuf_output_3.json" {
+		t.Errorf("Unexpected manifest entry for https://b.example.com: '%s'", got)
+	}
+}
+
+// dirRecorder records the directory each command was started in.
+type dirRecorder struct {
+	dirs []string
+}
+
+func (r *dirRecorder) Run(ctx context.Context, command string, args []string) error {
+	return r.RunInDir(ctx, "", command, args)
+}
+
+func (r *dirRecorder) RunInDir(ctx context.Context, dir, command string, args []string) error {
+	r.dirs = append(r.dirs, dir)
+	return nil
+}
+
+func TestReplacementCommandRunner_SpecDir(t *testing.T) {
+	scanDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(scanDir, "httpx_output.txt"), []byte("https://a.example.com\nhttps://b.example.com\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	recorder := &dirRecorder{}
+	spec := tools.ReplacementSpec{
+		Token:          "{{URL}}",
+		Files:          []string{"httpx_output.txt"},
+		Dir:            scanDir,
+		OutputTemplate: "{{index}}.json",
+		ManifestPath:   tools.OutputManifestFile("ffuf"),
+		Tool:           "ffuf",
+	}
+	args := []string{"-u", "{{URL}}", "-o", tools.OutputPlaceholder}
+	if err := runner.NewReplacementCommandRunner(recorder).RunWithReplacementSpec(context.Background(), "ffuf", args, spec); err != nil {
+		t.Fatalf("RunWithReplacementSpec failed: %v", err)
+	}
+
+	if len(recorder.dirs) != 2 || recorder.dirs[0] != scanDir || recorder.dirs[1] != scanDir {
+		t.Errorf("Expected both runs in %s, got %v", scanDir, recorder.dirs)
+	}
+	if _, err := tools.ReadOutputManifest(filepath.Join(scanDir, tools.OutputManifestFile("ffuf"))); err != nil {
+		t.Errorf("Expected the manifest in the scan directory: %v", err)
+	}
+}
+
+// failEveryRunner fails the runs whose value ends in "7", one in ten.
+type failEveryRunner struct{}
+
+func (failEveryRunner) Run(ctx context.Context, command string, args []string) error {
+	if strings.HasSuffix(args[1], "7") {
+		return errors.New("exit status 1")
+	}
+	return nil
+}
+
+func TestReplacementCommandRunner_SamplesLogs(t *testing.T) {
+	tempDir := t.TempDir()
+
+	var values strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&values, "https://host%d.example.com/%d\n", i, i%10)
+	}
+	valuesFile := filepath.Join(tempDir, "urls.txt")
+	if err := os.WriteFile(valuesFile, []byte(values.String()), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	var out bytes.Buffer
+	log := logger.NewLogger(logrus.InfoLevel)
+	log.SetOutput(&out)
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.Sampling = logger.SamplingConfig{First: 10, Every: 100, SummaryInterval: time.Hour}
+
+	replacementRunner := runner.NewReplacementCommandRunner(failEveryRunner{})
+	replacementRunner.SetLogger(log)
+	if err := replacementRunner.RunWithReplacementFiles(context.Background(), "ffuf", []string{"-u", "{{URL}}"}, "{{URL}}", []string{valuesFile}); err != nil {
+		t.Fatalf("RunWithReplacementFiles failed: %v", err)
+	}
+
+	counts := make(map[string]int)
+	var finished map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("bad log line %q: %v", line, err)
+		}
+		msg := entry["msg"].(string)
+		counts[msg]++
+		if msg == "Finished replacement values" {
+			finished = entry
+		}
+	}
+
+	// the first 10 values, then values 110, 210, ... 910
+	if counts["Processing replacement"] != 19 || counts["Executing replacement command"] != 19 {
+		t.Errorf("detail lines = %d processing, %d executing, want 19 each", counts["Processing replacement"], counts["Executing replacement command"])
+	}
+	if counts["Command failed for replacement value"] != 100 {
+		t.Errorf("failure lines = %d, want all 100", counts["Command failed for replacement value"])
+	}
+	if finished == nil || finished["count"] != float64(1000) || finished["failures"] != float64(100) {
+		t.Errorf("summary line = %v", finished)
+	}
+}
+
+// concurrentRunner records the values it ran and how many ran at once. Runs
+// for values ending in "bad" fail; release, when set, holds every run until
+// it is closed.
+type concurrentRunner struct {
+	delay   time.Duration
+	release chan struct{}
+	started chan string
+
+	mu      sync.Mutex
+	values  []string
+	running int
+	peak    int
+}
+
+func (c *concurrentRunner) Run(ctx context.Context, command string, args []string) error {
+	value := args[1]
+	c.mu.Lock()
+	c.values = append(c.values, value)
+	c.running++
+	c.peak = max(c.peak, c.running)
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.running--
+		c.mu.Unlock()
+	}()
+
+	if c.started != nil {
+		c.started <- value
+	}
+	if c.release != nil {
+		select {
+		case <-c.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	time.Sleep(c.delay)
+	if strings.HasSuffix(value, "bad") {
+		return errors.New("exit status 1")
+	}
+	return nil
+}
+
+func writeValues(t *testing.T, values ...string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "values.txt")
+	if err := os.WriteFile(file, []byte(strings.Join(values, "\n")), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	return file
+}
+
+func TestReplacementCommandRunner_Concurrency(t *testing.T) {
+	var values []string
+	for i := 0; i < 12; i++ {
+		values = append(values, fmt.Sprintf("host%02d.example.com", i))
+	}
+	file := writeValues(t, values...)
+
+	for _, concurrency := range []int{0, 1, 4} {
+		t.Run(fmt.Sprint(concurrency), func(t *testing.T) {
+			base := &concurrentRunner{delay: 20 * time.Millisecond}
+			spec := tools.ReplacementSpec{Token: "{{HOST}}", Files: []string{file}, Concurrency: concurrency}
+			if err := runner.NewReplacementCommandRunner(base).RunWithReplacementSpec(context.Background(), "httpx", []string{"-u", "{{HOST}}"}, spec); err != nil {
+				t.Fatalf("RunWithReplacementSpec failed: %v", err)
+			}
+
+			// every value runs once, in whatever order they finish
+			got := append([]string(nil), base.values...)
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(values, ",") {
+				t.Errorf("Expected each value once, got %v", base.values)
+			}
+			limit := max(concurrency, 1)
+			if base.peak > limit {
+				t.Errorf("Expected at most %d runs at once, got %d", limit, base.peak)
+			}
+			if limit > 1 && base.peak < 2 {
+				t.Errorf("Expected runs to overlap, peak was %d", base.peak)
+			}
+		})
+	}
+}
+
+func TestReplacementCommandRunner_ConcurrentFailuresAreJoined(t *testing.T) {
+	file := writeValues(t, "a.example.com", "first.bad", "b.example.com", "second.bad")
+
+	base := &concurrentRunner{}
+	spec := tools.ReplacementSpec{Token: "{{HOST}}", Files: []string{file}, Concurrency: 2}
+	err := runner.NewReplacementCommandRunner(base).RunWithReplacementSpec(context.Background(), "httpx", []string{"-u", "{{HOST}}"}, spec)
+	if err == nil {
+		t.Fatal("Expected the failed values to fail the run")
+	}
+	for _, want := range []string{"2 of 4 replacement values failed", "first.bad: exit status 1", "second.bad: exit status 1"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %q", want, err.Error())
+		}
+	}
+	if len(base.values) != 4 {
+		t.Errorf("Expected every value to run, got %v", base.values)
+	}
+
+	// one at a time, failures are only logged as before
+	spec.Concurrency = 1
+	if err := runner.NewReplacementCommandRunner(&concurrentRunner{}).RunWithReplacementSpec(context.Background(), "httpx", []string{"-u", "{{HOST}}"}, spec); err != nil {
+		t.Errorf("Expected no error without concurrency, got %v", err)
+	}
+}
+
+func TestReplacementCommandRunner_ConcurrentCancel(t *testing.T) {
+	var values []string
+	for i := 0; i < 20; i++ {
+		values = append(values, fmt.Sprintf("host%02d.example.com", i))
+	}
+	file := writeValues(t, values...)
+
+	base := &concurrentRunner{release: make(chan struct{}), started: make(chan string, len(values))}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		spec := tools.ReplacementSpec{Token: "{{HOST}}", Files: []string{file}, Concurrency: 3}
+		done <- runner.NewReplacementCommandRunner(base).RunWithReplacementSpec(ctx, "httpx", []string{"-u", "{{HOST}}"}, spec)
+	}()
+
+	// the pool is full, so cancelling starts nothing new
+	for i := 0; i < 3; i++ {
+		<-base.started
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	if len(base.values) != 3 {
+		t.Errorf("Expected only the 3 running values to have started, got %v", base.values)
+	}
+}
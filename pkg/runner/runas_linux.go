@@ -0,0 +1,21 @@
+//go:build linux
+
+package runner
+
+import (
+	"os/exec"
+	"pipeliner/pkg/tools"
+	"syscall"
+)
+
+func setCredential(cmd *exec.Cmd, cred *tools.Credential) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{
+		Uid:    cred.UID,
+		Gid:    cred.GID,
+		Groups: cred.Groups,
+	}
+	return nil
+}
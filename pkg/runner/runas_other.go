@@ -0,0 +1,13 @@
+//go:build !linux
+
+package runner
+
+import (
+	"errors"
+	"os/exec"
+	"pipeliner/pkg/tools"
+)
+
+func setCredential(cmd *exec.Cmd, cred *tools.Credential) error {
+	return errors.New("run_as is only supported on Linux")
+}
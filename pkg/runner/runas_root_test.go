@@ -0,0 +1,46 @@
+//go:build linux && root
+
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"pipeliner/pkg/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run with: sudo go test -tags root ./pkg/runner -run RunAs
+func TestSimpleRunner_RunAsDropsPrivileges(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("needs root")
+	}
+
+	// the unprivileged user has to reach the directory, like with scans/
+	dir, err := os.MkdirTemp("", "pipeliner-runas-")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	require.NoError(t, os.Chown(dir, 65534, 65534))
+
+	options := tools.DefaultOptions()
+	options.WorkingDir = dir
+	tool := tools.NewConfigurableTool("touch", "recon", tools.ToolConfig{
+		Name:    "touch",
+		Command: "touch",
+		RunAs:   "65534:65534",
+		Flags:   []tools.FlagConfig{{Flag: "out.txt", IsPositional: true}},
+	}, NewSimpleRunner())
+	require.NoError(t, tool.Run(context.Background(), options))
+
+	var info os.FileInfo
+	info, err = os.Stat(filepath.Join(dir, "out.txt"))
+	require.NoError(t, err)
+	stat := info.Sys().(*syscall.Stat_t)
+	assert.Equal(t, uint32(65534), stat.Uid)
+	assert.Equal(t, uint32(65534), stat.Gid)
+}
@@ -18,6 +18,30 @@ import (
 
 var safeFilename = regexp.MustCompile(`^[a-zA-Z0-9_\-./]+$`)
 
+type contextKey string
+
+const (
+	quietLoggingKey     contextKey = "quiet_logging"
+	replacementValueKey contextKey = "replacement_value"
+)
+
+// withQuietLogging drops a run's routine Info lines to Debug, for the runs a
+// sampled replacement loop does not log in detail. Errors still log.
+func withQuietLogging(ctx context.Context) context.Context {
+	return context.WithValue(ctx, quietLoggingKey, true)
+}
+
+func quietLogging(ctx context.Context) bool {
+	quiet, _ := ctx.Value(quietLoggingKey).(bool)
+	return quiet
+}
+
+// withReplacementValue tags a run's log lines with the replacement value it
+// runs for, so the lines of values running at once stay attributable.
+func withReplacementValue(ctx context.Context, value string) context.Context {
+	return context.WithValue(ctx, replacementValueKey, value)
+}
+
 type SimpleRunner struct {
 	logger *logger.Logger
 }
@@ -29,73 +53,157 @@ func NewSimpleRunner() *SimpleRunner {
 }
 
 func (r *SimpleRunner) Run(ctx context.Context, command string, args []string) error {
+	return r.RunInDir(ctx, "", command, args)
+}
+
+// RunInDir is Run with the command started in dir.
+func (r *SimpleRunner) RunInDir(ctx context.Context, dir, command string, args []string) error {
+	cmd, err := r.prepare(ctx, dir, command, args)
+	if err != nil {
+		return err
+	}
+
+	stdout, stderr, err := r.openStreams(ctx, dir)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	// registered with the scan's pause gate so a hard pause can suspend it
+	err = cmd.Start()
+	if err == nil {
+		untrack := tools.TrackProcess(ctx, cmd.Process)
+		stopWatch := tools.WatchOutputLimit(ctx, dir, func() { cmd.Process.Kill() })
+		err = cmd.Wait()
+		if limitErr := stopWatch(); limitErr != nil {
+			err = limitErr
+		}
+		untrack()
+	}
+	stdout.flush()
+	stderr.flush()
+	for _, s := range []*outputStream{stdout, stderr} {
+		if closeErr := s.close(); closeErr != nil {
+			r.log(ctx).WithError(closeErr).Warn("Command log is incomplete")
+		}
+	}
+
+	if err != nil {
+		stderrTail := stderr.tail()
+		if stderrTail != "" {
+			r.log(ctx).WithFields(stderr.fields()).Error("Command stderr output")
+		}
+		if stdout.tail() != "" {
+			r.log(ctx).WithFields(stdout.fields()).Info("Command stdout output")
+		}
+
+		r.log(ctx).WithError(err).Error("Command execution failed")
+		// wrapped so an output limit error stays recognisable
+		if stderrTail != "" {
+			return fmt.Errorf("execution failed: %w\nstderr: %s", err, stderrTail)
+		}
+		return fmt.Errorf("execution failed: %w", err)
+	}
+	return nil
+}
+
+// log is the runner's logger, with the replacement value of ctx if any.
+func (r *SimpleRunner) log(ctx context.Context) *logrus.Entry {
+	entry := logrus.NewEntry(r.logger.Logger)
+	if value, ok := ctx.Value(replacementValueKey).(string); ok {
+		entry = entry.WithField("value", value)
+	}
+	return entry
+}
+
+// prepare validates a command and builds it to start in dir, as the
+// context's run_as user and at its niceness.
+func (r *SimpleRunner) prepare(ctx context.Context, dir, command string, args []string) (*exec.Cmd, error) {
 	if err := r.validateCommand(command); err != nil {
-		return fmt.Errorf("invalid command: %w", err)
+		return nil, fmt.Errorf("invalid command: %w", err)
 	}
 
 	for i, arg := range args {
 		if err := r.validateArgument(arg); err != nil {
-			return fmt.Errorf("invalid argument at index %d (%s): %w", i, arg, err)
+			return nil, fmt.Errorf("invalid argument at index %d (%s): %w", i, arg, err)
 		}
 	}
 
-	finalCommand, finalArgs := r.resolveInterpreter(command, args)
+	finalCommand, finalArgs := ResolveInterpreter(command, args)
 
 	if err := r.validateCommand(finalCommand); err != nil {
-		return fmt.Errorf("invalid resolved command: %w", err)
+		return nil, fmt.Errorf("invalid resolved command: %w", err)
+	}
+
+	// re-checked before every start in case a binary changes mid-scan
+	if verifier := tools.BinaryVerifierFromContext(ctx); verifier != nil {
+		if err := verifier.Verify(command); err != nil {
+			return nil, err
+		}
+		if finalCommand != command {
+			if err := verifier.Verify(finalCommand); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if nice, ok := tools.NiceFromContext(ctx); ok {
+		finalCommand, finalArgs = niceCommand(nice, finalCommand, finalArgs)
 	}
 
-	r.logger.WithFields(logger.Fields{
+	level := logrus.InfoLevel
+	if quietLogging(ctx) {
+		level = logrus.DebugLevel
+	}
+	r.log(ctx).WithFields(logrus.Fields{
 		"command": finalCommand,
 		"args":    finalArgs,
-	}).Info("Executing command")
+	}).Log(level, "Executing command")
 
 	cmd := exec.CommandContext(ctx, finalCommand, finalArgs...)
 
-	if workDir := tools.GetWorkingDirFromContext(ctx); workDir != "" {
-		cmd.Dir = workDir
-		r.logger.WithFields(logger.Fields{
-			"working_dir": workDir,
+	if dir != "" {
+		cmd.Dir = dir
+		r.log(ctx).WithFields(logrus.Fields{
+			"working_dir": dir,
 		}).Debug("Setting command working directory")
 	}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
-
-	stdoutStr := stdout.String()
-	stderrStr := stderr.String()
-
-	if err != nil {
-		if stderr.Len() > 0 {
-			r.logger.WithFields(logger.Fields{
-				"stderr": stderrStr,
-			}).Error("Command stderr output")
-		}
-		if stdout.Len() > 0 {
-			r.logger.WithFields(logger.Fields{
-				"stdout": stdoutStr,
-			}).Info("Command stdout output")
-		}
+	if env := tools.EnvironmentFromContext(ctx); len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 
-		errorMsg := fmt.Sprintf("execution failed: %v", err)
-		if stderr.Len() > 0 {
-			errorMsg = fmt.Sprintf("%s\nstderr: %s", errorMsg, stderrStr)
+	if cred := tools.RunAsFromContext(ctx); cred != nil {
+		if err := setCredential(cmd, cred); err != nil {
+			return nil, err
 		}
-
-		r.logger.WithError(err).Error("Command execution failed")
-		return fmt.Errorf("%s", errorMsg)
+		r.log(ctx).WithFields(logrus.Fields{
+			"uid": cred.UID,
+			"gid": cred.GID,
+		}).Debug("Running command as another user")
 	}
+	return cmd, nil
+}
 
-	if stdout.Len() > 0 {
-		r.logger.WithFields(logger.Fields{
-			"stdout": stdoutStr,
-		}).Debug("Command stdout output")
+// Output runs a command like RunInDir and returns its stdout and stderr,
+// interleaved, even when it fails.
+func (r *SimpleRunner) Output(ctx context.Context, dir, command string, args []string) ([]byte, error) {
+	cmd, err := r.prepare(ctx, dir, command, args)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err = cmd.Start()
+	if err == nil {
+		untrack := tools.TrackProcess(ctx, cmd.Process)
+		err = cmd.Wait()
+		untrack()
+	}
+	return output.Bytes(), err
 }
 
 func (r *SimpleRunner) validateCommand(command string) error {
@@ -147,7 +255,10 @@ func (r *SimpleRunner) validateArgument(arg string) error {
 	return nil
 }
 
-func (r *SimpleRunner) resolveInterpreter(command string, args []string) (string, []string) {
+// ResolveInterpreter is the command and arguments a tool command runs
+// with: script files (.py, .js, .rb, .sh, .bat, .ps1) run through their
+// interpreter, anything else as is.
+func ResolveInterpreter(command string, args []string) (string, []string) {
 	if strings.Contains(command, ".") {
 		ext := filepath.Ext(command)
 
@@ -2,8 +2,15 @@ package runner_test
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	perrors "pipeliner/pkg/errors"
 	"pipeliner/pkg/runner"
 	"pipeliner/pkg/tools"
 )
@@ -93,3 +100,123 @@ func TestSimpleRunner_ImplementsInterface(t *testing.T) {
 		t.Fatalf("Failed to execute command through ReplacementCommandRunner: %v", err)
 	}
 }
+
+func TestSimpleRunner_Output(t *testing.T) {
+	simpleRunner := runner.NewSimpleRunner()
+	var _ tools.OutputCommandRunner = simpleRunner
+
+	output, err := simpleRunner.Output(context.Background(), "", "echo", []string{"reachable"})
+	if err != nil || string(output) != "reachable\n" {
+		t.Fatalf("Output = %q, %v", output, err)
+	}
+
+	// stderr is kept, and so is the output of a failed command
+	output, err = simpleRunner.Output(context.Background(), "", "ls", []string{"/pipeliner-does-not-exist"})
+	if err == nil || !strings.Contains(string(output), "pipeliner-does-not-exist") {
+		t.Fatalf("Output = %q, %v", output, err)
+	}
+
+	if _, err := simpleRunner.Output(context.Background(), "", "echo", []string{"a;b"}); err == nil {
+		t.Fatal("Output should sanitize arguments like Run")
+	}
+}
+
+func TestSimpleRunner_OutputLimitKillsTool(t *testing.T) {
+	interval := tools.OutputLimitInterval
+	tools.OutputLimitInterval = 10 * time.Millisecond
+	t.Cleanup(func() { tools.OutputLimitInterval = interval })
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "flood.sh")
+	if err := os.WriteFile(script, []byte("while :; do printf '%065536d' 0 >> flood.txt; done\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := tools.WithOutputLimit(context.Background(), tools.OutputLimit{Files: []string{"flood.txt"}, LimitMB: 1})
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	err := runner.NewSimpleRunner().RunInDir(ctx, dir, script, nil)
+	if !errors.Is(err, perrors.ErrOutputLimit) {
+		t.Fatalf("RunInDir() error = %v, want ErrOutputLimit", err)
+	}
+	if !strings.Contains(err.Error(), "flood.txt") || ctx.Err() != nil {
+		t.Fatalf("RunInDir() error = %v, ctx error = %v", err, ctx.Err())
+	}
+
+	// a command that stays under the limit is left alone
+	ctx = tools.WithOutputLimit(context.Background(), tools.OutputLimit{Files: []string{"flood.txt"}, LimitMB: 1})
+	if err := os.Remove(filepath.Join(dir, "flood.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.NewSimpleRunner().RunInDir(ctx, dir, "sleep", []string{"0.1"}); err != nil {
+		t.Fatalf("RunInDir() under the limit error = %v", err)
+	}
+}
+
+func TestSimpleRunner_ReportsStderrWarnings(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "warn.sh")
+	if err := os.WriteFile(script, []byte("echo 'found 3 results'\necho '[WRN] rate limited by provider' >&2\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var warnings []tools.ToolWarning
+	m, err := tools.NewWarningMatcher("warn", []string{`(?i)rate limited`}, false, func(w tools.ToolWarning) { warnings = append(warnings, w) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.NewSimpleRunner().RunInDir(tools.WithWarningMatcher(context.Background(), m), dir, script, nil); err != nil {
+		t.Fatalf("RunInDir() error = %v", err)
+	}
+	if len(warnings) != 1 || warnings[0].Line != "[WRN] rate limited by provider" {
+		t.Fatalf("warnings = %+v", warnings)
+	}
+}
+
+func TestSimpleRunner_StreamsOutputToCommandLogs(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "flood.sh")
+	// 8 MB of stdout, a line of stderr and a last line without a newline
+	body := "yes https://a.example.com:443 | head -n 300000\necho 'done' >&2\nprintf 'last'\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := tools.WithToolName(context.Background(), "flood")
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	if err := runner.NewSimpleRunner().RunInDir(ctx, dir, script, nil); err != nil {
+		t.Fatalf("RunInDir() error = %v", err)
+	}
+	runtime.ReadMemStats(&after)
+
+	stdoutLog, stderrLog := tools.CommandLogFiles("flood")
+	stdout, err := os.ReadFile(filepath.Join(dir, stdoutLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Repeat("https://a.example.com:443\n", 300000) + "last"
+	if string(stdout) != want {
+		t.Fatalf("stdout log has %d bytes, want %d", len(stdout), len(want))
+	}
+	if stderr, err := os.ReadFile(filepath.Join(dir, stderrLog)); err != nil || string(stderr) != "done\n" {
+		t.Fatalf("stderr log = %q, %v", stderr, err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > uint64(len(want))/4 {
+		t.Fatalf("allocated %d bytes for %d bytes of output, want it streamed", allocated, len(want))
+	}
+
+	// a failed command's error keeps the end of its stderr
+	if err := os.WriteFile(script, []byte("yes 'retrying' | head -n 5000 >&2\necho 'fatal: no targets' >&2\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	err = runner.NewSimpleRunner().RunInDir(ctx, dir, script, nil)
+	if err == nil || !strings.HasSuffix(err.Error(), "retrying\nfatal: no targets\n") || len(err.Error()) > 5000 {
+		t.Fatalf("RunInDir() error = %q", err)
+	}
+	if stderr, _ := os.ReadFile(filepath.Join(dir, stderrLog)); !strings.HasSuffix(string(stderr), "fatal: no targets\n") || len(stderr) < 40000 {
+		t.Fatalf("stderr log of the failed run has %d bytes", len(stderr))
+	}
+}
@@ -0,0 +1,165 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pipeliner/pkg/tools"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxStreamLine is the longest line a stream passes on whole; longer
+	// ones are split.
+	maxStreamLine = 64 * 1024
+	// maxStreamTail is how much of the end of a stream is kept for the
+	// error and log lines of a failed command.
+	maxStreamTail = 4 * 1024
+)
+
+// outputStream takes one of a command's pipes line by line: it appends each
+// line to the tool's command log, if the command runs for a tool, tees it
+// to the logger at debug level and keeps the last maxStreamTail bytes.
+// Nothing else of the output is held in memory.
+type outputStream struct {
+	name     string
+	path     string
+	file     *os.File
+	writeErr error
+	onLine   func(line []byte)
+
+	partial []byte
+	last    []byte
+}
+
+// openStreams opens the stdout and stderr streams of a command run in dir
+// with ctx.
+func (r *SimpleRunner) openStreams(ctx context.Context, dir string) (stdout, stderr *outputStream, err error) {
+	stdout = &outputStream{name: "stdout"}
+	stderr = &outputStream{name: "stderr"}
+	if tool := tools.ToolNameFromContext(ctx); tool != "" {
+		stdoutLog, stderrLog := tools.CommandLogFiles(tool)
+		if err := stdout.open(filepath.Join(dir, stdoutLog)); err != nil {
+			return nil, nil, err
+		}
+		if err := stderr.open(filepath.Join(dir, stderrLog)); err != nil {
+			stdout.close()
+			return nil, nil, err
+		}
+	}
+
+	if r.logger.IsLevelEnabled(logrus.DebugLevel) {
+		for _, s := range []*outputStream{stdout, stderr} {
+			entry := r.log(ctx).WithField("stream", s.name)
+			s.onLine = func(line []byte) { entry.Debug(string(bytes.TrimRight(line, "\r\n"))) }
+		}
+	}
+	if m := tools.WarningMatcherFromContext(ctx); m != nil {
+		tee := stderr.onLine
+		stderr.onLine = func(line []byte) {
+			if tee != nil {
+				tee(line)
+			}
+			m.ReportLine(string(line))
+		}
+	}
+	return stdout, stderr, nil
+}
+
+// open appends to the log at path, which the values of a replacement run
+// share; each line goes out in one write so theirs do not interleave.
+func (s *outputStream) open(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open command log: %w", err)
+	}
+	s.path, s.file = path, file
+	return nil
+}
+
+func (s *outputStream) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			s.partial = append(s.partial, p...)
+			if len(s.partial) >= maxStreamLine {
+				s.line(s.partial)
+				s.partial = s.partial[:0]
+			}
+			break
+		}
+		line := p[:i+1]
+		if len(s.partial) > 0 {
+			s.partial = append(s.partial, line...)
+			line = s.partial
+		}
+		s.line(line)
+		s.partial = s.partial[:0]
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+func (s *outputStream) line(line []byte) {
+	if s.file != nil && s.writeErr == nil {
+		_, s.writeErr = s.file.Write(line)
+	}
+	if s.onLine != nil {
+		s.onLine(line)
+	}
+	s.last = append(s.last, line...)
+	if len(s.last) > 2*maxStreamTail {
+		s.last = append(s.last[:0], s.last[len(s.last)-maxStreamTail:]...)
+	}
+}
+
+// flush passes on a last line without a newline, once the command is done
+// writing.
+func (s *outputStream) flush() {
+	if len(s.partial) > 0 {
+		s.line(s.partial)
+		s.partial = s.partial[:0]
+	}
+}
+
+// tail is the end of the stream, from the first whole line in its last
+// maxStreamTail bytes.
+func (s *outputStream) tail() string {
+	tail := s.last
+	if len(tail) > maxStreamTail {
+		tail = tail[len(tail)-maxStreamTail:]
+		if i := bytes.IndexByte(tail, '\n'); i >= 0 {
+			tail = tail[i+1:]
+		}
+	}
+	return string(tail)
+}
+
+// close closes the log and returns the first error writing it.
+func (s *outputStream) close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	if s.writeErr != nil {
+		err = s.writeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write command log %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// fields are the log fields of a failed command's stream: its tail and the
+// log holding all of it.
+func (s *outputStream) fields() logrus.Fields {
+	fields := logrus.Fields{s.name: s.tail()}
+	if s.path != "" {
+		fields["log"] = s.path
+	}
+	return fields
+}
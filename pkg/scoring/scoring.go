@@ -0,0 +1,202 @@
+// Package scoring adjusts the severity of findings by ordered rules, e.g. to
+// raise everything found on payment hosts or quiet a noisy template tag.
+package scoring
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severities are the severity levels from lowest to highest.
+var Severities = []string{"info", "low", "medium", "high", "critical"}
+
+// Rule changes the severity of the findings it matches. A rule matches a
+// finding when all of its set conditions do; a rule without conditions
+// matches every finding.
+type Rule struct {
+	// Host is a glob of the finding's host, e.g. "*.pay.example.com"; *
+	// matches any run of characters, dots included.
+	Host     string `yaml:"host,omitempty" mapstructure:"host"`
+	Tool     string `yaml:"tool,omitempty" mapstructure:"tool"`
+	Category string `yaml:"category,omitempty" mapstructure:"category"`
+	// Tags must all be among the finding's template tags.
+	Tags []string `yaml:"tags,omitempty" mapstructure:"tags"`
+
+	// Severity replaces the severity; Adjust then moves it up or down that
+	// many levels, staying within info and critical.
+	Severity string   `yaml:"severity,omitempty" mapstructure:"severity"`
+	Adjust   int      `yaml:"adjust,omitempty" mapstructure:"adjust"`
+	Labels   []string `yaml:"labels,omitempty" mapstructure:"labels"`
+	// Stop skips the rules after this one when it matches.
+	Stop bool `yaml:"stop,omitempty" mapstructure:"stop"`
+}
+
+func (r Rule) Validate() error {
+	if r.Severity != "" && severityLevel(r.Severity) < 0 {
+		return fmt.Errorf("unknown severity %q, expected one of %s", r.Severity, strings.Join(Severities, ", "))
+	}
+	if r.Severity == "" && r.Adjust == 0 && len(r.Labels) == 0 {
+		return fmt.Errorf("rule sets no severity, adjust or labels")
+	}
+	return nil
+}
+
+// Subject is what rules match a finding on.
+type Subject struct {
+	Host     string
+	Tool     string
+	Category string
+	Tags     []string
+}
+
+// Result is a finding's severity after the rules, and the labels they added.
+type Result struct {
+	Severity string
+	Labels   []string
+}
+
+// Policy is a compiled, ordered list of rules. The nil Policy leaves every
+// severity as it is.
+type Policy struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	Rule
+	host *regexp.Regexp
+}
+
+// Compile validates rules and compiles their host globs.
+func Compile(rules []Rule) (*Policy, error) {
+	return (*Policy)(nil).With(rules)
+}
+
+// With returns a policy that applies rules after p's own.
+func (p *Policy) With(rules []Rule) (*Policy, error) {
+	next := &Policy{}
+	if p != nil {
+		next.rules = slices.Clone(p.rules)
+	}
+	for i, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("severity rule %d: %w", i+1, err)
+		}
+		compiled := compiledRule{Rule: rule}
+		if rule.Host != "" {
+			compiled.host = compileGlob(rule.Host)
+		}
+		next.rules = append(next.rules, compiled)
+	}
+	return next, nil
+}
+
+// Len is the number of rules in the policy.
+func (p *Policy) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.rules)
+}
+
+// Apply runs the rules in order over a finding of the given severity. Every
+// matching rule applies, so a later rule overrides the severity an earlier
+// one set, until a matching rule with stop. A severity outside Severities
+// is only changed by a rule that sets one.
+func (p *Policy) Apply(subject Subject, severity string) Result {
+	result := Result{Severity: severity}
+	if p == nil {
+		return result
+	}
+	for _, rule := range p.rules {
+		if !rule.matches(subject) {
+			continue
+		}
+		if rule.Severity != "" {
+			result.Severity = strings.ToLower(rule.Severity)
+		}
+		if level := severityLevel(result.Severity); rule.Adjust != 0 && level >= 0 {
+			level = min(max(level+rule.Adjust, 0), len(Severities)-1)
+			result.Severity = Severities[level]
+		}
+		for _, label := range rule.Labels {
+			if !slices.Contains(result.Labels, label) {
+				result.Labels = append(result.Labels, label)
+			}
+		}
+		if rule.Stop {
+			break
+		}
+	}
+	return result
+}
+
+func (r compiledRule) matches(s Subject) bool {
+	if r.host != nil && !r.host.MatchString(hostOf(s.Host)) {
+		return false
+	}
+	if r.Tool != "" && !strings.EqualFold(r.Tool, s.Tool) {
+		return false
+	}
+	if r.Category != "" && !strings.EqualFold(r.Category, s.Category) {
+		return false
+	}
+	for _, tag := range r.Tags {
+		if !slices.ContainsFunc(s.Tags, func(t string) bool { return strings.EqualFold(t, tag) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// compileGlob turns a host glob into an anchored, case-insensitive regexp.
+func compileGlob(glob string) *regexp.Regexp {
+	parts := strings.Split(strings.ToLower(glob), "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+// hostOf strips the scheme, port and path from a host or URL.
+func hostOf(value string) string {
+	if _, rest, ok := strings.Cut(value, "://"); ok {
+		value = rest
+	}
+	if i := strings.IndexAny(value, "/?#"); i >= 0 {
+		value = value[:i]
+	}
+	if strings.HasPrefix(value, "[") {
+		value, _, _ = strings.Cut(value[1:], "]")
+	} else if strings.Count(value, ":") == 1 {
+		value, _, _ = strings.Cut(value, ":")
+	}
+	return strings.ToLower(value)
+}
+
+func severityLevel(severity string) int {
+	return slices.Index(Severities, strings.ToLower(severity))
+}
+
+// LoadFile reads the severity_rules of a YAML file, in the same form as a
+// module's.
+func LoadFile(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Rules []Rule `yaml:"severity_rules"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if _, err := Compile(file.Rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file.Rules, nil
+}
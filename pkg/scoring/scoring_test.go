@@ -0,0 +1,180 @@
+package scoring_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"pipeliner/pkg/scoring"
+)
+
+func TestPolicy_Apply(t *testing.T) {
+	nuclei := scoring.Subject{Host: "https://api.pay.example.com:8443/login", Tool: "nuclei", Tags: []string{"exposure", "Config"}}
+	ffuf := scoring.Subject{Host: "admin.example.com", Tool: "ffuf", Category: "Source Code"}
+
+	tests := []struct {
+		name       string
+		rules      []scoring.Rule
+		subject    scoring.Subject
+		severity   string
+		want       string
+		wantLabels []string
+	}{
+		{
+			name:     "no rules",
+			subject:  nuclei,
+			severity: "medium",
+			want:     "medium",
+		},
+		{
+			name:     "host glob sets severity",
+			rules:    []scoring.Rule{{Host: "*.PAY.example.com", Severity: "critical"}},
+			subject:  nuclei,
+			severity: "low",
+			want:     "critical",
+		},
+		{
+			name:     "host glob is anchored",
+			rules:    []scoring.Rule{{Host: "pay.example.com", Severity: "critical"}},
+			subject:  nuclei,
+			severity: "low",
+			want:     "low",
+		},
+		{
+			name:       "all tags must match",
+			rules:      []scoring.Rule{{Tags: []string{"config", "exposure"}, Adjust: -1, Labels: []string{"noisy"}}, {Tags: []string{"config", "cve"}, Severity: "critical"}},
+			subject:    nuclei,
+			severity:   "high",
+			want:       "medium",
+			wantLabels: []string{"noisy"},
+		},
+		{
+			name:     "tool and category",
+			rules:    []scoring.Rule{{Tool: "nuclei", Severity: "info"}, {Tool: "FFUF", Category: "source code", Severity: "critical"}},
+			subject:  ffuf,
+			severity: "high",
+			want:     "critical",
+		},
+		{
+			name:     "adjust stays within the levels",
+			rules:    []scoring.Rule{{Adjust: 3}},
+			subject:  nuclei,
+			severity: "high",
+			want:     "critical",
+		},
+		{
+			name:     "adjust leaves unknown severities",
+			rules:    []scoring.Rule{{Adjust: -1}},
+			subject:  nuclei,
+			severity: "unknown",
+			want:     "unknown",
+		},
+		{
+			name: "later rules override earlier ones",
+			rules: []scoring.Rule{
+				{Host: "*.example.com", Severity: "high", Labels: []string{"in-scope"}},
+				{Tags: []string{"exposure"}, Severity: "low", Labels: []string{"exposure", "in-scope"}},
+			},
+			subject:    nuclei,
+			severity:   "medium",
+			want:       "low",
+			wantLabels: []string{"in-scope", "exposure"},
+		},
+		{
+			name: "stop ends evaluation",
+			rules: []scoring.Rule{
+				{Host: "*.pay.example.com", Severity: "critical", Labels: []string{"payments"}, Stop: true},
+				{Tags: []string{"exposure"}, Severity: "low"},
+			},
+			subject:    nuclei,
+			severity:   "medium",
+			want:       "critical",
+			wantLabels: []string{"payments"},
+		},
+		{
+			name:     "set then adjust",
+			rules:    []scoring.Rule{{Tool: "nuclei", Severity: "medium", Adjust: 1}},
+			subject:  nuclei,
+			severity: "info",
+			want:     "high",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := scoring.Compile(tt.rules)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			got := policy.Apply(tt.subject, tt.severity)
+			if got.Severity != tt.want {
+				t.Errorf("Severity = %q, want %q", got.Severity, tt.want)
+			}
+			if !slices.Equal(got.Labels, tt.wantLabels) {
+				t.Errorf("Labels = %v, want %v", got.Labels, tt.wantLabels)
+			}
+		})
+	}
+}
+
+func TestPolicy_WithAppliesModuleRulesAfterGlobal(t *testing.T) {
+	global, err := scoring.Compile([]scoring.Rule{{Tool: "nuclei", Severity: "high"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	module, err := global.With([]scoring.Rule{{Host: "staging.example.com", Adjust: -2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject := scoring.Subject{Host: "staging.example.com", Tool: "nuclei"}
+	if got := module.Apply(subject, "info").Severity; got != "low" {
+		t.Errorf("module policy severity = %q, want low", got)
+	}
+	if got := global.Apply(subject, "info").Severity; got != "high" {
+		t.Errorf("global policy severity = %q, want high", got)
+	}
+	if global.Len() != 1 || module.Len() != 2 {
+		t.Errorf("Len() = %d and %d, want 1 and 2", global.Len(), module.Len())
+	}
+
+	var none *scoring.Policy
+	if got := none.Apply(subject, "medium").Severity; got != "medium" {
+		t.Errorf("nil policy severity = %q, want medium", got)
+	}
+}
+
+func TestCompile_RejectsInvalidRules(t *testing.T) {
+	tests := map[string]scoring.Rule{
+		"unknown severity": {Tool: "nuclei", Severity: "severe"},
+		"no effect":        {Tool: "nuclei"},
+	}
+	for name, rule := range tests {
+		if _, err := scoring.Compile([]scoring.Rule{{Adjust: 1}, rule}); err == nil || !strings.Contains(err.Error(), "severity rule 2") {
+			t.Errorf("%s: Compile() error = %v, want an error for rule 2", name, err)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	data := "severity_rules:\n  - host: \"*.internal.example.com\"\n    adjust: -1\n    labels: [internal]\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := scoring.LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if len(rules) != 1 || rules[0].Host != "*.internal.example.com" || rules[0].Adjust != -1 {
+		t.Errorf("LoadFile() = %+v", rules)
+	}
+
+	if err := os.WriteFile(path, []byte("severity_rules:\n  - severity: urgent\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scoring.LoadFile(path); err == nil {
+		t.Error("LoadFile() accepted an unknown severity")
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"pipeliner/pkg/queue"
 )
 
 // MockCommandRunner implements tools.CommandRunner for testing
@@ -213,3 +215,85 @@ func AssertEquals(t *testing.T, expected, actual interface{}) {
 		t.Fatalf("Expected %v, got %v", expected, actual)
 	}
 }
+
+// ResetGlobalQueueForTests replaces the global scan queue with a fresh one
+// of the given size and restores an uninitialized queue when the test ends.
+func ResetGlobalQueueForTests(t *testing.T, maxConcurrent int) *queue.EngineQueue {
+	t.Helper()
+	q := queue.New(maxConcurrent)
+	queue.SetGlobal(q)
+	t.Cleanup(func() { queue.SetGlobal(nil) })
+	return q
+}
+
+// FakeClock is a manually advanced clock for code that takes a now func.
+// After returns channels that fire once Advance moves past their deadline.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeTimer
+}
+
+type fakeTimer struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeTimer{deadline: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Waiters reports how many After channels have not fired yet.
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// WaitForWaiters blocks until n After calls are pending, so a test can
+// advance the clock only once the code under test is waiting on it.
+func (c *FakeClock) WaitForWaiters(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Waiters() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d clock waiters", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.deadline.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
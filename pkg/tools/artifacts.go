@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Artifact is an output file a tool recorded in its manifest.
+type Artifact struct {
+	Tool  string `json:"tool"`
+	Stage Stage  `json:"stage"`
+	// Value is the replacement value the file was written for, empty for
+	// tools that run once.
+	Value string `json:"value,omitempty"`
+	// Name is the file's path relative to the scan directory; pass it to
+	// Artifacts.Open.
+	Name string `json:"name"`
+}
+
+// Artifacts lists and opens the tool outputs in a scan directory, as
+// recorded in the tools' output manifests. Outputs that were not written or
+// live outside the scan directory are left out.
+type Artifacts struct {
+	dir string
+}
+
+func NewArtifacts(dir string) *Artifacts {
+	return &Artifacts{dir: dir}
+}
+
+// Artifacts is the accessor for the outputs of the tools that ran before
+// the hook.
+func (h HookContext) Artifacts() *Artifacts {
+	return NewArtifacts(h.OutputDir)
+}
+
+// ListByTool returns the outputs of one tool, or none if it has no manifest.
+func (a *Artifacts) ListByTool(name string) ([]Artifact, error) {
+	manifest, err := ReadOutputManifest(filepath.Join(a.dir, OutputManifestFile(name)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a.artifacts(manifest), nil
+}
+
+// List returns the outputs of every tool.
+func (a *Artifacts) List() ([]Artifact, error) {
+	return a.list(func(*OutputManifest) bool { return true })
+}
+
+// ListByStage returns the outputs of every tool in stage.
+func (a *Artifacts) ListByStage(stage Stage) ([]Artifact, error) {
+	return a.list(func(manifest *OutputManifest) bool { return manifest.Stage == stage })
+}
+
+func (a *Artifacts) list(include func(*OutputManifest) bool) ([]Artifact, error) {
+	paths, err := filepath.Glob(filepath.Join(a.dir, OutputManifestFile("*")))
+	if err != nil {
+		return nil, err
+	}
+	var artifacts []Artifact
+	for _, path := range paths {
+		manifest, err := ReadOutputManifest(path)
+		if err != nil {
+			return nil, err
+		}
+		if include(manifest) {
+			artifacts = append(artifacts, a.artifacts(manifest)...)
+		}
+	}
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].Name < artifacts[j].Name })
+	return artifacts, nil
+}
+
+// Open opens an artifact by its Name. Names that are absolute or climb out
+// of the scan directory are refused.
+func (a *Artifacts) Open(name string) (*os.File, error) {
+	if !filepath.IsLocal(name) {
+		return nil, fmt.Errorf("artifact %q is outside the scan directory", name)
+	}
+	return os.Open(filepath.Join(a.dir, name))
+}
+
+func (a *Artifacts) artifacts(manifest *OutputManifest) []Artifact {
+	var artifacts []Artifact
+	add := func(value, file string) {
+		name, ok := a.localName(file)
+		if !ok {
+			return
+		}
+		if _, err := os.Stat(filepath.Join(a.dir, name)); err != nil {
+			return
+		}
+		artifacts = append(artifacts, Artifact{Tool: manifest.Tool, Stage: manifest.Stage, Value: value, Name: name})
+	}
+	for _, file := range manifest.Files {
+		add("", file)
+	}
+	for value, file := range manifest.Outputs {
+		add(value, file)
+	}
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].Name < artifacts[j].Name })
+	return artifacts
+}
+
+// localName turns a manifest path into one relative to the scan directory.
+func (a *Artifacts) localName(file string) (string, bool) {
+	if filepath.IsAbs(file) {
+		dir, err := filepath.Abs(a.dir)
+		if err != nil {
+			return "", false
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return "", false
+		}
+		file = rel
+	}
+	file = filepath.Clean(file)
+	if !filepath.IsLocal(file) {
+		return "", false
+	}
+	return file, true
+}
@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fileWritingRunner writes the file named by the -o argument, relative to
+// dir, unless it is in skip.
+type fileWritingRunner struct {
+	dir  string
+	skip map[string]bool
+}
+
+func (r *fileWritingRunner) Run(ctx context.Context, command string, args []string) error {
+	for i, arg := range args {
+		if arg == "-o" && i+1 < len(args) && !r.skip[command] {
+			return os.WriteFile(filepath.Join(r.dir, args[i+1]), []byte(command+"\n"), 0644)
+		}
+	}
+	return nil
+}
+
+func runArtifactTool(t *testing.T, dir, name, toolType, output string, runner CommandRunner) {
+	t.Helper()
+	config := ToolConfig{
+		Name:    name,
+		Command: name,
+		Type:    toolType,
+		Flags:   []FlagConfig{{Flag: "-o", Option: "Output", Default: output}},
+	}
+	tool := NewConfigurableTool(name, toolType, config, runner)
+	if err := tool.Run(context.Background(), &Options{WorkingDir: dir}); err != nil {
+		t.Fatalf("run %s: %v", name, err)
+	}
+}
+
+func TestArtifacts_ListsRecordedOutputs(t *testing.T) {
+	dir := t.TempDir()
+	runner := &fileWritingRunner{dir: dir, skip: map[string]bool{"chaos": true}}
+
+	runArtifactTool(t, dir, "subfinder", "domain_enum", "subfinder.txt", runner)
+	runArtifactTool(t, dir, "findomain", "domain_enum", "findomain.txt", runner)
+	runArtifactTool(t, dir, "chaos", "domain_enum", "chaos.txt", runner)
+	runArtifactTool(t, dir, "httpx", "recon", "httpx.txt", runner)
+
+	if _, err := os.Stat(filepath.Join(dir, OutputManifestFile("chaos"))); !os.IsNotExist(err) {
+		t.Fatalf("a tool that wrote nothing should record no manifest, stat err = %v", err)
+	}
+
+	artifacts := NewArtifacts(dir)
+	subdomains, err := artifacts.ListByStage(StageSubdomain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subdomains) != 2 || subdomains[0].Name != "findomain.txt" || subdomains[1].Name != "subfinder.txt" {
+		t.Fatalf("subdomain stage artifacts = %+v", subdomains)
+	}
+	if subdomains[1].Tool != "subfinder" || subdomains[1].Stage != StageSubdomain {
+		t.Fatalf("subfinder artifact = %+v", subdomains[1])
+	}
+
+	httpx, err := artifacts.ListByTool("httpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(httpx) != 1 || httpx[0].Name != "httpx.txt" {
+		t.Fatalf("httpx artifacts = %+v", httpx)
+	}
+	if missing, err := artifacts.ListByTool("nuclei"); err != nil || missing != nil {
+		t.Fatalf("ListByTool(nuclei) = %v, %v", missing, err)
+	}
+
+	f, err := artifacts.Open(httpx[0].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
+
+func TestArtifacts_StayInsideScanDir(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "scan")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(root, "secret.txt")
+	if err := os.WriteFile(outside, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ffuf_a.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := &OutputManifest{
+		Tool:  "ffuf",
+		Stage: StageRecon,
+		Outputs: map[string]string{
+			"https://a.example.com": filepath.Join(dir, "ffuf_a.json"),
+			"https://b.example.com": "../secret.txt",
+			"https://c.example.com": outside,
+		},
+	}
+	if err := WriteOutputManifest(filepath.Join(dir, OutputManifestFile("ffuf")), manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	artifacts := NewArtifacts(dir)
+	got, err := artifacts.ListByTool("ffuf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "ffuf_a.json" || got[0].Value != "https://a.example.com" {
+		t.Fatalf("ffuf artifacts = %+v", got)
+	}
+
+	for _, name := range []string{"../secret.txt", outside, "a/../../secret.txt"} {
+		if f, err := artifacts.Open(name); err == nil {
+			f.Close()
+			t.Errorf("Open(%q) should be refused", name)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"pipeliner/pkg/logger"
 	"runtime"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -20,8 +21,76 @@ func getOutputDir(options *Options) string {
 	return "."
 }
 
+// executePreHooks runs the tool's pre hooks in order before it starts. A
+// critical hook that fails stops the rest and returns an error the tool is
+// failed with; missing hooks and non-critical failures only warn.
+func executePreHooks(ctx context.Context, tool Tool, options *Options) error {
+	hookNames := tool.PreHooks()
+	if len(hookNames) == 0 || (options != nil && options.SkipHooks) {
+		return nil
+	}
+	if options == nil {
+		options = &Options{}
+	}
+	toolName := tool.Name()
+
+	log := options.Logger
+	if log == nil {
+		log = chainLogger
+	}
+	log.WithFields(logger.Fields{"hook_count": len(hookNames), "tool_name": toolName}).Info("Executing pre hooks for tool")
+
+	hookCtx := HookContext{
+		ctx:       ctx,
+		OutputDir: getOutputDir(options),
+		ToolName:  toolName,
+		Options:   options,
+	}
+	if configured, ok := tool.(interface{ Config() ToolConfig }); ok {
+		hookCtx.ToolConfig = configured.Config()
+	}
+
+	for _, hookName := range hookNames {
+		preHook, critical := resolvePreHook(hookName, options)
+		if preHook == nil {
+			log.WithFields(logger.Fields{"hook_name": hookName, "tool_name": toolName}).Warn("Pre hook not found for tool")
+			reportHookExecution(options, HookExecution{
+				Hook:   hookName,
+				Scope:  HookScopePreTool,
+				Target: toolName,
+				Status: HookStatusMissing,
+			})
+			continue
+		}
+
+		exec := HookExecution{Hook: hookName, Scope: HookScopePreTool, Target: toolName, StartedAt: time.Now()}
+		err := preHook.Execute(hookCtx)
+		exec.FinishedAt = time.Now()
+
+		if err != nil {
+			exec.Err = err
+			if !critical && ctx.Err() == nil {
+				exec.Status = HookStatusWarned
+				reportHookExecution(options, exec)
+				warnHookFailure(toolName, hookName, err, options)
+				continue
+			}
+
+			exec.Status = HookStatusFailed
+			reportHookExecution(options, exec)
+			log.WithFields(logger.Fields{"hook_name": hookName, "tool_name": toolName, "error": err}).Error("Pre hook failed, skipping tool")
+			return errors.NewToolError(toolName, fmt.Errorf("pre hook %s failed: %w", hookName, err))
+		}
+
+		exec.Status = HookStatusSucceeded
+		reportHookExecution(options, exec)
+		log.WithFields(logger.Fields{"hook_name": hookName, "tool_name": toolName}).Info("Pre hook completed successfully for tool")
+	}
+	return nil
+}
+
 func executePostHooks(ctx context.Context, toolName string, hookNames []string, options *Options) error {
-	if len(hookNames) == 0 {
+	if len(hookNames) == 0 || (options != nil && options.SkipHooks) {
 		return nil
 	}
 
@@ -35,62 +104,63 @@ func executePostHooks(ctx context.Context, toolName string, hookNames []string,
 	}
 
 	for _, hookName := range hookNames {
-		postHook := GetPostHook(hookName)
+		// GetPostHook also resolves legacy hooks through their wrapper
+		postHook, critical := resolvePostHook(hookName, options)
 		if postHook == nil {
-			legacyHook := GetHook(hookName)
-			if legacyHook == nil {
-				if options.Logger != nil {
-					options.Logger.Warn("Post hook not found for tool", logger.Fields{
-						"hook_name": hookName,
-						"tool_name": toolName,
-					})
-				} else {
-					chainLogger.Warnf("Post hook %s not found for tool %s", hookName, toolName)
-				}
-				continue
+			if options.Logger != nil {
+				options.Logger.Warn("Post hook not found for tool", logger.Fields{
+					"hook_name": hookName,
+					"tool_name": toolName,
+				})
+			} else {
+				chainLogger.Warnf("Post hook %s not found for tool %s", hookName, toolName)
 			}
+			reportHookExecution(options, HookExecution{
+				Hook:   hookName,
+				Scope:  HookScopeTool,
+				Target: toolName,
+				Status: HookStatusMissing,
+			})
+			continue
+		}
 
-			hookCtx := HookContext{
-				ctx:       ctx,
-				OutputDir: getOutputDir(options),
-				ToolName:  toolName,
-				Options:   options,
-			}
+		hookCtx := HookContext{
+			ctx:       ctx,
+			OutputDir: getOutputDir(options),
+			ToolName:  toolName,
+			Options:   options,
+		}
 
-			if err := legacyHook.PostHook(hookCtx); err != nil {
-				if options.Logger != nil {
-					options.Logger.Error("Post hook failed for tool", logger.Fields{
-						"hook_name": hookName,
-						"tool_name": toolName,
-						"error":     err,
-					})
-				} else {
-					chainLogger.Errorf("Post hook %s failed for tool %s: %v", hookName, toolName, err)
-				}
-				return errors.NewToolError(toolName, fmt.Errorf("post hook %s failed: %w", hookName, err))
-			}
-		} else {
-			hookCtx := HookContext{
-				ctx:       ctx,
-				OutputDir: getOutputDir(options),
-				ToolName:  toolName,
-				Options:   options,
+		exec := HookExecution{Hook: hookName, Scope: HookScopeTool, Target: toolName, StartedAt: time.Now()}
+		err := postHook.Execute(hookCtx)
+		exec.FinishedAt = time.Now()
+
+		if err != nil {
+			exec.Err = err
+			if !critical {
+				exec.Status = HookStatusWarned
+				reportHookExecution(options, exec)
+				warnHookFailure(toolName, hookName, err, options)
+				continue
 			}
 
-			if err := postHook.Execute(hookCtx); err != nil {
-				if options.Logger != nil {
-					options.Logger.Error("Post hook failed for tool", logger.Fields{
-						"hook_name": hookName,
-						"tool_name": toolName,
-						"error":     err,
-					})
-				} else {
-					chainLogger.Errorf("Post hook %s failed for tool %s: %v", hookName, toolName, err)
-				}
-				return errors.NewToolError(toolName, fmt.Errorf("post hook %s failed: %w", hookName, err))
+			exec.Status = HookStatusFailed
+			reportHookExecution(options, exec)
+			if options.Logger != nil {
+				options.Logger.Error("Post hook failed for tool", logger.Fields{
+					"hook_name": hookName,
+					"tool_name": toolName,
+					"error":     err,
+				})
+			} else {
+				chainLogger.Errorf("Post hook %s failed for tool %s: %v", hookName, toolName, err)
 			}
+			return errors.NewToolError(toolName, fmt.Errorf("post hook %s failed: %w", hookName, err))
 		}
 
+		exec.Status = HookStatusSucceeded
+		reportHookExecution(options, exec)
+
 		if options.Logger != nil {
 			options.Logger.Info("Post hook completed successfully for tool", logger.Fields{
 				"hook_name": hookName,
@@ -104,9 +174,30 @@ func executePostHooks(ctx context.Context, toolName string, hookNames []string,
 	return nil
 }
 
-func executeStageHooks(ctx context.Context, stage Stage, stageName string, options *Options) error {
-	hooks := GetStageHooks(stage)
-	if len(hooks) == 0 {
+// warnHookFailure logs a non-critical hook failure and hands it to the
+// options callback so it can be recorded on the scan.
+func warnHookFailure(toolName, hookName string, err error, options *Options) {
+	if options.Logger != nil {
+		options.Logger.Warn("Non-critical post hook failed, continuing", logger.Fields{
+			"hook_name": hookName,
+			"tool_name": toolName,
+			"error":     err,
+		})
+	} else {
+		chainLogger.Warnf("Non-critical post hook %s failed for tool %s, continuing: %v", hookName, toolName, err)
+	}
+
+	if options.OnHookWarning != nil {
+		options.OnHookWarning(HookWarning{Hook: hookName, Tool: toolName, Err: err})
+	}
+}
+
+func executeStageHooks(ctx context.Context, stage Stage, stageName string, startedAt time.Time, options *Options) error {
+	if options != nil && options.OnStageComplete != nil {
+		defer options.OnStageComplete(stage)
+	}
+	hooks := stageHooksFor(stage, options)
+	if len(hooks) == 0 || (options != nil && options.SkipHooks) {
 		return nil
 	}
 
@@ -120,17 +211,25 @@ func executeStageHooks(ctx context.Context, stage Stage, stageName string, optio
 		go func(h StageHook) {
 			defer wg.Done()
 			hookCtx := HookContext{
-				ctx:       ctx,
-				OutputDir: getOutputDir(options),
-				ToolName:  stageName,
-				Options:   options,
+				ctx:            ctx,
+				OutputDir:      getOutputDir(options),
+				ToolName:       stageName,
+				Options:        options,
+				StageStartedAt: startedAt,
 			}
-			if err := h.ExecuteForStage(hookCtx); err != nil {
+			exec := HookExecution{Hook: h.Name(), Scope: HookScopeStage, Target: stageName, StartedAt: time.Now()}
+			err := h.ExecuteForStage(hookCtx)
+			exec.FinishedAt = time.Now()
+
+			if err != nil {
+				exec.Status, exec.Err = HookStatusFailed, err
 				chainLogger.Errorf("Stage hook %s failed for stage %s: %v", h.Name(), stageName, err)
 				errChan <- fmt.Errorf("stage hook %s failed for stage %s: %w", h.Name(), stageName, err)
 			} else {
+				exec.Status = HookStatusSucceeded
 				chainLogger.Infof("Stage hook %s completed successfully for stage %s", h.Name(), stageName)
 			}
+			reportHookExecution(options, exec)
 		}(hook)
 	}
 
@@ -158,6 +257,52 @@ func findToolByName(tools []Tool, name string) Tool {
 	return nil
 }
 
+// toolTimeout is implemented by tools that carry their own run timeout.
+type toolTimeout interface {
+	Timeout() time.Duration
+}
+
+// runAttempt runs t bounded by its stage budget and its own timeout,
+// whichever ends first, and reports which of the two cut it short.
+func runAttempt(ctx context.Context, t Tool, options *Options, tracker *stageTracker) error {
+	stage, deadline := tracker.startTool(t)
+	byStage := !deadline.IsZero()
+
+	var timeout time.Duration
+	if tt, ok := t.(toolTimeout); ok && tt.Timeout() > 0 {
+		timeout = tt.Timeout()
+		if toolDeadline := tracker.now().Add(timeout); deadline.IsZero() || toolDeadline.Before(deadline) {
+			deadline = toolDeadline
+			byStage = false
+		}
+	}
+
+	if deadline.IsZero() {
+		return t.Run(ctx, options)
+	}
+
+	remaining := deadline.Sub(tracker.now())
+	if remaining <= 0 && byStage {
+		chainLogger.Warnf("Stage %s budget exhausted before tool %s started", stage, t.Name())
+		return errors.NewStageTimeoutError(string(stage), tracker.timeouts[stage], nil)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, remaining)
+	defer cancel()
+
+	err := t.Run(runCtx, options)
+	if err == nil || ctx.Err() != nil || runCtx.Err() != context.DeadlineExceeded {
+		return err
+	}
+
+	if byStage {
+		chainLogger.Warnf("Stage %s exceeded its %s budget while running tool %s", stage, tracker.timeouts[stage], t.Name())
+		return errors.NewStageTimeoutError(string(stage), tracker.timeouts[stage], err)
+	}
+	chainLogger.Warnf("Tool %s exceeded its %s timeout", t.Name(), timeout)
+	return errors.NewToolTimeoutError(t.Name(), timeout, err)
+}
+
 type ExecutionStrategy interface {
 	Run(ctx context.Context, tools []Tool, options *Options) error
 }
@@ -176,6 +321,16 @@ func (e *PartialExecutionError) Error() string {
 	return e.Message
 }
 
+// Unwrap exposes each tool's error so errors.Is can match sentinels such as
+// errors.ErrStageTimeout through the partial failure.
+func (e *PartialExecutionError) Unwrap() []error {
+	errs := make([]error, 0, len(e.FailedTools))
+	for _, f := range e.FailedTools {
+		errs = append(errs, f.Err)
+	}
+	return errs
+}
+
 func NewPartialExecutionError(failedTools []ToolError) *PartialExecutionError {
 	return &PartialExecutionError{
 		FailedTools: failedTools,
@@ -183,17 +338,40 @@ func NewPartialExecutionError(failedTools []ToolError) *PartialExecutionError {
 	}
 }
 
-type SequentialStrategy struct{}
+type SequentialStrategy struct {
+	StageTimeouts  map[Stage]time.Duration
+	StageCooldowns map[Stage]time.Duration
+	now            func() time.Time
+	after          func(time.Duration) <-chan time.Time
+}
 
 func (s *SequentialStrategy) Run(ctx context.Context, tools []Tool, options *Options) error {
 	chainLogger.Info("Executing tools sequentially")
 
-	tracker := newStageTracker(tools)
+	tracker := newStageTracker(tools, s.StageTimeouts, s.now)
 	successCount := 0
 	var failedTools []ToolError
 
+	// the previous tool's cooldown is served before the next one starts, so
+	// nothing is owed after the last tool
+	var owed time.Duration
+	var owedBy string
+
 	for _, tool := range tools {
-		err := tool.Run(ctx, options)
+		if err := cooldown(ctx, owedBy, owed, options, s.after, s.now); err != nil {
+			return err
+		}
+		if err := waitForResume(ctx, tool.Name(), options); err != nil {
+			return err
+		}
+
+		if err := executePreHooks(ctx, tool, options); err != nil {
+			failedTools = append(failedTools, ToolError{Tool: tool.Name(), Err: fmt.Errorf("pre hooks failed: %w", err)})
+			continue
+		}
+
+		err := runTool(ctx, tool, options, tracker, s.after)
+		owed, owedBy = cooldownAfter(tool), tool.Name()
 		if err != nil {
 			chainLogger.Errorf("Tool %s failed: %v", tool.Name(), err)
 			failedTools = append(failedTools, ToolError{Tool: tool.Name(), Err: err})
@@ -209,9 +387,10 @@ func (s *SequentialStrategy) Run(ctx context.Context, tools []Tool, options *Opt
 		completedStage := tracker.markCompleted(tool.Name())
 		if completedStage != "" {
 			chainLogger.Infof("Stage %s completed. Triggering stage hooks...", completedStage)
-			if err := executeStageHooks(ctx, completedStage, string(completedStage), options); err != nil {
+			if err := executeStageHooks(ctx, completedStage, string(completedStage), tracker.startedAt(completedStage), options); err != nil {
 				chainLogger.Errorf("Stage hooks failed for stage %s: %v", completedStage, err)
 			}
+			owed = cooldownFor(tool, completedStage, s.StageCooldowns)
 		}
 
 		successCount++
@@ -226,40 +405,71 @@ func (s *SequentialStrategy) Run(ctx context.Context, tools []Tool, options *Opt
 	return nil
 }
 
-type ConcurrentStrategy struct{}
+// ConcurrentStrategy starts every tool at once, or MaxConcurrency at a time
+// when set, so no tool waits on another and cooldowns do not apply.
+type ConcurrentStrategy struct {
+	StageTimeouts  map[Stage]time.Duration
+	MaxConcurrency int
+	now            func() time.Time
+	after          func(time.Duration) <-chan time.Time
+}
 
 func (s *ConcurrentStrategy) Run(ctx context.Context, tools []Tool, options *Options) error {
-	chainLogger.Info("Executing tools concurrently")
+	workers := len(tools)
+	if s.MaxConcurrency > 0 && s.MaxConcurrency < workers {
+		workers = s.MaxConcurrency
+	}
+	chainLogger.Infof("Executing tools concurrently, %d at a time", workers)
 
-	tracker := newStageTracker(tools)
+	tracker := newStageTracker(tools, s.StageTimeouts, s.now)
 	var wg sync.WaitGroup
 	// Create channels for results
 	errChan := make(chan ToolError, len(tools))
 	completedTools := make(chan Tool, len(tools))
 
+	queue := make(chan Tool, len(tools))
 	for _, tool := range tools {
-		wg.Add(1)
-		go func(t Tool) {
-			defer wg.Done()
-			select {
-			case <-ctx.Done():
-				errChan <- ToolError{Tool: t.Name(), Err: ctx.Err()}
-				return
-			default:
-			}
+		queue <- tool
+	}
+	close(queue)
 
-			if err := t.Run(ctx, options); err != nil {
-				errChan <- ToolError{Tool: t.Name(), Err: err}
-				return
-			}
+	run := func(t Tool) {
+		select {
+		case <-ctx.Done():
+			errChan <- ToolError{Tool: t.Name(), Err: ctx.Err()}
+			return
+		default:
+		}
 
-			select {
-			case <-ctx.Done():
-				errChan <- ToolError{Tool: t.Name(), Err: ctx.Err()}
-				return
-			case completedTools <- t:
+		if err := waitForResume(ctx, t.Name(), options); err != nil {
+			errChan <- ToolError{Tool: t.Name(), Err: err}
+			return
+		}
+		if err := executePreHooks(ctx, t, options); err != nil {
+			errChan <- ToolError{Tool: t.Name(), Err: fmt.Errorf("pre hooks failed: %w", err)}
+			return
+		}
+		if err := runTool(ctx, t, options, tracker, s.after); err != nil {
+			errChan <- ToolError{Tool: t.Name(), Err: err}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			errChan <- ToolError{Tool: t.Name(), Err: ctx.Err()}
+			return
+		case completedTools <- t:
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range queue {
+				run(t)
 			}
-		}(tool)
+		}()
 	}
 
 	go func() {
@@ -299,7 +509,7 @@ func (s *ConcurrentStrategy) Run(ctx context.Context, tools []Tool, options *Opt
 			completedStage := tracker.markCompleted(tool.Name())
 			if completedStage != "" {
 				chainLogger.Infof("Stage %s completed. Triggering stage hooks...", completedStage)
-				if err := executeStageHooks(ctx, completedStage, string(completedStage), options); err != nil {
+				if err := executeStageHooks(ctx, completedStage, string(completedStage), tracker.startedAt(completedStage), options); err != nil {
 					chainLogger.Errorf("Stage hooks failed for stage %s: %v", completedStage, err)
 				}
 			}
@@ -315,13 +525,24 @@ func (s *ConcurrentStrategy) Run(ctx context.Context, tools []Tool, options *Opt
 	return nil
 }
 
-type HybridStrategy struct{}
+type HybridStrategy struct {
+	StageTimeouts  map[Stage]time.Duration
+	StageCooldowns map[Stage]time.Duration
+	// MaxConcurrency, when set, replaces the one worker per CPU.
+	MaxConcurrency int
+	now            func() time.Time
+	after          func(time.Duration) <-chan time.Time
+}
 
 func (hybrid *HybridStrategy) Run(ctx context.Context, tools []Tool, options *Options) error {
 	chainLogger.Info("Executing tools in hybrid (DAG-based)")
 
 	// Build and validate the graph
-	g, err := newDepGraph(tools)
+	var satisfied []string
+	if options != nil {
+		satisfied = options.Satisfied
+	}
+	g, err := newDepGraph(tools, satisfied)
 	if err != nil {
 		return err
 	}
@@ -329,9 +550,12 @@ func (hybrid *HybridStrategy) Run(ctx context.Context, tools []Tool, options *Op
 		return err
 	}
 
-	tracker := newStageTracker(tools)
+	tracker := newStageTracker(tools, hybrid.StageTimeouts, hybrid.now)
 
 	workers := runtime.NumCPU()
+	if hybrid.MaxConcurrency > 0 {
+		workers = hybrid.MaxConcurrency
+	}
 	if workers < 1 {
 		workers = 1
 	}
@@ -339,15 +563,50 @@ func (hybrid *HybridStrategy) Run(ctx context.Context, tools []Tool, options *Op
 
 	ready := make(chan Tool, len(tools))
 	results := make(chan runResult, len(tools))
+	// dependents released once a cooldown ends; nil if it was interrupted
+	cooled := make(chan []Tool, len(tools))
 	errs := make([]ToolError, 0)
 	var wg sync.WaitGroup
 
 	workerCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	for _, t := range g.initialReady() {
+	now := hybrid.now
+	if now == nil {
+		now = time.Now
+	}
+	// every state change is published so callers can show what waits on what
+	publish := func() {
+		if options != nil && options.OnProgress != nil {
+			options.OnProgress(ProgressEvent{
+				Status:    ProgressDAGChanged,
+				Message:   "dependency graph changed",
+				Timestamp: now(),
+				DAG:       g.snapshot(now()),
+			})
+		}
+	}
+	enqueue := func(batch []Tool) error {
+		for _, t := range batch {
+			g.setState(t.Name(), DAGReady)
+		}
+		publish()
+		for _, t := range batch {
+			select {
+			case ready <- t:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+
+	initial := g.initialReady()
+	for _, t := range initial {
 		chainLogger.Infof("Initial ready: %s", t.Name())
-		ready <- t
+	}
+	if err := enqueue(initial); err != nil {
+		return err
 	}
 
 	for i := 0; i < workers; i++ {
@@ -367,8 +626,19 @@ func (hybrid *HybridStrategy) Run(ctx context.Context, tools []Tool, options *Op
 						return
 					}
 
+					if err := waitForResume(workerCtx, t.Name(), options); err != nil {
+						return
+					}
+
 					chainLogger.Infof("Worker %d executing tool %s", workerID, t.Name())
-					runErr := t.Run(workerCtx, options)
+					g.setState(t.Name(), DAGRunning)
+					publish()
+					runErr := executePreHooks(workerCtx, t, options)
+					if runErr != nil {
+						runErr = fmt.Errorf("pre hooks failed: %w", runErr)
+					} else {
+						runErr = runTool(workerCtx, t, options, tracker, hybrid.after)
+					}
 
 					select {
 					case results <- runResult{name: t.Name(), err: runErr}:
@@ -395,6 +665,11 @@ func (hybrid *HybridStrategy) Run(ctx context.Context, tools []Tool, options *Op
 		case <-ctx.Done():
 			return ctx.Err()
 
+		case released := <-cooled:
+			if err := enqueue(released); err != nil {
+				return err
+			}
+
 		case r := <-results:
 			doneCount++
 			success := (r.err == nil)
@@ -416,24 +691,41 @@ func (hybrid *HybridStrategy) Run(ctx context.Context, tools []Tool, options *Op
 			completedStage := tracker.markCompleted(r.name)
 			if completedStage != "" {
 				chainLogger.Infof("Stage %s completed. Triggering stage hooks...", completedStage)
-				if err := executeStageHooks(ctx, completedStage, string(completedStage), options); err != nil {
+				if err := executeStageHooks(ctx, completedStage, string(completedStage), tracker.startedAt(completedStage), options); err != nil {
 					chainLogger.Errorf("Stage hooks failed for stage %s: %v", completedStage, err)
 				}
 			}
 
 			newReady, skipped := g.onComplete(r.name, success)
+			if tool := findToolByName(tools, r.name); success && tool != nil && reportsEmptyOutput(tool) {
+				g.setState(r.name, DAGCompletedEmpty)
+			}
 			for _, s := range skipped {
 				doneCount++
 				errs = append(errs, ToolError{Tool: s, Err: fmt.Errorf("skipped due to failed dependency")})
 				chainLogger.Warnf("Tool %s skipped (failed dependency)", s)
 			}
-			for _, t := range newReady {
-				select {
-				case ready <- t:
-				case <-ctx.Done():
-					return ctx.Err()
+
+			// the cooldown holds back only this tool's dependents; other
+			// results keep being scheduled while it runs
+			if tool := findToolByName(tools, r.name); tool != nil && len(newReady) > 0 {
+				if wait := cooldownFor(tool, completedStage, hybrid.StageCooldowns); wait > 0 {
+					for _, t := range newReady {
+						g.setState(t.Name(), DAGCooldown)
+					}
+					publish()
+					go func(name string, released []Tool) {
+						if err := cooldown(ctx, name, wait, options, hybrid.after, hybrid.now); err != nil {
+							released = nil
+						}
+						cooled <- released
+					}(r.name, newReady)
+					continue
 				}
 			}
+			if err := enqueue(newReady); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -2,9 +2,12 @@ package tools
 
 import (
 	"context"
+	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"pipeliner/pkg/scoring"
 	"pipeliner/pkg/testutil"
 )
 
@@ -13,6 +16,8 @@ type MockTool struct {
 	name         string
 	toolType     string
 	dependencies []string
+	preHooks     []string
+	postHooks    []string
 	runFunc      func(ctx context.Context, options *Options) error
 	runCount     int
 }
@@ -29,7 +34,8 @@ func NewMockTool(name, toolType string, dependencies []string) *MockTool {
 func (m *MockTool) Name() string        { return m.name }
 func (m *MockTool) Type() string        { return m.toolType }
 func (m *MockTool) DependsOn() []string { return m.dependencies }
-func (m *MockTool) PostHooks() []string { return []string{} } // No hooks for mock tools
+func (m *MockTool) PreHooks() []string  { return m.preHooks }
+func (m *MockTool) PostHooks() []string { return m.postHooks }
 
 func (m *MockTool) Run(ctx context.Context, options *Options) error {
 	m.runCount++
@@ -108,6 +114,44 @@ func TestConcurrentStrategy_Run(t *testing.T) {
 	}
 }
 
+func TestMaxConcurrency(t *testing.T) {
+	strategies := map[string]func() ExecutionStrategy{
+		"concurrent": func() ExecutionStrategy { return &ConcurrentStrategy{MaxConcurrency: 2} },
+		"hybrid":     func() ExecutionStrategy { return &HybridStrategy{MaxConcurrency: 2} },
+	}
+	for name, strategy := range strategies {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+			defer cancel()
+
+			var running, peak atomic.Int32
+			var tools []Tool
+			for i := 0; i < 6; i++ {
+				tool := NewMockTool(fmt.Sprintf("tool%d", i), "test", nil)
+				tool.SetRunFunc(func(context.Context, *Options) error {
+					now := running.Add(1)
+					defer running.Add(-1)
+					for {
+						old := peak.Load()
+						if now <= old || peak.CompareAndSwap(old, now) {
+							break
+						}
+					}
+					time.Sleep(20 * time.Millisecond)
+					return nil
+				})
+				tools = append(tools, tool)
+			}
+
+			testutil.AssertNoError(t, strategy().Run(ctx, tools, DefaultOptions()))
+			for _, tool := range tools {
+				testutil.AssertEquals(t, 1, tool.(*MockTool).GetRunCount())
+			}
+			testutil.AssertEquals(t, int32(2), peak.Load())
+		})
+	}
+}
+
 func TestHybridStrategy_RunWithDependencies(t *testing.T) {
 	ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
 	defer cancel()
@@ -175,6 +219,16 @@ func TestToolConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "non-numeric uid with gid",
+			config: ToolConfig{
+				Name:    "test-tool",
+				Command: "echo",
+				Type:    "test",
+				RunAs:   "scanner:1000",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -215,6 +269,39 @@ func TestChainConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "negative max concurrency",
+			config: ChainConfig{
+				ExecutionMode:  "concurrent",
+				MaxConcurrency: -1,
+				Tools: []ToolConfig{
+					{Name: "tool1", Command: "echo", Type: "test"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid severity rule",
+			config: ChainConfig{
+				ExecutionMode: "sequential",
+				SeverityRules: []scoring.Rule{{Tool: "nuclei", Severity: "urgent"}},
+				Tools: []ToolConfig{
+					{Name: "tool1", Command: "echo", Type: "test"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "estimate tool depends on full scan tool",
+			config: ChainConfig{
+				ExecutionMode: "sequential",
+				Tools: []ToolConfig{
+					{Name: "tool1", Command: "echo", Type: "test"},
+					{Name: "tool2", Command: "echo", Type: "test", Estimate: true, DependsOn: []string{"tool1"}},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "no tools",
 			config: ChainConfig{
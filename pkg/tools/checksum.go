@@ -0,0 +1,201 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"pipeliner/pkg/errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChecksumsFileEnv names an allowlist of pinned binaries shared by every
+// module. Module checksums override its entries.
+const ChecksumsFileEnv = "PIPELINER_CHECKSUMS_FILE"
+
+const binaryVerifierKey contextKey = "binary_verifier"
+
+// BinaryVerifier checks tool binaries against pinned sha256 digests.
+type BinaryVerifier struct {
+	pins map[string]string
+	// OnMismatch is called for every binary that fails the check.
+	OnMismatch func(err *errors.ChecksumMismatchError)
+}
+
+// NewBinaryVerifier pins commands, by name or path, to sha256 hex digests.
+func NewBinaryVerifier(pins map[string]string) *BinaryVerifier {
+	v := &BinaryVerifier{pins: make(map[string]string, len(pins))}
+	for command, sum := range pins {
+		v.pins[command] = strings.ToLower(sum)
+	}
+	return v
+}
+
+// Verify resolves command through PATH and compares the binary with its pin.
+// Commands without a pin pass.
+func (v *BinaryVerifier) Verify(command string) error {
+	if v == nil || len(v.pins) == 0 {
+		return nil
+	}
+
+	path, lookErr := exec.LookPath(command)
+	want := v.pinFor(command, path)
+	if want == "" {
+		return nil
+	}
+	if lookErr != nil {
+		return fmt.Errorf("security: %s is pinned but cannot be found: %w", command, lookErr)
+	}
+
+	got, err := HashBinary(path)
+	if err != nil {
+		return fmt.Errorf("security: cannot hash pinned binary %s: %w", path, err)
+	}
+	if got != want {
+		mismatch := &errors.ChecksumMismatchError{Command: command, Path: path, Expected: want, Actual: got}
+		if v.OnMismatch != nil {
+			v.OnMismatch(mismatch)
+		}
+		return mismatch
+	}
+	return nil
+}
+
+func (v *BinaryVerifier) pinFor(command, path string) string {
+	if sum, ok := v.pins[command]; ok {
+		return sum
+	}
+	if path == "" {
+		return ""
+	}
+	if sum, ok := v.pins[path]; ok {
+		return sum
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return v.pins[abs]
+	}
+	return ""
+}
+
+// WithBinaryVerifier makes runners check binaries with v before starting them.
+func WithBinaryVerifier(ctx context.Context, v *BinaryVerifier) context.Context {
+	return context.WithValue(ctx, binaryVerifierKey, v)
+}
+
+// BinaryVerifierFromContext is the verifier set with WithBinaryVerifier, or
+// nil.
+func BinaryVerifierFromContext(ctx context.Context) *BinaryVerifier {
+	v, _ := ctx.Value(binaryVerifierKey).(*BinaryVerifier)
+	return v
+}
+
+type cachedHash struct {
+	modTime time.Time
+	size    int64
+	sum     string
+}
+
+var (
+	hashCacheMu sync.Mutex
+	hashCache   = make(map[string]cachedHash)
+)
+
+// HashBinary returns the sha256 of the file at path. The result is reused
+// while the file keeps its size and modification time.
+func HashBinary(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	hashCacheMu.Lock()
+	cached, ok := hashCache[path]
+	hashCacheMu.Unlock()
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.sum, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	hashCacheMu.Lock()
+	hashCache[path] = cachedHash{modTime: info.ModTime(), size: info.Size(), sum: sum}
+	hashCacheMu.Unlock()
+	return sum, nil
+}
+
+// LoadChecksumFile reads an allowlist in sha256sum format, one
+// "<sha256>  <path>" per line. Blank lines and # comments are skipped.
+func LoadChecksumFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pins := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		sum, command, ok := strings.Cut(text, " ")
+		command = strings.TrimPrefix(strings.TrimSpace(command), "*")
+		if !ok || command == "" || !validSHA256(sum) {
+			return nil, fmt.Errorf("%s:%d: expected \"<sha256>  <path>\"", path, line)
+		}
+		pins[command] = strings.ToLower(sum)
+	}
+	return pins, scanner.Err()
+}
+
+// ChecksumAllowlist loads the file named by PIPELINER_CHECKSUMS_FILE, or
+// returns nil when it is not set.
+func ChecksumAllowlist() (map[string]string, error) {
+	path := os.Getenv(ChecksumsFileEnv)
+	if path == "" {
+		return nil, nil
+	}
+	return LoadChecksumFile(path)
+}
+
+// FormatChecksums renders pins in the format LoadChecksumFile reads.
+func FormatChecksums(pins map[string]string) []byte {
+	commands := make([]string, 0, len(pins))
+	for command := range pins {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+
+	var buf bytes.Buffer
+	for _, command := range commands {
+		fmt.Fprintf(&buf, "%s  %s\n", pins[command], command)
+	}
+	return buf.Bytes()
+}
+
+func validSHA256(sum string) bool {
+	if len(sum) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(sum)
+	return err == nil
+}
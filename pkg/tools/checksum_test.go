@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	perrors "pipeliner/pkg/errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeBinary(t *testing.T, path, content string) string {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0755))
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestBinaryVerifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scanner")
+	sum := writeBinary(t, path, "#!/bin/sh\necho v1\n")
+
+	assert.NoError(t, NewBinaryVerifier(map[string]string{path: sum}).Verify(path))
+	assert.NoError(t, NewBinaryVerifier(map[string]string{"other": sum}).Verify(path), "unpinned commands pass")
+
+	var reported *perrors.ChecksumMismatchError
+	v := NewBinaryVerifier(map[string]string{path: sum})
+	v.OnMismatch = func(err *perrors.ChecksumMismatchError) { reported = err }
+
+	// a new mtime makes the cached hash stale
+	writeBinary(t, path, "#!/bin/sh\necho v2\n")
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+
+	err := v.Verify(path)
+	require.True(t, errors.Is(err, perrors.ErrChecksumMismatch), "got %v", err)
+	require.NotNil(t, reported)
+	assert.Equal(t, sum, reported.Expected)
+	assert.Contains(t, err.Error(), "security")
+
+	err = NewBinaryVerifier(map[string]string{"no-such-tool-xyz": sum}).Verify("no-such-tool-xyz")
+	assert.Error(t, err, "pinned but missing binaries fail")
+}
+
+func TestChecksumFileRoundTrip(t *testing.T) {
+	pins := map[string]string{
+		"/usr/bin/nuclei": "aa" + hex.EncodeToString(make([]byte, 31)),
+		"subfinder":       "BB" + hex.EncodeToString(make([]byte, 31)),
+	}
+	path := filepath.Join(t.TempDir(), "checksums.txt")
+	data := append([]byte("# pinned tools\n\n"), FormatChecksums(pins)...)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+
+	loaded, err := LoadChecksumFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, pins["/usr/bin/nuclei"], loaded["/usr/bin/nuclei"])
+	assert.Equal(t, "bb"+hex.EncodeToString(make([]byte, 31)), loaded["subfinder"])
+
+	require.NoError(t, os.WriteFile(path, []byte("nothex  /usr/bin/nuclei\n"), 0644))
+	_, err = LoadChecksumFile(path)
+	assert.ErrorContains(t, err, ":1:")
+}
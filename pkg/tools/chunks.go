@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ChunkProgress is reported after each chunk of a replacement run with a
+// replace_chunk_size.
+type ChunkProgress struct {
+	Tool  string
+	Stage Stage
+	// Chunk counts from 1, across a resumed run's chunks too.
+	Chunk int
+	// Done is how many of Total values have run, failed ones included.
+	Done     int
+	Total    int
+	Failures int
+	// Notify is the tool's notify_chunks.
+	Notify bool
+}
+
+// ChunkCheckpoint is what a chunked replacement run writes after each
+// chunk, so an interrupted run continues after its last finished chunk.
+type ChunkCheckpoint struct {
+	Tool  string `json:"tool"`
+	Chunk int    `json:"chunk"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// ChunkCheckpointFile is the name of the checkpoint a chunked tool writes in
+// its working directory. A finished run removes it.
+func ChunkCheckpointFile(toolName string) string {
+	return fmt.Sprintf("%s_checkpoint.json", toolName)
+}
+
+func WriteChunkCheckpoint(path string, checkpoint *ChunkCheckpoint) error {
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode chunk checkpoint: %w", err)
+	}
+	// renamed into place so a run killed mid-write leaves the last one
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write chunk checkpoint %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write chunk checkpoint %s: %w", path, err)
+	}
+	return nil
+}
+
+func ReadChunkCheckpoint(path string) (*ChunkCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var checkpoint ChunkCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse chunk checkpoint %s: %w", path, err)
+	}
+	return &checkpoint, nil
+}
+
+// interruptedChunks reports whether dir holds the checkpoint of an
+// unfinished chunked run of the tool.
+func interruptedChunks(config ToolConfig, dir string) (string, bool) {
+	if config.ReplaceChunkSize <= 0 {
+		return "", false
+	}
+	file := ChunkCheckpointFile(config.Name)
+	checkpoint, err := ReadChunkCheckpoint(filepath.Join(dir, file))
+	if err != nil || checkpoint.Done >= checkpoint.Total {
+		return "", false
+	}
+	return file, true
+}
+
+// chunkSpec sets up the chunks of a replacement run: the checkpoint, which
+// a resumed scan or a retry continues from, and the report after each
+// chunk.
+func (t *ConfigurableTool) chunkSpec(ctx context.Context, spec *ReplacementSpec, options *Options) {
+	if t.config.ReplaceChunkSize <= 0 {
+		return
+	}
+	spec.ChunkSize = t.config.ReplaceChunkSize
+	spec.CheckpointPath = ChunkCheckpointFile(t.name)
+	if options == nil {
+		return
+	}
+	_, resumed := options.Resume[t.name]
+	spec.ResumeChunks = resumed || isRetry(ctx)
+	if options.OnChunkComplete != nil {
+		spec.OnChunk = func(progress ChunkProgress) {
+			progress.Notify = t.config.NotifyChunks
+			options.OnChunkComplete(progress)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// specRunner records the ReplacementSpec of a replacement run and reports
+// one chunk through it.
+type specRunner struct {
+	spec ReplacementSpec
+}
+
+func (r *specRunner) Run(ctx context.Context, command string, args []string) error { return nil }
+
+func (r *specRunner) RunWithReplacement(ctx context.Context, command string, args []string, replaceToken, replaceFromFile string) error {
+	return nil
+}
+
+func (r *specRunner) RunWithReplacementSpec(ctx context.Context, command string, args []string, spec ReplacementSpec) error {
+	r.spec = spec
+	if spec.OnChunk != nil {
+		spec.OnChunk(ChunkProgress{Tool: spec.Tool, Chunk: 1, Done: 500, Total: 1200})
+	}
+	return nil
+}
+
+func chunkedConfig() ToolConfig {
+	return ToolConfig{
+		Name:             "ffuf",
+		Command:          "ffuf",
+		Replace:          "{{URL}}",
+		ReplaceFrom:      []string{"urls.txt"},
+		Flags:            []FlagConfig{{Flag: "-u", Default: "{{URL}}/FUZZ"}},
+		ReplaceChunkSize: 500,
+		NotifyChunks:     true,
+	}
+}
+
+func TestChunks_ToolPassesChunkSpec(t *testing.T) {
+	for _, resumed := range []bool{false, true} {
+		runner := &specRunner{}
+		tool := NewConfigurableTool("ffuf", "fuzz", chunkedConfig(), runner)
+
+		var progress []ChunkProgress
+		options := &Options{WorkingDir: t.TempDir(), OnChunkComplete: func(p ChunkProgress) { progress = append(progress, p) }}
+		if resumed {
+			options.Resume = map[string]string{"ffuf": ChunkCheckpointFile("ffuf")}
+		}
+		if err := tool.Run(context.Background(), options); err != nil {
+			t.Fatal(err)
+		}
+
+		spec := runner.spec
+		if spec.ChunkSize != 500 || spec.CheckpointPath != "ffuf_checkpoint.json" || spec.ResumeChunks != resumed {
+			t.Fatalf("spec = %+v, resumed %v", spec, resumed)
+		}
+		if len(progress) != 1 || !progress[0].Notify || progress[0].Done != 500 {
+			t.Fatalf("progress = %+v, want the chunk with notify_chunks", progress)
+		}
+	}
+}
+
+func TestChunks_CheckpointMarksRunInterrupted(t *testing.T) {
+	dir := t.TempDir()
+	config := chunkedConfig()
+	if _, ok := InterruptedRun(config, dir); ok {
+		t.Fatal("no checkpoint, nothing to resume")
+	}
+
+	path := filepath.Join(dir, ChunkCheckpointFile("ffuf"))
+	if err := WriteChunkCheckpoint(path, &ChunkCheckpoint{Tool: "ffuf", Chunk: 2, Done: 1000, Total: 1200}); err != nil {
+		t.Fatal(err)
+	}
+	if file, ok := InterruptedRun(config, dir); !ok || file != "ffuf_checkpoint.json" {
+		t.Fatalf("InterruptedRun = %q, %v", file, ok)
+	}
+	config.ReplaceChunkSize = 0
+	if _, ok := InterruptedRun(config, dir); ok {
+		t.Fatal("a tool without chunks has no checkpoint to resume")
+	}
+
+	// a checkpoint with every value done is not an interrupted run
+	if err := WriteChunkCheckpoint(path, &ChunkCheckpoint{Tool: "ffuf", Chunk: 3, Done: 1200, Total: 1200}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := InterruptedRun(chunkedConfig(), dir); ok {
+		t.Fatal("finished checkpoint reported as interrupted")
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary checkpoint left behind: %v", err)
+	}
+}
+
+func TestChunks_Validate(t *testing.T) {
+	for _, tt := range []struct {
+		mutate func(*ToolConfig)
+		want   string
+	}{
+		{func(tc *ToolConfig) { tc.ReplaceChunkSize = -1 }, "replace_chunk_size must not be negative"},
+		{func(tc *ToolConfig) { tc.Replace, tc.ReplaceFrom = "", nil }, "replace_chunk_size requires replace"},
+		{func(tc *ToolConfig) { tc.ReplaceChunkSize = 0 }, "notify_chunks requires replace_chunk_size"},
+	} {
+		config := chunkedConfig()
+		tt.mutate(&config)
+		if err := config.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Validate() = %v, want %q", err, tt.want)
+		}
+	}
+	config := chunkedConfig()
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const toolNameKey contextKey = "tool_name"
+
+// CommandLogFiles are the files, in the directory a tool's command runs in,
+// that the runner streams its stdout and stderr to.
+func CommandLogFiles(toolName string) (stdout, stderr string) {
+	return fmt.Sprintf("%s_stdout.log", toolName), fmt.Sprintf("%s_stderr.log", toolName)
+}
+
+// WithToolName has the runner stream the output of the commands run with ctx
+// to the tool's CommandLogFiles.
+func WithToolName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, toolNameKey, name)
+}
+
+// ToolNameFromContext is the name of the tool whose command runs in ctx, or
+// "" for commands run on their own.
+func ToolNameFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(toolNameKey).(string)
+	return name
+}
+
+// commandLogContext names the tool for the runner's command logs. The first
+// attempt removes the logs of an earlier run; retries and the values of a
+// replacement run append to them. A dry run leaves them alone.
+func (t *ConfigurableTool) commandLogContext(ctx context.Context, options *Options) context.Context {
+	if !isRetry(ctx) && (options == nil || !options.DryRun) {
+		stdout, stderr := CommandLogFiles(t.name)
+		for _, name := range []string{stdout, stderr} {
+			if err := os.Remove(filepath.Join(getOutputDir(options), name)); err != nil && !os.IsNotExist(err) {
+				t.logger.WithTool(t.name, t.tool_type).Warnf("Could not remove old command log: %v", err)
+			}
+		}
+	}
+	return WithToolName(ctx, t.name)
+}
@@ -2,8 +2,11 @@ package tools
 
 import (
 	"fmt"
+	"pipeliner/pkg/hostlimit"
 	"pipeliner/pkg/logger"
+	"pipeliner/pkg/scoring"
 	"reflect"
+	"regexp"
 	"strings"
 	"time"
 )
@@ -16,6 +19,66 @@ type Options struct {
 	Environment map[string]string
 	DryRun      bool
 	Logger      *logger.Logger
+	// SkipHooks runs the tools without their post and stage hooks, for runs
+	// whose results are thrown away.
+	SkipHooks bool
+	// SubprocessHooks are the module's subprocess_hooks by name, set by
+	// PrepareScan.
+	SubprocessHooks map[string]*SubprocessHook
+	// StageHooks are the module's hooks by stage, set by PrepareScan. They
+	// run alongside the registered stage hooks, in place of any with the
+	// same name.
+	StageHooks map[Stage][]StageHook
+
+	// OnHookWarning, if set, receives non-critical post hook failures.
+	OnHookWarning func(HookWarning)
+	// OnToolWarning, if set, receives the stderr lines of the tools that
+	// match their warning patterns.
+	OnToolWarning func(ToolWarning)
+	// OnHookExecution, if set, receives a record of every post and stage hook run.
+	OnHookExecution func(HookExecution)
+	// OnProgress, if set, receives the progress events of the strategies,
+	// such as the start and end of a cooldown.
+	OnProgress func(ProgressEvent)
+	// OnToolProgress, if set, receives every tool's own progress events:
+	// started, running, and completed or failed.
+	OnToolProgress func(ProgressEvent)
+	// OnStageComplete, if set, is called after each stage and its hooks
+	// finish.
+	OnStageComplete func(Stage)
+	// OnChunkComplete, if set, is called after each chunk of a tool with a
+	// replace_chunk_size, once its outputs and checkpoint are written.
+	OnChunkComplete func(ChunkProgress)
+	// Pause, if set, is checked by the strategies before each tool starts.
+	Pause *PauseGate
+	// HTTPHeaders are sent by every HTTP tool that takes headers, on top
+	// of the module's http_headers.
+	HTTPHeaders map[string]string
+	// Satisfied names tools that already ran in WorkingDir and are not run
+	// again, as when retrying a scan's failed tools; dependencies on them
+	// count as met.
+	Satisfied []string
+	// Proxy is the URL every tool that can use a proxy goes through.
+	Proxy string
+	// ScanID names the scan to the per-host limiter; empty uses WorkingDir.
+	ScanID string
+	// HostLimiter spaces out the replacement commands sent to each host
+	// across scans; nil uses hostlimit.Global().
+	HostLimiter *hostlimit.Limiter
+	// Priority is the scan's priority, which NicePolicy, if set, turns
+	// into the niceness its tools run at.
+	Priority   int
+	NicePolicy *NicePolicy
+	// SeverityPolicy scores the scan's findings. PrepareScan adds the
+	// module's severity_rules to it.
+	SeverityPolicy *scoring.Policy
+	// Resume maps the tools to continue from an interrupted run to the
+	// state file they left, as found by InterruptedRun.
+	Resume map[string]string
+	// DiskQuotaMB is the most WorkingDir may hold, which the server checks
+	// while the scan runs. It also caps the output of the tools without a
+	// max_output_mb; 0 is no limit.
+	DiskQuotaMB int
 }
 
 // DefaultOptions returns a new Options instance with sensible defaults
@@ -58,12 +121,91 @@ type ToolConfig struct {
 	Type        string        `yaml:"type" mapstructure:"type"`
 	Command     string        `yaml:"command"`
 	Replace     string        `yaml:"replace,omitempty"`
-	ReplaceFrom string        `yaml:"replace_from,omitempty" mapstructure:"replace_from"`
+	ReplaceFrom []string      `yaml:"replace_from,omitempty" mapstructure:"replace_from"` // files or globs, merged in order
 	Flags       []FlagConfig  `yaml:"flags"`
 	DependsOn   []string      `yaml:"depends_on" mapstructure:"depends_on"`
 	Timeout     time.Duration `yaml:"timeout,omitempty" mapstructure:"timeout"`
 	Retries     int           `yaml:"retries,omitempty" mapstructure:"retries"`
+	PreHooks    []string      `yaml:"prehooks,omitempty" mapstructure:"prehooks"`
 	PostHooks   []string      `yaml:"posthooks,omitempty" mapstructure:"posthooks"`
+
+	// RetryBackoff is the wait before the first of the Retries, doubled for
+	// each one after; 0 is DefaultRetryBackoff.
+	RetryBackoff time.Duration `yaml:"retry_backoff,omitempty" mapstructure:"retry_backoff"`
+
+	// OutputPerValue names each replacement run's output, e.g.
+	// "{{value_sanitized}}_ffuf_output.json"; it replaces {{output}} in the args.
+	OutputPerValue string `yaml:"output_per_value,omitempty" mapstructure:"output_per_value"`
+
+	// ReplaceConcurrency runs up to this many replacement values at once.
+	// 0 or 1 runs them one after another. Above 1, the tool fails if any
+	// value failed.
+	ReplaceConcurrency int `yaml:"replace_concurrency,omitempty" mapstructure:"replace_concurrency"`
+	// ReplaceChunkSize runs the replacement values in chunks of this many,
+	// writing a checkpoint after each one that an interrupted run
+	// continues from. 0 runs them all in one go.
+	ReplaceChunkSize int `yaml:"replace_chunk_size,omitempty" mapstructure:"replace_chunk_size"`
+	// NotifyChunks sends a notification of the progress after each chunk.
+	NotifyChunks bool `yaml:"notify_chunks,omitempty" mapstructure:"notify_chunks"`
+
+	// CooldownAfter holds back the tools that run after this one, giving a
+	// fragile target time to recover.
+	CooldownAfter time.Duration `yaml:"cooldown_after,omitempty" mapstructure:"cooldown_after"`
+
+	// RunAs drops privileges for this tool: a user name, a uid, or
+	// "uid:gid". Empty runs it as the pipeliner process.
+	RunAs string `yaml:"run_as,omitempty" mapstructure:"run_as"`
+
+	// OnFailure runs a diagnostic command when the tool fails and keeps its
+	// output next to the tool's.
+	OnFailure *OnFailureConfig `yaml:"on_failure,omitempty" mapstructure:"on_failure"`
+
+	// FailOnEmptyOutput fails the tool, instead of warning, when it exits
+	// cleanly but every declared output is empty.
+	FailOnEmptyOutput bool `yaml:"fail_on_empty_output,omitempty" mapstructure:"fail_on_empty_output"`
+
+	// SuccessCriteria fail the tool, or warn, when its outputs are not
+	// what a working run produces.
+	SuccessCriteria *SuccessCriteria `yaml:"success_criteria,omitempty" mapstructure:"success_criteria"`
+
+	// MaxOutputMB stops the tool once one of its declared outputs grows
+	// past this many megabytes. 0 falls back to the scan's DiskQuotaMB.
+	MaxOutputMB int `yaml:"max_output_mb,omitempty" mapstructure:"max_output_mb"`
+
+	// WarningPatterns are regexes for stderr lines worth a scan warning
+	// even when the tool succeeds, e.g. "rate limited". subfinder and
+	// nuclei have defaults these add to.
+	WarningPatterns []string `yaml:"warning_patterns,omitempty" mapstructure:"warning_patterns"`
+	// NotifyWarnings also sends a low severity notification for each.
+	NotifyWarnings bool `yaml:"notify_warnings,omitempty" mapstructure:"notify_warnings"`
+
+	// HeaderFlag is how the tool takes the http_headers, e.g. "-H" for
+	// "-H 'Name: value'". httpx, nuclei and ffuf default to -H; "none"
+	// leaves the headers out.
+	HeaderFlag string `yaml:"header_flag,omitempty" mapstructure:"header_flag"`
+
+	// ProxyFlag is how the tool takes the scan's proxy URL. httpx, nuclei
+	// and ffuf have defaults; "none" leaves it to the environment.
+	ProxyFlag string `yaml:"proxy_flag,omitempty" mapstructure:"proxy_flag"`
+	// NoProxy keeps the tool off the proxy altogether.
+	NoProxy bool `yaml:"no_proxy,omitempty" mapstructure:"no_proxy"`
+
+	// SkipIfOutputExists skips the run when the tool's output is already in
+	// the working directory and not empty, as left by an interrupted scan.
+	SkipIfOutputExists bool `yaml:"skip_if_output_exists,omitempty" mapstructure:"skip_if_output_exists"`
+
+	// Estimate marks the cheap tools, typically subdomain enumeration, that
+	// a workload estimate runs before the real scan.
+	Estimate bool `yaml:"estimate,omitempty" mapstructure:"estimate"`
+
+	// ResumeSupport lets a resumed scan or a retry continue an interrupted
+	// run of the tool from the state it left, instead of starting over.
+	ResumeSupport *ResumeSupport `yaml:"resume_support,omitempty" mapstructure:"resume_support"`
+}
+
+// Stage is the stage the tool's type puts it in, empty for none.
+func (tc *ToolConfig) Stage() Stage {
+	return stageForToolType(tc.Type)
 }
 
 func (tc *ToolConfig) Validate() error {
@@ -76,6 +218,76 @@ func (tc *ToolConfig) Validate() error {
 	if tc.Retries < 0 {
 		return fmt.Errorf("retries must be non-negative for tool %s", tc.Name)
 	}
+	if tc.RetryBackoff < 0 {
+		return fmt.Errorf("retry_backoff must be non-negative for tool %s", tc.Name)
+	}
+	if tc.CooldownAfter < 0 {
+		return fmt.Errorf("cooldown_after must be non-negative for tool %s", tc.Name)
+	}
+	if tc.MaxOutputMB < 0 {
+		return fmt.Errorf("max_output_mb must be non-negative for tool %s", tc.Name)
+	}
+	for _, pattern := range tc.WarningPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid warning pattern %q for tool %s: %w", pattern, tc.Name, err)
+		}
+	}
+	if tc.RunAs != "" {
+		if err := ParseRunAs(tc.RunAs); err != nil {
+			return fmt.Errorf("tool %s: %w", tc.Name, err)
+		}
+	}
+	if tc.OnFailure != nil {
+		if err := tc.OnFailure.Validate(); err != nil {
+			return fmt.Errorf("tool %s: %w", tc.Name, err)
+		}
+	}
+	if tc.SuccessCriteria != nil {
+		if err := tc.SuccessCriteria.Validate(); err != nil {
+			return fmt.Errorf("tool %s: %w", tc.Name, err)
+		}
+	}
+	if tc.HeaderFlag != "" && tc.HeaderFlag != NoHeaderFlag {
+		if err := validateFlag(tc.HeaderFlag); err != nil {
+			return fmt.Errorf("invalid header_flag for tool %s: %w", tc.Name, err)
+		}
+	}
+	if tc.ProxyFlag != "" && tc.ProxyFlag != NoProxyFlag {
+		if err := validateFlag(tc.ProxyFlag); err != nil {
+			return fmt.Errorf("invalid proxy_flag for tool %s: %w", tc.Name, err)
+		}
+	}
+	if tc.ResumeSupport != nil {
+		if tc.Replace != "" {
+			return fmt.Errorf("resume_support cannot be combined with replace for tool %s", tc.Name)
+		}
+		if err := tc.ResumeSupport.Validate(); err != nil {
+			return fmt.Errorf("tool %s: %w", tc.Name, err)
+		}
+	}
+	if tc.ReplaceConcurrency < 0 {
+		return fmt.Errorf("replace_concurrency must not be negative for tool %s", tc.Name)
+	}
+	if tc.ReplaceConcurrency > 1 && tc.Replace == "" {
+		return fmt.Errorf("replace_concurrency requires replace for tool %s", tc.Name)
+	}
+	if tc.ReplaceChunkSize < 0 {
+		return fmt.Errorf("replace_chunk_size must not be negative for tool %s", tc.Name)
+	}
+	if tc.ReplaceChunkSize > 0 && tc.Replace == "" {
+		return fmt.Errorf("replace_chunk_size requires replace for tool %s", tc.Name)
+	}
+	if tc.NotifyChunks && tc.ReplaceChunkSize == 0 {
+		return fmt.Errorf("notify_chunks requires replace_chunk_size for tool %s", tc.Name)
+	}
+	if tc.OutputPerValue != "" {
+		if tc.Replace == "" {
+			return fmt.Errorf("output_per_value requires replace for tool %s", tc.Name)
+		}
+		if err := validateOutputTemplate(tc.OutputPerValue); err != nil {
+			return fmt.Errorf("tool %s: %w", tc.Name, err)
+		}
+	}
 
 	for i, flag := range tc.Flags {
 		if err := flag.Validate(); err != nil {
@@ -94,11 +306,77 @@ func (fc *FlagConfig) Validate() error {
 }
 
 type ChainConfig struct {
-	Name          string        `yaml:"name"`
-	Description   string        `yaml:"description"`
-	ExecutionMode string        `yaml:"execution_mode"`
-	Tools         []ToolConfig  `yaml:"tools"`
-	GlobalTimeout time.Duration `yaml:"global_timeout,omitempty" mapstructure:"global_timeout"`
+	Name          string                   `yaml:"name"`
+	Description   string                   `yaml:"description"`
+	ExecutionMode string                   `yaml:"execution_mode"`
+	Tools         []ToolConfig             `yaml:"tools"`
+	GlobalTimeout time.Duration            `yaml:"global_timeout,omitempty" mapstructure:"global_timeout"`
+	StageTimeouts map[string]time.Duration `yaml:"stage_timeouts,omitempty" mapstructure:"stage_timeouts"`
+	StageSettings map[string]StageSettings `yaml:"stage_settings,omitempty" mapstructure:"stage_settings"`
+	// Checksums pins tool binaries, by command or path, to a sha256.
+	Checksums map[string]string `yaml:"checksums,omitempty" mapstructure:"checksums"`
+	// PreRun hooks set the scan up before the first tool starts.
+	PreRun []PhaseHookConfig `yaml:"pre_run,omitempty" mapstructure:"pre_run"`
+	// Cleanup hooks run after the scan, even when it failed or was cancelled.
+	Cleanup []PhaseHookConfig `yaml:"cleanup,omitempty" mapstructure:"cleanup"`
+	// SubprocessHooks are executables usable as hooks by name in this
+	// module, in place of a built-in hook of the same name.
+	SubprocessHooks []SubprocessHookConfig `yaml:"subprocess_hooks,omitempty" mapstructure:"subprocess_hooks"`
+	// Hooks are stage hooks built for this module by registered hook
+	// factories.
+	Hooks []HookConfig `yaml:"hooks,omitempty" mapstructure:"hooks"`
+	// TemplatesRef pins nuclei to a nuclei-templates tag, branch or commit.
+	TemplatesRef string `yaml:"templates_ref,omitempty" mapstructure:"templates_ref"`
+	// HTTPHeaders, such as a program's canary header, are passed to every
+	// tool with a header flag.
+	HTTPHeaders map[string]string `yaml:"http_headers,omitempty" mapstructure:"http_headers"`
+	// RedactHeaders are http_headers whose values are kept out of the scan
+	// record.
+	RedactHeaders []string `yaml:"redact_headers,omitempty" mapstructure:"redact_headers"`
+	// Proxy is an http(s) or socks5 URL the scan's traffic goes through.
+	Proxy string `yaml:"proxy,omitempty" mapstructure:"proxy"`
+	// IterationDirs gives every run of a periodic scan its own run_<time>
+	// directory instead of writing over the previous run's outputs.
+	IterationDirs bool `yaml:"iteration_dirs,omitempty" mapstructure:"iteration_dirs"`
+	// MaxConcurrency caps how many tools the concurrent and hybrid modes
+	// run at once; 0 leaves concurrent unlimited and hybrid at one per CPU.
+	MaxConcurrency int `yaml:"max_concurrency,omitempty" mapstructure:"max_concurrency"`
+	// SeverityRules adjust the severity of the scan's findings, after the
+	// server's global rules.
+	SeverityRules []scoring.Rule `yaml:"severity_rules,omitempty" mapstructure:"severity_rules"`
+}
+
+var validRef = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+
+type StageSettings struct {
+	// Cooldown holds back later tools once every tool in the stage is done.
+	Cooldown time.Duration `yaml:"cooldown,omitempty" mapstructure:"cooldown"`
+}
+
+// StageBudgets returns StageTimeouts keyed by Stage for the strategies.
+func (cc *ChainConfig) StageBudgets() map[Stage]time.Duration {
+	if len(cc.StageTimeouts) == 0 {
+		return nil
+	}
+	budgets := make(map[Stage]time.Duration, len(cc.StageTimeouts))
+	for stage, timeout := range cc.StageTimeouts {
+		budgets[Stage(stage)] = timeout
+	}
+	return budgets
+}
+
+// StageCooldowns returns the stage_settings cooldowns keyed by Stage.
+func (cc *ChainConfig) StageCooldowns() map[Stage]time.Duration {
+	cooldowns := make(map[Stage]time.Duration)
+	for stage, settings := range cc.StageSettings {
+		if settings.Cooldown > 0 {
+			cooldowns[Stage(stage)] = settings.Cooldown
+		}
+	}
+	if len(cooldowns) == 0 {
+		return nil
+	}
+	return cooldowns
 }
 
 func (cc *ChainConfig) Validate() error {
@@ -116,7 +394,84 @@ func (cc *ChainConfig) Validate() error {
 		return fmt.Errorf("invalid execution mode: %s", cc.ExecutionMode)
 	}
 
+	if cc.MaxConcurrency < 0 {
+		return fmt.Errorf("max_concurrency must be non-negative")
+	}
+
+	for stage, timeout := range cc.StageTimeouts {
+		if !knownStages[Stage(stage)] {
+			return fmt.Errorf("stage_timeouts: unknown stage %s", stage)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("stage_timeouts: timeout for stage %s must be positive", stage)
+		}
+	}
+
+	for stage, settings := range cc.StageSettings {
+		if !knownStages[Stage(stage)] {
+			return fmt.Errorf("stage_settings: unknown stage %s", stage)
+		}
+		if settings.Cooldown < 0 {
+			return fmt.Errorf("stage_settings: cooldown for stage %s must be non-negative", stage)
+		}
+	}
+
+	for phase, hooks := range map[string][]PhaseHookConfig{"pre_run": cc.PreRun, "cleanup": cc.Cleanup} {
+		for i, hook := range hooks {
+			if hook.Hook == "" {
+				return fmt.Errorf("%s: hook name is required at index %d", phase, i)
+			}
+			if hook.Timeout < 0 {
+				return fmt.Errorf("%s: timeout for hook %s must be non-negative", phase, hook.Hook)
+			}
+		}
+	}
+
+	subprocessHooks := make(map[string]bool, len(cc.SubprocessHooks))
+	for _, hook := range cc.SubprocessHooks {
+		if err := hook.Validate(); err != nil {
+			return fmt.Errorf("subprocess_hooks: %w", err)
+		}
+		if subprocessHooks[hook.Name] {
+			return fmt.Errorf("subprocess_hooks: duplicate hook %s", hook.Name)
+		}
+		subprocessHooks[hook.Name] = true
+	}
+
+	for _, hook := range cc.Hooks {
+		if _, err := hook.build(); err != nil {
+			return fmt.Errorf("hooks: %w", err)
+		}
+	}
+
+	for command, sum := range cc.Checksums {
+		if !validSHA256(sum) {
+			return fmt.Errorf("checksums: %s is not a sha256 hex digest for %s", sum, command)
+		}
+	}
+
+	if cc.TemplatesRef != "" && !validRef.MatchString(cc.TemplatesRef) {
+		return fmt.Errorf("templates_ref: %q is not a valid tag, branch or commit", cc.TemplatesRef)
+	}
+
+	for name, value := range cc.HTTPHeaders {
+		if err := validateHeader(name, value); err != nil {
+			return fmt.Errorf("http_headers: %w", err)
+		}
+	}
+
+	if cc.Proxy != "" {
+		if err := ValidateProxy(cc.Proxy); err != nil {
+			return err
+		}
+	}
+
+	if _, err := scoring.Compile(cc.SeverityRules); err != nil {
+		return fmt.Errorf("severity_rules: %w", err)
+	}
+
 	toolNames := make(map[string]bool)
+	estimates := make(map[string]bool)
 	for i, tool := range cc.Tools {
 		if err := tool.Validate(); err != nil {
 			return fmt.Errorf("invalid tool config at index %d: %w", i, err)
@@ -126,6 +481,7 @@ func (cc *ChainConfig) Validate() error {
 			return fmt.Errorf("duplicate tool name: %s", tool.Name)
 		}
 		toolNames[tool.Name] = true
+		estimates[tool.Name] = tool.Estimate
 	}
 
 	for _, tool := range cc.Tools {
@@ -133,6 +489,9 @@ func (cc *ChainConfig) Validate() error {
 			if !toolNames[dep] {
 				return fmt.Errorf("tool %s depends on unknown tool %s", tool.Name, dep)
 			}
+			if tool.Estimate && !estimates[dep] {
+				return fmt.Errorf("estimate tool %s depends on %s, which is not an estimate tool", tool.Name, dep)
+			}
 		}
 	}
 
@@ -221,6 +580,15 @@ func (tc *ToolConfig) BuildArgs(options interface{}) ([]string, error) {
 			args = append(args, flag.Flag, value)
 		}
 	}
+
+	if opts, ok := options.(*Options); ok && opts != nil {
+		headers, err := tc.headerArgs(opts.HTTPHeaders)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, headers...)
+		args = append(args, tc.proxyArgs(opts.Proxy)...)
+	}
 	return args, nil
 }
 
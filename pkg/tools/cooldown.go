@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// toolCooldown is implemented by tools that ask for a pause before the tools
+// that follow them.
+type toolCooldown interface {
+	CooldownAfter() time.Duration
+}
+
+func cooldownAfter(t Tool) time.Duration {
+	if tc, ok := t.(toolCooldown); ok {
+		return tc.CooldownAfter()
+	}
+	return 0
+}
+
+// cooldownFor is the pause owed after t finishes; when t also completed stage,
+// the longer of the tool and stage cooldowns applies.
+func cooldownFor(t Tool, stage Stage, stageCooldowns map[Stage]time.Duration) time.Duration {
+	wait := cooldownAfter(t)
+	if stage != "" && stageCooldowns[stage] > wait {
+		wait = stageCooldowns[stage]
+	}
+	return wait
+}
+
+// cooldown waits d after name finished, or until ctx is done. The start and
+// end are reported as progress events so the pause does not look like a hang.
+// A dry run does not wait.
+func cooldown(ctx context.Context, name string, d time.Duration, options *Options, after func(time.Duration) <-chan time.Time, now func() time.Time) error {
+	if d <= 0 || (options != nil && options.DryRun) {
+		return nil
+	}
+	if after == nil {
+		after = time.After
+	}
+	if now == nil {
+		now = time.Now
+	}
+
+	chainLogger.Infof("Cooling down for %s after %s", d, name)
+	reportProgress(options, ProgressEvent{
+		Tool:      name,
+		Status:    ProgressCoolingDown,
+		Message:   fmt.Sprintf("waiting %s before starting the next tools", d),
+		Timestamp: now(),
+	})
+
+	select {
+	case <-ctx.Done():
+		chainLogger.Warnf("Cooldown after %s interrupted: %v", name, ctx.Err())
+		return ctx.Err()
+	case <-after(d):
+	}
+
+	reportProgress(options, ProgressEvent{
+		Tool:      name,
+		Status:    ProgressCooldownFinished,
+		Message:   fmt.Sprintf("waited %s", d),
+		Timestamp: now(),
+	})
+	return nil
+}
+
+func reportProgress(options *Options, event ProgressEvent) {
+	if options != nil && options.OnProgress != nil {
+		options.OnProgress(event)
+	}
+}
@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pipeliner/pkg/testutil"
+)
+
+type cooldownMockTool struct {
+	*MockTool
+	cooldown time.Duration
+}
+
+func (c *cooldownMockTool) CooldownAfter() time.Duration { return c.cooldown }
+
+type progressRecorder struct {
+	mu       sync.Mutex
+	statuses []string
+}
+
+func (p *progressRecorder) record(event ProgressEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.statuses = append(p.statuses, event.Tool+":"+event.Status)
+}
+
+func (p *progressRecorder) list() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.statuses...)
+}
+
+func TestSequentialStrategy_CooldownDelaysNextTool(t *testing.T) {
+	ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+	defer cancel()
+
+	clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	nmap := &cooldownMockTool{MockTool: NewMockTool("nmap", "recon", nil), cooldown: 10 * time.Minute}
+	nuclei := NewMockTool("nuclei", "vuln", nil)
+
+	progress := &progressRecorder{}
+	strategy := &SequentialStrategy{now: clock.Now, after: clock.After}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- strategy.Run(ctx, []Tool{nmap, nuclei}, &Options{OnProgress: progress.record})
+	}()
+
+	clock.WaitForWaiters(t, 1)
+	testutil.AssertEquals(t, 0, nuclei.GetRunCount())
+	testutil.AssertEquals(t, "nmap:"+ProgressCoolingDown, strings.Join(progress.list(), ","))
+
+	clock.Advance(9 * time.Minute)
+	testutil.AssertEquals(t, 1, clock.Waiters())
+
+	clock.Advance(time.Minute)
+	testutil.AssertNoError(t, <-done)
+	testutil.AssertEquals(t, 1, nuclei.GetRunCount())
+	testutil.AssertEquals(t, "nmap:"+ProgressCoolingDown+",nmap:"+ProgressCooldownFinished, strings.Join(progress.list(), ","))
+}
+
+func TestSequentialStrategy_CancelInterruptsCooldown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	nmap := &cooldownMockTool{MockTool: NewMockTool("nmap", "recon", nil), cooldown: time.Hour}
+	nuclei := NewMockTool("nuclei", "vuln", nil)
+
+	strategy := &SequentialStrategy{now: clock.Now, after: clock.After}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- strategy.Run(ctx, []Tool{nmap, nuclei}, &Options{})
+	}()
+
+	clock.WaitForWaiters(t, 1)
+	cancel()
+
+	select {
+	case err := <-done:
+		testutil.AssertEquals(t, true, errors.Is(err, context.Canceled))
+	case <-time.After(2 * time.Second):
+		t.Fatal("cooldown was not interrupted by cancellation")
+	}
+	testutil.AssertEquals(t, 0, nuclei.GetRunCount())
+}
+
+func TestHybridStrategy_StageCooldownHoldsDependents(t *testing.T) {
+	ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+	defer cancel()
+
+	clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	subfinder := NewMockTool("subfinder", "domain_enum", nil)
+	httpx := NewMockTool("httpx", "recon", []string{"subfinder"})
+
+	strategy := &HybridStrategy{
+		StageCooldowns: map[Stage]time.Duration{StageSubdomain: 5 * time.Minute},
+		now:            clock.Now,
+		after:          clock.After,
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- strategy.Run(ctx, []Tool{subfinder, httpx}, &Options{})
+	}()
+
+	clock.WaitForWaiters(t, 1)
+	testutil.AssertEquals(t, 0, httpx.GetRunCount())
+
+	clock.Advance(5 * time.Minute)
+	testutil.AssertNoError(t, <-done)
+	testutil.AssertEquals(t, 1, httpx.GetRunCount())
+}
+
+func TestChainConfig_StageSettingsValidation(t *testing.T) {
+	config := ChainConfig{
+		ExecutionMode: "sequential",
+		Tools:         []ToolConfig{{Name: "nmap", Command: "nmap", CooldownAfter: time.Minute}},
+		StageSettings: map[string]StageSettings{"recon": {Cooldown: time.Minute}},
+	}
+	testutil.AssertNoError(t, config.Validate())
+	testutil.AssertEquals(t, time.Minute, config.StageCooldowns()[StageRecon])
+
+	config.StageSettings = map[string]StageSettings{"bogus": {Cooldown: time.Minute}}
+	testutil.AssertError(t, config.Validate())
+
+	config.StageSettings = nil
+	config.Tools[0].CooldownAfter = -time.Second
+	testutil.AssertError(t, config.Validate())
+}
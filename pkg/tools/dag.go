@@ -3,22 +3,64 @@ package tools
 import (
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
+type DAGToolState string
+
+const (
+	DAGBlocked   DAGToolState = "blocked"
+	DAGCooldown  DAGToolState = "cooldown"
+	DAGReady     DAGToolState = "ready"
+	DAGRunning   DAGToolState = "running"
+	DAGCompleted DAGToolState = "completed"
+	DAGFailed    DAGToolState = "failed"
+	DAGSkipped   DAGToolState = "skipped"
+
+	// DAGCompletedEmpty is a tool that exited cleanly with empty output; its
+	// dependents still run.
+	DAGCompletedEmpty DAGToolState = "completed_empty"
+)
+
+// DAGNode is one tool in a DAGSnapshot. BlockedBy lists the dependencies that
+// have not completed yet.
+type DAGNode struct {
+	Name      string       `json:"name"`
+	State     DAGToolState `json:"state"`
+	BlockedBy []string     `json:"blocked_by,omitempty"`
+}
+
+// DAGSnapshot is the state of every tool in a hybrid run, in config order.
+type DAGSnapshot struct {
+	Tools   []DAGNode `json:"tools"`
+	TakenAt time.Time `json:"taken_at"`
+}
+
 type depGraph struct {
 	nodes       map[string]Tool
 	children    map[string][]string
 	remaining   map[string]int
 	failedDeps  map[string]int
 	initialized bool
+
+	// state is written by the scheduler and workers and read by snapshot
+	mu    sync.Mutex
+	order []string
+	state map[string]DAGToolState
 }
 
-func newDepGraph(tools []Tool) (*depGraph, error) {
+// newDepGraph builds the graph of tools. Dependencies on satisfied tools,
+// which are not part of this run, are already met.
+func newDepGraph(tools []Tool, satisfied []string) (*depGraph, error) {
 	g := &depGraph{
 		nodes:      make(map[string]Tool, len(tools)),
 		children:   make(map[string][]string, len(tools)),
 		remaining:  make(map[string]int, len(tools)),
 		failedDeps: make(map[string]int, len(tools)),
+		state:      make(map[string]DAGToolState, len(tools)),
 	}
 	// Build nodes
 	for _, t := range tools {
@@ -27,17 +69,22 @@ func newDepGraph(tools []Tool) (*depGraph, error) {
 			return nil, fmt.Errorf("duplicate tool name: %s", name)
 		}
 		g.nodes[name] = t
+		g.order = append(g.order, name)
+		g.state[name] = DAGBlocked
 	}
 
 	// Build edges and indegrees
 	for _, t := range tools {
 		name := t.Name()
-		deps := t.DependsOn()
-		g.remaining[name] = len(deps)
-		for _, p := range deps {
+		g.remaining[name] = 0
+		for _, p := range t.DependsOn() {
 			if _, ok := g.nodes[p]; !ok {
+				if slices.Contains(satisfied, p) {
+					continue
+				}
 				return nil, fmt.Errorf("tool %s depends on unknown tool %s", name, p)
 			}
+			g.remaining[name]++
 			g.children[p] = append(g.children[p], name)
 		}
 	}
@@ -72,11 +119,32 @@ func (g *depGraph) validate() error {
 		}
 	}
 	if seen != len(g.nodes) {
-		return fmt.Errorf("dependency cycle detected (seen %d of %d)", seen, len(g.nodes))
+		// what is left waits on a cycle or is part of one
+		var stuck []string
+		for _, name := range g.order {
+			if inDeg[name] > 0 {
+				stuck = append(stuck, name)
+			}
+		}
+		return fmt.Errorf("dependency cycle detected among %s", strings.Join(stuck, ", "))
 	}
 	return nil
 }
 
+// CheckDependencies finds the dependency problems of a module's tools that
+// a hybrid run would refuse to start with: unknown tools and cycles.
+func CheckDependencies(configs []ToolConfig) error {
+	tools := make([]Tool, len(configs))
+	for i, config := range configs {
+		tools[i] = NewConfigurableTool(config.Name, config.Type, config, nil)
+	}
+	g, err := newDepGraph(tools, nil)
+	if err != nil {
+		return err
+	}
+	return g.validate()
+}
+
 func (g *depGraph) initialReady() []Tool {
 	var ready []Tool
 	for name, deg := range g.remaining {
@@ -87,7 +155,47 @@ func (g *depGraph) initialReady() []Tool {
 	return ready
 }
 
+func (g *depGraph) setState(name string, state DAGToolState) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.state[name] = state
+}
+
+// snapshot is safe to call while the graph is being scheduled.
+func (g *depGraph) snapshot(now time.Time) *DAGSnapshot {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	snap := &DAGSnapshot{Tools: make([]DAGNode, 0, len(g.order)), TakenAt: now}
+	for _, name := range g.order {
+		node := DAGNode{Name: name, State: g.state[name]}
+		if node.State == DAGBlocked {
+			for _, dep := range g.nodes[name].DependsOn() {
+				if _, ok := g.nodes[dep]; !ok {
+					continue
+				}
+				if g.state[dep] != DAGCompleted && g.state[dep] != DAGCompletedEmpty {
+					node.BlockedBy = append(node.BlockedBy, dep)
+				}
+			}
+		}
+		snap.Tools = append(snap.Tools, node)
+	}
+	return snap
+}
+
 func (g *depGraph) onComplete(name string, success bool) (newReady []Tool, skipped []string) {
+	if success {
+		g.setState(name, DAGCompleted)
+	} else {
+		g.setState(name, DAGFailed)
+	}
+	defer func() {
+		for _, s := range skipped {
+			g.setState(s, DAGSkipped)
+		}
+	}()
+
 	queue := make([]string, 0)
 	for _, child := range g.children[name] {
 		g.remaining[child]--
@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pipeliner/pkg/testutil"
+)
+
+func dagStates(snap *DAGSnapshot) string {
+	parts := make([]string, 0, len(snap.Tools))
+	for _, node := range snap.Tools {
+		part := node.Name + "=" + string(node.State)
+		if len(node.BlockedBy) > 0 {
+			part += "(" + strings.Join(node.BlockedBy, "+") + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestHybridStrategy_PublishesDAGSnapshots(t *testing.T) {
+	ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+	defer cancel()
+
+	subfinder := NewMockTool("subfinder", "domain_enum", nil)
+	httpx := NewMockTool("httpx", "recon", []string{"subfinder"})
+	nuclei := NewMockTool("nuclei", "vuln", []string{"httpx", "subfinder"})
+	naabu := NewMockTool("naabu", "recon", []string{"subfinder"})
+	nmap := NewMockTool("nmap", "recon", []string{"naabu"})
+
+	// hold httpx so the graph can be inspected mid-run
+	httpxRunning := make(chan struct{})
+	releaseHttpx := make(chan struct{})
+	httpx.SetRunFunc(func(context.Context, *Options) error {
+		close(httpxRunning)
+		<-releaseHttpx
+		return nil
+	})
+	naabu.SetRunFunc(func(context.Context, *Options) error { return context.DeadlineExceeded })
+
+	var mu sync.Mutex
+	var snapshots []*DAGSnapshot
+	options := &Options{OnProgress: func(event ProgressEvent) {
+		if event.Status != ProgressDAGChanged {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		snapshots = append(snapshots, event.DAG)
+	}}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- (&HybridStrategy{}).Run(ctx, []Tool{subfinder, httpx, nuclei, naabu, nmap}, options)
+	}()
+
+	<-httpxRunning
+	mu.Lock()
+	mid := dagStates(snapshots[len(snapshots)-1])
+	mu.Unlock()
+	testutil.AssertEquals(t, true, strings.HasPrefix(mid, "subfinder=completed httpx=running nuclei=blocked(httpx) "))
+
+	close(releaseHttpx)
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	testutil.AssertEquals(t, "subfinder=ready httpx=blocked(subfinder) nuclei=blocked(httpx+subfinder) naabu=blocked(subfinder) nmap=blocked(naabu)", dagStates(snapshots[0]))
+	testutil.AssertEquals(t, "subfinder=completed httpx=completed nuclei=completed naabu=failed nmap=skipped", dagStates(snapshots[len(snapshots)-1]))
+}
+
+func TestCheckDependencies(t *testing.T) {
+	tests := []struct {
+		name    string
+		tools   []ToolConfig
+		wantErr string
+	}{
+		{
+			name: "acyclic",
+			tools: []ToolConfig{
+				{Name: "subfinder", Command: "subfinder"},
+				{Name: "httpx", Command: "httpx", DependsOn: []string{"subfinder"}},
+			},
+		},
+		{
+			name: "cycle",
+			tools: []ToolConfig{
+				{Name: "subfinder", Command: "subfinder"},
+				{Name: "httpx", Command: "httpx", DependsOn: []string{"subfinder", "nuclei"}},
+				{Name: "nuclei", Command: "nuclei", DependsOn: []string{"httpx"}},
+			},
+			wantErr: "dependency cycle detected among httpx, nuclei",
+		},
+		{
+			name:    "unknown dependency",
+			tools:   []ToolConfig{{Name: "httpx", Command: "httpx", DependsOn: []string{"subfinder"}}},
+			wantErr: "tool httpx depends on unknown tool subfinder",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckDependencies(tt.tools)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("got %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
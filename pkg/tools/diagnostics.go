@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// DomainPlaceholder in on_failure args is replaced with the scan's domain.
+	DomainPlaceholder = "{{domain}}"
+
+	defaultFailureTimeout = 30 * time.Second
+	maxDiagnosticsBytes   = 1 << 20
+)
+
+// OnFailureConfig is a diagnostic command run when a tool fails, e.g.
+// "curl -v https://{{domain}}" or "dig {{domain}}".
+type OnFailureConfig struct {
+	Command string        `yaml:"command"`
+	Args    []string      `yaml:"args,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty" mapstructure:"timeout"`
+}
+
+func (c *OnFailureConfig) Validate() error {
+	if c.Command == "" {
+		return fmt.Errorf("on_failure command is required")
+	}
+	if strings.ContainsAny(c.Command, " \t") {
+		return fmt.Errorf("on_failure command %q must be a single executable, put its arguments in args", c.Command)
+	}
+	if err := validateArgument(c.Command); err != nil {
+		return fmt.Errorf("invalid on_failure command: %w", err)
+	}
+	for i, arg := range c.Args {
+		if err := validateArgument(arg); err != nil {
+			return fmt.Errorf("invalid on_failure argument at index %d: %w", i, err)
+		}
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("on_failure timeout must be non-negative")
+	}
+	return nil
+}
+
+// FailureDiagnosticsFile is where a tool's on_failure output is saved, in
+// its working directory.
+func FailureDiagnosticsFile(toolName string) string {
+	return fmt.Sprintf("%s_failure_diagnostics.txt", toolName)
+}
+
+// DiagnosedError is a tool failure with on_failure diagnostics saved to
+// Diagnostics. It reads and unwraps as the original error.
+type DiagnosedError struct {
+	Err         error
+	Diagnostics string
+}
+
+func (e *DiagnosedError) Error() string { return e.Err.Error() }
+func (e *DiagnosedError) Unwrap() error { return e.Err }
+
+// captureFailure runs the on_failure command within its timeout and returns
+// runErr, wrapped with the diagnostics file when one was written. Scans that
+// were cancelled are not diagnosed.
+func (t *ConfigurableTool) captureFailure(ctx context.Context, options *Options, runErr error) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return runErr
+	}
+	onFailure := t.config.OnFailure
+	runner, ok := t.runner.(OutputCommandRunner)
+	if !ok {
+		t.logger.WithTool(t.name, t.tool_type).Warnf("Runner cannot capture on_failure output for %s", t.name)
+		return runErr
+	}
+
+	domain := ""
+	if options != nil {
+		domain = options.Domain
+	}
+	args := make([]string, len(onFailure.Args))
+	for i, arg := range onFailure.Args {
+		args[i] = strings.ReplaceAll(arg, DomainPlaceholder, domain)
+	}
+
+	timeout := onFailure.Timeout
+	if timeout <= 0 {
+		timeout = defaultFailureTimeout
+	}
+	// the tool's own deadline may be what failed it
+	diagCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	start := time.Now()
+	output, diagErr := runner.Output(diagCtx, commandDir(options), onFailure.Command, args)
+	if len(output) > maxDiagnosticsBytes {
+		output = append(output[:maxDiagnosticsBytes], "\n[output truncated]"...)
+	}
+
+	var report strings.Builder
+	fmt.Fprintf(&report, "$ %s %s\n", onFailure.Command, strings.Join(args, " "))
+	fmt.Fprintf(&report, "tool error: %v\n\n", runErr)
+	report.Write(output)
+	if diagErr != nil {
+		fmt.Fprintf(&report, "\n[diagnostic failed after %s: %v]\n", time.Since(start).Round(time.Millisecond), diagErr)
+	}
+
+	name := FailureDiagnosticsFile(t.name)
+	if err := os.WriteFile(filepath.Join(getOutputDir(options), name), []byte(report.String()), 0644); err != nil {
+		t.logger.WithTool(t.name, t.tool_type).Warnf("Failed to save failure diagnostics: %v", err)
+		return runErr
+	}
+	t.logger.WithTool(t.name, t.tool_type).Infof("Saved failure diagnostics to %s", name)
+	return &DiagnosedError{Err: runErr, Diagnostics: name}
+}
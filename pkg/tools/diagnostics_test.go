@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errToolExit = errors.New("exit status 2")
+
+// diagnosingRunner fails every Run and answers Output with output, or
+// blocks until the context ends when output is nil.
+type diagnosingRunner struct {
+	output  []byte
+	gotArgs []string
+}
+
+func (r *diagnosingRunner) Run(ctx context.Context, command string, args []string) error {
+	return errToolExit
+}
+
+func (r *diagnosingRunner) Output(ctx context.Context, dir, command string, args []string) ([]byte, error) {
+	r.gotArgs = append([]string{command}, args...)
+	if r.output == nil {
+		<-ctx.Done()
+		return []byte("partial"), ctx.Err()
+	}
+	return r.output, nil
+}
+
+func TestOnFailureCapturesDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	runner := &diagnosingRunner{output: []byte("< HTTP/1.1 502 Bad Gateway\n")}
+	config := ToolConfig{
+		Name:      "httpx",
+		Command:   "httpx",
+		OnFailure: &OnFailureConfig{Command: "curl", Args: []string{"-v", "https://{{domain}}"}},
+	}
+	tool := NewConfigurableTool("httpx", "recon", config, runner)
+
+	err := tool.Run(context.Background(), &Options{Domain: "example.com", WorkingDir: dir})
+	if err == nil || err.Error() != errToolExit.Error() || !errors.Is(err, errToolExit) {
+		t.Fatalf("the tool's error must come through unchanged, got %v", err)
+	}
+	var diagnosed *DiagnosedError
+	if !errors.As(err, &diagnosed) || diagnosed.Diagnostics != "httpx_failure_diagnostics.txt" {
+		t.Fatalf("error should carry the diagnostics file, got %#v", err)
+	}
+	if strings.Join(runner.gotArgs, " ") != "curl -v https://example.com" {
+		t.Fatalf("diagnostic ran %v", runner.gotArgs)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, diagnosed.Diagnostics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"$ curl -v https://example.com", "tool error: exit status 2", "502 Bad Gateway"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("diagnostics missing %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestOnFailureIsBoundedInTime(t *testing.T) {
+	dir := t.TempDir()
+	config := ToolConfig{
+		Name:      "dnsx",
+		Command:   "dnsx",
+		OnFailure: &OnFailureConfig{Command: "dig", Args: []string{"{{domain}}"}, Timeout: 50 * time.Millisecond},
+	}
+	tool := NewConfigurableTool("dnsx", "recon", config, &diagnosingRunner{})
+
+	start := time.Now()
+	err := tool.Run(context.Background(), &Options{Domain: "example.com", WorkingDir: dir})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("a hanging diagnostic held the tool for %s", elapsed)
+	}
+	if !errors.Is(err, errToolExit) {
+		t.Fatalf("a failed diagnostic must not replace the tool's error, got %v", err)
+	}
+
+	data, readErr := os.ReadFile(filepath.Join(dir, FailureDiagnosticsFile("dnsx")))
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	if !strings.Contains(string(data), "partial") || !strings.Contains(string(data), "[diagnostic failed after") {
+		t.Fatalf("diagnostics = %q", data)
+	}
+}
+
+func TestOnFailureValidation(t *testing.T) {
+	for _, onFailure := range []OnFailureConfig{
+		{},
+		{Command: "curl -v"},
+		{Command: "curl;rm"},
+		{Command: "curl", Args: []string{"$(id)"}},
+		{Command: "curl", Args: []string{"../../etc/passwd"}},
+		{Command: "curl", Timeout: -time.Second},
+	} {
+		config := ToolConfig{Name: "httpx", Command: "httpx", OnFailure: &onFailure}
+		if err := config.Validate(); err == nil {
+			t.Errorf("on_failure %+v should be rejected", onFailure)
+		}
+	}
+
+	config := ToolConfig{Name: "httpx", Command: "httpx", OnFailure: &OnFailureConfig{Command: "curl", Args: []string{"-v", "https://{{domain}}"}}}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("valid on_failure rejected: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dryRunPreview is how many replacement values a dry run shows command
+// lines for.
+const dryRunPreview = 3
+
+// dryRun reports the command lines the tool would run instead of running
+// them. A replacement tool shows its first dryRunPreview values and how
+// many there are; its values usually come from tools that have not run.
+func (t *ConfigurableTool) dryRun(args []string, options *Options) error {
+	if t.config.Replace == "" {
+		t.reportDryRun(commandLine(t.config.Command, args))
+		return nil
+	}
+
+	files, err := t.replacementFiles(options)
+	if err != nil {
+		return err
+	}
+	values, total := previewReplacementValues(commandDir(options), files, dryRunPreview)
+	if total == 0 {
+		t.reportDryRun(fmt.Sprintf("%s (once per value in %s, none yet)", commandLine(t.config.Command, args), strings.Join(files, ", ")))
+		return nil
+	}
+	for _, value := range values {
+		replaced := make([]string, len(args))
+		for i, arg := range args {
+			replaced[i] = strings.ReplaceAll(arg, t.config.Replace, value)
+		}
+		t.reportDryRun(commandLine(t.config.Command, replaced))
+	}
+	if total > len(values) {
+		t.reportDryRun(fmt.Sprintf("... and %d more, %d values in all", total-len(values), total))
+	}
+	return nil
+}
+
+func (t *ConfigurableTool) reportDryRun(line string) {
+	t.logger.WithTool(t.name, t.tool_type).Infof("Dry run: %s", line)
+	t.sendProgress(ProgressEvent{
+		Tool:      t.name,
+		Status:    ProgressDryRun,
+		Message:   line,
+		Timestamp: time.Now(),
+	})
+}
+
+// previewReplacementValues reads the distinct values in files the way the
+// replacement runner does, returning the first n and how many there are.
+// Missing files have no values.
+func previewReplacementValues(dir string, files []string, n int) ([]string, int) {
+	var first []string
+	seen := make(map[string]bool)
+	for _, file := range files {
+		if !filepath.IsAbs(file) && dir != "" {
+			file = filepath.Join(dir, file)
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") || seen[line] {
+				continue
+			}
+			seen[line] = true
+			if len(first) < n {
+				first = append(first, line)
+			}
+		}
+		f.Close()
+	}
+	return first, len(seen)
+}
+
+// commandLine quotes the arguments that would not survive a shell as they
+// are.
+func commandLine(command string, args []string) string {
+	parts := make([]string, 0, len(args)+1)
+	parts = append(parts, command)
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"$&;|<>*?`\\") {
+			arg = strconv.Quote(arg)
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
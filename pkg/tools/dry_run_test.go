@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"pipeliner/pkg/testutil"
+)
+
+func TestDryRun_WalksTheDAGWithoutRunningCommands(t *testing.T) {
+	dir := t.TempDir()
+	// left by an earlier scan; the dry run only reads it
+	values := "a.example.com\nb.example.com\n# comment\nb.example.com\nc.example.com\nd.example.com\ne.example.com\n"
+	if err := os.WriteFile(filepath.Join(dir, "hosts.txt"), []byte(values), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := testutil.NewMockCommandRunner()
+	chain := []Tool{
+		NewConfigurableTool("subfinder", "domain_enum", ToolConfig{
+			Name:    "subfinder",
+			Command: "subfinder",
+			Flags:   []FlagConfig{{Flag: "-d", Option: "Domain"}, {Flag: "-o", Default: "hosts.txt"}},
+		}, runner),
+		NewConfigurableTool("httpx", "recon", ToolConfig{
+			Name:        "httpx",
+			Command:     "httpx",
+			Flags:       []FlagConfig{{Flag: "-u", Default: "{host}"}, {Flag: "-o", Default: "{host}.txt"}},
+			Replace:     "{host}",
+			ReplaceFrom: []string{"hosts.txt"},
+			DependsOn:   []string{"subfinder"},
+		}, runner),
+	}
+
+	var mu sync.Mutex
+	completed := make(map[string]bool)
+	var lines []string
+	options := &Options{Domain: "example.com", WorkingDir: dir, DryRun: true, OnToolProgress: func(e ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch e.Status {
+		case "Completed":
+			completed[e.Tool] = true
+		case ProgressDryRun:
+			lines = append(lines, e.Message)
+		}
+	}}
+	if err := (&HybridStrategy{}).Run(context.Background(), chain, options); err != nil {
+		t.Fatal(err)
+	}
+
+	if executed := runner.GetExecutedCommands(); len(executed) != 0 {
+		t.Errorf("dry run executed %v", executed)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !completed["subfinder"] || !completed["httpx"] {
+		t.Errorf("completed = %v, want both tools", completed)
+	}
+	want := []string{
+		"subfinder -d example.com -o hosts.txt",
+		"httpx -u a.example.com -o a.example.com.txt",
+		"httpx -u b.example.com -o b.example.com.txt",
+		"httpx -u c.example.com -o c.example.com.txt",
+		"... and 2 more, 5 values in all",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("dry run lines:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"pipeliner/pkg/errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EmptyOutputReporter is implemented by tools that can tell whether their
+// last successful run left every declared output empty.
+type EmptyOutputReporter interface {
+	EmptyOutputs() []string
+}
+
+func (t *ConfigurableTool) EmptyOutputs() []string { return t.emptyOutputs }
+
+// reportsEmptyOutput is whether t's last run succeeded with nothing in it.
+func reportsEmptyOutput(t Tool) bool {
+	r, ok := t.(EmptyOutputReporter)
+	return ok && len(r.EmptyOutputs()) > 0
+}
+
+// checkOutputs looks at the declared outputs after a clean exit. When all
+// are empty the tool fails with fail_on_empty_output, and otherwise
+// completes with a ProgressCompletedEmpty warning.
+func (t *ConfigurableTool) checkOutputs(options *Options) error {
+	dir := getOutputDir(options)
+	declared := t.declaredOutputs(dir)
+	if len(declared) == 0 || anyNonEmpty(dir, declared) {
+		return nil
+	}
+
+	if t.config.FailOnEmptyOutput {
+		return errors.NewEmptyOutputError(t.name, declared)
+	}
+	t.emptyOutputs = declared
+	t.logger.WithTool(t.name, t.tool_type).Warnf("Tool %s exited cleanly but produced empty output: %s", t.name, strings.Join(declared, ", "))
+	reportProgress(options, ProgressEvent{
+		Tool:      t.name,
+		Status:    ProgressCompletedEmpty,
+		Message:   fmt.Sprintf("empty output: %s", strings.Join(declared, ", ")),
+		Timestamp: time.Now(),
+	})
+	return nil
+}
+
+// anyNonEmpty reports whether one of files, relative to dir, has content.
+func anyNonEmpty(dir string, files []string) bool {
+	for _, file := range files {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		if info, err := os.Stat(file); err == nil && info.Size() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// declaredOutputs are the files named by the output flags or, for
+// output_per_value runs, the files in the tool's manifest.
+func (t *ConfigurableTool) declaredOutputs(dir string) []string {
+	if t.config.OutputPerValue != "" {
+		manifest, err := ReadOutputManifest(filepath.Join(dir, OutputManifestFile(t.name)))
+		if err != nil {
+			return nil
+		}
+		var files []string
+		for _, file := range manifest.Outputs {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		return files
+	}
+
+	var files []string
+	for _, flag := range t.config.Flags {
+		if !t.isOutputFlag(flag) || flag.Default == "" {
+			continue
+		}
+		if t.config.Replace != "" && strings.Contains(flag.Default, t.config.Replace) {
+			continue
+		}
+		files = append(files, flag.Default)
+	}
+	return files
+}
@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	perrors "pipeliner/pkg/errors"
+)
+
+// contentRunner writes content to the file named by the -o argument.
+type contentRunner struct {
+	dir     string
+	content string
+}
+
+func (r *contentRunner) Run(ctx context.Context, command string, args []string) error {
+	for i, arg := range args {
+		if arg == "-o" && i+1 < len(args) {
+			return os.WriteFile(filepath.Join(r.dir, args[i+1]), []byte(r.content), 0644)
+		}
+	}
+	return nil
+}
+
+func emptyOutputTool(dir, content string, failOnEmpty bool) *ConfigurableTool {
+	config := ToolConfig{
+		Name:              "chaos",
+		Command:           "chaos",
+		Flags:             []FlagConfig{{Flag: "-o", Option: "Output", Default: "chaos.txt"}},
+		FailOnEmptyOutput: failOnEmpty,
+	}
+	return NewConfigurableTool("chaos", "domain_enum", config, &contentRunner{dir: dir, content: content}).(*ConfigurableTool)
+}
+
+func TestEmptyOutputIsAWarning(t *testing.T) {
+	dir := t.TempDir()
+	tool := emptyOutputTool(dir, "", false)
+
+	var events []ProgressEvent
+	options := &Options{WorkingDir: dir, OnProgress: func(e ProgressEvent) { events = append(events, e) }}
+	if err := tool.Run(context.Background(), options); err != nil {
+		t.Fatalf("empty output should not fail the tool: %v", err)
+	}
+	if got := tool.EmptyOutputs(); len(got) != 1 || got[0] != "chaos.txt" {
+		t.Fatalf("EmptyOutputs = %v", got)
+	}
+
+	found := false
+	for _, e := range events {
+		found = found || (e.Tool == "chaos" && e.Status == ProgressCompletedEmpty)
+	}
+	if !found {
+		t.Fatalf("no %s progress event in %+v", ProgressCompletedEmpty, events)
+	}
+}
+
+func TestEmptyOutputCanFailTheTool(t *testing.T) {
+	dir := t.TempDir()
+	tool := emptyOutputTool(dir, "", true)
+
+	err := tool.Run(context.Background(), &Options{WorkingDir: dir})
+	if !errors.Is(err, perrors.ErrEmptyOutput) {
+		t.Fatalf("err = %v, want ErrEmptyOutput", err)
+	}
+	if len(tool.EmptyOutputs()) != 0 {
+		t.Fatalf("a failed tool reports no empty outputs, got %v", tool.EmptyOutputs())
+	}
+}
+
+func TestNonEmptyOutputCompletes(t *testing.T) {
+	dir := t.TempDir()
+	tool := emptyOutputTool(dir, "a.example.com\n", true)
+
+	if err := tool.Run(context.Background(), &Options{WorkingDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if len(tool.EmptyOutputs()) != 0 {
+		t.Fatalf("empty outputs = %v", tool.EmptyOutputs())
+	}
+}
@@ -0,0 +1,13 @@
+package tools
+
+// EstimateTools picks the tools a workload estimate runs: those tagged
+// estimate, in module order. Validate keeps them from depending on others.
+func EstimateTools(configs []ToolConfig) []ToolConfig {
+	var estimate []ToolConfig
+	for _, config := range configs {
+		if config.Estimate {
+			estimate = append(estimate, config)
+		}
+	}
+	return estimate
+}
@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	// NoHeaderFlag as a tool's header_flag keeps http_headers off its command line.
+	NoHeaderFlag = "none"
+	// RedactedHeaderValue replaces the value of a redacted header in the scan record.
+	RedactedHeaderValue = "[redacted]"
+)
+
+// defaultHeaderFlags is how the known HTTP tools take a "Name: value" header.
+var defaultHeaderFlags = map[string]string{
+	"httpx":  "-H",
+	"nuclei": "-H",
+	"ffuf":   "-H",
+}
+
+var validHeaderName = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// headerFlag is the flag the tool takes headers with, or "" when it takes none.
+func (tc *ToolConfig) headerFlag() string {
+	switch tc.HeaderFlag {
+	case NoHeaderFlag:
+		return ""
+	case "":
+		return defaultHeaderFlags[filepath.Base(tc.Command)]
+	default:
+		return tc.HeaderFlag
+	}
+}
+
+// headerArgs passes every header in the tool's own syntax, sorted by name.
+func (tc *ToolConfig) headerArgs(headers map[string]string) ([]string, error) {
+	flag := tc.headerFlag()
+	if flag == "" || len(headers) == 0 {
+		return nil, nil
+	}
+	var args []string
+	for _, name := range sortedHeaderNames(headers) {
+		if err := validateHeader(name, headers[name]); err != nil {
+			return nil, err
+		}
+		args = append(args, flag, name+": "+headers[name])
+	}
+	return args, nil
+}
+
+func validateHeader(name, value string) error {
+	if !validHeaderName.MatchString(name) {
+		return fmt.Errorf("invalid header name %q", name)
+	}
+	if err := validateArgument(value); err != nil {
+		return fmt.Errorf("invalid value for header %s: %w", name, err)
+	}
+	return nil
+}
+
+func sortedHeaderNames(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MergeHeaders overlays override on base; neither is modified.
+func MergeHeaders(base, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for name, value := range base {
+		merged[name] = value
+	}
+	for name, value := range override {
+		merged[name] = value
+	}
+	return merged
+}
+
+// RedactHeaders returns headers with the values of the named ones, matched
+// case-insensitively, replaced by RedactedHeaderValue.
+func RedactHeaders(headers map[string]string, redact []string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	redacted := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if slices.ContainsFunc(redact, func(r string) bool { return strings.EqualFold(r, name) }) {
+			value = RedactedHeaderValue
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
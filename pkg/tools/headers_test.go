@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildArgs_InjectsHTTPHeaders(t *testing.T) {
+	options := &Options{HTTPHeaders: map[string]string{
+		"X-Bugbounty": "researcher-42",
+		"User-Agent":  "Mozilla/5.0 pipeliner/1.0",
+	}}
+
+	for _, tc := range []struct {
+		config ToolConfig
+		want   string
+	}{
+		{
+			config: ToolConfig{Name: "httpx", Command: "/usr/local/bin/httpx", Flags: []FlagConfig{{Flag: "-silent", IsBoolean: true}}},
+			want:   "-silent|-H|User-Agent: Mozilla/5.0 pipeliner/1.0|-H|X-Bugbounty: researcher-42",
+		},
+		{
+			config: ToolConfig{Name: "katana", Command: "katana", HeaderFlag: "--header"},
+			want:   "--header|User-Agent: Mozilla/5.0 pipeliner/1.0|--header|X-Bugbounty: researcher-42",
+		},
+		{
+			config: ToolConfig{Name: "nuclei", Command: "nuclei", HeaderFlag: NoHeaderFlag},
+			want:   "",
+		},
+		{
+			config: ToolConfig{Name: "subfinder", Command: "subfinder"},
+			want:   "",
+		},
+	} {
+		args, err := tc.config.BuildArgs(options)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.config.Name, err)
+		}
+		if got := strings.Join(args, "|"); got != tc.want {
+			t.Errorf("%s args = %q, want %q", tc.config.Name, got, tc.want)
+		}
+	}
+}
+
+func TestBuildArgs_RejectsUnsafeHeaders(t *testing.T) {
+	config := ToolConfig{Name: "ffuf", Command: "ffuf"}
+	for _, headers := range []map[string]string{
+		{"X-Id": "$(id)"},
+		{"X Id": "value"},
+	} {
+		if _, err := config.BuildArgs(&Options{HTTPHeaders: headers}); err == nil {
+			t.Errorf("headers %v should be rejected", headers)
+		}
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	headers := map[string]string{"authorization": "Bearer secret", "x-bugbounty": "researcher-42"}
+	got := RedactHeaders(headers, []string{"Authorization"})
+	if got["authorization"] != RedactedHeaderValue || got["x-bugbounty"] != "researcher-42" {
+		t.Fatalf("redacted = %v", got)
+	}
+	if headers["authorization"] != "Bearer secret" {
+		t.Fatal("RedactHeaders must not modify its input")
+	}
+}
@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"pipeliner/pkg/logger"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -14,6 +15,18 @@ type HookContext struct {
 	ToolConfig ToolConfig
 	Options    *Options
 	OtherData  map[string]interface{}
+	// StageStartedAt is when the stage's first tool started; it is only set
+	// for stage hooks.
+	StageStartedAt time.Time
+}
+
+// Context is the scan's context, cancelled when the scan stops or the hook
+// runs out of time.
+func (h HookContext) Context() context.Context {
+	if h.ctx == nil {
+		return context.Background()
+	}
+	return h.ctx
 }
 
 type PostHook interface {
@@ -22,6 +35,14 @@ type PostHook interface {
 	Execute(ctx HookContext) error
 }
 
+// PreHook runs before a tool starts, e.g. to fetch a wordlist it reads. A
+// failure keeps the tool from running and fails it.
+type PreHook interface {
+	Name() string
+	Description() string
+	Execute(ctx HookContext) error
+}
+
 type StageHook interface {
 	Name() string
 	Description() string
@@ -34,10 +55,90 @@ type Hook interface {
 	PostHook(ctx HookContext) error
 }
 
+// Criticality is optionally implemented by hooks to declare whether their
+// failure should fail the tool. Hooks without it are critical.
+type Criticality interface {
+	Critical() bool
+}
+
+// HookWarning describes a non-critical hook failure that was tolerated.
+type HookWarning struct {
+	Hook string
+	Tool string
+	Err  error
+}
+
+const (
+	HookScopeTool    = "tool"
+	HookScopePreTool = "pre_tool"
+	HookScopeStage   = "stage"
+	HookScopePreRun  = "pre_run"
+	HookScopeCleanup = "cleanup"
+
+	HookStatusSucceeded = "succeeded"
+	HookStatusFailed    = "failed"
+	HookStatusWarned    = "warned"
+	HookStatusMissing   = "missing"
+	HookStatusPlanned   = "planned"
+)
+
+// HookExecution describes one hook run (or, with HookStatusPlanned, one that
+// would run) against a tool or a stage.
+type HookExecution struct {
+	Hook       string
+	Scope      string
+	Target     string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Status     string
+	Err        error
+}
+
+func reportHookExecution(options *Options, exec HookExecution) {
+	if options != nil && options.OnHookExecution != nil {
+		options.OnHookExecution(exec)
+	}
+}
+
+// PlanHookExecutions lists the pre, post and stage hooks a run of tools
+// would trigger, without executing anything.
+func PlanHookExecutions(tools []Tool, options *Options) []HookExecution {
+	var planned []HookExecution
+	stages := make(map[Stage]bool)
+
+	for _, t := range tools {
+		for _, name := range t.PreHooks() {
+			status := HookStatusPlanned
+			if hook, _ := resolvePreHook(name, options); hook == nil {
+				status = HookStatusMissing
+			}
+			planned = append(planned, HookExecution{Hook: name, Scope: HookScopePreTool, Target: t.Name(), Status: status})
+		}
+		for _, name := range t.PostHooks() {
+			status := HookStatusPlanned
+			if hook, _ := resolvePostHook(name, options); hook == nil {
+				status = HookStatusMissing
+			}
+			planned = append(planned, HookExecution{Hook: name, Scope: HookScopeTool, Target: t.Name(), Status: status})
+		}
+		if stage := stageForToolType(t.Type()); stage != "" && !stages[stage] {
+			stages[stage] = true
+			for _, h := range stageHooksFor(stage, options) {
+				planned = append(planned, HookExecution{Hook: h.Name(), Scope: HookScopeStage, Target: string(stage), Status: HookStatusPlanned})
+			}
+		}
+	}
+	return planned
+}
+
 type PostHookInfo struct {
 	Name        string
 	Description string
 	Hook        PostHook
+	Critical    bool
+	// Pre is set for hooks registered with RegisterPreHook, which run
+	// before their tool.
+	Pre bool
 }
 
 type StageHookInfo struct {
@@ -47,6 +148,7 @@ type StageHookInfo struct {
 }
 
 var (
+	preHookRegistry    = make(map[string]*PostHookInfo)
 	postHookRegistry   = make(map[string]*PostHookInfo)
 	legacyHookRegistry = make(map[string]*PostHookInfo)
 	hookLogger         = logger.NewLogger(logrus.InfoLevel)
@@ -60,13 +162,78 @@ func RegisterPostHook(name string, hook PostHook) {
 		Name:        name,
 		Description: hook.Description(),
 		Hook:        hook,
+		Critical:    isCritical(hook),
 	}
 	hookLogger.WithFields(logger.Fields{
 		"hook":        name,
 		"description": hook.Description(),
+		"critical":    postHookRegistry[name].Critical,
 	}).Info("Registered post hook")
 }
 
+func RegisterPreHook(name string, hook PreHook) {
+	if _, exists := preHookRegistry[name]; exists {
+		hookLogger.WithFields(logger.Fields{"hook": name}).Warn("PreHook already registered, overwriting")
+	}
+	preHookRegistry[name] = &PostHookInfo{
+		Name:        name,
+		Description: hook.Description(),
+		Hook:        hook,
+		Critical:    isCritical(hook),
+		Pre:         true,
+	}
+	hookLogger.WithFields(logger.Fields{
+		"hook":        name,
+		"description": hook.Description(),
+		"critical":    preHookRegistry[name].Critical,
+	}).Info("Registered pre hook")
+}
+
+func GetPreHook(name string) PreHook {
+	if hookInfo, exists := preHookRegistry[name]; exists {
+		return hookInfo.Hook
+	}
+	return nil
+}
+
+// IsPreHookCritical reports whether a failure of the named pre hook keeps
+// its tool from running. Unknown hooks are treated as critical.
+func IsPreHookCritical(name string) bool {
+	if hookInfo, exists := preHookRegistry[name]; exists {
+		return hookInfo.Critical
+	}
+	return true
+}
+
+// SetPostHookCritical overrides the criticality of a registered hook.
+func SetPostHookCritical(name string, critical bool) {
+	if hookInfo, exists := postHookRegistry[name]; exists {
+		hookInfo.Critical = critical
+	}
+	if hookInfo, exists := legacyHookRegistry[name]; exists {
+		hookInfo.Critical = critical
+	}
+}
+
+// IsPostHookCritical reports whether a failure of the named hook fails its
+// tool. Unknown hooks are treated as critical.
+func IsPostHookCritical(name string) bool {
+	if hookInfo, exists := postHookRegistry[name]; exists {
+		return hookInfo.Critical
+	}
+	if hookInfo, exists := legacyHookRegistry[name]; exists {
+		return hookInfo.Critical
+	}
+	return true
+}
+
+func isCritical(hook interface{}) bool {
+	if c, ok := hook.(Criticality); ok {
+		return c.Critical()
+	}
+	return true
+}
+
 func GetPostHook(name string) PostHook {
 	if hookInfo, exists := postHookRegistry[name]; exists {
 		return hookInfo.Hook
@@ -87,6 +254,7 @@ func RegisterHook(name string, hook Hook) {
 		Name:        name,
 		Description: hook.Description(),
 		Hook:        wrapper,
+		Critical:    isCritical(hook),
 	}
 	hookLogger.WithFields(logger.Fields{
 		"hook":        name,
@@ -120,7 +288,11 @@ func (w *legacyHookWrapper) Execute(ctx HookContext) error {
 }
 
 func ListAvailableHooks() []PostHookInfo {
-	allHooks := make([]PostHookInfo, 0, len(postHookRegistry)+len(legacyHookRegistry))
+	allHooks := make([]PostHookInfo, 0, len(preHookRegistry)+len(postHookRegistry)+len(legacyHookRegistry))
+
+	for _, hookInfo := range preHookRegistry {
+		allHooks = append(allHooks, *hookInfo)
+	}
 
 	for _, hookInfo := range postHookRegistry {
 		allHooks = append(allHooks, *hookInfo)
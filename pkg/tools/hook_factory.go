@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"pipeliner/pkg/logger"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HookFactory builds a stage hook from the config of a module's hooks
+// entry. It returns an error for config it does not accept, which fails
+// the module's validation.
+type HookFactory func(config map[string]any) (StageHook, error)
+
+var hookFactories = make(map[string]HookFactory)
+
+// RegisterHookFactory makes name usable in the hooks section of modules.
+func RegisterHookFactory(name string, factory HookFactory) {
+	if _, exists := hookFactories[name]; exists {
+		hookLogger.WithFields(logger.Fields{"hook": name}).Warn("Hook factory already registered, overwriting")
+	}
+	hookFactories[name] = factory
+}
+
+// HookFactoryNames lists the registered hook factories.
+func HookFactoryNames() []string {
+	names := make([]string, 0, len(hookFactories))
+	for name := range hookFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HookConfig is an entry of a module's hooks section: a stage hook built
+// by the factory registered as Name, run when Stage completes.
+type HookConfig struct {
+	Name   string         `yaml:"name" mapstructure:"name"`
+	Stage  Stage          `yaml:"stage" mapstructure:"stage"`
+	Config map[string]any `yaml:"config,omitempty" mapstructure:"config"`
+}
+
+// build checks the entry and returns the hook its factory makes of it.
+func (hc HookConfig) build() (StageHook, error) {
+	if hc.Name == "" {
+		return nil, fmt.Errorf("hook name is required")
+	}
+	if !knownStages[hc.Stage] {
+		return nil, fmt.Errorf("unknown stage %q for hook %s", hc.Stage, hc.Name)
+	}
+	factory, ok := hookFactories[hc.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown hook %s", hc.Name)
+	}
+	hook, err := factory(hc.Config)
+	if err != nil {
+		return nil, fmt.Errorf("hook %s: %w", hc.Name, err)
+	}
+	// stageHooksFor replaces registered hooks by name
+	if hook.Name() != hc.Name {
+		return nil, fmt.Errorf("hook factory %s built a hook named %s", hc.Name, hook.Name())
+	}
+	return hook, nil
+}
+
+// NewConfiguredHooks builds the module's hooks by stage, for
+// Options.StageHooks.
+func NewConfiguredHooks(configs []HookConfig) (map[Stage][]StageHook, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	hooks := make(map[Stage][]StageHook)
+	for _, hc := range configs {
+		hook, err := hc.build()
+		if err != nil {
+			return nil, err
+		}
+		hooks[hc.Stage] = append(hooks[hc.Stage], hook)
+	}
+	return hooks, nil
+}
+
+// DecodeHookConfig decodes a hooks entry's config into out by its yaml
+// tags. Keys out has no field for are an error.
+func DecodeHookConfig(config map[string]any, out any) error {
+	if len(config) == 0 {
+		return nil
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(out); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
+
+// stageHooksFor returns the hooks to run when stage completes: the
+// module's, and the registered ones it has no hook of the same name for.
+func stageHooksFor(stage Stage, options *Options) []StageHook {
+	registered := GetStageHooks(stage)
+	if options == nil || len(options.StageHooks[stage]) == 0 {
+		return registered
+	}
+	configured := options.StageHooks[stage]
+	replaced := make(map[string]bool, len(configured))
+	for _, hook := range configured {
+		replaced[hook.Name()] = true
+	}
+	hooks := make([]StageHook, 0, len(registered)+len(configured))
+	for _, hook := range registered {
+		if !replaced[hook.Name()] {
+			hooks = append(hooks, hook)
+		}
+	}
+	return append(hooks, configured...)
+}
@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// namedStageHook records its name, and its label if it has one, when it
+// runs.
+type namedStageHook struct {
+	name  string
+	label string
+	mu    *sync.Mutex
+	ran   *[]string
+}
+
+func (h namedStageHook) Name() string        { return h.name }
+func (h namedStageHook) Description() string { return "records that it ran" }
+func (h namedStageHook) ExecuteForStage(HookContext) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*h.ran = append(*h.ran, strings.TrimSuffix(h.name+":"+h.label, ":"))
+	return nil
+}
+
+func registerTestHookFactory(t *testing.T, name string, build func(map[string]any) (StageHook, error)) {
+	t.Helper()
+	saved, existed := hookFactories[name]
+	t.Cleanup(func() {
+		if existed {
+			hookFactories[name] = saved
+		} else {
+			delete(hookFactories, name)
+		}
+	})
+	RegisterHookFactory(name, build)
+}
+
+func TestChainConfig_ValidateHooks(t *testing.T) {
+	registerTestHookFactory(t, "labelled", func(config map[string]any) (StageHook, error) {
+		var cfg struct {
+			Label string `yaml:"label"`
+		}
+		if err := DecodeHookConfig(config, &cfg); err != nil {
+			return nil, err
+		}
+		if cfg.Label == "" {
+			return nil, fmt.Errorf("label is required")
+		}
+		return namedStageHook{name: "labelled", label: cfg.Label}, nil
+	})
+	registerTestHookFactory(t, "misnamed", func(map[string]any) (StageHook, error) {
+		return namedStageHook{name: "labelled"}, nil
+	})
+
+	base := ChainConfig{
+		ExecutionMode: "sequential",
+		Tools:         []ToolConfig{{Name: "subfinder", Command: "subfinder"}},
+	}
+	tests := []struct {
+		name string
+		hook HookConfig
+		err  string
+	}{
+		{name: "valid", hook: HookConfig{Name: "labelled", Stage: StageVuln, Config: map[string]any{"label": "nuclei"}}},
+		{name: "unknown hook", hook: HookConfig{Name: "missing", Stage: StageVuln}, err: "unknown hook missing"},
+		{name: "no name", hook: HookConfig{Stage: StageVuln}, err: "hook name is required"},
+		{name: "unknown stage", hook: HookConfig{Name: "labelled", Stage: "exploit", Config: map[string]any{"label": "x"}}, err: `unknown stage "exploit"`},
+		{name: "unknown config key", hook: HookConfig{Name: "labelled", Stage: StageVuln, Config: map[string]any{"label": "x", "colour": "red"}}, err: "field colour not found"},
+		{name: "rejected config", hook: HookConfig{Name: "labelled", Stage: StageVuln}, err: "hook labelled: label is required"},
+		{name: "hook not named after its factory", hook: HookConfig{Name: "misnamed", Stage: StageVuln}, err: "hook factory misnamed built a hook named labelled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := base
+			config.Hooks = []HookConfig{tt.hook}
+			err := config.Validate()
+			if tt.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestExecuteStageHooks_ConfiguredHooksReplaceRegisteredOnes(t *testing.T) {
+	var mu sync.Mutex
+	var ran []string
+	registerTestHookFactory(t, "combine", func(config map[string]any) (StageHook, error) {
+		return namedStageHook{name: "combine", label: "configured", mu: &mu, ran: &ran}, nil
+	})
+
+	saved := stageHooks[StageSubdomain]
+	t.Cleanup(func() { stageHooks[StageSubdomain] = saved })
+	stageHooks[StageSubdomain] = nil
+	RegisterStageHook(StageSubdomain, namedStageHook{name: "combine", mu: &mu, ran: &ran})
+	RegisterStageHook(StageSubdomain, namedStageHook{name: "summary", mu: &mu, ran: &ran})
+
+	configured, err := NewConfiguredHooks([]HookConfig{{Name: "combine", Stage: StageSubdomain}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without the module's hooks the registered ones run
+	if err := executeStageHooks(context.Background(), StageSubdomain, string(StageSubdomain), time.Time{}, &Options{}); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	got := slices.Sorted(slices.Values(ran))
+	ran = nil
+	mu.Unlock()
+	if want := []string{"combine", "summary"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ran %v, want %v", got, want)
+	}
+
+	options := &Options{StageHooks: configured}
+	if err := executeStageHooks(context.Background(), StageSubdomain, string(StageSubdomain), time.Time{}, options); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"combine:configured", "summary"}; !reflect.DeepEqual(slices.Sorted(slices.Values(ran)), want) {
+		t.Fatalf("ran %v, want %v", ran, want)
+	}
+
+	planned := PlanHookExecutions([]Tool{NewMockTool("subfinder", "domain_enum", nil)}, options)
+	if len(planned) != 2 {
+		t.Fatalf("planned %d stage hooks, want 2", len(planned))
+	}
+}
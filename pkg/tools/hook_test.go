@@ -0,0 +1,220 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pipeliner/pkg/testutil"
+)
+
+type failingHook struct {
+	name     string
+	critical bool
+}
+
+func (h *failingHook) Name() string                  { return h.name }
+func (h *failingHook) Description() string           { return "always fails" }
+func (h *failingHook) Critical() bool                { return h.critical }
+func (h *failingHook) Execute(ctx HookContext) error { return fmt.Errorf("%s unavailable", h.name) }
+
+type plainHook struct{}
+
+func (h *plainHook) Name() string                  { return "plain" }
+func (h *plainHook) Description() string           { return "declares no criticality" }
+func (h *plainHook) Execute(ctx HookContext) error { return nil }
+
+func TestPostHookCriticality(t *testing.T) {
+	RegisterPostHook("test-critical-hook", &failingHook{name: "test-critical-hook", critical: true})
+	RegisterPostHook("test-noncritical-hook", &failingHook{name: "test-noncritical-hook", critical: false})
+
+	strategies := map[string]ExecutionStrategy{
+		"sequential": &SequentialStrategy{},
+		"concurrent": &ConcurrentStrategy{},
+		"hybrid":     &HybridStrategy{},
+	}
+
+	for name, strategy := range strategies {
+		t.Run(name+"/non-critical failure only warns", func(t *testing.T) {
+			ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+			defer cancel()
+
+			nuclei := NewMockTool("nuclei", "vuln", nil)
+			nuclei.postHooks = []string{"test-noncritical-hook"}
+			report := NewMockTool("report", "recon", []string{"nuclei"})
+
+			var mu sync.Mutex
+			var warnings []HookWarning
+			options := &Options{OnHookWarning: func(w HookWarning) {
+				mu.Lock()
+				defer mu.Unlock()
+				warnings = append(warnings, w)
+			}}
+
+			err := strategy.Run(ctx, []Tool{nuclei, report}, options)
+			testutil.AssertNoError(t, err)
+			testutil.AssertEquals(t, 1, report.GetRunCount())
+			testutil.AssertEquals(t, 1, len(warnings))
+			testutil.AssertEquals(t, "test-noncritical-hook", warnings[0].Hook)
+			testutil.AssertEquals(t, "nuclei", warnings[0].Tool)
+		})
+
+		t.Run(name+"/critical failure fails the tool", func(t *testing.T) {
+			ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+			defer cancel()
+
+			nuclei := NewMockTool("nuclei", "vuln", nil)
+			nuclei.postHooks = []string{"test-critical-hook"}
+
+			err := strategy.Run(ctx, []Tool{nuclei}, &Options{})
+
+			var partial *PartialExecutionError
+			if !errors.As(err, &partial) {
+				t.Fatalf("expected PartialExecutionError, got %v", err)
+			}
+			testutil.AssertEquals(t, 1, len(partial.FailedTools))
+			testutil.AssertEquals(t, "nuclei", partial.FailedTools[0].Tool)
+		})
+	}
+}
+
+func TestIsPostHookCritical_Defaults(t *testing.T) {
+	RegisterPostHook("test-default-hook", &plainHook{})
+
+	testutil.AssertEquals(t, true, IsPostHookCritical("test-default-hook"))
+	testutil.AssertEquals(t, true, IsPostHookCritical("test-unregistered-hook"))
+
+	SetPostHookCritical("test-default-hook", false)
+	testutil.AssertEquals(t, false, IsPostHookCritical("test-default-hook"))
+}
+
+func TestPostHookExecutionsReported(t *testing.T) {
+	RegisterPostHook("test-reported-hook", &plainHook{})
+	RegisterPostHook("test-reported-warn-hook", &failingHook{name: "test-reported-warn-hook", critical: false})
+
+	ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+	defer cancel()
+
+	nuclei := NewMockTool("nuclei", "vuln", nil)
+	nuclei.postHooks = []string{"test-reported-hook", "test-reported-warn-hook", "test-unregistered-hook"}
+
+	var execs []HookExecution
+	options := &Options{OnHookExecution: func(e HookExecution) { execs = append(execs, e) }}
+
+	testutil.AssertNoError(t, (&SequentialStrategy{}).Run(ctx, []Tool{nuclei}, options))
+
+	statuses := make(map[string]string)
+	for _, e := range execs {
+		if e.Scope == HookScopeTool {
+			testutil.AssertEquals(t, "nuclei", e.Target)
+			statuses[e.Hook] = e.Status
+		}
+	}
+	testutil.AssertEquals(t, HookStatusSucceeded, statuses["test-reported-hook"])
+	testutil.AssertEquals(t, HookStatusWarned, statuses["test-reported-warn-hook"])
+	testutil.AssertEquals(t, HookStatusMissing, statuses["test-unregistered-hook"])
+
+	planned := PlanHookExecutions([]Tool{nuclei}, nil)
+	testutil.AssertEquals(t, HookStatusPlanned, planned[0].Status)
+	testutil.AssertEquals(t, HookStatusMissing, planned[2].Status)
+}
+
+type orderHook struct {
+	mu    *sync.Mutex
+	order *[]string
+}
+
+func (h *orderHook) Name() string        { return "order" }
+func (h *orderHook) Description() string { return "records when it runs" }
+func (h *orderHook) Execute(ctx HookContext) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*h.order = append(*h.order, "pre:"+ctx.ToolName)
+	return nil
+}
+
+func TestPreHooks(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	RegisterPreHook("test-order-pre-hook", &orderHook{mu: &mu, order: &order})
+	RegisterPreHook("test-failing-pre-hook", &failingHook{name: "test-failing-pre-hook", critical: true})
+
+	strategies := map[string]ExecutionStrategy{
+		"sequential": &SequentialStrategy{},
+		"concurrent": &ConcurrentStrategy{},
+		"hybrid":     &HybridStrategy{},
+	}
+
+	for name, strategy := range strategies {
+		t.Run(name+"/runs before the tool", func(t *testing.T) {
+			ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+			defer cancel()
+			order = nil
+
+			ffuf := NewMockTool("ffuf", "recon", nil)
+			ffuf.preHooks = []string{"test-order-pre-hook"}
+			ffuf.SetRunFunc(func(context.Context, *Options) error {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, "run:ffuf")
+				return nil
+			})
+
+			testutil.AssertNoError(t, strategy.Run(ctx, []Tool{ffuf}, &Options{}))
+			testutil.AssertEquals(t, "pre:ffuf,run:ffuf", strings.Join(order, ","))
+		})
+
+		t.Run(name+"/failure skips the tool", func(t *testing.T) {
+			ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+			defer cancel()
+
+			ffuf := NewMockTool("ffuf", "recon", nil)
+			ffuf.preHooks = []string{"test-failing-pre-hook"}
+			httpx := NewMockTool("httpx", "recon", nil)
+
+			var execs []HookExecution
+			var execMu sync.Mutex
+			options := &Options{OnHookExecution: func(e HookExecution) {
+				execMu.Lock()
+				defer execMu.Unlock()
+				execs = append(execs, e)
+			}}
+			err := strategy.Run(ctx, []Tool{ffuf, httpx}, options)
+
+			var partial *PartialExecutionError
+			if !errors.As(err, &partial) {
+				t.Fatalf("expected PartialExecutionError, got %v", err)
+			}
+			testutil.AssertEquals(t, 1, len(partial.FailedTools))
+			testutil.AssertEquals(t, "ffuf", partial.FailedTools[0].Tool)
+			if msg := partial.FailedTools[0].Err.Error(); !strings.Contains(msg, "pre hook test-failing-pre-hook failed") {
+				t.Errorf("unexpected error %q", msg)
+			}
+			testutil.AssertEquals(t, 0, ffuf.GetRunCount())
+			testutil.AssertEquals(t, 1, httpx.GetRunCount())
+
+			testutil.AssertEquals(t, 1, len(execs))
+			testutil.AssertEquals(t, HookScopePreTool, execs[0].Scope)
+			testutil.AssertEquals(t, HookStatusFailed, execs[0].Status)
+		})
+	}
+
+	ffuf := NewMockTool("ffuf", "recon", nil)
+	ffuf.preHooks = []string{"test-order-pre-hook", "test-unregistered-hook"}
+	planned := PlanHookExecutions([]Tool{ffuf}, nil)
+	testutil.AssertEquals(t, HookScopePreTool, planned[0].Scope)
+	testutil.AssertEquals(t, HookStatusPlanned, planned[0].Status)
+	testutil.AssertEquals(t, HookStatusMissing, planned[1].Status)
+
+	listed := false
+	for _, h := range ListAvailableHooks() {
+		if h.Name == "test-order-pre-hook" {
+			listed = h.Pre
+		}
+	}
+	testutil.AssertEquals(t, true, listed)
+}
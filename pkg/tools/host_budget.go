@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"context"
+	"pipeliner/pkg/hostlimit"
+	"strings"
+)
+
+const hostBudgetKey contextKey = "host_budget"
+
+// hostBudget is the scan a command runs for, as the per-host limiter
+// knows it.
+type hostBudget struct {
+	limiter *hostlimit.Limiter
+	scan    string
+	// domain is the key of the scan's target, for values that are not hosts.
+	domain string
+}
+
+func withHostBudget(ctx context.Context, options *Options) context.Context {
+	if options == nil {
+		return ctx
+	}
+	limiter := options.HostLimiter
+	if limiter == nil {
+		limiter = hostlimit.Global()
+	}
+	scan := options.ScanID
+	if scan == "" {
+		scan = options.WorkingDir
+	}
+	return context.WithValue(ctx, hostBudgetKey, &hostBudget{limiter: limiter, scan: scan, domain: hostlimit.Key(options.Domain)})
+}
+
+// WaitForHost holds back a command run for value until the host value
+// targets, or else the scan's domain, is within the budget shared by all
+// scans. Runners call it before each replacement value.
+func WaitForHost(ctx context.Context, value string) error {
+	b, ok := ctx.Value(hostBudgetKey).(*hostBudget)
+	if !ok {
+		return nil
+	}
+	host := hostlimit.Key(value)
+	if host == "" {
+		host = b.domain
+	}
+	if host == "" {
+		return nil
+	}
+	return b.limiter.Wait(ctx, b.scan, host)
+}
+
+// noteHostActivity records a command that sends its own requests to the
+// scan's domain. It cannot be held back, so overlapping scans only get a
+// warning.
+func (t *ConfigurableTool) noteHostActivity(ctx context.Context) {
+	b, ok := ctx.Value(hostBudgetKey).(*hostBudget)
+	if !ok || b.domain == "" {
+		return
+	}
+	if others := b.limiter.Note(b.scan, b.domain); len(others) > 0 {
+		t.logger.WithTool(t.name, t.tool_type).Warnf("%s cannot be rate limited and other scans are also sending requests to %s: %s", t.name, b.domain, strings.Join(others, ", "))
+	}
+}
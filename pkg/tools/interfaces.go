@@ -7,6 +7,7 @@ type Tool interface {
 	Type() string
 	Run(ctx context.Context, options *Options) error
 	DependsOn() []string
+	PreHooks() []string
 	PostHooks() []string
 }
 
@@ -14,9 +15,60 @@ type CommandRunner interface {
 	Run(ctx context.Context, command string, args []string) error
 }
 
+// DirCommandRunner is implemented by runners that can start a command in a
+// given working directory.
+type DirCommandRunner interface {
+	RunInDir(ctx context.Context, dir, command string, args []string) error
+}
+
+// RunIn runs command in dir when runner supports it, and with Run
+// otherwise. An empty dir is the process's working directory.
+func RunIn(ctx context.Context, runner CommandRunner, dir, command string, args []string) error {
+	if dirRunner, ok := runner.(DirCommandRunner); ok {
+		return dirRunner.RunInDir(ctx, dir, command, args)
+	}
+	return runner.Run(ctx, command, args)
+}
+
+// OutputCommandRunner is implemented by runners that can hand back the
+// output of a command run in dir, as on_failure diagnostics need.
+type OutputCommandRunner interface {
+	Output(ctx context.Context, dir, command string, args []string) ([]byte, error)
+}
+
 type ReplacementCommandRunner interface {
 	CommandRunner
 	RunWithReplacement(ctx context.Context, command string, args []string, replaceToken, replaceFromFile string) error
+	RunWithReplacementSpec(ctx context.Context, command string, args []string, spec ReplacementSpec) error
+}
+
+// ReplacementSpec describes a replacement run: Token in the args is replaced
+// by each value read from Files.
+type ReplacementSpec struct {
+	Token string
+	Files []string
+	// Dir is where the commands run; relative Files and ManifestPath are
+	// resolved in it.
+	Dir string
+	// Concurrency is how many values run at once; 0 or 1 runs them one
+	// after another.
+	Concurrency int
+
+	// OutputTemplate, when set, is rendered per value and substituted for
+	// OutputPlaceholder; the value to file mapping is written to ManifestPath.
+	OutputTemplate string
+	ManifestPath   string
+	Tool           string
+	Stage          Stage
+
+	// ChunkSize, when set, runs the values in chunks of this many. After
+	// each chunk the manifest and a ChunkCheckpoint are written to
+	// CheckpointPath and OnChunk, if set, is called. With ResumeChunks the
+	// run skips the values the checkpoint has done.
+	ChunkSize      int
+	CheckpointPath string
+	ResumeChunks   bool
+	OnChunk        func(ChunkProgress)
 }
 
 type ToolRegistry interface {
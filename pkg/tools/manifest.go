@@ -0,0 +1,75 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// OutputPlaceholder marks the argument a replacement run swaps for the
+// per-value output file rendered from ToolConfig.OutputPerValue.
+const OutputPlaceholder = "{{output}}"
+
+// Variables available in an output_per_value template.
+const (
+	outputVarValue          = "{{value}}"
+	outputVarValueSanitized = "{{value_sanitized}}"
+	outputVarIndex          = "{{index}}"
+)
+
+// OutputManifest records the files a tool wrote: Outputs maps each
+// replacement value to its output_per_value file, Files lists the outputs of
+// a tool run once.
+type OutputManifest struct {
+	Tool    string            `json:"tool"`
+	Stage   Stage             `json:"stage,omitempty"`
+	Outputs map[string]string `json:"outputs,omitempty"`
+	Files   []string          `json:"files,omitempty"`
+}
+
+// OutputManifestFile is the name of the manifest a tool writes in its working directory.
+func OutputManifestFile(toolName string) string {
+	return fmt.Sprintf("%s_manifest.json", toolName)
+}
+
+// RenderOutputName fills an output_per_value template for one value.
+func RenderOutputName(template, value, sanitized string, index int) string {
+	return strings.NewReplacer(
+		outputVarValueSanitized, sanitized,
+		outputVarValue, value,
+		outputVarIndex, fmt.Sprintf("%d", index),
+	).Replace(template)
+}
+
+func validateOutputTemplate(template string) error {
+	for _, v := range []string{outputVarValue, outputVarValueSanitized, outputVarIndex} {
+		if strings.Contains(template, v) {
+			return nil
+		}
+	}
+	return fmt.Errorf("output_per_value must reference %s, %s or %s so each value gets its own file", outputVarValueSanitized, outputVarValue, outputVarIndex)
+}
+
+func WriteOutputManifest(path string, manifest *OutputManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode output manifest: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write output manifest %s: %w", path, err)
+	}
+	return nil
+}
+
+func ReadOutputManifest(path string) (*OutputManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest OutputManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse output manifest %s: %w", path, err)
+	}
+	return &manifest, nil
+}
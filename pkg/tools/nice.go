@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const niceKey contextKey = "nice"
+
+// Niceness bounds of the scheduler.
+const (
+	MinNice = -20
+	MaxNice = 19
+)
+
+// NicePolicy maps a scan's priority onto the niceness its tools run at, so
+// that a high-priority scan gets more CPU and I/O than the periodic scans
+// running beside it.
+type NicePolicy struct {
+	// Levels maps priorities to niceness. A priority takes the niceness of
+	// the highest listed priority at or below it, or of the lowest listed
+	// one if it is below them all.
+	Levels map[int]int
+	// Floor and Ceiling bound the niceness. Going below the server's own
+	// niceness needs CAP_SYS_NICE; without it tools keep the server's.
+	Floor   int
+	Ceiling int
+}
+
+// DefaultNicePolicy has no levels, which leaves every tool at the server's
+// niceness, and keeps tools from being raised above it.
+func DefaultNicePolicy() NicePolicy {
+	return NicePolicy{Floor: 0, Ceiling: MaxNice}
+}
+
+func (p NicePolicy) Validate() error {
+	if p.Floor < MinNice || p.Ceiling > MaxNice || p.Floor > p.Ceiling {
+		return fmt.Errorf("nice floor %d and ceiling %d must satisfy %d <= floor <= ceiling <= %d", p.Floor, p.Ceiling, MinNice, MaxNice)
+	}
+	for priority, nice := range p.Levels {
+		if nice < MinNice || nice > MaxNice {
+			return fmt.Errorf("niceness %d for priority %d is outside %d..%d", nice, priority, MinNice, MaxNice)
+		}
+	}
+	return nil
+}
+
+// Nice is the niceness tools of a scan with priority run at, or false if
+// the policy has no levels.
+func (p NicePolicy) Nice(priority int) (int, bool) {
+	if len(p.Levels) == 0 {
+		return 0, false
+	}
+	priorities := make([]int, 0, len(p.Levels))
+	for level := range p.Levels {
+		priorities = append(priorities, level)
+	}
+	sort.Ints(priorities)
+
+	nice := p.Levels[priorities[0]]
+	for _, level := range priorities {
+		if level > priority {
+			break
+		}
+		nice = p.Levels[level]
+	}
+	return min(max(nice, p.Floor), p.Ceiling), true
+}
+
+// ParseNiceLevels reads levels written as priority=nice pairs separated by
+// commas, e.g. "10=-5,0=5,-10=15".
+func ParseNiceLevels(s string) (map[int]int, error) {
+	levels := make(map[int]int)
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		priority, nice, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("nice level %q is not priority=nice", pair)
+		}
+		p, err := strconv.Atoi(strings.TrimSpace(priority))
+		if err != nil {
+			return nil, fmt.Errorf("nice level %q: invalid priority: %w", pair, err)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(nice))
+		if err != nil {
+			return nil, fmt.Errorf("nice level %q: invalid niceness: %w", pair, err)
+		}
+		levels[p] = n
+	}
+	return levels, nil
+}
+
+// IOPriority is the best-effort I/O priority, 0 (highest) to 7, that goes
+// with a niceness, as the kernel derives it for processes without one.
+func IOPriority(nice int) int {
+	return (min(max(nice, MinNice), MaxNice) + 20) / 5
+}
+
+// niceFor is the niceness the scan options ask tools to run at.
+func niceFor(options *Options) (int, bool) {
+	if options == nil || options.NicePolicy == nil {
+		return 0, false
+	}
+	return options.NicePolicy.Nice(options.Priority)
+}
+
+func withNice(ctx context.Context, nice int) context.Context {
+	return context.WithValue(ctx, niceKey, nice)
+}
+
+// NiceFromContext is the niceness the command running in ctx should start
+// with, or false to keep the server's.
+func NiceFromContext(ctx context.Context) (int, bool) {
+	nice, ok := ctx.Value(niceKey).(int)
+	return nice, ok
+}
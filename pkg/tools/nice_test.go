@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNicePolicy_Nice(t *testing.T) {
+	policy := NicePolicy{Levels: map[int]int{10: -5, 0: 5, -10: 15}, Floor: 0, Ceiling: 10}
+
+	for priority, want := range map[int]int{
+		20:  0, // -5, raised to the floor
+		10:  0,
+		5:   5,
+		0:   5,
+		-5:  10, // 15, lowered to the ceiling
+		-50: 10,
+	} {
+		nice, ok := policy.Nice(priority)
+		assert.True(t, ok)
+		assert.Equal(t, want, nice, "priority %d", priority)
+	}
+
+	_, ok := DefaultNicePolicy().Nice(10)
+	assert.False(t, ok)
+}
+
+func TestParseNiceLevels(t *testing.T) {
+	levels, err := ParseNiceLevels(" 10=-5, 0=5 ,-10=15,")
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{10: -5, 0: 5, -10: 15}, levels)
+
+	for _, bad := range []string{"10", "high=5", "10=low"} {
+		_, err := ParseNiceLevels(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestNicePolicy_Validate(t *testing.T) {
+	assert.NoError(t, DefaultNicePolicy().Validate())
+	assert.NoError(t, NicePolicy{Levels: map[int]int{1: -20}, Floor: -20, Ceiling: 19}.Validate())
+	assert.Error(t, NicePolicy{Floor: 5, Ceiling: 0}.Validate())
+	assert.Error(t, NicePolicy{Floor: -21, Ceiling: 0}.Validate())
+	assert.Error(t, NicePolicy{Levels: map[int]int{1: 20}, Ceiling: 19}.Validate())
+}
+
+func TestIOPriority(t *testing.T) {
+	assert.Equal(t, 0, IOPriority(-20))
+	assert.Equal(t, 4, IOPriority(0))
+	assert.Equal(t, 7, IOPriority(19))
+}
+
+type niceRecorder struct {
+	nice *int
+}
+
+func (r *niceRecorder) Run(ctx context.Context, command string, args []string) error {
+	if nice, ok := NiceFromContext(ctx); ok {
+		r.nice = &nice
+	}
+	return nil
+}
+
+func TestConfigurableTool_PassesNiceToRunner(t *testing.T) {
+	recorder := &niceRecorder{}
+	var mu sync.Mutex
+	var started []ProgressEvent
+	options := DefaultOptions()
+	options.Priority = 10
+	options.NicePolicy = &NicePolicy{Levels: map[int]int{10: 2, 0: 12}, Ceiling: MaxNice}
+	options.OnToolProgress = func(event ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		if event.Status == "Started" {
+			started = append(started, event)
+		}
+	}
+
+	tool := NewConfigurableTool("nuclei", "vuln", ToolConfig{Name: "nuclei", Command: "nuclei"}, recorder)
+	require.NoError(t, tool.Run(context.Background(), options))
+	require.NotNil(t, recorder.nice)
+	assert.Equal(t, 2, *recorder.nice)
+
+	mu.Lock()
+	require.Len(t, started, 1)
+	require.NotNil(t, started[0].Nice)
+	assert.Equal(t, 2, *started[0].Nice)
+	mu.Unlock()
+
+	recorder.nice = nil
+	require.NoError(t, tool.Run(context.Background(), DefaultOptions()))
+	assert.Nil(t, recorder.nice)
+}
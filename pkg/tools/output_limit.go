@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"pipeliner/pkg/errors"
+	"time"
+)
+
+const outputLimitKey contextKey = "output_limit"
+
+// OutputLimitInterval is how often a running command's output files are
+// checked against its OutputLimit.
+var OutputLimitInterval = time.Second
+
+// OutputLimit is how large a command's output files may grow while it runs.
+// Relative Files are in the command's directory.
+type OutputLimit struct {
+	Files   []string
+	LimitMB int
+}
+
+// WithOutputLimit has the runner stop the commands run with ctx once one of
+// limit's files grows past it.
+func WithOutputLimit(ctx context.Context, limit OutputLimit) context.Context {
+	return context.WithValue(ctx, outputLimitKey, limit)
+}
+
+func OutputLimitFromContext(ctx context.Context) (OutputLimit, bool) {
+	limit, ok := ctx.Value(outputLimitKey).(OutputLimit)
+	return limit, ok && limit.LimitMB > 0
+}
+
+// exceeded returns the first of the files in dir over the limit.
+func (l OutputLimit) exceeded(dir string) (string, bool) {
+	maxBytes := int64(l.LimitMB) << 20
+	for _, file := range l.Files {
+		path := file
+		if !filepath.IsAbs(path) && dir != "" {
+			path = filepath.Join(dir, path)
+		}
+		if info, err := os.Stat(path); err == nil && info.Size() > maxBytes {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// WatchOutputLimit checks the files of ctx's OutputLimit, in dir, every
+// OutputLimitInterval and calls kill once one is over it. stop ends the
+// watch and returns the OutputLimitError if kill was called.
+func WatchOutputLimit(ctx context.Context, dir string, kill func()) (stop func() error) {
+	limit, ok := OutputLimitFromContext(ctx)
+	if !ok || len(limit.Files) == 0 {
+		return func() error { return nil }
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	var exceeded error
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(OutputLimitInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if file, over := limit.exceeded(dir); over {
+					exceeded = errors.NewOutputLimitError(file, limit.LimitMB)
+					kill()
+					return
+				}
+			}
+		}
+	}()
+	return func() error {
+		close(done)
+		<-finished
+		return exceeded
+	}
+}
+
+// outputLimitContext sets the output limit of the tool's run: its
+// max_output_mb, or else the scan's disk quota, over its declared outputs.
+// output_per_value runs are limited per value by the replacement runner.
+func (t *ConfigurableTool) outputLimitContext(ctx context.Context, options *Options) context.Context {
+	limitMB := t.config.MaxOutputMB
+	if limitMB == 0 && options != nil {
+		limitMB = options.DiskQuotaMB
+	}
+	if limitMB <= 0 {
+		return ctx
+	}
+	limit := OutputLimit{LimitMB: limitMB}
+	if t.config.OutputPerValue == "" {
+		limit.Files = t.declaredOutputs(getOutputDir(options))
+	}
+	return WithOutputLimit(ctx, limit)
+}
@@ -0,0 +1,137 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"sync"
+)
+
+const pauseGateKey contextKey = "pause_gate"
+
+// PauseGate holds the strategies back from starting new tools while a scan is
+// paused. A hard pause also suspends the processes of the tools already
+// running; a soft pause lets them finish.
+type PauseGate struct {
+	mu      sync.Mutex
+	paused  bool
+	hard    bool
+	resumed chan struct{} // closed when the gate opens again
+	procs   map[*os.Process]struct{}
+}
+
+func NewPauseGate() *PauseGate {
+	return &PauseGate{procs: make(map[*os.Process]struct{})}
+}
+
+// Pause closes the gate. It reports false if the gate was already closed.
+func (g *PauseGate) Pause(hard bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		return false
+	}
+	g.paused, g.hard = true, hard
+	g.resumed = make(chan struct{})
+
+	if hard {
+		for p := range g.procs {
+			if err := suspendProcess(p); err != nil {
+				chainLogger.Warnf("Failed to suspend process %d: %v", p.Pid, err)
+			}
+		}
+	}
+	return true
+}
+
+// Resume opens the gate and continues suspended processes. It reports false
+// if the gate was not closed.
+func (g *PauseGate) Resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		return false
+	}
+
+	if g.hard {
+		for p := range g.procs {
+			if err := resumeProcess(p); err != nil {
+				chainLogger.Warnf("Failed to resume process %d: %v", p.Pid, err)
+			}
+		}
+	}
+	g.paused, g.hard = false, false
+	close(g.resumed)
+	return true
+}
+
+func (g *PauseGate) Paused() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// Wait blocks while the gate is closed, or until ctx is done. A nil gate
+// never blocks.
+func (g *PauseGate) Wait(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	paused, resumed := g.paused, g.resumed
+	g.mu.Unlock()
+	if !paused {
+		return nil
+	}
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// track registers a running tool process so a hard pause can suspend it. A
+// process started during a hard pause is suspended right away.
+func (g *PauseGate) track(p *os.Process) func() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.procs[p] = struct{}{}
+	if g.paused && g.hard {
+		if err := suspendProcess(p); err != nil {
+			chainLogger.Warnf("Failed to suspend process %d: %v", p.Pid, err)
+		}
+	}
+
+	return func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		delete(g.procs, p)
+	}
+}
+
+func withPauseGate(ctx context.Context, g *PauseGate) context.Context {
+	return context.WithValue(ctx, pauseGateKey, g)
+}
+
+// TrackProcess registers p with the pause gate of the scan running in ctx, if
+// any. The returned func must be called once the process has exited.
+func TrackProcess(ctx context.Context, p *os.Process) func() {
+	g, ok := ctx.Value(pauseGateKey).(*PauseGate)
+	if !ok || g == nil {
+		return func() {}
+	}
+	return g.track(p)
+}
+
+// waitForResume is called by the strategies before they start a tool.
+func waitForResume(ctx context.Context, name string, options *Options) error {
+	if options == nil || !options.Pause.Paused() {
+		return nil
+	}
+	chainLogger.Infof("Scan paused, holding back tool %s", name)
+	return options.Pause.Wait(ctx)
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package tools
+
+import (
+	"errors"
+	"os"
+)
+
+// Processes cannot be stopped and continued here, so a hard pause only holds
+// back new tools.
+var errSuspendUnsupported = errors.New("suspending processes is not supported on this platform")
+
+func suspendProcess(p *os.Process) error {
+	return errSuspendUnsupported
+}
+
+func resumeProcess(p *os.Process) error {
+	return errSuspendUnsupported
+}
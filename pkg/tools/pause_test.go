@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pipeliner/pkg/testutil"
+)
+
+func TestSequentialStrategy_PauseHoldsBackNextTool(t *testing.T) {
+	gate := NewPauseGate()
+
+	first := NewMockTool("subfinder", "domain_enum", nil)
+	first.SetRunFunc(func(context.Context, *Options) error {
+		// paused mid-run: this tool finishes, the next one waits
+		gate.Pause(false)
+		return nil
+	})
+	started := make(chan struct{})
+	second := NewMockTool("httpx", "recon", nil)
+	second.SetRunFunc(func(context.Context, *Options) error {
+		close(started)
+		return nil
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- (&SequentialStrategy{}).Run(context.Background(), []Tool{first, second}, &Options{Pause: gate})
+	}()
+
+	select {
+	case <-started:
+		t.Fatal("httpx started while the scan was paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+	testutil.AssertEquals(t, true, gate.Paused())
+
+	testutil.AssertEquals(t, true, gate.Resume())
+	<-started
+	testutil.AssertNoError(t, <-done)
+}
+
+func TestPauseGate_WaitStopsOnCancel(t *testing.T) {
+	gate := NewPauseGate()
+	testutil.AssertEquals(t, true, gate.Pause(true))
+	testutil.AssertEquals(t, false, gate.Pause(false))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	testutil.AssertEquals(t, context.Canceled, gate.Wait(ctx))
+
+	testutil.AssertEquals(t, true, gate.Resume())
+	testutil.AssertEquals(t, false, gate.Resume())
+	testutil.AssertNoError(t, gate.Wait(ctx))
+
+	var nilGate *PauseGate
+	testutil.AssertNoError(t, nilGate.Wait(ctx))
+}
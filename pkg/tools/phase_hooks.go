@@ -0,0 +1,185 @@
+package tools
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"pipeliner/pkg/errors"
+	"pipeliner/pkg/logger"
+	"time"
+)
+
+// phaseTarget is the HookExecution target of pre-run and cleanup hooks.
+const phaseTarget = "scan"
+
+// DefaultCleanupTimeout bounds the whole cleanup phase.
+const DefaultCleanupTimeout = 30 * time.Second
+
+// PhaseHookConfig runs a registered post hook once for the whole scan: in
+// pre_run before the first tool (templates, VPN), or in cleanup after the
+// last one.
+type PhaseHookConfig struct {
+	Hook    string        `yaml:"hook" mapstructure:"hook"`
+	Timeout time.Duration `yaml:"timeout,omitempty" mapstructure:"timeout"`
+	// Critical overrides the hook's own criticality when set.
+	Critical *bool `yaml:"critical,omitempty" mapstructure:"critical"`
+	// Params reach the hook as HookContext.OtherData.
+	Params map[string]string `yaml:"params,omitempty" mapstructure:"params"`
+}
+
+func (hc PhaseHookConfig) critical(options *Options) bool {
+	if hc.Critical != nil {
+		return *hc.Critical
+	}
+	_, critical := resolvePostHook(hc.Hook, options)
+	return critical
+}
+
+// ExecutePreRunHooks runs hooks in order. A critical hook that fails, is
+// missing or times out stops the rest and returns an error wrapping
+// ErrPreRunHookFailed; other failures become hook warnings.
+func ExecutePreRunHooks(ctx context.Context, hooks []PhaseHookConfig, options *Options) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	if options == nil {
+		options = &Options{}
+	}
+
+	log := options.Logger
+	if log == nil {
+		log = chainLogger
+	}
+	log.WithFields(logger.Fields{"hook_count": len(hooks)}).Info("Executing pre-run hooks")
+
+	for _, hc := range hooks {
+		exec := HookExecution{Hook: hc.Hook, Scope: HookScopePreRun, Target: phaseTarget, StartedAt: time.Now()}
+
+		hook, _ := resolvePostHook(hc.Hook, options)
+		var err error
+		if hook == nil {
+			exec.Status = HookStatusMissing
+			err = fmt.Errorf("hook %s is not registered", hc.Hook)
+		} else {
+			err = runPhaseHook(ctx, hook, hc, options)
+		}
+		exec.FinishedAt = time.Now()
+
+		if err == nil {
+			exec.Status = HookStatusSucceeded
+			reportHookExecution(options, exec)
+			log.WithFields(logger.Fields{"hook_name": hc.Hook}).Info("Pre-run hook completed")
+			continue
+		}
+
+		exec.Err = err
+		if !hc.critical(options) && ctx.Err() == nil {
+			if exec.Status == "" {
+				exec.Status = HookStatusWarned
+			}
+			reportHookExecution(options, exec)
+			warnHookFailure(HookScopePreRun, hc.Hook, err, options)
+			continue
+		}
+
+		if exec.Status == "" {
+			exec.Status = HookStatusFailed
+		}
+		reportHookExecution(options, exec)
+		log.WithFields(logger.Fields{"hook_name": hc.Hook, "error": err}).Error("Pre-run hook failed, no tools will run")
+		return fmt.Errorf("%w: %s: %w", errors.ErrPreRunHookFailed, hc.Hook, err)
+	}
+	return nil
+}
+
+// ExecuteCleanupHooks runs every hook in order, whatever the others do, and
+// returns their joined failures. ctx should be detached from the scan's so a
+// cancelled scan still cleans up.
+func ExecuteCleanupHooks(ctx context.Context, hooks []PhaseHookConfig, options *Options) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	if options == nil {
+		options = &Options{}
+	}
+
+	log := options.Logger
+	if log == nil {
+		log = chainLogger
+	}
+	log.WithFields(logger.Fields{"hook_count": len(hooks)}).Info("Executing cleanup hooks")
+
+	var failures []error
+	for _, hc := range hooks {
+		exec := HookExecution{Hook: hc.Hook, Scope: HookScopeCleanup, Target: phaseTarget, StartedAt: time.Now()}
+
+		hook, _ := resolvePostHook(hc.Hook, options)
+		var err error
+		if hook == nil {
+			exec.Status = HookStatusMissing
+			err = fmt.Errorf("hook %s is not registered", hc.Hook)
+		} else {
+			err = runPhaseHook(ctx, hook, hc, options)
+		}
+		exec.FinishedAt = time.Now()
+
+		switch {
+		case err == nil:
+			exec.Status = HookStatusSucceeded
+		case exec.Status != "":
+		case hc.critical(options):
+			exec.Status = HookStatusFailed
+		default:
+			exec.Status = HookStatusWarned
+		}
+		exec.Err = err
+		reportHookExecution(options, exec)
+
+		if err == nil {
+			log.WithFields(logger.Fields{"hook_name": hc.Hook}).Info("Cleanup hook completed")
+			continue
+		}
+		if exec.Status == HookStatusWarned {
+			warnHookFailure(HookScopeCleanup, hc.Hook, err, options)
+		} else {
+			log.WithFields(logger.Fields{"hook_name": hc.Hook, "error": err}).Error("Cleanup hook failed")
+		}
+		failures = append(failures, fmt.Errorf("%s: %w", hc.Hook, err))
+	}
+	return stderrors.Join(failures...)
+}
+
+// runPhaseHook gives up on a hook when its timeout passes or the scan is
+// cancelled, even if the hook itself ignores the context.
+func runPhaseHook(ctx context.Context, hook PostHook, hc PhaseHookConfig, options *Options) error {
+	if hc.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hc.Timeout)
+		defer cancel()
+	}
+
+	otherData := make(map[string]interface{}, len(hc.Params))
+	for key, value := range hc.Params {
+		otherData[key] = value
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- hook.Execute(HookContext{
+			ctx:       ctx,
+			OutputDir: getOutputDir(options),
+			Options:   options,
+			OtherData: otherData,
+		})
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %s", hc.Timeout)
+		}
+		return ctx.Err()
+	}
+}
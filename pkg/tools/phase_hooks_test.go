@@ -0,0 +1,156 @@
+package tools
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	perrors "pipeliner/pkg/errors"
+	"pipeliner/pkg/testutil"
+)
+
+type recordingHook struct {
+	runs   int
+	params map[string]interface{}
+}
+
+func (h *recordingHook) Name() string        { return "recording" }
+func (h *recordingHook) Description() string { return "records its runs" }
+func (h *recordingHook) Execute(ctx HookContext) error {
+	h.runs++
+	h.params = ctx.OtherData
+	return nil
+}
+
+type blockingHook struct{}
+
+func (h *blockingHook) Name() string        { return "blocking" }
+func (h *blockingHook) Description() string { return "waits for its context" }
+func (h *blockingHook) Execute(ctx HookContext) error {
+	<-ctx.Context().Done()
+	return ctx.Context().Err()
+}
+
+func TestExecutePreRunHooks(t *testing.T) {
+	RegisterPostHook("test-prerun-critical", &failingHook{name: "test-prerun-critical", critical: true})
+	RegisterPostHook("test-prerun-noncritical", &failingHook{name: "test-prerun-noncritical", critical: false})
+	RegisterPostHook("test-prerun-blocking", &blockingHook{})
+	recorder := &recordingHook{}
+	RegisterPostHook("test-prerun-recording", recorder)
+
+	notCritical := false
+	critical := true
+
+	tests := []struct {
+		name       string
+		hooks      []PhaseHookConfig
+		wantErr    bool
+		wantRuns   int
+		wantStatus []string
+		// wantParam is the "command" param the recording hook saw
+		wantParam interface{}
+	}{
+		{
+			name: "critical failure stops the rest",
+			hooks: []PhaseHookConfig{
+				{Hook: "test-prerun-critical"},
+				{Hook: "test-prerun-recording"},
+			},
+			wantErr:    true,
+			wantStatus: []string{HookStatusFailed},
+		},
+		{
+			name: "non-critical failure warns",
+			hooks: []PhaseHookConfig{
+				{Hook: "test-prerun-noncritical"},
+				{Hook: "test-prerun-recording", Params: map[string]string{"command": "true"}},
+			},
+			wantRuns:   1,
+			wantStatus: []string{HookStatusWarned, HookStatusSucceeded},
+			wantParam:  "true",
+		},
+		{
+			name: "critical override on the entry",
+			hooks: []PhaseHookConfig{
+				{Hook: "test-prerun-noncritical", Critical: &critical},
+			},
+			wantErr:    true,
+			wantStatus: []string{HookStatusFailed},
+		},
+		{
+			name: "timeout",
+			hooks: []PhaseHookConfig{
+				{Hook: "test-prerun-blocking", Timeout: 20 * time.Millisecond},
+			},
+			wantErr:    true,
+			wantStatus: []string{HookStatusFailed},
+		},
+		{
+			name: "missing hook",
+			hooks: []PhaseHookConfig{
+				{Hook: "test-prerun-missing", Critical: &notCritical},
+				{Hook: "test-prerun-recording"},
+			},
+			wantRuns:   1,
+			wantStatus: []string{HookStatusMissing, HookStatusSucceeded},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+			defer cancel()
+			recorder.runs, recorder.params = 0, nil
+
+			var statuses []string
+			options := &Options{OnHookExecution: func(exec HookExecution) {
+				testutil.AssertEquals(t, HookScopePreRun, exec.Scope)
+				statuses = append(statuses, exec.Status)
+			}}
+
+			err := ExecutePreRunHooks(ctx, tt.hooks, options)
+			if tt.wantErr {
+				if !errors.Is(err, perrors.ErrPreRunHookFailed) {
+					t.Fatalf("expected ErrPreRunHookFailed, got %v", err)
+				}
+			} else {
+				testutil.AssertNoError(t, err)
+			}
+			testutil.AssertEquals(t, tt.wantRuns, recorder.runs)
+			testutil.AssertEquals(t, strings.Join(tt.wantStatus, ","), strings.Join(statuses, ","))
+			testutil.AssertEquals(t, tt.wantParam, recorder.params["command"])
+		})
+	}
+}
+
+func TestExecuteCleanupHooks_RunsEveryHook(t *testing.T) {
+	RegisterPostHook("test-cleanup-critical", &failingHook{name: "test-cleanup-critical", critical: true})
+	RegisterPostHook("test-cleanup-noncritical", &failingHook{name: "test-cleanup-noncritical", critical: false})
+	recorder := &recordingHook{}
+	RegisterPostHook("test-cleanup-recording", recorder)
+
+	ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+	defer cancel()
+
+	var statuses []string
+	var warnings []HookWarning
+	options := &Options{
+		OnHookExecution: func(exec HookExecution) {
+			testutil.AssertEquals(t, HookScopeCleanup, exec.Scope)
+			statuses = append(statuses, exec.Status)
+		},
+		OnHookWarning: func(w HookWarning) { warnings = append(warnings, w) },
+	}
+
+	err := ExecuteCleanupHooks(ctx, []PhaseHookConfig{
+		{Hook: "test-cleanup-critical"},
+		{Hook: "test-cleanup-noncritical"},
+		{Hook: "test-cleanup-recording"},
+	}, options)
+
+	testutil.AssertError(t, err)
+	testutil.AssertEquals(t, 1, recorder.runs)
+	testutil.AssertEquals(t, "failed,warned,succeeded", strings.Join(statuses, ","))
+	testutil.AssertEquals(t, 1, len(warnings))
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+)
+
+const (
+	// ProxyEnv sends every scan through an egress proxy, overriding the
+	// module's proxy.
+	ProxyEnv = "PIPELINER_PROXY"
+	// NoProxyFlag as a tool's proxy_flag keeps the proxy off its command
+	// line; the proxy environment variables are still set.
+	NoProxyFlag = "none"
+)
+
+const environmentKey contextKey = "environment"
+
+// defaultProxyFlags is how the known HTTP tools take a proxy URL.
+var defaultProxyFlags = map[string]string{
+	"httpx":  "-http-proxy",
+	"nuclei": "-proxy",
+	"ffuf":   "-x",
+}
+
+var proxySchemes = map[string]bool{"http": true, "https": true, "socks5": true, "socks5h": true}
+
+// ValidateProxy checks that proxy is an http(s) or socks5 URL with a host.
+func ValidateProxy(proxy string) error {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	if !proxySchemes[u.Scheme] {
+		return fmt.Errorf("proxy %s: scheme must be http, https, socks5 or socks5h", RedactProxy(proxy))
+	}
+	if u.Host == "" {
+		return fmt.Errorf("proxy %s: host is required", RedactProxy(proxy))
+	}
+	if err := validateArgument(proxy); err != nil {
+		return fmt.Errorf("proxy %s: %w", RedactProxy(proxy), err)
+	}
+	return nil
+}
+
+// RedactProxy hides the password of a proxy URL.
+func RedactProxy(proxy string) string {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return proxy
+	}
+	return u.Redacted()
+}
+
+// proxyFlag is the flag the tool takes the proxy with, or "" when it takes
+// none.
+func (tc *ToolConfig) proxyFlag() string {
+	switch tc.ProxyFlag {
+	case NoProxyFlag:
+		return ""
+	case "":
+		return defaultProxyFlags[filepath.Base(tc.Command)]
+	default:
+		return tc.ProxyFlag
+	}
+}
+
+func (tc *ToolConfig) proxyArgs(proxy string) []string {
+	flag := tc.proxyFlag()
+	if flag == "" || proxy == "" || tc.NoProxy {
+		return nil
+	}
+	return []string{flag, proxy}
+}
+
+// Environment is what the tool runs with on top of pipeliner's own
+// environment: the options' variables and, unless the tool opts out, the
+// proxy variables most tools honour.
+func (tc *ToolConfig) Environment(options *Options) []string {
+	if options == nil {
+		return nil
+	}
+	var env []string
+	for name, value := range options.Environment {
+		env = append(env, name+"="+value)
+	}
+	if options.Proxy != "" && !tc.NoProxy {
+		for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "ALL_PROXY", "http_proxy", "https_proxy", "all_proxy"} {
+			env = append(env, name+"="+options.Proxy)
+		}
+	}
+	return env
+}
+
+func withEnvironment(ctx context.Context, env []string) context.Context {
+	return context.WithValue(ctx, environmentKey, env)
+}
+
+// EnvironmentFromContext is the extra NAME=value environment the command
+// running in ctx gets, or nil.
+func EnvironmentFromContext(ctx context.Context) []string {
+	env, _ := ctx.Value(environmentKey).([]string)
+	return env
+}
@@ -4,8 +4,12 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"pipeliner/pkg/hostlimit"
 	"pipeliner/pkg/runner"
 	"pipeliner/pkg/tools"
 )
@@ -18,12 +22,18 @@ type MockToolRunner struct {
 type ExecutedCommand struct {
 	Command string
 	Args    []string
+	Dir     string
 }
 
 func (m *MockToolRunner) Run(ctx context.Context, command string, args []string) error {
+	return m.RunInDir(ctx, "", command, args)
+}
+
+func (m *MockToolRunner) RunInDir(ctx context.Context, dir, command string, args []string) error {
 	m.ExecutedCommands = append(m.ExecutedCommands, ExecutedCommand{
 		Command: command,
 		Args:    args,
+		Dir:     dir,
 	})
 	return nil
 }
@@ -90,16 +100,12 @@ http://demo.org`
 		registry,
 	)
 
-	// Change to temp directory to make the test file accessible
-	originalDir, _ := os.Getwd()
-	os.Chdir(tempDir)
-	defer os.Chdir(originalDir)
-
-	// Run the tool
+	// Run the tool in the scan directory
 	ctx := context.Background()
 	options := tools.DefaultOptions()
 	options.ScanType = "test"
 	options.Domain = "example.com"
+	options.WorkingDir = tempDir
 
 	err = tool.Run(ctx, options)
 	if err != nil {
@@ -123,6 +129,9 @@ http://demo.org`
 		if execCmd.Args[1] != expectedArg {
 			t.Errorf("Expected URL argument '%s', got '%s'", expectedArg, execCmd.Args[1])
 		}
+		if execCmd.Dir != tempDir {
+			t.Errorf("Expected command to run in %s, got %q", tempDir, execCmd.Dir)
+		}
 	}
 }
 
@@ -253,16 +262,12 @@ func TestReplacementWithoutRegistry(t *testing.T) {
 		t.Fatalf("Failed to write test file: %v", err)
 	}
 
-	// Change to temp directory
-	originalDir, _ := os.Getwd()
-	os.Chdir(tempDir)
-	defer os.Chdir(originalDir)
-
 	// Run the tool - should use fallback pattern
 	ctx := context.Background()
 	options := tools.DefaultOptions()
 	options.ScanType = "test"
 	options.Domain = "example.com"
+	options.WorkingDir = tempDir
 
 	err = tool.Run(ctx, options)
 	if err != nil {
@@ -279,3 +284,140 @@ func TestReplacementWithoutRegistry(t *testing.T) {
 		t.Errorf("Expected URL '%s', got '%s'", expectedURL, mockRunner.ExecutedCommands[0].Args[1])
 	}
 }
+
+func TestReplacementMergesAllDependencyOutputs(t *testing.T) {
+	tempDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tempDir, "httpx_results.txt"), []byte("http://example.com\nhttps://test.com\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "probe_results.txt"), []byte("https://test.com\nhttp://demo.org\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	registry := tools.NewSimpleToolRegistry()
+	registry.RegisterTool(tools.ToolConfig{
+		Name:    "httpx",
+		Command: "httpx",
+		Flags:   []tools.FlagConfig{{Flag: "-o", Option: "Output", Default: "httpx_results.txt"}},
+	})
+	registry.RegisterTool(tools.ToolConfig{
+		Name:    "probe",
+		Command: "probe",
+		Flags:   []tools.FlagConfig{{Flag: "-o", Option: "Output", Default: "probe_results.txt"}},
+	})
+
+	tests := []struct {
+		name        string
+		replaceFrom []string
+	}{
+		{name: "inferred from every dependency"},
+		{name: "explicit list", replaceFrom: []string{"httpx_results.txt", "probe_results.txt"}},
+		{name: "glob", replaceFrom: []string{"*_results.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ffufConfig := tools.ToolConfig{
+				Name:        "ffuf",
+				Command:     "ffuf",
+				Replace:     "{{URL}}",
+				ReplaceFrom: tt.replaceFrom,
+				DependsOn:   []string{"httpx", "probe"},
+				Flags:       []tools.FlagConfig{{Flag: "-u", Default: "{{URL}}/FUZZ"}},
+			}
+
+			mockBaseRunner := &MockToolRunner{}
+			tool := tools.NewConfigurableToolWithRegistry("ffuf", "recon", ffufConfig, runner.NewReplacementCommandRunner(mockBaseRunner), registry)
+
+			options := tools.DefaultOptions()
+			options.WorkingDir = tempDir
+
+			if err := tool.Run(context.Background(), options); err != nil {
+				t.Fatalf("Tool run failed: %v", err)
+			}
+
+			expectedURLs := []string{"http://example.com", "https://test.com", "http://demo.org"}
+			if len(mockBaseRunner.ExecutedCommands) != len(expectedURLs) {
+				t.Fatalf("Expected %d commands, got %d", len(expectedURLs), len(mockBaseRunner.ExecutedCommands))
+			}
+			for i, execCmd := range mockBaseRunner.ExecutedCommands {
+				if execCmd.Args[1] != expectedURLs[i]+"/FUZZ" {
+					t.Errorf("Command %d: expected '%s/FUZZ', got '%s'", i, expectedURLs[i], execCmd.Args[1])
+				}
+			}
+		})
+	}
+}
+
+// timedRunner records when each command started.
+type timedRunner struct {
+	mu     sync.Mutex
+	starts []time.Time
+}
+
+func (r *timedRunner) Run(ctx context.Context, command string, args []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.starts = append(r.starts, time.Now())
+	return nil
+}
+
+func TestReplacementSharesHostBudgetAcrossScans(t *testing.T) {
+	limiter := hostlimit.New(50) // 20ms apart
+	scans := []struct{ id, domain, urls string }{
+		{"scan-a", "example.com", "https://example.com/a\nhttps://www.example.com/b\nhttps://example.com/c\n"},
+		{"scan-b", "api.example.com", "https://api.example.com/a\nhttps://api.example.com/b\nhttps://api.example.com/c\n"},
+	}
+
+	base := &timedRunner{}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, scan := range scans {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "urls.txt"), []byte(scan.urls), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		tool := tools.NewConfigurableTool("nuclei", "vuln", tools.ToolConfig{
+			Name:        "nuclei",
+			Command:     "nuclei",
+			Replace:     "{{URL}}",
+			ReplaceFrom: []string{"urls.txt"},
+			Flags:       []tools.FlagConfig{{Flag: "-u", Default: "{{URL}}"}},
+		}, runner.NewReplacementCommandRunner(base))
+
+		options := tools.DefaultOptions()
+		options.Domain = scan.domain
+		options.WorkingDir = dir
+		options.ScanID = scan.id
+		options.HostLimiter = limiter
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := tool.Run(context.Background(), options); err != nil {
+				t.Errorf("%s failed: %v", scan.id, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// each scan alone stays under the budget in 40ms; together they share it
+	if len(base.starts) != 6 {
+		t.Fatalf("Expected 6 commands, got %d", len(base.starts))
+	}
+	if elapsed := time.Since(start); elapsed < 5*20*time.Millisecond {
+		t.Errorf("6 commands took %s, want at least 100ms", elapsed)
+	}
+
+	stats := limiter.Stats()
+	if len(stats) != 1 || stats[0].Host != "example.com" {
+		t.Fatalf("Expected one host example.com, got %+v", stats)
+	}
+	if got := strings.Join(stats[0].Scans, ","); got != "scan-a,scan-b" {
+		t.Errorf("Expected both scans on example.com, got %s", got)
+	}
+	if stats[0].Requests < 6 {
+		t.Errorf("Expected at least 6 requests, got %d", stats[0].Requests)
+	}
+}
@@ -0,0 +1,141 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// OutputExists reports whether the output file inferred for config is in
+// dir and not empty, which is what lets skip_if_output_exists skip it.
+func OutputExists(config ToolConfig, dir string) bool {
+	tool := &ConfigurableTool{name: config.Name, config: config}
+	_, ok := tool.outputIn(dir)
+	return ok
+}
+
+// existingOutput is the output a skip_if_output_exists tool would skip on.
+// Dry runs never skip, so the plan shows every command.
+func (t *ConfigurableTool) existingOutput(options *Options) (string, bool) {
+	if !t.config.SkipIfOutputExists || (options != nil && options.DryRun) {
+		return "", false
+	}
+	return t.outputIn(getOutputDir(options))
+}
+
+func (t *ConfigurableTool) outputIn(dir string) (string, bool) {
+	file := t.extractOutputFileFromConfig(&t.config)
+	path := file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || info.Size() == 0 {
+		return "", false
+	}
+	return file, true
+}
+
+// skip completes the tool without running it. The strategies see a
+// success, so the tool still counts toward its stage.
+func (t *ConfigurableTool) skip(options *Options, file string) error {
+	t.emptyOutputs = nil
+	t.logger.WithTool(t.name, t.tool_type).Infof("Skipping %s, output %s already exists", t.name, file)
+	if t.config.OutputPerValue == "" {
+		t.writeOutputManifest(options)
+	}
+	reportProgress(options, ProgressEvent{
+		Tool:      t.name,
+		Status:    ProgressSkipped,
+		Message:   fmt.Sprintf("output %s already exists", file),
+		Timestamp: time.Now(),
+	})
+	return nil
+}
+
+// ResumeSupport describes how a tool continues a run it was interrupted in,
+// e.g. nmap's "--resume nmap_output.txt" with the -oN file of that run.
+type ResumeSupport struct {
+	// Flag resumes the tool; it takes the state file as its value.
+	Flag string `yaml:"flag"`
+	// StateFile is the file an interrupted run leaves in the working
+	// directory.
+	StateFile string `yaml:"state_file" mapstructure:"state_file"`
+	// Only passes nothing but Flag and the state file, for tools like nmap
+	// that read the rest of the command line back from it.
+	Only bool `yaml:"only,omitempty"`
+	// DoneMarker, found near the end of the state file, means the run
+	// finished and there is nothing to resume, e.g. "# Nmap done".
+	DoneMarker string `yaml:"done_marker,omitempty" mapstructure:"done_marker"`
+}
+
+func (r *ResumeSupport) Validate() error {
+	if err := validateFlag(r.Flag); err != nil {
+		return fmt.Errorf("invalid resume_support flag: %w", err)
+	}
+	if r.StateFile == "" {
+		return fmt.Errorf("resume_support state_file is required")
+	}
+	if err := validateArgument(r.StateFile); err != nil {
+		return fmt.Errorf("invalid resume_support state_file: %w", err)
+	}
+	return nil
+}
+
+// doneMarkerWindow is how much of the end of a state file is searched for
+// its done marker.
+const doneMarkerWindow = 4096
+
+// InterruptedRun reports whether dir holds the state of an unfinished run
+// of a tool with resume_support, or the checkpoint of one with a
+// replace_chunk_size, and names the state file.
+func InterruptedRun(config ToolConfig, dir string) (string, bool) {
+	resume := config.ResumeSupport
+	if resume == nil {
+		return interruptedChunks(config, dir)
+	}
+	path := resume.StateFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() || info.Size() == 0 {
+		return "", false
+	}
+	if resume.DoneMarker != "" {
+		tail := make([]byte, min(info.Size(), doneMarkerWindow))
+		if _, err := f.ReadAt(tail, info.Size()-int64(len(tail))); err != nil && err != io.EOF {
+			return "", false
+		}
+		if bytes.Contains(tail, []byte(resume.DoneMarker)) {
+			return "", false
+		}
+	}
+	return resume.StateFile, true
+}
+
+// resumeFrom is the state file the tool continues from in this run, if the
+// engine chose to resume it.
+func (t *ConfigurableTool) resumeFrom(options *Options) (string, bool) {
+	if t.config.ResumeSupport == nil || options == nil {
+		return "", false
+	}
+	stateFile, ok := options.Resume[t.name]
+	return stateFile, ok
+}
+
+// resumeArgs turns the usual arguments into those of a resumed run.
+func (r *ResumeSupport) resumeArgs(args []string, stateFile string) []string {
+	if r.Only {
+		return []string{r.Flag, stateFile}
+	}
+	return append(args, r.Flag, stateFile)
+}
@@ -0,0 +1,158 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// commandRunner records the commands it ran and writes their -o output.
+type commandRunner struct {
+	mu       sync.Mutex
+	dir      string
+	commands []string
+}
+
+func (r *commandRunner) Run(ctx context.Context, command string, args []string) error {
+	r.mu.Lock()
+	r.commands = append(r.commands, command)
+	r.mu.Unlock()
+	for i, arg := range args {
+		if arg == "-o" && i+1 < len(args) {
+			return os.WriteFile(filepath.Join(r.dir, args[i+1]), []byte("a.example.com\n"), 0644)
+		}
+	}
+	return nil
+}
+
+type stageHookFunc func(HookContext) error
+
+func (f stageHookFunc) Name() string                          { return "record_stage" }
+func (f stageHookFunc) Description() string                   { return "records the stage" }
+func (f stageHookFunc) ExecuteForStage(ctx HookContext) error { return f(ctx) }
+
+func resumeTool(name, toolType, output string, runner CommandRunner) Tool {
+	return NewConfigurableTool(name, toolType, ToolConfig{
+		Name:               name,
+		Command:            name,
+		Flags:              []FlagConfig{{Flag: "-o", Option: "Output", Default: output}},
+		SkipIfOutputExists: true,
+	}, runner)
+}
+
+func TestSkipIfOutputExists_PartialScanDirectory(t *testing.T) {
+	dir := t.TempDir()
+	// the scan was killed after subfinder, with httpx halfway: an empty
+	// output does not count as done
+	if err := os.WriteFile(filepath.Join(dir, "subfinder_output.txt"), []byte("a.example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "httpx_output.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := stageHooks[StageSubdomain]
+	t.Cleanup(func() { stageHooks[StageSubdomain] = saved })
+	stageHooks[StageSubdomain] = nil
+	var stages []string
+	RegisterStageHook(StageSubdomain, stageHookFunc(func(ctx HookContext) error {
+		stages = append(stages, ctx.ToolName)
+		return nil
+	}))
+
+	runner := &commandRunner{dir: dir}
+	var skipped []string
+	options := &Options{WorkingDir: dir, OnProgress: func(e ProgressEvent) {
+		if e.Status == ProgressSkipped {
+			skipped = append(skipped, e.Tool)
+		}
+	}}
+	chain := []Tool{
+		resumeTool("subfinder", "domain_enum", "subfinder_output.txt", runner),
+		resumeTool("httpx", "recon", "httpx_output.txt", runner),
+	}
+	if err := (&SequentialStrategy{}).Run(context.Background(), chain, options); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(runner.commands) != 1 || runner.commands[0] != "httpx" {
+		t.Errorf("ran %v, want only httpx", runner.commands)
+	}
+	if len(skipped) != 1 || skipped[0] != "subfinder" {
+		t.Errorf("skipped %v, want subfinder", skipped)
+	}
+	if len(stages) != 1 || stages[0] != string(StageSubdomain) {
+		t.Errorf("stage hooks ran for %v, want the skipped subfinder's stage", stages)
+	}
+}
+
+func TestSkipIfOutputExists_Off(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "subfinder_output.txt"), []byte("a.example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &commandRunner{dir: dir}
+	tool := NewConfigurableTool("subfinder", "domain_enum", ToolConfig{
+		Name:    "subfinder",
+		Command: "subfinder",
+		Flags:   []FlagConfig{{Flag: "-o", Option: "Output", Default: "subfinder_output.txt"}},
+	}, runner)
+	if err := tool.Run(context.Background(), &Options{WorkingDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.commands) != 1 {
+		t.Errorf("ran %v, want subfinder to run again", runner.commands)
+	}
+}
+
+func TestInterruptedRun(t *testing.T) {
+	dir := t.TempDir()
+	config := ToolConfig{
+		Name:          "nmap",
+		Command:       "nmap",
+		ResumeSupport: &ResumeSupport{Flag: "--resume", StateFile: "nmap_output.gnmap", Only: true, DoneMarker: "# Nmap done"},
+	}
+
+	if _, ok := InterruptedRun(config, dir); ok {
+		t.Error("found an interrupted run without a state file")
+	}
+
+	state := filepath.Join(dir, "nmap_output.gnmap")
+	if err := os.WriteFile(state, []byte("# Nmap 7.94 scan initiated\nHost: 10.0.0.1 ()\tStatus: Up\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if file, ok := InterruptedRun(config, dir); !ok || file != "nmap_output.gnmap" {
+		t.Errorf("InterruptedRun = %q, %v, want the state file", file, ok)
+	}
+
+	f, err := os.OpenFile(state, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("# Nmap done at Sat Jun  1 12:00:00 2024 -- 1 IP address (1 host up) scanned\n")
+	f.Close()
+	if _, ok := InterruptedRun(config, dir); ok {
+		t.Error("a finished run counts as interrupted")
+	}
+}
+
+func TestResumeSupport_Validate(t *testing.T) {
+	valid := ToolConfig{Name: "nmap", Command: "nmap", ResumeSupport: &ResumeSupport{Flag: "--resume", StateFile: "nmap_output.txt"}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid resume_support: %v", err)
+	}
+
+	for name, config := range map[string]ToolConfig{
+		"no flag":       {Name: "nmap", Command: "nmap", ResumeSupport: &ResumeSupport{StateFile: "nmap_output.txt"}},
+		"no state file": {Name: "nmap", Command: "nmap", ResumeSupport: &ResumeSupport{Flag: "--resume"}},
+		"bad state":     {Name: "nmap", Command: "nmap", ResumeSupport: &ResumeSupport{Flag: "--resume", StateFile: "out;rm"}},
+		"replace":       {Name: "ffuf", Command: "ffuf", Replace: "{{url}}", ResumeSupport: &ResumeSupport{Flag: "-resume", StateFile: "ffuf.state"}},
+	} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package tools
+
+import "slices"
+
+// RetryTools picks the tools to run again in a scan directory: the failed
+// ones and every dependency of theirs, transitively, that failed too or left
+// no output in dir. It returns them in module order along with the names
+// of the tools that are not run again.
+func RetryTools(configs []ToolConfig, failed []string, dir string) (retry []ToolConfig, satisfied []string) {
+	byName := make(map[string]ToolConfig, len(configs))
+	for _, config := range configs {
+		byName[config.Name] = config
+	}
+
+	selected := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		config, ok := byName[name]
+		if !ok || selected[name] {
+			return
+		}
+		selected[name] = true
+		for _, dep := range config.DependsOn {
+			if slices.Contains(failed, dep) || !outputsPresent(byName[dep], dir) {
+				visit(dep)
+			}
+		}
+	}
+	for _, name := range failed {
+		visit(name)
+	}
+
+	for _, config := range configs {
+		if selected[config.Name] {
+			retry = append(retry, config)
+		} else {
+			satisfied = append(satisfied, config.Name)
+		}
+	}
+	return retry, satisfied
+}
+
+// outputsPresent reports whether a tool's declared outputs are still in dir.
+// A tool that declares none has nothing to redo.
+func outputsPresent(config ToolConfig, dir string) bool {
+	tool := &ConfigurableTool{name: config.Name, config: config}
+	declared := tool.declaredOutputs(dir)
+	if len(declared) == 0 {
+		// output_per_value runs declare theirs in a manifest, which is
+		// missing when they produced nothing
+		return config.OutputPerValue == ""
+	}
+	return anyNonEmpty(dir, declared)
+}
@@ -0,0 +1,71 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pipeliner/pkg/testutil"
+)
+
+func retryConfig(name, output string, deps ...string) ToolConfig {
+	return ToolConfig{
+		Name:      name,
+		Command:   name,
+		Flags:     []FlagConfig{{Flag: "-o", Option: "Output", Default: output}},
+		DependsOn: deps,
+	}
+}
+
+func retryNames(configs []ToolConfig) string {
+	names := make([]string, len(configs))
+	for i, config := range configs {
+		names[i] = config.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func TestRetryTools_AddsDependenciesWithoutOutput(t *testing.T) {
+	dir := t.TempDir()
+	// httpx left output; naabu's is empty so nmap needs it run again
+	outputs := map[string]string{"subfinder.txt": "a.example.com\n", "httpx.txt": "https://a.example.com\n", "naabu.txt": ""}
+	for file, content := range outputs {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configs := []ToolConfig{
+		retryConfig("subfinder", "subfinder.txt"),
+		retryConfig("httpx", "httpx.txt", "subfinder"),
+		retryConfig("naabu", "naabu.txt", "subfinder"),
+		retryConfig("nmap", "nmap.txt", "naabu"),
+		retryConfig("nuclei", "nuclei.txt", "httpx"),
+	}
+
+	retry, satisfied := RetryTools(configs, []string{"nmap", "nuclei"}, dir)
+	if got := retryNames(retry); got != "naabu,nmap,nuclei" {
+		t.Fatalf("retry = %s", got)
+	}
+	if got := strings.Join(satisfied, ","); got != "subfinder,httpx" {
+		t.Fatalf("satisfied = %s", got)
+	}
+}
+
+func TestHybridStrategy_SatisfiedDependencies(t *testing.T) {
+	ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+	defer cancel()
+
+	nuclei := NewMockTool("nuclei", "vuln", []string{"httpx"})
+	if err := (&HybridStrategy{}).Run(ctx, []Tool{nuclei}, &Options{}); err == nil {
+		t.Fatal("a dependency outside the run must still be rejected")
+	}
+
+	if err := (&HybridStrategy{}).Run(ctx, []Tool{nuclei}, &Options{Satisfied: []string{"httpx"}}); err != nil {
+		t.Fatalf("satisfied dependency: %v", err)
+	}
+	if nuclei.GetRunCount() != 1 {
+		t.Fatal("nuclei should run once its dependency is satisfied")
+	}
+}
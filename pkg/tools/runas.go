@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+const runAsKey contextKey = "run_as"
+
+// Credential is the user and groups a tool runs as.
+type Credential struct {
+	UID    uint32
+	GID    uint32
+	Groups []uint32
+}
+
+// ParseRunAs checks the syntax of a run_as value: a user name, a numeric uid,
+// or "uid:gid".
+func ParseRunAs(spec string) error {
+	if strings.TrimSpace(spec) == "" {
+		return fmt.Errorf("run_as is empty")
+	}
+	name, group, hasGroup := strings.Cut(spec, ":")
+	if hasGroup {
+		if _, err := strconv.ParseUint(name, 10, 32); err != nil {
+			return fmt.Errorf("run_as %q: uid must be numeric when a gid is given", spec)
+		}
+		if _, err := strconv.ParseUint(group, 10, 32); err != nil {
+			return fmt.Errorf("run_as %q: gid must be numeric", spec)
+		}
+	}
+	return nil
+}
+
+// ResolveRunAs looks up the credential for a run_as value. A user name or
+// uid takes that user's primary and supplementary groups; "uid:gid" uses
+// exactly the given ids.
+func ResolveRunAs(spec string) (*Credential, error) {
+	if err := ParseRunAs(spec); err != nil {
+		return nil, err
+	}
+
+	if uidStr, gidStr, ok := strings.Cut(spec, ":"); ok {
+		uid, _ := strconv.ParseUint(uidStr, 10, 32)
+		gid, _ := strconv.ParseUint(gidStr, 10, 32)
+		return &Credential{UID: uint32(uid), GID: uint32(gid)}, nil
+	}
+
+	var u *user.User
+	var err error
+	if _, numErr := strconv.ParseUint(spec, 10, 32); numErr == nil {
+		u, err = user.LookupId(spec)
+	} else {
+		u, err = user.Lookup(spec)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("run_as %q: %w", spec, err)
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("run_as %q: non-numeric uid %s", spec, u.Uid)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("run_as %q: non-numeric gid %s", spec, u.Gid)
+	}
+	cred := &Credential{UID: uint32(uid), GID: uint32(gid)}
+
+	if ids, err := u.GroupIds(); err == nil {
+		for _, id := range ids {
+			if g, err := strconv.ParseUint(id, 10, 32); err == nil && uint32(g) != cred.GID {
+				cred.Groups = append(cred.Groups, uint32(g))
+			}
+		}
+	}
+	return cred, nil
+}
+
+func withRunAs(ctx context.Context, cred *Credential) context.Context {
+	return context.WithValue(ctx, runAsKey, cred)
+}
+
+// RunAsFromContext is the credential the tool running in ctx should use, or
+// nil to keep the current user.
+func RunAsFromContext(ctx context.Context) *Credential {
+	cred, _ := ctx.Value(runAsKey).(*Credential)
+	return cred
+}
+
+// CanRunAs reports why the process cannot start programs as cred, or nil if
+// it can.
+func CanRunAs(cred *Credential) error {
+	return canSwitchCredential(cred)
+}
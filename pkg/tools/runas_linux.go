@@ -0,0 +1,51 @@
+//go:build linux
+
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	capSetGID = 6
+	capSetUID = 7
+)
+
+func canSwitchCredential(cred *Credential) error {
+	if cred == nil || (uint32(os.Geteuid()) == cred.UID && uint32(os.Getegid()) == cred.GID) {
+		return nil
+	}
+
+	caps, err := effectiveCapabilities()
+	if err != nil {
+		return fmt.Errorf("cannot read process capabilities: %w", err)
+	}
+	if caps&(1<<capSetUID) == 0 || caps&(1<<capSetGID) == 0 {
+		return fmt.Errorf("switching to uid %d gid %d needs root or CAP_SETUID and CAP_SETGID", cred.UID, cred.GID)
+	}
+	return nil
+}
+
+// effectiveCapabilities reads the CapEff mask from /proc/self/status.
+func effectiveCapabilities() (uint64, error) {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "CapEff:"); ok {
+			return strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("CapEff not found")
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package tools
+
+import "errors"
+
+var errRunAsUnsupported = errors.New("run_as is only supported on Linux")
+
+func canSwitchCredential(cred *Credential) error {
+	if cred == nil {
+		return nil
+	}
+	return errRunAsUnsupported
+}
@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveRunAs(t *testing.T) {
+	cred, err := ResolveRunAs("1000:2000")
+	require.NoError(t, err)
+	assert.Equal(t, &Credential{UID: 1000, GID: 2000}, cred)
+
+	current, err := user.Current()
+	require.NoError(t, err)
+	for _, spec := range []string{current.Username, current.Uid} {
+		cred, err := ResolveRunAs(spec)
+		require.NoError(t, err, spec)
+		assert.Equal(t, uint32(os.Getuid()), cred.UID, spec)
+	}
+
+	_, err = ResolveRunAs("no-such-pipeliner-user")
+	assert.Error(t, err)
+	_, err = ResolveRunAs("1000:")
+	assert.Error(t, err)
+}
+
+type credentialRecorder struct {
+	cred *Credential
+}
+
+func (r *credentialRecorder) Run(ctx context.Context, command string, args []string) error {
+	r.cred = RunAsFromContext(ctx)
+	return nil
+}
+
+func TestConfigurableTool_PassesRunAsToRunner(t *testing.T) {
+	recorder := &credentialRecorder{}
+	tool := NewConfigurableTool("nuclei", "vuln", ToolConfig{Name: "nuclei", Command: "nuclei", RunAs: "1000:1000"}, recorder)
+	require.NoError(t, tool.Run(context.Background(), DefaultOptions()))
+	assert.Equal(t, &Credential{UID: 1000, GID: 1000}, recorder.cred)
+
+	recorder.cred = nil
+	tool = NewConfigurableTool("nmap", "recon", ToolConfig{Name: "nmap", Command: "nmap"}, recorder)
+	require.NoError(t, tool.Run(context.Background(), DefaultOptions()))
+	assert.Nil(t, recorder.cred)
+
+	tool = NewConfigurableTool("httpx", "recon", ToolConfig{Name: "httpx", Command: "httpx", RunAs: "no-such-pipeliner-user"}, recorder)
+	assert.Error(t, tool.Run(context.Background(), DefaultOptions()))
+}
+
+func TestCanRunAs_CurrentUser(t *testing.T) {
+	assert.NoError(t, CanRunAs(nil))
+	assert.NoError(t, CanRunAs(&Credential{UID: uint32(os.Geteuid()), GID: uint32(os.Getegid())}))
+}
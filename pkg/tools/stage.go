@@ -3,6 +3,7 @@ package tools
 import (
 	"pipeliner/pkg/logger"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -18,6 +19,16 @@ const (
 	StageVuln           Stage = "vuln_scan"
 )
 
+// Stages lists the stages in the order a scan reaches them.
+var Stages = []Stage{StageSubdomain, StageRecon, StageFingerPrinting, StageVuln}
+
+var knownStages = map[Stage]bool{
+	StageSubdomain:      true,
+	StageRecon:          true,
+	StageFingerPrinting: true,
+	StageVuln:           true,
+}
+
 func stageForToolType(toolType string) Stage {
 	switch toolType {
 	case "domain_enum":
@@ -38,13 +49,24 @@ type stageTracker struct {
 	completed      map[string]bool
 	stageTools     map[Stage][]string
 	stageCompleted map[Stage]bool
+
+	// stages start with their first tool; budgets are measured from there
+	timeouts     map[Stage]time.Duration
+	stageStarted map[Stage]time.Time
+	now          func() time.Time
 }
 
-func newStageTracker(tools []Tool) *stageTracker {
+func newStageTracker(tools []Tool, timeouts map[Stage]time.Duration, now func() time.Time) *stageTracker {
+	if now == nil {
+		now = time.Now
+	}
 	st := &stageTracker{
 		completed:      make(map[string]bool),
 		stageTools:     make(map[Stage][]string),
 		stageCompleted: make(map[Stage]bool),
+		timeouts:       timeouts,
+		stageStarted:   make(map[Stage]time.Time),
+		now:            now,
 	}
 	for _, t := range tools {
 		stage := stageForToolType(t.Type())
@@ -55,6 +77,36 @@ func newStageTracker(tools []Tool) *stageTracker {
 	return st
 }
 
+// startTool records the stage start on its first tool and returns the stage
+// deadline, or a zero time if the stage has no budget.
+func (st *stageTracker) startTool(t Tool) (Stage, time.Time) {
+	stage := stageForToolType(t.Type())
+	if stage == "" {
+		return stage, time.Time{}
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	started, ok := st.stageStarted[stage]
+	if !ok {
+		started = st.now()
+		st.stageStarted[stage] = started
+	}
+	budget, ok := st.timeouts[stage]
+	if !ok || budget <= 0 {
+		return stage, time.Time{}
+	}
+	return stage, started.Add(budget)
+}
+
+// startedAt is when stage's first tool started, or a zero time if none has.
+func (st *stageTracker) startedAt(stage Stage) time.Time {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.stageStarted[stage]
+}
+
 func (st *stageTracker) markCompleted(toolName string) Stage {
 	st.mu.Lock()
 	defer st.mu.Unlock()
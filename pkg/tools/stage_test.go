@@ -0,0 +1,184 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	perrors "pipeliner/pkg/errors"
+	"pipeliner/pkg/testutil"
+)
+
+// timedMockTool is a MockTool that carries its own run timeout.
+type timedMockTool struct {
+	*MockTool
+	timeout time.Duration
+}
+
+func (m *timedMockTool) Timeout() time.Duration { return m.timeout }
+
+func blockUntilDone(ctx context.Context, _ *Options) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func failuresByTool(t *testing.T, err error) map[string]error {
+	t.Helper()
+	var partial *PartialExecutionError
+	if !errors.As(err, &partial) {
+		t.Fatalf("expected PartialExecutionError, got %v", err)
+	}
+	failures := make(map[string]error)
+	for _, f := range partial.FailedTools {
+		failures[f.Tool] = f.Err
+	}
+	return failures
+}
+
+func TestSequentialStrategy_StageBudgetExhausted(t *testing.T) {
+	ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+	defer cancel()
+
+	clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	subfinder := NewMockTool("subfinder", "domain_enum", nil)
+	subfinder.SetRunFunc(func(context.Context, *Options) error {
+		clock.Advance(31 * time.Minute)
+		return nil
+	})
+	amass := NewMockTool("amass", "domain_enum", nil)
+	nuclei := NewMockTool("nuclei", "vuln", nil)
+	nuclei.SetRunFunc(func(context.Context, *Options) error {
+		clock.Advance(2 * time.Hour)
+		return nil
+	})
+
+	strategy := &SequentialStrategy{
+		StageTimeouts: map[Stage]time.Duration{
+			StageSubdomain: 30 * time.Minute,
+			StageVuln:      6 * time.Hour,
+		},
+		now: clock.Now,
+	}
+
+	err := strategy.Run(ctx, []Tool{subfinder, amass, nuclei}, &Options{})
+	failures := failuresByTool(t, err)
+
+	testutil.AssertEquals(t, 1, len(failures))
+	testutil.AssertEquals(t, true, errors.Is(failures["amass"], perrors.ErrStageTimeout))
+	testutil.AssertEquals(t, 0, amass.GetRunCount())
+	testutil.AssertEquals(t, 1, nuclei.GetRunCount())
+
+	var stageErr *perrors.StageTimeoutError
+	testutil.AssertEquals(t, true, errors.As(failures["amass"], &stageErr))
+	testutil.AssertEquals(t, string(StageSubdomain), stageErr.Stage)
+}
+
+func TestConcurrentStrategy_StageAndToolTimeouts(t *testing.T) {
+	ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+	defer cancel()
+
+	slowRecon := NewMockTool("slow-recon", "recon", nil)
+	slowRecon.SetRunFunc(blockUntilDone)
+
+	slowFingerprint := &timedMockTool{MockTool: NewMockTool("slow-fingerprint", "fingerprint", nil), timeout: 20 * time.Millisecond}
+	slowFingerprint.SetRunFunc(blockUntilDone)
+
+	fast := NewMockTool("fast", "recon", nil)
+
+	strategy := &ConcurrentStrategy{
+		StageTimeouts: map[Stage]time.Duration{StageRecon: 50 * time.Millisecond},
+	}
+
+	err := strategy.Run(ctx, []Tool{slowRecon, slowFingerprint, fast}, &Options{})
+	failures := failuresByTool(t, err)
+
+	testutil.AssertEquals(t, 2, len(failures))
+	testutil.AssertEquals(t, true, errors.Is(failures["slow-recon"], perrors.ErrStageTimeout))
+	testutil.AssertEquals(t, false, errors.Is(failures["slow-recon"], perrors.ErrToolTimeout))
+	testutil.AssertEquals(t, true, errors.Is(failures["slow-fingerprint"], perrors.ErrToolTimeout))
+	testutil.AssertEquals(t, false, errors.Is(failures["slow-fingerprint"], perrors.ErrStageTimeout))
+	testutil.AssertEquals(t, 1, fast.GetRunCount())
+}
+
+func TestHybridStrategy_StageTimeoutSkipsDependents(t *testing.T) {
+	ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+	defer cancel()
+
+	subfinder := NewMockTool("subfinder", "domain_enum", nil)
+	subfinder.SetRunFunc(blockUntilDone)
+	httpx := NewMockTool("httpx", "recon", []string{"subfinder"})
+
+	// the tool's own timeout is longer than the stage budget, so the stage wins
+	nuclei := &timedMockTool{MockTool: NewMockTool("nuclei", "vuln", nil), timeout: time.Hour}
+	nuclei.SetRunFunc(blockUntilDone)
+
+	strategy := &HybridStrategy{
+		StageTimeouts: map[Stage]time.Duration{
+			StageSubdomain: 30 * time.Millisecond,
+			StageVuln:      30 * time.Millisecond,
+		},
+	}
+
+	err := strategy.Run(ctx, []Tool{subfinder, httpx, nuclei}, &Options{})
+	failures := failuresByTool(t, err)
+
+	testutil.AssertEquals(t, true, errors.Is(failures["subfinder"], perrors.ErrStageTimeout))
+	testutil.AssertEquals(t, true, errors.Is(failures["nuclei"], perrors.ErrStageTimeout))
+	testutil.AssertError(t, failures["httpx"])
+	testutil.AssertEquals(t, 0, httpx.GetRunCount())
+}
+
+func TestSequentialStrategy_StageHooksGetStageStart(t *testing.T) {
+	ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+	defer cancel()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := testutil.NewFakeClock(start)
+
+	saved := stageHooks[StageVuln]
+	t.Cleanup(func() { stageHooks[StageVuln] = saved })
+	stageHooks[StageVuln] = nil
+	var startedAt time.Time
+	RegisterStageHook(StageVuln, stageHookFunc(func(ctx HookContext) error {
+		startedAt = ctx.StageStartedAt
+		return nil
+	}))
+
+	subfinder := NewMockTool("subfinder", "domain_enum", nil)
+	subfinder.SetRunFunc(func(context.Context, *Options) error {
+		clock.Advance(10 * time.Minute)
+		return nil
+	})
+	nuclei := NewMockTool("nuclei", "vuln", nil)
+	nuclei.SetRunFunc(func(context.Context, *Options) error {
+		clock.Advance(time.Hour)
+		return nil
+	})
+
+	// no stage budgets: the start is recorded all the same
+	strategy := &SequentialStrategy{now: clock.Now}
+	testutil.AssertNoError(t, strategy.Run(ctx, []Tool{subfinder, nuclei}, &Options{}))
+	testutil.AssertEquals(t, start.Add(10*time.Minute), startedAt)
+}
+
+func TestChainConfig_ValidateStageTimeouts(t *testing.T) {
+	base := ChainConfig{
+		ExecutionMode: "sequential",
+		Tools:         []ToolConfig{{Name: "subfinder", Command: "subfinder"}},
+	}
+
+	valid := base
+	valid.StageTimeouts = map[string]time.Duration{"subdomain_enum": 30 * time.Minute, "vuln_scan": 6 * time.Hour}
+	testutil.AssertNoError(t, valid.Validate())
+	testutil.AssertEquals(t, 30*time.Minute, valid.StageBudgets()[StageSubdomain])
+
+	unknown := base
+	unknown.StageTimeouts = map[string]time.Duration{"bogus": time.Minute}
+	testutil.AssertError(t, unknown.Validate())
+
+	negative := base
+	negative.StageTimeouts = map[string]time.Duration{"recon": -time.Minute}
+	testutil.AssertError(t, negative.Validate())
+}
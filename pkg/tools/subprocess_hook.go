@@ -0,0 +1,312 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultSubprocessHookTimeout bounds a subprocess hook without a
+	// timeout.
+	DefaultSubprocessHookTimeout = 5 * time.Minute
+	// maxSubprocessHookOutput is how much of a hook's stdout is read for
+	// warnings, and maxSubprocessHookStderr how much of the end of its
+	// stderr ends up in its error.
+	maxSubprocessHookOutput = 1 << 20
+	maxSubprocessHookStderr = 2048
+)
+
+// subprocessHookEnv are the variables of pipeliner's environment every
+// subprocess hook gets. The rest, which may hold credentials, are left out
+// unless the hook's env names them.
+var subprocessHookEnv = []string{"PATH", "LANG", "LC_ALL", "TZ"}
+
+// SubprocessHookConfig registers an executable as a hook of the module. It
+// can be named in prehooks, posthooks, pre_run and cleanup like a built-in
+// hook, which it takes the place of.
+type SubprocessHookConfig struct {
+	Name string `yaml:"name" mapstructure:"name"`
+	// Path is the executable, relative to the module's directory unless
+	// absolute. It takes no arguments.
+	Path    string        `yaml:"path" mapstructure:"path"`
+	Timeout time.Duration `yaml:"timeout,omitempty" mapstructure:"timeout"`
+	// Critical, true unless set, makes a failure fail the tool, as with
+	// built-in hooks.
+	Critical *bool `yaml:"critical,omitempty" mapstructure:"critical"`
+	// Env names the variables of pipeliner's environment passed on to the
+	// hook, on top of PATH and the locale.
+	Env []string `yaml:"env,omitempty" mapstructure:"env"`
+}
+
+func (hc SubprocessHookConfig) Validate() error {
+	if hc.Name == "" {
+		return fmt.Errorf("subprocess hook name is required")
+	}
+	if hc.Path == "" {
+		return fmt.Errorf("path is required for subprocess hook %s", hc.Name)
+	}
+	if hc.Timeout < 0 {
+		return fmt.Errorf("timeout must be non-negative for subprocess hook %s", hc.Name)
+	}
+	for _, name := range hc.Env {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return fmt.Errorf("invalid env name %q for subprocess hook %s", name, hc.Name)
+		}
+	}
+	return nil
+}
+
+// SubprocessHookInput is what a subprocess hook reads as JSON on stdin.
+type SubprocessHookInput struct {
+	Hook      string `json:"hook"`
+	OutputDir string `json:"output_dir"`
+	// ToolName is empty for pre_run and cleanup hooks.
+	ToolName string `json:"tool_name,omitempty"`
+	Scan     struct {
+		ID     string `json:"id,omitempty"`
+		Type   string `json:"type,omitempty"`
+		Domain string `json:"domain,omitempty"`
+	} `json:"scan"`
+	// Params are the pre_run or cleanup entry's params.
+	Params map[string]string `json:"params,omitempty"`
+	// Artifacts are the outputs of the hook's tool, or of every tool for
+	// pre_run and cleanup hooks.
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// subprocessHookMessage is a line of a subprocess hook's stdout.
+// {"warning": "..."} becomes a hook warning; other lines are ignored.
+type subprocessHookMessage struct {
+	Warning string `json:"warning"`
+}
+
+// SubprocessHook runs an executable as a hook: it gets a
+// SubprocessHookInput on stdin, runs in the scan directory with a scrubbed
+// environment, and fails by exiting non-zero.
+type SubprocessHook struct {
+	config SubprocessHookConfig
+	path   string
+}
+
+// NewSubprocessHooks checks the module's subprocess hooks and returns them
+// by name. Relative paths are in dir.
+func NewSubprocessHooks(configs []SubprocessHookConfig, dir string) (map[string]*SubprocessHook, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	hooks := make(map[string]*SubprocessHook, len(configs))
+	for _, hc := range configs {
+		path := hc.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		// the hook runs in the scan directory
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("subprocess hook %s: %w", hc.Name, err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("subprocess hook %s: %w", hc.Name, err)
+		}
+		if info.IsDir() || info.Mode().Perm()&0111 == 0 {
+			return nil, fmt.Errorf("subprocess hook %s: %s is not executable", hc.Name, path)
+		}
+		hooks[hc.Name] = &SubprocessHook{config: hc, path: path}
+	}
+	return hooks, nil
+}
+
+func (h *SubprocessHook) Name() string { return h.config.Name }
+
+func (h *SubprocessHook) Description() string { return "Runs " + h.path }
+
+func (h *SubprocessHook) Critical() bool {
+	return h.config.Critical == nil || *h.config.Critical
+}
+
+func (h *SubprocessHook) Execute(hookCtx HookContext) error {
+	input, err := h.input(hookCtx)
+	if err != nil {
+		return err
+	}
+
+	timeout := h.config.Timeout
+	if timeout == 0 {
+		timeout = DefaultSubprocessHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(hookCtx.Context(), timeout)
+	defer cancel()
+
+	stdout := limitedBuffer{limit: maxSubprocessHookOutput}
+	stderr := tailBuffer{limit: maxSubprocessHookStderr}
+	cmd := exec.CommandContext(ctx, h.path)
+	cmd.Dir = hookCtx.OutputDir
+	cmd.Env = h.environment(hookCtx.OutputDir)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// children that keep the pipes open must not hold the scan up
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	h.reportWarnings(hookCtx, stdout.Bytes())
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("subprocess hook %s timed out after %s", h.config.Name, timeout)
+	}
+	if err != nil {
+		if tail := stderr.String(); tail != "" {
+			return fmt.Errorf("subprocess hook %s failed: %w: %s", h.config.Name, err, tail)
+		}
+		return fmt.Errorf("subprocess hook %s failed: %w", h.config.Name, err)
+	}
+	return nil
+}
+
+func (h *SubprocessHook) input(hookCtx HookContext) ([]byte, error) {
+	input := SubprocessHookInput{Hook: h.config.Name, OutputDir: hookCtx.OutputDir, ToolName: hookCtx.ToolName}
+	if options := hookCtx.Options; options != nil {
+		input.Scan.ID = options.ScanID
+		input.Scan.Type = options.ScanType
+		input.Scan.Domain = options.Domain
+	}
+	for key, value := range hookCtx.OtherData {
+		if s, ok := value.(string); ok {
+			if input.Params == nil {
+				input.Params = make(map[string]string)
+			}
+			input.Params[key] = s
+		}
+	}
+
+	artifacts := hookCtx.Artifacts()
+	var err error
+	if hookCtx.ToolName != "" {
+		input.Artifacts, err = artifacts.ListByTool(hookCtx.ToolName)
+	} else {
+		input.Artifacts, err = artifacts.List()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("subprocess hook %s: failed to list artifacts: %w", h.config.Name, err)
+	}
+	if input.Artifacts == nil {
+		input.Artifacts = []Artifact{}
+	}
+	return json.Marshal(input)
+}
+
+// environment is the hook's scrubbed environment, with HOME in the scan
+// directory so nothing is written to pipeliner's home.
+func (h *SubprocessHook) environment(dir string) []string {
+	env := []string{"HOME=" + dir}
+	for _, name := range append(append([]string(nil), subprocessHookEnv...), h.config.Env...) {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+func (h *SubprocessHook) reportWarnings(hookCtx HookContext, stdout []byte) {
+	options := hookCtx.Options
+	if options == nil || options.OnHookWarning == nil {
+		return
+	}
+	target := hookCtx.ToolName
+	if target == "" {
+		target = phaseTarget
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(stdout))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSubprocessHookOutput)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		var msg subprocessHookMessage
+		if err := json.Unmarshal(line, &msg); err != nil || msg.Warning == "" {
+			continue
+		}
+		options.OnHookWarning(HookWarning{Hook: h.config.Name, Tool: target, Err: fmt.Errorf("%s", msg.Warning)})
+	}
+}
+
+// tailBuffer keeps the last limit bytes written to it, so a chatty hook's
+// error ends with its final lines without holding all of its output.
+type tailBuffer struct {
+	last    []byte
+	limit   int
+	dropped bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.last = append(b.last, p...)
+	if len(b.last) > 2*b.limit {
+		b.last = append(b.last[:0], b.last[len(b.last)-b.limit:]...)
+		b.dropped = true
+	}
+	return len(p), nil
+}
+
+// String is the end of the output, trimmed, starting with "..." if earlier
+// output was dropped.
+func (b *tailBuffer) String() string {
+	tail, dropped := b.last, b.dropped
+	if len(tail) > b.limit {
+		tail, dropped = tail[len(tail)-b.limit:], true
+	}
+	trimmed := strings.TrimSpace(string(tail))
+	if dropped && trimmed != "" {
+		return "..." + trimmed
+	}
+	return trimmed
+}
+
+// limitedBuffer keeps the first limit bytes written to it and drops the
+// rest, so a chatty hook cannot exhaust memory.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// resolvePostHook returns the module's subprocess hook called name, or else
+// the registered post hook, and whether its failure is critical.
+func resolvePostHook(name string, options *Options) (PostHook, bool) {
+	if options != nil {
+		if hook, ok := options.SubprocessHooks[name]; ok {
+			return hook, hook.Critical()
+		}
+	}
+	hook := GetPostHook(name)
+	return hook, IsPostHookCritical(name)
+}
+
+// resolvePreHook is resolvePostHook for pre hooks.
+func resolvePreHook(name string, options *Options) (PreHook, bool) {
+	if options != nil {
+		if hook, ok := options.SubprocessHooks[name]; ok {
+			return hook, hook.Critical()
+		}
+	}
+	hook := GetPreHook(name)
+	return hook, IsPreHookCritical(name)
+}
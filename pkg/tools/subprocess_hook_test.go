@@ -0,0 +1,307 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pipeliner/pkg/testutil"
+)
+
+// writeHookScript writes an executable shell script into dir.
+func writeHookScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newSubprocessHooks(t *testing.T, dir string, configs ...SubprocessHookConfig) map[string]*SubprocessHook {
+	t.Helper()
+	hooks, err := NewSubprocessHooks(configs, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hooks
+}
+
+type hookWarnings struct {
+	mu       sync.Mutex
+	warnings []HookWarning
+}
+
+func (w *hookWarnings) add(warning HookWarning) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.warnings = append(w.warnings, warning)
+}
+
+func TestSubprocessHook_PostHook(t *testing.T) {
+	modules := t.TempDir()
+	writeHookScript(t, modules, "ok.sh", `cat > input.json
+echo 'not a message'
+echo '{"warning": "2 hosts skipped"}'
+`)
+	writeHookScript(t, modules, "fail.sh", "echo 'cmdb unreachable' >&2\nexit 3\n")
+	notCritical := false
+
+	t.Run("gets its input and reports warnings", func(t *testing.T) {
+		scanDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(scanDir, "subs.txt"), []byte("a.example.com\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteOutputManifest(filepath.Join(scanDir, OutputManifestFile("subfinder")),
+			&OutputManifest{Tool: "subfinder", Stage: StageRecon, Files: []string{"subs.txt"}}); err != nil {
+			t.Fatal(err)
+		}
+
+		var warnings hookWarnings
+		options := &Options{
+			ScanID:          "scan-1",
+			ScanType:        "subdomain",
+			Domain:          "example.com",
+			WorkingDir:      scanDir,
+			OnHookWarning:   warnings.add,
+			SubprocessHooks: newSubprocessHooks(t, modules, SubprocessHookConfig{Name: "push", Path: "ok.sh"}),
+		}
+		if err := executePostHooks(context.Background(), "subfinder", []string{"push"}, options); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(scanDir, "input.json"))
+		if err != nil {
+			t.Fatalf("hook did not run in the scan directory: %v", err)
+		}
+		var input SubprocessHookInput
+		if err := json.Unmarshal(data, &input); err != nil {
+			t.Fatal(err)
+		}
+		testutil.AssertEquals(t, "push", input.Hook)
+		testutil.AssertEquals(t, scanDir, input.OutputDir)
+		testutil.AssertEquals(t, "subfinder", input.ToolName)
+		testutil.AssertEquals(t, "scan-1", input.Scan.ID)
+		testutil.AssertEquals(t, "example.com", input.Scan.Domain)
+		if want := []Artifact{{Tool: "subfinder", Stage: StageRecon, Name: "subs.txt"}}; !reflect.DeepEqual(want, input.Artifacts) {
+			t.Fatalf("expected artifacts %v, got %v", want, input.Artifacts)
+		}
+
+		testutil.AssertEquals(t, 1, len(warnings.warnings))
+		testutil.AssertEquals(t, "push", warnings.warnings[0].Hook)
+		testutil.AssertEquals(t, "subfinder", warnings.warnings[0].Tool)
+		testutil.AssertEquals(t, "2 hosts skipped", warnings.warnings[0].Err.Error())
+	})
+
+	t.Run("critical failure fails the tool", func(t *testing.T) {
+		options := &Options{
+			WorkingDir:      t.TempDir(),
+			SubprocessHooks: newSubprocessHooks(t, modules, SubprocessHookConfig{Name: "push", Path: "fail.sh"}),
+		}
+		err := executePostHooks(context.Background(), "subfinder", []string{"push"}, options)
+		if err == nil || !strings.Contains(err.Error(), "cmdb unreachable") {
+			t.Fatalf("expected the hook's stderr in the error, got %v", err)
+		}
+	})
+
+	t.Run("non-critical failure only warns", func(t *testing.T) {
+		var warnings hookWarnings
+		options := &Options{
+			WorkingDir:    t.TempDir(),
+			OnHookWarning: warnings.add,
+			SubprocessHooks: newSubprocessHooks(t, modules,
+				SubprocessHookConfig{Name: "push", Path: "fail.sh", Critical: &notCritical}),
+		}
+		if err := executePostHooks(context.Background(), "subfinder", []string{"push"}, options); err != nil {
+			t.Fatal(err)
+		}
+		testutil.AssertEquals(t, 1, len(warnings.warnings))
+		if !strings.Contains(warnings.warnings[0].Err.Error(), "exit status 3") {
+			t.Fatalf("unexpected warning %v", warnings.warnings[0].Err)
+		}
+	})
+
+	t.Run("runs as a pre hook", func(t *testing.T) {
+		tool := NewMockTool("nuclei", "vuln", nil)
+		tool.preHooks = []string{"push"}
+		options := &Options{
+			WorkingDir:      t.TempDir(),
+			SubprocessHooks: newSubprocessHooks(t, modules, SubprocessHookConfig{Name: "push", Path: "fail.sh"}),
+		}
+		if err := executePreHooks(context.Background(), tool, options); err == nil {
+			t.Fatal("expected the critical pre hook to fail the tool")
+		}
+	})
+}
+
+func TestSubprocessHook_ErrorEndsWithLastStderr(t *testing.T) {
+	modules := t.TempDir()
+	// over a MiB of noise before the failure
+	writeHookScript(t, modules, "chatty.sh", `head -c 1100000 /dev/zero | tr '\0' x >&2
+echo >&2
+echo 'cmdb unreachable' >&2
+exit 3
+`)
+	hooks := newSubprocessHooks(t, modules, SubprocessHookConfig{Name: "chatty", Path: "chatty.sh"})
+
+	err := hooks["chatty"].Execute(HookContext{ctx: context.Background(), OutputDir: t.TempDir(), Options: &Options{}})
+	if err == nil || !strings.HasSuffix(err.Error(), "cmdb unreachable") {
+		t.Fatalf("expected the end of the hook's stderr in the error, got %.200q", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, ": ...xxx") || len(msg) > maxSubprocessHookStderr+200 {
+		t.Fatalf("expected only the last %d bytes of stderr, got %d bytes", maxSubprocessHookStderr, len(msg))
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{"short", []string{"a\n", "b\n"}, "a\nb"},
+		{"exactly the limit", []string{"abcd"}, "abcd"},
+		{"over the limit", []string{"ab", "cdef"}, "...cdef"},
+		{"compacted", []string{"abcdefghij", "k"}, "...hijk"},
+		{"blank end", []string{"abcdef", "    "}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tailBuffer{limit: 4}
+			for _, w := range tt.writes {
+				if n, err := b.Write([]byte(w)); n != len(w) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v", w, n, err)
+				}
+			}
+			testutil.AssertEquals(t, tt.want, b.String())
+		})
+	}
+}
+
+func TestSubprocessHook_Timeout(t *testing.T) {
+	modules := t.TempDir()
+	writeHookScript(t, modules, "slow.sh", "sleep 10\n")
+	hooks := newSubprocessHooks(t, modules, SubprocessHookConfig{Name: "slow", Path: "slow.sh", Timeout: 100 * time.Millisecond})
+
+	start := time.Now()
+	err := hooks["slow"].Execute(HookContext{ctx: context.Background(), OutputDir: t.TempDir(), Options: &Options{}})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("hook was not stopped at its timeout, took %s", elapsed)
+	}
+}
+
+func TestSubprocessHook_Environment(t *testing.T) {
+	t.Setenv("PIPELINER_TEST_SECRET", "hunter2")
+	t.Setenv("CMDB_URL", "https://cmdb.example.com")
+	modules := t.TempDir()
+	writeHookScript(t, modules, "env.sh", "env > env.txt\n")
+	hooks := newSubprocessHooks(t, modules, SubprocessHookConfig{Name: "env", Path: "env.sh", Env: []string{"CMDB_URL"}})
+
+	scanDir := t.TempDir()
+	if err := hooks["env"].Execute(HookContext{ctx: context.Background(), OutputDir: scanDir, Options: &Options{}}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(scanDir, "env.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := string(data)
+	if strings.Contains(env, "hunter2") {
+		t.Fatal("hook saw a variable it was not given")
+	}
+	for _, want := range []string{"CMDB_URL=https://cmdb.example.com", "HOME=" + scanDir, "PATH="} {
+		if !strings.Contains(env, want) {
+			t.Fatalf("expected %s in the hook's environment:\n%s", want, env)
+		}
+	}
+}
+
+func TestSubprocessHook_PhaseHooks(t *testing.T) {
+	modules := t.TempDir()
+	writeHookScript(t, modules, "cleanup.sh", "cat > input.json\n")
+	scanDir := t.TempDir()
+	options := &Options{
+		WorkingDir:      scanDir,
+		SubprocessHooks: newSubprocessHooks(t, modules, SubprocessHookConfig{Name: "archive", Path: "cleanup.sh"}),
+	}
+
+	hooks := []PhaseHookConfig{{Hook: "archive", Params: map[string]string{"bucket": "scans"}}}
+	if err := ExecuteCleanupHooks(context.Background(), hooks, options); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(scanDir, "input.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var input SubprocessHookInput
+	if err := json.Unmarshal(data, &input); err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEquals(t, "", input.ToolName)
+	testutil.AssertEquals(t, "scans", input.Params["bucket"])
+	if input.Artifacts == nil || len(input.Artifacts) != 0 {
+		t.Fatalf("expected an empty artifact list, got %v", input.Artifacts)
+	}
+}
+
+func TestSubprocessHook_ExampleScript(t *testing.T) {
+	var warnings hookWarnings
+	options := &Options{
+		WorkingDir:    t.TempDir(),
+		OnHookWarning: warnings.add,
+		SubprocessHooks: newSubprocessHooks(t, filepath.Join("..", "..", "config"),
+			SubprocessHookConfig{Name: "cmdb_push", Path: "hooks/cmdb_push.sh", Env: []string{"CMDB_URL"}}),
+	}
+	t.Setenv("CMDB_URL", "")
+
+	if err := executePostHooks(context.Background(), "subfinder", []string{"cmdb_push"}, options); err != nil {
+		t.Fatal(err)
+	}
+	testutil.AssertEquals(t, 1, len(warnings.warnings))
+	testutil.AssertEquals(t, "CMDB_URL is not set, nothing was pushed", warnings.warnings[0].Err.Error())
+}
+
+func TestNewSubprocessHooks(t *testing.T) {
+	modules := t.TempDir()
+	if err := os.WriteFile(filepath.Join(modules, "plain.sh"), []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewSubprocessHooks([]SubprocessHookConfig{{Name: "plain", Path: "plain.sh"}}, modules); err == nil {
+		t.Fatal("expected a non-executable hook to be refused")
+	}
+	_, err := NewSubprocessHooks([]SubprocessHookConfig{{Name: "missing", Path: "missing.sh"}}, modules)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected a missing hook to be refused, got %v", err)
+	}
+}
+
+func TestChainConfigValidate_SubprocessHooks(t *testing.T) {
+	tests := []struct {
+		name  string
+		hooks []SubprocessHookConfig
+	}{
+		{"missing name", []SubprocessHookConfig{{Path: "hook.sh"}}},
+		{"missing path", []SubprocessHookConfig{{Name: "push"}}},
+		{"negative timeout", []SubprocessHookConfig{{Name: "push", Path: "hook.sh", Timeout: -time.Second}}},
+		{"duplicate", []SubprocessHookConfig{{Name: "push", Path: "a.sh"}, {Name: "push", Path: "b.sh"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cc := ChainConfig{ExecutionMode: "sequential", Tools: []ToolConfig{{Name: "subfinder", Type: "subfinder"}}, SubprocessHooks: tt.hooks}
+			if err := cc.Validate(); err == nil || !strings.Contains(err.Error(), "subprocess_hooks") {
+				t.Fatalf("expected a subprocess_hooks error, got %v", err)
+			}
+		})
+	}
+}
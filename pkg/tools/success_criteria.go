@@ -0,0 +1,174 @@
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pipeliner/pkg/errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Success criteria actions.
+const (
+	SuccessCriteriaFail = "fail"
+	SuccessCriteriaWarn = "warn"
+)
+
+// maxCriteriaLine is the longest output line the success criteria read.
+const maxCriteriaLine = 1 << 20
+
+// SuccessCriteria are checked against the outputs in the tool's manifest
+// after a clean exit, for tools whose empty or odd output still exits 0.
+type SuccessCriteria struct {
+	// MinLines is the fewest non-blank lines the outputs need between them.
+	MinLines int `yaml:"min_lines,omitempty" mapstructure:"min_lines"`
+	// MustMatch is a regex at least one output line has to match.
+	MustMatch string `yaml:"must_match,omitempty" mapstructure:"must_match"`
+	// MustNotMatch is a regex no output line may match.
+	MustNotMatch string `yaml:"must_not_match,omitempty" mapstructure:"must_not_match"`
+	// Action is "fail", the default, to fail the tool on a violation, or
+	// "warn" to complete it with a warning.
+	Action string `yaml:"action,omitempty" mapstructure:"action"`
+}
+
+func (sc *SuccessCriteria) Validate() error {
+	if sc.MinLines < 0 {
+		return fmt.Errorf("success_criteria min_lines must be non-negative")
+	}
+	for name, pattern := range map[string]string{"must_match": sc.MustMatch, "must_not_match": sc.MustNotMatch} {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid success_criteria %s %q: %w", name, pattern, err)
+		}
+	}
+	if sc.Action != "" && sc.Action != SuccessCriteriaFail && sc.Action != SuccessCriteriaWarn {
+		return fmt.Errorf("success_criteria action must be %s or %s, got %q", SuccessCriteriaFail, SuccessCriteriaWarn, sc.Action)
+	}
+	return nil
+}
+
+// outputScan is what the success criteria need of a tool's outputs.
+type outputScan struct {
+	files    []string
+	lines    int
+	matched  bool
+	excluded string
+}
+
+// checkSuccessCriteria evaluates the tool's success_criteria after a clean
+// exit. A violation fails the tool, or with action warn is reported as a
+// tool warning and the tool completes.
+func (t *ConfigurableTool) checkSuccessCriteria(options *Options) error {
+	sc := t.config.SuccessCriteria
+	if sc == nil {
+		return nil
+	}
+	var mustMatch, mustNotMatch *regexp.Regexp
+	if sc.MustMatch != "" {
+		mustMatch = regexp.MustCompile(sc.MustMatch)
+	}
+	if sc.MustNotMatch != "" {
+		mustNotMatch = regexp.MustCompile(sc.MustNotMatch)
+	}
+
+	dir := getOutputDir(options)
+	scan, err := scanOutputs(dir, t.manifestOutputs(dir), mustMatch, mustNotMatch)
+	if err != nil {
+		return fmt.Errorf("failed to check success criteria of tool %s: %w", t.name, err)
+	}
+
+	outputs := "the outputs"
+	if len(scan.files) == 0 {
+		outputs = "its outputs (none recorded)"
+	} else if len(scan.files) == 1 {
+		outputs = scan.files[0]
+	}
+	var violations []*errors.SuccessCriteriaError
+	if scan.lines < sc.MinLines {
+		violations = append(violations, errors.NewSuccessCriteriaError(t.name, "min_lines",
+			fmt.Sprintf("%d lines in %s, want at least %d", scan.lines, outputs, sc.MinLines)))
+	}
+	if mustMatch != nil && !scan.matched {
+		violations = append(violations, errors.NewSuccessCriteriaError(t.name, "must_match",
+			fmt.Sprintf("no line of %s matches %q", outputs, sc.MustMatch)))
+	}
+	if scan.excluded != "" {
+		violations = append(violations, errors.NewSuccessCriteriaError(t.name, "must_not_match",
+			fmt.Sprintf("%s has a line matching %q: %s", outputs, sc.MustNotMatch, scan.excluded)))
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	if sc.Action != SuccessCriteriaWarn {
+		return violations[0]
+	}
+	for _, v := range violations {
+		t.logger.WithTool(t.name, t.tool_type).Warnf("%v", v)
+		if options != nil && options.OnToolWarning != nil {
+			options.OnToolWarning(ToolWarning{Tool: t.name, Pattern: "success_criteria." + v.Criterion, Line: v.Error(), Notify: t.config.NotifyWarnings})
+		}
+	}
+	return nil
+}
+
+// manifestOutputs are the files the tool's manifest lists, run once or per
+// value.
+func (t *ConfigurableTool) manifestOutputs(dir string) []string {
+	manifest, err := ReadOutputManifest(filepath.Join(dir, OutputManifestFile(t.name)))
+	if err != nil {
+		return nil
+	}
+	files := append([]string(nil), manifest.Files...)
+	for _, file := range manifest.Outputs {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// scanOutputs counts the non-blank lines of files, relative to dir, and
+// looks for lines matching mustMatch and mustNotMatch, either of which may
+// be nil. Files that do not exist count as empty.
+func scanOutputs(dir string, files []string, mustMatch, mustNotMatch *regexp.Regexp) (outputScan, error) {
+	scan := outputScan{files: files}
+	for _, file := range files {
+		path := file
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return scan, err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), maxCriteriaLine)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			scan.lines++
+			if mustMatch != nil && !scan.matched && mustMatch.MatchString(line) {
+				scan.matched = true
+			}
+			if mustNotMatch != nil && scan.excluded == "" && mustNotMatch.MatchString(line) {
+				if len(line) > maxWarningLine {
+					line = line[:maxWarningLine]
+				}
+				scan.excluded = line
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return scan, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+	}
+	return scan, nil
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	perrors "pipeliner/pkg/errors"
+)
+
+func criteriaTool(dir, content string, criteria SuccessCriteria) *ConfigurableTool {
+	config := ToolConfig{
+		Name:            "subfinder",
+		Command:         "subfinder",
+		Flags:           []FlagConfig{{Flag: "-o", Option: "Output", Default: "subfinder.txt"}},
+		SuccessCriteria: &criteria,
+	}
+	return NewConfigurableTool("subfinder", "domain_enum", config, &contentRunner{dir: dir, content: content}).(*ConfigurableTool)
+}
+
+func TestSuccessCriteria(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		criteria  SuccessCriteria
+		criterion string
+		detail    string
+	}{
+		{name: "enough lines", content: "a.example.com\nb.example.com\n\n", criteria: SuccessCriteria{MinLines: 2}},
+		{name: "too few lines", content: "a.example.com\n\n\n", criteria: SuccessCriteria{MinLines: 2},
+			criterion: "min_lines", detail: "1 lines in subfinder.txt, want at least 2"},
+		{name: "no output", criteria: SuccessCriteria{MinLines: 1},
+			criterion: "min_lines", detail: "0 lines in its outputs (none recorded), want at least 1"},
+		{name: "must match", content: "a.example.com\nwww.example.com\n", criteria: SuccessCriteria{MustMatch: `^www\.`}},
+		{name: "must match missing", content: "a.example.com\n", criteria: SuccessCriteria{MustMatch: `^www\.`},
+			criterion: "must_match", detail: `no line of subfinder.txt matches "^www\\."`},
+		{name: "must not match", content: "a.example.com\n", criteria: SuccessCriteria{MustNotMatch: `(?i)unauthorized`}},
+		{name: "must not match found", content: "a.example.com\nError: Unauthorized\n", criteria: SuccessCriteria{MustNotMatch: `(?i)unauthorized`},
+			criterion: "must_not_match", detail: `subfinder.txt has a line matching "(?i)unauthorized": Error: Unauthorized`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tool := criteriaTool(dir, tt.content, tt.criteria)
+			if tt.content == "" {
+				// no -o output at all
+				tool.config.Flags = nil
+			}
+
+			err := tool.Run(context.Background(), &Options{WorkingDir: dir})
+			if tt.criterion == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, perrors.ErrSuccessCriteria) {
+				t.Fatalf("err = %v, want ErrSuccessCriteria", err)
+			}
+			var criteriaErr *perrors.SuccessCriteriaError
+			if !errors.As(err, &criteriaErr) {
+				t.Fatalf("err = %v, want a SuccessCriteriaError", err)
+			}
+			if criteriaErr.Criterion != tt.criterion || criteriaErr.Detail != tt.detail {
+				t.Fatalf("got criterion %q detail %q, want %q %q", criteriaErr.Criterion, criteriaErr.Detail, tt.criterion, tt.detail)
+			}
+		})
+	}
+}
+
+func TestSuccessCriteria_WarnCompletesTheTool(t *testing.T) {
+	dir := t.TempDir()
+	tool := criteriaTool(dir, "a.example.com\n", SuccessCriteria{MinLines: 100, MustMatch: `^www\.`, Action: SuccessCriteriaWarn})
+
+	var warnings []ToolWarning
+	options := &Options{WorkingDir: dir, OnToolWarning: func(w ToolWarning) { warnings = append(warnings, w) }}
+	if err := tool.Run(context.Background(), options); err != nil {
+		t.Fatalf("a warn criterion should not fail the tool: %v", err)
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("got %d warnings, want one per violated criterion: %+v", len(warnings), warnings)
+	}
+	if warnings[0].Pattern != "success_criteria.min_lines" || !strings.Contains(warnings[0].Line, "want at least 100") {
+		t.Errorf("unexpected warning %+v", warnings[0])
+	}
+	if warnings[1].Pattern != "success_criteria.must_match" {
+		t.Errorf("unexpected warning %+v", warnings[1])
+	}
+}
+
+func TestSuccessCriteria_Validate(t *testing.T) {
+	for _, criteria := range []SuccessCriteria{
+		{MinLines: -1},
+		{MustMatch: "("},
+		{MustNotMatch: "[a-"},
+		{Action: "ignore"},
+	} {
+		if err := criteria.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", criteria)
+		}
+	}
+	if err := (&SuccessCriteria{MinLines: 10, MustMatch: `\.example\.com$`, Action: SuccessCriteriaWarn}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
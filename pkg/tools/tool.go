@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 	"pipeliner/pkg/logger"
 	"strings"
@@ -13,25 +14,50 @@ import (
 
 type contextKey string
 
-const workingDirKey contextKey = "working_dir"
-
-func withWorkingDir(ctx context.Context, dir string) context.Context {
-	return context.WithValue(ctx, workingDirKey, dir)
-}
-
-func GetWorkingDirFromContext(ctx context.Context) string {
-	if dir, ok := ctx.Value(workingDirKey).(string); ok {
-		return dir
+// commandDir is where a tool's commands start: the scan's working
+// directory, or the process's own when there is none.
+func commandDir(options *Options) string {
+	if options == nil || options.WorkingDir == "." {
+		return ""
 	}
-	return ""
+	return options.WorkingDir
 }
 
+// Progress statuses reported by the strategies through Options.OnProgress.
+const (
+	ProgressCoolingDown      = "CoolingDown"
+	ProgressCooldownFinished = "CooldownFinished"
+	ProgressDAGChanged       = "DAGChanged"
+	ProgressPaused           = "Paused"
+	ProgressResumed          = "Resumed"
+	ProgressCancelled        = "Cancelled"
+	// ProgressCompletedEmpty is a clean exit that left every declared
+	// output empty.
+	ProgressCompletedEmpty = "CompletedEmpty"
+	// ProgressSkipped is a skip_if_output_exists tool that found its
+	// output and did not run.
+	ProgressSkipped = "Skipped"
+	// ProgressDryRun carries a command line a dry run would have run.
+	ProgressDryRun = "DryRun"
+)
+
 type ProgressEvent struct {
 	Tool      string
 	Status    string
 	Message   string
 	Timestamp time.Time
-	ack       chan struct{}
+	// DAG is set on ProgressDAGChanged events from the hybrid strategy.
+	DAG *DAGSnapshot
+	// Nice is set on a tool's Started event when its commands run at a
+	// niceness from the scan's priority.
+	Nice *int
+	// Resumed is set on a tool's Started event when it continues an
+	// interrupted run instead of starting over.
+	Resumed bool
+	// Attempt is set on ProgressRetrying events to the attempt about to
+	// start, the first run being 1.
+	Attempt int
+	ack     chan struct{}
 }
 
 type ConfigurableTool struct {
@@ -42,6 +68,10 @@ type ConfigurableTool struct {
 	progress     chan ProgressEvent
 	toolRegistry ToolRegistry
 	logger       *logger.Logger
+
+	// emptyOutputs are the declared outputs the last run left empty, set
+	// only when all of them were
+	emptyOutputs []string
 }
 
 func NewConfigurableTool(name string, tool_type string, config ToolConfig, runner CommandRunner) Tool {
@@ -106,42 +136,95 @@ func (t *ConfigurableTool) Type() string {
 
 func (t *ConfigurableTool) DependsOn() []string { return t.config.DependsOn }
 
+func (t *ConfigurableTool) PreHooks() []string { return t.config.PreHooks }
+
 func (t *ConfigurableTool) PostHooks() []string { return t.config.PostHooks }
 
+// Config is the configuration the tool was built from.
+func (t *ConfigurableTool) Config() ToolConfig { return t.config }
+
+func (t *ConfigurableTool) Timeout() time.Duration { return t.config.Timeout }
+
+func (t *ConfigurableTool) CooldownAfter() time.Duration { return t.config.CooldownAfter }
+
 func (t *ConfigurableTool) Run(ctx context.Context, options *Options) error {
+	if file, ok := t.existingOutput(options); ok && !isRetry(ctx) {
+		return t.skip(options, file)
+	}
+
 	done := make(chan bool, 1)
 	eventAck := make(chan struct{})
-	go t.monitorProgress(ctx, done)
+	go t.monitorProgress(ctx, done, options)
 
-	if options != nil && options.WorkingDir != "" && options.WorkingDir != "." {
-		ctx = withWorkingDir(ctx, options.WorkingDir)
+	if options != nil && options.Pause != nil {
+		ctx = withPauseGate(ctx, options.Pause)
 	}
+	if env := t.config.Environment(options); len(env) > 0 {
+		ctx = withEnvironment(ctx, env)
+	}
+	ctx = withHostBudget(ctx, options)
+	ctx = t.outputLimitContext(ctx, options)
+	ctx = t.warningContext(ctx, options)
+	ctx = t.commandLogContext(ctx, options)
 
-	t.sendProgress(ProgressEvent{
+	started := ProgressEvent{
 		Tool:      t.name,
 		Status:    "Started",
 		Message:   "Running command",
 		Timestamp: time.Now(),
-	})
+	}
+	if nice, ok := niceFor(options); ok {
+		ctx = withNice(ctx, nice)
+		started.Nice = &nice
+	}
+	stateFile, resuming := t.resumeFrom(options)
+	if resuming {
+		started.Message = "Resuming from " + stateFile
+		started.Resumed = true
+	}
+	t.sendProgress(started)
 
 	// Build args and run tool
 	args, buildErr := t.config.BuildArgs(options)
+	if resuming && buildErr == nil {
+		t.logger.WithTool(t.name, t.tool_type).Infof("Resuming %s from %s", t.name, stateFile)
+		args = t.config.ResumeSupport.resumeArgs(args, stateFile)
+	}
 	var err error
 	if buildErr != nil {
 		err = fmt.Errorf("failed to build arguments: %w", buildErr)
-	} else {
+	} else if ctx, err = t.runAsContext(ctx); err == nil {
 		// Check if this tool requires replacement logic
-		if t.config.Replace != "" {
+		if options != nil && options.DryRun {
+			err = t.dryRun(args, options)
+		} else if t.config.Replace != "" {
 			err = t.runWithReplacement(ctx, args, options)
 		} else {
 			t.logger.WithTool(t.name, t.tool_type).Infof("Executing command: %s %s", t.config.Command, strings.Join(args, " "))
-			err = t.runner.Run(ctx, t.config.Command, args)
+			t.noteHostActivity(ctx)
+			err = RunIn(ctx, t.runner, commandDir(options), t.config.Command, args)
 		}
+		if t.config.OutputPerValue == "" {
+			t.writeOutputManifest(options)
+		}
+	}
+
+	t.emptyOutputs = nil
+	if err == nil && (options == nil || !options.DryRun) {
+		err = t.checkOutputs(options)
+		if err == nil {
+			err = t.checkSuccessCriteria(options)
+		}
+	}
+	if err != nil && t.config.OnFailure != nil {
+		err = t.captureFailure(ctx, options, err)
 	}
 
 	status := "Completed"
 	if err != nil {
 		status = "Failed"
+	} else if len(t.emptyOutputs) > 0 {
+		status = ProgressCompletedEmpty
 	}
 	t.sendProgressWithAck(ProgressEvent{
 		Status:    status,
@@ -153,28 +236,102 @@ func (t *ConfigurableTool) Run(ctx context.Context, options *Options) error {
 	return err
 }
 
-func (t *ConfigurableTool) runWithReplacement(ctx context.Context, args []string, options *Options) error {
-	replaceFromFile := t.config.ReplaceFrom
-	if replaceFromFile == "" && len(t.config.DependsOn) > 0 {
-		replaceFromFile = t.inferReplacementFile(t.config.DependsOn[0])
+// runAsContext resolves the tool's run_as user for the runner.
+func (t *ConfigurableTool) runAsContext(ctx context.Context) (context.Context, error) {
+	if t.config.RunAs == "" {
+		return ctx, nil
+	}
+	cred, err := ResolveRunAs(t.config.RunAs)
+	if err != nil {
+		return ctx, fmt.Errorf("tool %s: %w", t.name, err)
 	}
+	return withRunAs(ctx, cred), nil
+}
 
-	if replaceFromFile == "" {
-		return fmt.Errorf("no replacement file specified for tool %s with replace token %s", t.name, t.config.Replace)
+func (t *ConfigurableTool) runWithReplacement(ctx context.Context, args []string, options *Options) error {
+	replaceFromFiles, err := t.replacementFiles(options)
+	if err != nil {
+		return err
 	}
 
-	if !filepath.IsAbs(replaceFromFile) && options != nil && options.WorkingDir != "" {
-		replaceFromFile = filepath.Join(options.WorkingDir, replaceFromFile)
+	spec := ReplacementSpec{
+		Token:          t.config.Replace,
+		Files:          replaceFromFiles,
+		Dir:            commandDir(options),
+		Concurrency:    t.config.ReplaceConcurrency,
+		OutputTemplate: t.config.OutputPerValue,
+		Tool:           t.name,
+		Stage:          stageForToolType(t.tool_type),
+	}
+	if spec.OutputTemplate != "" {
+		spec.ManifestPath = OutputManifestFile(t.name)
 	}
+	t.chunkSpec(ctx, &spec, options)
 
 	if replacementRunner, ok := t.runner.(ReplacementCommandRunner); ok {
-		t.logger.WithTool(t.name, t.tool_type).Infof("Executing replacement command: %s with token %s from file %s", t.config.Command, t.config.Replace, replaceFromFile)
-		return replacementRunner.RunWithReplacement(ctx, t.config.Command, args, t.config.Replace, replaceFromFile)
+		t.logger.WithTool(t.name, t.tool_type).Infof("Executing replacement command: %s with token %s from files %v", t.config.Command, t.config.Replace, replaceFromFiles)
+		return replacementRunner.RunWithReplacementSpec(ctx, t.config.Command, args, spec)
 	}
 
 	return fmt.Errorf("runner does not support replacement for tool %s", t.name)
 }
 
+// replacementFiles lists the files the replace token's values come from:
+// replace_from, or else the outputs of the tool's dependencies.
+func (t *ConfigurableTool) replacementFiles(options *Options) ([]string, error) {
+	sources := t.config.ReplaceFrom
+	if len(sources) == 0 {
+		sources = t.inferReplacementFiles(t.config.DependsOn)
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no replacement file specified for tool %s with replace token %s", t.name, t.config.Replace)
+	}
+	return t.resolveReplacementFiles(sources, options)
+}
+
+// resolveReplacementFiles expands globs in the working directory, keeping
+// the configured order. Relative paths stay relative to it; the runner
+// resolves them against ReplacementSpec.Dir.
+func (t *ConfigurableTool) resolveReplacementFiles(sources []string, options *Options) ([]string, error) {
+	dir := commandDir(options)
+	var files []string
+	for _, source := range sources {
+		if !strings.ContainsAny(source, "*?[") {
+			files = append(files, source)
+			continue
+		}
+
+		pattern := source
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replace_from pattern %s for tool %s: %w", source, t.name, err)
+		}
+		if len(matches) == 0 {
+			t.logger.WithTool(t.name, t.tool_type).Warnf("replace_from pattern %s matched no files", source)
+		}
+		for _, match := range matches {
+			if !filepath.IsAbs(source) {
+				match, _ = filepath.Rel(dir, match)
+			}
+			files = append(files, match)
+		}
+	}
+	return files, nil
+}
+
+// inferReplacementFiles collects the output file of every dependency.
+func (t *ConfigurableTool) inferReplacementFiles(dependencies []string) []string {
+	var files []string
+	for _, dep := range dependencies {
+		files = append(files, t.inferReplacementFile(dep))
+	}
+	return files
+}
+
 func (t *ConfigurableTool) inferReplacementFile(dependencyName string) string {
 	if t.toolRegistry != nil {
 		if depConfig, exists := t.toolRegistry.GetToolConfig(dependencyName); exists {
@@ -200,6 +357,34 @@ func (t *ConfigurableTool) ExtractOutputFileFromConfig(config *ToolConfig) strin
 	return t.extractOutputFileFromConfig(config)
 }
 
+// writeOutputManifest records the output files the run left behind so
+// hooks can find them through Artifacts. Replacement runs with
+// output_per_value write their own manifest.
+func (t *ConfigurableTool) writeOutputManifest(options *Options) {
+	if options != nil && options.DryRun {
+		return
+	}
+	dir := getOutputDir(options)
+	var files []string
+	for _, file := range t.declaredOutputs(dir) {
+		path := file
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return
+	}
+
+	manifest := &OutputManifest{Tool: t.name, Stage: stageForToolType(t.tool_type), Files: files}
+	if err := WriteOutputManifest(filepath.Join(dir, OutputManifestFile(t.name)), manifest); err != nil {
+		t.logger.WithTool(t.name, t.tool_type).Warnf("Failed to write output manifest: %v", err)
+	}
+}
+
 func (t *ConfigurableTool) isOutputFlag(flag FlagConfig) bool {
 	outputFlags := []string{"-o", "--output", "-output", "--out", "-out"}
 	outputOptions := []string{"Output", "OutputFile", "Out", "output", "outputfile", "out"}
@@ -219,9 +404,10 @@ func (t *ConfigurableTool) isOutputFlag(flag FlagConfig) bool {
 	return false
 }
 
-func (t *ConfigurableTool) monitorProgress(ctx context.Context, done chan bool) {
+func (t *ConfigurableTool) monitorProgress(ctx context.Context, done chan bool, options *Options) {
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
+	running := t.logger.NewSampler(nil)
 
 	for {
 		select {
@@ -231,7 +417,13 @@ func (t *ConfigurableTool) monitorProgress(ctx context.Context, done chan bool)
 		case <-done:
 			return
 		case event := <-t.progress:
-			t.logger.WithTool(t.name, t.tool_type).Infof("Tool: %s, Progress Event: %v, Message: %s, Timestamp: %s", event.Tool, event.Status, event.Message, event.Timestamp)
+			// the periodic "running" ticks of a long tool are sampled
+			if event.Status != "Running" || running.Next() {
+				t.logger.WithTool(t.name, t.tool_type).Infof("Tool: %s, Progress Event: %v, Message: %s, Timestamp: %s", event.Tool, event.Status, event.Message, event.Timestamp)
+			}
+			if options != nil && options.OnToolProgress != nil {
+				options.OnToolProgress(event)
+			}
 			if event.ack != nil {
 				close(event.ack)
 			}
@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"pipeliner/pkg/errors"
+	"time"
+)
+
+// DefaultRetryBackoff is the wait before a tool's first retry without a
+// retry_backoff. Each later retry waits twice as long as the one before.
+const DefaultRetryBackoff = 5 * time.Second
+
+// ProgressRetrying is reported before each retry of a failed tool.
+const ProgressRetrying = "Retrying"
+
+const retryAttemptKey contextKey = "retry_attempt"
+
+// toolRetries is implemented by tools that are run again when they fail.
+type toolRetries interface {
+	Retries() int
+	RetryBackoff() time.Duration
+}
+
+func (t *ConfigurableTool) Retries() int { return t.config.Retries }
+
+func (t *ConfigurableTool) RetryBackoff() time.Duration {
+	if t.config.RetryBackoff > 0 {
+		return t.config.RetryBackoff
+	}
+	return DefaultRetryBackoff
+}
+
+// isRetry reports whether ctx runs a retry of a failed attempt, which must
+// not skip on the output the failed attempt left.
+func isRetry(ctx context.Context) bool {
+	attempt, _ := ctx.Value(retryAttemptKey).(int)
+	return attempt > 1
+}
+
+// runTool runs t, and runs it again up to its retries when it fails, with
+// an exponential backoff in between, waited out with after (time.After when
+// nil). Post hooks run once the strategy gets a success, so failed attempts
+// never trigger them. Cancellation and an exhausted stage budget are not
+// retried.
+func runTool(ctx context.Context, t Tool, options *Options, tracker *stageTracker, after func(time.Duration) <-chan time.Time) error {
+	if after == nil {
+		after = time.After
+	}
+	retries, backoff := 0, time.Duration(0)
+	if tr, ok := t.(toolRetries); ok {
+		retries, backoff = tr.Retries(), tr.RetryBackoff()
+	}
+
+	attempt := 1
+	for {
+		err := runAttempt(context.WithValue(ctx, retryAttemptKey, attempt), t, options, tracker)
+		if err == nil || attempt > retries || !retryable(ctx, err) {
+			if err != nil && attempt > 1 {
+				return fmt.Errorf("tool %s failed after %d attempts: %w", t.Name(), attempt, err)
+			}
+			return err
+		}
+
+		attempt++
+		chainLogger.Warnf("Tool %s failed, retrying in %s (attempt %d of %d): %v", t.Name(), backoff, attempt, retries+1, err)
+		reportProgress(options, ProgressEvent{
+			Tool:      t.Name(),
+			Status:    ProgressRetrying,
+			Message:   fmt.Sprintf("attempt %d of %d in %s: %v", attempt, retries+1, backoff, err),
+			Timestamp: time.Now(),
+			Attempt:   attempt,
+		})
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("tool %s failed after %d attempts: %w", t.Name(), attempt-1, err)
+		case <-after(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return !stderrors.Is(err, errors.ErrStageTimeout)
+}
@@ -0,0 +1,205 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pipeliner/pkg/testutil"
+)
+
+// flakyRunner fails its first failures runs, leaving a partial -o output,
+// and writes the whole output in the ones after.
+type flakyRunner struct {
+	mu       sync.Mutex
+	dir      string
+	failures int
+	runs     int
+}
+
+var errFlaky = errors.New("connection reset")
+
+func (r *flakyRunner) Run(ctx context.Context, command string, args []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs++
+	if r.runs <= r.failures {
+		if err := (&contentRunner{dir: r.dir, content: "partial"}).Run(ctx, command, args); err != nil {
+			return err
+		}
+		return errFlaky
+	}
+	return (&contentRunner{dir: r.dir, content: "a.example.com\n"}).Run(ctx, command, args)
+}
+
+func (r *flakyRunner) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.runs
+}
+
+// countingHook counts its runs.
+type countingHook struct {
+	mu   sync.Mutex
+	runs int
+}
+
+func (h *countingHook) Name() string        { return "counting" }
+func (h *countingHook) Description() string { return "counts its runs" }
+func (h *countingHook) Execute(HookContext) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.runs++
+	return nil
+}
+
+// registerTestPostHook registers hook for the rest of the test only.
+func registerTestPostHook(t *testing.T, name string, hook PostHook) {
+	t.Helper()
+	RegisterPostHook(name, hook)
+	t.Cleanup(func() { delete(postHookRegistry, name) })
+}
+
+func flakyTool(runner *flakyRunner, retries int, backoff time.Duration, postHooks ...string) Tool {
+	return NewConfigurableTool("subfinder", "domain_enum", ToolConfig{
+		Name:         "subfinder",
+		Command:      "subfinder",
+		Flags:        []FlagConfig{{Flag: "-o", Option: "Output", Default: "subfinder.txt"}},
+		Retries:      retries,
+		RetryBackoff: backoff,
+		PostHooks:    postHooks,
+		// a failed attempt's output must not make the retry skip
+		SkipIfOutputExists: true,
+	}, runner)
+}
+
+func TestRetries_RunAgainUntilSuccess(t *testing.T) {
+	hook := &countingHook{}
+	registerTestPostHook(t, "test-retry-counting-hook", hook)
+
+	strategies := map[string]func() ExecutionStrategy{
+		"sequential": func() ExecutionStrategy { return &SequentialStrategy{} },
+		"concurrent": func() ExecutionStrategy { return &ConcurrentStrategy{} },
+		"hybrid":     func() ExecutionStrategy { return &HybridStrategy{} },
+	}
+	for name, strategy := range strategies {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+			defer cancel()
+			hook.mu.Lock()
+			hook.runs = 0
+			hook.mu.Unlock()
+
+			dir := t.TempDir()
+			runner := &flakyRunner{dir: dir, failures: 2}
+			var mu sync.Mutex
+			var attempts []int
+			options := &Options{WorkingDir: dir, OnProgress: func(e ProgressEvent) {
+				if e.Status == ProgressRetrying {
+					mu.Lock()
+					attempts = append(attempts, e.Attempt)
+					mu.Unlock()
+				}
+			}}
+
+			err := strategy().Run(ctx, []Tool{flakyTool(runner, 3, time.Millisecond, "test-retry-counting-hook")}, options)
+			testutil.AssertNoError(t, err)
+			testutil.AssertEquals(t, 3, runner.count())
+			mu.Lock()
+			if !reflect.DeepEqual(attempts, []int{2, 3}) {
+				t.Errorf("retrying events for attempts %v, want [2 3]", attempts)
+			}
+			mu.Unlock()
+			hook.mu.Lock()
+			testutil.AssertEquals(t, 1, hook.runs)
+			hook.mu.Unlock()
+		})
+	}
+}
+
+func TestRetries_BackoffDoublesOnTheStrategyClock(t *testing.T) {
+	strategies := map[string]func(after func(time.Duration) <-chan time.Time) ExecutionStrategy{
+		"sequential": func(after func(time.Duration) <-chan time.Time) ExecutionStrategy {
+			return &SequentialStrategy{after: after}
+		},
+		"concurrent": func(after func(time.Duration) <-chan time.Time) ExecutionStrategy {
+			return &ConcurrentStrategy{after: after}
+		},
+		"hybrid": func(after func(time.Duration) <-chan time.Time) ExecutionStrategy {
+			return &HybridStrategy{after: after}
+		},
+	}
+	for name, strategy := range strategies {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+			defer cancel()
+			clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+			dir := t.TempDir()
+			runner := &flakyRunner{dir: dir, failures: 2}
+			done := make(chan error, 1)
+			go func() {
+				done <- strategy(clock.After).Run(ctx, []Tool{flakyTool(runner, 2, time.Hour)}, &Options{WorkingDir: dir})
+			}()
+
+			clock.WaitForWaiters(t, 1)
+			testutil.AssertEquals(t, 1, runner.count())
+			clock.Advance(time.Hour)
+
+			// the second retry waits twice as long
+			clock.WaitForWaiters(t, 1)
+			testutil.AssertEquals(t, 2, runner.count())
+			clock.Advance(time.Hour)
+			testutil.AssertEquals(t, 1, clock.Waiters())
+			clock.Advance(time.Hour)
+
+			testutil.AssertNoError(t, <-done)
+			testutil.AssertEquals(t, 3, runner.count())
+		})
+	}
+}
+
+func TestRetries_GiveUpAfterLastAttempt(t *testing.T) {
+	ctx, cancel := testutil.WithTimeout(t, 5*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	runner := &flakyRunner{dir: dir, failures: 10}
+	err := runTool(ctx, flakyTool(runner, 2, time.Millisecond), &Options{WorkingDir: dir}, newStageTracker(nil, nil, nil), nil)
+	if !errors.Is(err, errFlaky) {
+		t.Fatalf("err = %v, want the last attempt's error", err)
+	}
+	if !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Fatalf("err = %v, want the number of attempts", err)
+	}
+	testutil.AssertEquals(t, 3, runner.count())
+}
+
+func TestRetries_StopWhenCancelledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	dir := t.TempDir()
+	runner := &flakyRunner{dir: dir, failures: 10}
+	options := &Options{WorkingDir: dir, OnProgress: func(e ProgressEvent) {
+		if e.Status == ProgressRetrying {
+			cancel()
+		}
+	}}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- runTool(ctx, flakyTool(runner, 5, time.Hour), options, newStageTracker(nil, nil, nil), nil)
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errFlaky) {
+			t.Fatalf("err = %v, want the failed attempt's error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runTool kept waiting after the context was cancelled")
+	}
+	testutil.AssertEquals(t, 1, runner.count())
+}
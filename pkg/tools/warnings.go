@@ -0,0 +1,162 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const warningMatcherKey contextKey = "warning_matcher"
+
+// maxWarningLine is how much of a stderr line a ToolWarning keeps.
+const maxWarningLine = 500
+
+// defaultWarningPatterns are the stderr lines of the known tools that are
+// worth a warning even though the tool exits 0. A tool's warning_patterns
+// add to them.
+var defaultWarningPatterns = map[string][]string{
+	// a provider that failed or ran out of quota leaves subdomains out
+	"subfinder": {
+		`(?i)could not run source`,
+		`(?i)\b(rate.?limit|quota exceeded|too many requests|status code 429)\b`,
+		`(?i)(invalid|missing|unauthori[sz]ed).*(api.?key|credential)`,
+	},
+	// templates that fail to load are silently not run
+	"nuclei": {
+		`(?i)could not (load|parse|compile) template`,
+		`(?i)templates? (failed|errored)`,
+		`(?i)\bno templates (provided|found|loaded)\b`,
+	},
+}
+
+// ToolWarning is a line a tool wrote to stderr that matched one of its
+// warning patterns.
+type ToolWarning struct {
+	Tool    string
+	Pattern string
+	Line    string
+	// Notify is the tool's notify_warnings.
+	Notify bool
+}
+
+// WarningMatcher finds the warnings in a tool's stderr.
+type WarningMatcher struct {
+	tool     string
+	notify   bool
+	patterns []*regexp.Regexp
+	report   func(ToolWarning)
+
+	mu       sync.Mutex
+	reported map[string]bool
+}
+
+// NewWarningMatcher compiles patterns for tool; report receives what Report
+// finds.
+func NewWarningMatcher(tool string, patterns []string, notify bool, report func(ToolWarning)) (*WarningMatcher, error) {
+	m := &WarningMatcher{tool: tool, notify: notify, report: report}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid warning pattern %q: %w", pattern, err)
+		}
+		m.patterns = append(m.patterns, re)
+	}
+	return m, nil
+}
+
+// Match returns a warning for each distinct stderr line that matches a
+// pattern, with the first pattern it matched.
+func (m *WarningMatcher) Match(stderr string) []ToolWarning {
+	var warnings []ToolWarning
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || seen[line] {
+			continue
+		}
+		for _, re := range m.patterns {
+			if re.MatchString(line) {
+				seen[line] = true
+				if len(line) > maxWarningLine {
+					line = line[:maxWarningLine]
+				}
+				warnings = append(warnings, ToolWarning{Tool: m.tool, Pattern: re.String(), Line: line, Notify: m.notify})
+				break
+			}
+		}
+	}
+	return warnings
+}
+
+// Report passes the warnings in stderr to the matcher's report func.
+func (m *WarningMatcher) Report(stderr string) {
+	if m.report == nil {
+		return
+	}
+	for _, w := range m.Match(stderr) {
+		m.report(w)
+	}
+}
+
+// ReportLine reports the warning in one stderr line, as a runner streaming
+// stderr sees them. Each distinct line is reported once per matcher.
+func (m *WarningMatcher) ReportLine(line string) {
+	if m.report == nil {
+		return
+	}
+	warnings := m.Match(line)
+	if len(warnings) == 0 {
+		return
+	}
+	m.mu.Lock()
+	if m.reported[warnings[0].Line] {
+		m.mu.Unlock()
+		return
+	}
+	if m.reported == nil {
+		m.reported = make(map[string]bool)
+	}
+	m.reported[warnings[0].Line] = true
+	m.mu.Unlock()
+	m.report(warnings[0])
+}
+
+// WithWarningMatcher has the runner report the warnings in the stderr of
+// the commands run with ctx.
+func WithWarningMatcher(ctx context.Context, m *WarningMatcher) context.Context {
+	return context.WithValue(ctx, warningMatcherKey, m)
+}
+
+func WarningMatcherFromContext(ctx context.Context) *WarningMatcher {
+	m, _ := ctx.Value(warningMatcherKey).(*WarningMatcher)
+	return m
+}
+
+// warningPatterns are the tool's warning_patterns after the defaults for
+// its command.
+func (tc *ToolConfig) warningPatterns() []string {
+	defaults := defaultWarningPatterns[filepath.Base(tc.Command)]
+	return append(append([]string(nil), defaults...), tc.WarningPatterns...)
+}
+
+// warningContext has the tool's stderr checked for its warning patterns
+// when the scan takes warnings.
+func (t *ConfigurableTool) warningContext(ctx context.Context, options *Options) context.Context {
+	if options == nil || options.OnToolWarning == nil {
+		return ctx
+	}
+	patterns := t.config.warningPatterns()
+	if len(patterns) == 0 {
+		return ctx
+	}
+	m, err := NewWarningMatcher(t.name, patterns, t.config.NotifyWarnings, options.OnToolWarning)
+	if err != nil {
+		// Validate rejects these, so only a tool built by hand gets here
+		t.logger.WithTool(t.name, t.tool_type).Warnf("Ignoring warning patterns: %v", err)
+		return ctx
+	}
+	return WithWarningMatcher(ctx, m)
+}
@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWarningMatcher_DefaultPatterns(t *testing.T) {
+	tests := []struct {
+		command string
+		stderr  string
+		want    []string
+	}{
+		{
+			command: "subfinder",
+			stderr: `[INF] Enumerating subdomains for example.com
+[WRN] Could not run source virustotal: 429 Too Many Requests
+[WRN] Could not run source virustotal: 429 Too Many Requests
+[ERR] securitytrails: invalid API key
+[INF] Found 42 subdomains for example.com in 3 seconds`,
+			want: []string{
+				"[WRN] Could not run source virustotal: 429 Too Many Requests",
+				"[ERR] securitytrails: invalid API key",
+			},
+		},
+		{
+			command: "/usr/local/bin/nuclei",
+			stderr: `[INF] Templates loaded for current scan: 812
+[ERR] Could not load template http/cves/2024/CVE-2024-0001.yaml: unknown field
+[WRN] Found 2 templates with runtime error (use -validate flag for further examination)`,
+			want: []string{"[ERR] Could not load template http/cves/2024/CVE-2024-0001.yaml: unknown field"},
+		},
+		{
+			command: "httpx",
+			stderr:  "[WRN] rate limited by provider",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			config := ToolConfig{Name: "tool", Command: tt.command}
+			m, err := NewWarningMatcher("tool", config.warningPatterns(), false, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, w := range m.Match(tt.stderr) {
+				got = append(got, w.Line)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Fatalf("Match() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWarningMatcher_ToolPatternsAddToDefaults(t *testing.T) {
+	config := ToolConfig{Name: "ffuf", Command: "ffuf", WarningPatterns: []string{`(?i)wordlist truncated`}}
+	m, err := NewWarningMatcher("ffuf", config.warningPatterns(), true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := m.Match("Wordlist truncated to 10000 lines\nprogress: 50%\n")
+	if len(got) != 1 || got[0].Tool != "ffuf" || got[0].Pattern != `(?i)wordlist truncated` || !got[0].Notify {
+		t.Fatalf("Match() = %+v", got)
+	}
+
+	long := "wordlist truncated " + strings.Repeat("x", 2*maxWarningLine)
+	if got := m.Match(long); len(got[0].Line) != maxWarningLine {
+		t.Fatalf("warning line is %d bytes, want %d", len(got[0].Line), maxWarningLine)
+	}
+
+	if _, err := NewWarningMatcher("ffuf", []string{"("}, false, nil); err == nil {
+		t.Fatal("NewWarningMatcher accepted an invalid pattern")
+	}
+	config.WarningPatterns = []string{"("}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "warning pattern") {
+		t.Fatalf("Validate() = %v, want an invalid warning pattern error", err)
+	}
+}
+
+// stderrRunner hands stderr to the warning matcher of the context, as
+// SimpleRunner does with a command's stderr.
+type stderrRunner struct {
+	stderr string
+}
+
+func (r *stderrRunner) Run(ctx context.Context, command string, args []string) error {
+	if m := WarningMatcherFromContext(ctx); m != nil {
+		m.Report(r.stderr)
+	}
+	return nil
+}
+
+func TestToolRun_ReportsStderrWarnings(t *testing.T) {
+	config := ToolConfig{Name: "subfinder", Command: "subfinder"}
+	tool := NewConfigurableTool("subfinder", "domain_enum", config, &stderrRunner{stderr: "[WRN] Could not run source chaos: quota exceeded\n"}).(*ConfigurableTool)
+
+	// without a callback the matcher is not set up at all
+	if err := tool.Run(context.Background(), &Options{WorkingDir: t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+
+	var warnings []ToolWarning
+	options := &Options{WorkingDir: t.TempDir(), OnToolWarning: func(w ToolWarning) { warnings = append(warnings, w) }}
+	if err := tool.Run(context.Background(), options); err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0].Tool != "subfinder" || warnings[0].Line != "[WRN] Could not run source chaos: quota exceeded" {
+		t.Fatalf("warnings = %+v", warnings)
+	}
+}
+
+func TestWarningMatcher_ReportLineOncePerLine(t *testing.T) {
+	var warnings []ToolWarning
+	m, err := NewWarningMatcher("subfinder", []string{`(?i)rate limit`}, false, func(w ToolWarning) { warnings = append(warnings, w) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"[WRN] rate limit hit\n", "found a.example.com\n", "[WRN] rate limit hit\n", "[WRN] Rate limit hit again\n"} {
+		m.ReportLine(line)
+	}
+	if len(warnings) != 2 || warnings[0].Line != "[WRN] rate limit hit" || warnings[1].Line != "[WRN] Rate limit hit again" {
+		t.Fatalf("warnings = %+v", warnings)
+	}
+}